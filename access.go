@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+	"github.com/handracs2007/kquiz/telegram"
+	"strings"
+)
+
+// grantAccess lets the tutor read the stats and the transcripts of the student, and tells the tutor how.
+func grantAccess(permissions telegram.Permissions, chatID int64, student string, tutor string) []tgbotapi.Chattable {
+	var replies []tgbotapi.Chattable
+	var msg tgbotapi.MessageConfig
+	tutor = strings.TrimPrefix(tutor, "@")
+	tutorID, err := permissions.Lookup(tutor)
+	if err == nil && tutorID == chatID {
+		msg = tgbotapi.NewMessage(chatID, "You can already read your own stats.")
+	} else if err == nil {
+		err = permissions.Grant(chatID, tutorID, tutor)
+	}
+
+	if err != nil {
+		msg = tgbotapi.NewMessage(chatID, fmt.Sprintf("Grant access failed. %s.", err))
+	} else if tutorID != chatID {
+		msg = tgbotapi.NewMessage(chatID, fmt.Sprintf("@%s can now read your stats and transcripts. Use /revoke @%s "+
+			"to stop it.", tutor, tutor))
+
+		replies = append(replies, tgbotapi.NewMessage(tutorID, fmt.Sprintf("@%s granted you access to their "+
+			"progress, use /stats @%s or /transcript @%s 7d.", student, student, student)))
+	}
+
+	return append(replies, msg)
+}
+
+// revokeAccess withdraws the access granted to the tutor, if any.
+func revokeAccess(permissions telegram.Permissions, chatID int64, tutor string) tgbotapi.Chattable {
+	var msg tgbotapi.MessageConfig
+	tutor = strings.TrimPrefix(tutor, "@")
+	tutorID, err := permissions.Lookup(tutor)
+	if err == nil {
+		err = permissions.Revoke(chatID, tutorID)
+	}
+
+	if err != nil {
+		msg = tgbotapi.NewMessage(chatID, fmt.Sprintf("Revoke access failed. %s.", err))
+	} else {
+		msg = tgbotapi.NewMessage(chatID, fmt.Sprintf("@%s can no longer read your stats and transcripts.", tutor))
+	}
+
+	return msg
+}
+
+// listGrants lists the tutors the user granted access to.
+func listGrants(permissions telegram.Permissions, chatID int64) tgbotapi.Chattable {
+	var msg tgbotapi.MessageConfig
+	tutors, err := permissions.Grantees(chatID)
+	if err != nil {
+		msg = tgbotapi.NewMessage(chatID, fmt.Sprintf("List grants failed. %s.", err))
+	} else if len(tutors) == 0 {
+		msg = tgbotapi.NewMessage(chatID, "Nobody can read your stats and transcripts. Use /grant @tutor to let "+
+			"your tutor monitor your progress.")
+	} else {
+		msg = tgbotapi.NewMessage(chatID, fmt.Sprintf("Your stats and transcripts can be read by @%s.",
+			strings.Join(tutors, ", @")))
+	}
+
+	return msg
+}
+
+// readableOwner returns the user whose stats or transcripts the viewer asks for, the student named by a leading
+// @username in the argument or the viewer, and the rest of the argument. It tells the viewer and returns false when
+// the viewer was not granted access.
+func readableOwner(permissions telegram.Permissions, viewer int64, argument string) (tgbotapi.Chattable, int64,
+	string, bool) {
+	if !strings.HasPrefix(argument, "@") {
+		return nil, viewer, argument, true
+	}
+
+	fields := strings.SplitN(argument, " ", 2)
+	rest := ""
+	if len(fields) == 2 {
+		rest = strings.TrimSpace(fields[1])
+	}
+
+	owner, err := permissions.Lookup(fields[0])
+	if err == nil && permissions.CanRead(viewer, owner) {
+		return nil, owner, rest, true
+	}
+
+	return tgbotapi.NewMessage(viewer, fmt.Sprintf("%s has not granted you access.", fields[0])), 0, "", false
+}
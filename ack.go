@@ -0,0 +1,76 @@
+package main
+
+import (
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+	"log"
+)
+
+// acknowledgedSender replaces the message acknowledging a slow request with the first response sent through it. A
+// text response is edited into the acknowledgement, the others, e.g. documents, are sent once it is deleted. The chat
+// action is shown until then.
+type acknowledgedSender struct {
+	sender
+	chatID    int64
+	messageID int
+	stop      func()
+}
+
+// acknowledge tells the user right away that the slow request is being worked on, with the chat action, e.g. typing,
+// kept until the response, and the text, e.g. Importing your words..., when not empty. It returns the sender of the
+// response, which replaces the acknowledgement.
+func acknowledge(botAPI sender, chatID int64, action string, text string) *acknowledgedSender {
+	ack := &acknowledgedSender{sender: botAPI, chatID: chatID, stop: keepAction(botAPI, chatID, action)}
+	if len(text) == 0 {
+		return ack
+	}
+
+	message, err := botAPI.Send(tgbotapi.NewMessage(chatID, text))
+	if err != nil {
+		log.Printf("Failed to acknowledge request. %s.\n", err)
+		return ack
+	}
+
+	ack.messageID = message.MessageID
+	return ack
+}
+
+func (s *acknowledgedSender) Send(c tgbotapi.Chattable) (tgbotapi.Message, error) {
+	if s.stop != nil {
+		s.stop()
+		s.stop = nil
+	}
+
+	if s.messageID == 0 {
+		return s.sender.Send(c)
+	}
+
+	messageID := s.messageID
+	s.messageID = 0
+
+	// Only the inline keyboards can be edited in, the other keyboards come with a new message.
+	if msg, ok := c.(tgbotapi.MessageConfig); ok && msg.ChatID == s.chatID {
+		keyboard, inline := msg.ReplyMarkup.(tgbotapi.InlineKeyboardMarkup)
+		if msg.ReplyMarkup == nil || inline {
+			edit := tgbotapi.NewEditMessageText(s.chatID, messageID, msg.Text)
+			edit.ParseMode = msg.ParseMode
+			edit.DisableWebPagePreview = msg.DisableWebPagePreview
+			if inline {
+				edit.ReplyMarkup = &keyboard
+			}
+
+			message, err := s.sender.Send(edit)
+			if err == nil {
+				return message, nil
+			}
+
+			log.Printf("Failed to edit acknowledgement, sending the response instead. %s.\n", err)
+		}
+	}
+
+	_, err := s.sender.DeleteMessage(tgbotapi.NewDeleteMessage(s.chatID, messageID))
+	if err != nil {
+		log.Printf("Failed to delete acknowledgement. %s.\n", err)
+	}
+
+	return s.sender.Send(c)
+}
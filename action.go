@@ -0,0 +1,95 @@
+package main
+
+import (
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+	"time"
+)
+
+// The voice chat actions, the client only knows their former names.
+const (
+	chatRecordVoice = "record_voice"
+	chatUploadVoice = "upload_voice"
+)
+
+// actionRefresh is how often a chat action is shown again while the operation goes on, Telegram shows it for 5 seconds
+// or until the next message.
+const actionRefresh = 4 * time.Second
+
+// maxActionDuration bounds how long a chat action is shown, in case the operation never responds.
+const maxActionDuration = time.Minute
+
+// actionSender shows the chat action matching the files sent through it while they are uploaded, e.g. upload_document
+// for the exports. The chat actions go to the client right away rather than through the outbox, they are only worth
+// showing at once.
+type actionSender struct {
+	sender
+	client sender
+}
+
+// Send shows the chat action matching the file sent, if any, and sends it.
+func (s actionSender) Send(c tgbotapi.Chattable) (tgbotapi.Message, error) {
+	if _, ok := c.(tgbotapi.ChatActionConfig); ok {
+		return s.client.Send(c)
+	}
+
+	if chatID, action := uploadAction(c); chatID != 0 {
+		showAction(s.client, chatID, action)
+	}
+
+	return s.sender.Send(c)
+}
+
+// uploadAction returns the chat and the chat action of the file sent, a zero chat ID when there is no file or the chat
+// is not known, e.g. a channel given by its username.
+func uploadAction(c tgbotapi.Chattable) (int64, string) {
+	switch config := c.(type) {
+	case tgbotapi.DocumentConfig:
+		return config.ChatID, tgbotapi.ChatUploadDocument
+	case tgbotapi.PhotoConfig:
+		return config.ChatID, tgbotapi.ChatUploadPhoto
+	case tgbotapi.VoiceConfig:
+		return config.ChatID, chatUploadVoice
+	case tgbotapi.AudioConfig:
+		return config.ChatID, tgbotapi.ChatUploadAudio
+	}
+
+	return 0, ""
+}
+
+// showAction shows the chat action, e.g. typing, to the user.
+func showAction(botAPI sender, chatID int64, action string) {
+	// Telegram answers the chat actions with true rather than a message, which the client fails to decode.
+	_, _ = botAPI.Send(tgbotapi.NewChatAction(chatID, action))
+}
+
+// keepAction keeps showing the chat action to the user while a slow operation goes on, e.g. record_voice while the
+// speech is synthesized. The returned function stops it, once the operation responds.
+func keepAction(botAPI sender, chatID int64, action string) func() {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+
+		ticker := time.NewTicker(actionRefresh)
+		defer ticker.Stop()
+
+		timeout := time.After(maxActionDuration)
+		for {
+			showAction(botAPI, chatID, action)
+
+			select {
+			case <-done:
+				return
+			case <-timeout:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	// Let's wait for the last action to be sent, so that it does not outlive the response.
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
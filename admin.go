@@ -0,0 +1,174 @@
+package main
+
+import (
+	"fmt"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+	"github.com/handracs2007/kquiz/backup"
+	"github.com/handracs2007/kquiz/storage"
+	"github.com/handracs2007/kquiz/telegram"
+	"github.com/handracs2007/kquiz/updates"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// unbannedAudience lists the users the bot messages on its own, leaving the banned chats out.
+type unbannedAudience struct {
+	storage.Audience
+	bans telegram.Banlist
+}
+
+// Users returns the chat IDs of the registered users who are not banned.
+func (audience unbannedAudience) Users() ([]int64, error) {
+	users, err := audience.Audience.Users()
+	if err != nil {
+		return nil, err
+	}
+
+	unbanned := make([]int64, 0, len(users))
+	for _, user := range users {
+		if !audience.bans.IsBanned(user) {
+			unbanned = append(unbanned, user)
+		}
+	}
+
+	return unbanned, nil
+}
+
+// isBanned reports whether the update comes from a banned chat or a banned user, e.g. in a group. It runs before any
+// handler, the banned chats are ignored altogether. The admins are never banned.
+func (d *dispatcher) isBanned(update updates.Update) bool {
+	var chatIDs []int64
+	var from *tgbotapi.User
+	switch {
+	case update.Message != nil:
+		chatIDs = append(chatIDs, update.Message.Chat.ID)
+		from = update.Message.From
+	case update.CallbackQuery != nil:
+		if update.CallbackQuery.Message != nil {
+			chatIDs = append(chatIDs, update.CallbackQuery.Message.Chat.ID)
+		}
+		from = update.CallbackQuery.From
+	case update.MessageReaction != nil:
+		chatIDs = append(chatIDs, update.MessageReaction.Chat.ID)
+		from = update.MessageReaction.User
+	}
+
+	if from != nil {
+		if d.admins[int64(from.ID)] {
+			return false
+		}
+
+		chatIDs = append(chatIDs, int64(from.ID))
+	}
+
+	for _, chatID := range chatIDs {
+		if d.bans.IsBanned(chatID) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// countUsers shows the number of registered users and of banned chats.
+func countUsers(audience storage.Audience, bans telegram.Banlist, chatID int64) tgbotapi.Chattable {
+	var msg tgbotapi.MessageConfig
+	users, err := audience.Users()
+	if err != nil {
+		msg = tgbotapi.NewMessage(chatID, fmt.Sprintf("Count users failed. %s.", err))
+	} else {
+		banned, err := bans.Banned()
+		if err != nil {
+			msg = tgbotapi.NewMessage(chatID, fmt.Sprintf("Count users failed. %s.", err))
+		} else {
+			msg = tgbotapi.NewMessage(chatID, fmt.Sprintf("Registered users: %d\nBanned chats: %d", len(users),
+				banned))
+		}
+	}
+
+	return msg
+}
+
+// banChat bans or unbans the chat given by its chat ID or the username of the user, e.g. /ban @spammer.
+func banChat(bans telegram.Banlist, permissions telegram.Permissions, admins map[int64]bool,
+	chatID int64, command string, argument string) tgbotapi.Chattable {
+	var msg tgbotapi.MessageConfig
+	target, err := strconv.ParseInt(argument, 10, 64)
+	if err != nil && strings.HasPrefix(argument, "@") && !strings.Contains(argument, " ") {
+		target, err = permissions.Lookup(argument)
+		if err != nil {
+			msg = tgbotapi.NewMessage(chatID, fmt.Sprintf("Find user failed. %s.", err))
+		}
+	} else if err != nil {
+		msg = tgbotapi.NewMessage(chatID, fmt.Sprintf("Please provide the chat ID or the username, e.g. %s "+
+			"123456789 or %s @username.", command, command))
+	}
+
+	switch {
+	case err != nil:
+	case command == "/unban":
+		err = bans.Unban(target)
+		if err != nil {
+			msg = tgbotapi.NewMessage(chatID, fmt.Sprintf("Unban failed. %s.", err))
+		} else {
+			msg = tgbotapi.NewMessage(chatID, fmt.Sprintf("Chat %d is unbanned.", target))
+		}
+	case admins[target]:
+		msg = tgbotapi.NewMessage(chatID, "The admins cannot be banned.")
+	default:
+		err = bans.Ban(target)
+		if err != nil {
+			msg = tgbotapi.NewMessage(chatID, fmt.Sprintf("Ban failed. %s.", err))
+		} else {
+			msg = tgbotapi.NewMessage(chatID, fmt.Sprintf("Chat %d is banned, the bot ignores it from now on.",
+				target))
+		}
+	}
+
+	return msg
+}
+
+// takeBackup backs up the databases now, in the background not to hold the other updates up, and tells the admin
+// once done. The reply is nil when the backup is started.
+func takeBackup(backups *backup.Backups, botAPI sender, chatID int64) tgbotapi.Chattable {
+	if backups == nil {
+		return tgbotapi.NewMessage(chatID, "Backups are not configured, set the backup directory or endpoint.")
+	}
+
+	go func() {
+		var msg tgbotapi.MessageConfig
+		info, err := backups.Take(time.Now())
+		if err != nil {
+			msg = tgbotapi.NewMessage(chatID, fmt.Sprintf("Backup failed. %s.", err))
+		} else {
+			msg = tgbotapi.NewMessage(chatID, fmt.Sprintf("Backup %s taken, %d shards, %.1f MB.", info.Name,
+				info.Shards, float64(info.Size)/(1<<20)))
+		}
+
+		respond(botAPI, "backup", msg)
+	}()
+
+	return nil
+}
+
+// listBackups lists the backups available to restore with the -restore flag, the latest ones first.
+func listBackups(backups *backup.Backups, chatID int64) tgbotapi.Chattable {
+	var msg tgbotapi.MessageConfig
+	if backups == nil {
+		msg = tgbotapi.NewMessage(chatID, "Backups are not configured, set the backup directory or endpoint.")
+	} else if list, err := backups.List(); err != nil {
+		msg = tgbotapi.NewMessage(chatID, fmt.Sprintf("List backups failed. %s.", err))
+	} else if len(list) == 0 {
+		msg = tgbotapi.NewMessage(chatID, "There is no backup yet. Send /backup to take one.")
+	} else {
+		lines := []string{"Backups, restore one by starting the bot with -restore <name>:"}
+		for i := len(list) - 1; i >= 0; i-- {
+			lines = append(lines, list[i])
+		}
+
+		msg = tgbotapi.NewMessage(chatID, strings.Join(lines, "\n"))
+	}
+
+	return msg
+}
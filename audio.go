@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+	"github.com/handracs2007/kquiz/providers"
+	"github.com/handracs2007/kquiz/telegram"
+	"log"
+	"time"
+)
+
+// sendSpeech sends the text read aloud as a voice message. The audio is synthesised once and cached, and sent again by
+// the file ID Telegram gave it on the first upload. The cache failures are logged and the text synthesised instead.
+func sendSpeech(cache telegram.AudioCacheManager, tts providers.TextToSpeech, botAPI sender, chat tgbotapi.BaseChat,
+	text string, name string, caption string) error {
+	cached, err := cache.Audio(text, time.Now())
+	if err != nil {
+		log.Printf("Failed to read cached audio. %s.\n", err)
+	}
+
+	if cached != nil && len(cached.FileID) != 0 {
+		voice := tgbotapi.VoiceConfig{BaseFile: tgbotapi.BaseFile{BaseChat: chat, FileID: cached.FileID,
+			UseExisting: true}, Caption: caption}
+
+		_, err = botAPI.Send(voice)
+		if err == nil {
+			return nil
+		}
+
+		// Telegram may have forgotten the file, let's upload it again.
+		log.Printf("Failed to reuse cached audio, uploading it again. %s.\n", err)
+	}
+
+	if cached == nil {
+		audio, err := tts.Synthesize(text, providers.KoreanLanguageCode)
+		if err != nil {
+			return err
+		}
+
+		cached = &telegram.CachedAudio{Audio: audio}
+	}
+
+	voice := tgbotapi.NewVoiceUpload(0, tgbotapi.FileBytes{Name: name, Bytes: cached.Audio})
+	voice.BaseChat = chat
+	voice.Caption = caption
+
+	message, err := botAPI.Send(voice)
+	if err != nil {
+		return err
+	}
+
+	if message.Voice != nil {
+		cached.FileID = message.Voice.FileID
+	}
+	cached.LastPlayed = time.Now()
+
+	err = cache.SaveAudio(text, *cached)
+	if err != nil {
+		log.Printf("Failed to cache audio. %s.\n", err)
+	}
+
+	return nil
+}
+
+func audioCacheStats(manager telegram.AudioCacheManager, chatID int64, maxSize int) tgbotapi.Chattable {
+	var msg tgbotapi.MessageConfig
+	count, size, err := manager.AudioSize()
+	if err != nil {
+		msg = tgbotapi.NewMessage(chatID, fmt.Sprintf("Audio cache stats failed. %s.", err))
+	} else {
+		msg = tgbotapi.NewMessage(chatID, fmt.Sprintf("%d cached audio, %.1f of %.1f MB.", count,
+			float64(size)/(1<<20), float64(maxSize)/(1<<20)))
+	}
+
+	return msg
+}
+
+func purgeAudioCache(manager telegram.AudioCacheManager, chatID int64) tgbotapi.Chattable {
+	var msg tgbotapi.MessageConfig
+	removed, err := manager.PurgeAudio()
+	if err != nil {
+		msg = tgbotapi.NewMessage(chatID, fmt.Sprintf("Purge audio cache failed. %s.", err))
+	} else {
+		msg = tgbotapi.NewMessage(chatID, fmt.Sprintf("%d cached audio removed.", removed))
+	}
+
+	return msg
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"fmt"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+	"github.com/handracs2007/kquiz/audit"
+	"github.com/handracs2007/kquiz/telegram"
+	"log"
+	"strconv"
+	"strings"
+)
+
+// The actions of the buttons of an audit, following auditCallbackPrefix. Keep is followed by the index of the entry
+// kept, the others of the issue are deleted.
+const (
+	auditKeep   = "k:"
+	auditDelete = "d"
+	auditSkip   = "s"
+	auditStop   = "x"
+)
+
+// cleanup is the message walking the user through the issues found by /audit, one at a time. It is edited in place as
+// the user fixes or skips them. In groups, only the member who started the audit and the admins press its buttons.
+type cleanup struct {
+	messageID int
+	userID    int
+	issues    []audit.Issue
+	current   int
+	fixed     int
+	skipped   int
+	stopped   bool
+	deleted   map[string]bool
+}
+
+// advance moves on to the next issue, passing the ones whose entries were deleted meanwhile, e.g. a word in two near
+// duplicates. These are audited again by the next /audit.
+func (c *cleanup) advance() {
+	for c.current++; c.current < len(c.issues); c.current++ {
+		left := 0
+		for _, entry := range c.issues[c.current].Entries {
+			if !c.deleted[entry.Word] {
+				left++
+			}
+		}
+
+		if left == len(c.issues[c.current].Entries) {
+			break
+		}
+	}
+}
+
+// summary returns the number of issues of each kind, e.g. 2 duplicates, 1 conflict.
+func (c *cleanup) summary() string {
+	counts := make(map[string]int)
+	for _, issue := range c.issues {
+		counts[issue.Kind]++
+	}
+
+	parts := make([]string, 0)
+	for _, kind := range []string{audit.Duplicate, audit.NearDuplicate, audit.Conflict, audit.Empty} {
+		if counts[kind] == 0 {
+			continue
+		}
+
+		name := kind
+		if kind == audit.Empty {
+			name = "empty translation"
+		}
+		if counts[kind] > 1 {
+			name += "s"
+		}
+
+		parts = append(parts, fmt.Sprintf("%d %s", counts[kind], name))
+	}
+
+	return strings.Join(parts, ", ")
+}
+
+// text returns the text of the cleanup, the current issue or the outcome once all are done.
+func (c *cleanup) text() string {
+	heading := fmt.Sprintf("Audit found %s.", c.summary())
+	if c.stopped || c.current == len(c.issues) {
+		return fmt.Sprintf("%s\n\nCleanup done, %d fixed and %d skipped.", heading, c.fixed, c.skipped)
+	}
+
+	issue := c.issues[c.current]
+	lines := []string{heading, "", fmt.Sprintf("Issue %d of %d, %s:", c.current+1, len(c.issues), issue.Kind)}
+	for _, entry := range issue.Entries {
+		line := fmt.Sprintf("%q -> %q", entry.Word, entry.Translation)
+		if len(entry.Deck) != 0 {
+			line += fmt.Sprintf(" (%s)", entry.Deck)
+		}
+
+		lines = append(lines, line)
+	}
+
+	if issue.Kind == audit.Empty {
+		lines = append(lines, "", "Delete the word, or skip it and add it again with its translation.")
+	} else {
+		lines = append(lines, "", "Keep one of them, the others are deleted.")
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// keyboard returns the buttons fixing the current issue, skipping it or stopping the cleanup, none once it is done.
+func (c *cleanup) keyboard() *tgbotapi.InlineKeyboardMarkup {
+	if c.stopped || c.current == len(c.issues) {
+		return nil
+	}
+
+	issue := c.issues[c.current]
+	rows := make([][]tgbotapi.InlineKeyboardButton, 0, len(issue.Entries)+1)
+	if issue.Kind == audit.Empty {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("Delete "+issue.Entries[0].Word, auditCallbackPrefix+auditDelete)))
+	} else {
+		for i, entry := range issue.Entries {
+			rows = append(rows, tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData(
+				fmt.Sprintf("Keep %s -> %s", entry.Word, entry.Translation), auditCallbackPrefix+auditKeep+
+					strconv.Itoa(i))))
+		}
+	}
+
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("Skip", auditCallbackPrefix+auditSkip),
+		tgbotapi.NewInlineKeyboardButtonData("Stop", auditCallbackPrefix+auditStop)))
+
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(rows...)
+	return &keyboard
+}
+
+// startAudit scans the words and grammar patterns of the chat for issues and walks the user through them. The
+// message walking through the issues is sent right away to keep its ID, so the reply is nil when issues are found.
+func (d *dispatcher) startAudit(chatID int64, userID int) tgbotapi.Chattable {
+	entries := make([]telegram.Entry, 0)
+	for _, kind := range []string{telegram.KindVocabulary, telegram.KindGrammar} {
+		kindEntries, err := d.words.ListEntries(chatID, kind)
+		if err != nil && err != telegram.ErrWordNotFound {
+			return tgbotapi.NewMessage(chatID, fmt.Sprintf("Audit failed. %s.", err))
+		}
+
+		entries = append(entries, kindEntries...)
+	}
+
+	c := &cleanup{userID: userID, issues: audit.Find(entries), deleted: make(map[string]bool)}
+	if len(c.issues) == 0 {
+		delete(d.cleanups, chatID)
+		return tgbotapi.NewMessage(chatID, fmt.Sprintf("Audit found no issue in your %d words.", len(entries)))
+	}
+
+	msg := tgbotapi.NewMessage(chatID, c.text())
+	msg.ReplyMarkup = c.keyboard()
+
+	message, err := d.bot.Send(msg)
+	if err != nil {
+		log.Printf("Failed to respond to audit request. %s.\n", err)
+		return nil
+	}
+
+	c.messageID = message.MessageID
+	d.cleanups[chatID] = c
+
+	return nil
+}
+
+// actOnAudit handles the buttons of the cleanup: keeping one entry of the issue, deleting the word without
+// translation, skipping the issue or stopping. It returns the edit of the cleanup, or nil when the buttons are those of
+// an earlier audit, stale and ignored.
+func (d *dispatcher) actOnAudit(query *tgbotapi.CallbackQuery) tgbotapi.Chattable {
+	chatID := query.Message.Chat.ID
+	c, ok := d.cleanups[chatID]
+	if !ok || c.messageID != query.Message.MessageID || c.current == len(c.issues) {
+		return nil
+	}
+
+	issue := c.issues[c.current]
+	action := strings.TrimPrefix(query.Data, auditCallbackPrefix)
+
+	var deleted []string
+	switch {
+	case action == auditStop:
+		c.stopped = true
+	case action == auditSkip:
+		c.skipped++
+		c.advance()
+	case action == auditDelete && issue.Kind == audit.Empty:
+		deleted = []string{issue.Entries[0].Word}
+	case strings.HasPrefix(action, auditKeep) && issue.Kind != audit.Empty:
+		index, err := strconv.Atoi(strings.TrimPrefix(action, auditKeep))
+		if err != nil || index < 0 || index >= len(issue.Entries) {
+			return nil
+		}
+
+		for i, entry := range issue.Entries {
+			if i != index {
+				deleted = append(deleted, entry.Word)
+			}
+		}
+	default:
+		return nil
+	}
+
+	for _, word := range deleted {
+		err := d.words.Delete(chatID, word)
+		if err != nil && err != telegram.ErrWordNotFound {
+			return tgbotapi.NewMessage(chatID, fmt.Sprintf("Delete word failed. %s.", err))
+		}
+
+		c.deleted[word] = true
+	}
+	if len(deleted) != 0 {
+		c.fixed++
+		c.advance()
+	}
+
+	edit := tgbotapi.NewEditMessageText(chatID, c.messageID, c.text())
+	edit.ReplyMarkup = c.keyboard()
+
+	if c.stopped || c.current == len(c.issues) {
+		delete(d.cleanups, chatID)
+	}
+
+	return edit
+}
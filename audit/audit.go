@@ -0,0 +1,138 @@
+// Package audit finds the entries of a user worth cleaning up: the same word added twice in different forms, the
+// words added twice with a typo, the words added twice with different translations and the missing translations.
+package audit
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/handracs2007/kquiz/telegram"
+	"golang.org/x/text/unicode/norm"
+)
+
+// The kinds of the issues.
+const (
+	// Duplicate is the same word with the same translation, in different forms, e.g. spaced or cased differently.
+	Duplicate = "duplicate"
+	// NearDuplicate is two words a typo apart with the same translation.
+	NearDuplicate = "near duplicate"
+	// Conflict is the same word with different translations.
+	Conflict = "conflict"
+	// Empty is a word without translation.
+	Empty = "empty"
+)
+
+// Issue is a group of entries to clean up, a single entry for Empty.
+type Issue struct {
+	Kind    string
+	Entries []telegram.Entry
+}
+
+// key returns the form of the word or translation compared: normalised, lower case, without spaces nor punctuation.
+func key(text string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) || unicode.IsPunct(r) {
+			return -1
+		}
+
+		return unicode.ToLower(r)
+	}, norm.NFC.String(text))
+}
+
+// typoApart reports whether the different words differ by a single letter inserted, removed or replaced.
+func typoApart(a []rune, b []rune) bool {
+	if len(a) > len(b) {
+		a, b = b, a
+	}
+	if len(b)-len(a) > 1 {
+		return false
+	}
+
+	i := 0
+	for i < len(a) && a[i] == b[i] {
+		i++
+	}
+	if i == len(b) {
+		return false
+	}
+
+	if len(a) == len(b) {
+		return string(a[i+1:]) == string(b[i+1:])
+	}
+
+	return string(a[i:]) == string(b[i+1:])
+}
+
+// Find returns the issues of the entries, the empty translations last. The entries of an issue are in the order of
+// the words, the issues in the order of their first word.
+func Find(entries []telegram.Entry) []Issue {
+	sorted := append([]telegram.Entry(nil), entries...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Word < sorted[j].Word
+	})
+
+	issues := make([]Issue, 0)
+	var empty []Issue
+
+	// The words of the same key are duplicates, or conflicts when their translations differ.
+	groups := make(map[string][]telegram.Entry)
+	keys := make([]string, 0)
+	for _, entry := range sorted {
+		if len(strings.TrimSpace(entry.Translation)) == 0 {
+			empty = append(empty, Issue{Kind: Empty, Entries: []telegram.Entry{entry}})
+			continue
+		}
+
+		k := key(entry.Word)
+		if _, ok := groups[k]; !ok {
+			keys = append(keys, k)
+		}
+		groups[k] = append(groups[k], entry)
+	}
+
+	// Typos are only looked for between the words of the same translation, one of each key.
+	byTranslation := make(map[string][]string)
+	for _, k := range keys {
+		group := groups[k]
+		if len(group) == 1 {
+			translation := key(group[0].Translation)
+			byTranslation[translation] = append(byTranslation[translation], k)
+			continue
+		}
+
+		kind := Duplicate
+		for _, entry := range group[1:] {
+			if key(entry.Translation) != key(group[0].Translation) {
+				kind = Conflict
+				break
+			}
+		}
+
+		issues = append(issues, Issue{Kind: kind, Entries: group})
+	}
+
+	for _, candidates := range byTranslation {
+		for i := 0; i < len(candidates); i++ {
+			for j := i + 1; j < len(candidates); j++ {
+				// The syllables are decomposed, a typo is usually a single jamo, e.g. 사과 and 사귀. A typo in a
+				// single syllable is another word altogether though, e.g. 눈 and 논.
+				a, b := []rune(norm.NFD.String(candidates[i])), []rune(norm.NFD.String(candidates[j]))
+				if len(a) > 3 && len(b) > 3 && typoApart(a, b) {
+					issues = append(issues, Issue{Kind: NearDuplicate,
+						Entries: []telegram.Entry{groups[candidates[i]][0], groups[candidates[j]][0]}})
+				}
+			}
+		}
+	}
+
+	sort.Slice(issues, func(i, j int) bool {
+		if issues[i].Entries[0].Word != issues[j].Entries[0].Word {
+			return issues[i].Entries[0].Word < issues[j].Entries[0].Word
+		}
+
+		return issues[i].Entries[1].Word < issues[j].Entries[1].Word
+	})
+
+	return append(issues, empty...)
+}
@@ -0,0 +1,420 @@
+// Package backup copies consistent snapshots of the databases to a directory or an S3-compatible bucket periodically,
+// keeping the latest ones, and restores them before the bot opens the databases.
+package backup
+
+import (
+	"errors"
+	"fmt"
+	"go.etcd.io/bbolt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/handracs2007/kquiz/scheduler"
+	"github.com/handracs2007/kquiz/telegram"
+)
+
+// ErrBackupNotFound indicates that the backup to restore does not exist.
+var ErrBackupNotFound = errors.New("backup not found")
+
+// ErrBackupFailed indicates that the backup could not be written to or read from its target.
+var ErrBackupFailed = errors.New("backup failed")
+
+// Latest names the latest backup when restoring.
+const Latest = "latest"
+
+// nameLayout is the layout of the names of the backups, the time they were taken in UTC, sorting chronologically.
+const nameLayout = "20060102T150405Z"
+
+// Config holds the settings of the backups. The backups go to the directory, or to the bucket of the S3-compatible
+// endpoint when one is set. They are off when neither is.
+type Config struct {
+	Dir       string `json:"dir"`
+	Endpoint  string `json:"endpoint"`
+	Bucket    string `json:"bucket"`
+	Prefix    string `json:"prefix"`
+	Region    string `json:"region"`
+	AccessKey string `json:"access_key"`
+	SecretKey string `json:"secret_key"`
+	// Keep is the number of backups kept, the older ones are removed after each backup. Zero keeps them all.
+	Keep int `json:"keep"`
+}
+
+// Enabled reports whether a target is configured.
+func (config Config) Enabled() bool {
+	return len(config.Dir) != 0 || len(config.Endpoint) != 0
+}
+
+// Target defines operations to be fulfilled by the implementation that has capability to store the backup files.
+// The files are named like paths, the name of the backup followed by a slash and the file name of the shard or of the
+// other database included.
+type Target interface {
+	Put(name string, body io.Reader, size int64) error
+	Get(name string) (io.ReadCloser, error)
+	List() ([]string, error)
+	Delete(name string) error
+}
+
+// NewTarget returns the target configured, the bucket when an endpoint is set and the directory otherwise.
+func NewTarget(config Config) Target {
+	if len(config.Endpoint) != 0 {
+		return NewS3Target(config.Endpoint, config.Bucket, config.Prefix, config.Region, config.AccessKey,
+			config.SecretKey)
+	}
+
+	return NewDirTarget(config.Dir)
+}
+
+// Info describes a backup taken.
+type Info struct {
+	Name   string
+	Shards int
+	Size   int64
+}
+
+// Snapshot writes a consistent copy of a database that is not a shard to the file at the path, which does not exist
+// yet, e.g. the SQLite database of the words.
+type Snapshot func(path string) error
+
+// included is a database backed up along with the shards, under its file name.
+type included struct {
+	name     string
+	snapshot Snapshot
+}
+
+// Backups takes the backups of the shards to the target, one at a time.
+type Backups struct {
+	shards   telegram.Shards
+	included []included
+	target   Target
+	keep     int
+	mutex    *sync.Mutex
+	latest   time.Time
+}
+
+// New creates a new instance of Backups
+func New(shards telegram.Shards, target Target, keep int) *Backups {
+	return &Backups{shards: shards, target: target, keep: keep, mutex: &sync.Mutex{}}
+}
+
+// Include backs up the database written by the snapshot along with the shards, under the file name, e.g. the SQLite
+// database of the words. The name must not be the one of a shard.
+func (backups *Backups) Include(name string, snapshot Snapshot) {
+	backups.mutex.Lock()
+	defer backups.mutex.Unlock()
+
+	backups.included = append(backups.included, included{name: name, snapshot: snapshot})
+}
+
+// isShard reports whether the file name within a backup is the one of a shard.
+func isShard(name string) bool {
+	return name == "kquiz.db" || strings.HasPrefix(name, "kquiz-") && strings.HasSuffix(name, ".db")
+}
+
+// names returns the names of the backups the files belong to, oldest first, with the number of shard files of each,
+// the databases included left out.
+func names(files []string) ([]string, map[string]int) {
+	counts := make(map[string]int)
+	for _, file := range files {
+		slash := strings.Index(file, "/")
+		if slash < 0 {
+			continue
+		}
+
+		if _, err := time.Parse(nameLayout, file[:slash]); err == nil {
+			// Every backup is listed, only its shard files are counted.
+			if _, ok := counts[file[:slash]]; !ok {
+				counts[file[:slash]] = 0
+			}
+			if isShard(file[slash+1:]) {
+				counts[file[:slash]]++
+			}
+		}
+	}
+
+	backups := make([]string, 0, len(counts))
+	for name := range counts {
+		backups = append(backups, name)
+	}
+	sort.Strings(backups)
+
+	return backups, counts
+}
+
+// List returns the names of the backups in the target, oldest first.
+// This function returns the following errors:
+//  - ErrBackupFailed
+func (backups *Backups) List() ([]string, error) {
+	files, err := backups.target.List()
+	if err != nil {
+		log.Printf("Failed to list backups. %s.\n", err)
+		return nil, ErrBackupFailed
+	}
+
+	list, _ := names(files)
+	return list, nil
+}
+
+// Take backs up every shard and the databases included at the given time and removes the backups beyond the ones to
+// keep. A read transaction sees a consistent snapshot of each shard and does not block the writers.
+// This function returns the following errors:
+//  - ErrBackupFailed
+func (backups *Backups) Take(now time.Time) (Info, error) {
+	backups.mutex.Lock()
+	defer backups.mutex.Unlock()
+
+	info := Info{Name: now.UTC().Format(nameLayout), Shards: len(backups.shards)}
+	for shard, db := range backups.shards {
+		err := db.View(func(tx *bbolt.Tx) error {
+			// The snapshot streams to the target as it is written, the transaction stays open until it is done.
+			reader, writer := io.Pipe()
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				_, err := tx.WriteTo(writer)
+				_ = writer.CloseWithError(err)
+			}()
+
+			err := backups.target.Put(info.Name+"/"+telegram.ShardPath("kquiz.db", shard), reader, tx.Size())
+			_ = reader.CloseWithError(err)
+			<-done
+
+			info.Size += tx.Size()
+			return err
+		})
+		if err != nil {
+			log.Printf("Failed to back up shard %d. %s.\n", shard, err)
+			return Info{}, ErrBackupFailed
+		}
+	}
+
+	for _, database := range backups.included {
+		size, err := backups.putSnapshot(info.Name+"/"+database.name, database.snapshot)
+		if err != nil {
+			log.Printf("Failed to back up %s. %s.\n", database.name, err)
+			return Info{}, ErrBackupFailed
+		}
+
+		info.Size += size
+	}
+
+	backups.latest = now
+	backups.prune()
+
+	return info, nil
+}
+
+// putSnapshot writes the snapshot to a temporary file and copies it to the target under the name, returning its size.
+func (backups *Backups) putSnapshot(name string, snapshot Snapshot) (int64, error) {
+	dir, err := os.MkdirTemp("", "kquiz-backup")
+	if err != nil {
+		return 0, err
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, filepath.Base(name))
+	err = snapshot(path)
+	if err != nil {
+		return 0, err
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	stat, err := file.Stat()
+	if err != nil {
+		return 0, err
+	}
+
+	return stat.Size(), backups.target.Put(name, file, stat.Size())
+}
+
+// prune removes the oldest backups beyond the ones to keep.
+func (backups *Backups) prune() {
+	if backups.keep <= 0 {
+		return
+	}
+
+	files, err := backups.target.List()
+	if err != nil {
+		log.Printf("Failed to list backups to prune. %s.\n", err)
+		return
+	}
+
+	list, _ := names(files)
+	if len(list) <= backups.keep {
+		return
+	}
+
+	expired := make(map[string]bool)
+	for _, name := range list[:len(list)-backups.keep] {
+		expired[name] = true
+	}
+
+	for _, file := range files {
+		if slash := strings.Index(file, "/"); slash >= 0 && expired[file[:slash]] {
+			err = backups.target.Delete(file)
+			if err != nil {
+				log.Printf("Failed to remove backup file %s. %s.\n", file, err)
+			}
+		}
+	}
+}
+
+// Latest returns the time the latest backup was taken, read from the target until one is taken.
+// This function returns the following errors:
+//  - ErrBackupFailed
+func (backups *Backups) Latest() (time.Time, error) {
+	backups.mutex.Lock()
+	defer backups.mutex.Unlock()
+
+	if backups.latest.IsZero() {
+		files, err := backups.target.List()
+		if err != nil {
+			log.Printf("Failed to list backups. %s.\n", err)
+			return time.Time{}, ErrBackupFailed
+		}
+
+		if list, _ := names(files); len(list) != 0 {
+			backups.latest, _ = time.Parse(nameLayout, list[len(list)-1])
+		}
+	}
+
+	return backups.latest, nil
+}
+
+// Job returns the job taking a backup once the interval went by since the latest one, also across restarts. It
+// checks at the start of every hour.
+func (backups *Backups) Job(interval time.Duration) scheduler.Job {
+	return func(now time.Time) {
+		if now.Minute() != 0 {
+			return
+		}
+
+		latest, err := backups.Latest()
+		if err != nil || now.Sub(latest) < interval {
+			return
+		}
+
+		info, err := backups.Take(now)
+		if err == nil {
+			log.Printf("Backed up %d shards to %s, %d bytes.\n", info.Shards, info.Name, info.Size)
+		}
+	}
+}
+
+// Restore replaces the database files laid out from the path with the shards of the backup, Latest for the latest
+// one, and returns the backup restored. The databases must not be open.
+// This function returns the following errors:
+//  - ErrBackupNotFound
+//  - ErrBackupFailed
+func Restore(target Target, name string, path string) (Info, error) {
+	files, err := target.List()
+	if err != nil {
+		log.Printf("Failed to list backups. %s.\n", err)
+		return Info{}, ErrBackupFailed
+	}
+
+	list, counts := names(files)
+	if name == Latest && len(list) != 0 {
+		name = list[len(list)-1]
+	}
+
+	if counts[name] == 0 {
+		return Info{}, ErrBackupNotFound
+	}
+
+	info := Info{Name: name, Shards: counts[name]}
+	for shard := 0; shard < info.Shards; shard++ {
+		written, err := restoreShard(target, name+"/"+telegram.ShardPath("kquiz.db", shard),
+			telegram.ShardPath(path, shard))
+		if err != nil {
+			log.Printf("Failed to restore shard %d of backup %s. %s.\n", shard, name, err)
+			return Info{}, ErrBackupFailed
+		}
+
+		info.Size += written
+	}
+
+	return info, nil
+}
+
+// RestoreIncluded replaces the database file at the path with the one included under the file name in the backup,
+// Latest for the latest one, and returns its size. The SQLite journals left next to it are removed, they belong to
+// the database replaced. The database must not be open.
+// This function returns the following errors:
+//  - ErrBackupNotFound
+//  - ErrBackupFailed
+func RestoreIncluded(target Target, name string, file string, path string) (int64, error) {
+	files, err := target.List()
+	if err != nil {
+		log.Printf("Failed to list backups. %s.\n", err)
+		return 0, ErrBackupFailed
+	}
+
+	if list, _ := names(files); name == Latest && len(list) != 0 {
+		name = list[len(list)-1]
+	}
+
+	found := false
+	for _, existing := range files {
+		found = found || existing == name+"/"+file
+	}
+	if !found {
+		return 0, ErrBackupNotFound
+	}
+
+	written, err := restoreShard(target, name+"/"+file, path)
+	if err != nil {
+		log.Printf("Failed to restore %s of backup %s. %s.\n", file, name, err)
+		return 0, ErrBackupFailed
+	}
+
+	for _, journal := range []string{path + "-wal", path + "-shm", path + "-journal"} {
+		err = os.Remove(journal)
+		if err != nil && !os.IsNotExist(err) {
+			log.Printf("Failed to remove %s. %s.\n", journal, err)
+			return 0, ErrBackupFailed
+		}
+	}
+
+	return written, nil
+}
+
+// restoreShard downloads the backup file into the database file and returns its size.
+func restoreShard(target Target, name string, path string) (int64, error) {
+	body, err := target.Get(name)
+	if err != nil {
+		return 0, err
+	}
+	defer body.Close()
+
+	// Let's write to a temporary file first so that a broken download never replaces the database.
+	file, err := os.Create(path + ".part")
+	if err != nil {
+		return 0, err
+	}
+
+	written, err := io.Copy(file, body)
+	closeErr := file.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err == nil && written == 0 {
+		err = fmt.Errorf("empty backup file %s", name)
+	}
+	if err != nil {
+		_ = os.Remove(path + ".part")
+		return 0, err
+	}
+
+	return written, os.Rename(path+".part", path)
+}
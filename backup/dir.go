@@ -0,0 +1,123 @@
+package backup
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// DirTarget stores the backup files in a directory, e.g. a mounted network volume.
+type DirTarget struct {
+	dir string
+}
+
+// NewDirTarget creates a new instance of DirTarget
+func NewDirTarget(dir string) DirTarget {
+	return DirTarget{dir: dir}
+}
+
+// path returns the path of the file, refusing the names escaping the directory.
+func (target DirTarget) path(name string) (string, error) {
+	path := filepath.Join(target.dir, filepath.FromSlash(name))
+	if !strings.HasPrefix(path, filepath.Clean(target.dir)+string(filepath.Separator)) {
+		return "", fmt.Errorf("invalid backup file name %s", name)
+	}
+
+	return path, nil
+}
+
+// Put writes the file, under a temporary name until it is complete.
+func (target DirTarget) Put(name string, body io.Reader, size int64) error {
+	path, err := target.path(name)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Create(path + ".part")
+	if err != nil {
+		return err
+	}
+
+	written, err := io.Copy(file, body)
+	if err == nil {
+		err = file.Sync()
+	}
+	closeErr := file.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err == nil && written != size {
+		err = fmt.Errorf("truncated backup file, %d of %d bytes", written, size)
+	}
+	if err != nil {
+		_ = os.Remove(path + ".part")
+		return err
+	}
+
+	return os.Rename(path+".part", path)
+}
+
+// Get opens the file.
+func (target DirTarget) Get(name string) (io.ReadCloser, error) {
+	path, err := target.path(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return os.Open(path)
+}
+
+// List returns the names of the files of the backups, the temporary ones left out.
+func (target DirTarget) List() ([]string, error) {
+	entries, err := os.ReadDir(target.dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	files := make([]string, 0)
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+
+		backupFiles, err := os.ReadDir(filepath.Join(target.dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		for _, file := range backupFiles {
+			if !file.IsDir() && !strings.HasSuffix(file.Name(), ".part") {
+				files = append(files, entry.Name()+"/"+file.Name())
+			}
+		}
+	}
+
+	return files, nil
+}
+
+// Delete removes the file, and the directory of its backup once empty.
+func (target DirTarget) Delete(name string) error {
+	path, err := target.path(name)
+	if err != nil {
+		return err
+	}
+
+	err = os.Remove(path)
+	if err != nil {
+		return err
+	}
+
+	// The directory still holds the other files of the backup until they are removed too.
+	_ = os.Remove(filepath.Dir(path))
+	return nil
+}
@@ -0,0 +1,208 @@
+package backup
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// unsignedPayload is the payload hash of the requests whose body is not signed, the snapshots being streamed.
+const unsignedPayload = "UNSIGNED-PAYLOAD"
+
+// S3Target stores the backup files in a bucket of an S3-compatible object storage, e.g. AWS S3 or MinIO. The bucket
+// is addressed in the path so that any endpoint works, the requests are signed with AWS Signature Version 4.
+type S3Target struct {
+	client    *http.Client
+	endpoint  string
+	bucket    string
+	prefix    string
+	region    string
+	accessKey string
+	secretKey string
+}
+
+// NewS3Target creates a new instance of S3Target storing the files under the prefix of the bucket. The region
+// defaults to us-east-1, which the S3-compatible storages usually accept.
+func NewS3Target(endpoint string, bucket string, prefix string, region string, accessKey string,
+	secretKey string) S3Target {
+	if len(region) == 0 {
+		region = "us-east-1"
+	}
+
+	if len(prefix) != 0 && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+
+	return S3Target{client: &http.Client{Timeout: time.Hour}, endpoint: strings.TrimSuffix(endpoint, "/"),
+		bucket: bucket, prefix: strings.TrimPrefix(prefix, "/"), region: region, accessKey: accessKey,
+		secretKey: secretKey}
+}
+
+// escape escapes the string the way AWS Signature Version 4 expects, every byte but the unreserved characters.
+func escape(value string, slash bool) string {
+	var escaped strings.Builder
+	for _, b := range []byte(value) {
+		if 'A' <= b && b <= 'Z' || 'a' <= b && b <= 'z' || '0' <= b && b <= '9' || strings.IndexByte("-._~", b) >= 0 ||
+			slash && b == '/' {
+			escaped.WriteByte(b)
+		} else {
+			fmt.Fprintf(&escaped, "%%%02X", b)
+		}
+	}
+
+	return escaped.String()
+}
+
+// hmacSHA256 returns the HMAC-SHA256 of the data with the key.
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	_, _ = mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// request sends a signed request for the key of the bucket, the bucket itself when the key is empty.
+func (target S3Target) request(method string, key string, query url.Values, body io.Reader,
+	size int64) (*http.Response, error) {
+	path := "/" + escape(target.bucket, false)
+	if len(key) != 0 {
+		path += "/" + escape(key, true)
+	}
+
+	// The query is canonical when its names and values are escaped and sorted.
+	names := make([]string, 0, len(query))
+	for name := range query {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	params := make([]string, 0, len(names))
+	for _, name := range names {
+		params = append(params, escape(name, false)+"="+escape(query.Get(name), false))
+	}
+	rawQuery := strings.Join(params, "&")
+
+	requestURL := target.endpoint + path
+	if len(rawQuery) != 0 {
+		requestURL += "?" + rawQuery
+	}
+
+	req, err := http.NewRequest(method, requestURL, body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.ContentLength = size
+	}
+
+	now := time.Now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	scope := now.Format("20060102") + "/" + target.region + "/s3/aws4_request"
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", unsignedPayload)
+
+	canonical := strings.Join([]string{method, path, rawQuery, "host:" + req.URL.Host,
+		"x-amz-content-sha256:" + unsignedPayload, "x-amz-date:" + amzDate, "",
+		"host;x-amz-content-sha256;x-amz-date", unsignedPayload}, "\n")
+	hash := sha256.Sum256([]byte(canonical))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(hash[:])
+
+	signingKey := hmacSHA256([]byte("AWS4"+target.secretKey), now.Format("20060102"))
+	for _, part := range []string{target.region, "s3", "aws4_request"} {
+		signingKey = hmacSHA256(signingKey, part)
+	}
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, "+
+		"SignedHeaders=host;x-amz-content-sha256;x-amz-date, Signature=%s", target.accessKey, scope,
+		hex.EncodeToString(hmacSHA256(signingKey, stringToSign))))
+
+	resp, err := target.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode/100 != 2 {
+		message, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		resp.Body.Close()
+		return nil, fmt.Errorf("%s %s failed with status %d. %s", method, path, resp.StatusCode,
+			strings.TrimSpace(string(message)))
+	}
+
+	return resp, nil
+}
+
+// Put uploads the file.
+func (target S3Target) Put(name string, body io.Reader, size int64) error {
+	resp, err := target.request(http.MethodPut, target.prefix+name, nil, body, size)
+	if err != nil {
+		return err
+	}
+
+	return resp.Body.Close()
+}
+
+// Get downloads the file.
+func (target S3Target) Get(name string) (io.ReadCloser, error) {
+	resp, err := target.request(http.MethodGet, target.prefix+name, nil, nil, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	return resp.Body, nil
+}
+
+// listResult is the response of ListObjectsV2.
+type listResult struct {
+	Contents []struct {
+		Key string `xml:"Key"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+// List returns the names of the files under the prefix, a page at a time.
+func (target S3Target) List() ([]string, error) {
+	files := make([]string, 0)
+	query := url.Values{"list-type": {"2"}, "prefix": {target.prefix}}
+	for {
+		resp, err := target.request(http.MethodGet, "", query, nil, 0)
+		if err != nil {
+			return nil, err
+		}
+
+		var result listResult
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, content := range result.Contents {
+			files = append(files, strings.TrimPrefix(content.Key, target.prefix))
+		}
+
+		if !result.IsTruncated {
+			return files, nil
+		}
+
+		query.Set("continuation-token", result.NextContinuationToken)
+	}
+}
+
+// Delete removes the file.
+func (target S3Target) Delete(name string) error {
+	resp, err := target.request(http.MethodDelete, target.prefix+name, nil, nil, 0)
+	if err != nil {
+		return err
+	}
+
+	return resp.Body.Close()
+}
@@ -0,0 +1,137 @@
+// Package card composes the images the users share on posters and slides, e.g. a deck with the QR code of its link,
+// and reads the QR codes back from the photos of them.
+package card
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+
+	"github.com/skip2/go-qrcode"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+)
+
+// The layout of the card, in pixels.
+const (
+	width     = 600
+	margin    = 40
+	qrSize    = 400
+	titleSize = 32
+	textSize  = 20
+	linkSize  = 14
+	lineGap   = 12
+)
+
+// Card represents a share card, the title and the lines of text above the QR code of the link, which is printed below
+// it for the readers who cannot scan it.
+type Card struct {
+	Title string
+	Lines []string
+	Link  string
+}
+
+// faces returns the faces of the title, the lines and the link, from the TrueType font when there is one. The built-in
+// face only has ASCII.
+func faces(ttf []byte) ([3]font.Face, error) {
+	if len(ttf) == 0 {
+		return [3]font.Face{basicfont.Face7x13, basicfont.Face7x13, basicfont.Face7x13}, nil
+	}
+
+	parsed, err := opentype.Parse(ttf)
+	if err != nil {
+		return [3]font.Face{}, err
+	}
+
+	var result [3]font.Face
+	for i, size := range []float64{titleSize, textSize, linkSize} {
+		result[i], err = opentype.NewFace(parsed, &opentype.FaceOptions{Size: size, DPI: 72, Hinting: font.HintingFull})
+		if err != nil {
+			return [3]font.Face{}, err
+		}
+	}
+
+	return result, nil
+}
+
+// fit shortens the text with an ellipsis until it fits in the width.
+func fit(face font.Face, text string, width int) string {
+	runes := []rune(text)
+	for i := len(runes); i > 0; i-- {
+		candidate := string(runes[:i])
+		if i < len(runes) {
+			candidate += "…"
+		}
+
+		if font.MeasureString(face, candidate).Ceil() <= width {
+			return candidate
+		}
+	}
+
+	return ""
+}
+
+// Render draws the card as a PNG image, the text in the TrueType font given, which should cover the text, or in a
+// built-in ASCII face when there is none.
+func Render(card Card, ttf []byte) ([]byte, error) {
+	code, err := qrcode.New(card.Link, qrcode.Medium)
+	if err != nil {
+		return nil, err
+	}
+
+	faces, err := faces(ttf)
+	if err != nil {
+		return nil, err
+	}
+
+	lines := []struct {
+		face font.Face
+		text string
+	}{{faces[0], card.Title}}
+	for _, line := range card.Lines {
+		lines = append(lines, struct {
+			face font.Face
+			text string
+		}{faces[1], line})
+	}
+
+	height := margin
+	for _, line := range lines {
+		height += line.face.Metrics().Height.Ceil() + lineGap
+	}
+	height += qrSize + faces[2].Metrics().Height.Ceil() + lineGap + margin
+
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(img, img.Bounds(), image.White, image.Point{}, draw.Src)
+
+	drawer := &font.Drawer{Dst: img, Src: image.NewUniform(color.Black)}
+	center := func(face font.Face, text string, top int) int {
+		drawer.Face = face
+		text = fit(face, text, width-2*margin)
+		x := (width - font.MeasureString(face, text).Ceil()) / 2
+		drawer.Dot = fixed.P(x, top+face.Metrics().Ascent.Ceil())
+		drawer.DrawString(text)
+		return top + face.Metrics().Height.Ceil() + lineGap
+	}
+
+	top := margin
+	for _, line := range lines {
+		top = center(line.face, line.text, top)
+	}
+
+	qrLeft := (width - qrSize) / 2
+	draw.Draw(img, image.Rect(qrLeft, top, qrLeft+qrSize, top+qrSize), code.Image(qrSize), image.Point{}, draw.Src)
+	center(faces[2], card.Link, top+qrSize)
+
+	var buffer bytes.Buffer
+	err = png.Encode(&buffer, img)
+	if err != nil {
+		return nil, err
+	}
+
+	return buffer.Bytes(), nil
+}
@@ -0,0 +1,96 @@
+package card
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"strings"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+)
+
+// The layout of the large print prompts, in pixels.
+const (
+	promptWidth  = 800
+	promptSize   = 64
+	promptMargin = 48
+)
+
+// ErrNoFont is returned when there is no TrueType font to draw the Hangul with.
+var ErrNoFont = errors.New("no font to draw the text with")
+
+// wrap breaks the text into the lines fitting in the width, breaking between the words and shortening a word too long
+// for a line of its own.
+func wrap(face font.Face, text string, width int) []string {
+	var lines []string
+	line := ""
+	for _, word := range strings.Fields(text) {
+		candidate := word
+		if len(line) != 0 {
+			candidate = line + " " + word
+		}
+
+		if font.MeasureString(face, candidate).Ceil() <= width {
+			line = candidate
+			continue
+		}
+
+		if len(line) != 0 {
+			lines = append(lines, line)
+		}
+		line = fit(face, word, width)
+	}
+
+	if len(line) != 0 {
+		lines = append(lines, line)
+	}
+
+	return lines
+}
+
+// RenderPrompt draws the text in large type as a PNG image, for the users who struggle to read the Hangul at the font
+// size of their phone. The built-in face has no Hangul, hence, the TrueType font is required.
+// This function returns the following errors:
+//  - ErrNoFont
+func RenderPrompt(text string, ttf []byte) ([]byte, error) {
+	if len(ttf) == 0 {
+		return nil, ErrNoFont
+	}
+
+	parsed, err := opentype.Parse(ttf)
+	if err != nil {
+		return nil, err
+	}
+
+	face, err := opentype.NewFace(parsed, &opentype.FaceOptions{Size: promptSize, DPI: 72, Hinting: font.HintingFull})
+	if err != nil {
+		return nil, err
+	}
+
+	lines := wrap(face, text, promptWidth-2*promptMargin)
+	lineHeight := face.Metrics().Height.Ceil() + lineGap
+	height := 2*promptMargin + len(lines)*lineHeight
+
+	img := image.NewRGBA(image.Rect(0, 0, promptWidth, height))
+	draw.Draw(img, img.Bounds(), image.White, image.Point{}, draw.Src)
+
+	drawer := &font.Drawer{Dst: img, Src: image.NewUniform(color.Black), Face: face}
+	for i, line := range lines {
+		x := (promptWidth - font.MeasureString(face, line).Ceil()) / 2
+		drawer.Dot = fixed.P(x, promptMargin+i*lineHeight+face.Metrics().Ascent.Ceil())
+		drawer.DrawString(line)
+	}
+
+	var buffer bytes.Buffer
+	err = png.Encode(&buffer, img)
+	if err != nil {
+		return nil, err
+	}
+
+	return buffer.Bytes(), nil
+}
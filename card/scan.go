@@ -0,0 +1,38 @@
+package card
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	_ "image/jpeg" // Telegram sends the photos as JPEG.
+	_ "image/png"
+
+	"github.com/makiuchi-d/gozxing"
+	"github.com/makiuchi-d/gozxing/qrcode"
+)
+
+// ErrNoQRCode indicates that no QR code could be read from the image.
+var ErrNoQRCode = errors.New("no QR code found")
+
+// Scan reads the text of the QR code in the image, e.g. a photo of a card on a poster.
+// This function returns the following errors:
+//  - ErrNoQRCode
+func Scan(data []byte) (string, error) {
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return "", ErrNoQRCode
+	}
+
+	bitmap, err := gozxing.NewBinaryBitmapFromImage(img)
+	if err != nil {
+		return "", ErrNoQRCode
+	}
+
+	hints := map[gozxing.DecodeHintType]interface{}{gozxing.DecodeHintType_TRY_HARDER: true}
+	result, err := qrcode.NewQRCodeReader().Decode(bitmap, hints)
+	if err != nil {
+		return "", ErrNoQRCode
+	}
+
+	return result.GetText(), nil
+}
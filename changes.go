@@ -0,0 +1,172 @@
+package main
+
+import (
+	"fmt"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+	"github.com/handracs2007/kquiz/scheduler"
+	"github.com/handracs2007/kquiz/storage"
+	"github.com/handracs2007/kquiz/telegram"
+	"log"
+	"sort"
+	"strings"
+	"time"
+)
+
+// snapshotInterval is how often the words of the users are snapshotted.
+const snapshotInterval = 7 * 24 * time.Hour
+
+// defaultChangesPeriod is the period of /changes when none is given.
+const defaultChangesPeriod = 30 * 24 * time.Hour
+
+// changesListSize is the number of words listed per section of /changes, the others are only counted.
+const changesListSize = 20
+
+// wordChanges are the words added, removed and edited since a snapshot, each sorted.
+type wordChanges struct {
+	added   []string
+	removed []string
+	edited  []string
+}
+
+// userEntries returns the words and the grammar patterns of the user.
+func userEntries(lister storage.Lister, chatID int64) ([]telegram.Entry, error) {
+	entries := make([]telegram.Entry, 0)
+	for _, kind := range []string{telegram.KindVocabulary, telegram.KindGrammar} {
+		kindEntries, err := lister.ListEntries(chatID, kind)
+		if err != nil && err != telegram.ErrWordNotFound {
+			return nil, err
+		}
+
+		entries = append(entries, kindEntries...)
+	}
+
+	return entries, nil
+}
+
+// diffSnapshots returns the changes from the earlier snapshot to the later one. A word is edited when its translation,
+// example, notes or tags changed.
+func diffSnapshots(earlier telegram.Snapshot, later telegram.Snapshot) wordChanges {
+	var changes wordChanges
+	for word, now := range later.Words {
+		then, ok := earlier.Words[word]
+		if !ok {
+			changes.added = append(changes.added, word)
+		} else if then.Translation != now.Translation || then.Example != now.Example || then.Notes != now.Notes ||
+			strings.Join(then.Tags, " ") != strings.Join(now.Tags, " ") {
+			changes.edited = append(changes.edited, word)
+		}
+	}
+
+	for word := range earlier.Words {
+		if _, ok := later.Words[word]; !ok {
+			changes.removed = append(changes.removed, word)
+		}
+	}
+
+	sort.Strings(changes.added)
+	sort.Strings(changes.removed)
+	sort.Strings(changes.edited)
+
+	return changes
+}
+
+// snapshotWords snapshots the words of the users whose latest snapshot is a week old, once an hour. The users are
+// snapshotted a week after their first snapshot rather than all at once.
+func snapshotWords(audience storage.Audience, lister storage.Lister,
+	snapshotter telegram.Snapshotter) scheduler.Job {
+	return func(now time.Time) {
+		if now.Minute() != 0 {
+			return
+		}
+
+		users, err := audience.Users()
+		if err != nil {
+			log.Printf("Failed to list users for snapshots. %s.\n", err)
+			return
+		}
+
+		for _, chatID := range users {
+			latest, err := snapshotter.LatestSnapshot(chatID)
+			if err != nil && err != telegram.ErrSnapshotNotFound {
+				continue
+			}
+			if latest != nil && now.Sub(latest.At) < snapshotInterval {
+				continue
+			}
+
+			entries, err := userEntries(lister, chatID)
+			if err != nil {
+				log.Printf("Failed to list words for snapshot. %s.\n", err)
+				continue
+			}
+
+			err = snapshotter.SaveSnapshot(chatID, telegram.NewSnapshot(entries, now))
+			if err != nil {
+				log.Printf("Failed to save snapshot. %s.\n", err)
+			}
+		}
+	}
+}
+
+// changesSection returns the lines listing the words of a section of /changes, e.g. Added:, with their current or
+// past translations.
+func changesSection(heading string, words []string, snapshot telegram.Snapshot) []string {
+	if len(words) == 0 {
+		return nil
+	}
+
+	lines := []string{"", heading}
+	for i, word := range words {
+		if i == changesListSize {
+			lines = append(lines, fmt.Sprintf("... and %d more.", len(words)-changesListSize))
+			break
+		}
+
+		lines = append(lines, fmt.Sprintf("%s -> %s", word, snapshot.Words[word].Translation))
+	}
+
+	return lines
+}
+
+// showChanges tells the user the words added, removed and edited over the period, since the snapshot taken then. When
+// the words were first snapshotted later, the changes are since that first snapshot.
+func showChanges(lister storage.Lister, snapshotter telegram.Snapshotter, manager telegram.SettingsManager,
+	chatID int64, period time.Duration) tgbotapi.Chattable {
+	var msg tgbotapi.MessageConfig
+	settings, err := manager.Settings(chatID)
+	if err != nil {
+		log.Printf("Failed to read settings, using the defaults. %s.\n", err)
+	}
+
+	now := time.Now()
+	snapshot, err := snapshotter.SnapshotAt(chatID, now.Add(-period))
+	var entries []telegram.Entry
+	if err == nil {
+		entries, err = userEntries(lister, chatID)
+	}
+
+	if err == telegram.ErrSnapshotNotFound {
+		msg = tgbotapi.NewMessage(chatID, "There is no snapshot of your words yet, one is taken every week. "+
+			"Come back next week to see how they changed.")
+	} else if err != nil {
+		msg = tgbotapi.NewMessage(chatID, fmt.Sprintf("Get changes failed. %s.", err))
+	} else {
+		current := telegram.NewSnapshot(entries, now)
+		changes := diffSnapshots(*snapshot, current)
+
+		since := snapshot.At.In(settings.Location()).Format("2 Jan 2006")
+		lines := []string{fmt.Sprintf("Since %s, %d words added, %d removed and %d edited. You have %d words now.",
+			since, len(changes.added), len(changes.removed), len(changes.edited), len(entries))}
+		if snapshot.At.After(now.Add(-period)) {
+			lines[0] = fmt.Sprintf("Your words were first snapshotted on %s. %s", since, lines[0])
+		}
+
+		lines = append(lines, changesSection("Added:", changes.added, current)...)
+		lines = append(lines, changesSection("Removed:", changes.removed, *snapshot)...)
+		lines = append(lines, changesSection("Edited:", changes.edited, current)...)
+
+		msg = tgbotapi.NewMessage(chatID, strings.Join(lines, "\n"))
+	}
+
+	return msg
+}
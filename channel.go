@@ -0,0 +1,353 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+	"github.com/handracs2007/kquiz/providers"
+	"github.com/handracs2007/kquiz/publish"
+	"github.com/handracs2007/kquiz/scheduler"
+	"github.com/handracs2007/kquiz/storage"
+	"github.com/handracs2007/kquiz/telegram"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// errNoChannel indicates that no channel is configured to publish the word of the day.
+var errNoChannel = errors.New("no channel configured")
+
+// errNotApproved indicates that the next word of the day waits for the approval of an admin.
+var errNotApproved = errors.New("the next word is not approved yet, see /queue")
+
+// errEmptyDeck indicates that the curated deck of the channel has no words to publish.
+var errEmptyDeck = errors.New("the deck has no words")
+
+// channelChat addresses the channel by its numeric ID or its @username.
+func channelChat(channel *telegram.Channel) tgbotapi.BaseChat {
+	if chatID, err := strconv.ParseInt(channel.Chat, 10, 64); err == nil {
+		return tgbotapi.BaseChat{ChatID: chatID}
+	}
+
+	return tgbotapi.BaseChat{ChannelUsername: channel.Chat}
+}
+
+// deckEntries lists the vocabulary of the curated deck of the channel.
+func deckEntries(lister storage.Lister, channel *telegram.Channel) ([]telegram.Entry, error) {
+	entries, err := lister.ListEntries(channel.OwnerID, telegram.KindVocabulary)
+	if err != nil {
+		return nil, err
+	}
+
+	deck := make([]telegram.Entry, 0, len(entries))
+	for _, entry := range entries {
+		if len(channel.Deck) == 0 || entry.Deck == channel.Deck {
+			deck = append(deck, entry)
+		}
+	}
+
+	return deck, nil
+}
+
+// contentQueue returns the upcoming posts of the channel, topped up with the next words of the curated deck.
+func contentQueue(manager telegram.ChannelManager, lister storage.Lister,
+	channel *telegram.Channel) ([]telegram.Draft, error) {
+	entries, err := deckEntries(lister, channel)
+	if err != nil {
+		return nil, err
+	}
+
+	posts, err := manager.Posts(0)
+	if err != nil {
+		return nil, err
+	}
+
+	queue, err := manager.Queue()
+	if err != nil {
+		return nil, err
+	}
+
+	filled := publish.Fill(queue, entries, posts)
+	if len(filled) != len(queue) {
+		err = manager.SaveQueue(filled)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return filled, nil
+}
+
+// publishWordOfTheDay posts the next word of the content queue to the channel, read aloud when text-to-speech is
+// available, and records the post.
+func publishWordOfTheDay(manager telegram.ChannelManager, lister storage.Lister, tts providers.TextToSpeech,
+	botAPI sender, now time.Time) (*telegram.Post, error) {
+	channel, err := manager.Channel()
+	if err != nil {
+		return nil, err
+	}
+
+	if channel == nil {
+		return nil, errNoChannel
+	}
+
+	queue, err := contentQueue(manager, lister, channel)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(queue) == 0 {
+		return nil, errEmptyDeck
+	}
+
+	if channel.Approval && !queue[0].Approved {
+		return nil, errNotApproved
+	}
+
+	post := publish.NewPost(queue[0], now)
+
+	message := tgbotapi.MessageConfig{BaseChat: channelChat(channel), Text: publish.Text(post)}
+	var chattable tgbotapi.Chattable = message
+	if providers.Available(tts) {
+		// The audio is a bonus, the post goes out as text when it cannot be synthesised.
+		audio, err := tts.Synthesize(publish.Speech(post), providers.KoreanLanguageCode)
+		if err != nil {
+			log.Printf("Failed to synthesise word of the day. %s.\n", err)
+		} else {
+			voice := tgbotapi.NewVoiceUpload(0, tgbotapi.FileBytes{Name: "word.ogg", Bytes: audio})
+			voice.BaseChat = channelChat(channel)
+			voice.Caption = message.Text
+			chattable = voice
+		}
+	}
+
+	_, err = botAPI.Send(chattable)
+	if err != nil {
+		return nil, err
+	}
+
+	err = manager.AddPost(post)
+	if err != nil {
+		return nil, err
+	}
+
+	return &post, manager.SaveQueue(queue[1:])
+}
+
+// parseChannel parses the argument of /channel, the channel followed by the optional deck name.
+func parseChannel(argument string, ownerID int64) (*telegram.Channel, bool) {
+	fields := strings.Fields(argument)
+	if len(fields) == 0 {
+		return nil, false
+	}
+
+	if !strings.HasPrefix(fields[0], "@") {
+		if _, err := strconv.ParseInt(fields[0], 10, 64); err != nil {
+			return nil, false
+		}
+	}
+
+	deck := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(argument), fields[0]))
+	return &telegram.Channel{Chat: fields[0], OwnerID: ownerID, Deck: deck}, true
+}
+
+func setChannel(manager telegram.ChannelManager, chatID int64, channel *telegram.Channel,
+	postTime string) tgbotapi.Chattable {
+	var msg tgbotapi.MessageConfig
+	err := manager.SaveChannel(channel)
+	if err == nil {
+		// The upcoming posts came from the previous deck, let's start over.
+		err = manager.SaveQueue(nil)
+	}
+
+	if err != nil {
+		msg = tgbotapi.NewMessage(chatID, fmt.Sprintf("Save channel failed. %s.", err))
+	} else if channel == nil {
+		msg = tgbotapi.NewMessage(chatID, "The word of the day is not published anymore.")
+	} else {
+		deck := "all your words"
+		if len(channel.Deck) != 0 {
+			deck = fmt.Sprintf("your deck %s", channel.Deck)
+		}
+
+		msg = tgbotapi.NewMessage(chatID, fmt.Sprintf("The word of the day from %s will be published to %s daily at "+
+			"%s UTC. Make sure the bot is an admin of the channel.", deck, channel.Chat, postTime))
+	}
+
+	return msg
+}
+
+// postWordOfTheDay publishes the word of the day to the channel now and returns the response to the user.
+func postWordOfTheDay(manager telegram.ChannelManager, lister storage.Lister, tts providers.TextToSpeech,
+	botAPI sender, chatID int64) tgbotapi.Chattable {
+	var msg tgbotapi.MessageConfig
+	post, err := publishWordOfTheDay(manager, lister, tts, botAPI, time.Now())
+	if err != nil {
+		msg = tgbotapi.NewMessage(chatID, fmt.Sprintf("Publish failed. %s.", err))
+	} else {
+		msg = tgbotapi.NewMessage(chatID, fmt.Sprintf("Published %s.", post.Word))
+	}
+
+	return msg
+}
+
+// wordOfTheDay returns the job publishing the word of the day to the channel once a day, from the given time in UTC.
+// The admins are alerted when the word waits for their approval.
+func wordOfTheDay(manager telegram.ChannelManager, lister storage.Lister, tts providers.TextToSpeech, botAPI sender,
+	postTime string, alert func(text string)) scheduler.Job {
+	// A failed post is retried an hour later rather than on every tick.
+	var retryAt time.Time
+	alertedDay := ""
+
+	return func(now time.Time) {
+		day := now.UTC().Format(publish.DayLayout)
+		if now.UTC().Format("15:04") < postTime || now.Before(retryAt) {
+			return
+		}
+
+		posts, err := manager.Posts(1)
+		if err != nil || (len(posts) != 0 && posts[0].Day == day) {
+			return
+		}
+
+		_, err = publishWordOfTheDay(manager, lister, tts, botAPI, now)
+		if err == errNoChannel {
+			return
+		}
+
+		if err != nil {
+			log.Printf("Failed to publish word of the day. %s.\n", err)
+			retryAt = now.Add(time.Hour)
+
+			if err == errNotApproved && alertedDay != day {
+				alertedDay = day
+				alert("The word of the day waits for approval, see /queue.")
+			}
+		}
+	}
+}
+
+// parseQueueIndex parses the 1-based position of a post in the content queue.
+func parseQueueIndex(value string, queue []telegram.Draft) (int, bool) {
+	index, err := strconv.Atoi(value)
+	if err != nil || index < 1 || index > len(queue) {
+		return 0, false
+	}
+
+	return index - 1, true
+}
+
+// editQueue applies an edit of the admin to the content queue. The edits are approve <n>|all, skip <n>,
+// move <n> <to> and edit <n> <translation> | <example>.
+func editQueue(queue []telegram.Draft, argument string) ([]telegram.Draft, bool) {
+	fields := strings.Fields(argument)
+	if len(fields) < 2 {
+		return nil, false
+	}
+
+	if fields[0] == "approve" && fields[1] == "all" {
+		for i := range queue {
+			queue[i].Approved = true
+		}
+
+		return queue, true
+	}
+
+	index, ok := parseQueueIndex(fields[1], queue)
+	if !ok {
+		return nil, false
+	}
+
+	switch {
+	case fields[0] == "approve" && len(fields) == 2:
+		queue[index].Approved = true
+	case fields[0] == "skip" && len(fields) == 2:
+		queue = append(queue[:index], queue[index+1:]...)
+	case fields[0] == "move" && len(fields) == 3:
+		to, ok := parseQueueIndex(fields[2], queue)
+		if !ok {
+			return nil, false
+		}
+
+		draft := queue[index]
+		queue = append(queue[:index], queue[index+1:]...)
+		queue = append(queue[:to], append([]telegram.Draft{draft}, queue[to:]...)...)
+	case fields[0] == "edit" && len(fields) > 2:
+		text := strings.TrimSpace(strings.SplitN(argument, fields[1], 2)[1])
+		translation := text
+		example := queue[index].Example
+		if pipeIndex := strings.Index(text, "|"); pipeIndex != -1 {
+			translation = strings.TrimSpace(text[:pipeIndex])
+			example = strings.TrimSpace(text[pipeIndex+1:])
+		}
+
+		if len(translation) != 0 {
+			queue[index].Translation = translation
+		}
+		queue[index].Example = example
+		// An edited post is approved again.
+		queue[index].Approved = false
+	default:
+		return nil, false
+	}
+
+	return queue, true
+}
+
+// manageQueue previews the upcoming posts of the channel, applying the edit of the admin first if any.
+func manageQueue(manager telegram.ChannelManager, lister storage.Lister, chatID int64,
+	argument string) tgbotapi.Chattable {
+	var msg tgbotapi.MessageConfig
+
+	channel, err := manager.Channel()
+	if err == nil && channel == nil {
+		err = errNoChannel
+	}
+
+	var queue []telegram.Draft
+	if err == nil {
+		queue, err = contentQueue(manager, lister, channel)
+	}
+
+	if err == nil && len(argument) != 0 {
+		if approval := strings.TrimPrefix(argument, "approval "); approval != argument {
+			channel.Approval = approval == "on"
+			err = manager.SaveChannel(channel)
+		} else if edited, ok := editQueue(queue, argument); ok {
+			err = manager.SaveQueue(edited)
+			if err == nil {
+				queue, err = contentQueue(manager, lister, channel)
+			}
+		} else {
+			msg = tgbotapi.NewMessage(chatID, "Please use /queue approve <n>|all, /queue skip <n>, "+
+				"/queue move <n> <to>, /queue edit <n> <translation> | <example> or /queue approval on|off.")
+		}
+	}
+
+	if err != nil {
+		msg = tgbotapi.NewMessage(chatID, fmt.Sprintf("Content queue failed. %s.", err))
+	} else if len(msg.Text) == 0 {
+		var sb strings.Builder
+		sb.WriteString("Upcoming words of the day")
+		if channel.Approval {
+			sb.WriteString(", approval required")
+		}
+		sb.WriteString(":\n")
+
+		for i, draft := range queue {
+			sb.WriteString(fmt.Sprintf("\n%d. %s - %s", i+1, draft.Word, draft.Translation))
+			if draft.Approved {
+				sb.WriteString(" (approved)")
+			}
+
+			if len(draft.Example) != 0 {
+				sb.WriteString(fmt.Sprintf("\n    %s", draft.Example))
+			}
+		}
+
+		msg = tgbotapi.NewMessage(chatID, sb.String())
+	}
+
+	return msg
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"fmt"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+	"github.com/handracs2007/kquiz/quiz"
+	"github.com/handracs2007/kquiz/storage"
+	"github.com/handracs2007/kquiz/telegram"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// choiceStop is the callback data of the button ending the multiple choice quiz, the options send their index.
+const choiceStop = "stop"
+
+// choiceKeyboard lays out the options of the question one per row, followed by the button ending the quiz.
+func choiceKeyboard(question quiz.Question) tgbotapi.InlineKeyboardMarkup {
+	rows := make([][]tgbotapi.InlineKeyboardButton, 0, len(question.Options)+1)
+	for i, option := range question.Options {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(option, choiceCallbackPrefix+strconv.Itoa(i))))
+	}
+
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("Stop", choiceCallbackPrefix+choiceStop)))
+	return tgbotapi.NewInlineKeyboardMarkup(rows...)
+}
+
+// nextChoice generates the next multiple choice question from the vocabulary of the user tagged with the tag, if any.
+// The words just asked are left out for a while.
+func nextChoice(lister storage.Lister, engine *quiz.Engine, tracker telegram.RecentTracker, cooldown quiz.Cooldown,
+	chatID int64, tag string) (*quiz.Question, error) {
+	entries, err := lister.ListEntries(chatID, telegram.KindVocabulary)
+	if err != nil {
+		return nil, err
+	}
+
+	entries = quiz.Active(telegram.Tagged(entries, tag))
+	question, ok := engine.ForMultipleChoice(entries, cooledDown(tracker, cooldown, chatID, entries))
+	if !ok {
+		return nil, nil
+	}
+
+	recordAsked(tracker, chatID, question.Word)
+	return &question, nil
+}
+
+// startChoice sends the first question of a multiple choice quiz, the message the whole quiz is edited into.
+func startChoice(lister storage.Lister, engine *quiz.Engine, tracker telegram.RecentTracker, cooldown quiz.Cooldown,
+	botAPI sender, chatID int64, session *quiz.Session) *quiz.Question {
+	var msg tgbotapi.MessageConfig
+	question, err := nextChoice(lister, engine, tracker, cooldown, chatID, session.Tag)
+	if err != nil {
+		msg = tgbotapi.NewMessage(chatID, fmt.Sprintf("Start quiz failed. %s.", err))
+	} else if question == nil && len(session.Tag) != 0 {
+		msg = tgbotapi.NewMessage(chatID, fmt.Sprintf("Please tag at least 2 words with different translations "+
+			"with #%s first.", session.Tag))
+	} else if question == nil {
+		msg = tgbotapi.NewMessage(chatID, "Please add at least 2 words with different translations first.")
+	} else {
+		msg = tgbotapi.NewMessage(chatID, question.Prompt)
+		msg.ReplyMarkup = choiceKeyboard(*question)
+	}
+
+	message, err := botAPI.Send(msg)
+	if err != nil {
+		log.Printf("Failed to respond to choice request. %s.\n", err)
+		return nil
+	}
+
+	if question != nil {
+		session.LiveMessageID = message.MessageID
+	}
+
+	return question
+}
+
+// plainChoiceFeedback grades the option picked like answerChoice in short plain sentences, starting with whether the
+// option is correct.
+func plainChoiceFeedback(question quiz.Question, option string, session *quiz.Session, combos bool) string {
+	if !question.Check(option) {
+		session.Record(false, combos, time.Now())
+		return fmt.Sprintf("Incorrect. %s is %s, not %s.", question.Word, question.Answer, option)
+	}
+
+	lines := []string{fmt.Sprintf("Correct. %s is %s.", question.Word, question.Answer)}
+	points := session.Record(true, combos, time.Now())
+	if combos {
+		lines = append(lines, fmt.Sprintf("You get %d points. Your session score is %d.", points, session.Score))
+		if feedback := quiz.PlainComboFeedback(session.Combo); len(feedback) != 0 {
+			lines = append(lines, feedback)
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// answerChoice grades the option picked for the question and edits the quiz message in place into the feedback
+// followed by the next question, or the summary when the quiz is stopped or runs out of questions. It returns the
+// edit and the next question, nil when the quiz is over.
+func answerChoice(lister storage.Lister, engine *quiz.Engine, tracker telegram.RecentTracker, cooldown quiz.Cooldown,
+	chatID int64, question quiz.Question, data string, session *quiz.Session,
+	settings telegram.Settings) (tgbotapi.Chattable, *quiz.Question) {
+	combos := !settings.NoCombos
+	feedback := "Quiz stopped."
+	if data != choiceStop {
+		index, err := strconv.Atoi(data)
+		if err != nil || index < 0 || index >= len(question.Options) {
+			return nil, &question
+		}
+
+		if settings.Accessible {
+			feedback = plainChoiceFeedback(question, question.Options[index], session, combos)
+		} else if question.Check(question.Options[index]) {
+			feedback = fmt.Sprintf("✓ %s is %s.", question.Word, question.Answer)
+			points := session.Record(true, combos, time.Now())
+			if combos {
+				feedback += fmt.Sprintf(" +%d, session score %d.", points, session.Score)
+				if cheer := quiz.ComboFeedback(session.Combo); len(cheer) != 0 {
+					feedback += "\n" + cheer
+				}
+			}
+		} else {
+			feedback = fmt.Sprintf("✗ %s is %s, not %s.", question.Word, question.Answer, question.Options[index])
+			session.Record(false, combos, time.Now())
+		}
+	}
+
+	var next *quiz.Question
+	if data != choiceStop {
+		var err error
+		next, err = nextChoice(lister, engine, tracker, cooldown, chatID, session.Tag)
+		if err != nil {
+			log.Printf("Failed to generate next choice question. %s.\n", err)
+		}
+	}
+
+	edit := tgbotapi.NewEditMessageText(chatID, session.LiveMessageID, "")
+	if next != nil {
+		keyboard := choiceKeyboard(*next)
+		edit.Text = fmt.Sprintf("%s\n\n%s", feedback, next.Prompt)
+		edit.ReplyMarkup = &keyboard
+	} else {
+		edit.Text = fmt.Sprintf("%s\n\nQuiz over.\n%s", feedback, session.Summary())
+		session.LiveMessageID = 0
+	}
+
+	return edit, next
+}
@@ -0,0 +1,290 @@
+// Command kquizctl administers a running kquiz bot.
+//
+// Usage:
+//
+//	kquizctl backup [-url http://localhost:8080] [-token TOKEN] [-out DIR]
+//	kquizctl replay -journal FILE [-db kquiz.db] [-shards 1] [-since 2006-01-02T15:04:05Z] [-config FILE]
+//	kquizctl promote [-url http://localhost:8080] [-token TOKEN]
+//	kquizctl verify [-db kquiz.db] [-shards 1] [-config FILE]
+//	kquizctl migrate [-db kquiz.db] [-shards 1] [-config FILE] [-dry-run]
+//
+// The backup command downloads a consistent snapshot of every database shard while the bot stays live. The token
+// defaults to the KQUIZ_ADMIN_TOKEN environment variable.
+//
+// The replay command applies the journal onto restored snapshots, which must not be in use by a bot, and rebuilds the
+// relation index. The changes made before the given time, e.g. when the snapshots were taken, are skipped.
+//
+// The promote command makes a standby instance take over as the primary. Make sure the primary is stopped first.
+//
+// The verify command checks the keys and entries of the words of databases not in use by a bot and lists the
+// problems found.
+//
+// The migrate command upgrades databases not in use by a bot to the schema of this version, as the bot does on start.
+// With -dry-run, it only tells what each migration would change.
+//
+// The replay, verify and migrate commands read the bucket names from the config file of the bot, if any, and replay
+// relates the words with its morphological analyzer.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"go.etcd.io/bbolt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/handracs2007/kquiz/config"
+	"github.com/handracs2007/kquiz/hanja"
+	"github.com/handracs2007/kquiz/migrations"
+	"github.com/handracs2007/kquiz/providers"
+	"github.com/handracs2007/kquiz/roots"
+	"github.com/handracs2007/kquiz/storage"
+	"github.com/handracs2007/kquiz/telegram"
+	"github.com/handracs2007/kquiz/web"
+)
+
+// downloadShard saves the snapshot of the shard into the output directory and returns the number of shards.
+func downloadShard(baseURL string, token string, out string, shard int) (int, error) {
+	path := filepath.Join(out, telegram.ShardPath("kquiz.db", shard))
+
+	count, written, err := web.DownloadBackup(baseURL, token, shard, path)
+	if err != nil {
+		return 0, err
+	}
+
+	log.Printf("Saved shard %d to %s, %d bytes.\n", shard, path, written)
+	return count, nil
+}
+
+func backup(args []string) error {
+	flags := flag.NewFlagSet("backup", flag.ExitOnError)
+	baseURL := flags.String("url", "http://localhost:8080", "base URL of the kquiz HTTP server")
+	token := flags.String("token", os.Getenv("KQUIZ_ADMIN_TOKEN"), "admin token of the kquiz HTTP server")
+	out := flags.String("out", ".", "directory to save the snapshots into")
+	_ = flags.Parse(args)
+
+	if len(*token) == 0 {
+		return fmt.Errorf("admin token is required")
+	}
+
+	count, err := downloadShard(strings.TrimSuffix(*baseURL, "/"), *token, *out, 0)
+	if err != nil {
+		return fmt.Errorf("backup of shard 0 failed. %s", err)
+	}
+
+	for shard := 1; shard < count; shard++ {
+		_, err = downloadShard(strings.TrimSuffix(*baseURL, "/"), *token, *out, shard)
+		if err != nil {
+			return fmt.Errorf("backup of shard %d failed. %s", shard, err)
+		}
+	}
+
+	return nil
+}
+
+func replay(args []string) error {
+	flags := flag.NewFlagSet("replay", flag.ExitOnError)
+	journalPath := flags.String("journal", "", "journal file to replay")
+	dbPath := flags.String("db", "kquiz.db", "database file of the first shard")
+	count := flags.Int("shards", 1, "number of shards")
+	sinceValue := flags.String("since", "", "skip the changes made before this RFC 3339 time")
+	configPath := flags.String("config", "", "JSON config file of the bot")
+	_ = flags.Parse(args)
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		return err
+	}
+
+	if len(*journalPath) == 0 {
+		return fmt.Errorf("journal is required")
+	}
+
+	var since time.Time
+	if len(*sinceValue) != 0 {
+		since, err = time.Parse(time.RFC3339, *sinceValue)
+		if err != nil {
+			return fmt.Errorf("invalid time %s. %s", *sinceValue, err)
+		}
+	}
+
+	file, err := os.Open(*journalPath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	shards, err := telegram.OpenShards(*dbPath, *count)
+	if err != nil {
+		return err
+	}
+	defer shards.Close()
+
+	applied, err := telegram.Replay(shards, file, since)
+	log.Printf("Replayed %d changes.\n", applied)
+	if err != nil {
+		return err
+	}
+
+	// The relation index is not journaled, let's derive it from the replayed words.
+	dict, err := hanja.NewDictionary()
+	if err != nil {
+		return err
+	}
+
+	err = shards.Update(func(tx *bbolt.Tx) error {
+		for _, bucketName := range []string{cfg.Buckets.Telegram, cfg.Buckets.Kquiz, cfg.Buckets.Deck} {
+			_, err := tx.CreateBucketIfNotExists([]byte(bucketName))
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// The words are related as the bot relates them, with the same morphological analyzer.
+	registry, err := providers.NewRegistry(cfg.Providers)
+	if err != nil {
+		return err
+	}
+
+	boltRepository := storage.NewBoltRepository(shards, cfg.Buckets.Telegram, cfg.Buckets.Kquiz, cfg.Buckets.Relation,
+		roots.NewFinder(dict, registry.Analyzer), nil)
+
+	// The journals written before the nested buckets hold the keys prefixed with the chat ID.
+	users, err := boltRepository.Users()
+	if err != nil {
+		return err
+	}
+
+	for _, bucketName := range []string{cfg.Buckets.Kquiz, cfg.Buckets.Deck} {
+		_, err = telegram.NestUserKeys(shards, bucketName, users)
+		if err != nil {
+			return err
+		}
+	}
+
+	return boltRepository.RebuildRelations()
+}
+
+func promote(args []string) error {
+	flags := flag.NewFlagSet("promote", flag.ExitOnError)
+	baseURL := flags.String("url", "http://localhost:8080", "base URL of the standby kquiz HTTP server")
+	token := flags.String("token", os.Getenv("KQUIZ_ADMIN_TOKEN"), "admin token of the kquiz HTTP server")
+	_ = flags.Parse(args)
+
+	err := web.Promote(strings.TrimSuffix(*baseURL, "/"), *token)
+	if err != nil {
+		return err
+	}
+
+	log.Println("Standby promoted.")
+	return nil
+}
+
+func verify(args []string) error {
+	flags := flag.NewFlagSet("verify", flag.ExitOnError)
+	dbPath := flags.String("db", "kquiz.db", "database file of the first shard")
+	count := flags.Int("shards", 1, "number of shards")
+	configPath := flags.String("config", "", "JSON config file of the bot")
+	_ = flags.Parse(args)
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		return err
+	}
+
+	shards, err := telegram.OpenShards(*dbPath, *count)
+	if err != nil {
+		return err
+	}
+	defer shards.Close()
+
+	boltRepository := storage.NewBoltRepository(shards, cfg.Buckets.Telegram, cfg.Buckets.Kquiz, cfg.Buckets.Relation,
+		nil, nil)
+	problems, err := boltRepository.Verify()
+	if err != nil {
+		return err
+	}
+
+	for _, problem := range problems {
+		fmt.Println(problem)
+	}
+
+	if len(problems) != 0 {
+		return fmt.Errorf("%d problems found", len(problems))
+	}
+
+	log.Println("No problems found.")
+	return nil
+}
+
+func migrate(args []string) error {
+	flags := flag.NewFlagSet("migrate", flag.ExitOnError)
+	dbPath := flags.String("db", "kquiz.db", "database file of the first shard")
+	count := flags.Int("shards", 1, "number of shards")
+	configPath := flags.String("config", "", "JSON config file of the bot")
+	dryRun := flags.Bool("dry-run", false, "tell what would change without changing anything")
+	_ = flags.Parse(args)
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		return err
+	}
+
+	shards, err := telegram.OpenShards(*dbPath, *count)
+	if err != nil {
+		return err
+	}
+	defer shards.Close()
+
+	results, err := migrations.Run(shards, migrations.Builtin(cfg.Buckets), *dryRun)
+	for _, result := range results {
+		fmt.Printf("Version %d, %s: %d changes.\n", result.Version, result.Name, result.Changed)
+	}
+	if err != nil {
+		return err
+	}
+
+	if len(results) == 0 {
+		log.Println("The databases are up to date.")
+	} else if *dryRun {
+		log.Println("Dry run, nothing was changed.")
+	}
+
+	return nil
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprintln(os.Stderr, "Usage: kquizctl backup|replay|promote|verify|migrate [flags]")
+		os.Exit(2)
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "backup":
+		err = backup(os.Args[2:])
+	case "replay":
+		err = replay(os.Args[2:])
+	case "promote":
+		err = promote(os.Args[2:])
+	case "verify":
+		err = verify(os.Args[2:])
+	case "migrate":
+		err = migrate(os.Args[2:])
+	default:
+		err = fmt.Errorf("unknown command %s", os.Args[1])
+	}
+
+	if err != nil {
+		log.Fatalf("%s.", err)
+	}
+}
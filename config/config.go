@@ -0,0 +1,685 @@
+// Package config loads the settings of the bot from an optional JSON file and the environment, so that the bot can be
+// deployed without recompiling. The environment variables take precedence over the file.
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/handracs2007/kquiz/backup"
+	"github.com/handracs2007/kquiz/features"
+	"github.com/handracs2007/kquiz/providers"
+)
+
+// Duration is a duration written like 24h or 90m in the config file.
+type Duration time.Duration
+
+// UnmarshalJSON parses the duration from its string representation.
+func (duration *Duration) UnmarshalJSON(data []byte) error {
+	var value string
+	err := json.Unmarshal(data, &value)
+	if err != nil {
+		return err
+	}
+
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		return err
+	}
+
+	*duration = Duration(parsed)
+	return nil
+}
+
+// MarshalJSON writes the duration in its string representation.
+func (duration Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(duration).String())
+}
+
+// Buckets holds the names of the buckets of the database.
+type Buckets struct {
+	Telegram      string `json:"telegram"`
+	Kquiz         string `json:"kquiz"`
+	Export        string `json:"export"`
+	Deck          string `json:"deck"`
+	Relation      string `json:"relation"`
+	Pronunciation string `json:"pronunciation"`
+	Cache         string `json:"cache"`
+	Usage         string `json:"usage"`
+	Settings      string `json:"settings"`
+	Activity      string `json:"activity"`
+	Channel       string `json:"channel"`
+	Content       string `json:"content"`
+	Analytics     string `json:"analytics"`
+	Audio         string `json:"audio"`
+	Template      string `json:"template"`
+	Pending       string `json:"pending"`
+	Stats         string `json:"stats"`
+	Leaderboard   string `json:"leaderboard"`
+	Transcript    string `json:"transcript"`
+	Access        string `json:"access"`
+	Token         string `json:"token"`
+	Ban           string `json:"ban"`
+	Snapshot      string `json:"snapshot"`
+	Session       string `json:"session"`
+	Recent        string `json:"recent"`
+}
+
+// names returns the bucket names keyed by what they store.
+func (buckets Buckets) names() map[string]string {
+	return map[string]string{
+		"telegram":      buckets.Telegram,
+		"kquiz":         buckets.Kquiz,
+		"export":        buckets.Export,
+		"deck":          buckets.Deck,
+		"relation":      buckets.Relation,
+		"pronunciation": buckets.Pronunciation,
+		"cache":         buckets.Cache,
+		"usage":         buckets.Usage,
+		"settings":      buckets.Settings,
+		"activity":      buckets.Activity,
+		"channel":       buckets.Channel,
+		"content":       buckets.Content,
+		"analytics":     buckets.Analytics,
+		"audio":         buckets.Audio,
+		"template":      buckets.Template,
+		"pending":       buckets.Pending,
+		"stats":         buckets.Stats,
+		"leaderboard":   buckets.Leaderboard,
+		"transcript":    buckets.Transcript,
+		"access":        buckets.Access,
+		"token":         buckets.Token,
+		"ban":           buckets.Ban,
+		"snapshot":      buckets.Snapshot,
+		"session":       buckets.Session,
+		"recent":        buckets.Recent,
+	}
+}
+
+// Config holds the settings of the bot.
+type Config struct {
+	TelegramToken string `json:"telegram_token"`
+	HTTPAddr      string `json:"http_addr"`
+	PublicURL     string `json:"public_url"`
+	// Admins are the chat IDs of the users allowed to use the admin commands.
+	Admins     []int64 `json:"admins"`
+	AdminToken string  `json:"admin_token"`
+
+	DBPath      string  `json:"db_path"`
+	DBShards    int     `json:"db_shards"`
+	JournalPath string  `json:"journal_path"`
+	Buckets     Buckets `json:"buckets"`
+	// Storage is bolt, sqlite or memory. With sqlite, the words and the users are kept in the SQLite database at
+	// SQLitePath, queryable with the standard tools, the rest in bbolt. In memory, nothing survives a restart: the
+	// words and the users are kept in memory and the rest in a temporary database, e.g. for a demo.
+	Storage    string `json:"storage"`
+	SQLitePath string `json:"sqlite_path"`
+	// Backup is where the snapshots of the databases are copied every BackupInterval, checked hourly, the SQLite one
+	// included with sqlite.
+	Backup         backup.Config `json:"backup"`
+	BackupInterval Duration      `json:"backup_interval"`
+
+	// Role is primary or standby, a standby replicates the primary at PrimaryURL until it is promoted.
+	Role                string   `json:"role"`
+	PrimaryURL          string   `json:"primary_url"`
+	ReplicationInterval Duration `json:"replication_interval"`
+	HandoffFrom         string   `json:"handoff_from"`
+
+	// UpdateMode is polling or webhook. In webhook mode, Telegram posts the updates to WebhookURL followed by the
+	// secret path, served on WebhookAddr. The listener serves HTTPS when a certificate is configured, otherwise the
+	// TLS is expected to end at a load balancer in front of it. A self-signed certificate is uploaded to Telegram.
+	UpdateMode        string `json:"update_mode"`
+	WebhookURL        string `json:"webhook_url"`
+	WebhookAddr       string `json:"webhook_addr"`
+	WebhookSecret     string `json:"webhook_secret"`
+	WebhookCert       string `json:"webhook_cert"`
+	WebhookKey        string `json:"webhook_key"`
+	WebhookSelfSigned bool   `json:"webhook_self_signed"`
+
+	ExportLinkTTL       Duration `json:"export_link_ttl"`
+	TranslationLanguage string   `json:"translation_language"`
+	TranslationCacheTTL Duration `json:"translation_cache_ttl"`
+	DictionaryCacheTTL  Duration `json:"dictionary_cache_ttl"`
+	AudioCacheMB        int      `json:"audio_cache_mb"`
+
+	// RandomSeed seeds the quiz engine, a fixed seed makes the quizzes reproducible.
+	RandomSeed       int64    `json:"random_seed"`
+	OnboardingSample bool     `json:"onboarding_sample"`
+	WordOfTheDayTime string   `json:"word_of_the_day_time"`
+	ReviewMessage    string   `json:"review_message"`
+	ReviewMessageB   string   `json:"review_message_b"`
+	WinBackAfter     Duration `json:"win_back_after"`
+	WinBackMessage   string   `json:"win_back_message"`
+
+	// MaxReviewInterval caps the time between two reviews of a word, zero lets it grow forever.
+	MaxReviewInterval Duration `json:"max_review_interval"`
+	// PendingQuestionTTL is how long a question waits for its answer, across restarts. Zero keeps it until it is
+	// answered or replaced.
+	PendingQuestionTTL Duration `json:"pending_question_ttl"`
+	// CooldownQuestions and CooldownWindow keep the words just asked out of the random questions, the last questions
+	// and the ones asked within the window. Zero turns either off.
+	CooldownQuestions int      `json:"cooldown_questions"`
+	CooldownWindow    Duration `json:"cooldown_window"`
+	// TypoTolerance is the number of typos accepted as close enough in the translations typed, one per 4 letters at
+	// most. Zero wants them spelled right.
+	TypoTolerance int `json:"typo_tolerance"`
+
+	// CompletionWebhookURL receives the class assignments completed by the students as JSON, CompletionCSV gets them
+	// appended as rows. Both are optional.
+	CompletionWebhookURL string `json:"completion_webhook_url"`
+	CompletionCSV        string `json:"completion_csv"`
+
+	// Font is the TrueType font with Hangul the printable worksheets and the share cards are rendered with. The
+	// worksheets are not available without it and the cards fall back to ASCII.
+	Font string `json:"font"`
+
+	// CommandLogRetention and DailyRetention are how long the commands used and the daily aggregates are kept, zero
+	// keeps them forever.
+	CommandLogRetention Duration `json:"command_log_retention"`
+	DailyRetention      Duration `json:"daily_retention"`
+	// TranscriptRetention is how long the questions answered and the answers given are kept for the transcripts, zero
+	// keeps them forever.
+	TranscriptRetention Duration `json:"transcript_retention"`
+	// SnapshotRetention is how long the weekly snapshots of the words are kept for /changes, zero keeps them forever.
+	// The latest snapshot of each user is always kept.
+	SnapshotRetention Duration `json:"snapshot_retention"`
+	// AnonymizeAnalytics stores keyed hashes of the chat IDs in the analytics instead of the chat IDs, the salt being
+	// the key. The salt must stay the same to keep telling the users apart across restarts.
+	AnonymizeAnalytics bool   `json:"anonymize_analytics"`
+	AnalyticsSalt      string `json:"analytics_salt"`
+	// PrivacyVersion is the version of the privacy notice the users accept before using the bot, changing it asks
+	// them again. Empty turns the notice off.
+	PrivacyVersion string `json:"privacy_version"`
+	PrivacyNotice  string `json:"privacy_notice"`
+
+	// Classroom locks the bot down for schools: nothing is published publicly and the profanity filter is on.
+	Classroom bool `json:"classroom"`
+	// ProfanityFilter refuses the words, translations and examples containing inappropriate language, the extra
+	// ProfanityWords adding to the built-in list.
+	ProfanityFilter bool     `json:"profanity_filter"`
+	ProfanityWords  []string `json:"profanity_words"`
+
+	Offline          bool             `json:"offline"`
+	DisabledFeatures []string         `json:"disabled_features"`
+	Providers        providers.Config `json:"providers"`
+	// Costs are rough estimates of a call to each service in USD, and DailyBudgets the daily spending limit of each
+	// service, zero being unlimited.
+	Costs        map[string]float64 `json:"costs"`
+	DailyBudgets map[string]float64 `json:"daily_budgets"`
+}
+
+// Default returns the settings used when neither the file nor the environment sets them.
+func Default() Config {
+	return Config{
+		HTTPAddr:   ":8080",
+		PublicURL:  "http://localhost:8080",
+		DBPath:     "kquiz.db",
+		DBShards:   1,
+		Storage:    "bolt",
+		SQLitePath: "kquiz.sqlite",
+		Backup: backup.Config{
+			Keep: 7,
+		},
+		BackupInterval: Duration(24 * time.Hour),
+		Buckets: Buckets{
+			Telegram:      "telegram",
+			Kquiz:         "kquiz",
+			Export:        "export",
+			Deck:          "deck",
+			Relation:      "relation",
+			Pronunciation: "pronunciation",
+			Cache:         "cache",
+			Usage:         "usage",
+			Settings:      "settings",
+			Activity:      "activity",
+			Channel:       "channel",
+			Content:       "content",
+			Analytics:     "analytics",
+			Audio:         "audio",
+			Template:      "template",
+			Pending:       "pending",
+			Stats:         "stats",
+			Leaderboard:   "leaderboard",
+			Transcript:    "transcript",
+			Access:        "access",
+			Token:         "token",
+			Ban:           "ban",
+			Snapshot:      "snapshot",
+			Session:       "session",
+			Recent:        "recent",
+		},
+		Role:                "primary",
+		ReplicationInterval: Duration(time.Minute),
+		UpdateMode:          "polling",
+		WebhookAddr:         ":8443",
+		ExportLinkTTL:       Duration(24 * time.Hour),
+		TranslationLanguage: "en",
+		TranslationCacheTTL: Duration(30 * 24 * time.Hour),
+		DictionaryCacheTTL:  Duration(30 * 24 * time.Hour),
+		AudioCacheMB:        50,
+		RandomSeed:          time.Now().UnixNano(),
+		OnboardingSample:    true,
+		WordOfTheDayTime:    "09:00",
+		ReviewMessage:       "Good {slot}, {name}! Time to review {due_count} words. Send /review to start.",
+		MaxReviewInterval:   Duration(180 * 24 * time.Hour),
+		PendingQuestionTTL:  Duration(24 * time.Hour),
+		CooldownQuestions:   5,
+		CooldownWindow:      Duration(time.Hour),
+		TypoTolerance:       1,
+		WinBackAfter:        Duration(7 * 24 * time.Hour),
+		WinBackMessage: "We miss you, {name}! Your {last_streak}-day streak is waiting and {due_count} words are " +
+			"due. Send /review to pick up where you left off, or /winback off to stop these messages.",
+		CommandLogRetention: Duration(90 * 24 * time.Hour),
+		TranscriptRetention: Duration(90 * 24 * time.Hour),
+		SnapshotRetention:   Duration(365 * 24 * time.Hour),
+		PrivacyVersion:      "1",
+		PrivacyNotice: "kquiz stores the words you add, your quiz results, your study activity and your settings to " +
+			"run your quizzes, and counts the commands used to improve the bot. The words and your voice answers may " +
+			"be sent to external services for translations, definitions and speech. Send /stop to unregister.",
+		Costs: map[string]float64{
+			providers.TTSService:         0.001,
+			providers.STTService:         0.006,
+			providers.TranslationService: 0.0005,
+			providers.DictionaryService:  0,
+		},
+		DailyBudgets: map[string]float64{
+			providers.TTSService:         0,
+			providers.STTService:         0,
+			providers.TranslationService: 0,
+			providers.DictionaryService:  0,
+		},
+	}
+}
+
+// Load returns the default settings overridden by the JSON file at the path, if any, then by the environment.
+func Load(path string) (Config, error) {
+	config := Default()
+
+	if len(path) != 0 {
+		file, err := os.Open(path)
+		if err != nil {
+			return Config{}, err
+		}
+		defer file.Close()
+
+		decoder := json.NewDecoder(file)
+		decoder.DisallowUnknownFields()
+		err = decoder.Decode(&config)
+		if err != nil {
+			return Config{}, fmt.Errorf("invalid config file %s. %s", path, err)
+		}
+	}
+
+	err := config.readEnv()
+	if err != nil {
+		return Config{}, err
+	}
+
+	if config.Classroom {
+		config.ProfanityFilter = true
+		config.DisabledFeatures = append(config.DisabledFeatures, features.Publishing)
+	}
+
+	return config, nil
+}
+
+// serviceEnv names the services in their environment variables.
+var serviceEnv = map[string]string{
+	providers.TTSService:         "TTS",
+	providers.STTService:         "STT",
+	providers.TranslationService: "TRANSLATION",
+	providers.DictionaryService:  "DICTIONARY",
+}
+
+// readEnv overrides the settings set in the environment.
+func (config *Config) readEnv() error {
+	lookupString("KQUIZ_TELEGRAM_TOKEN", &config.TelegramToken)
+	lookupString("KQUIZ_HTTP_ADDR", &config.HTTPAddr)
+	lookupString("KQUIZ_PUBLIC_URL", &config.PublicURL)
+	lookupString("KQUIZ_ADMIN_TOKEN", &config.AdminToken)
+	lookupString("KQUIZ_DB_PATH", &config.DBPath)
+	lookupString("KQUIZ_JOURNAL_PATH", &config.JournalPath)
+	lookupString("KQUIZ_STORAGE", &config.Storage)
+	lookupString("KQUIZ_SQLITE_PATH", &config.SQLitePath)
+	lookupString("KQUIZ_BACKUP_DIR", &config.Backup.Dir)
+	lookupString("KQUIZ_BACKUP_ENDPOINT", &config.Backup.Endpoint)
+	lookupString("KQUIZ_BACKUP_BUCKET", &config.Backup.Bucket)
+	lookupString("KQUIZ_BACKUP_PREFIX", &config.Backup.Prefix)
+	lookupString("KQUIZ_BACKUP_REGION", &config.Backup.Region)
+	lookupString("KQUIZ_BACKUP_ACCESS_KEY", &config.Backup.AccessKey)
+	lookupString("KQUIZ_BACKUP_SECRET_KEY", &config.Backup.SecretKey)
+	lookupString("KQUIZ_ROLE", &config.Role)
+	lookupString("KQUIZ_PRIMARY_URL", &config.PrimaryURL)
+	lookupString("KQUIZ_HANDOFF_FROM", &config.HandoffFrom)
+	lookupString("KQUIZ_UPDATE_MODE", &config.UpdateMode)
+	lookupString("KQUIZ_WEBHOOK_URL", &config.WebhookURL)
+	lookupString("KQUIZ_WEBHOOK_ADDR", &config.WebhookAddr)
+	lookupString("KQUIZ_WEBHOOK_SECRET", &config.WebhookSecret)
+	lookupString("KQUIZ_WEBHOOK_CERT", &config.WebhookCert)
+	lookupString("KQUIZ_WEBHOOK_KEY", &config.WebhookKey)
+	lookupString("KQUIZ_TRANSLATION_LANGUAGE", &config.TranslationLanguage)
+	lookupString("KQUIZ_WORD_OF_THE_DAY_TIME", &config.WordOfTheDayTime)
+	lookupString("KQUIZ_REVIEW_MESSAGE", &config.ReviewMessage)
+	lookupString("KQUIZ_REVIEW_MESSAGE_B", &config.ReviewMessageB)
+	lookupString("KQUIZ_WINBACK_MESSAGE", &config.WinBackMessage)
+	lookupString("KQUIZ_ANALYTICS_SALT", &config.AnalyticsSalt)
+	lookupString("KQUIZ_PRIVACY_VERSION", &config.PrivacyVersion)
+	lookupString("KQUIZ_PRIVACY_NOTICE", &config.PrivacyNotice)
+	lookupString("KQUIZ_COMPLETION_WEBHOOK_URL", &config.CompletionWebhookURL)
+	lookupString("KQUIZ_COMPLETION_CSV", &config.CompletionCSV)
+	lookupString("KQUIZ_FONT", &config.Font)
+	lookupString("KQUIZ_TTS_PROVIDER", &config.Providers.TTS)
+	lookupString("KQUIZ_STT_PROVIDER", &config.Providers.STT)
+	lookupString("KQUIZ_TRANSLATION_PROVIDER", &config.Providers.Translation)
+	lookupString("KQUIZ_DICTIONARY_PROVIDER", &config.Providers.Dictionary)
+	lookupString("KQUIZ_GOOGLE_API_KEY", &config.Providers.GoogleAPIKey)
+	lookupString("KQUIZ_PAPAGO_CLIENT_ID", &config.Providers.PapagoClientID)
+	lookupString("KQUIZ_PAPAGO_CLIENT_SECRET", &config.Providers.PapagoClientSecret)
+	lookupString("KQUIZ_KRDICT_API_KEY", &config.Providers.KrdictAPIKey)
+	lookupString("KQUIZ_ANALYZER", &config.Providers.Analyzer)
+	lookupString("KQUIZ_MECAB_PATH", &config.Providers.MecabPath)
+	lookupString("KQUIZ_MECAB_DICTIONARY", &config.Providers.MecabDictionary)
+	lookupList("KQUIZ_DISABLED_FEATURES", &config.DisabledFeatures)
+	lookupList("KQUIZ_PROFANITY_WORDS", &config.ProfanityWords)
+	lookupBool("KQUIZ_OFFLINE", &config.Offline)
+	lookupBool("KQUIZ_ONBOARDING_SAMPLE", &config.OnboardingSample)
+	lookupBool("KQUIZ_ANONYMIZE_ANALYTICS", &config.AnonymizeAnalytics)
+	lookupBool("KQUIZ_WEBHOOK_SELF_SIGNED", &config.WebhookSelfSigned)
+	lookupBool("KQUIZ_CLASSROOM", &config.Classroom)
+	lookupBool("KQUIZ_PROFANITY_FILTER", &config.ProfanityFilter)
+
+	lookups := []error{
+		lookupInt("KQUIZ_DB_SHARDS", &config.DBShards),
+		lookupInt("KQUIZ_AUDIO_CACHE_MB", &config.AudioCacheMB),
+		lookupInt("KQUIZ_BACKUP_KEEP", &config.Backup.Keep),
+		lookupInt("KQUIZ_COOLDOWN_QUESTIONS", &config.CooldownQuestions),
+		lookupInt("KQUIZ_TYPO_TOLERANCE", &config.TypoTolerance),
+		lookupInt64("KQUIZ_RANDOM_SEED", &config.RandomSeed),
+		lookupDuration("KQUIZ_REPLICATION_INTERVAL", &config.ReplicationInterval),
+		lookupDuration("KQUIZ_BACKUP_INTERVAL", &config.BackupInterval),
+		lookupDuration("KQUIZ_EXPORT_LINK_TTL", &config.ExportLinkTTL),
+		lookupDuration("KQUIZ_TRANSLATION_CACHE_TTL", &config.TranslationCacheTTL),
+		lookupDuration("KQUIZ_DICTIONARY_CACHE_TTL", &config.DictionaryCacheTTL),
+		lookupDuration("KQUIZ_MAX_REVIEW_INTERVAL", &config.MaxReviewInterval),
+		lookupDuration("KQUIZ_PENDING_QUESTION_TTL", &config.PendingQuestionTTL),
+		lookupDuration("KQUIZ_COOLDOWN_WINDOW", &config.CooldownWindow),
+		lookupDuration("KQUIZ_WINBACK_AFTER", &config.WinBackAfter),
+		lookupDuration("KQUIZ_COMMAND_LOG_RETENTION", &config.CommandLogRetention),
+		lookupDuration("KQUIZ_DAILY_RETENTION", &config.DailyRetention),
+		lookupDuration("KQUIZ_TRANSCRIPT_RETENTION", &config.TranscriptRetention),
+		lookupDuration("KQUIZ_SNAPSHOT_RETENTION", &config.SnapshotRetention),
+		lookupChatIDs("KQUIZ_ADMINS", &config.Admins),
+	}
+
+	if config.Costs == nil {
+		config.Costs = map[string]float64{}
+	}
+
+	if config.DailyBudgets == nil {
+		config.DailyBudgets = map[string]float64{}
+	}
+
+	for service, name := range serviceEnv {
+		lookups = append(lookups, lookupFloat("KQUIZ_"+name+"_COST", config.Costs, service),
+			lookupFloat("KQUIZ_"+name+"_DAILY_BUDGET", config.DailyBudgets, service))
+	}
+
+	for _, err := range lookups {
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Validate checks that the settings are complete and consistent.
+func (config Config) Validate() error {
+	if len(config.TelegramToken) == 0 {
+		return fmt.Errorf("the Telegram bot token is required, set KQUIZ_TELEGRAM_TOKEN or telegram_token")
+	}
+
+	if config.DBShards < 1 {
+		return fmt.Errorf("invalid number of shards %d, expected at least 1", config.DBShards)
+	}
+
+	switch config.Storage {
+	case "bolt":
+	case "sqlite", "memory":
+		// The replication only copies the bbolt database.
+		if config.Role != "primary" {
+			return fmt.Errorf("a standby needs the bolt storage")
+		}
+	default:
+		return fmt.Errorf("invalid storage %s, expected bolt, sqlite or memory", config.Storage)
+	}
+
+	if len(config.Backup.Endpoint) != 0 {
+		if !strings.HasPrefix(config.Backup.Endpoint, "https://") && !strings.HasPrefix(config.Backup.Endpoint, "http://") {
+			return fmt.Errorf("invalid backup endpoint %s", config.Backup.Endpoint)
+		}
+
+		if len(config.Backup.Bucket) == 0 || len(config.Backup.AccessKey) == 0 || len(config.Backup.SecretKey) == 0 {
+			return fmt.Errorf("a backup endpoint needs the bucket, the access key and the secret key")
+		}
+	}
+
+	if config.BackupInterval < Duration(time.Hour) {
+		return fmt.Errorf("invalid backup interval %s, expected at least 1h", time.Duration(config.BackupInterval))
+	}
+
+	switch config.Role {
+	case "primary":
+	case "standby":
+		if len(config.PrimaryURL) == 0 || len(config.AdminToken) == 0 {
+			return fmt.Errorf("a standby needs the primary URL and the admin token")
+		}
+	default:
+		return fmt.Errorf("invalid role %s, expected primary or standby", config.Role)
+	}
+
+	switch config.UpdateMode {
+	case "polling":
+	case "webhook":
+		if !strings.HasPrefix(config.WebhookURL, "https://") {
+			return fmt.Errorf("invalid webhook URL %s, Telegram only posts to HTTPS", config.WebhookURL)
+		}
+
+		// The secret path is all that keeps others from posting fake updates.
+		if len(config.WebhookSecret) < 16 {
+			return fmt.Errorf("the webhook secret must be at least 16 characters long")
+		}
+
+		if (len(config.WebhookCert) == 0) != (len(config.WebhookKey) == 0) {
+			return fmt.Errorf("the webhook certificate and its key go together")
+		}
+
+		if config.WebhookSelfSigned && len(config.WebhookCert) == 0 {
+			return fmt.Errorf("a self-signed webhook needs its certificate")
+		}
+	default:
+		return fmt.Errorf("invalid update mode %s, expected polling or webhook", config.UpdateMode)
+	}
+
+	if _, err := time.Parse("15:04", config.WordOfTheDayTime); err != nil {
+		return fmt.Errorf("invalid word of the day time %s, expected e.g. 09:00", config.WordOfTheDayTime)
+	}
+
+	if len(config.CompletionWebhookURL) != 0 && !strings.HasPrefix(config.CompletionWebhookURL, "https://") &&
+		!strings.HasPrefix(config.CompletionWebhookURL, "http://") {
+		return fmt.Errorf("invalid completion webhook URL %s", config.CompletionWebhookURL)
+	}
+
+	if config.AudioCacheMB < 0 {
+		return fmt.Errorf("invalid audio cache size %d MB", config.AudioCacheMB)
+	}
+
+	if config.TypoTolerance < 0 {
+		return fmt.Errorf("invalid typo tolerance %d", config.TypoTolerance)
+	}
+
+	if config.CooldownQuestions < 0 || config.CooldownWindow < 0 {
+		return fmt.Errorf("invalid cooldown of %d questions and %s", config.CooldownQuestions,
+			time.Duration(config.CooldownWindow))
+	}
+
+	if config.AnonymizeAnalytics && len(config.AnalyticsSalt) == 0 {
+		return fmt.Errorf("anonymised analytics need a salt, set KQUIZ_ANALYTICS_SALT or analytics_salt")
+	}
+
+	if len(config.PrivacyVersion) != 0 && len(config.PrivacyNotice) == 0 {
+		return fmt.Errorf("the privacy notice is required, set KQUIZ_PRIVACY_NOTICE or privacy_notice")
+	}
+
+	if config.ReplicationInterval <= 0 {
+		return fmt.Errorf("invalid replication interval %s", time.Duration(config.ReplicationInterval))
+	}
+
+	used := map[string]string{}
+	for bucket, name := range config.Buckets.names() {
+		if len(name) == 0 {
+			return fmt.Errorf("the name of the %s bucket is required", bucket)
+		}
+
+		if other, ok := used[name]; ok {
+			return fmt.Errorf("the %s and %s buckets are both named %s", other, bucket, name)
+		}
+		used[name] = bucket
+	}
+
+	return nil
+}
+
+// WebhookPath returns the path the updates are posted to in webhook mode.
+func (config Config) WebhookPath() string {
+	return "/telegram/" + config.WebhookSecret
+}
+
+// AnalyticsKey returns the salt anonymising the chat IDs in the analytics, empty when they are not anonymised.
+func (config Config) AnalyticsKey() string {
+	if !config.AnonymizeAnalytics {
+		return ""
+	}
+
+	return config.AnalyticsSalt
+}
+
+// AdminChatIDs returns the chat IDs of the admins as a set.
+func (config Config) AdminChatIDs() map[int64]bool {
+	admins := make(map[int64]bool, len(config.Admins))
+	for _, chatID := range config.Admins {
+		admins[chatID] = true
+	}
+
+	return admins
+}
+
+func lookupString(key string, value *string) {
+	if env, ok := os.LookupEnv(key); ok {
+		*value = env
+	}
+}
+
+// lookupList reads the comma separated values of the environment variable.
+func lookupList(key string, value *[]string) {
+	if env, ok := os.LookupEnv(key); ok {
+		*value = strings.Split(env, ",")
+	}
+}
+
+func lookupInt(key string, value *int) error {
+	env, ok := os.LookupEnv(key)
+	if !ok {
+		return nil
+	}
+
+	number, err := strconv.Atoi(env)
+	if err != nil {
+		return fmt.Errorf("invalid integer %s for %s", env, key)
+	}
+
+	*value = number
+	return nil
+}
+
+func lookupInt64(key string, value *int64) error {
+	env, ok := os.LookupEnv(key)
+	if !ok {
+		return nil
+	}
+
+	number, err := strconv.ParseInt(env, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid integer %s for %s", env, key)
+	}
+
+	*value = number
+	return nil
+}
+
+// lookupBool reads the environment variable as true when it is set to true, false otherwise.
+func lookupBool(key string, value *bool) {
+	if env, ok := os.LookupEnv(key); ok {
+		*value = env == "true"
+	}
+}
+
+func lookupDuration(key string, value *Duration) error {
+	env, ok := os.LookupEnv(key)
+	if !ok {
+		return nil
+	}
+
+	duration, err := time.ParseDuration(env)
+	if err != nil {
+		return fmt.Errorf("invalid duration %s for %s", env, key)
+	}
+
+	*value = Duration(duration)
+	return nil
+}
+
+func lookupFloat(key string, values map[string]float64, name string) error {
+	env, ok := os.LookupEnv(key)
+	if !ok {
+		return nil
+	}
+
+	number, err := strconv.ParseFloat(env, 64)
+	if err != nil {
+		return fmt.Errorf("invalid number %s for %s", env, key)
+	}
+
+	values[name] = number
+	return nil
+}
+
+// lookupChatIDs reads the comma separated chat IDs of the environment variable.
+func lookupChatIDs(key string, value *[]int64) error {
+	env, ok := os.LookupEnv(key)
+	if !ok {
+		return nil
+	}
+
+	chatIDs := make([]int64, 0)
+	for _, field := range strings.Split(env, ",") {
+		field = strings.TrimSpace(field)
+		if len(field) == 0 {
+			continue
+		}
+
+		chatID, err := strconv.ParseInt(field, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid chat ID %s for %s", field, key)
+		}
+
+		chatIDs = append(chatIDs, chatID)
+	}
+
+	*value = chatIDs
+	return nil
+}
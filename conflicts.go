@@ -0,0 +1,163 @@
+package main
+
+import (
+	"fmt"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+	"github.com/handracs2007/kquiz/storage"
+	"github.com/handracs2007/kquiz/telegram"
+	"log"
+	"strings"
+)
+
+// The actions of the buttons of a conflict review, following conflictCallbackPrefix.
+const (
+	conflictMine   = "m"
+	conflictTheirs = "t"
+	conflictBoth   = "b"
+	conflictStop   = "x"
+)
+
+// translationConflict is a word of an imported deck the user already has with another translation.
+type translationConflict struct {
+	word   string
+	mine   string
+	theirs string
+}
+
+// conflictReview is the message walking the user through the translation conflicts of the imports, one at a time. It
+// is edited in place as the user resolves them. In groups, only the member who imported the words and the admins press
+// its buttons.
+type conflictReview struct {
+	messageID int
+	userID    int
+	conflicts []translationConflict
+	current   int
+	replaced  int
+	merged    int
+	kept      int
+}
+
+// findConflict returns the conflict of the imported word and translation with the word the user already has, false
+// when the translations are the same but for the case and the spaces.
+func findConflict(searcher storage.Searcher, chatID int64, word string, translation string) (translationConflict,
+	bool) {
+	entry, err := searcher.SearchEntry(chatID, word)
+	if err != nil || strings.EqualFold(strings.TrimSpace(entry.Translation), strings.TrimSpace(translation)) {
+		return translationConflict{}, false
+	}
+
+	return translationConflict{word: word, mine: entry.Translation, theirs: strings.TrimSpace(translation)}, true
+}
+
+// done reports whether all the conflicts are resolved.
+func (r *conflictReview) done() bool {
+	return r.current == len(r.conflicts)
+}
+
+// text returns the text of the review, the current conflict or the outcome once all are resolved.
+func (r *conflictReview) text() string {
+	if r.done() {
+		return fmt.Sprintf("Translation conflicts resolved, %d replaced, %d merged and %d kept.", r.replaced,
+			r.merged, r.kept)
+	}
+
+	conflict := r.conflicts[r.current]
+	return fmt.Sprintf("Conflict %d of %d, the imported deck translates %s differently.\n\nYours: %s\nImported: %s",
+		r.current+1, len(r.conflicts), conflict.word, conflict.mine, conflict.theirs)
+}
+
+// keyboard returns the buttons resolving the current conflict, none once all are resolved.
+func (r *conflictReview) keyboard() *tgbotapi.InlineKeyboardMarkup {
+	if r.done() {
+		return nil
+	}
+
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("Keep mine", conflictCallbackPrefix+conflictMine),
+			tgbotapi.NewInlineKeyboardButtonData("Use imported", conflictCallbackPrefix+conflictTheirs)),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("Keep both", conflictCallbackPrefix+conflictBoth),
+			tgbotapi.NewInlineKeyboardButtonData("Keep all mine", conflictCallbackPrefix+conflictStop)))
+	return &keyboard
+}
+
+// reviewConflicts asks the user to resolve the translation conflicts of an import. The conflicts left from an earlier
+// import are reviewed first, in the new message.
+func (d *dispatcher) reviewConflicts(chatID int64, userID int, conflicts []translationConflict) {
+	if len(conflicts) == 0 {
+		return
+	}
+
+	r := &conflictReview{userID: userID, conflicts: conflicts}
+	if earlier, ok := d.conflicts[chatID]; ok {
+		r.conflicts = append(earlier.conflicts[earlier.current:], conflicts...)
+	}
+
+	msg := tgbotapi.NewMessage(chatID, r.text())
+	msg.ReplyMarkup = r.keyboard()
+
+	message, err := d.bot.Send(msg)
+	if err != nil {
+		log.Printf("Failed to send translation conflicts. %s.\n", err)
+		return
+	}
+
+	r.messageID = message.MessageID
+	d.conflicts[chatID] = r
+}
+
+// resolveConflict handles the buttons of the conflict review: keeping the translation of the user, using the imported
+// one, keeping both or keeping the translations of the user for all the conflicts left. It returns the edit of the
+// review, or nil when the buttons are those of an earlier review, stale and ignored.
+func (d *dispatcher) resolveConflict(query *tgbotapi.CallbackQuery) tgbotapi.Chattable {
+	chatID := query.Message.Chat.ID
+	r, ok := d.conflicts[chatID]
+	if !ok || r.messageID != query.Message.MessageID || r.done() {
+		return nil
+	}
+
+	conflict := r.conflicts[r.current]
+	action := strings.TrimPrefix(query.Data, conflictCallbackPrefix)
+
+	var err error
+	switch action {
+	case conflictMine, conflictStop:
+	case conflictTheirs:
+		err = d.updater.SetTranslation(chatID, conflict.word, conflict.theirs)
+	case conflictBoth:
+		err = d.updater.SetTranslation(chatID, conflict.word, conflict.mine+", "+conflict.theirs)
+	default:
+		return nil
+	}
+
+	// The word may have been deleted since the import, there is nothing to resolve then.
+	if err != nil && err != telegram.ErrWordNotFound {
+		return tgbotapi.NewMessage(chatID, fmt.Sprintf("Set translation failed. %s.", err))
+	}
+
+	switch action {
+	case conflictMine:
+		r.kept++
+	case conflictTheirs:
+		r.replaced++
+	case conflictBoth:
+		r.merged++
+	case conflictStop:
+		r.kept += len(r.conflicts) - r.current
+	}
+
+	r.current++
+	if action == conflictStop {
+		r.current = len(r.conflicts)
+	}
+
+	edit := tgbotapi.NewEditMessageText(chatID, r.messageID, r.text())
+	edit.ReplyMarkup = r.keyboard()
+
+	if r.done() {
+		delete(d.conflicts, chatID)
+	}
+
+	return edit
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"github.com/handracs2007/kquiz/quiz"
+	"github.com/handracs2007/kquiz/telegram"
+	"log"
+	"time"
+)
+
+// recentQuestionsSize is the least number of words recently asked remembered for each user, for the cooldown.
+const recentQuestionsSize = 100
+
+// cooledDown returns the entries to pick the next random question of the user from, leaving out the words just asked.
+// All the entries are returned when the recent words cannot be read.
+func cooledDown(tracker telegram.RecentTracker, cooldown quiz.Cooldown, chatID int64,
+	entries []telegram.Entry) []telegram.Entry {
+	recent, err := tracker.Recent(chatID)
+	if err != nil {
+		log.Printf("Failed to read recent words, ignoring the cooldown. %s.\n", err)
+		return entries
+	}
+
+	return cooldown.Filter(entries, recent, time.Now())
+}
+
+// recordAsked remembers the word asked to the user for the cooldown.
+func recordAsked(tracker telegram.RecentTracker, chatID int64, word string) {
+	err := tracker.RecordAsked(chatID, word, time.Now())
+	if err != nil {
+		log.Printf("Failed to record asked word. %s.\n", err)
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+	"github.com/handracs2007/kquiz/quiz"
+	"github.com/handracs2007/kquiz/scheduler"
+	"github.com/handracs2007/kquiz/storage"
+	"github.com/handracs2007/kquiz/telegram"
+	"log"
+	"time"
+)
+
+// dailyQuestion picks the question of the daily quiz of the user, among the words due for review first.
+func dailyQuestion(lister storage.Lister, engine *quiz.Engine, settings telegram.Settings, chatID int64,
+	now time.Time) (quiz.Question, bool) {
+	entries, err := lister.ListEntries(chatID, telegram.KindVocabulary)
+	if err != nil {
+		return quiz.Question{}, false
+	}
+
+	if due := quiz.DueQueue(entries, now); len(due) != 0 {
+		entries = due
+	}
+
+	entry, ok := engine.Pick(quiz.Active(entries))
+	if !ok {
+		return quiz.Question{}, false
+	}
+
+	return quiz.ForUser(entry, settings), true
+}
+
+// dailyQuizzes returns the job sending the users a question at the local time of their daily quiz. The question waits
+// for its answer like the ones asked with /random.
+func dailyQuizzes(audience storage.Audience, lister storage.Lister, manager telegram.SettingsManager,
+	pending telegram.PendingStore, engine *quiz.Engine, botAPI sender, font []byte) scheduler.Job {
+	// The job may run more than once within the minute of a quiz, let's remember the quizzes sent lately.
+	sent := make(map[string]time.Time)
+
+	return func(now time.Time) {
+		for key, at := range sent {
+			if now.Sub(at) > 48*time.Hour {
+				delete(sent, key)
+			}
+		}
+
+		users, err := audience.Users()
+		if err != nil {
+			log.Printf("Failed to list users for daily quizzes. %s.\n", err)
+			return
+		}
+
+		for _, chatID := range users {
+			settings, err := manager.Settings(chatID)
+			if err != nil || len(settings.DailyQuiz) == 0 {
+				continue
+			}
+
+			local := now.In(settings.Location())
+			if local.Format("15:04") != settings.DailyQuiz {
+				continue
+			}
+
+			key := fmt.Sprintf("%d/%s", chatID, local.Format("2006-01-02"))
+			if _, ok := sent[key]; ok {
+				continue
+			}
+			sent[key] = now
+
+			question, ok := dailyQuestion(lister, engine, settings, chatID, now)
+			if !ok {
+				continue
+			}
+
+			msg := promptMessage(chatID, fmt.Sprintf("Daily quiz! %s", question.Prompt), settings, font,
+				settings.Silent(now))
+
+			_, err = botAPI.Send(msg)
+			if err != nil {
+				log.Printf("Failed to send daily quiz. %s.\n", err)
+				continue
+			}
+
+			err = pending.SavePending(chatID, question)
+			if err != nil {
+				log.Printf("Failed to save daily quiz. %s.\n", err)
+			}
+		}
+	}
+}
+
+func setDailyQuiz(manager telegram.SettingsManager, chatID int64, at string) tgbotapi.Chattable {
+	var msg tgbotapi.MessageConfig
+	settings, err := manager.Settings(chatID)
+	if err == nil {
+		settings.DailyQuiz = at
+		err = manager.SaveSettings(chatID, settings)
+	}
+
+	if err != nil {
+		msg = tgbotapi.NewMessage(chatID, fmt.Sprintf("Save settings failed. %s.", err))
+	} else if len(at) == 0 {
+		msg = tgbotapi.NewMessage(chatID, "Daily quizzes are off.")
+	} else {
+		msg = tgbotapi.NewMessage(chatID, fmt.Sprintf("You will get a quiz every day at %s (%s), set your time zone "+
+			"with /timezone.", at, settings.Location()))
+	}
+
+	return msg
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+	"github.com/handracs2007/kquiz/backup"
+	"github.com/handracs2007/kquiz/janitor"
+	"github.com/handracs2007/kquiz/metrics"
+	"github.com/handracs2007/kquiz/outbox"
+	"github.com/handracs2007/kquiz/scheduler"
+	"github.com/handracs2007/kquiz/web"
+	"log"
+	"time"
+)
+
+// meteredSender counts the calls to Telegram and those failing in the metrics. The chat actions are left out, the
+// client fails to decode their response.
+type meteredSender struct {
+	sender
+	metrics *metrics.Metrics
+}
+
+func (s meteredSender) Send(c tgbotapi.Chattable) (tgbotapi.Message, error) {
+	message, err := s.sender.Send(c)
+	if _, ok := c.(tgbotapi.ChatActionConfig); !ok {
+		s.metrics.RecordCall(err != nil, time.Now())
+	}
+
+	return message, err
+}
+
+// dashboard returns the live metrics of the bot at the given time. The backups are nil when they are not configured.
+func dashboard(liveMetrics *metrics.Metrics, messages *outbox.Outbox, messageJanitor *janitor.Janitor,
+	sched *scheduler.Scheduler, backups *backup.Backups, now time.Time) web.Dashboard {
+	board := web.Dashboard{
+		At:                now,
+		ActiveUsers:       liveMetrics.ActiveUsers(now),
+		CommandsPerMinute: liveMetrics.CommandsPerMinute(now),
+		ErrorRate:         liveMetrics.ErrorRate(now),
+		Queues: []web.Queue{
+			{Name: "outgoing messages", Depth: messages.Pending()},
+			{Name: "messages to delete", Depth: messageJanitor.Pending()},
+		},
+		NextRun: sched.Next(),
+		Jobs:    sched.Statuses(),
+		Backups: backups != nil,
+	}
+
+	if backups != nil {
+		latest, err := backups.Latest()
+		if err != nil {
+			log.Printf("Failed to read the latest backup for the dashboard. %s.\n", err)
+		}
+
+		board.LastBackup = latest
+	}
+
+	return board
+}
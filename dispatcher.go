@@ -0,0 +1,1699 @@
+package main
+
+import (
+	"fmt"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+	"github.com/handracs2007/kquiz/backup"
+	"github.com/handracs2007/kquiz/events"
+	"github.com/handracs2007/kquiz/features"
+	"github.com/handracs2007/kquiz/gradebook"
+	"github.com/handracs2007/kquiz/hanja"
+	"github.com/handracs2007/kquiz/janitor"
+	"github.com/handracs2007/kquiz/metrics"
+	"github.com/handracs2007/kquiz/nudge"
+	"github.com/handracs2007/kquiz/outbox"
+	"github.com/handracs2007/kquiz/providers"
+	"github.com/handracs2007/kquiz/quiz"
+	"github.com/handracs2007/kquiz/roots"
+	"github.com/handracs2007/kquiz/storage"
+	"github.com/handracs2007/kquiz/telegram"
+	"github.com/handracs2007/kquiz/updates"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// sender is the part of the Telegram client used by the handlers, so that the bot can be driven without Telegram.
+type sender interface {
+	Send(c tgbotapi.Chattable) (tgbotapi.Message, error)
+	AnswerCallbackQuery(config tgbotapi.CallbackConfig) (tgbotapi.APIResponse, error)
+	GetFileDirectURL(fileID string) (string, error)
+	DeleteMessage(config tgbotapi.DeleteMessageConfig) (tgbotapi.APIResponse, error)
+	GetChatMember(config tgbotapi.ChatConfigWithUser) (tgbotapi.ChatMember, error)
+	PinChatMessage(config tgbotapi.PinChatMessageConfig) (tgbotapi.APIResponse, error)
+	UnpinChatMessage(config tgbotapi.UnpinChatMessageConfig) (tgbotapi.APIResponse, error)
+}
+
+// respond sends the replies returned by a handler, in order, skipping the nil ones, and logs the failures as those of
+// the request, e.g. registration. The handlers build their replies rather than send them, so that they can be tested
+// without Telegram.
+func respond(botAPI sender, request string, replies ...tgbotapi.Chattable) {
+	for _, reply := range replies {
+		if reply == nil {
+			continue
+		}
+
+		_, err := botAPI.Send(reply)
+		if err != nil {
+			log.Printf("Failed to respond to %s request. %s.\n", request, err)
+		}
+	}
+}
+
+// queuedSender sends the messages through the outbox, within the rate limits of Telegram. The other calls go through
+// as is.
+type queuedSender struct {
+	sender
+	outbox *outbox.Outbox
+}
+
+// Send queues the message and sends it once the rate limits allow it.
+func (queued queuedSender) Send(c tgbotapi.Chattable) (tgbotapi.Message, error) {
+	return queued.outbox.Send(c)
+}
+
+// poster is the part of the outbox queueing the bulk messages, sent in the background.
+type poster interface {
+	Post(c tgbotapi.Chattable)
+}
+
+// dispatcher routes the Telegram updates to their handlers.
+type dispatcher struct {
+	bot                 sender
+	bulk                poster
+	botName             string
+	botID               int
+	users               storage.UserRepository
+	words               storage.WordRepository
+	adder               storage.Adder
+	updater             storage.Updater
+	hanjaDict           *hanja.Dictionary
+	rootFinder          roots.Finder
+	registry            *providers.Registry
+	featureFlags        features.Flags
+	admins              map[int64]bool
+	quizEngine          *quiz.Engine
+	reviewScheduler     quiz.Scheduler
+	pending             telegram.PendingStore
+	sessionStore        telegram.SessionStore
+	recent              telegram.RecentStore
+	cooldown            quiz.Cooldown
+	grader              quiz.Grader
+	sessions            map[int64]*quiz.Session
+	reveals             map[int64]reveal
+	recaps              map[int64]*recap
+	notePrompts         map[int64]notePrompt
+	cleanups            map[int64]*cleanup
+	conflicts           map[int64]*conflictReview
+	lemmaPrompts        map[int64]lemmaPrompt
+	sampleDeck          []telegram.Entry
+	privacyVersion      string
+	privacyNotice       string
+	channelStore        telegram.ChannelStore
+	wordOfTheDayTime    string
+	settingsStore       telegram.SettingsStore
+	activityStore       telegram.ActivityStore
+	statsStore          telegram.StatsStore
+	leaderboard         telegram.LeaderboardStore
+	analyticsStore      telegram.AnalyticsStore
+	janitor             *janitor.Janitor
+	pronunciationStore  telegram.PronunciationStore
+	transcriptStore     telegram.TranscriptStore
+	snapshotStore       telegram.SnapshotStore
+	access              telegram.AccessStore
+	tokens              telegram.TokenStore
+	bans                telegram.BanStore
+	events              events.Emitter
+	deckStore           telegram.DeckManager
+	exportLinks         telegram.ExportLinkStore
+	templateStore       telegram.TemplateStore
+	gradebook           gradebook.Reporters
+	publicURL           string
+	exportLinkTTL       time.Duration
+	font                []byte
+	translationLanguage string
+	responseCache       *telegram.ResponseCache
+	audioCache          telegram.AudioCache
+	audioCacheSize      int
+	usageStore          telegram.UsageStore
+	metrics             *metrics.Metrics
+	budget              *providers.Budget
+	backups             *backup.Backups
+}
+
+// pendingQuestion returns the question the user is to answer, false when there is none.
+func (d *dispatcher) pendingQuestion(chatID int64) (quiz.Question, bool) {
+	var question quiz.Question
+	ok, err := d.pending.Pending(chatID, &question)
+	if err != nil {
+		return quiz.Question{}, false
+	}
+
+	return question, ok
+}
+
+// setPending makes the question the one the user is to answer.
+func (d *dispatcher) setPending(chatID int64, question quiz.Question) {
+	err := d.pending.SavePending(chatID, question)
+	if err != nil {
+		log.Printf("Failed to save the question to answer. %s.\n", err)
+	}
+}
+
+// clearPending drops the question the user was to answer.
+func (d *dispatcher) clearPending(chatID int64) {
+	err := d.pending.DeletePending(chatID)
+	if err != nil {
+		log.Printf("Failed to delete the question to answer. %s.\n", err)
+	}
+}
+
+// reveal is the message revealing the answer of the word the user missed, reacting to it grades the word again.
+type reveal struct {
+	messageID int
+	word      string
+	before    telegram.WordEntry
+}
+
+// session returns the quiz session of the user, starting a new one when there is none or it has expired. The quiz
+// sessions saved before a restart are picked up again.
+func (d *dispatcher) session(chatID int64) *quiz.Session {
+	session, ok := d.sessions[chatID]
+	if !ok {
+		session, ok = d.restoreSession(chatID)
+	}
+	if !ok || session.Expired(time.Now()) {
+		session = &quiz.Session{LastAnswer: time.Now()}
+		d.sessions[chatID] = session
+	}
+
+	return session
+}
+
+// continuePractice asks the next question of an adaptive practice session, a quiz session or a review through the
+// given sender, or ends it with a summary.
+func (d *dispatcher) continuePractice(botAPI sender, chatID int64, session *quiz.Session) {
+	if session.Questions > 0 {
+		d.continueQuiz(botAPI, chatID, session)
+		return
+	}
+
+	if session.Queue != nil {
+		d.continueReview(botAPI, chatID, session)
+		return
+	}
+
+	if session.Goal <= 0 {
+		return
+	}
+
+	if over, reason := session.PracticeOver(); over {
+		session.Goal = 0
+		if session.StatusMessageID != 0 {
+			settings, err := d.settingsStore.Settings(chatID)
+			if err != nil {
+				log.Printf("Failed to read settings, not posting the scores. %s.\n", err)
+			} else {
+				postScores(settings, chatID, session, time.Now())
+			}
+		}
+
+		updateGroupQuiz(d.bot, chatID, session, reason)
+		d.emitCompleted(chatID, "practice", session)
+		d.sendRecap(chatID, reason, session)
+
+		return
+	}
+
+	if prompt, ok := d.askWarmUp(chatID, session); ok {
+		respond(botAPI, "warm-up", prompt)
+		return
+	}
+
+	question := d.askRandom(botAPI, chatID, telegram.KindVocabulary, "")
+	if question != nil {
+		d.setPending(chatID, *question)
+	} else {
+		session.Goal = 0
+		updateGroupQuiz(d.bot, chatID, session, "No words to practise.")
+	}
+}
+
+// recordReview reschedules the next review of the word given whether it was answered correctly, and reports the class
+// assignment it may complete. It returns the entry as it was before the review.
+func (d *dispatcher) recordReview(chatID int64, from *tgbotapi.User, word string, correct bool) telegram.WordEntry {
+	var before telegram.WordEntry
+
+	now := time.Now()
+	err := d.words.Review(chatID, word, func(entry telegram.WordEntry) telegram.WordEntry {
+		before = entry
+		return quiz.TrackMistake(d.reviewScheduler.Review(entry, correct, now), correct, now)
+	})
+	if err != nil {
+		log.Printf("Failed to mark %s reviewed. %s.\n", word, err)
+		return before
+	}
+
+	if len(d.gradebook) == 0 {
+		return before
+	}
+
+	name := ""
+	if from != nil {
+		name = strings.TrimSpace(from.FirstName + " " + from.LastName)
+	}
+
+	respond(d.bot, "assignment completion", completeAssignment(d.words, d.settingsStore, d.templateStore, d.gradebook,
+		chatID, name, now))
+	return before
+}
+
+// gradeByReaction grades the missed word again when the user reacts to the message revealing its answer, 👍 as Good
+// when the miss was a typo and 👎 as Again. The other reactions and messages are ignored.
+func (d *dispatcher) gradeByReaction(reaction *updates.MessageReaction) {
+	chatID := reaction.Chat.ID
+	last, ok := d.reveals[chatID]
+	if !ok || last.messageID != reaction.MessageID || len(reaction.NewReaction) == 0 {
+		return
+	}
+
+	var good bool
+	switch reaction.NewReaction[len(reaction.NewReaction)-1].Emoji {
+	case "👍":
+		good = true
+	case "👎":
+		good = false
+	default:
+		return
+	}
+
+	now := time.Now()
+	err := d.words.Review(chatID, last.word, func(_ telegram.WordEntry) telegram.WordEntry {
+		return quiz.TrackMistake(d.reviewScheduler.Review(last.before, good, now), good, now)
+	})
+	if err != nil {
+		log.Printf("Failed to grade %s again. %s.\n", last.word, err)
+		return
+	}
+
+	text := fmt.Sprintf("Graded %s as Again.", last.word)
+	if good {
+		text = fmt.Sprintf("Graded %s as Good.", last.word)
+	}
+
+	respond(d.bot, "reaction", tgbotapi.NewMessage(chatID, text))
+}
+
+// register registers the user and tells whether the user is registered, new or not.
+func (d *dispatcher) register(chatID int64) bool {
+	reply, registered := registerUser(d.users, chatID)
+	respond(d.bot, "registration", reply)
+
+	return registered
+}
+
+// askRandom quizzes a random word of the kind tagged with the tag, if any, through the given sender and counts it in the
+// stats, it returns the question asked, nil when there is none.
+func (d *dispatcher) askRandom(botAPI sender, chatID int64, kind string, tag string) *quiz.Question {
+	reply, question := randomWord(d.words, d.settingsStore, d.quizEngine, d.recent, d.cooldown, chatID, kind, tag,
+		d.font)
+	respond(botAPI, "random word", reply)
+	if question == nil {
+		return nil
+	}
+
+	err := d.statsStore.CountAsked(chatID)
+	if err != nil {
+		log.Printf("Failed to count asked quiz. %s.\n", err)
+	}
+
+	return question
+}
+
+// transcribe appends the answer given to the question to the transcript of the user.
+func (d *dispatcher) transcribe(chatID int64, question quiz.Question, answer string, correct bool) {
+	err := d.transcriptStore.RecordLine(chatID, telegram.TranscriptLine{Prompt: question.Prompt,
+		Expected: question.Answer, Answer: answer, Correct: correct, At: time.Now()})
+	if err != nil {
+		log.Printf("Failed to record transcript. %s.\n", err)
+	}
+}
+
+// countAnswer counts the answer in the stats given whether it was correct, and updates the leaderboard.
+func (d *dispatcher) countAnswer(chatID int64, correct bool) {
+	err := d.statsStore.CountAnswer(chatID, correct)
+	if err != nil {
+		log.Printf("Failed to count answer. %s.\n", err)
+		return
+	}
+
+	updatePlayer(d.leaderboard, d.statsStore, d.activityStore, d.settingsStore, chatID)
+}
+
+// recordStudy counts the answer of the user in the study streak of the chat.
+func (d *dispatcher) recordStudy(chatID int64, from *tgbotapi.User) {
+	name := ""
+	if from != nil {
+		name = from.FirstName
+	}
+
+	settings, err := d.settingsStore.Settings(chatID)
+	if err != nil {
+		log.Printf("Failed to read settings, using the defaults. %s.\n", err)
+	}
+
+	previous, err := d.activityStore.Activity(chatID)
+	if err != nil {
+		log.Printf("Failed to read activity. %s.\n", err)
+	}
+
+	now := time.Now()
+	activity, err := d.activityStore.RecordStudy(chatID, name, now, settings.Location())
+	if err != nil {
+		log.Printf("Failed to record study. %s.\n", err)
+		return
+	}
+
+	err = d.statsStore.RecordStreak(chatID, activity.Streak)
+	if err != nil {
+		log.Printf("Failed to record streak. %s.\n", err)
+	}
+
+	// The streak starts again from one after a day without studying.
+	if previous.Streak > 1 && activity.Streak == 1 {
+		d.events.Emit(chatID, events.StreakBroken, map[string]interface{}{"streak": previous.Streak,
+			"last_day": previous.LastDay})
+	}
+
+	// The first quiz soon after a nudge counts as engagement with its variant.
+	if len(previous.Nudge) != 0 && previous.LastStudied.Before(previous.NudgedAt) &&
+		now.Sub(previous.NudgedAt) <= nudge.EngagementWindow {
+		err = d.analyticsStore.Count(previous.Nudge + "/" + nudge.EventEngaged)
+		if err != nil {
+			log.Printf("Failed to count engagement. %s.\n", err)
+		}
+	}
+}
+
+// answerChoice handles the button pressed in the multiple choice quiz. The buttons of the earlier messages are stale
+// and ignored.
+func (d *dispatcher) answerChoice(query *tgbotapi.CallbackQuery) {
+	chatID := query.Message.Chat.ID
+	session := d.session(chatID)
+	question, ok := d.pendingQuestion(chatID)
+	if !ok || question.Kind != quiz.KindChoice || session.LiveMessageID != query.Message.MessageID {
+		return
+	}
+
+	settings, err := d.settingsStore.Settings(chatID)
+	if err != nil {
+		log.Printf("Failed to read settings, using the defaults. %s.\n", err)
+	}
+
+	data := strings.TrimPrefix(query.Data, choiceCallbackPrefix)
+	before := *session
+	edit, next := answerChoice(d.words, d.quizEngine, d.recent, d.cooldown, chatID, question, data, session, settings)
+	respond(d.bot, "choice", edit)
+	if session.Answered > before.Answered {
+		d.recordStudy(chatID, query.From)
+		d.countAnswer(chatID, session.Correct > before.Correct)
+		if index, err := strconv.Atoi(data); err == nil && index >= 0 && index < len(question.Options) {
+			d.transcribe(chatID, question, question.Options[index], session.Correct > before.Correct)
+		}
+		d.recordReview(chatID, query.From, question.Word, session.Correct > before.Correct)
+		if session.Correct == before.Correct {
+			session.Miss(question.Word)
+		}
+	}
+
+	if next != nil {
+		d.setPending(chatID, *next)
+	} else {
+		d.clearPending(chatID)
+		d.emitCompleted(chatID, "choice", session)
+
+		// The quiz message already ends with the summary, the recap only follows for the missed words.
+		if len(session.Missed) != 0 {
+			d.sendRecap(chatID, "Quiz over.", session)
+		}
+	}
+}
+
+// applyTemplate applies the class template of the token and asks the student to resolve the words they already have
+// with another translation.
+func (d *dispatcher) applyTemplate(chatID int64, userID int, token string) {
+	reply, conflicts := applyTemplate(d.templateStore, d.adder, d.words, d.deckStore, d.settingsStore, chatID, token)
+	respond(d.bot, "template", reply)
+	d.reviewConflicts(chatID, userID, conflicts)
+}
+
+// deferTemplate keeps the token of the class template until the privacy notice is accepted.
+func (d *dispatcher) deferTemplate(chatID int64, token string) {
+	settings, err := d.settingsStore.Settings(chatID)
+	if err == nil {
+		settings.PendingTemplate = token
+		err = d.settingsStore.SaveSettings(chatID, settings)
+	}
+	if err != nil {
+		log.Printf("Failed to save pending template. %s.\n", err)
+	}
+}
+
+// acceptPrivacy handles the acceptance of the privacy notice. New users try a sample word once they accepted it, the
+// notices of earlier versions are shown again in the current version.
+func (d *dispatcher) acceptPrivacy(query *tgbotapi.CallbackQuery) {
+	chatID := query.Message.Chat.ID
+	if strings.TrimPrefix(query.Data, privacyCallbackPrefix) != d.privacyVersion {
+		respond(d.bot, "privacy", showPrivacyNotice(chatID, d.privacyNotice, d.privacyVersion))
+		return
+	}
+
+	edit, first := acceptPrivacy(d.settingsStore, chatID, query.Message.MessageID, d.privacyNotice, d.privacyVersion,
+		time.Now())
+	respond(d.bot, "privacy", edit)
+
+	// Students who opened the link of their class template get it instead of the sample word.
+	settings, err := d.settingsStore.Settings(chatID)
+	if err == nil && len(settings.PendingTemplate) != 0 && settings.PrivacyVersion == d.privacyVersion {
+		token := settings.PendingTemplate
+		settings.PendingTemplate = ""
+		err = d.settingsStore.SaveSettings(chatID, settings)
+		if err != nil {
+			log.Printf("Failed to save settings. %s.\n", err)
+			return
+		}
+
+		d.applyTemplate(chatID, query.From.ID, token)
+		return
+	}
+
+	if !first {
+		return
+	}
+
+	reply, question := trySample(d.sampleDeck, d.quizEngine, chatID)
+	respond(d.bot, "sample", reply)
+	if question != nil {
+		d.setPending(chatID, *question)
+	}
+}
+
+// continueReview asks the next word of the review queue through the given sender, or ends the review with a summary.
+func (d *dispatcher) continueReview(botAPI sender, chatID int64, session *quiz.Session) {
+	// The assignment ends at its deadline, the questions left are not asked.
+	if session.Overdue(time.Now()) {
+		session.Queue = nil
+		session.Deadline = time.Time{}
+		d.emitCompleted(chatID, "assignment", session)
+		d.sendRecap(chatID, "The deadline of the assignment has passed.", session)
+
+		return
+	}
+
+	if prompt, ok := d.askWarmUp(chatID, session); ok {
+		respond(botAPI, "warm-up", prompt)
+		return
+	}
+
+	entry, ok := session.Next()
+	if !ok {
+		session.Queue = nil
+
+		done, kind := "Review done!", "review"
+		if session.Assignment {
+			done, kind = "Assignment done!", "assignment"
+		}
+		d.emitCompleted(chatID, kind, session)
+		d.sendRecap(chatID, done, session)
+
+		return
+	}
+
+	settings, err := d.settingsStore.Settings(chatID)
+	if err != nil {
+		log.Printf("Failed to read settings, using the defaults. %s.\n", err)
+	}
+
+	question := quiz.ForUser(entry, settings)
+	d.setPending(chatID, question)
+
+	respond(botAPI, "review", promptMessage(chatID, question.Prompt, settings, d.font, false))
+}
+
+// flowOwner returns the user who started the flow the pressed button belongs to, e.g. the audit, false when the
+// button is not one of such a flow or the flow is over.
+func (d *dispatcher) flowOwner(query *tgbotapi.CallbackQuery) (int, bool) {
+	chatID := query.Message.Chat.ID
+	switch {
+	case strings.HasPrefix(query.Data, auditCallbackPrefix):
+		if c, ok := d.cleanups[chatID]; ok {
+			return c.userID, true
+		}
+	case strings.HasPrefix(query.Data, conflictCallbackPrefix):
+		if r, ok := d.conflicts[chatID]; ok {
+			return r.userID, true
+		}
+	}
+
+	return 0, false
+}
+
+// mayPress reports whether the user may press the button, changing the words of the chat. The buttons of a flow are
+// pressed by the user who started it, the admins of the bot and, in groups, the admins of the group.
+func (d *dispatcher) mayPress(query *tgbotapi.CallbackQuery) bool {
+	if query.Message == nil {
+		return true
+	}
+
+	owner, ok := d.flowOwner(query)
+	if !ok || owner == query.From.ID || d.admins[int64(query.From.ID)] {
+		return true
+	}
+
+	chat := query.Message.Chat
+	return (chat.IsGroup() || chat.IsSuperGroup()) && isGroupAdmin(d.bot, chat.ID, query.From.ID)
+}
+
+// dispatch handles an update. The updates are handled one at a time.
+func (d *dispatcher) dispatch(update updates.Update) {
+	if d.isBanned(update) {
+		return
+	}
+
+	if update.MessageReaction != nil {
+		d.gradeByReaction(update.MessageReaction)
+		return
+	}
+
+	if update.CallbackQuery != nil {
+		query := update.CallbackQuery
+		d.metrics.RecordUser(int64(query.From.ID), time.Now())
+		log.Printf("Received callback from %s[%d]: %s\n", query.From.UserName, query.From.ID, query.Data)
+
+		// Let's stop the loading indicator on the button first. The buttons of the flows started by another member of
+		// the group are refused.
+		allowed := d.mayPress(query)
+		answer := tgbotapi.NewCallback(query.ID, "")
+		if !allowed {
+			answer.Text = "Only the member who started this, or an admin, can use its buttons."
+		}
+
+		_, err := d.bot.AnswerCallbackQuery(answer)
+		if err != nil {
+			log.Printf("Failed to answer callback query. %s.\n", err)
+		}
+
+		if !allowed {
+			return
+		}
+
+		if query.Message != nil && strings.HasPrefix(query.Data, hanjaCallbackPrefix) {
+			respond(d.bot, "hanja lookup", lookupHanja(d.hanjaDict, query.Message.Chat.ID,
+				strings.TrimPrefix(query.Data, hanjaCallbackPrefix)))
+		}
+
+		if query.Message != nil && strings.HasPrefix(query.Data, choiceCallbackPrefix) {
+			d.answerChoice(query)
+		}
+
+		if query.Message != nil && strings.HasPrefix(query.Data, privacyCallbackPrefix) {
+			d.acceptPrivacy(query)
+		}
+
+		if query.Message != nil && strings.HasPrefix(query.Data, recapCallbackPrefix) {
+			respond(d.bot, "recap", d.actOnRecap(query))
+		}
+
+		if query.Message != nil && strings.HasPrefix(query.Data, conflictCallbackPrefix) {
+			respond(d.bot, "conflict review", d.resolveConflict(query))
+		}
+
+		if query.Message != nil && strings.HasPrefix(query.Data, lemmaCallbackPrefix) {
+			d.chooseForm(query)
+		}
+
+		if query.Message != nil && strings.HasPrefix(query.Data, auditCallbackPrefix) {
+			respond(d.bot, "audit", d.actOnAudit(query))
+		}
+
+		if query.Message != nil && strings.HasPrefix(query.Data, listCallbackPrefix) {
+			if offset, tag, ok := parseListCallback(query.Data); ok {
+				respond(d.bot, "list page", turnListPage(d.words, query.Message.Chat.ID, query.Message.MessageID, tag,
+					offset))
+			}
+		}
+
+		return
+	}
+
+	if update.Message == nil {
+		return
+	}
+
+	if update.Message.From != nil {
+		d.metrics.RecordUser(int64(update.Message.From.ID), time.Now())
+	}
+
+	username := update.Message.Chat.UserName
+	chatID := update.Message.Chat.ID
+	message := update.Message.Text
+	group := update.Message.Chat.IsGroup() || update.Message.Chat.IsSuperGroup()
+
+	// The flows started by a member of a group, e.g. /audit, only take the button presses of that member.
+	userID := 0
+	if update.Message.From != nil {
+		userID = update.Message.From.ID
+	}
+
+	// The users refer to each other by username, e.g. to grant their tutor access, which needs their chat ID.
+	if !group {
+		err := d.access.RememberUser(chatID, username)
+		if err != nil {
+			log.Printf("Failed to remember username. %s.\n", err)
+		}
+	}
+
+	if update.Message.Voice != nil {
+		log.Printf("Received voice message from %s[%d]\n", username, chatID)
+
+		// Voice messages are only expected as the answer of the speaking practice.
+		question, ok := d.pendingQuestion(chatID)
+		if !ok || question.Kind != quiz.KindSpeaking || !d.featureFlags.Enabled(features.STT) {
+			return
+		}
+
+		ack := acknowledge(d.bot, chatID, tgbotapi.ChatTyping, "")
+		respond(ack, "voice", answerSpeaking(d.pronunciationStore, d.registry.STT, ack, chatID, question,
+			update.Message.Voice))
+		d.clearPending(chatID)
+		d.recordStudy(chatID, update.Message.From)
+		return
+	}
+
+	// Photos are only expected as the share cards of the decks, scanned in private.
+	if update.Message.Photo != nil && len(*update.Message.Photo) != 0 {
+		log.Printf("Received photo from %s[%d]\n", username, chatID)
+
+		if !group {
+			respond(d.bot, "photo", scanCard(d.templateStore, d.bot, chatID, d.botName, *update.Message.Photo))
+		}
+
+		return
+	}
+
+	// Documents are only expected as the files to import, sent with the command as their caption.
+	if update.Message.Document != nil {
+		message = update.Message.Caption
+	}
+
+	log.Printf("Received message from %s[%d]: %s\n", username, chatID, message)
+
+	// The notes asked for by the recap of a session come as the reply to the prompt.
+	if reply := update.Message.ReplyToMessage; reply != nil {
+		if prompt, ok := d.notePrompts[chatID]; ok && prompt.messageID == reply.MessageID {
+			respond(d.bot, "recap notes", d.noteFromRecap(chatID, prompt, message))
+			return
+		}
+	}
+
+	var groupSettings telegram.Settings
+	if group {
+		var err error
+		groupSettings, err = d.settingsStore.Settings(chatID)
+		if err != nil {
+			log.Printf("Failed to read settings, using the defaults. %s.\n", err)
+		}
+	}
+
+	// The texts forwarded in private, e.g. from a Korean channel, are mined for the words the user does not know yet.
+	if !group && update.Message.ForwardDate != 0 && len(message) != 0 && d.users.IsRegistered(chatID) &&
+		(len(d.privacyVersion) == 0 || acceptedPrivacy(d.settingsStore, chatID, d.privacyVersion)) {
+		respond(d.bot, "forwarded message", extractWords(d.registry.Analyzer, d.users, chatID, message))
+		return
+	}
+
+	message, argument, ok := parseCommand(message, d.botName, groupSettings.Prefix, group)
+	if !ok {
+		return
+	}
+
+	// The question prompts and the feedback on the answers are transient, groups may have them cleaned up.
+	quizBot := d.bot
+	if group && groupSettings.CleanupMinutes > 0 {
+		quizBot = transientSender{
+			sender:  d.bot,
+			janitor: d.janitor,
+			delay:   time.Duration(groupSettings.CleanupMinutes) * time.Minute,
+		}
+	}
+
+	if !isAvailable(d.featureFlags, d.admins[chatID], message) {
+		respond(d.bot, "command", tgbotapi.NewMessage(chatID, "This command is not available."))
+
+		return
+	}
+
+	// The users accept the privacy notice before using the bot in private, and again whenever it changes.
+	if !group && len(d.privacyVersion) != 0 && !privacyExempt[message] &&
+		!acceptedPrivacy(d.settingsStore, chatID, d.privacyVersion) {
+		if message == "/start" || message == "/register" {
+			template := strings.HasPrefix(argument, templateStartPrefix)
+			if (!template || !d.users.IsRegistered(chatID)) && !d.register(chatID) {
+				return
+			}
+
+			// The class template is applied once the notice is accepted.
+			if template {
+				d.deferTemplate(chatID, strings.TrimPrefix(argument, templateStartPrefix))
+			}
+		}
+
+		respond(d.bot, "privacy", showPrivacyNotice(chatID, d.privacyNotice, d.privacyVersion))
+		return
+	}
+
+	// Only the known commands are logged, never the answers nor the arguments.
+	if isCommand(message) {
+		d.metrics.RecordCommand(time.Now())
+		err := d.analyticsStore.LogCommand(chatID, message, time.Now())
+		if err != nil {
+			log.Printf("Failed to log command. %s.\n", err)
+		}
+	}
+
+	switch message {
+	case "/start", "/register":
+		// Students open the link of their class template, registered or not.
+		if strings.HasPrefix(argument, templateStartPrefix) {
+			if !d.users.IsRegistered(chatID) && !d.register(chatID) {
+				return
+			}
+
+			d.applyTemplate(chatID, userID, strings.TrimPrefix(argument, templateStartPrefix))
+			return
+		}
+
+		if !d.register(chatID) {
+			return
+		}
+
+		// New users try a sample word straight away, before adding words of their own.
+		reply, question := trySample(d.sampleDeck, d.quizEngine, chatID)
+		respond(quizBot, "sample", reply)
+		if question != nil {
+			d.setPending(chatID, *question)
+		}
+
+	case "/stop", "/unregister":
+		// Stop ends the quiz session first, the users who are not in one unregister as before.
+		if message == "/stop" && d.stopQuiz(chatID) {
+			return
+		}
+
+		respond(d.bot, "unregistration", unregisterUser(d.users, chatID))
+
+		err := d.leaderboard.Leave(chatID)
+		if err != nil {
+			log.Printf("Failed to leave leaderboard. %s.\n", err)
+		}
+
+	case "/privacy":
+		if len(d.privacyVersion) == 0 {
+			respond(d.bot, "privacy", tgbotapi.NewMessage(chatID, "No privacy notice is configured."))
+
+			return
+		}
+
+		respond(d.bot, "privacy", showPrivacyNotice(chatID, d.privacyNotice, d.privacyVersion))
+
+	case "/add":
+		// The trailing hashtags tag the word, e.g. /add 사과 apple #food.
+		argument, tags := parseTags(argument)
+
+		// Without translation, let's translate the word ourselves if we can.
+		if len(argument) != 0 && strings.Index(argument, " ") == -1 && d.featureFlags.Enabled(features.Translation) {
+			if lemma, ok := dictionaryForm(d.registry.Analyzer, argument); ok {
+				d.offerDictionaryForm(chatID, lemmaPrompt{word: argument, lemma: lemma, tags: tags})
+				return
+			}
+
+			ack := acknowledge(d.bot, chatID, tgbotapi.ChatTyping, "")
+			respond(ack, "add word", addTranslatedWord(d.adder, d.registry.Translator, chatID, argument,
+				d.translationLanguage, tags))
+			return
+		}
+
+		if len(argument) == 0 || strings.Index(argument, " ") == -1 {
+			msg := tgbotapi.NewMessage(chatID, "Please provide the Korean word and its translation.")
+			respond(d.bot, "add word", msg)
+
+			return
+		}
+
+		splitted := strings.SplitN(argument, " ", 2)
+		word := splitted[0]
+		translation := splitted[1]
+
+		// The conjugated verbs and adjectives are better learnt in their dictionary form, e.g. 먹다 for 먹어요.
+		if lemma, ok := dictionaryForm(d.registry.Analyzer, word); ok {
+			d.offerDictionaryForm(chatID, lemmaPrompt{word: word, lemma: lemma, translation: translation, tags: tags})
+			return
+		}
+
+		respond(d.bot, "add word", addWord(d.adder, chatID, word, translation, tags))
+
+	case "/addgrammar":
+		pattern, meaning, example, ok := parseGrammar(argument)
+		if !ok {
+			msg := tgbotapi.NewMessage(chatID, "Please provide the grammar pattern and its meaning, optionally "+
+				"followed by an example, e.g. /addgrammar -(으)려고 in order to | 한국어를 배우려고 왔어요.")
+			respond(d.bot, "add grammar", msg)
+
+			return
+		}
+
+		respond(d.bot, "add grammar", addGrammar(d.adder, chatID, pattern, meaning, example))
+
+	case "/grammar":
+		respond(d.bot, "list grammar", listGrammar(d.words, chatID)...)
+
+	case "/search":
+		if len(argument) == 0 {
+			respond(d.bot, "search word", tgbotapi.NewMessage(chatID, "Please provide the Korean word."))
+
+			return
+		}
+
+		respond(d.bot, "search word", searchWord(d.words, d.hanjaDict, chatID, argument))
+
+	case "/define":
+		if len(argument) == 0 {
+			respond(d.bot, "define", tgbotapi.NewMessage(chatID, "Please provide the Korean word."))
+
+			return
+		}
+
+		respond(acknowledge(d.bot, chatID, tgbotapi.ChatTyping, ""), "define word", defineWord(d.registry.Dictionary,
+			chatID, argument))
+
+	case "/hanja":
+		if len(argument) == 0 {
+			respond(d.bot, "hanja lookup", tgbotapi.NewMessage(chatID, "Please provide the Korean word."))
+
+			return
+		}
+
+		respond(d.bot, "hanja lookup", lookupHanja(d.hanjaDict, chatID, argument))
+
+	case "/related":
+		if len(argument) == 0 {
+			respond(d.bot, "related words", tgbotapi.NewMessage(chatID, "Please provide the Korean word."))
+
+			return
+		}
+
+		respond(d.bot, "related words", relatedWords(d.words, d.rootFinder, chatID, argument))
+
+	case "/random":
+		kind := telegram.KindVocabulary
+		if strings.HasPrefix(argument, "grammar") {
+			kind = telegram.KindGrammar
+			argument = strings.TrimSpace(strings.TrimPrefix(argument, "grammar"))
+		}
+
+		question := d.askRandom(quizBot, chatID, kind, parseTag(argument))
+
+		if question != nil {
+			d.setPending(chatID, *question)
+		}
+
+	case "/example":
+		splitted := strings.SplitN(argument, " ", 2)
+		if len(splitted) != 2 || len(strings.TrimSpace(splitted[1])) == 0 {
+			msg := tgbotapi.NewMessage(chatID, "Please provide the Korean word and an example sentence.")
+			respond(d.bot, "set example", msg)
+
+			return
+		}
+
+		respond(d.bot, "set example", setExample(d.updater, chatID, splitted[0], strings.TrimSpace(splitted[1])))
+
+	case "/note":
+		splitted := strings.SplitN(argument, " ", 2)
+		if len(splitted) != 2 || len(strings.TrimSpace(splitted[1])) == 0 {
+			respond(d.bot, "set notes", tgbotapi.NewMessage(chatID, "Please provide the Korean word and your notes."))
+
+			return
+		}
+
+		respond(d.bot, "set notes", setNotes(d.updater, chatID, splitted[0], strings.TrimSpace(splitted[1])))
+
+	case "/dictation":
+		stop := keepAction(d.bot, chatID, chatRecordVoice)
+		reply, question := dictation(d.words, d.quizEngine, d.registry.TTS, d.audioCache, quizBot, chatID)
+		stop()
+		respond(quizBot, "dictation", reply)
+
+		if question != nil {
+			d.setPending(chatID, *question)
+		}
+
+	case "/speak":
+		if argument == "stats" {
+			respond(d.bot, "pronunciation stats", pronunciationStats(d.pronunciationStore, chatID))
+			return
+		}
+
+		reply, question := speakingPractice(d.words, d.quizEngine, d.registry.STT, chatID)
+		respond(quizBot, "speaking practice", reply)
+
+		if question != nil {
+			d.setPending(chatID, *question)
+		}
+
+	case "/stats":
+		reply, owner, _, ok := readableOwner(d.access, chatID, argument)
+		respond(d.bot, "access", reply)
+		if ok {
+			respond(d.bot, "stats", showStats(d.statsStore, d.activityStore, d.settingsStore, chatID, owner))
+		}
+
+	case "/grant", "/revoke":
+		if group || !strings.HasPrefix(argument, "@") || strings.Contains(argument, " ") {
+			msg := tgbotapi.NewMessage(chatID, fmt.Sprintf("Please provide the username of your tutor in a private "+
+				"chat, e.g. %s @tutor.", message))
+			respond(d.bot, "revoke", msg)
+
+			return
+		}
+
+		if message == "/revoke" {
+			respond(d.bot, "revoke", revokeAccess(d.access, chatID, argument))
+			return
+		}
+
+		if len(username) == 0 {
+			msg := tgbotapi.NewMessage(chatID, "Please set a Telegram username first, your tutor refers to you by it.")
+			respond(d.bot, "grant", msg)
+
+			return
+		}
+
+		respond(d.bot, "grant", grantAccess(d.access, chatID, username, argument)...)
+
+	case "/grants":
+		respond(d.bot, "grants", listGrants(d.access, chatID))
+
+	case "/transcript":
+		reply, owner, argument, ok := readableOwner(d.access, chatID, argument)
+		respond(d.bot, "access", reply)
+		if !ok {
+			return
+		}
+
+		period, ok := parsePeriod(argument)
+		if !ok {
+			msg := tgbotapi.NewMessage(chatID, "Please provide the period in days or hours, e.g. /transcript 7d.")
+			respond(d.bot, "transcript", msg)
+
+			return
+		}
+
+		ack := acknowledge(d.bot, chatID, tgbotapi.ChatUploadDocument, "")
+		respond(ack, "transcript", sendTranscript(d.transcriptStore, d.settingsStore, chatID, owner, period))
+
+	case "/changes":
+		period := defaultChangesPeriod
+		if len(argument) != 0 {
+			var ok bool
+			period, ok = parsePeriod(argument)
+			if !ok {
+				msg := tgbotapi.NewMessage(chatID, "Please provide the period in days or hours, e.g. /changes 30d.")
+				respond(d.bot, "changes", msg)
+
+				return
+			}
+		}
+
+		respond(d.bot, "changes", showChanges(d.words, d.snapshotStore, d.settingsStore, chatID, period))
+
+	case "/leaderboard":
+		switch argument {
+		case "", telegram.RankAccuracy, telegram.RankStreak:
+			respond(d.bot, "leaderboard", showLeaderboard(d.leaderboard, chatID, argument))
+			return
+		case "leave":
+			respond(d.bot, "leaderboard", leaveLeaderboard(d.leaderboard, chatID))
+			return
+		}
+
+		// Only the users take part, a group would rank the answers of all its members.
+		if argument != "join" || group || update.Message.From == nil {
+			msg := tgbotapi.NewMessage(chatID, "Please choose /leaderboard [accuracy|streak], or /leaderboard join "+
+				"or /leaderboard leave in a private chat.")
+			respond(d.bot, "leaderboard", msg)
+
+			return
+		}
+
+		respond(d.bot, "leaderboard", joinLeaderboard(d.leaderboard, d.statsStore, d.activityStore, d.settingsStore,
+			chatID, update.Message.From.FirstName))
+
+	case "/sentence":
+		reply, question := sentenceBuilding(d.words, d.quizEngine, chatID)
+		respond(quizBot, "sentence", reply)
+
+		if question != nil {
+			d.setPending(chatID, *question)
+		}
+
+	case "/delete":
+		if len(argument) == 0 {
+			respond(d.bot, "delete word", tgbotapi.NewMessage(chatID, "Please provide the Korean word."))
+
+			return
+		}
+
+		respond(d.bot, "delete word", deleteWord(d.words, chatID, argument))
+
+	case "/import":
+		if update.Message.Document != nil {
+			ack := acknowledge(d.bot, chatID, tgbotapi.ChatTyping, "Importing your words...")
+			reply, conflicts := importDocument(d.users, d.adder, d.words, ack, chatID, update.Message.Document)
+			respond(ack, "import", reply)
+			d.reviewConflicts(chatID, userID, conflicts)
+			return
+		}
+
+		source := strings.SplitN(argument, " ", 2)
+		if len(source) != 2 || (source[0] != "sheet" && source[0] != "set") {
+			msg := tgbotapi.NewMessage(chatID, "Please provide the import source, e.g. /import sheet <Google Sheets URL> "+
+				"or /import set <Quizlet or Memrise URL>, or send a CSV file with /import as its caption.")
+			respond(d.bot, "import", msg)
+
+			return
+		}
+
+		if !d.featureFlags.Enabled(features.RemoteImport) {
+			respond(d.bot, "import", tgbotapi.NewMessage(chatID, "Importing from websites is not available."))
+
+			return
+		}
+
+		ack := acknowledge(d.bot, chatID, tgbotapi.ChatTyping, "Importing your words...")
+		var reply tgbotapi.Chattable
+		var conflicts []translationConflict
+		if source[0] == "sheet" {
+			reply, conflicts = importSheet(d.adder, d.words, chatID, source[1])
+		} else {
+			reply, conflicts = importSet(d.users, d.adder, d.words, d.deckStore, chatID, source[1])
+		}
+
+		respond(ack, "import", reply)
+		d.reviewConflicts(chatID, userID, conflicts)
+
+	case "/decks":
+		respond(d.bot, "list decks", listDecks(d.deckStore, chatID))
+
+	case "/export":
+		if argument == exportCSV || argument == exportAnki || argument == exportPDF {
+			ack := acknowledge(d.bot, chatID, tgbotapi.ChatUploadDocument, "Preparing your export...")
+			respond(ack, "export", exportFile(d.words, chatID, argument, d.font))
+			return
+		}
+
+		if argument != "link" {
+			msg := tgbotapi.NewMessage(chatID, "Please provide the export type, e.g. /export link, /export csv, "+
+				"/export anki or /export pdf.")
+			respond(d.bot, "export link", msg)
+
+			return
+		}
+
+		respond(d.bot, "export link", exportLink(d.users, d.exportLinks, chatID, d.publicURL, d.exportLinkTTL))
+
+	case "/apitoken":
+		if group || (argument != "" && argument != "off") {
+			msg := tgbotapi.NewMessage(chatID, "In a private chat, please use /apitoken to get a token adding words "+
+				"from other services, or /apitoken off to revoke it.")
+			respond(d.bot, "API token", msg)
+
+			return
+		}
+
+		if argument == "off" {
+			respond(d.bot, "API token", revokeToken(d.tokens, chatID))
+		} else {
+			respond(d.bot, "API token", issueToken(d.users, d.tokens, chatID, d.publicURL))
+		}
+
+	case "/template":
+		respond(d.bot, "template", createTemplate(d.words, d.settingsStore, d.templateStore, chatID, d.botName,
+			argument))
+
+	case "/card":
+		ack := acknowledge(d.bot, chatID, tgbotapi.ChatUploadPhoto, "")
+		respond(ack, "card", shareCard(d.words, d.settingsStore, d.templateStore, chatID, d.botName, argument, d.font))
+
+	case "/practice":
+		goal := quiz.DefaultPracticeGoal
+		if len(argument) != 0 {
+			var err error
+			goal, err = strconv.Atoi(argument)
+			if err != nil || goal < 1 {
+				msg := tgbotapi.NewMessage(chatID, "Please provide the number of correct answers to reach, e.g. "+
+					"/practice 10.")
+				respond(d.bot, "practice", msg)
+
+				return
+			}
+		}
+
+		// Practice starts a fresh session, so that its accuracy is not dragged by the earlier answers.
+		session := &quiz.Session{Goal: goal, LastAnswer: time.Now()}
+		d.sessions[chatID] = session
+		d.warmUp(chatID, session)
+
+		msg := tgbotapi.NewMessage(chatID, fmt.Sprintf("Let's practise until you get %d correct answers.", goal))
+		respond(d.bot, "practice", msg)
+
+		// In groups, the members compete with live standings in a pinned message.
+		if group {
+			startGroupQuiz(d.bot, chatID, d.botID, update.Message.Chat.IsSuperGroup(), session)
+		}
+
+		d.continuePractice(quizBot, chatID, session)
+
+	case "/review":
+		// Review starts a fresh session, so that the summary covers the review only.
+		session := &quiz.Session{LastAnswer: time.Now()}
+		d.sessions[chatID] = session
+
+		reply, ok := startReview(d.words, d.settingsStore, chatID, session)
+		respond(d.bot, "review", reply)
+		if ok {
+			d.warmUp(chatID, session)
+			d.continueReview(quizBot, chatID, session)
+		}
+
+	case "/mistakes":
+		if argument != "quiz" {
+			respond(d.bot, "mistakes", showMistakes(d.words, chatID))
+			return
+		}
+
+		// The quiz of the mistakes is a review of the notebook, the correct answers count towards taking the words out.
+		session := &quiz.Session{LastAnswer: time.Now()}
+		d.sessions[chatID] = session
+
+		reply, ok := startMistakes(d.words, chatID, session)
+		respond(d.bot, "mistakes", reply)
+		if ok {
+			d.warmUp(chatID, session)
+			d.continueReview(quizBot, chatID, session)
+		}
+
+	case "/mastered":
+		if len(argument) == 0 {
+			respond(d.bot, "mastered", showMastered(d.words, d.settingsStore, chatID))
+			return
+		}
+
+		value := strings.TrimSpace(strings.TrimPrefix(argument, "resurface"))
+		count, err := strconv.Atoi(value)
+		if value == "off" {
+			count, err = 0, nil
+		}
+
+		if !strings.HasPrefix(argument, "resurface") || err != nil || count < 0 || count > maxResurfacePerWeek {
+			msg := tgbotapi.NewMessage(chatID, fmt.Sprintf("Please provide how many mastered words come back "+
+				"into your reviews every week, up to %d, e.g. /mastered resurface 5, or /mastered resurface off.",
+				maxResurfacePerWeek))
+			respond(d.bot, "resurface", msg)
+
+			return
+		}
+
+		respond(d.bot, "resurface", setResurface(d.settingsStore, chatID, count))
+
+	case "/assignment":
+		// Each attempt starts a fresh session, so that the summary covers the attempt only.
+		session := &quiz.Session{LastAnswer: time.Now()}
+		d.sessions[chatID] = session
+
+		reply, ok := startAssignment(d.words, d.settingsStore, d.templateStore, d.quizEngine, chatID, session)
+		respond(d.bot, "assignment", reply)
+		if ok {
+			d.continueReview(quizBot, chatID, session)
+		}
+
+	case "/reviews":
+		schedule, ok := parseReviews(argument)
+		if !ok {
+			msg := tgbotapi.NewMessage(chatID, "Please provide the morning and evening review times and optionally "+
+				"the number of words to review in the morning, e.g. /reviews 08:00 20:00 20, or /reviews off.")
+			respond(d.bot, "reviews", msg)
+
+			return
+		}
+
+		respond(d.bot, "reviews", setReviews(d.settingsStore, chatID, schedule))
+
+	case "/timezone":
+		respond(d.bot, "timezone", setTimezone(d.settingsStore, chatID, argument))
+
+	case "/prefix":
+		if !group {
+			respond(d.bot, "prefix", tgbotapi.NewMessage(chatID, "Command prefixes are only used in groups."))
+
+			return
+		}
+
+		if argument != "off" && !validPrefix(argument) {
+			msg := tgbotapi.NewMessage(chatID, "Please provide up to 3 punctuation characters, e.g. /prefix !, or "+
+				"/prefix off.")
+			respond(d.bot, "prefix", msg)
+
+			return
+		}
+
+		groupPrefix := argument
+		if argument == "off" {
+			groupPrefix = ""
+		}
+
+		respond(d.bot, "prefix", setPrefix(d.settingsStore, chatID, groupPrefix, d.botName))
+
+	case "/silent":
+		if argument != "on" && argument != "off" {
+			respond(d.bot, "silent", tgbotapi.NewMessage(chatID, "Please choose /silent on or /silent off."))
+
+			return
+		}
+
+		respond(d.bot, "silent", setSilentPushes(d.settingsStore, chatID, argument == "on"))
+
+	case "/daily":
+		if argument == "off" {
+			respond(d.bot, "daily quiz", setDailyQuiz(d.settingsStore, chatID, ""))
+			return
+		}
+
+		at, ok := parseReviewTime(argument)
+		if !ok {
+			msg := tgbotapi.NewMessage(chatID, "Please provide the time of your daily quiz, e.g. /daily 08:00, or "+
+				"/daily off.")
+			respond(d.bot, "daily quiz", msg)
+
+			return
+		}
+
+		respond(d.bot, "daily quiz", setDailyQuiz(d.settingsStore, chatID, at))
+
+	case "/podcast":
+		if argument == "off" {
+			respond(d.bot, "podcast", setPodcast(d.settingsStore, chatID, ""))
+			return
+		}
+
+		if len(argument) == 0 {
+			ack := acknowledge(d.bot, chatID, chatRecordVoice, "Recording your podcast...")
+			respond(ack, "podcast", podcastMessage(d.words, d.audioCache, d.registry.TTS, chatID, d.translationLanguage,
+				time.Now()))
+			return
+		}
+
+		at, ok := parseReviewTime(argument)
+		if !ok {
+			msg := tgbotapi.NewMessage(chatID, "Please provide the time of your daily podcast, e.g. /podcast 07:30, "+
+				"or /podcast off.")
+			respond(d.bot, "podcast", msg)
+
+			return
+		}
+
+		respond(d.bot, "podcast", setPodcast(d.settingsStore, chatID, at))
+
+	case "/quiet":
+		if argument == "off" {
+			respond(d.bot, "quiet hours", setQuietHours(d.settingsStore, chatID, "", ""))
+			return
+		}
+
+		fields := strings.Fields(argument)
+		var start, end string
+		var startOK, endOK bool
+		if len(fields) == 2 {
+			start, startOK = parseReviewTime(fields[0])
+			end, endOK = parseReviewTime(fields[1])
+		}
+
+		if !startOK || !endOK || start == end {
+			msg := tgbotapi.NewMessage(chatID, "Please provide the start and the end of your quiet hours, e.g. "+
+				"/quiet 22:00 07:00, or /quiet off.")
+			respond(d.bot, "quiet hours", msg)
+
+			return
+		}
+
+		respond(d.bot, "quiet hours", setQuietHours(d.settingsStore, chatID, start, end))
+
+	case "/winback":
+		if argument != "on" && argument != "off" {
+			respond(d.bot, "win-back", tgbotapi.NewMessage(chatID, "Please choose /winback on or /winback off."))
+
+			return
+		}
+
+		respond(d.bot, "win-back", setWinBack(d.settingsStore, chatID, argument == "on"))
+
+	case "/cleanup":
+		minutes, err := strconv.Atoi(argument)
+		if argument == "off" {
+			minutes, err = 0, nil
+		}
+
+		if !group || err != nil || minutes < 0 {
+			msg := tgbotapi.NewMessage(chatID, "In groups, please provide the minutes after which the questions "+
+				"and the feedback are deleted, e.g. /cleanup 10, or /cleanup off.")
+			respond(d.bot, "cleanup", msg)
+
+			return
+		}
+
+		respond(d.bot, "cleanup", setCleanup(d.settingsStore, chatID, minutes))
+
+	case "/gradebook":
+		fields := strings.Fields(argument)
+		valid := len(fields) == 2 && (strings.HasPrefix(fields[0], "https://") ||
+			strings.HasPrefix(fields[0], "http://"))
+		if !group || !(valid || argument == "off") {
+			msg := tgbotapi.NewMessage(chatID, "In groups, please provide the LMS endpoint and the assignment the "+
+				"scores are posted for, e.g. /gradebook https://lms.example.com/kquiz week-1, or /gradebook off.")
+			respond(d.bot, "gradebook", msg)
+
+			return
+		}
+
+		if update.Message.From == nil || !isGroupAdmin(d.bot, chatID, update.Message.From.ID) {
+			msg := tgbotapi.NewMessage(chatID, "Only the admins of the group can set the gradebook.")
+			respond(d.bot, "gradebook", msg)
+
+			return
+		}
+
+		if argument == "off" {
+			respond(d.bot, "gradebook", setGradebook(d.settingsStore, d.bot, chatID, update.Message.From.ID, "", ""))
+		} else {
+			respond(d.bot, "gradebook", setGradebook(d.settingsStore, d.bot, chatID, update.Message.From.ID,
+				fields[0], fields[1]))
+		}
+
+	case "/webhook":
+		fields := strings.Fields(argument)
+		valid := len(fields) == 2 && fields[0] == "set" && strings.HasPrefix(fields[1], "https://")
+		if group || !(valid || argument == "off") {
+			msg := tgbotapi.NewMessage(chatID, "In a private chat, please provide the HTTPS endpoint receiving your "+
+				"events, e.g. /webhook set https://example.com/kquiz, or /webhook off.")
+			respond(d.bot, "webhook", msg)
+
+			return
+		}
+
+		if argument == "off" {
+			respond(d.bot, "webhook", setEventWebhook(d.settingsStore, chatID, ""))
+		} else {
+			respond(d.bot, "webhook", setEventWebhook(d.settingsStore, chatID, fields[1]))
+		}
+
+	case "/accessible":
+		if argument != "on" && argument != "off" {
+			msg := tgbotapi.NewMessage(chatID, "Please choose /accessible on or /accessible off.")
+			respond(d.bot, "accessible", msg)
+
+			return
+		}
+
+		respond(d.bot, "accessible", setAccessible(d.settingsStore, chatID, argument == "on"))
+
+	case "/warmup":
+		count, err := strconv.Atoi(argument)
+		if argument == "off" {
+			count, err = 0, nil
+		}
+
+		if err != nil || count < 0 || count > quiz.MaxWarmUp {
+			msg := tgbotapi.NewMessage(chatID, fmt.Sprintf("Please provide the number of well-known words starting "+
+				"the sessions, up to %d, e.g. /warmup 2, or /warmup off.", quiz.MaxWarmUp))
+			respond(d.bot, "warm-up", msg)
+
+			return
+		}
+
+		respond(d.bot, "warm-up", setWarmUp(d.settingsStore, chatID, count))
+
+	case "/recap":
+		if argument != "on" && argument != "off" {
+			respond(d.bot, "recap", tgbotapi.NewMessage(chatID, "Please choose /recap on or /recap off."))
+
+			return
+		}
+
+		respond(d.bot, "recap", setRecap(d.settingsStore, chatID, argument == "on"))
+
+	case "/largeprint":
+		if argument != "on" && argument != "off" {
+			msg := tgbotapi.NewMessage(chatID, "Please choose /largeprint on or /largeprint off.")
+			respond(d.bot, "large print", msg)
+
+			return
+		}
+
+		if argument == "on" && len(d.font) == 0 {
+			respond(d.bot, "large print", tgbotapi.NewMessage(chatID, "Large print is not available, no font is "+
+				"configured."))
+
+			return
+		}
+
+		respond(d.bot, "large print", setLargePrint(d.settingsStore, chatID, argument == "on"))
+
+	case "/reverse":
+		if argument != "on" && argument != "off" {
+			respond(d.bot, "reverse", tgbotapi.NewMessage(chatID, "Please choose /reverse on or /reverse off."))
+
+			return
+		}
+
+		respond(d.bot, "reverse", setReverse(d.settingsStore, chatID, argument == "on"))
+
+	case "/combo":
+		if argument != "on" && argument != "off" {
+			respond(d.bot, "combo", tgbotapi.NewMessage(chatID, "Please choose /combo on or /combo off."))
+
+			return
+		}
+
+		respond(d.bot, "combo", setCombos(d.settingsStore, chatID, argument == "on"))
+
+	case "/help":
+		respond(d.bot, "help", showHelp(d.featureFlags, d.admins[chatID], chatID))
+
+	case "/channel":
+		var tts providers.TextToSpeech
+		if d.featureFlags.Enabled(features.TTS) {
+			tts = d.registry.TTS
+		}
+
+		switch argument {
+		case "post":
+			respond(d.bot, "channel post", postWordOfTheDay(d.channelStore, d.words, tts, d.bot, chatID))
+		case "off":
+			respond(d.bot, "channel", setChannel(d.channelStore, chatID, nil, d.wordOfTheDayTime))
+		default:
+			channel, ok := parseChannel(argument, chatID)
+			if !ok {
+				msg := tgbotapi.NewMessage(chatID, "Please provide the channel and optionally your deck to publish, "+
+					"e.g. /channel @kquizdaily Basics, /channel post or /channel off.")
+				respond(d.bot, "channel", msg)
+
+				return
+			}
+
+			respond(d.bot, "channel", setChannel(d.channelStore, chatID, channel, d.wordOfTheDayTime))
+		}
+
+	case "/queue":
+		respond(d.bot, "queue", manageQueue(d.channelStore, d.words, chatID, argument))
+
+	case "/broadcast":
+		if len(argument) == 0 {
+			msg := tgbotapi.NewMessage(chatID, "Please provide the message, e.g. /broadcast Hi {name}, your "+
+				"{streak}-day streak is waiting and {due_count} words are due.")
+			respond(d.bot, "broadcast", msg)
+
+			return
+		}
+
+		broadcast(unbannedAudience{Audience: d.users, bans: d.bans}, d.activityStore, d.settingsStore, d.words, d.bot,
+			d.bulk, chatID, argument)
+
+	case "/users":
+		respond(d.bot, "users", countUsers(d.users, d.bans, chatID))
+
+	case "/ban", "/unban":
+		respond(d.bot, "ban", banChat(d.bans, d.access, d.admins, chatID, message, argument))
+
+	case "/experiments":
+		respond(d.bot, "experiments", experimentReport(d.analyticsStore, chatID))
+
+	case "/usage":
+		respond(d.bot, "usage", usageReport(d.usageStore, d.budget, chatID))
+
+	case "/cache":
+		if argument == "clear" || strings.HasPrefix(argument, "clear ") {
+			respond(d.bot, "clear cache", clearCache(d.responseCache, chatID,
+				strings.TrimSpace(strings.TrimPrefix(argument, "clear"))))
+			return
+		}
+
+		respond(d.bot, "cache stats", cacheStats(d.responseCache, chatID))
+
+	case "/backup":
+		if argument == "list" {
+			respond(d.bot, "list backups", listBackups(d.backups, chatID))
+			return
+		}
+
+		respond(d.bot, "backup", takeBackup(d.backups, d.bot, chatID))
+
+	case "/audio":
+		if argument == "purge" {
+			respond(d.bot, "purge audio cache", purgeAudioCache(d.audioCache, chatID))
+			return
+		}
+
+		respond(d.bot, "audio cache stats", audioCacheStats(d.audioCache, chatID, d.audioCacheSize))
+
+	case "/choice", "/quiz":
+		// A number of questions starts a quiz session answered by typing, e.g. /quiz 10 #food.
+		count, tags := parseTags(argument)
+		if message == "/quiz" && len(count) != 0 {
+			questions, err := strconv.Atoi(count)
+			if err != nil || questions < 1 || questions > quiz.MaxQuizQuestions {
+				msg := tgbotapi.NewMessage(chatID, fmt.Sprintf("Please provide the number of questions, up to %d, "+
+					"e.g. /quiz 10, optionally followed by a tag, e.g. /quiz 10 #food.", quiz.MaxQuizQuestions))
+				respond(d.bot, "quiz", msg)
+
+				return
+			}
+
+			tag := ""
+			if len(tags) != 0 {
+				tag = tags[0]
+			}
+
+			d.startQuiz(quizBot, chatID, questions, tag, group, update.Message.Chat.IsSuperGroup())
+			return
+		}
+
+		session := d.session(chatID)
+		session.Tag = parseTag(argument)
+		question := startChoice(d.words, d.quizEngine, d.recent, d.cooldown, d.bot, chatID, session)
+		if question != nil {
+			d.setPending(chatID, *question)
+		}
+
+	case "/skip":
+		d.skipQuestion(quizBot, chatID)
+
+	case "/hint":
+		respond(quizBot, "hint", d.giveHint(chatID))
+
+	case "/list":
+		respond(d.bot, "list words", listWords(d.words, chatID, parseListFilter(argument)))
+
+	case "/audit":
+		respond(d.bot, "audit", d.startAudit(chatID, userID))
+
+	case "/clear":
+		respond(d.bot, "clear words", clearWords(d.words, chatID))
+
+	default:
+		// We assume this is answer from the user for the randomised word. Answers may contain spaces, hence, let's
+		// check the whole text rather than the first word.
+		question, ok := d.pendingQuestion(chatID)
+		if !ok {
+			log.Printf("Unknown command [%s].", message)
+			break
+		}
+
+		if question.Kind == quiz.KindSpeaking {
+			respond(d.bot, "answer", tgbotapi.NewMessage(chatID, "Please answer with a voice message."))
+
+			break
+		}
+
+		settings, err := d.settingsStore.Settings(chatID)
+		if err != nil {
+			log.Printf("Failed to read settings, using the defaults. %s.\n", err)
+		}
+
+		session := d.session(chatID)
+		before := *session
+		reply, pending, revealed := answerQuestion(d.grader, chatID, question, update.Message.Text, session, settings)
+
+		// The reactions to the reveal grade the missed word, so its ID is kept.
+		revealID := 0
+		if revealed {
+			message, err := quizBot.Send(reply)
+			if err != nil {
+				log.Printf("Failed to respond to answer. %s.\n", err)
+			} else {
+				revealID = message.MessageID
+			}
+		} else {
+			respond(quizBot, "answer", reply)
+		}
+		d.recordStudy(chatID, update.Message.From)
+		d.transcribe(chatID, question, update.Message.Text, session.Correct > before.Correct)
+
+		if session.Answered > before.Answered {
+			d.countAnswer(chatID, session.Correct > before.Correct)
+		}
+		if session.StatusMessageID != 0 && session.Answered > before.Answered && update.Message.From != nil {
+			session.RecordPlayer(int64(update.Message.From.ID), update.Message.From.FirstName,
+				session.Correct > before.Correct, session.Score-before.Score)
+			updateGroupQuiz(d.bot, chatID, session, "")
+		}
+		if pending != nil {
+			d.setPending(chatID, *pending)
+			return
+		}
+
+		if session.QuizRunning() && session.Answered > before.Answered {
+			session.AddResult(question, session.Correct > before.Correct)
+		}
+
+		d.clearPending(chatID)
+		delete(d.reveals, chatID)
+		if len(question.Word) != 0 {
+			entry := d.recordReview(chatID, update.Message.From, question.Word, session.Correct > before.Correct)
+			if session.Answered > before.Answered && session.Correct == before.Correct {
+				session.Miss(question.Word)
+			}
+			if revealID != 0 {
+				d.reveals[chatID] = reveal{messageID: revealID, word: question.Word, before: entry}
+			}
+		}
+
+		d.continuePractice(quizBot, chatID, session)
+	}
+}
+
+// transientSender schedules the messages sent through it for deletion by the janitor after the delay.
+type transientSender struct {
+	sender
+	janitor *janitor.Janitor
+	delay   time.Duration
+}
+
+func (s transientSender) Send(c tgbotapi.Chattable) (tgbotapi.Message, error) {
+	message, err := s.sender.Send(c)
+	if err == nil && message.Chat != nil {
+		s.janitor.Schedule(message.Chat.ID, message.MessageID, time.Now().Add(s.delay))
+	}
+
+	return message, err
+}
@@ -0,0 +1,267 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+	"github.com/handracs2007/kquiz/audit"
+	"github.com/handracs2007/kquiz/telegram"
+	"github.com/handracs2007/kquiz/updates"
+	"strings"
+	"testing"
+)
+
+// fakeSender stands in for Telegram, it keeps the messages sent and posted in order and numbers them like Telegram
+// does.
+type fakeSender struct {
+	sent      []tgbotapi.Chattable
+	posted    []tgbotapi.Chattable
+	answers   []tgbotapi.CallbackConfig
+	messageID int
+	err       error
+}
+
+func (fake *fakeSender) Send(c tgbotapi.Chattable) (tgbotapi.Message, error) {
+	if fake.err != nil {
+		return tgbotapi.Message{}, fake.err
+	}
+
+	fake.sent = append(fake.sent, c)
+	fake.messageID++
+
+	return tgbotapi.Message{MessageID: fake.messageID}, nil
+}
+
+func (fake *fakeSender) Post(c tgbotapi.Chattable) {
+	fake.posted = append(fake.posted, c)
+}
+
+func (fake *fakeSender) AnswerCallbackQuery(config tgbotapi.CallbackConfig) (tgbotapi.APIResponse, error) {
+	fake.answers = append(fake.answers, config)
+	return tgbotapi.APIResponse{Ok: true}, nil
+}
+
+func (fake *fakeSender) GetFileDirectURL(fileID string) (string, error) {
+	return "", fmt.Errorf("no file %s", fileID)
+}
+
+func (fake *fakeSender) DeleteMessage(tgbotapi.DeleteMessageConfig) (tgbotapi.APIResponse, error) {
+	return tgbotapi.APIResponse{Ok: true}, nil
+}
+
+func (fake *fakeSender) GetChatMember(tgbotapi.ChatConfigWithUser) (tgbotapi.ChatMember, error) {
+	return tgbotapi.ChatMember{Status: "member"}, nil
+}
+
+func (fake *fakeSender) PinChatMessage(tgbotapi.PinChatMessageConfig) (tgbotapi.APIResponse, error) {
+	return tgbotapi.APIResponse{Ok: true}, nil
+}
+
+func (fake *fakeSender) UnpinChatMessage(tgbotapi.UnpinChatMessageConfig) (tgbotapi.APIResponse, error) {
+	return tgbotapi.APIResponse{Ok: true}, nil
+}
+
+// texts returns the texts of the messages sent so far.
+func (fake *fakeSender) texts() []string {
+	texts := make([]string, 0, len(fake.sent))
+	for _, c := range fake.sent {
+		texts = append(texts, replyText(c))
+	}
+
+	return texts
+}
+
+// replyText returns the text of the reply, or the caption of the files, as the user reads it.
+func replyText(c tgbotapi.Chattable) string {
+	switch reply := c.(type) {
+	case tgbotapi.MessageConfig:
+		return reply.Text
+	case tgbotapi.EditMessageTextConfig:
+		return reply.Text
+	case tgbotapi.PhotoConfig:
+		return reply.Caption
+	case tgbotapi.DocumentConfig:
+		return reply.Caption
+	case tgbotapi.VoiceConfig:
+		return reply.Caption
+	case tgbotapi.AudioConfig:
+		return reply.Caption
+	default:
+		return fmt.Sprintf("%T", c)
+	}
+}
+
+func TestRespond(t *testing.T) {
+	fake := &fakeSender{}
+	respond(fake, "test", tgbotapi.NewMessage(1, "first"), nil, tgbotapi.NewEditMessageText(1, 2, "second"))
+
+	got := fake.texts()
+	if len(got) != 2 || got[0] != "first" || got[1] != "second" {
+		t.Fatalf("respond() sent %q, want [first second]", got)
+	}
+
+	// The failures are logged, the replies after them are still sent.
+	fake.err = errors.New("blocked by the user")
+	respond(fake, "test", tgbotapi.NewMessage(1, "third"))
+	if len(fake.sent) != 2 {
+		t.Fatalf("respond() sent %d messages, want 2", len(fake.sent))
+	}
+}
+
+func TestPressButtonsOfAnotherMember(t *testing.T) {
+	fake := &fakeSender{}
+	d := newScenarioDispatcher(t, fake)
+
+	const groupID, owner, member = int64(-100123), 1001, 1002
+	d.cleanups[groupID] = &cleanup{messageID: 7, userID: owner, deleted: make(map[string]bool), issues: []audit.Issue{
+		{Kind: audit.Duplicate, Entries: []telegram.Entry{{Word: "학교"}, {Word: "학교 "}}},
+		{Kind: audit.Empty, Entries: []telegram.Entry{{Word: "버스"}}},
+	}}
+
+	press := func(userID int) updates.Update {
+		var incoming updates.Update
+		incoming.CallbackQuery = &tgbotapi.CallbackQuery{
+			ID:      fmt.Sprintf("press-%d", userID),
+			From:    &tgbotapi.User{ID: userID},
+			Message: &tgbotapi.Message{MessageID: 7, Chat: &tgbotapi.Chat{ID: groupID, Type: "supergroup"}},
+			Data:    auditCallbackPrefix + auditSkip,
+		}
+
+		return incoming
+	}
+
+	// The other members of the group are refused, the audit is left as it was.
+	d.dispatch(press(member))
+	if len(fake.answers) != 1 || len(fake.answers[0].Text) == 0 || d.cleanups[groupID].skipped != 0 {
+		t.Fatalf("the press of another member was not refused, answers %+v", fake.answers)
+	}
+
+	d.dispatch(press(owner))
+	if len(fake.answers) != 2 || len(fake.answers[1].Text) != 0 || d.cleanups[groupID].skipped != 1 {
+		t.Fatalf("the press of the owner was refused, answers %+v", fake.answers)
+	}
+
+	// The admins of the bot act on the flows of the others.
+	d.admins[member] = true
+	d.dispatch(press(member))
+	if _, ok := d.cleanups[groupID]; ok {
+		t.Fatal("the press of the admin was refused, the audit is still running")
+	}
+}
+
+// onboard registers the user of the scenarios and accepts the privacy notice.
+func onboard(t *testing.T, d *dispatcher, fake *fakeSender) {
+	d.dispatch(messageUpdate("/start"))
+
+	accept, ok := pressUpdate(fake, "Accept")
+	if !ok {
+		t.Fatalf("no privacy notice to accept in %q", fake.texts())
+	}
+	d.dispatch(accept)
+}
+
+func TestUsageReplies(t *testing.T) {
+	fake := &fakeSender{}
+	d := newScenarioDispatcher(t, fake)
+	onboard(t, d, fake)
+
+	for command, want := range map[string]string{
+		"/search":       "Please provide the Korean word.",
+		"/note 학교":      "Please provide the Korean word and your notes.",
+		"/silent maybe": "Please choose /silent on or /silent off.",
+		"/prefix !":     "Command prefixes are only used in groups.",
+		"/practice 0":   "Please provide the number of correct answers to reach, e.g. /practice 10.",
+	} {
+		sent := len(fake.sent)
+		d.dispatch(messageUpdate(command))
+
+		if got := fake.texts()[sent:]; len(got) != 1 || got[0] != want {
+			t.Errorf("%s replied %q, want %q", command, got, want)
+		}
+	}
+}
+
+func TestActOnAudit(t *testing.T) {
+	fake := &fakeSender{}
+	d := newScenarioDispatcher(t, fake)
+	onboard(t, d, fake)
+
+	if text := replyText(d.startAudit(scenarioChatID, scenarioUser.ID)); !strings.HasPrefix(text,
+		"Audit found no issue in your") {
+		t.Fatalf("startAudit() = %q, want no issue found", text)
+	}
+
+	d.cleanups[scenarioChatID] = &cleanup{messageID: 7, deleted: make(map[string]bool), issues: []audit.Issue{
+		{Kind: audit.Empty, Entries: []telegram.Entry{{Word: "버스"}}},
+	}}
+	press := func(messageID int, action string) *tgbotapi.CallbackQuery {
+		return &tgbotapi.CallbackQuery{
+			From:    scenarioUser,
+			Message: &tgbotapi.Message{MessageID: messageID, Chat: &tgbotapi.Chat{ID: scenarioChatID}},
+			Data:    auditCallbackPrefix + action,
+		}
+	}
+
+	// The buttons of the earlier audits are ignored.
+	if reply := d.actOnAudit(press(6, auditSkip)); reply != nil {
+		t.Fatalf("actOnAudit() of a stale button = %q, want nil", replyText(reply))
+	}
+
+	edit, ok := d.actOnAudit(press(7, auditSkip)).(tgbotapi.EditMessageTextConfig)
+	if !ok || edit.MessageID != 7 || !strings.Contains(edit.Text, "1 skipped") {
+		t.Fatalf("actOnAudit() = %+v, want the cleanup edited", edit)
+	}
+	if _, ok := d.cleanups[scenarioChatID]; ok {
+		t.Fatal("the audit is still running once all its issues are skipped")
+	}
+}
+
+func TestResolveConflict(t *testing.T) {
+	fake := &fakeSender{}
+	d := newScenarioDispatcher(t, fake)
+	onboard(t, d, fake)
+	d.dispatch(messageUpdate("/add 학교 school"))
+
+	d.conflicts[scenarioChatID] = &conflictReview{messageID: 7, conflicts: []translationConflict{
+		{word: "학교", mine: "school", theirs: "schoolhouse"},
+	}}
+	reply := d.resolveConflict(&tgbotapi.CallbackQuery{
+		From:    scenarioUser,
+		Message: &tgbotapi.Message{MessageID: 7, Chat: &tgbotapi.Chat{ID: scenarioChatID}},
+		Data:    conflictCallbackPrefix + conflictTheirs,
+	})
+
+	want := "Translation conflicts resolved, 1 replaced, 0 merged and 0 kept."
+	if text := replyText(reply); text != want {
+		t.Fatalf("resolveConflict() = %q, want %q", text, want)
+	}
+
+	entry, err := d.words.SearchEntry(scenarioChatID, "학교")
+	if err != nil || entry.Translation != "schoolhouse" {
+		t.Fatalf("학교 translates to %+v, %v, want schoolhouse", entry, err)
+	}
+}
+
+func TestNoteFromRecap(t *testing.T) {
+	fake := &fakeSender{}
+	d := newScenarioDispatcher(t, fake)
+	onboard(t, d, fake)
+	d.dispatch(messageUpdate("/add 학교 school"))
+
+	prompt := notePrompt{messageID: 8, word: "학교"}
+	if reply := d.noteFromRecap(scenarioChatID, prompt, " "); reply != nil {
+		t.Fatalf("noteFromRecap() of blank notes = %q, want nil", replyText(reply))
+	}
+
+	d.recaps[scenarioChatID] = &recap{messageID: 7, heading: "Session over.", tagged: make(map[string]bool),
+		noted: make(map[string]bool), missed: []telegram.Entry{{Word: "학교"}}}
+	edit, ok := d.noteFromRecap(scenarioChatID, prompt, "학 + 교").(tgbotapi.EditMessageTextConfig)
+	if !ok || edit.MessageID != 7 || !d.recaps[scenarioChatID].noted["학교"] {
+		t.Fatalf("noteFromRecap() = %+v, want the recap edited", edit)
+	}
+
+	want := fmt.Sprintf("Set notes failed. %s.", telegram.ErrWordNotFound)
+	if text := replyText(d.noteFromRecap(scenarioChatID, notePrompt{word: "버스"}, "bus")); text != want {
+		t.Fatalf("noteFromRecap() of a missing word = %q, want %q", text, want)
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+	"github.com/handracs2007/kquiz/events"
+	"github.com/handracs2007/kquiz/gradebook"
+	"github.com/handracs2007/kquiz/quiz"
+	"github.com/handracs2007/kquiz/telegram"
+	"log"
+)
+
+// setEventWebhook saves the URL receiving the events of the user, empty to stop them, and returns the secret signing
+// them. The secret is kept when the URL changes, so that the endpoint only needs it once. The host of the URL must
+// only resolve to public addresses.
+func setEventWebhook(manager telegram.SettingsManager, chatID int64, url string) tgbotapi.Chattable {
+	var msg tgbotapi.MessageConfig
+	if len(url) != 0 {
+		err := events.CheckURL(url)
+		if err != nil {
+			return tgbotapi.NewMessage(chatID, fmt.Sprintf("Set webhook failed. %s.", err))
+		}
+	}
+
+	settings, err := manager.Settings(chatID)
+	if err == nil && len(url) != 0 && len(settings.WebhookSecret) == 0 {
+		random := make([]byte, 32)
+		_, err = rand.Read(random)
+		if err != nil {
+			log.Printf("Failed to generate webhook secret. %s.\n", err)
+			err = telegram.ErrDatabaseError
+		}
+
+		settings.WebhookSecret = hex.EncodeToString(random)
+	}
+	if err == nil {
+		settings.WebhookURL = url
+		err = manager.SaveSettings(chatID, settings)
+	}
+
+	if err != nil {
+		msg = tgbotapi.NewMessage(chatID, fmt.Sprintf("Save settings failed. %s.", err))
+	} else if len(url) != 0 {
+		msg = tgbotapi.NewMessage(chatID, fmt.Sprintf("Your events (%s, %s and %s) are posted as JSON to %s, signed "+
+			"in the %s header with the secret:\n%s", events.WordAdded, events.QuizCompleted, events.StreakBroken, url,
+			gradebook.SignatureHeader, settings.WebhookSecret))
+	} else {
+		msg = tgbotapi.NewMessage(chatID, "Your events are no longer posted.")
+	}
+
+	return msg
+}
+
+// emitCompleted emits the event of the quiz of the kind, e.g. review, completed by the user.
+func (d *dispatcher) emitCompleted(chatID int64, kind string, session *quiz.Session) {
+	d.events.Emit(chatID, events.QuizCompleted, map[string]interface{}{"kind": kind, "answered": session.Answered,
+		"correct": session.Correct, "score": session.Score})
+}
@@ -0,0 +1,248 @@
+// Package events posts the events of the users, e.g. a word added, to the webhooks they register, so that they can wire
+// the bot into their own tools.
+package events
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"syscall"
+	"time"
+
+	"github.com/handracs2007/kquiz/gradebook"
+	"github.com/handracs2007/kquiz/storage"
+	"github.com/handracs2007/kquiz/telegram"
+)
+
+// The types of the events.
+const (
+	WordAdded     = "word_added"
+	QuizCompleted = "quiz_completed"
+	StreakBroken  = "streak_broken"
+)
+
+// ErrInvalidURL indicates that the webhook is not an HTTPS URL.
+var ErrInvalidURL = errors.New("invalid webhook URL")
+
+// ErrUnknownHost indicates that the host of the webhook could not be resolved.
+var ErrUnknownHost = errors.New("unknown webhook host")
+
+// ErrPrivateHost indicates that the host of the webhook resolves to a loopback, private or link-local address, which
+// the events are never posted to.
+var ErrPrivateHost = errors.New("webhook host not public")
+
+// workers is the number of events posted at once, queueSize the number of events waiting before the next ones are
+// dropped, e.g. during a large import.
+const (
+	workers   = 4
+	queueSize = 1024
+)
+
+// resolveTimeout bounds how long the host of a webhook is resolved for when it is set.
+const resolveTimeout = 5 * time.Second
+
+// privateNetworks are the networks the events are never posted to: loopback, private, shared, link-local,
+// unspecified and multicast addresses.
+var privateNetworks = parseNetworks("0.0.0.0/8", "10.0.0.0/8", "100.64.0.0/10", "127.0.0.0/8", "169.254.0.0/16",
+	"172.16.0.0/12", "192.168.0.0/16", "224.0.0.0/4", "::/128", "::1/128", "fc00::/7", "fe80::/10", "ff00::/8")
+
+// The addresses are checked again as they are dialed, so that a host resolving differently once set, or a redirect,
+// does not reach the private networks.
+var httpClient = &http.Client{
+	Timeout: 10 * time.Second,
+	Transport: &http.Transport{
+		DialContext:         (&net.Dialer{Timeout: 10 * time.Second, Control: checkDial}).DialContext,
+		TLSHandshakeTimeout: 10 * time.Second,
+	},
+}
+
+// parseNetworks parses the networks in the CIDR notation.
+func parseNetworks(cidrs ...string) []*net.IPNet {
+	networks := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+
+		networks = append(networks, network)
+	}
+
+	return networks
+}
+
+// isPublic reports whether the events may be posted to the address.
+func isPublic(ip net.IP) bool {
+	for _, network := range privateNetworks {
+		if network.Contains(ip) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// checkDial refuses to connect to the addresses the events are never posted to.
+func checkDial(network string, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+
+	if ip := net.ParseIP(host); ip == nil || !isPublic(ip) {
+		return ErrPrivateHost
+	}
+
+	return nil
+}
+
+// CheckURL checks that the webhook is an HTTPS URL whose host only resolves to public addresses, before it is saved.
+// This function returns the following errors:
+//  - ErrInvalidURL
+//  - ErrUnknownHost
+//  - ErrPrivateHost
+func CheckURL(rawURL string) error {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || parsed.Scheme != "https" || len(parsed.Hostname()) == 0 {
+		return ErrInvalidURL
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), resolveTimeout)
+	defer cancel()
+
+	addresses, err := net.DefaultResolver.LookupIPAddr(ctx, parsed.Hostname())
+	if err != nil || len(addresses) == 0 {
+		return ErrUnknownHost
+	}
+
+	for _, address := range addresses {
+		if !isPublic(address.IP) {
+			return ErrPrivateHost
+		}
+	}
+
+	return nil
+}
+
+// Event represents something the user did, posted as JSON to the webhook of the user. The data depends on the type.
+type Event struct {
+	Type   string                 `json:"type"`
+	ChatID int64                  `json:"chat_id"`
+	At     time.Time              `json:"at"`
+	Data   map[string]interface{} `json:"data,omitempty"`
+}
+
+// delivery is an event waiting to be posted to a webhook.
+type delivery struct {
+	url    string
+	secret []byte
+	event  Event
+}
+
+// Emitter posts the events to the webhooks of the users, signed with their secret like the scores of the gradebook,
+// see gradebook.Sign. A few workers post them from a bounded queue.
+type Emitter struct {
+	manager telegram.SettingsManager
+	queue   chan delivery
+}
+
+// NewEmitter creates a new instance of Emitter, starting the workers posting the events.
+func NewEmitter(manager telegram.SettingsManager) Emitter {
+	emitter := Emitter{manager: manager, queue: make(chan delivery, queueSize)}
+	for i := 0; i < workers; i++ {
+		go func() {
+			for next := range emitter.queue {
+				post(next.url, next.secret, next.event)
+			}
+		}()
+	}
+
+	return emitter
+}
+
+// Emit queues the event of the user identified by the chat ID for the webhook of the user, if any, so that a slow
+// endpoint does not hold the user up. The event is dropped when the queue is full. Failures are only logged, the events
+// are not retried.
+func (emitter Emitter) Emit(chatID int64, eventType string, data map[string]interface{}) {
+	settings, err := emitter.manager.Settings(chatID)
+	if err != nil || len(settings.WebhookURL) == 0 {
+		return
+	}
+
+	event := Event{Type: eventType, ChatID: chatID, At: time.Now(), Data: data}
+	select {
+	case emitter.queue <- delivery{url: settings.WebhookURL, secret: []byte(settings.WebhookSecret), event: event}:
+	default:
+		log.Printf("Failed to queue %s event of %d. The queue is full.\n", eventType, chatID)
+	}
+}
+
+// post posts the event as JSON to the URL, signed with the secret.
+func post(url string, secret []byte, event Event) {
+	body, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("Failed to encode event. %s.\n", err)
+		return
+	}
+
+	request, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		log.Printf("Failed to create event request. %s.\n", err)
+		return
+	}
+
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set(gradebook.SignatureHeader, gradebook.Sign(secret, body))
+
+	response, err := httpClient.Do(request)
+	if err != nil {
+		log.Printf("Failed to post event. %s.\n", err)
+		return
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode/100 != 2 {
+		log.Printf("Failed to post event. The webhook responded %s.\n", response.Status)
+	}
+}
+
+// Adder emits an event for every word added through the underlying adder.
+type Adder struct {
+	adder   storage.Adder
+	emitter Emitter
+}
+
+// NewAdder creates a new instance of Adder
+func NewAdder(adder storage.Adder, emitter Emitter) Adder {
+	return Adder{adder: adder, emitter: emitter}
+}
+
+// Add adds the word and its translation and emits the event.
+// This function returns the following errors:
+//  - the errors of the underlying adder
+func (adder Adder) Add(chatID int64, word string, translation string) error {
+	err := adder.adder.Add(chatID, word, translation)
+	if err == nil {
+		adder.emitter.Emit(chatID, WordAdded, map[string]interface{}{"word": word, "translation": translation})
+	}
+
+	return err
+}
+
+// AddEntry adds the word with its entry and emits the event.
+// This function returns the following errors:
+//  - the errors of the underlying adder
+func (adder Adder) AddEntry(chatID int64, word string, entry telegram.WordEntry) error {
+	err := adder.adder.AddEntry(chatID, word, entry)
+	if err == nil {
+		adder.emitter.Emit(chatID, WordAdded, map[string]interface{}{"word": word,
+			"translation": entry.Translation, "kind": entry.EntryKind(), "tags": entry.Tags, "source": entry.Source})
+	}
+
+	return err
+}
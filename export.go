@@ -0,0 +1,185 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+	"github.com/handracs2007/kquiz/storage"
+	"github.com/handracs2007/kquiz/telegram"
+	"github.com/jung-kurt/gofpdf"
+	"log"
+	"strings"
+)
+
+// The formats of the exported files.
+const (
+	exportCSV  = "csv"
+	exportAnki = "anki"
+	exportPDF  = "pdf"
+)
+
+// The layout of the worksheet, in millimetres on A4 paper.
+const (
+	worksheetMargin     = 15
+	worksheetRowHeight  = 9
+	worksheetFontFamily = "worksheet"
+)
+
+// allEntries lists the vocabulary and the grammar patterns of the user.
+func allEntries(lister storage.Lister, chatID int64) ([]telegram.Entry, error) {
+	entries := make([]telegram.Entry, 0)
+	for _, kind := range []string{telegram.KindVocabulary, telegram.KindGrammar} {
+		kindEntries, err := lister.ListEntries(chatID, kind)
+		if err == telegram.ErrWordNotFound {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		entries = append(entries, kindEntries...)
+	}
+
+	if len(entries) == 0 {
+		return nil, telegram.ErrWordNotFound
+	}
+
+	return entries, nil
+}
+
+// encodeCSV writes the entries as CSV with a header, the word and its translation first so that the file can be
+// imported again.
+func encodeCSV(entries []telegram.Entry) ([]byte, error) {
+	var buffer bytes.Buffer
+	writer := csv.NewWriter(&buffer)
+
+	err := writer.Write([]string{"word", "translation", "example", "deck", "kind", "notes", "tags"})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, entry := range entries {
+		err = writer.Write([]string{entry.Word, entry.Translation, entry.Example, entry.Deck, entry.EntryKind(),
+			entry.Notes, strings.Join(entry.Tags, " ")})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	writer.Flush()
+	return buffer.Bytes(), writer.Error()
+}
+
+// ankiField removes the tabs and line breaks separating the fields and the notes of Anki.
+func ankiField(value string) string {
+	return strings.Join(strings.Fields(value), " ")
+}
+
+// encodeAnki writes the entries as the tab separated notes Anki imports, the word on the front, the translation and
+// the example on the back, and the deck as a tag.
+func encodeAnki(entries []telegram.Entry) []byte {
+	lines := []string{"#separator:tab", "#html:false", "#tags column:4"}
+	for _, entry := range entries {
+		tag := strings.Join(strings.Fields(entry.Deck), "_")
+		lines = append(lines, strings.Join([]string{ankiField(entry.Word), ankiField(entry.Translation),
+			ankiField(entry.Example), tag}, "\t"))
+	}
+
+	return []byte(strings.Join(lines, "\n") + "\n")
+}
+
+// encodePDF renders the entries as a printable worksheet, a two-column table of the words and their translations, with
+// the font given, which must have Hangul. The table header is repeated on every page.
+func encodePDF(entries []telegram.Entry, font []byte) ([]byte, error) {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetMargins(worksheetMargin, worksheetMargin, worksheetMargin)
+	pdf.SetAutoPageBreak(false, worksheetMargin)
+	pdf.AddUTF8FontFromBytes(worksheetFontFamily, "", font)
+	if pdf.Err() {
+		return nil, pdf.Error()
+	}
+
+	width, height := pdf.GetPageSize()
+	column := (width - 2*worksheetMargin) / 2
+	header := func() {
+		pdf.AddPage()
+		pdf.SetFont(worksheetFontFamily, "", 16)
+		pdf.CellFormat(0, 12, "Kquiz worksheet", "", 1, "L", false, 0, "")
+		pdf.SetFont(worksheetFontFamily, "", 12)
+		pdf.SetFillColor(230, 230, 230)
+		pdf.CellFormat(column, worksheetRowHeight, "Word", "1", 0, "L", true, 0, "")
+		pdf.CellFormat(column, worksheetRowHeight, "Translation", "1", 1, "L", true, 0, "")
+	}
+
+	header()
+	for _, entry := range entries {
+		if pdf.GetY()+worksheetRowHeight > height-worksheetMargin {
+			header()
+		}
+
+		// The long words and translations are cut to the width of their column.
+		word := pdf.SplitText(ankiField(entry.Word), column-2)
+		translation := pdf.SplitText(ankiField(entry.Translation), column-2)
+		if len(word) == 0 || len(translation) == 0 {
+			continue
+		}
+
+		pdf.CellFormat(column, worksheetRowHeight, word[0], "1", 0, "L", false, 0, "")
+		pdf.CellFormat(column, worksheetRowHeight, translation[0], "1", 1, "L", false, 0, "")
+	}
+
+	var buffer bytes.Buffer
+	err := pdf.Output(&buffer)
+	if err != nil {
+		return nil, err
+	}
+
+	return buffer.Bytes(), nil
+}
+
+// exportFile returns the words of the user as a document, as CSV, as the notes Anki imports or as a printable
+// worksheet rendered with the font given, nil when the worksheets are not available.
+func exportFile(lister storage.Lister, chatID int64, format string, worksheetFont []byte) tgbotapi.Chattable {
+	failed := func(err error) tgbotapi.Chattable {
+		return tgbotapi.NewMessage(chatID, fmt.Sprintf("Export failed. %s.", err))
+	}
+
+	if format == exportPDF && len(worksheetFont) == 0 {
+		return tgbotapi.NewMessage(chatID, "Printable worksheets are not available, please try /export csv instead.")
+	}
+
+	entries, err := allEntries(lister, chatID)
+	if err != nil {
+		return failed(err)
+	}
+
+	var file tgbotapi.FileBytes
+	switch format {
+	case exportAnki:
+		file = tgbotapi.FileBytes{Name: "kquiz-anki.txt", Bytes: encodeAnki(entries)}
+
+	case exportPDF:
+		data, err := encodePDF(entries, worksheetFont)
+		if err != nil {
+			log.Printf("Failed to render worksheet. %s.\n", err)
+			return failed(telegram.ErrDatabaseError)
+		}
+
+		file = tgbotapi.FileBytes{Name: "kquiz-worksheet.pdf", Bytes: data}
+
+	default:
+		data, err := encodeCSV(entries)
+		if err != nil {
+			log.Printf("Failed to encode words. %s.\n", err)
+			return failed(telegram.ErrDatabaseError)
+		}
+
+		file = tgbotapi.FileBytes{Name: "kquiz.csv", Bytes: data}
+	}
+
+	document := tgbotapi.NewDocumentUpload(chatID, file)
+	document.Caption = fmt.Sprintf("Your %d words.", len(entries))
+
+	return document
+}
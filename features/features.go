@@ -0,0 +1,55 @@
+package features
+
+import (
+	"strings"
+
+	"github.com/handracs2007/kquiz/providers"
+)
+
+// Names of the optional features depending on external services.
+const (
+	// TTS enables the features playing audio, e.g. dictation.
+	TTS = "tts"
+	// STT enables the features listening to voice messages, e.g. speaking practice.
+	STT = "stt"
+	// Translation enables the automatic translation of the added words.
+	Translation = "translation"
+	// Dictionary enables the dictionary lookup.
+	Dictionary = "dictionary"
+	// RemoteImport enables importing words from websites such as Google Sheets or Quizlet.
+	RemoteImport = "remote-import"
+	// Publishing enables publishing the word of the day to a public channel and its feed.
+	Publishing = "publishing"
+)
+
+// Flags holds whether each optional feature is enabled. Features not depending on external services are always
+// enabled and are not part of the flags.
+type Flags map[string]bool
+
+// New creates the flags from the available providers. In offline mode, every feature depending on an external service
+// other than Telegram is disabled. The disabled features are disabled even when their provider is available.
+func New(registry *providers.Registry, offline bool, disabled []string) Flags {
+	flags := Flags{
+		TTS:          !offline && providers.Available(registry.TTS),
+		STT:          !offline && providers.Available(registry.STT),
+		Translation:  !offline && providers.Available(registry.Translator),
+		Dictionary:   !offline && providers.Available(registry.Dictionary),
+		RemoteImport: !offline,
+		Publishing:   true,
+	}
+
+	for _, name := range disabled {
+		name = strings.TrimSpace(name)
+		if _, ok := flags[name]; ok {
+			flags[name] = false
+		}
+	}
+
+	return flags
+}
+
+// Enabled reports whether the feature is enabled. An empty name, used by the features that are always available, is
+// always enabled.
+func (flags Flags) Enabled(name string) bool {
+	return len(name) == 0 || flags[name]
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+	"github.com/handracs2007/kquiz/providers"
+	"github.com/handracs2007/kquiz/storage"
+	"strings"
+	"unicode"
+)
+
+// forwardedWordsSize is the most words listed from a forwarded message.
+const forwardedWordsSize = 30
+
+// newWords returns the dictionary forms of the meaningful words of the text the user has not added yet, in their
+// order, each once. The words the user added conjugated, e.g. 먹어요 for 먹다, count as added.
+func newWords(analyzer providers.Analyzer, checker storage.Checker, chatID int64, text string) ([]string, error) {
+	seen := make(map[string]bool)
+	words := make([]string, 0)
+
+	// The words are analyzed one at a time to tell which of them the user added as they are.
+	for _, field := range strings.Fields(text) {
+		tokens, err := analyzer.Analyze(field)
+		if err != nil {
+			return nil, err
+		}
+
+		added := checker.IsAdded(chatID, strings.TrimFunc(field, func(r rune) bool {
+			return !unicode.Is(unicode.Hangul, r)
+		}))
+		for _, token := range tokens {
+			if !token.Content() || seen[token.Lemma] {
+				continue
+			}
+
+			seen[token.Lemma] = true
+			if !added && !checker.IsAdded(chatID, token.Lemma) {
+				words = append(words, token.Lemma)
+			}
+		}
+	}
+
+	return words, nil
+}
+
+// extractWords lists the words of the text forwarded by the user that the user has not added yet, e.g. from a
+// Korean channel, to pick the ones to learn.
+func extractWords(analyzer providers.Analyzer, checker storage.Checker, chatID int64,
+	text string) tgbotapi.Chattable {
+	var msg tgbotapi.MessageConfig
+	words, err := newWords(analyzer, checker, chatID, text)
+	if err != nil {
+		msg = tgbotapi.NewMessage(chatID, fmt.Sprintf("Extract words failed. %s.", err))
+	} else if len(words) == 0 {
+		msg = tgbotapi.NewMessage(chatID, "No new Korean word in the forwarded message.")
+	} else {
+		more := ""
+		if len(words) > forwardedWordsSize {
+			more = fmt.Sprintf(" and %d more", len(words)-forwardedWordsSize)
+			words = words[:forwardedWordsSize]
+		}
+
+		msg = tgbotapi.NewMessage(chatID, fmt.Sprintf("New words in the forwarded message: %s%s.\n\n"+
+			"Add the ones to learn with /add <word> <translation>.", strings.Join(words, ", "), more))
+	}
+
+	return msg
+}
@@ -0,0 +1,173 @@
+// Package gradebook reports the class assignments the students complete, so that the teachers can pull them into their
+// gradebook.
+package gradebook
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ErrReportFailed indicates that the completion could not be reported.
+var ErrReportFailed = errors.New("report failed")
+
+var httpClient = &http.Client{Timeout: 30 * time.Second}
+
+// Completion represents a student completing the assignment of a class template, i.e. reviewing every word of its
+// deck.
+type Completion struct {
+	Template    string    `json:"template"`
+	Teacher     int64     `json:"teacher"`
+	Student     int64     `json:"student"`
+	Name        string    `json:"name"`
+	Deck        string    `json:"deck"`
+	Words       int       `json:"words"`
+	CompletedAt time.Time `json:"completed_at"`
+}
+
+// Reporter defines operations to be fulfilled by the implementation that has capability to report the completions.
+type Reporter interface {
+	Report(completion Completion) error
+}
+
+// Webhook posts the completions as JSON to a URL.
+type Webhook struct {
+	url string
+}
+
+// NewWebhook creates a new instance of Webhook
+func NewWebhook(url string) Webhook {
+	return Webhook{url: url}
+}
+
+// Report posts the completion to the webhook.
+// This function returns the following errors:
+//  - ErrReportFailed
+func (webhook Webhook) Report(completion Completion) error {
+	return post(webhook.url, nil, completion)
+}
+
+// post posts the payload as JSON to the URL, signed with the secret unless it is empty.
+func post(url string, secret []byte, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		log.Printf("Failed to encode report. %s.\n", err)
+		return ErrReportFailed
+	}
+
+	request, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		log.Printf("Failed to create report request. %s.\n", err)
+		return ErrReportFailed
+	}
+
+	request.Header.Set("Content-Type", "application/json")
+	if len(secret) != 0 {
+		request.Header.Set(SignatureHeader, Sign(secret, body))
+	}
+
+	response, err := httpClient.Do(request)
+	if err != nil {
+		log.Printf("Failed to post report. %s.\n", err)
+		return ErrReportFailed
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode/100 != 2 {
+		log.Printf("Failed to post report. The endpoint responded %s.\n", response.Status)
+		return ErrReportFailed
+	}
+
+	return nil
+}
+
+// csvHeader is the first row of the CSV files.
+var csvHeader = []string{"template", "teacher", "student", "name", "deck", "words", "completed_at"}
+
+// CSV appends the completions to a CSV file, starting it with a header when it is new.
+type CSV struct {
+	path  string
+	mutex *sync.Mutex
+}
+
+// NewCSV creates a new instance of CSV
+func NewCSV(path string) CSV {
+	return CSV{path: path, mutex: &sync.Mutex{}}
+}
+
+// Report appends the completion to the CSV file.
+// This function returns the following errors:
+//  - ErrReportFailed
+func (file CSV) Report(completion Completion) error {
+	file.mutex.Lock()
+	defer file.mutex.Unlock()
+
+	err := file.append(completion)
+	if err != nil {
+		log.Printf("Failed to write completion to %s. %s.\n", file.path, err)
+		return ErrReportFailed
+	}
+
+	return nil
+}
+
+func (file CSV) append(completion Completion) error {
+	out, err := os.OpenFile(file.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	info, err := out.Stat()
+	if err != nil {
+		return err
+	}
+
+	writer := csv.NewWriter(out)
+	if info.Size() == 0 {
+		err = writer.Write(csvHeader)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = writer.Write([]string{
+		completion.Template,
+		strconv.FormatInt(completion.Teacher, 10),
+		strconv.FormatInt(completion.Student, 10),
+		completion.Name,
+		completion.Deck,
+		strconv.Itoa(completion.Words),
+		completion.CompletedAt.UTC().Format(time.RFC3339),
+	})
+	if err != nil {
+		return err
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// Reporters reports the completions to every reporter.
+type Reporters []Reporter
+
+// Report reports the completion to every reporter, even when some of them fail.
+// This function returns the following errors:
+//  - ErrReportFailed
+func (reporters Reporters) Report(completion Completion) error {
+	var err error
+	for _, reporter := range reporters {
+		if reporter.Report(completion) != nil {
+			err = ErrReportFailed
+		}
+	}
+
+	return err
+}
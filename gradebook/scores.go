@@ -0,0 +1,42 @@
+package gradebook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+)
+
+// SignatureHeader is the header holding the signature of the scores posted to the LMS, see Sign. The LMS computes the
+// signature of the body with the secret of the group and compares it to tell the genuine posts from forged ones.
+const SignatureHeader = "X-Kquiz-Signature"
+
+// Score represents the result of a member of the group in a quiz.
+type Score struct {
+	UserID  int64  `json:"user_id"`
+	Name    string `json:"name"`
+	Correct int    `json:"correct"`
+	Score   int    `json:"score"`
+}
+
+// ScoreReport represents the scores of the quiz of a group, posted to the LMS endpoint of its assignment.
+type ScoreReport struct {
+	Assignment string    `json:"assignment"`
+	ChatID     int64     `json:"chat_id"`
+	Scores     []Score   `json:"scores"`
+	FinishedAt time.Time `json:"finished_at"`
+}
+
+// Sign returns the signature of the body, its HMAC-SHA256 keyed with the secret in hex prefixed with sha256=.
+func Sign(secret []byte, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	_, _ = mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// PostScores posts the scores to the LMS endpoint at the URL, signed with the secret.
+// This function returns the following errors:
+//  - ErrReportFailed
+func PostScores(url string, secret string, report ScoreReport) error {
+	return post(url, []byte(secret), report)
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+	"github.com/handracs2007/kquiz/gradebook"
+	"github.com/handracs2007/kquiz/quiz"
+	"github.com/handracs2007/kquiz/telegram"
+	"log"
+	"sort"
+	"time"
+)
+
+// canPin reports whether the bot may pin messages in the group. In basic groups, every admin may pin messages.
+func canPin(botAPI sender, chatID int64, botID int, superGroup bool) bool {
+	member, err := botAPI.GetChatMember(tgbotapi.ChatConfigWithUser{ChatID: chatID, UserID: botID})
+	if err != nil {
+		log.Printf("Failed to get the permissions of the bot. %s.\n", err)
+		return false
+	}
+
+	return member.IsCreator() || (member.IsAdministrator() && (member.CanPinMessages || !superGroup))
+}
+
+// groupStatus describes the quiz of the group with its live standings.
+func groupStatus(session *quiz.Session, over string) string {
+	if len(over) != 0 {
+		return fmt.Sprintf("Group practice over. %s\n\n%s", over, session.Standings())
+	}
+
+	return fmt.Sprintf("Group practice, %d of %d correct answers.\n\n%s", session.Correct, session.Goal,
+		session.Standings())
+}
+
+// startGroupQuiz posts the status message of the quiz of the group and pins it when the bot is allowed to.
+func startGroupQuiz(botAPI sender, chatID int64, botID int, superGroup bool, session *quiz.Session) {
+	message, err := botAPI.Send(tgbotapi.NewMessage(chatID, groupStatus(session, "")))
+	if err != nil {
+		log.Printf("Failed to send group quiz status. %s.\n", err)
+		return
+	}
+
+	session.StatusMessageID = message.MessageID
+
+	if !canPin(botAPI, chatID, botID, superGroup) {
+		respond(botAPI, "group quiz", tgbotapi.NewMessage(chatID, "Make me an admin allowed to pin messages to keep "+
+			"the standings pinned."))
+		return
+	}
+
+	_, err = botAPI.PinChatMessage(tgbotapi.PinChatMessageConfig{
+		ChatID:              chatID,
+		MessageID:           message.MessageID,
+		DisableNotification: true,
+	})
+	if err != nil {
+		log.Printf("Failed to pin group quiz status. %s.\n", err)
+	}
+}
+
+// updateGroupQuiz edits the status message of the quiz of the group with the live standings. A finished quiz is
+// unpinned.
+func updateGroupQuiz(botAPI sender, chatID int64, session *quiz.Session, over string) {
+	if session.StatusMessageID == 0 {
+		return
+	}
+
+	_, err := botAPI.Send(tgbotapi.NewEditMessageText(chatID, session.StatusMessageID, groupStatus(session, over)))
+	if err != nil {
+		log.Printf("Failed to update group quiz status. %s.\n", err)
+	}
+
+	if len(over) == 0 {
+		return
+	}
+
+	session.StatusMessageID = 0
+
+	_, err = botAPI.UnpinChatMessage(tgbotapi.UnpinChatMessageConfig{ChatID: chatID})
+	if err != nil {
+		log.Printf("Failed to unpin group quiz status. %s.\n", err)
+	}
+}
+
+// isGroupAdmin reports whether the user is an admin of the group.
+func isGroupAdmin(botAPI sender, chatID int64, userID int) bool {
+	member, err := botAPI.GetChatMember(tgbotapi.ChatConfigWithUser{ChatID: chatID, UserID: userID})
+	if err != nil {
+		log.Printf("Failed to get the permissions of the user. %s.\n", err)
+		return false
+	}
+
+	return member.IsCreator() || member.IsAdministrator()
+}
+
+// setGradebook sets the LMS endpoint receiving the scores of the quizzes of the group for the assignment, or turns the
+// posts off with an empty URL. The secret signing the posts is sent to the admin in private through the sender, never
+// in the group, the reply to the group is returned.
+func setGradebook(manager telegram.SettingsManager, botAPI sender, chatID int64, userID int, url string,
+	assignment string) tgbotapi.Chattable {
+	var msg tgbotapi.MessageConfig
+	settings, err := manager.Settings(chatID)
+	if err != nil {
+		msg = tgbotapi.NewMessage(chatID, fmt.Sprintf("Save settings failed. %s.", err))
+	} else if len(url) == 0 {
+		settings.GradebookURL = ""
+		settings.GradebookAssignment = ""
+		settings.GradebookSecret = ""
+
+		err = manager.SaveSettings(chatID, settings)
+		if err != nil {
+			msg = tgbotapi.NewMessage(chatID, fmt.Sprintf("Save settings failed. %s.", err))
+		} else {
+			msg = tgbotapi.NewMessage(chatID, "The scores are no longer posted to the gradebook.")
+		}
+	} else {
+		msg = configureGradebook(manager, botAPI, chatID, userID, settings, url, assignment)
+	}
+
+	return msg
+}
+
+// configureGradebook saves the LMS endpoint of the group and returns the response to the group. The secret of the
+// group is kept across assignments, so that the LMS only needs it once.
+func configureGradebook(manager telegram.SettingsManager, botAPI sender, chatID int64, userID int,
+	settings telegram.Settings, url string, assignment string) tgbotapi.MessageConfig {
+	if len(settings.GradebookSecret) == 0 {
+		random := make([]byte, 32)
+		_, err := rand.Read(random)
+		if err != nil {
+			log.Printf("Failed to generate gradebook secret. %s.\n", err)
+			return tgbotapi.NewMessage(chatID, "Save settings failed. Please try again later.")
+		}
+
+		settings.GradebookSecret = hex.EncodeToString(random)
+	}
+
+	_, err := botAPI.Send(tgbotapi.NewMessage(int64(userID), fmt.Sprintf("The scores of the quizzes of the group "+
+		"are posted to %s for the assignment %s, signed in the %s header with the secret:\n%s", url, assignment,
+		gradebook.SignatureHeader, settings.GradebookSecret)))
+	if err != nil {
+		log.Printf("Failed to send gradebook secret. %s.\n", err)
+		return tgbotapi.NewMessage(chatID, "Please start a private chat with me first, the secret signing the "+
+			"scores is sent there.")
+	}
+
+	settings.GradebookURL = url
+	settings.GradebookAssignment = assignment
+
+	err = manager.SaveSettings(chatID, settings)
+	if err != nil {
+		return tgbotapi.NewMessage(chatID, fmt.Sprintf("Save settings failed. %s.", err))
+	}
+
+	return tgbotapi.NewMessage(chatID, fmt.Sprintf("The scores of the group practices are posted to the gradebook "+
+		"for the assignment %s. The secret was sent to you in private.", assignment))
+}
+
+// postScores posts the standings of the quiz of the group to the LMS endpoint of its assignment, in the background so
+// that a slow LMS does not hold the group up.
+func postScores(settings telegram.Settings, chatID int64, session *quiz.Session, now time.Time) {
+	if len(settings.GradebookURL) == 0 || len(session.Players) == 0 {
+		return
+	}
+
+	report := gradebook.ScoreReport{Assignment: settings.GradebookAssignment, ChatID: chatID, FinishedAt: now}
+	for userID, player := range session.Players {
+		report.Scores = append(report.Scores, gradebook.Score{
+			UserID:  userID,
+			Name:    player.Name,
+			Correct: player.Correct,
+			Score:   player.Score,
+		})
+	}
+
+	sort.Slice(report.Scores, func(i, j int) bool {
+		return report.Scores[i].UserID < report.Scores[j].UserID
+	})
+
+	go func() {
+		_ = gradebook.PostScores(settings.GradebookURL, settings.GradebookSecret, report)
+	}()
+}
@@ -0,0 +1,132 @@
+package handoff
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+	"github.com/handracs2007/kquiz/updates"
+)
+
+// pollTimeout is the long polling timeout of Telegram, it bounds how long draining waits for a pending request.
+const pollTimeout = 10
+
+// Client is the part of the Telegram client requesting the updates, so that the poller can be driven without Telegram.
+type Client interface {
+	MakeRequest(endpoint string, params url.Values) (tgbotapi.APIResponse, error)
+}
+
+// Poller receives the Telegram updates and keeps track of the offset of the next update to process, so that another
+// instance of the bot can take over from there without dropping or processing an update twice.
+type Poller struct {
+	bot     Client
+	offset  int
+	updates chan updates.Update
+	stop    chan struct{}
+	stopped chan struct{}
+	once    sync.Once
+}
+
+// NewPoller creates a new instance of Poller starting at the given update offset, zero for the oldest update Telegram
+// still holds.
+func NewPoller(bot Client, offset int) *Poller {
+	return &Poller{
+		bot:     bot,
+		offset:  offset,
+		updates: make(chan updates.Update),
+		stop:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+}
+
+// Start starts receiving the updates in the background. The channel is unbuffered so that an update counts as
+// delivered only once it is received, and it is closed when the poller is drained or the context is done.
+func (poller *Poller) Start(ctx context.Context) <-chan updates.Update {
+	go poller.run(ctx)
+	return poller.updates
+}
+
+func (poller *Poller) run(ctx context.Context) {
+	defer close(poller.stopped)
+	defer close(poller.updates)
+
+	for {
+		select {
+		case <-poller.stop:
+			return
+		case <-ctx.Done():
+			return
+		default:
+		}
+
+		received, err := poller.getUpdates()
+		if err != nil {
+			log.Printf("Failed to get updates, retrying in 3 seconds. %s.\n", err)
+
+			select {
+			case <-poller.stop:
+				return
+			case <-ctx.Done():
+				return
+			case <-time.After(3 * time.Second):
+			}
+
+			continue
+		}
+
+		for _, update := range received {
+			if update.UpdateID < poller.offset {
+				continue
+			}
+
+			// The updates not delivered yet are left to the next instance, Telegram only forgets them once an
+			// offset past them is requested.
+			select {
+			case poller.updates <- update:
+				poller.offset = update.UpdateID + 1
+			case <-poller.stop:
+				return
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
+
+// getUpdates requests the updates from the offset. The client predates some kinds of updates, e.g. the reactions, and
+// drops them, hence, the request is made directly.
+func (poller *Poller) getUpdates() ([]updates.Update, error) {
+	params := url.Values{}
+	params.Set("offset", strconv.Itoa(poller.offset))
+	params.Set("timeout", strconv.Itoa(pollTimeout))
+	params.Set("allowed_updates", updates.AllowedUpdatesParam())
+
+	resp, err := poller.bot.MakeRequest("getUpdates", params)
+	if err != nil {
+		return nil, err
+	}
+
+	var received []updates.Update
+	err = json.Unmarshal(resp.Result, &received)
+	if err != nil {
+		return nil, err
+	}
+
+	return received, nil
+}
+
+// Drain stops receiving updates and returns the offset of the first update not delivered. The updates channel is
+// closed, the caller must still wait for the update being processed, if any, before handing the offset over.
+func (poller *Poller) Drain() int {
+	poller.once.Do(func() {
+		close(poller.stop)
+	})
+
+	<-poller.stopped
+	return poller.offset
+}
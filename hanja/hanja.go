@@ -0,0 +1,161 @@
+package hanja
+
+import (
+	"bufio"
+	"embed"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// ErrWordNotFound indicates that the word is not a known Sino-Korean word.
+var ErrWordNotFound = errors.New("no hanja found for this word")
+
+//go:embed data/characters.tsv data/words.tsv
+var data embed.FS
+
+// nativeSuffixes are native Korean endings commonly attached to Sino-Korean stems, e.g. 공부하다 or 선생님.
+var nativeSuffixes = []string{"하다", "되다", "시키다", "스럽다", "적인", "적으로", "적", "님"}
+
+// Character represents a hanja character with its Korean reading and meaning.
+type Character struct {
+	Hanja   string
+	Reading string
+	Meaning string
+}
+
+// Breakdown represents a Sino-Korean word decomposed into its hanja characters.
+type Breakdown struct {
+	Word       string
+	Hanja      string
+	Characters []Character
+}
+
+// Dictionary holds the embedded hanja characters and Sino-Korean words.
+type Dictionary struct {
+	characters map[string]Character
+	words      map[string]string
+	usages     map[string][]string
+}
+
+// readTSV reads the tab separated records of an embedded data file, skipping comments and empty lines.
+func readTSV(name string, fields int) ([][]string, error) {
+	file, err := data.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	records := make([][]string, 0)
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if len(text) == 0 || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		record := strings.Split(text, "\t")
+		if len(record) != fields {
+			return nil, fmt.Errorf("%s line %d: expected %d fields, got %d", name, line, fields, len(record))
+		}
+
+		records = append(records, record)
+	}
+
+	return records, scanner.Err()
+}
+
+// NewDictionary creates a new instance of Dictionary from the embedded data.
+func NewDictionary() (*Dictionary, error) {
+	dict := &Dictionary{
+		characters: make(map[string]Character),
+		words:      make(map[string]string),
+		usages:     make(map[string][]string),
+	}
+
+	characters, err := readTSV("data/characters.tsv", 3)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, record := range characters {
+		dict.characters[record[0]] = Character{Hanja: record[0], Reading: record[1], Meaning: record[2]}
+	}
+
+	words, err := readTSV("data/words.tsv", 2)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, record := range words {
+		// Each hanja is read as exactly one Hangul syllable, anything else is a mistake in the data.
+		if utf8.RuneCountInString(record[0]) != utf8.RuneCountInString(record[1]) {
+			return nil, fmt.Errorf("word %s does not match hanja %s", record[0], record[1])
+		}
+
+		for _, char := range record[1] {
+			if _, ok := dict.characters[string(char)]; !ok {
+				return nil, fmt.Errorf("word %s uses unknown hanja %c", record[0], char)
+			}
+		}
+
+		dict.words[record[0]] = record[1]
+		for _, char := range record[1] {
+			dict.usages[string(char)] = append(dict.usages[string(char)], record[0])
+		}
+	}
+
+	return dict, nil
+}
+
+// stem returns the Sino-Korean part of the word known by the dictionary, removing a native suffix when necessary.
+func (dict *Dictionary) stem(word string) (string, bool) {
+	word = strings.TrimSpace(word)
+	if _, ok := dict.words[word]; ok {
+		return word, true
+	}
+
+	for _, suffix := range nativeSuffixes {
+		stem := strings.TrimSuffix(word, suffix)
+		if _, ok := dict.words[stem]; ok && stem != word {
+			return stem, true
+		}
+	}
+
+	return "", false
+}
+
+// Lookup decomposes the word into its hanja characters. The reading of each character is taken from the word itself,
+// so that sound changes such as 年 being read 연 at the beginning of a word are preserved.
+// This function returns the following errors:
+//  - ErrWordNotFound
+func (dict *Dictionary) Lookup(word string) (*Breakdown, error) {
+	stem, ok := dict.stem(word)
+	if !ok {
+		return nil, ErrWordNotFound
+	}
+
+	hanja := dict.words[stem]
+	breakdown := &Breakdown{Word: stem, Hanja: hanja}
+
+	syllables := []rune(stem)
+	for i, char := range []rune(hanja) {
+		character := dict.characters[string(char)]
+		character.Reading = string(syllables[i])
+		breakdown.Characters = append(breakdown.Characters, character)
+	}
+
+	return breakdown, nil
+}
+
+// Character returns the details of the hanja character.
+func (dict *Dictionary) Character(hanja string) (Character, bool) {
+	character, ok := dict.characters[hanja]
+	return character, ok
+}
+
+// WordsWith returns the Sino-Korean words in the dictionary using the hanja character.
+func (dict *Dictionary) WordsWith(hanja string) []string {
+	return dict.usages[hanja]
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"fmt"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+	"github.com/handracs2007/kquiz/features"
+	"strings"
+)
+
+// command describes a bot command. Commands requiring a disabled feature are hidden from /help and refused, so are
+// the admin commands for the other users.
+type command struct {
+	name        string
+	usage       string
+	description string
+	feature     string
+	admin       bool
+}
+
+var commands = []command{
+	{name: "/register", description: "Register to start using the bot."},
+	{name: "/unregister", description: "Unregister and stop receiving updates."},
+	{name: "/add", usage: "<word> <translation> [#tag...]", description: "Add a word and its translation."},
+	{name: "/add", usage: "<word>", description: "Add a word translated automatically.", feature: features.Translation},
+	{name: "/addgrammar", usage: "<pattern> <meaning> | <example>", description: "Add a grammar pattern."},
+	{name: "/example", usage: "<word> <sentence>", description: "Set the example sentence of a word."},
+	{name: "/note", usage: "<word> <notes>", description: "Keep notes on a word, e.g. its usage or a mnemonic."},
+	{name: "/audit", description: "Find the duplicate words, conflicting and missing translations, and clean them up."},
+	{name: "/search", usage: "<word>", description: "Search the translation of a word and its origin."},
+	{name: "/define", usage: "<word>", description: "Look a word up in the dictionary.", feature: features.Dictionary},
+	{name: "/hanja", usage: "<word>", description: "Show the hanja of a Sino-Korean word."},
+	{name: "/related", usage: "<word>", description: "List the words sharing hanja or stems."},
+	{name: "/random", usage: "[grammar] [#tag]", description: "Quiz a word or grammar pattern, the ones due first."},
+	{name: "/practice", usage: "[goal]", description: "Practise until you reach the goal, or stop on a bad day."},
+	{name: "/quiz", usage: "[count] [#tag]", description: "Pick the translations among 4 options with buttons, " +
+		"keeping a running score. With a count, type the answers of a quiz of count questions and get its results."},
+	{name: "/hint", description: "Get a hint for the question, each one lowers the points of the answer."},
+	{name: "/skip", description: "Skip the question of the quiz, it counts as wrong."},
+	{name: "/stop", description: "Stop the quiz and get its results so far."},
+	{name: "/stats", description: "Show how many words you added and quizzes you answered, and your streak."},
+	{name: "/transcript", usage: "[period]", description: "Get the questions you answered, e.g. over 7d, as CSV."},
+	{name: "/changes", usage: "[period]", description: "See the words added, removed and edited, e.g. over 30d."},
+	{name: "/grant", usage: "@tutor", description: "Let your tutor read your stats and transcripts."},
+	{name: "/revoke", usage: "@tutor", description: "Stop your tutor reading your stats and transcripts."},
+	{name: "/grants", description: "List the tutors who can read your stats and transcripts."},
+	{name: "/leaderboard", usage: "[accuracy|streak|join|leave]",
+		description: "Rank the users who opted in by accuracy or streak."},
+	{name: "/review", description: "Review the words due today."},
+	{name: "/mistakes", usage: "[quiz]", description: "Show or quiz the words you answered wrong until you fix them."},
+	{name: "/mastered", description: "Show the words you mastered, which are no longer quizzed."},
+	{name: "/mastered", usage: "resurface <count>|off", description: "Bring a few mastered words back every week."},
+	{name: "/reviews", usage: "<morning> <evening> [count]|off", description: "Split the daily reviews in two."},
+	{name: "/daily", usage: "<time>|off", description: "Get a quiz question every day at the given time."},
+	{name: "/timezone", usage: "<Area/City>", description: "Set your time zone for the reviews."},
+	{name: "/sentence", description: "Put the words of an example sentence in order."},
+	{name: "/podcast", usage: "[<time>|off]", description: "Listen to your due words, now or every day at a time.",
+		feature: features.TTS},
+	{name: "/dictation", description: "Transcribe an example sentence you hear.", feature: features.TTS},
+	{name: "/speak", usage: "[stats]", description: "Practise your pronunciation.", feature: features.STT},
+	{name: "/accessible", usage: "on|off", description: "Get plain feedback without emojis, for screen readers."},
+	{name: "/largeprint", usage: "on|off", description: "Get the quiz questions as images in large type."},
+	{name: "/reverse", usage: "on|off", description: "Answer the quizzes with the Korean word, in Hangul or romanized."},
+	{name: "/warmup", usage: "<count>|off", description: "Start the sessions with a few well-known words."},
+	{name: "/recap", usage: "on|off", description: "End the sessions with the missed words to tag, note or drill."},
+	{name: "/combo", usage: "on|off", description: "Earn more points for correct answers in a row."},
+	{name: "/silent", usage: "on|off", description: "Get the reminders without a notification sound."},
+	{name: "/quiet", usage: "<start> <end>|off", description: "Get the reminders silently around your quiet hours."},
+	{name: "/winback", usage: "on|off", description: "Get a message when you have not studied for a while."},
+	{name: "/cleanup", usage: "<minutes>|off", description: "Delete questions and feedback in groups after a while."},
+	{name: "/gradebook", usage: "<url> <assignment>|off",
+		description: "Post the scores of the group practices to an LMS, for group admins."},
+	{name: "/apitoken", usage: "[off]", description: "Get a token adding words from Zapier, IFTTT or other services."},
+	{name: "/webhook", usage: "set <url>|off", description: "Post your events as signed JSON to your own endpoint."},
+	{name: "/prefix", usage: "<prefix>|off", description: "Use a prefix instead of mentions for commands in groups."},
+	{name: "/list", usage: "[#tag|source:<name>]", description: "List your words, or the tagged or imported ones."},
+	{name: "/grammar", description: "List your grammar patterns."},
+	{name: "/decks", description: "List your decks."},
+	{name: "/delete", usage: "<word>", description: "Delete a word."},
+	{name: "/clear", description: "Delete all your words."},
+	{name: "/import", usage: "sheet <url>", description: "Import words from Google Sheets.",
+		feature: features.RemoteImport},
+	{name: "/import", usage: "set <url>", description: "Import a Quizlet or Memrise set.",
+		feature: features.RemoteImport},
+	{name: "/import", description: "Import the words of a CSV file sent with this caption, word and translation per line."},
+	{name: "/export", usage: "link", description: "Get a temporary link to a page with your words."},
+	{name: "/export", usage: "csv|anki", description: "Get your words as a CSV file or as notes to import in Anki."},
+	{name: "/export", usage: "pdf", description: "Get a printable worksheet of your words and their translations."},
+	{name: "/template", usage: "[deck] [fixed|shuffle] [attempts=<n>] [due=<yyyy-mm-dd>]",
+		description: "Get a link giving your class your words, review times and assignment."},
+	{name: "/card", usage: "[deck]", description: "Get an image with the QR code of a link sharing your words."},
+	{name: "/assignment", description: "Take the assignment of your class."},
+	{name: "/channel", usage: "<@channel> [deck]|off", description: "Publish the word of the day to a channel.",
+		admin: true, feature: features.Publishing},
+	{name: "/channel", usage: "post", description: "Publish the next word of the day now.", admin: true,
+		feature: features.Publishing},
+	{name: "/queue", usage: "[approve|skip|move|edit <n> ...]", description: "Preview the upcoming words of the day.",
+		admin: true, feature: features.Publishing},
+	{name: "/broadcast", usage: "<message>", description: "Message every user, with {name}, {streak} and {due_count}.",
+		admin: true},
+	{name: "/users", description: "Count the registered users and the banned chats.", admin: true},
+	{name: "/ban", usage: "<chat ID>|@username", description: "Make the bot ignore an abusive chat.", admin: true},
+	{name: "/unban", usage: "<chat ID>|@username", description: "Let a banned chat use the bot again.", admin: true},
+	{name: "/experiments", description: "Compare the engagement with the nudge variants.", admin: true},
+	{name: "/usage", description: "Show the calls to the external services today and their cost.", admin: true},
+	{name: "/cache", description: "Show the usage of the cached responses.", admin: true},
+	{name: "/cache", usage: "clear [translation|dictionary]", description: "Remove the cached responses.", admin: true},
+	{name: "/audio", description: "Show the size of the cached audio.", admin: true},
+	{name: "/audio", usage: "purge", description: "Remove the cached audio.", admin: true},
+	{name: "/backup", description: "Back up the databases now.", admin: true},
+	{name: "/backup", usage: "list", description: "List the backups available to restore.", admin: true},
+	{name: "/help", description: "Show this help."},
+	{name: "/privacy", description: "Show the privacy notice."},
+}
+
+// isAvailable reports whether the command can be used. A command is available when at least one of its usages does not
+// require a disabled feature nor, for the other users, an admin. The handler takes care of refusing the disabled
+// usages.
+func isAvailable(flags features.Flags, admin bool, name string) bool {
+	known := false
+	for _, cmd := range commands {
+		if cmd.name != name {
+			continue
+		}
+
+		known = true
+		if flags.Enabled(cmd.feature) && (admin || !cmd.admin) {
+			return true
+		}
+	}
+
+	// Aliases and the answers of the quizzes are not in the list, these are always available.
+	return !known
+}
+
+// isCommand reports whether the name is one of the listed commands.
+func isCommand(name string) bool {
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+func showHelp(flags features.Flags, admin bool, chatID int64) tgbotapi.Chattable {
+	lines := []string{"Available commands:"}
+	for _, cmd := range commands {
+		if !flags.Enabled(cmd.feature) || (cmd.admin && !admin) {
+			continue
+		}
+
+		usage := cmd.name
+		if len(cmd.usage) != 0 {
+			usage += " " + cmd.usage
+		}
+
+		lines = append(lines, fmt.Sprintf("%s - %s", usage, cmd.description))
+	}
+
+	return tgbotapi.NewMessage(chatID, strings.Join(lines, "\n"))
+}
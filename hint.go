@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+	"github.com/handracs2007/kquiz/quiz"
+)
+
+// giveHint reveals the next hint of the question the user is to answer: the first letter of the answer, its length,
+// then an example sentence. The hints taken lower the points of the answer.
+func (d *dispatcher) giveHint(chatID int64) tgbotapi.Chattable {
+	var msg tgbotapi.MessageConfig
+	question, ok := d.pendingQuestion(chatID)
+
+	example := ""
+	if ok && len(question.Word) != 0 {
+		if entry, err := d.words.SearchEntry(chatID, question.Word); err == nil {
+			example = entry.Example
+		}
+	}
+
+	hint, hinted := question.Hint(example)
+	if !ok {
+		msg = tgbotapi.NewMessage(chatID, "There is no question to hint, start a quiz with /random or /quiz <count>.")
+	} else if !question.Hintable() {
+		msg = tgbotapi.NewMessage(chatID, "This question has no hints.")
+	} else if !hinted {
+		msg = tgbotapi.NewMessage(chatID, "No hints left, give it your best guess.")
+	} else {
+		question.Hints++
+		d.setPending(chatID, question)
+
+		session := d.session(chatID)
+		session.HintsUsed++
+		if session.QuizRunning() {
+			d.saveSession(chatID, session)
+		}
+
+		msg = tgbotapi.NewMessage(chatID, fmt.Sprintf("Hint %d of %d: %s\nThe answer now earns %d%% of the points.",
+			question.Hints, quiz.MaxHints, hint, quiz.HintedPoints(100, question.Hints)))
+	}
+
+	return msg
+}
@@ -0,0 +1,107 @@
+package importer
+
+import (
+	"encoding/csv"
+	"errors"
+	"io"
+	"strings"
+)
+
+// ErrEmptySource indicates that the import source does not contain any usable row.
+var ErrEmptySource = errors.New("nothing to import")
+
+// Pair represents a word and its translation read from an import source.
+type Pair struct {
+	Word        string
+	Translation string
+}
+
+// Result holds the rows read from an import source.
+type Result struct {
+	Pairs     []Pair
+	Malformed int
+}
+
+// add appends the word and translation to the result, or counts it as malformed when either is empty.
+func (result *Result) add(word string, translation string) {
+	word = strings.TrimSpace(word)
+	translation = strings.TrimSpace(translation)
+
+	if len(word) == 0 || len(translation) == 0 {
+		result.Malformed++
+		return
+	}
+
+	result.Pairs = append(result.Pairs, Pair{Word: word, Translation: translation})
+}
+
+// headerNames contains the column names we consider to be a header row instead of a word.
+var headerNames = map[string]bool{
+	"word":        true,
+	"korean":      true,
+	"term":        true,
+	"translation": true,
+	"meaning":     true,
+	"english":     true,
+	"definition":  true,
+}
+
+func isHeader(record []string) bool {
+	if len(record) < 2 {
+		return false
+	}
+
+	return headerNames[strings.ToLower(strings.TrimSpace(record[0]))] &&
+		headerNames[strings.ToLower(strings.TrimSpace(record[1]))]
+}
+
+// ReadCSV reads word and translation pairs from CSV data. The first column is treated as the Korean word and the
+// second column as its translation, any other column is ignored. A header row is skipped when present. Rows with
+// an empty word or translation are counted as malformed.
+// This function returns the following errors:
+//  - ErrEmptySource
+func ReadCSV(r io.Reader) (*Result, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1 // Spreadsheets commonly have ragged rows.
+	reader.LazyQuotes = true
+
+	result := &Result{}
+	first := true
+
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			// This row cannot be parsed. Let's count it and continue with the next one.
+			result.Malformed++
+			continue
+		}
+
+		if first {
+			first = false
+			if isHeader(record) {
+				continue
+			}
+		}
+
+		if len(record) < 2 {
+			result.Malformed++
+			continue
+		}
+
+		if len(strings.TrimSpace(record[0])) == 0 && len(strings.TrimSpace(record[1])) == 0 {
+			// Blank rows are common in spreadsheets, these are not worth reporting.
+			continue
+		}
+
+		result.add(record[0], record[1])
+	}
+
+	if len(result.Pairs) == 0 && result.Malformed == 0 {
+		return nil, ErrEmptySource
+	}
+
+	return result, nil
+}
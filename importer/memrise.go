@@ -0,0 +1,68 @@
+package importer
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+)
+
+var memriseCoursePattern = regexp.MustCompile(`^/course/(\d+)/([^/]+)`)
+
+var memriseTitlePattern = regexp.MustCompile(`(?s)<h1 class="course-name[^"]*">(.*?)</h1>`)
+
+var memriseAuthorPattern = regexp.MustCompile(`(?s)class="creator-name[^"]*"[^>]*>(.*?)</`)
+
+var memriseThingPattern = regexp.MustCompile(
+	`(?s)<div class="col_a col text"[^>]*>\s*<div class="text"[^>]*>(.*?)</div>.*?` +
+		`<div class="col_b col text"[^>]*>\s*<div class="text"[^>]*>(.*?)</div>`)
+
+func fetchMemriseCourse(u *url.URL) (*Set, error) {
+	match := memriseCoursePattern.FindStringSubmatch(u.Path)
+	if match == nil {
+		return nil, ErrUnsupportedSetURL
+	}
+
+	courseURL := fmt.Sprintf("https://%s/course/%s/%s/", u.Host, match[1], match[2])
+	page, err := fetchPage(courseURL)
+	if err != nil {
+		return nil, err
+	}
+
+	set := &Set{
+		Provider: "Memrise",
+		URL:      courseURL,
+		Title:    firstMatch(memriseTitlePattern, page),
+		Author:   firstMatch(memriseAuthorPattern, page),
+		Result:   &Result{},
+	}
+	if len(set.Title) == 0 {
+		set.Title = "Memrise course " + match[1]
+	}
+
+	// Courses with a single level list their words directly on the course page, the others are split into levels.
+	readMemriseThings(page, set.Result)
+
+	levelPattern := regexp.MustCompile(fmt.Sprintf(`href="/course/%s/%s/(\d+)/"`, match[1], regexp.QuoteMeta(match[2])))
+	seen := make(map[string]bool)
+	for _, level := range levelPattern.FindAllStringSubmatch(page, -1) {
+		if seen[level[1]] {
+			continue
+		}
+		seen[level[1]] = true
+
+		levelPage, err := fetchPage(courseURL + level[1] + "/")
+		if err != nil {
+			return nil, err
+		}
+
+		readMemriseThings(levelPage, set.Result)
+	}
+
+	return set, nil
+}
+
+func readMemriseThings(page string, result *Result) {
+	for _, thing := range memriseThingPattern.FindAllStringSubmatch(page, -1) {
+		result.add(textOf(thing[1]), textOf(thing[2]))
+	}
+}
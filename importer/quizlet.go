@@ -0,0 +1,94 @@
+package importer
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+var quizletSetIDPattern = regexp.MustCompile(`^/(?:[a-z]{2}(?:-[a-z]{2})?/)?(\d+)(?:/|$)`)
+
+var quizletTitlePattern = regexp.MustCompile(`(?s)<title>(.*?)(?:\s+Flashcards)?\s*\|\s*Quizlet</title>`)
+
+var quizletAuthorPattern = regexp.MustCompile(`"creator":\{[^{}]*?"username":"([^"]+)"`)
+
+// quizletItems mirrors the part of the Quizlet studiable items response that we need.
+type quizletItems struct {
+	Responses []struct {
+		Models struct {
+			StudiableItem []struct {
+				CardSides []struct {
+					Media []struct {
+						PlainText string `json:"plainText"`
+					} `json:"media"`
+				} `json:"cardSides"`
+			} `json:"studiableItem"`
+		} `json:"models"`
+	} `json:"responses"`
+}
+
+func fetchQuizletSet(u *url.URL) (*Set, error) {
+	match := quizletSetIDPattern.FindStringSubmatch(u.Path)
+	if match == nil {
+		return nil, ErrUnsupportedSetURL
+	}
+
+	setID := match[1]
+	set := &Set{Provider: "Quizlet", URL: u.String(), Result: &Result{}}
+
+	// The terms are served by the same API used by the Quizlet website. Private sets are answered with an error status.
+	itemsURL := fmt.Sprintf("https://quizlet.com/webapi/3.4/studiable-item-documents?"+
+		"filters%%5BstudiableContainerId%%5D=%s&filters%%5BstudiableContainerType%%5D=1&perPage=1000&page=1", setID)
+
+	resp, err := fetch(itemsURL)
+	if err != nil {
+		log.Printf("Failed to download Quizlet set %s. %s.\n", setID, err)
+		return nil, ErrDownloadFailed
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		log.Printf("Failed to download Quizlet set %s. Status %d.\n", setID, resp.StatusCode)
+		return nil, ErrSetNotAccessible
+	}
+
+	var items quizletItems
+	err = json.NewDecoder(resp.Body).Decode(&items)
+	if err != nil {
+		log.Printf("Failed to read Quizlet set %s. %s.\n", setID, err)
+		return nil, ErrSetNotAccessible
+	}
+
+	for _, response := range items.Responses {
+		for _, item := range response.Models.StudiableItem {
+			if len(item.CardSides) < 2 || len(item.CardSides[0].Media) == 0 || len(item.CardSides[1].Media) == 0 {
+				set.Result.Malformed++
+				continue
+			}
+
+			set.Result.add(item.CardSides[0].Media[0].PlainText, item.CardSides[1].Media[0].PlainText)
+		}
+	}
+
+	if len(set.Result.Pairs) == 0 && set.Result.Malformed == 0 {
+		// Quizlet does not always refuse the request for private sets, it may return no item instead.
+		return nil, ErrSetNotAccessible
+	}
+
+	// The title and the author are only nice to have, the set page is often protected against bots.
+	page, err := fetchPage(u.String())
+	if err == nil {
+		set.Title = firstMatch(quizletTitlePattern, page)
+		set.Author = firstMatch(quizletAuthorPattern, page)
+	}
+
+	if len(strings.TrimSpace(set.Title)) == 0 {
+		set.Title = "Quizlet set " + setID
+	}
+
+	return set, nil
+}
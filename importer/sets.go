@@ -0,0 +1,99 @@
+package importer
+
+import (
+	"errors"
+	"html"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// ErrUnsupportedSetURL indicates that the URL does not refer to a supported flashcard website.
+var ErrUnsupportedSetURL = errors.New("unsupported set URL, only Quizlet and Memrise sets are supported")
+
+// ErrSetNotAccessible indicates that the set is private, removed, or cannot be read.
+var ErrSetNotAccessible = errors.New("set is private or does not exist")
+
+// Set represents a flashcard set fetched from a flashcard website together with its attribution.
+type Set struct {
+	Title    string
+	Author   string
+	Provider string
+	URL      string
+	Result   *Result
+}
+
+// FetchSet downloads a public Quizlet or Memrise set and reads its term and definition pairs.
+// This function returns the following errors:
+//  - ErrUnsupportedSetURL
+//  - ErrSetNotAccessible
+//  - ErrDownloadFailed
+//  - ErrEmptySource
+func FetchSet(setURL string) (*Set, error) {
+	u, err := url.Parse(strings.TrimSpace(setURL))
+	if err != nil {
+		return nil, ErrUnsupportedSetURL
+	}
+
+	var set *Set
+	switch strings.TrimPrefix(u.Host, "www.") {
+	case "quizlet.com":
+		set, err = fetchQuizletSet(u)
+	case "memrise.com", "app.memrise.com", "community-courses.memrise.com":
+		set, err = fetchMemriseCourse(u)
+	default:
+		return nil, ErrUnsupportedSetURL
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if len(set.Result.Pairs) == 0 && set.Result.Malformed == 0 {
+		return nil, ErrEmptySource
+	}
+
+	return set, nil
+}
+
+// fetchPage downloads an HTML page. A missing page or a redirect to the login page is reported as ErrSetNotAccessible.
+func fetchPage(pageURL string) (string, error) {
+	resp, err := fetch(pageURL)
+	if err != nil {
+		log.Printf("Failed to download %s. %s.\n", pageURL, err)
+		return "", ErrDownloadFailed
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK || strings.Contains(resp.Request.URL.Path, "login") {
+		log.Printf("Failed to download %s. Status %d, final URL %s.\n", pageURL, resp.StatusCode, resp.Request.URL)
+		return "", ErrSetNotAccessible
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxDownloadSize))
+	if err != nil {
+		log.Printf("Failed to read %s. %s.\n", pageURL, err)
+		return "", ErrDownloadFailed
+	}
+
+	return string(body), nil
+}
+
+// textOf converts an HTML fragment into plain text.
+func textOf(fragment string) string {
+	return strings.TrimSpace(html.UnescapeString(htmlTagPattern.ReplaceAllString(fragment, "")))
+}
+
+var htmlTagPattern = regexp.MustCompile(`<[^>]*>`)
+
+// firstMatch returns the text of the first group matched by the pattern or an empty string.
+func firstMatch(pattern *regexp.Regexp, page string) string {
+	match := pattern.FindStringSubmatch(page)
+	if match == nil {
+		return ""
+	}
+
+	return textOf(match[1])
+}
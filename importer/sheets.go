@@ -0,0 +1,98 @@
+package importer
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// ErrInvalidSheetURL indicates that the given URL is not a Google Sheets URL.
+var ErrInvalidSheetURL = errors.New("invalid Google Sheets URL")
+
+// ErrSheetNotAccessible indicates that the sheet cannot be downloaded, most likely because it is not shared publicly.
+var ErrSheetNotAccessible = errors.New("sheet is not accessible, make sure it is shared with anyone with the link")
+
+// ErrDownloadFailed indicates that the import source cannot be downloaded.
+var ErrDownloadFailed = errors.New("download failed")
+
+// maxDownloadSize limits how much data we are willing to read from a remote import source.
+const maxDownloadSize = 5 << 20
+
+var sheetIDPattern = regexp.MustCompile(`^/spreadsheets/d/([a-zA-Z0-9_-]+)`)
+
+var httpClient = &http.Client{Timeout: 30 * time.Second}
+
+// fetch sends a GET request to the given URL. Some websites refuse requests without a browser-like user agent.
+func fetch(rawURL string) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("User-Agent", "Mozilla/5.0 (compatible; kquiz)")
+	return httpClient.Do(req)
+}
+
+// SheetExportURL converts a shareable Google Sheets URL into its CSV export URL. The sheet tab referred by the gid
+// parameter, if any, is preserved.
+// This function returns the following errors:
+//  - ErrInvalidSheetURL
+func SheetExportURL(sheetURL string) (string, error) {
+	u, err := url.Parse(strings.TrimSpace(sheetURL))
+	if err != nil || u.Host != "docs.google.com" {
+		return "", ErrInvalidSheetURL
+	}
+
+	match := sheetIDPattern.FindStringSubmatch(u.Path)
+	if match == nil {
+		return "", ErrInvalidSheetURL
+	}
+
+	// The tab can be given either in the query or in the fragment, e.g. /edit#gid=0.
+	gid := u.Query().Get("gid")
+	if len(gid) == 0 && strings.HasPrefix(u.Fragment, "gid=") {
+		gid = strings.TrimPrefix(u.Fragment, "gid=")
+	}
+
+	exportURL := fmt.Sprintf("https://docs.google.com/spreadsheets/d/%s/export?format=csv", match[1])
+	if len(gid) != 0 {
+		exportURL += "&gid=" + url.QueryEscape(gid)
+	}
+
+	return exportURL, nil
+}
+
+// FetchSheet downloads a publicly shared Google Sheet and reads its word and translation columns.
+// This function returns the following errors:
+//  - ErrInvalidSheetURL
+//  - ErrSheetNotAccessible
+//  - ErrDownloadFailed
+//  - ErrEmptySource
+func FetchSheet(sheetURL string) (*Result, error) {
+	exportURL, err := SheetExportURL(sheetURL)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := fetch(exportURL)
+	if err != nil {
+		log.Printf("Failed to download sheet %s. %s.\n", exportURL, err)
+		return nil, ErrDownloadFailed
+	}
+	defer resp.Body.Close()
+
+	// Private sheets are answered with a redirect to the login page which ends up as an HTML page.
+	if resp.StatusCode != http.StatusOK || !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/csv") {
+		log.Printf("Failed to download sheet %s. Status %d, content type %s.\n", exportURL, resp.StatusCode,
+			resp.Header.Get("Content-Type"))
+		return nil, ErrSheetNotAccessible
+	}
+
+	return ReadCSV(io.LimitReader(resp.Body, maxDownloadSize))
+}
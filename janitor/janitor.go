@@ -0,0 +1,83 @@
+// Package janitor deletes the transient messages of the bot after a delay, keeping the group chats readable, and the
+// records past their retention, keeping the database small.
+package janitor
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+// Deleter is the part of the Telegram client deleting messages.
+type Deleter interface {
+	DeleteMessage(config tgbotapi.DeleteMessageConfig) (tgbotapi.APIResponse, error)
+}
+
+type deletion struct {
+	chatID    int64
+	messageID int
+	at        time.Time
+}
+
+// Janitor keeps the messages to delete until their time comes. The messages are kept in memory, those pending when the
+// bot stops are left in place.
+type Janitor struct {
+	deleter  Deleter
+	mutex    sync.Mutex
+	pending  []deletion
+	policies []policy
+	prunedAt time.Time
+}
+
+// New creates a new instance of Janitor
+func New(deleter Deleter) *Janitor {
+	return &Janitor{deleter: deleter}
+}
+
+// Schedule deletes the message at the given time, on the first sweep after it.
+func (janitor *Janitor) Schedule(chatID int64, messageID int, at time.Time) {
+	janitor.mutex.Lock()
+	defer janitor.mutex.Unlock()
+
+	janitor.pending = append(janitor.pending, deletion{chatID: chatID, messageID: messageID, at: at})
+}
+
+// Pending returns the number of messages waiting to be deleted.
+func (janitor *Janitor) Pending() int {
+	janitor.mutex.Lock()
+	defer janitor.mutex.Unlock()
+
+	return len(janitor.pending)
+}
+
+// Sweep deletes the messages due at the given time and enforces the retention policies. It is meant to run as a job of
+// the scheduler.
+func (janitor *Janitor) Sweep(now time.Time) {
+	janitor.enforce(now)
+
+	janitor.mutex.Lock()
+	due := make([]deletion, 0)
+	kept := janitor.pending[:0]
+	for _, pending := range janitor.pending {
+		if pending.at.After(now) {
+			kept = append(kept, pending)
+		} else {
+			due = append(due, pending)
+		}
+	}
+	janitor.pending = kept
+	janitor.mutex.Unlock()
+
+	// Messages already deleted by the admins of the group fail to delete, which is fine.
+	for _, pending := range due {
+		_, err := janitor.deleter.DeleteMessage(tgbotapi.DeleteMessageConfig{
+			ChatID:    pending.chatID,
+			MessageID: pending.messageID,
+		})
+		if err != nil {
+			log.Printf("Failed to delete message %d in %d. %s.\n", pending.messageID, pending.chatID, err)
+		}
+	}
+}
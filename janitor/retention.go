@@ -0,0 +1,53 @@
+package janitor
+
+import (
+	"log"
+	"time"
+)
+
+// pruneInterval is how often the retention policies are enforced, the records do not need to go on the minute.
+const pruneInterval = time.Hour
+
+// Pruner removes the records older than the given time and returns how many were removed.
+type Pruner func(before time.Time) (int, error)
+
+type policy struct {
+	name   string
+	prune  Pruner
+	retain time.Duration
+}
+
+// Retain keeps the records of the pruner for the given duration, the older ones are removed on the sweeps. The records
+// are kept forever when the duration is not positive.
+func (janitor *Janitor) Retain(name string, prune Pruner, retain time.Duration) {
+	if retain <= 0 {
+		return
+	}
+
+	janitor.mutex.Lock()
+	defer janitor.mutex.Unlock()
+
+	janitor.policies = append(janitor.policies, policy{name: name, prune: prune, retain: retain})
+}
+
+// enforce removes the records past their retention, at most once per prune interval.
+func (janitor *Janitor) enforce(now time.Time) {
+	janitor.mutex.Lock()
+	if now.Sub(janitor.prunedAt) < pruneInterval {
+		janitor.mutex.Unlock()
+		return
+	}
+
+	janitor.prunedAt = now
+	policies := janitor.policies
+	janitor.mutex.Unlock()
+
+	for _, policy := range policies {
+		removed, err := policy.prune(now.Add(-policy.retain))
+		if err != nil {
+			log.Printf("Failed to prune %s. %s.\n", policy.name, err)
+		} else if removed != 0 {
+			log.Printf("Pruned %d %s records.\n", removed, policy.name)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+// Package layout lays out the lists the bot sends as monospace tables, aligning the columns whatever the script of the
+// words, e.g. the full-width Hangul and hanja, or the right-to-left Arabic and Hebrew.
+package layout
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/bidi"
+	"golang.org/x/text/width"
+)
+
+// The marks isolating the right-to-left text, so that it does not reorder the columns around it.
+const (
+	firstStrongIsolate = '⁨'
+	popDirectional     = '⁩'
+)
+
+// runeWidth returns the number of monospace columns the rune takes. The combining marks, the format characters and the
+// conjoining vowels and finals of the Hangul take none, they join the rune before them.
+func runeWidth(r rune) int {
+	switch {
+	case unicode.In(r, unicode.Mn, unicode.Me, unicode.Cf):
+		return 0
+	case r >= 0x1160 && r <= 0x11FF, r >= 0xD7B0 && r <= 0xD7FF:
+		return 0
+	}
+
+	switch width.LookupRune(r).Kind() {
+	case width.EastAsianWide, width.EastAsianFullwidth:
+		return 2
+	default:
+		return 1
+	}
+}
+
+// Width returns the number of monospace columns the text takes.
+func Width(text string) int {
+	columns := 0
+	for _, r := range text {
+		columns += runeWidth(r)
+	}
+
+	return columns
+}
+
+// Isolate wraps the text in directional isolates when it contains right-to-left script, the other texts are returned
+// as they are.
+func Isolate(text string) string {
+	for _, r := range text {
+		properties, _ := bidi.LookupRune(r)
+		if class := properties.Class(); class == bidi.R || class == bidi.AL {
+			return string(firstStrongIsolate) + text + string(popDirectional)
+		}
+	}
+
+	return text
+}
+
+// Pad isolates the text and pads it with spaces to the number of columns.
+func Pad(text string, columns int) string {
+	if padding := columns - Width(text); padding > 0 {
+		return Isolate(text) + strings.Repeat(" ", padding)
+	}
+
+	return Isolate(text)
+}
+
+// Table lays out the rows as lines of columns separated by the separator, each column as wide as its widest cell. The
+// last column is not padded.
+func Table(rows [][]string, separator string) []string {
+	var widths []int
+	for _, row := range rows {
+		for i, cell := range row {
+			if i == len(widths) {
+				widths = append(widths, 0)
+			}
+			if cellWidth := Width(cell); cellWidth > widths[i] {
+				widths[i] = cellWidth
+			}
+		}
+	}
+
+	lines := make([]string, 0, len(rows))
+	for _, row := range rows {
+		cells := make([]string, len(row))
+		for i, cell := range row {
+			if i == len(row)-1 {
+				cells[i] = Isolate(cell)
+			} else {
+				cells[i] = Pad(cell, widths[i])
+			}
+		}
+
+		lines = append(lines, strings.Join(cells, separator))
+	}
+
+	return lines
+}
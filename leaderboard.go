@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+	"github.com/handracs2007/kquiz/telegram"
+	"log"
+	"strings"
+	"time"
+)
+
+// leaderboardSize is the number of players shown on the leaderboard.
+const leaderboardSize = 10
+
+// joinLeaderboard opts the user in to the leaderboard under the first name, with the figures so far.
+func joinLeaderboard(leaderboard telegram.Leaderboard, tracker telegram.StatsTracker,
+	activities telegram.ActivityTracker, manager telegram.SettingsManager, chatID int64,
+	name string) tgbotapi.Chattable {
+	var msg tgbotapi.MessageConfig
+	err := leaderboard.Join(chatID, name)
+	if err == nil {
+		updatePlayer(leaderboard, tracker, activities, manager, chatID)
+		msg = tgbotapi.NewMessage(chatID, fmt.Sprintf("You are on the leaderboard as %s. Use /leaderboard leave to "+
+			"be removed.", name))
+	} else {
+		msg = tgbotapi.NewMessage(chatID, fmt.Sprintf("Join leaderboard failed. %s.", err))
+	}
+
+	return msg
+}
+
+// leaveLeaderboard removes the user from the leaderboard.
+func leaveLeaderboard(leaderboard telegram.Leaderboard, chatID int64) tgbotapi.Chattable {
+	var msg tgbotapi.MessageConfig
+	err := leaderboard.Leave(chatID)
+	if err == nil {
+		msg = tgbotapi.NewMessage(chatID, "You are no longer on the leaderboard.")
+	} else {
+		msg = tgbotapi.NewMessage(chatID, fmt.Sprintf("Leave leaderboard failed. %s.", err))
+	}
+
+	return msg
+}
+
+// updatePlayer updates the figures of the user on the leaderboard, if the user opted in.
+func updatePlayer(leaderboard telegram.Leaderboard, tracker telegram.StatsTracker, activities telegram.ActivityTracker,
+	manager telegram.SettingsManager, chatID int64) {
+	stats, err := tracker.Stats(chatID)
+	if err != nil {
+		return
+	}
+
+	activity, err := activities.Activity(chatID)
+	if err != nil {
+		return
+	}
+
+	settings, err := manager.Settings(chatID)
+	if err != nil {
+		log.Printf("Failed to read settings, using the defaults. %s.\n", err)
+	}
+
+	err = leaderboard.UpdatePlayer(chatID, stats, activity, settings.Timezone)
+	if err != nil {
+		log.Printf("Failed to update leaderboard. %s.\n", err)
+	}
+}
+
+// showLeaderboard lists the best players by accuracy or by streak, the players who did not opt in never appear.
+func showLeaderboard(leaderboard telegram.Leaderboard, chatID int64, rank string) tgbotapi.Chattable {
+	var msg tgbotapi.MessageConfig
+	now := time.Now()
+	players, err := leaderboard.Ranking(rank, now, leaderboardSize)
+	if err != nil {
+		msg = tgbotapi.NewMessage(chatID, fmt.Sprintf("Get leaderboard failed. %s.", err))
+	} else if len(players) == 0 {
+		msg = tgbotapi.NewMessage(chatID, "Nobody is on the leaderboard yet. Use /leaderboard join to take part.")
+	} else {
+		lines := []string{fmt.Sprintf("Best accuracy, at least %d answers:", telegram.MinRankedAnswers)}
+		if rank == telegram.RankStreak {
+			lines = []string{"Longest streaks:"}
+		}
+
+		for i, player := range players {
+			figure := fmt.Sprintf("%d%% of %d answers", player.Accuracy(), player.Answered)
+			if rank == telegram.RankStreak {
+				figure = fmt.Sprintf("%d days", player.CurrentStreak(now))
+			}
+
+			lines = append(lines, fmt.Sprintf("%d. %s, %s", i+1, player.Name, figure))
+		}
+
+		joined, err := leaderboard.Joined(chatID)
+		if err == nil && !joined {
+			lines = append(lines, "", "Use /leaderboard join to take part.")
+		}
+
+		msg = tgbotapi.NewMessage(chatID, strings.Join(lines, "\n"))
+	}
+
+	return msg
+}
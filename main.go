@@ -1,18 +1,85 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"flag"
 	"fmt"
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+	"github.com/handracs2007/kquiz/backup"
+	"github.com/handracs2007/kquiz/card"
+	"github.com/handracs2007/kquiz/config"
+	"github.com/handracs2007/kquiz/events"
+	"github.com/handracs2007/kquiz/features"
+	"github.com/handracs2007/kquiz/gradebook"
+	"github.com/handracs2007/kquiz/handoff"
+	"github.com/handracs2007/kquiz/hanja"
+	"github.com/handracs2007/kquiz/importer"
+	"github.com/handracs2007/kquiz/janitor"
+	"github.com/handracs2007/kquiz/layout"
+	"github.com/handracs2007/kquiz/metrics"
+	"github.com/handracs2007/kquiz/migrations"
+	"github.com/handracs2007/kquiz/moderation"
+	"github.com/handracs2007/kquiz/nudge"
+	"github.com/handracs2007/kquiz/outbox"
+	"github.com/handracs2007/kquiz/providers"
+	"github.com/handracs2007/kquiz/quiz"
+	"github.com/handracs2007/kquiz/replication"
+	"github.com/handracs2007/kquiz/roots"
+	"github.com/handracs2007/kquiz/scheduler"
+	"github.com/handracs2007/kquiz/storage"
 	"github.com/handracs2007/kquiz/telegram"
+	"github.com/handracs2007/kquiz/updates"
+	"github.com/handracs2007/kquiz/web"
 	"go.etcd.io/bbolt"
+	"html"
+	"io"
+	"io/ioutil"
 	"log"
+	"net/http"
+	"net/url"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"strconv"
 	"strings"
 	"syscall"
+	"time"
+	_ "time/tzdata"
 )
 
-func registerUser(registerer telegram.Registerer, botAPI *tgbotapi.BotAPI, chatID int64) {
+// hanjaCallbackPrefix prefixes the callback data of the buttons showing the hanja breakdown of a word.
+const hanjaCallbackPrefix = "hanja:"
+
+// privacyCallbackPrefix prefixes the callback data of the button accepting the privacy notice, followed by its version.
+const privacyCallbackPrefix = "privacy:"
+
+// choiceCallbackPrefix prefixes the callback data of the buttons answering the multiple choice quiz.
+const choiceCallbackPrefix = "choice:"
+
+// recapCallbackPrefix prefixes the callback data of the buttons of the session recaps, followed by the action.
+const recapCallbackPrefix = "recap:"
+
+// auditCallbackPrefix prefixes the callback data of the buttons cleaning up the issues found by /audit, followed by the
+// action.
+const auditCallbackPrefix = "audit:"
+
+// conflictCallbackPrefix prefixes the callback data of the buttons resolving the translation conflicts of the imports,
+// followed by the action.
+const conflictCallbackPrefix = "conflict:"
+
+// lemmaCallbackPrefix prefixes the callback data of the buttons offering the dictionary form of a conjugated word,
+// followed by the action.
+const lemmaCallbackPrefix = "lemma:"
+
+// listCallbackPrefix prefixes the callback data of the buttons paging through /list, followed by the offset and the tag.
+const listCallbackPrefix = "list:"
+
+// sqliteBackupName is the file name of the SQLite database within the backups.
+const sqliteBackupName = "kquiz.sqlite"
+
+// registerUser registers the user and returns the reply and whether the user is registered, new or not.
+func registerUser(registerer storage.Registerer, chatID int64) (tgbotapi.Chattable, bool) {
 	var msg tgbotapi.MessageConfig
 	err := registerer.Register(chatID)
 	if err != nil {
@@ -21,13 +88,22 @@ func registerUser(registerer telegram.Registerer, botAPI *tgbotapi.BotAPI, chatI
 		msg = tgbotapi.NewMessage(chatID, "Thanks for your registration.")
 	}
 
-	_, err = botAPI.Send(msg)
-	if err != nil {
-		log.Printf("Failed to respond to registration request. %s.\n", err)
+	return msg, err == nil || err == telegram.ErrAlreadyRegistered
+}
+
+// trySample asks a new user a word of the sample deck, so that the quiz can be tried before adding any word. It
+// returns no question when the sample deck is empty.
+func trySample(sampleDeck []telegram.Entry, engine *quiz.Engine, chatID int64) (tgbotapi.Chattable, *quiz.Question) {
+	entry, ok := engine.Pick(sampleDeck)
+	if !ok {
+		return nil, nil
 	}
+
+	question := quiz.ForSample(entry)
+	return tgbotapi.NewMessage(chatID, question.Prompt), &question
 }
 
-func unregisterUser(unregisterer telegram.Unregisterer, botAPI *tgbotapi.BotAPI, chatID int64) {
+func unregisterUser(unregisterer storage.Unregisterer, chatID int64) tgbotapi.Chattable {
 	var msg tgbotapi.MessageConfig
 	err := unregisterer.Unregister(chatID)
 	if err != nil {
@@ -36,286 +112,1769 @@ func unregisterUser(unregisterer telegram.Unregisterer, botAPI *tgbotapi.BotAPI,
 		msg = tgbotapi.NewMessage(chatID, "You have been successfully unregistered. You will not receive any future updates.")
 	}
 
-	_, err = botAPI.Send(msg)
-	if err != nil {
-		log.Printf("Failed to respond to unregistration request. %s.\n", err)
-	}
+	return msg
 }
 
-func addWord(adder telegram.Adder, botAPI *tgbotapi.BotAPI, chatID int64, word string, translation string) {
+func addWord(adder storage.Adder, chatID int64, word string, translation string, tags []string) tgbotapi.Chattable {
 	var msg tgbotapi.MessageConfig
-	err := adder.Add(chatID, word, translation)
+	err := adder.AddEntry(chatID, word, telegram.WordEntry{Translation: translation, Tags: tags,
+		Origin: telegram.OriginManual})
 	if err != nil {
 		msg = tgbotapi.NewMessage(chatID, fmt.Sprintf("Add word failed. %s.", err))
+	} else if len(tags) != 0 {
+		msg = tgbotapi.NewMessage(chatID, fmt.Sprintf("New word successfully added. %s -> %s, tagged #%s.", word,
+			translation, strings.Join(tags, " #")))
 	} else {
 		msg = tgbotapi.NewMessage(chatID, fmt.Sprintf("New word successfully added. %s -> %s.", word, translation))
 	}
 
-	_, err = botAPI.Send(msg)
+	return msg
+}
+
+// parseTags splits the tags, the trailing words starting with #, off the argument. The tags are lowercase and without #.
+func parseTags(argument string) (string, []string) {
+	fields := strings.Fields(argument)
+	end := len(fields)
+	for end > 0 && strings.HasPrefix(fields[end-1], "#") && len(fields[end-1]) > 1 {
+		end--
+	}
+
+	var tags []string
+	for _, field := range fields[end:] {
+		tag := strings.ToLower(strings.TrimPrefix(field, "#"))
+		if !(telegram.WordEntry{Tags: tags}).HasTag(tag) {
+			tags = append(tags, tag)
+		}
+	}
+
+	return strings.Join(fields[:end], " "), tags
+}
+
+// parseTag returns the tag of the argument of the commands filtering by tag, e.g. #food, empty when there is none.
+func parseTag(argument string) string {
+	if !strings.HasPrefix(argument, "#") {
+		return ""
+	}
+
+	return strings.ToLower(strings.TrimPrefix(argument, "#"))
+}
+
+// parseListFilter returns the filter of the argument of /list, the tag, e.g. #food, or the source following
+// telegram.SourceFilter, e.g. source:topik1. It is empty when there is none.
+func parseListFilter(argument string) string {
+	if strings.HasPrefix(strings.ToLower(argument), telegram.SourceFilter) {
+		return telegram.SourceFilter + strings.TrimSpace(argument[len(telegram.SourceFilter):])
+	}
+
+	return parseTag(argument)
+}
+
+// parseGrammar splits the argument of /addgrammar into the grammar pattern, its meaning, and the optional example.
+// The example follows " | ". Patterns containing spaces, e.g. -(으)ㄹ 수 있다, are separated from their meaning with
+// " = ", otherwise the first space separates them.
+func parseGrammar(argument string) (string, string, string, bool) {
+	example := ""
+	if idx := strings.Index(argument, " | "); idx != -1 {
+		example = strings.TrimSpace(argument[idx+3:])
+		argument = argument[:idx]
+	}
+
+	separator := " "
+	if strings.Contains(argument, " = ") {
+		separator = " = "
+	}
+
+	splitted := strings.SplitN(argument, separator, 2)
+	if len(splitted) != 2 {
+		return "", "", "", false
+	}
+
+	pattern := strings.TrimSpace(splitted[0])
+	meaning := strings.TrimSpace(splitted[1])
+	if len(pattern) == 0 || len(meaning) == 0 {
+		return "", "", "", false
+	}
+
+	return pattern, meaning, example, true
+}
+
+func addGrammar(adder storage.Adder, chatID int64, pattern string, meaning string,
+	example string) tgbotapi.Chattable {
+	var msg tgbotapi.MessageConfig
+	err := adder.AddEntry(chatID, pattern, telegram.WordEntry{Kind: telegram.KindGrammar, Translation: meaning,
+		Example: example, Origin: telegram.OriginManual})
+	if err != nil {
+		msg = tgbotapi.NewMessage(chatID, fmt.Sprintf("Add grammar failed. %s.", err))
+	} else {
+		msg = tgbotapi.NewMessage(chatID, fmt.Sprintf("New grammar pattern successfully added. %s -> %s.", pattern,
+			meaning))
+	}
+
+	return msg
+}
+
+func listGrammar(lister storage.Lister, chatID int64) []tgbotapi.Chattable {
+	entries, err := lister.ListEntries(chatID, telegram.KindGrammar)
+	if err != nil {
+		return []tgbotapi.Chattable{tgbotapi.NewMessage(chatID, fmt.Sprintf("List grammar failed. %s.", err))}
+	}
+
+	replies := make([]tgbotapi.Chattable, 0, len(entries))
+	for _, entry := range entries {
+		text := fmt.Sprintf("%s -> %s", entry.Word, entry.Translation)
+		if len(entry.Example) != 0 {
+			text += fmt.Sprintf("\nExample: %s", entry.Example)
+		}
+
+		replies = append(replies, tgbotapi.NewMessage(chatID, text))
+	}
+
+	return replies
+}
+
+func addTranslatedWord(adder storage.Adder, translator providers.Translator, chatID int64, word string,
+	language string, tags []string) tgbotapi.Chattable {
+	translation, err := translator.Translate(word, "ko", language)
+	if err != nil {
+		return tgbotapi.NewMessage(chatID, fmt.Sprintf("Translate word failed. %s. Please provide the translation.",
+			err))
+	}
+
+	return addWord(adder, chatID, word, translation, tags)
+}
+
+func defineWord(dictionary providers.Dictionary, chatID int64, word string) tgbotapi.Chattable {
+	var msg tgbotapi.MessageConfig
+	definitions, err := dictionary.Define(word)
 	if err != nil {
-		log.Printf("Failed to respond to add word request. %s.\n", err)
+		msg = tgbotapi.NewMessage(chatID, fmt.Sprintf("Define word failed. %s.", err))
+	} else {
+		lines := make([]string, 0, len(definitions))
+		for i, definition := range definitions {
+			line := fmt.Sprintf("%d. %s", i+1, definition.Word)
+			if len(definition.PartOfSpeech) != 0 {
+				line += fmt.Sprintf(" (%s)", definition.PartOfSpeech)
+			}
+
+			lines = append(lines, fmt.Sprintf("%s: %s", line, strings.Join(definition.Meanings, "; ")))
+		}
+
+		msg = tgbotapi.NewMessage(chatID, strings.Join(lines, "\n"))
 	}
+
+	return msg
 }
 
-func searchWord(searcher telegram.Searcher, botAPI *tgbotapi.BotAPI, chatID int64, word string) {
+func searchWord(searcher storage.Searcher, dict *hanja.Dictionary, chatID int64,
+	word string) tgbotapi.Chattable {
 	var msg tgbotapi.MessageConfig
-	translation, err := searcher.Search(chatID, word)
+	entry, err := searcher.SearchEntry(chatID, word)
 	if err != nil {
 		msg = tgbotapi.NewMessage(chatID, fmt.Sprintf("Search word failed. %s.", err))
 	} else {
-		msg = tgbotapi.NewMessage(chatID, fmt.Sprintf("%s -> %s.", word, *translation))
+		text := fmt.Sprintf("%s -> %s.", word, entry.Translation)
+		if origin := describeOrigin(*entry); len(origin) != 0 {
+			text += "\n" + origin
+		}
+		msg = tgbotapi.NewMessage(chatID, text)
+
+		// Let's offer the hanja breakdown for Sino-Korean words. Telegram limits the callback data to 64 bytes.
+		if breakdown, err := dict.Lookup(word); err == nil && len(hanjaCallbackPrefix+word) <= 64 {
+			msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("Hanja %s", breakdown.Hanja), hanjaCallbackPrefix+word)))
+		}
+	}
+
+	return msg
+}
+
+// describeOrigin tells how the word was added, e.g. Imported from topik1.csv., empty when it is not known.
+func describeOrigin(entry telegram.WordEntry) string {
+	kind, name := telegram.SplitOrigin(entry.EntryOrigin())
+	switch kind {
+	case telegram.OriginManual:
+		return "Added by hand."
+	case telegram.OriginImport:
+		return fmt.Sprintf("Imported from %s.", name)
+	case telegram.OriginDeck:
+		return fmt.Sprintf("Added from the shared deck %s.", name)
+	case telegram.OriginAPI:
+		return "Added through the API."
+	case telegram.OriginExtension:
+		if site := sourceSite(entry.Source); len(site) != 0 {
+			return fmt.Sprintf("Captured from %s.", site)
+		}
+
+		return "Captured by the browser extension."
 	}
 
-	_, err = botAPI.Send(msg)
+	return ""
+}
+
+// sourceSite returns the host of the page a word was captured from, without www., empty when there is none.
+func sourceSite(source string) string {
+	parsed, err := url.Parse(source)
 	if err != nil {
-		log.Printf("Failed to respond to search word request. %s.\n", err)
+		return ""
 	}
+
+	return strings.TrimPrefix(parsed.Hostname(), "www.")
 }
 
-func randomWord(searcher telegram.Searcher, botAPI *tgbotapi.BotAPI, chatID int64) []string {
+func lookupHanja(dict *hanja.Dictionary, chatID int64, word string) tgbotapi.Chattable {
 	var msg tgbotapi.MessageConfig
-	words, err := searcher.Random(chatID)
+	breakdown, err := dict.Lookup(word)
 	if err != nil {
-		msg = tgbotapi.NewMessage(chatID, fmt.Sprintf("Get random word failed. %s.", err))
-		words = nil
+		msg = tgbotapi.NewMessage(chatID, fmt.Sprintf("Hanja lookup failed. %s.", err))
 	} else {
-		msg = tgbotapi.NewMessage(chatID, fmt.Sprintf("What is translation for: %s", words[0]))
+		lines := []string{fmt.Sprintf("%s (%s)", breakdown.Word, breakdown.Hanja)}
+		for _, character := range breakdown.Characters {
+			lines = append(lines, fmt.Sprintf("%s [%s] %s", character.Hanja, character.Reading, character.Meaning))
+		}
+
+		msg = tgbotapi.NewMessage(chatID, strings.Join(lines, "\n"))
 	}
 
-	_, err = botAPI.Send(msg)
+	return msg
+}
+
+func relatedWords(relater storage.Relater, rootFinder roots.Finder, chatID int64,
+	word string) tgbotapi.Chattable {
+	var msg tgbotapi.MessageConfig
+	related, err := relater.Related(chatID, word)
 	if err != nil {
-		log.Printf("Failed to respond to random word request. %s.\n", err)
+		msg = tgbotapi.NewMessage(chatID, fmt.Sprintf("Find related words failed. %s.", err))
+	} else {
+		lines := make([]string, 0)
+		for _, root := range rootFinder.Roots(word) {
+			if words := related[root]; len(words) > 0 {
+				lines = append(lines, fmt.Sprintf("%s: %s", rootFinder.Describe(root), strings.Join(words, ", ")))
+			}
+		}
+
+		// Let's also suggest the words from the dictionary which are not in the user's words yet.
+		for _, root := range rootFinder.Roots(word) {
+			known := map[string]bool{word: true}
+			for _, relatedWord := range related[root] {
+				known[relatedWord] = true
+			}
+
+			suggestions := make([]string, 0)
+			for _, dictWord := range rootFinder.DictionaryWords(root) {
+				if !known[dictWord] {
+					suggestions = append(suggestions, dictWord)
+				}
+			}
+
+			if len(suggestions) > 0 {
+				lines = append(lines, fmt.Sprintf("%s in the dictionary: %s", rootFinder.Describe(root),
+					strings.Join(suggestions, ", ")))
+			}
+		}
+
+		if len(lines) == 0 {
+			msg = tgbotapi.NewMessage(chatID, fmt.Sprintf("No word related to %s found.", word))
+		} else {
+			msg = tgbotapi.NewMessage(chatID, fmt.Sprintf("Words related to %s:\n%s", word, strings.Join(lines, "\n")))
+		}
 	}
 
-	return words
+	return msg
 }
 
-func deleteWord(deleter telegram.Deleter, botAPI *tgbotapi.BotAPI, chatID int64, word string) {
-	var msg tgbotapi.MessageConfig
-	err := deleter.Delete(chatID, word)
+func randomWord(lister storage.Lister, manager telegram.SettingsManager, engine *quiz.Engine,
+	tracker telegram.RecentTracker, cooldown quiz.Cooldown, chatID int64, kind string, tag string,
+	font []byte) (tgbotapi.Chattable, *quiz.Question) {
+	var msg tgbotapi.Chattable
+	var question *quiz.Question
+	settings, err := manager.Settings(chatID)
 	if err != nil {
-		msg = tgbotapi.NewMessage(chatID, fmt.Sprintf("Delete word failed. %s.", err))
+		log.Printf("Failed to read settings, using the defaults. %s.\n", err)
+	}
+
+	entries, err := lister.ListEntries(chatID, kind)
+	if err == nil {
+		entries = telegram.Tagged(entries, tag)
+	}
+
+	if err != nil {
+		msg = tgbotapi.NewMessage(chatID, fmt.Sprintf("Get random word failed. %s.", err))
+	} else if len(entries) == 0 && len(tag) != 0 {
+		msg = tgbotapi.NewMessage(chatID, fmt.Sprintf("No words are tagged #%s.", tag))
+	} else if entries = quiz.Active(entries); len(entries) == 0 {
+		msg = tgbotapi.NewMessage(chatID, "You mastered all these words, add new ones or see them with /mastered.")
 	} else {
-		msg = tgbotapi.NewMessage(chatID, fmt.Sprintf("%s deleted.", word))
+		// The words due for review come first, the others are quizzed once none is due. The words just asked wait.
+		if due := quiz.DueQueue(entries, time.Now()); len(due) != 0 {
+			entries = due
+		}
+
+		entry, _ := engine.Pick(cooledDown(tracker, cooldown, chatID, entries))
+		recordAsked(tracker, chatID, entry.Word)
+		q := quiz.ForUser(entry, settings)
+		question = &q
+		msg = promptMessage(chatID, question.Prompt, settings, font, false)
+	}
+
+	return msg, question
+}
+
+// promptMessage returns the message asking the prompt, with large print it is an image of the prompt in large type
+// captioned with the prompt. The prompt is sent as text when it cannot be drawn.
+func promptMessage(chatID int64, prompt string, settings telegram.Settings, font []byte,
+	silent bool) tgbotapi.Chattable {
+	text := tgbotapi.NewMessage(chatID, prompt)
+	text.DisableNotification = silent
+	if !settings.LargePrint {
+		return text
 	}
 
-	_, err = botAPI.Send(msg)
+	image, err := card.RenderPrompt(prompt, font)
 	if err != nil {
-		log.Printf("Failed to respond to delete word request. %s.\n", err)
+		log.Printf("Failed to render large print prompt. %s.\n", err)
+		return text
 	}
+
+	photo := tgbotapi.NewPhotoUpload(chatID, tgbotapi.FileBytes{Name: "kquiz-prompt.png", Bytes: image})
+	photo.Caption = prompt
+	photo.DisableNotification = silent
+	return photo
 }
 
-func clearWords(deleter telegram.Deleter, botAPI *tgbotapi.BotAPI, chatID int64) {
+// setLargePrint turns the large print of the quiz prompts on or off.
+func setLargePrint(manager telegram.SettingsManager, chatID int64, enabled bool) tgbotapi.Chattable {
 	var msg tgbotapi.MessageConfig
-	err := deleter.Clear(chatID)
+	settings, err := manager.Settings(chatID)
+	if err == nil {
+		settings.LargePrint = enabled
+		err = manager.SaveSettings(chatID, settings)
+	}
+
 	if err != nil {
-		msg = tgbotapi.NewMessage(chatID, fmt.Sprintf("Clear words failed. %s.", err))
+		msg = tgbotapi.NewMessage(chatID, fmt.Sprintf("Save settings failed. %s.", err))
+	} else if enabled {
+		msg = tgbotapi.NewMessage(chatID, "The quiz questions come as images in large type.")
 	} else {
-		msg = tgbotapi.NewMessage(chatID, "Words cleared.")
+		msg = tgbotapi.NewMessage(chatID, "The quiz questions come as text.")
 	}
 
-	_, err = botAPI.Send(msg)
+	return msg
+}
+
+func setExample(updater storage.Updater, chatID int64, word string, example string) tgbotapi.Chattable {
+	var msg tgbotapi.MessageConfig
+	err := updater.SetExample(chatID, word, example)
 	if err != nil {
-		log.Printf("Failed to respond to clear words request. %s.\n", err)
+		msg = tgbotapi.NewMessage(chatID, fmt.Sprintf("Set example failed. %s.", err))
+	} else {
+		msg = tgbotapi.NewMessage(chatID, fmt.Sprintf("Example for %s saved.", word))
 	}
+
+	return msg
 }
 
-func listWords(lister telegram.Lister, botAPI *tgbotapi.BotAPI, chatID int64) {
+func setNotes(updater storage.Updater, chatID int64, word string, notes string) tgbotapi.Chattable {
 	var msg tgbotapi.MessageConfig
-	words, err := lister.List(chatID)
+	err := updater.SetNotes(chatID, word, notes)
 	if err != nil {
-		msg = tgbotapi.NewMessage(chatID, fmt.Sprintf("List words failed. %s.", err))
+		msg = tgbotapi.NewMessage(chatID, fmt.Sprintf("Set notes failed. %s.", err))
+	} else {
+		msg = tgbotapi.NewMessage(chatID, fmt.Sprintf("Notes for %s saved.", word))
+	}
 
-		_, err = botAPI.Send(msg)
-		if err != nil {
-			log.Printf("Failed to respond to list words request. %s.\n", err)
+	return msg
+}
+
+// exampleEntries lists the vocabulary and grammar entries of the user not mastered, from which the ones with an example
+// sentence can be used for the sentence exercises.
+func exampleEntries(lister storage.Lister, chatID int64) ([]telegram.Entry, error) {
+	entries := make([]telegram.Entry, 0)
+	for _, kind := range []string{telegram.KindVocabulary, telegram.KindGrammar} {
+		kindEntries, err := lister.ListEntries(chatID, kind)
+		if err != nil && err != telegram.ErrWordNotFound {
+			return nil, err
 		}
+
+		entries = append(entries, kindEntries...)
+	}
+
+	return quiz.Active(entries), nil
+}
+
+func sentenceBuilding(lister storage.Lister, engine *quiz.Engine, chatID int64) (tgbotapi.Chattable, *quiz.Question) {
+	var msg tgbotapi.MessageConfig
+	var question *quiz.Question
+
+	entries, err := exampleEntries(lister, chatID)
+	if err != nil {
+		msg = tgbotapi.NewMessage(chatID, fmt.Sprintf("Get sentence failed. %s.", err))
+	} else if q, ok := engine.RandomFrom(entries, engine.ForSentenceBuilding); !ok {
+		msg = tgbotapi.NewMessage(chatID, "You do not have any example sentence yet. "+
+			"Use /example <word> <sentence> to add one.")
 	} else {
-		for _, pairs := range words {
-			word := pairs[0]
-			translation := pairs[1]
-			msg = tgbotapi.NewMessage(chatID, fmt.Sprintf("%s -> %s", word, translation))
+		question = &q
+		msg = tgbotapi.NewMessage(chatID, question.Prompt)
+	}
 
-			_, err = botAPI.Send(msg)
-			if err != nil {
-				log.Printf("Failed to respond to list words request. %s.\n", err)
-			}
-		}
+	return msg, question
+}
+
+// dictation sends the recording of an example sentence to type. The sentence is sent right away to cache its
+// audio, so the reply is nil unless the dictation can't start.
+func dictation(lister storage.Lister, engine *quiz.Engine, tts providers.TextToSpeech,
+	cache telegram.AudioCacheManager, botAPI sender, chatID int64) (tgbotapi.Chattable, *quiz.Question) {
+	var msg tgbotapi.MessageConfig
+
+	entries, err := exampleEntries(lister, chatID)
+	if err != nil {
+		msg = tgbotapi.NewMessage(chatID, fmt.Sprintf("Get sentence failed. %s.", err))
+	} else if !providers.Available(tts) {
+		msg = tgbotapi.NewMessage(chatID, "Dictation is not available, text-to-speech is not configured.")
+	} else if q, ok := engine.RandomFrom(entries, quiz.ForDictation); !ok {
+		msg = tgbotapi.NewMessage(chatID, "You do not have any example sentence yet. "+
+			"Use /example <word> <sentence> to add one.")
+	} else if err := sendSpeech(cache, tts, botAPI, tgbotapi.BaseChat{ChatID: chatID}, q.Answer, "dictation.ogg",
+		q.Prompt); err != nil {
+		msg = tgbotapi.NewMessage(chatID, fmt.Sprintf("Get audio failed. %s.", err))
+	} else {
+		return nil, &q
 	}
+
+	return msg, nil
 }
 
-func main() {
-	const telegramBucket = "telegram"
-	const kquizBucket = "kquiz"
-	const telegramToken = "1633333576:AAFQPddA8OZ6gfEVja_WHZIqJbbT9yg_I-o"
+// maxDownloadSize limits the size of the files sent by the users that we are willing to download.
+const maxDownloadSize = 5 << 20
 
-	var currRandomWord = make(map[int64]string)
+// fileLocator is the part of the Telegram client locating the files sent by the users.
+type fileLocator interface {
+	GetFileDirectURL(fileID string) (string, error)
+}
 
-	db, err := bbolt.Open("kquiz.db", 0666, nil)
+// downloadFile downloads a file sent by the user to the bot.
+func downloadFile(files fileLocator, fileID string) ([]byte, error) {
+	fileURL, err := files.GetFileDirectURL(fileID)
 	if err != nil {
-		log.Fatalf("Failed to open database. %s.", err)
+		return nil, err
 	}
-	defer func() {
-		log.Println("Closing database.")
-		err = db.Close()
+
+	resp, err := http.Get(fileURL)
+	if err != nil {
+		// The URL contains the bot token, let's not log it.
+		return nil, fmt.Errorf("download failed")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("download failed with status %s", resp.Status)
+	}
+
+	return io.ReadAll(io.LimitReader(resp.Body, maxDownloadSize))
+}
+
+func speakingPractice(lister storage.Lister, engine *quiz.Engine, stt providers.SpeechToText,
+	chatID int64) (tgbotapi.Chattable, *quiz.Question) {
+	var msg tgbotapi.MessageConfig
+	var question *quiz.Question
+
+	if !providers.Available(stt) {
+		msg = tgbotapi.NewMessage(chatID, "Speaking practice is not available, speech recognition is not configured.")
+	} else if entries, err := lister.ListEntries(chatID, telegram.KindVocabulary); err != nil {
+		msg = tgbotapi.NewMessage(chatID, fmt.Sprintf("Get random word failed. %s.", err))
+	} else if entries = quiz.Active(entries); len(entries) == 0 {
+		msg = tgbotapi.NewMessage(chatID, "You mastered all your words, add new ones or see them with /mastered.")
+	} else {
+		entry, _ := engine.Pick(entries)
+		q := quiz.ForSpeaking(entry)
+		question = &q
+		msg = tgbotapi.NewMessage(chatID, question.Prompt)
+	}
+
+	return msg, question
+}
+
+// answerSpeaking scores the pronunciation in the voice message sent for the speaking question.
+func answerSpeaking(tracker telegram.PronunciationTracker, stt providers.SpeechToText, files fileLocator,
+	chatID int64, question quiz.Question, voice *tgbotapi.Voice) tgbotapi.Chattable {
+	var msg tgbotapi.MessageConfig
+
+	audio, err := downloadFile(files, voice.FileID)
+	if err != nil {
+		log.Printf("Failed to download voice message. %s.\n", err)
+		msg = tgbotapi.NewMessage(chatID, "Failed to read your voice message. Please try again.")
+	} else if transcript, err := stt.Recognize(audio, providers.KoreanLanguageCode); err != nil {
+		msg = tgbotapi.NewMessage(chatID, fmt.Sprintf("Speech recognition failed. %s.", err))
+	} else {
+		attempt := telegram.PronunciationAttempt{
+			Word:       question.Answer,
+			Transcript: transcript.Text,
+			Confidence: transcript.Confidence,
+			Score:      quiz.PronunciationScore(question.Answer, transcript.Text, transcript.Confidence),
+			At:         time.Now(),
+		}
+
+		err = tracker.RecordAttempt(chatID, attempt)
 		if err != nil {
-			log.Printf("Failed to close database. %s.", err)
+			log.Printf("Failed to record pronunciation attempt. %s.\n", err)
 		}
-	}()
 
-	// Let's create our bucket first if not exist
-	err = db.Update(func(tx *bbolt.Tx) error {
-		_, err := tx.CreateBucketIfNotExists([]byte(kquizBucket))
-		return err
-	})
-	if err != nil {
-		log.Printf("Failed to create bucket %s. %s.\n", kquizBucket, err)
-		return
+		heard := attempt.Transcript
+		if len(heard) == 0 {
+			heard = "nothing recognisable"
+		}
+
+		msg = tgbotapi.NewMessage(chatID, fmt.Sprintf("I heard %s (confidence %.0f%%). Your pronunciation score for %s "+
+			"is %d/100.", heard, 100*attempt.Confidence, attempt.Word, attempt.Score))
 	}
 
-	// Let's create another bucket to store our Telegram bot registrants.
-	err = db.Update(func(tx *bbolt.Tx) error {
-		_, err := tx.CreateBucketIfNotExists([]byte(telegramBucket))
-		return err
-	})
+	return msg
+}
+
+// showStats shows the counters of the owner, who is the user or a student who granted the user access.
+func showStats(tracker telegram.StatsTracker, activities telegram.ActivityTracker, manager telegram.SettingsManager,
+	chatID int64, owner int64) tgbotapi.Chattable {
+	var msg tgbotapi.MessageConfig
+	settings, err := manager.Settings(owner)
 	if err != nil {
-		log.Printf("Failed to create bucket %s. %s.\n", telegramBucket, err)
-		return
+		log.Printf("Failed to read settings, using the defaults. %s.\n", err)
+	}
+
+	var activity telegram.Activity
+	stats, err := tracker.Stats(owner)
+	if err == nil {
+		activity, err = activities.Activity(owner)
 	}
 
-	// Let's prepare our Telegram bot
-	tgBot, err := tgbotapi.NewBotAPI(telegramToken)
 	if err != nil {
-		log.Printf("Failed to create telegram bot. %s.", err)
-		return
+		msg = tgbotapi.NewMessage(chatID, fmt.Sprintf("Get stats failed. %s.", err))
+	} else {
+		streak := activity.CurrentStreak(time.Now(), settings.Location())
+		lines := []string{
+			fmt.Sprintf("Words added: %d", stats.WordsAdded),
+			fmt.Sprintf("Quizzes asked: %d", stats.QuizzesAsked),
+			fmt.Sprintf("Quizzes answered: %d", stats.Answered()),
+			fmt.Sprintf("Correct: %d (%d%%)", stats.Correct, stats.Accuracy()),
+			fmt.Sprintf("Incorrect: %d", stats.Incorrect),
+			fmt.Sprintf("Streak: %d days, best %d days", streak, stats.BestStreak),
+		}
+		msg = tgbotapi.NewMessage(chatID, strings.Join(lines, "\n"))
 	}
 
-	botHandler := telegram.NewBotHandler(db, telegramBucket, kquizBucket)
+	return msg
+}
 
-	// Listen to Telegram updates
-	go func() {
-		u := tgbotapi.NewUpdate(0)
-		u.Timeout = 0
+func pronunciationStats(tracker telegram.PronunciationTracker, chatID int64) tgbotapi.Chattable {
+	const weeks = 4
+	var msg tgbotapi.MessageConfig
 
-		updates, err := tgBot.GetUpdatesChan(u)
-		if err != nil {
-			log.Printf("Failed to get updates channel. %s.", err)
-			return
+	now := time.Now()
+	attempts, err := tracker.Attempts(chatID, now.AddDate(0, 0, -7*weeks))
+	if err != nil {
+		msg = tgbotapi.NewMessage(chatID, fmt.Sprintf("Get pronunciation stats failed. %s.", err))
+	} else if len(attempts) == 0 {
+		msg = tgbotapi.NewMessage(chatID, "You have not practised speaking in the last 4 weeks. Use /speak to start.")
+	} else {
+		// Let's show the average score per week so the user can see the improvement.
+		var totals, counts [weeks]int
+		for _, attempt := range attempts {
+			week := int(now.Sub(attempt.At).Hours() / (24 * 7))
+			if week >= 0 && week < weeks {
+				totals[week] += attempt.Score
+				counts[week]++
+			}
 		}
 
-		for update := range updates {
-			if update.Message == nil {
-				continue
+		lines := []string{"Average pronunciation score:"}
+		for week := weeks - 1; week >= 0; week-- {
+			label := fmt.Sprintf("%d weeks ago", week)
+			if week == 0 {
+				label = "This week"
+			} else if week == 1 {
+				label = "Last week"
 			}
 
-			username := update.Message.Chat.UserName
-			chatID := update.Message.Chat.ID
-			message := update.Message.Text
-			argument := ""
-			log.Printf("Received message from %s[%d]: %s\n", username, chatID, message)
-
-			// Message can contain parameters, hence, let's get the first text before space as the message and
-			// store the rest as arguments.
-			if spaceIndex := strings.Index(message, " "); spaceIndex != -1 {
-				argument = message[spaceIndex+1:]
-				message = message[:spaceIndex]
+			if counts[week] == 0 {
+				lines = append(lines, fmt.Sprintf("%s: no attempt", label))
+			} else {
+				lines = append(lines, fmt.Sprintf("%s: %d/100 over %d attempts", label, totals[week]/counts[week],
+					counts[week]))
 			}
+		}
 
-			switch message {
-			case "/start", "/register":
-				registerUser(botHandler, tgBot, chatID)
+		msg = tgbotapi.NewMessage(chatID, strings.Join(lines, "\n"))
+	}
 
-			case "/stop", "/unregister":
-				unregisterUser(botHandler, tgBot, chatID)
+	return msg
+}
 
-			case "/add":
-				if len(argument) == 0 || strings.Index(argument, " ") == -1 {
-					msg := tgbotapi.NewMessage(chatID, "Please provide the Korean word and its translation.")
+// answerQuestion grades the answer of the pending question and counts it in the session. The answers with a few typos
+// are close enough, their spelling is shown. With combos, the points and the correct answers in a row are shown as
+// well, as plain sentences in the accessible mode. It returns the reply, the question when the user can try again,
+// otherwise nil, and whether the reply reveals the answer of a missed word, to be graded by reacting to it.
+func answerQuestion(grader quiz.Grader, chatID int64, question quiz.Question, answer string, session *quiz.Session,
+	settings telegram.Settings) (tgbotapi.Chattable, *quiz.Question, bool) {
+	var msg tgbotapi.MessageConfig
+	var pending *quiz.Question
+	revealed := false
+	combos := !settings.NoCombos
 
-					_, err := tgBot.Send(msg)
-					if err != nil {
-						log.Printf("Failed to send response. %s.\n", err)
-					}
+	if settings.Accessible {
+		pending, revealed, msg = answerPlainly(grader, chatID, question, answer, session, combos)
+	} else if grade := grader.Grade(question, answer); grade.Correct {
+		text := "Your answer is correct"
+		if grade.Close {
+			text = fmt.Sprintf("Close enough, it is spelled %s", grade.Expected)
+		}
 
-					continue
-				}
+		points := session.RecordHinted(true, combos, question.Hints, time.Now())
+		if combos {
+			text += fmt.Sprintf(" (+%d, session score %d)", points, session.Score)
+			if feedback := quiz.ComboFeedback(session.Combo); len(feedback) != 0 {
+				text += "\n" + feedback
+			}
+		}
 
-				splitted := strings.SplitN(argument, " ", 2)
-				word := splitted[0]
-				translation := splitted[1]
+		msg = tgbotapi.NewMessage(chatID, text)
+	} else if question.Kind == quiz.KindDictation {
+		// Dictation is hard, let's show the mistakes and reveal the sentence a bit more for each attempt.
+		question.Attempts++
+		diff, extra := quiz.Diff(question.Answer, answer)
 
-				addWord(botHandler, tgBot, chatID, word, translation)
+		text := fmt.Sprintf("Mistakes are in brackets: %s", diff)
+		if extra > 0 {
+			text += fmt.Sprintf(" (%d extra characters)", extra)
+		}
 
-			case "/search":
-				if len(argument) == 0 {
-					msg := tgbotapi.NewMessage(chatID, "Please provide the Korean word.")
+		if question.Attempts < quiz.MaxDictationAttempts {
+			if hint := quiz.Reveal(question.Answer, question.Attempts); len(hint) != 0 {
+				text += fmt.Sprintf("\nHint: %s", hint)
+			}
 
-					_, err := tgBot.Send(msg)
-					if err != nil {
-						log.Printf("Failed to send response. %s.\n", err)
-					}
+			text += "\nPlease try again."
+			pending = &question
+		} else {
+			text += fmt.Sprintf("\nCorrect answer is %s.", question.Answer)
+			session.Record(false, combos, time.Now())
+		}
 
-					continue
-				}
+		msg = tgbotapi.NewMessage(chatID, text)
+	} else {
+		text := fmt.Sprintf("Your answer is incorrect. Correct answer is %s.", question.Answer)
+		if len(question.Word) != 0 {
+			text += "\nReact 👍 if it was a typo to grade it Good, or 👎 to grade it Again."
+			revealed = true
+		}
 
-				searchWord(botHandler, tgBot, chatID, argument)
+		msg = tgbotapi.NewMessage(chatID, text)
+		session.Record(false, combos, time.Now())
+	}
 
-			case "/random":
-				words := randomWord(botHandler, tgBot, chatID)
+	return msg, pending, revealed
+}
 
-				if words != nil {
-					currRandomWord[chatID] = words[1]
-				}
+// answerPlainly grades the answer like answerQuestion in short plain sentences, always starting with whether the answer
+// is correct. The mistakes of a dictation are not bracketed, the hint is enough.
+func answerPlainly(grader quiz.Grader, chatID int64, question quiz.Question, answer string, session *quiz.Session,
+	combos bool) (*quiz.Question, bool, tgbotapi.MessageConfig) {
+	if grade := grader.Grade(question, answer); grade.Correct {
+		lines := []string{"Correct."}
+		if grade.Close {
+			lines = []string{"Close enough.", fmt.Sprintf("It is spelled %s.", grade.Expected)}
+		}
+		points := session.RecordHinted(true, combos, question.Hints, time.Now())
+		if combos {
+			lines = append(lines, fmt.Sprintf("You get %d points. Your session score is %d.", points, session.Score))
+			if feedback := quiz.PlainComboFeedback(session.Combo); len(feedback) != 0 {
+				lines = append(lines, feedback)
+			}
+		}
 
-			case "/delete":
-				if len(argument) == 0 {
-					msg := tgbotapi.NewMessage(chatID, "Please provide the Korean word.")
+		return nil, false, tgbotapi.NewMessage(chatID, strings.Join(lines, "\n"))
+	}
 
-					_, err := tgBot.Send(msg)
-					if err != nil {
-						log.Printf("Failed to send response. %s.\n", err)
-					}
+	if question.Kind == quiz.KindDictation {
+		question.Attempts++
+		if question.Attempts < quiz.MaxDictationAttempts {
+			lines := []string{"Incorrect."}
+			if hint := quiz.Reveal(question.Answer, question.Attempts); len(hint) != 0 {
+				lines = append(lines, fmt.Sprintf("Hint: %s", hint))
+			}
 
-					continue
-				}
+			lines = append(lines, "Please try again.")
+			return &question, false, tgbotapi.NewMessage(chatID, strings.Join(lines, "\n"))
+		}
+	}
 
-				deleteWord(botHandler, tgBot, chatID, argument)
+	session.Record(false, combos, time.Now())
+	lines := []string{"Incorrect.", fmt.Sprintf("The answer is %s.", question.Answer)}
+	if question.Kind != quiz.KindDictation && len(question.Word) != 0 {
+		lines = append(lines, "React with a thumbs up if it was a typo, or a thumbs down to study it again.")
+		return nil, true, tgbotapi.NewMessage(chatID, strings.Join(lines, "\n"))
+	}
 
-			case "/list":
-				listWords(botHandler, tgBot, chatID)
+	return nil, false, tgbotapi.NewMessage(chatID, strings.Join(lines, "\n"))
+}
 
-			case "/clear":
-				clearWords(botHandler, tgBot, chatID)
+// setAccessible turns the accessible mode of the feedback on or off.
+func setAccessible(manager telegram.SettingsManager, chatID int64, enabled bool) tgbotapi.Chattable {
+	var msg tgbotapi.MessageConfig
+	settings, err := manager.Settings(chatID)
+	if err == nil {
+		settings.Accessible = enabled
+		err = manager.SaveSettings(chatID, settings)
+	}
 
-			default:
-				// We assume this is answer from the user for the randomised word.
-				answer, ok := currRandomWord[chatID]
-				if !ok {
-					log.Printf("Unknown command [%s].", message)
-					break
-				}
+	if err != nil {
+		msg = tgbotapi.NewMessage(chatID, fmt.Sprintf("Save settings failed. %s.", err))
+	} else if enabled {
+		msg = tgbotapi.NewMessage(chatID, "Accessible mode is on. The feedback uses short plain sentences.")
+	} else {
+		msg = tgbotapi.NewMessage(chatID, "Accessible mode is off.")
+	}
 
-				var msg tgbotapi.MessageConfig
-				if strings.ToLower(message) == strings.ToLower(answer) {
-					msg = tgbotapi.NewMessage(chatID, "Your answer is correct")
-				} else {
-					msg = tgbotapi.NewMessage(chatID, fmt.Sprintf("Your answer is incorrect. Correct answer is %s.", answer))
-				}
+	return msg
+}
 
-				_, err = tgBot.Send(msg)
-				if err != nil {
-					log.Printf("Failed to respond to answer. %s.\n", err)
-				}
+func setReverse(manager telegram.SettingsManager, chatID int64, enabled bool) tgbotapi.Chattable {
+	var msg tgbotapi.MessageConfig
+	settings, err := manager.Settings(chatID)
+	if err == nil {
+		settings.ReverseQuiz = enabled
+		err = manager.SaveSettings(chatID, settings)
+	}
 
-				delete(currRandomWord, chatID)
-			}
-		}
-	}()
+	if err != nil {
+		msg = tgbotapi.NewMessage(chatID, fmt.Sprintf("Save settings failed. %s.", err))
+	} else if enabled {
+		msg = tgbotapi.NewMessage(chatID, "The quizzes show the translation, please answer with the Korean word.")
+	} else {
+		msg = tgbotapi.NewMessage(chatID, "The quizzes show the Korean word, please answer with the translation.")
+	}
 
-	// Make a channel that will listen to the OS signal to handle server shutdown gracefully.
-	c := make(chan os.Signal, 1)
-	signal.Notify(c, syscall.SIGINT, syscall.SIGTERM, syscall.SIGKILL)
-	<-c // Block until signal is received from the channel.
+	return msg
+}
 
-	log.Println("Shutting down.")
+func setCombos(manager telegram.SettingsManager, chatID int64, enabled bool) tgbotapi.Chattable {
+	var msg tgbotapi.MessageConfig
+	settings, err := manager.Settings(chatID)
+	if err == nil {
+		settings.NoCombos = !enabled
+		err = manager.SaveSettings(chatID, settings)
+	}
+
+	if err != nil {
+		msg = tgbotapi.NewMessage(chatID, fmt.Sprintf("Save settings failed. %s.", err))
+	} else if enabled {
+		msg = tgbotapi.NewMessage(chatID, "Combos are on, correct answers in a row earn more points.")
+	} else {
+		msg = tgbotapi.NewMessage(chatID, "Combos are off.")
+	}
+
+	return msg
+}
+
+func setPrefix(manager telegram.SettingsManager, chatID int64, prefix string, botName string) tgbotapi.Chattable {
+	var msg tgbotapi.MessageConfig
+	settings, err := manager.Settings(chatID)
+	if err == nil {
+		settings.Prefix = prefix
+		err = manager.SaveSettings(chatID, settings)
+	}
+
+	if err != nil {
+		msg = tgbotapi.NewMessage(chatID, fmt.Sprintf("Save settings failed. %s.", err))
+	} else if len(prefix) == 0 {
+		msg = tgbotapi.NewMessage(chatID, fmt.Sprintf("Commands must mention the bot, e.g. /help@%s.", botName))
+	} else {
+		msg = tgbotapi.NewMessage(chatID, fmt.Sprintf("Commands can start with %s, e.g. %shelp, or mention the bot, "+
+			"e.g. /help@%s.", prefix, prefix, botName))
+	}
+
+	return msg
+}
+
+func setCleanup(manager telegram.SettingsManager, chatID int64, minutes int) tgbotapi.Chattable {
+	var msg tgbotapi.MessageConfig
+	settings, err := manager.Settings(chatID)
+	if err == nil {
+		settings.CleanupMinutes = minutes
+		err = manager.SaveSettings(chatID, settings)
+	}
+
+	if err != nil {
+		msg = tgbotapi.NewMessage(chatID, fmt.Sprintf("Save settings failed. %s.", err))
+	} else if minutes == 0 {
+		msg = tgbotapi.NewMessage(chatID, "Questions and feedback are kept.")
+	} else {
+		msg = tgbotapi.NewMessage(chatID, fmt.Sprintf("Questions and feedback are deleted after %d minutes. The bot "+
+			"needs to be an admin allowed to delete messages.", minutes))
+	}
+
+	return msg
+}
+
+func deleteWord(deleter storage.Deleter, chatID int64, word string) tgbotapi.Chattable {
+	var msg tgbotapi.MessageConfig
+	err := deleter.Delete(chatID, word)
+	if err != nil {
+		msg = tgbotapi.NewMessage(chatID, fmt.Sprintf("Delete word failed. %s.", err))
+	} else {
+		msg = tgbotapi.NewMessage(chatID, fmt.Sprintf("%s deleted.", word))
+	}
+
+	return msg
+}
+
+func clearWords(deleter storage.Deleter, chatID int64) tgbotapi.Chattable {
+	var msg tgbotapi.MessageConfig
+	err := deleter.Clear(chatID)
+	if err != nil {
+		msg = tgbotapi.NewMessage(chatID, fmt.Sprintf("Clear words failed. %s.", err))
+	} else {
+		msg = tgbotapi.NewMessage(chatID, "Words cleared.")
+	}
+
+	return msg
+}
+
+// listPageSize is the number of words per message of /list, paged through with the Prev and Next buttons.
+const listPageSize = 20
+
+// listPage renders the page of the words matching the filter, a tag or a source, if any, from the offset as a
+// monospace table, with the buttons to the previous and the next pages.
+func listPage(lister storage.Lister, chatID int64, tag string, offset int) (string, *tgbotapi.InlineKeyboardMarkup,
+	error) {
+	entries, total, err := lister.ListPage(chatID, telegram.KindVocabulary, tag, offset, listPageSize)
+	if err != nil {
+		return "", nil, err
+	}
+
+	// The words may have been deleted since the page was shown, let's start over.
+	if len(entries) == 0 && offset > 0 {
+		return listPage(lister, chatID, tag, 0)
+	}
+
+	rows := make([][]string, 0, len(entries))
+	for _, entry := range entries {
+		row := []string{entry.Word, "->", entry.Translation}
+		if len(entry.Tags) != 0 {
+			row = append(row, "#"+strings.Join(entry.Tags, " #"))
+		}
+
+		rows = append(rows, row)
+	}
+
+	lines := layout.Table(rows, " ")
+	for i, line := range lines {
+		lines[i] = html.EscapeString(line)
+	}
+
+	text := "<pre>" + strings.Join(lines, "\n") + "</pre>"
+	if total <= listPageSize {
+		return text, nil, nil
+	}
+
+	text += fmt.Sprintf("\nWords %d-%d of %d.", offset+1, offset+len(entries), total)
+
+	// Telegram limits the callback data to 64 bytes, the long tags are not paged through.
+	buttons := make([]tgbotapi.InlineKeyboardButton, 0, 2)
+	if offset > 0 && len(listCallbackData(offset, tag)) <= 64 {
+		previous := offset - listPageSize
+		if previous < 0 {
+			previous = 0
+		}
+
+		buttons = append(buttons, tgbotapi.NewInlineKeyboardButtonData("« Prev", listCallbackData(previous, tag)))
+	}
+	if offset+len(entries) < total && len(listCallbackData(offset+listPageSize, tag)) <= 64 {
+		buttons = append(buttons, tgbotapi.NewInlineKeyboardButtonData("Next »",
+			listCallbackData(offset+listPageSize, tag)))
+	}
+	if len(buttons) == 0 {
+		return text, nil, nil
+	}
+
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(buttons)
+	return text, &keyboard, nil
+}
+
+// listCallbackData returns the callback data of the button showing the page of /list from the offset.
+func listCallbackData(offset int, tag string) string {
+	return fmt.Sprintf("%s%d:%s", listCallbackPrefix, offset, tag)
+}
+
+// parseListCallback parses the offset and the tag of the callback data of a button of /list.
+func parseListCallback(data string) (int, string, bool) {
+	fields := strings.SplitN(strings.TrimPrefix(data, listCallbackPrefix), ":", 2)
+	if len(fields) != 2 {
+		return 0, "", false
+	}
+
+	offset, err := strconv.Atoi(fields[0])
+	if err != nil || offset < 0 {
+		return 0, "", false
+	}
+
+	return offset, fields[1], true
+}
+
+func listWords(lister storage.Lister, chatID int64, filter string) tgbotapi.Chattable {
+	var msg tgbotapi.MessageConfig
+	text, keyboard, err := listPage(lister, chatID, filter, 0)
+	if err != nil {
+		msg = tgbotapi.NewMessage(chatID, fmt.Sprintf("List words failed. %s.", err))
+	} else {
+		msg = tgbotapi.NewMessage(chatID, text)
+		msg.ParseMode = tgbotapi.ModeHTML
+		if keyboard != nil {
+			msg.ReplyMarkup = *keyboard
+		}
+	}
+
+	return msg
+}
+
+// turnListPage edits the message of /list in place into the page from the offset.
+func turnListPage(lister storage.Lister, chatID int64, messageID int, tag string, offset int) tgbotapi.Chattable {
+	text, keyboard, err := listPage(lister, chatID, tag, offset)
+	if err != nil {
+		text = fmt.Sprintf("List words failed. %s.", err)
+	}
+
+	edit := tgbotapi.NewEditMessageText(chatID, messageID, text)
+	edit.ReplyMarkup = keyboard
+	if err == nil {
+		edit.ParseMode = tgbotapi.ModeHTML
+	}
+
+	return edit
+}
+
+// importWords adds the imported words to the deck, if any, recording their origin. The words the user already has with
+// another translation are not overwritten, they are returned for the user to resolve.
+func importWords(adder storage.Adder, searcher storage.Searcher, chatID int64, result *importer.Result, deck string,
+	origin string) (tgbotapi.Chattable, []translationConflict) {
+	added := 0
+	duplicates := 0
+	failed := 0
+	conflicts := make([]translationConflict, 0)
+
+	for _, pair := range result.Pairs {
+		err := adder.AddEntry(chatID, pair.Word, telegram.WordEntry{Translation: pair.Translation, Deck: deck,
+			Origin: origin})
+		if err == telegram.ErrDuplicateWord {
+			if conflict, ok := findConflict(searcher, chatID, pair.Word, pair.Translation); ok {
+				conflicts = append(conflicts, conflict)
+				continue
+			}
+		}
+		if err == telegram.ErrNotRegistered {
+			return tgbotapi.NewMessage(chatID, fmt.Sprintf("Import failed. %s.", err)), nil
+		}
+
+		switch err {
+		case nil:
+			added++
+		case telegram.ErrDuplicateWord:
+			duplicates++
+		default:
+			failed++
+		}
+	}
+
+	text := fmt.Sprintf("Import finished. %d added, %d skipped as duplicates, %d malformed.", added, duplicates,
+		result.Malformed)
+	if len(conflicts) != 0 {
+		text += fmt.Sprintf(" %d words you already have with another translation, please review them.",
+			len(conflicts))
+	}
+	if len(deck) != 0 {
+		text += fmt.Sprintf(" The words are in the deck %s.", deck)
+	}
+	if failed > 0 {
+		text += fmt.Sprintf(" %d could not be saved, please try again later.", failed)
+	}
+
+	return tgbotapi.NewMessage(chatID, text), conflicts
+}
+
+func importSheet(adder storage.Adder, searcher storage.Searcher, chatID int64,
+	sheetURL string) (tgbotapi.Chattable, []translationConflict) {
+	result, err := importer.FetchSheet(sheetURL)
+	if err != nil {
+		return tgbotapi.NewMessage(chatID, fmt.Sprintf("Import failed. %s.", err)), nil
+	}
+
+	return importWords(adder, searcher, chatID, result, "", telegram.NewOrigin(telegram.OriginImport, sheetURL))
+}
+
+// importDocument imports the words of a CSV file sent to the bot, the words in the first column and their translations
+// in the second.
+func importDocument(checker storage.Checker, adder storage.Adder, searcher storage.Searcher, files fileLocator,
+	chatID int64, document *tgbotapi.Document) (tgbotapi.Chattable, []translationConflict) {
+	failed := func(err error) tgbotapi.Chattable {
+		return tgbotapi.NewMessage(chatID, fmt.Sprintf("Import failed. %s.", err))
+	}
+
+	// Let's not download anything for users who cannot store the words anyway.
+	if !checker.IsRegistered(chatID) {
+		return failed(telegram.ErrNotRegistered), nil
+	}
+
+	if !strings.HasSuffix(strings.ToLower(document.FileName), ".csv") && document.MimeType != "text/csv" {
+		return failed(fmt.Errorf("please send a CSV file")), nil
+	}
+
+	if document.FileSize > maxDownloadSize {
+		return failed(fmt.Errorf("the file is larger than %d MB", maxDownloadSize>>20)), nil
+	}
+
+	data, err := downloadFile(files, document.FileID)
+	if err != nil {
+		return failed(err), nil
+	}
+
+	// Spreadsheet applications often start their CSV files with a byte order mark.
+	result, err := importer.ReadCSV(bytes.NewReader(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))))
+	if err != nil {
+		return failed(err), nil
+	}
+
+	return importWords(adder, searcher, chatID, result, "", telegram.NewOrigin(telegram.OriginImport,
+		document.FileName))
+}
+
+func importSet(checker storage.Checker, adder storage.Adder, searcher storage.Searcher,
+	deckManager telegram.DeckManager, chatID int64, setURL string) (tgbotapi.Chattable, []translationConflict) {
+	failed := func(err error) tgbotapi.Chattable {
+		return tgbotapi.NewMessage(chatID, fmt.Sprintf("Import failed. %s.", err))
+	}
+
+	// Let's not download anything for users who cannot store the words anyway.
+	if !checker.IsRegistered(chatID) {
+		return failed(telegram.ErrNotRegistered), nil
+	}
+
+	set, err := importer.FetchSet(setURL)
+	if err != nil {
+		return failed(err), nil
+	}
+
+	deck, err := deckManager.CreateDeck(chatID, telegram.Deck{
+		Name:       set.Title,
+		Title:      set.Title,
+		Author:     set.Author,
+		Provider:   set.Provider,
+		SourceURL:  set.URL,
+		ImportedAt: time.Now(),
+	})
+	if err != nil {
+		return failed(err), nil
+	}
+
+	return importWords(adder, searcher, chatID, set.Result, deck, telegram.NewOrigin(telegram.OriginImport,
+		set.Title))
+}
+
+func listDecks(deckManager telegram.DeckManager, chatID int64) tgbotapi.Chattable {
+	var msg tgbotapi.MessageConfig
+	decks, err := deckManager.Decks(chatID)
+	if err != nil {
+		msg = tgbotapi.NewMessage(chatID, fmt.Sprintf("List decks failed. %s.", err))
+	} else if len(decks) == 0 {
+		msg = tgbotapi.NewMessage(chatID, "You do not have any deck yet. Use /import set <url> to import one.")
+	} else {
+		lines := make([]string, 0, len(decks))
+		for _, deck := range decks {
+			line := fmt.Sprintf("%s (%d words)", deck.Name, deck.WordCount)
+			if len(deck.Provider) != 0 {
+				line += fmt.Sprintf(", imported from %s", deck.Provider)
+				if len(deck.Author) != 0 {
+					line += fmt.Sprintf(" by %s", deck.Author)
+				}
+				line += fmt.Sprintf(": %s", deck.SourceURL)
+			}
+
+			lines = append(lines, line)
+		}
+
+		msg = tgbotapi.NewMessage(chatID, strings.Join(lines, "\n"))
+	}
+
+	return msg
+}
+
+func exportLink(checker storage.Checker, linker telegram.ExportLinker, chatID int64,
+	baseURL string, ttl time.Duration) tgbotapi.Chattable {
+	var msg tgbotapi.MessageConfig
+	if !checker.IsRegistered(chatID) {
+		msg = tgbotapi.NewMessage(chatID, fmt.Sprintf("Export failed. %s.", telegram.ErrNotRegistered))
+	} else if token, err := linker.CreateLink(chatID, ttl); err != nil {
+		msg = tgbotapi.NewMessage(chatID, fmt.Sprintf("Export failed. %s.", err))
+	} else {
+		msg = tgbotapi.NewMessage(chatID, fmt.Sprintf("Your words are available at %s/export/%s until %s.",
+			strings.TrimSuffix(baseURL, "/"), token, time.Now().Add(ttl).Format("2006-01-02 15:04 MST")))
+	}
+
+	return msg
+}
+
+// issueToken returns a new API token adding words from other services, e.g. Zapier or IFTTT, and how to use
+// it. The previous token stops working.
+func issueToken(checker storage.Checker, issuer telegram.TokenIssuer, chatID int64, baseURL string) tgbotapi.Chattable {
+	var msg tgbotapi.MessageConfig
+	if !checker.IsRegistered(chatID) {
+		msg = tgbotapi.NewMessage(chatID, fmt.Sprintf("Issue token failed. %s.", telegram.ErrNotRegistered))
+	} else if token, err := issuer.IssueToken(chatID); err != nil {
+		msg = tgbotapi.NewMessage(chatID, fmt.Sprintf("Issue token failed. %s.", err))
+	} else {
+		msg = tgbotapi.NewMessage(chatID, fmt.Sprintf("Add words from other services by posting the word, the "+
+			"translation and the optional tags and source URL, as JSON or as a form, to %s/api/words with the header:\n"+
+			"Authorization: Bearer %s\n\nGetting it lists your words with the offset and limit parameters. Keep the "+
+			"token secret, /apitoken off revokes it.", strings.TrimSuffix(baseURL, "/"), token))
+	}
+
+	return msg
+}
+
+// revokeToken revokes the API token of the user, if any.
+func revokeToken(issuer telegram.TokenIssuer, chatID int64) tgbotapi.Chattable {
+	var msg tgbotapi.MessageConfig
+	err := issuer.RevokeToken(chatID)
+	if err != nil {
+		msg = tgbotapi.NewMessage(chatID, fmt.Sprintf("Revoke token failed. %s.", err))
+	} else {
+		msg = tgbotapi.NewMessage(chatID, "Your API token is revoked.")
+	}
+
+	return msg
+}
+
+// adminAlerts returns the alert of the text to each admin.
+func adminAlerts(admins map[int64]bool, text string) []tgbotapi.Chattable {
+	alerts := make([]tgbotapi.Chattable, 0, len(admins))
+	for chatID := range admins {
+		alerts = append(alerts, tgbotapi.NewMessage(chatID, text))
+	}
+
+	return alerts
+}
+
+func usageReport(usage providers.Usage, budget *providers.Budget, chatID int64) tgbotapi.Chattable {
+	day := providers.Today()
+	lines := []string{fmt.Sprintf("Usage on %s:", day)}
+	for _, service := range []string{providers.TTSService, providers.STTService, providers.TranslationService,
+		providers.DictionaryService} {
+		calls, cost, err := usage.Spent(day, service)
+		if err != nil {
+			lines = append(lines, fmt.Sprintf("%s: failed. %s.", service, err))
+			continue
+		}
+
+		line := fmt.Sprintf("%s: %d calls, %.4f USD", service, calls, cost)
+		if limit := budget.Limit(service); limit > 0 {
+			line += fmt.Sprintf(" of %.2f USD", limit)
+		}
+
+		lines = append(lines, line+".")
+	}
+
+	return tgbotapi.NewMessage(chatID, strings.Join(lines, "\n"))
+}
+
+func cacheStats(manager telegram.CacheManager, chatID int64) tgbotapi.Chattable {
+	var msg tgbotapi.MessageConfig
+	stats, err := manager.Stats()
+	if err != nil {
+		msg = tgbotapi.NewMessage(chatID, fmt.Sprintf("Cache stats failed. %s.", err))
+	} else if len(stats) == 0 {
+		msg = tgbotapi.NewMessage(chatID, "The cache is empty.")
+	} else {
+		lines := make([]string, 0, len(stats))
+		for _, namespaceStats := range stats {
+			lines = append(lines, fmt.Sprintf("%s: %d entries (%d expired), %d hits, %d misses.",
+				namespaceStats.Namespace, namespaceStats.Entries, namespaceStats.Expired, namespaceStats.Hits,
+				namespaceStats.Misses))
+		}
+
+		msg = tgbotapi.NewMessage(chatID, strings.Join(lines, "\n"))
+	}
+
+	return msg
+}
+
+func clearCache(manager telegram.CacheManager, chatID int64, namespace string) tgbotapi.Chattable {
+	var msg tgbotapi.MessageConfig
+	removed, err := manager.Invalidate(namespace)
+	if err != nil {
+		msg = tgbotapi.NewMessage(chatID, fmt.Sprintf("Clear cache failed. %s.", err))
+	} else {
+		msg = tgbotapi.NewMessage(chatID, fmt.Sprintf("%d cached responses removed.", removed))
+	}
+
+	return msg
+}
+
+// migrate upgrades the data stored by the earlier versions and returns whether the bot can start.
+func migrate(shards telegram.Shards, cfg config.Config, boltRepository storage.BoltRepository) bool {
+	// The migrations not run yet on these databases run in order, a failed one leaves them as they were.
+	results, err := migrations.Run(shards, migrations.Builtin(cfg.Buckets), false)
+	for _, result := range results {
+		log.Printf("Migrated to version %d, %s, %d changes.\n", result.Version, result.Name, result.Changed)
+	}
+	if err != nil {
+		log.Printf("Failed to migrate the databases. %s.\n", err)
+		return false
+	}
+
+	// The relation index is kept up to date on every write, but it must be rebuilt for the words added before it
+	// existed and whenever the hanja dictionary changes.
+	err = boltRepository.RebuildRelations()
+	if err != nil {
+		log.Printf("Failed to rebuild relation index. %s.\n", err)
+	}
+
+	return true
+}
+
+// shutdownTimeout bounds how long the queued messages are waited for when shutting down.
+const shutdownTimeout = 30 * time.Second
+
+func main() {
+	configPath := flag.String("config", "", "JSON config file, the environment variables take precedence over it")
+	restore := flag.String("restore", "", "backup to restore before starting, e.g. 20060102T150405Z or latest")
+	flag.Parse()
+
+	cfg, err := config.Load(*configPath)
+	if err == nil {
+		err = cfg.Validate()
+	}
+	if err != nil {
+		log.Fatalf("Invalid configuration. %s.\n", err)
+	}
+
+	admins := cfg.AdminChatIDs()
+	audioCacheSize := cfg.AudioCacheMB << 20
+	cfg.Providers.Offline = cfg.Offline
+
+	// The engine is seeded once, a fixed seed makes the quizzes reproducible.
+	quizEngine := quiz.NewEngine(cfg.RandomSeed)
+
+	// A standby only replicates the primary until an admin promotes it with kquizctl, then it carries on as the
+	// primary with the replicated data.
+	if cfg.Role == "standby" {
+		standby := replication.NewStandby(cfg.PrimaryURL, cfg.AdminToken, cfg.DBPath,
+			time.Duration(cfg.ReplicationInterval))
+		if !standby.Run(cfg.HTTPAddr) {
+			return
+		}
+	}
+
+	// The context is done on SIGINT or SIGTERM, stopping the updates and the scheduler. The update in progress still
+	// finishes and the queued messages are still sent before the database closes.
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	// When deploying a new version, the old instance is drained first. It hands over the offset of the next update
+	// and exits, releasing the database, so that every update is processed exactly once.
+	updateOffset := 0
+	if len(cfg.HandoffFrom) != 0 {
+		offset, err := web.RequestHandoff(strings.TrimSuffix(cfg.HandoffFrom, "/"), cfg.AdminToken)
+		if err != nil {
+			log.Printf("Failed to take over from %s, starting from the pending updates. %s.\n", cfg.HandoffFrom, err)
+		} else {
+			log.Printf("Took over from %s at update %d.\n", cfg.HandoffFrom, offset)
+			updateOffset = offset
+		}
+	}
+
+	// In memory, the stores other than the words and the users still need a database, a temporary one gone at exit.
+	// The startup failures from here on return rather than exit, so that it is removed.
+	if cfg.Storage == "memory" {
+		dir, err := ioutil.TempDir("", "kquiz")
+		if err != nil {
+			log.Fatalf("Failed to create temporary database directory. %s.", err)
+		}
+		defer os.RemoveAll(dir)
+
+		cfg.DBPath = filepath.Join(dir, "kquiz.db")
+		log.Println("Storing the data in memory, it is lost at exit.")
+	}
+
+	// Restoring replaces the databases with the backup before they are opened.
+	if len(*restore) != 0 {
+		if !cfg.Backup.Enabled() {
+			log.Println("Failed to restore backup. No backup directory nor endpoint is configured.")
+			return
+		}
+
+		info, err := backup.Restore(backup.NewTarget(cfg.Backup), *restore, cfg.DBPath)
+		if err != nil {
+			log.Printf("Failed to restore backup %s. %s.", *restore, err)
+			return
+		}
+
+		log.Printf("Restored %d shards from backup %s, %d bytes.\n", info.Shards, info.Name, info.Size)
+
+		// The words and the users kept in SQLite come back along with the shards.
+		if cfg.Storage == "sqlite" {
+			size, err := backup.RestoreIncluded(backup.NewTarget(cfg.Backup), info.Name, sqliteBackupName,
+				cfg.SQLitePath)
+			if err != nil {
+				log.Printf("Failed to restore SQL database from backup %s. %s.", info.Name, err)
+				return
+			}
+
+			log.Printf("Restored SQL database from backup %s, %d bytes.\n", info.Name, size)
+		}
+	}
+
+	// Large deployments spread the users across several database files, the first one also storing the shared data.
+	shards, err := telegram.OpenShards(cfg.DBPath, cfg.DBShards)
+	if err != nil {
+		log.Printf("Failed to open database. %s.", err)
+		return
+	}
+	defer func() {
+		log.Println("Closing database.")
+		err = shards.Close()
+		if err != nil {
+			log.Printf("Failed to close database. %s.", err)
+		}
+	}()
+
+	db := shards.Main()
+
+	// The journal narrows the data loss window between backups, the changes it holds can be replayed with kquizctl.
+	var journal *telegram.Journal
+	if len(cfg.JournalPath) != 0 {
+		journal, err = telegram.OpenJournal(cfg.JournalPath)
+		if err != nil {
+			log.Printf("Failed to open journal. %s.", err)
+			return
+		}
+		defer journal.Close()
+	}
+
+	// Let's create our buckets first if not exist. The kquiz bucket stores the words, the telegram bucket stores our
+	// Telegram bot registrants, the deck bucket stores the decks of the users, the relation bucket indexes the words
+	// sharing hanja or stems, the pronunciation bucket stores the scored speaking attempts, the settings bucket stores
+	// the preferences of the users, the activity bucket their study streaks, the pending bucket the questions they are
+	// to answer, the stats bucket the counters of their studying, the transcript bucket the questions they answered, the
+	// snapshot bucket the weekly snapshots of their words, the session bucket the quiz sessions they are in and the
+	// recent bucket the words they were just asked. These are owned by the users and exist in every shard.
+	for _, bucketName := range []string{cfg.Buckets.Kquiz, cfg.Buckets.Telegram, cfg.Buckets.Deck, cfg.Buckets.Relation,
+		cfg.Buckets.Pronunciation, cfg.Buckets.Settings, cfg.Buckets.Activity, cfg.Buckets.Pending,
+		cfg.Buckets.Stats, cfg.Buckets.Transcript, cfg.Buckets.Snapshot, cfg.Buckets.Session, cfg.Buckets.Recent} {
+		err = shards.Update(func(tx *bbolt.Tx) error {
+			_, err := tx.CreateBucketIfNotExists([]byte(bucketName))
+			return err
+		})
+		if err != nil {
+			log.Printf("Failed to create bucket %s. %s.\n", bucketName, err)
+			return
+		}
+	}
+
+	// The export bucket stores the temporary export links, the cache bucket stores the responses of the external
+	// providers, the usage bucket counts the daily calls to them, the channel bucket stores the word of the day
+	// published to the channel, the content bucket its upcoming posts, the analytics bucket counts the events the
+	// operators learn from, the audio bucket stores the synthesised speech, the template bucket the class templates and
+	// the leaderboard bucket the users who opted in to the leaderboard, the access bucket the usernames and the access
+	// the users grant their tutors, the token bucket the API tokens adding words and the ban bucket the chats the
+	// admins banned. These are shared and exist in the main database.
+	for _, bucketName := range []string{cfg.Buckets.Export, cfg.Buckets.Cache, cfg.Buckets.Usage, cfg.Buckets.Channel,
+		cfg.Buckets.Content, cfg.Buckets.Analytics, cfg.Buckets.Audio, cfg.Buckets.Template, cfg.Buckets.Leaderboard,
+		cfg.Buckets.Access, cfg.Buckets.Token, cfg.Buckets.Ban} {
+		err = db.Update(func(tx *bbolt.Tx) error {
+			_, err := tx.CreateBucketIfNotExists([]byte(bucketName))
+			return err
+		})
+		if err != nil {
+			log.Printf("Failed to create bucket %s. %s.\n", bucketName, err)
+			return
+		}
+	}
+
+	// Let's prepare our Telegram bot
+	tgBot, err := tgbotapi.NewBotAPI(cfg.TelegramToken)
+	if err != nil {
+		log.Printf("Failed to create telegram bot. %s.", err)
+		return
+	}
+
+	// The messages are queued within the rate limits of Telegram, so that the bulk operations do not lose any, and the
+	// uploads show their chat action. The calls failing are counted for the dashboard.
+	liveMetrics := metrics.New()
+	messages := outbox.New(meteredSender{sender: tgBot, metrics: liveMetrics})
+	botAPI := actionSender{sender: queuedSender{sender: tgBot, outbox: messages}, client: tgBot}
+
+	hanjaDict, err := hanja.NewDictionary()
+	if err != nil {
+		log.Printf("Failed to load hanja dictionary. %s.", err)
+		return
+	}
+
+	// The features relying on external services are disabled when their provider is not configured.
+	registry, err := providers.NewRegistry(cfg.Providers)
+	if err != nil {
+		log.Printf("Failed to configure providers. %s.", err)
+		return
+	}
+
+	// Once a service spends its daily budget, the features using it fail until the next day and the admins are told.
+	usageStore := telegram.NewUsageStore(db, cfg.Buckets.Usage)
+	budget := providers.NewBudget(usageStore, cfg.Costs, cfg.DailyBudgets, func(service string, limit float64) {
+		respond(botAPI, "budget alert", adminAlerts(admins, fmt.Sprintf("The daily %s budget of %.2f USD is spent, "+
+			"it is disabled until tomorrow.", service, limit))...)
+	})
+	registry.UseBudget(budget)
+
+	// Many users add the same common words, let's not pay twice for their translations and definitions.
+	responseCache := telegram.NewResponseCache(db, cfg.Buckets.Cache)
+	registry.UseCache(responseCache, time.Duration(cfg.TranslationCacheTTL), time.Duration(cfg.DictionaryCacheTTL))
+	audioCache := telegram.NewAudioCache(db, cfg.Buckets.Audio, audioCacheSize)
+
+	featureFlags := features.New(registry, cfg.Offline, cfg.DisabledFeatures)
+
+	rootFinder := roots.NewFinder(hanjaDict, registry.Analyzer)
+	var users storage.UserRepository
+	var words storage.WordRepository
+	// The SQLite database is backed up along with the shards, the backups would miss the words otherwise.
+	var sqliteSnapshot backup.Snapshot
+	switch cfg.Storage {
+	case "memory":
+		repo := storage.NewMemoryRepository(rootFinder)
+		users, words = repo, repo
+	case "sqlite":
+		repo, err := storage.OpenSQLRepository(cfg.SQLitePath, rootFinder)
+		if err != nil {
+			log.Printf("Failed to open SQL database. %s.\n", err)
+			return
+		}
+		defer func() {
+			err := repo.Close()
+			if err != nil {
+				log.Printf("Failed to close SQL database. %s.", err)
+			}
+		}()
+
+		users, words = repo, repo
+		sqliteSnapshot = repo.Snapshot
+	default:
+		boltRepository := storage.NewBoltRepository(shards, cfg.Buckets.Telegram, cfg.Buckets.Kquiz,
+			cfg.Buckets.Relation, rootFinder, journal)
+		if !migrate(shards, cfg, boltRepository) {
+			return
+		}
+
+		users, words = boltRepository, boltRepository
+	}
+
+	// Schools and other deployments may refuse the words containing inappropriate language.
+	var adder storage.Adder = words
+	var updater storage.Updater = words
+	if cfg.ProfanityFilter {
+		moderated := moderation.NewAdder(words, words, moderation.NewFilter(cfg.ProfanityWords))
+		adder = moderated
+		updater = moderated
+	}
+
+	// The words added are counted in the stats of the users, whichever way they are added.
+	statsStore := telegram.NewStatsStore(shards, cfg.Buckets.Stats, journal)
+	adder = storage.NewCountingAdder(adder, statsStore)
+
+	// The power users get their events, e.g. the words added, on their own webhook.
+	settingsStore := telegram.NewSettingsStore(shards, cfg.Buckets.Settings, journal)
+	emitter := events.NewEmitter(settingsStore)
+	adder = events.NewAdder(adder, emitter)
+
+	// The teachers pull the completed class assignments into their gradebook.
+	var reporters gradebook.Reporters
+	if len(cfg.CompletionWebhookURL) != 0 {
+		reporters = append(reporters, gradebook.NewWebhook(cfg.CompletionWebhookURL))
+	}
+	if len(cfg.CompletionCSV) != 0 {
+		reporters = append(reporters, gradebook.NewCSV(cfg.CompletionCSV))
+	}
+
+	var font []byte
+	if len(cfg.Font) != 0 {
+		font, err = os.ReadFile(cfg.Font)
+		if err != nil {
+			log.Printf("Failed to read font. %s.", err)
+			return
+		}
+	}
+
+	exportLinks := telegram.NewExportLinkStore(db, cfg.Buckets.Export)
+	// The words of the decks are counted through the repository, whichever backend stores them.
+	deckStore := storage.NewDeckCounter(telegram.NewDeckStore(shards, cfg.Buckets.Deck, journal), words)
+	pronunciationStore := telegram.NewPronunciationStore(shards, cfg.Buckets.Pronunciation, journal)
+	transcriptStore := telegram.NewTranscriptStore(shards, cfg.Buckets.Transcript, journal)
+	snapshotStore := telegram.NewSnapshotStore(shards, cfg.Buckets.Snapshot, journal)
+	pendingStore := telegram.NewPendingStore(shards, cfg.Buckets.Pending, time.Duration(cfg.PendingQuestionTTL))
+	sessionStore := telegram.NewSessionStore(shards, cfg.Buckets.Session)
+
+	// The ring buffer of the recent words holds at least the last questions of the cooldown.
+	recentSize := recentQuestionsSize
+	if cfg.CooldownQuestions > recentSize {
+		recentSize = cfg.CooldownQuestions
+	}
+	recentStore := telegram.NewRecentStore(shards, cfg.Buckets.Recent, recentSize)
+	activityStore := telegram.NewActivityStore(shards, cfg.Buckets.Activity, journal)
+	leaderboardStore := telegram.NewLeaderboardStore(db, cfg.Buckets.Leaderboard)
+	accessStore := telegram.NewAccessStore(db, cfg.Buckets.Access)
+	tokenStore := telegram.NewTokenStore(db, cfg.Buckets.Token)
+	banStore := telegram.NewBanStore(db, cfg.Buckets.Ban)
+	analyticsStore := telegram.NewAnalyticsStore(db, cfg.Buckets.Analytics, cfg.AnalyticsKey())
+	channelStore := telegram.NewChannelStore(db, cfg.Buckets.Channel, cfg.Buckets.Content)
+
+	// Let's start our HTTP server serving the web pages.
+	httpServer := web.NewServer(cfg.HTTPAddr)
+	httpServer.Handle("/export/", web.NewExportHandler(exportLinks, words))
+	httpServer.Handle("/api/words", web.NewWordsHandler(tokenStore, adder, words, words))
+	if featureFlags.Enabled(features.Publishing) {
+		httpServer.Handle("/feed.xml", web.NewFeedHandler(channelStore, cfg.PublicURL))
+	}
+
+	// Backups can be taken remotely with kquizctl while the bot stays live, only when an admin token is configured.
+	if len(cfg.AdminToken) != 0 {
+		httpServer.Handle("/admin/backup", web.NewBackupHandler(shards, cfg.AdminToken, sqliteSnapshot != nil))
+	}
+
+	poller := handoff.NewPoller(tgBot, updateOffset)
+	drained := make(chan struct{})
+	handedOff := make(chan struct{})
+
+	// A new instance takes over by draining this one, only when an admin token is configured. In webhook mode,
+	// Telegram posts the updates to whichever instance is up, there is nothing to hand over.
+	if len(cfg.AdminToken) != 0 && cfg.UpdateMode == "polling" {
+		httpServer.Handle("/admin/handoff", web.NewHandoffHandler(cfg.AdminToken, func() int {
+			offset := poller.Drain()
+			<-drained
+			close(handedOff)
+
+			log.Printf("Handing off at update %d.\n", offset)
+			return offset
+		}))
+	}
+	httpServer.Start()
+	defer httpServer.Shutdown(10 * time.Second)
+
+	// Let's remind the users of their reviews, checking the review times of the users every minute. The banned chats
+	// get no messages from the bot.
+	sched := scheduler.New(time.Minute)
+	audience := unbannedAudience{Audience: users, bans: banStore}
+	// A second review message makes an A/B test, splitting the users between both messages.
+	reviewExperiment := nudge.Experiment{Name: "reviews", Templates: []string{cfg.ReviewMessage}}
+	if len(cfg.ReviewMessageB) != 0 {
+		reviewExperiment.Templates = append(reviewExperiment.Templates, cfg.ReviewMessageB)
+	}
+	sched.Add("reviews", reviewPushes(audience, activityStore, words, settingsStore, analyticsStore, botAPI,
+		reviewExperiment))
+	// The janitor cleans the transient messages of the bot up in the groups asking for it.
+	messageJanitor := janitor.New(botAPI)
+	messageJanitor.Retain("command log", analyticsStore.PruneCommandLog, time.Duration(cfg.CommandLogRetention))
+	messageJanitor.Retain("daily command counts", analyticsStore.PruneDailyCounts,
+		time.Duration(cfg.DailyRetention))
+	messageJanitor.Retain("usage", usageStore.PruneUsage, time.Duration(cfg.DailyRetention))
+	messageJanitor.Retain("pending question", pendingStore.PrunePending, time.Duration(cfg.PendingQuestionTTL))
+	messageJanitor.Retain("quiz session", sessionStore.PruneSessions, quiz.SessionTimeout)
+	messageJanitor.Retain("transcript", transcriptStore.PruneTranscripts, time.Duration(cfg.TranscriptRetention))
+	messageJanitor.Retain("snapshot", snapshotStore.PruneSnapshots, time.Duration(cfg.SnapshotRetention))
+	sched.Add("janitor", messageJanitor.Sweep)
+	sched.Add("daily quiz", dailyQuizzes(audience, words, settingsStore, pendingStore, quizEngine,
+		botAPI, font))
+	sched.Add("win-back", winBack(audience, activityStore, words, settingsStore, analyticsStore, botAPI,
+		time.Duration(cfg.WinBackAfter), cfg.WinBackMessage))
+	sched.Add("snapshots", snapshotWords(audience, words, snapshotStore))
+	sched.Add("resurface", resurfaceMastered(audience, words, words, settingsStore))
+
+	// The backups are taken periodically when a target is configured, and on demand by the admins.
+	var backups *backup.Backups
+	if cfg.Backup.Enabled() {
+		backups = backup.New(shards, backup.NewTarget(cfg.Backup), cfg.Backup.Keep)
+		if sqliteSnapshot != nil {
+			backups.Include(sqliteBackupName, sqliteSnapshot)
+		}
+		sched.Add("backup", backups.Job(time.Duration(cfg.BackupInterval)))
+	}
+
+	// The word of the day is read aloud when text-to-speech is enabled.
+	var channelTTS providers.TextToSpeech
+	if featureFlags.Enabled(features.TTS) {
+		channelTTS = registry.TTS
+		sched.Add("daily podcast", dailyPodcasts(audience, words, settingsStore, audioCache, registry.TTS,
+			botAPI, cfg.TranslationLanguage))
+	}
+	if featureFlags.Enabled(features.Publishing) {
+		sched.Add("word of the day", wordOfTheDay(channelStore, words, channelTTS, botAPI, cfg.WordOfTheDayTime,
+			func(text string) {
+				respond(botAPI, "word of the day alert", adminAlerts(admins, text)...)
+			}))
+	}
+	sched.Start(ctx)
+	defer sched.Stop()
+
+	// The operators watch the live metrics on the dashboard, only when an admin token is configured.
+	if len(cfg.AdminToken) != 0 {
+		httpServer.Handle("/admin/dashboard", web.NewDashboardHandler(cfg.AdminToken, func(now time.Time) web.Dashboard {
+			return dashboard(liveMetrics, messages, messageJanitor, sched, backups, now)
+		}))
+	}
+
+	// New users are quizzed on the sample deck right after the registration, unless the onboarding sample is off.
+	var sampleDeck []telegram.Entry
+	if cfg.OnboardingSample {
+		sampleDeck, err = quiz.SampleDeck()
+		if err != nil {
+			log.Printf("Failed to load sample deck. %s.", err)
+			return
+		}
+	}
+
+	d := &dispatcher{
+		bot:                 botAPI,
+		bulk:                messages,
+		botName:             tgBot.Self.UserName,
+		botID:               tgBot.Self.ID,
+		users:               users,
+		words:               words,
+		adder:               adder,
+		updater:             updater,
+		hanjaDict:           hanjaDict,
+		rootFinder:          rootFinder,
+		registry:            registry,
+		featureFlags:        featureFlags,
+		admins:              admins,
+		quizEngine:          quizEngine,
+		reviewScheduler:     quiz.NewScheduler(time.Duration(cfg.MaxReviewInterval)),
+		pending:             pendingStore,
+		sessionStore:        sessionStore,
+		recent:              recentStore,
+		cooldown:            quiz.Cooldown{Questions: cfg.CooldownQuestions, Window: time.Duration(cfg.CooldownWindow)},
+		grader:              quiz.NewGrader(cfg.TypoTolerance),
+		sessions:            make(map[int64]*quiz.Session),
+		reveals:             make(map[int64]reveal),
+		recaps:              make(map[int64]*recap),
+		notePrompts:         make(map[int64]notePrompt),
+		cleanups:            make(map[int64]*cleanup),
+		conflicts:           make(map[int64]*conflictReview),
+		lemmaPrompts:        make(map[int64]lemmaPrompt),
+		sampleDeck:          sampleDeck,
+		privacyVersion:      cfg.PrivacyVersion,
+		privacyNotice:       cfg.PrivacyNotice,
+		channelStore:        channelStore,
+		wordOfTheDayTime:    cfg.WordOfTheDayTime,
+		settingsStore:       settingsStore,
+		activityStore:       activityStore,
+		statsStore:          statsStore,
+		leaderboard:         leaderboardStore,
+		access:              accessStore,
+		tokens:              tokenStore,
+		bans:                banStore,
+		events:              emitter,
+		analyticsStore:      analyticsStore,
+		janitor:             messageJanitor,
+		pronunciationStore:  pronunciationStore,
+		transcriptStore:     transcriptStore,
+		snapshotStore:       snapshotStore,
+		deckStore:           deckStore,
+		exportLinks:         exportLinks,
+		templateStore:       telegram.NewTemplateStore(db, cfg.Buckets.Template),
+		gradebook:           reporters,
+		publicURL:           cfg.PublicURL,
+		exportLinkTTL:       time.Duration(cfg.ExportLinkTTL),
+		font:                font,
+		translationLanguage: cfg.TranslationLanguage,
+		responseCache:       responseCache,
+		audioCache:          audioCache,
+		audioCacheSize:      audioCacheSize,
+		usageStore:          usageStore,
+		metrics:             liveMetrics,
+		budget:              budget,
+		backups:             backups,
+	}
+
+	// Let's receive the updates by long polling, or on the webhook when the bot runs behind a load balancer.
+	var incoming <-chan updates.Update
+	webhook := web.NewWebhookHandler()
+	if cfg.UpdateMode == "webhook" {
+		webhookServer := web.NewServer(cfg.WebhookAddr)
+		webhookServer.Handle(cfg.WebhookPath(), webhook)
+		if len(cfg.WebhookCert) != 0 {
+			webhookServer.StartTLS(cfg.WebhookCert, cfg.WebhookKey)
+		} else {
+			webhookServer.Start()
+		}
+		defer webhookServer.Shutdown(10 * time.Second)
+
+		// The client predates the allowed updates of the webhooks, hence, the request is made directly.
+		params := map[string]string{
+			"url":             strings.TrimSuffix(cfg.WebhookURL, "/") + cfg.WebhookPath(),
+			"allowed_updates": updates.AllowedUpdatesParam(),
+		}
+		if cfg.WebhookSelfSigned {
+			_, err = tgBot.UploadFile("setWebhook", params, "certificate", cfg.WebhookCert)
+		} else {
+			values := url.Values{}
+			for key, value := range params {
+				values.Set(key, value)
+			}
+
+			_, err = tgBot.MakeRequest("setWebhook", values)
+		}
+		if err != nil {
+			log.Printf("Failed to set webhook. %s.", err)
+			return
+		}
+
+		incoming = webhook.Updates()
+	} else {
+		// Telegram refuses to be polled while a webhook is set, e.g. after switching back from the webhook mode.
+		_, err = tgBot.RemoveWebhook()
+		if err != nil {
+			log.Printf("Failed to remove webhook. %s.\n", err)
+		}
+
+		incoming = poller.Start(ctx)
+	}
+
+	// Listen to Telegram updates
+	go func() {
+		defer close(drained)
+
+		for update := range incoming {
+			d.dispatch(update)
+		}
+	}()
+
+	// Block until a signal is received or a new instance took over.
+	select {
+	case <-ctx.Done():
+	case <-handedOff:
+	}
+
+	// A second signal kills the bot right away, e.g. when the shutdown hangs.
+	stop()
+	log.Println("Shutting down.")
+
+	// The poller stopped with the context, the updates posted to the webhook meanwhile are refused for Telegram to
+	// deliver them again. Let's finish the one in progress.
+	if cfg.UpdateMode == "webhook" {
+		webhook.Close()
+	}
+	<-drained
+
+	// The jobs running finish, then the messages still queued are sent before the database closes.
+	sched.Stop()
+
+	flushCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	err = messages.Flush(flushCtx)
+	if err != nil {
+		log.Printf("Failed to send the queued messages. %s.\n", err)
+	}
 }
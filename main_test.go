@@ -0,0 +1,187 @@
+package main
+
+import (
+	"fmt"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+	"github.com/handracs2007/kquiz/hanja"
+	"github.com/handracs2007/kquiz/quiz"
+	"github.com/handracs2007/kquiz/storage"
+	"github.com/handracs2007/kquiz/telegram"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testChatID = int64(12345)
+
+// registeredRepository returns a repository in memory with the test user registered.
+func registeredRepository(t *testing.T) storage.MemoryRepository {
+	repo := storage.NewMemoryRepository(nil)
+	if err := repo.Register(testChatID); err != nil {
+		t.Fatal(err)
+	}
+
+	return repo
+}
+
+func TestRegisterUser(t *testing.T) {
+	repo := storage.NewMemoryRepository(nil)
+
+	reply, registered := registerUser(repo, testChatID)
+	if text := replyText(reply); !registered || text != "Thanks for your registration." {
+		t.Fatalf("registerUser() = %q, %t, want the thanks, true", text, registered)
+	}
+
+	// Registering again fails, but the user is registered all the same.
+	reply, registered = registerUser(repo, testChatID)
+	want := fmt.Sprintf("Registration failed. %s.", telegram.ErrAlreadyRegistered)
+	if text := replyText(reply); !registered || text != want {
+		t.Fatalf("registerUser() = %q, %t, want %q, true", text, registered, want)
+	}
+}
+
+func TestAddWord(t *testing.T) {
+	repo := registeredRepository(t)
+
+	if text := replyText(addWord(repo, testChatID, "학교", "school", nil)); text !=
+		"New word successfully added. 학교 -> school." {
+		t.Fatalf("addWord() = %q", text)
+	}
+
+	if text := replyText(addWord(repo, testChatID, "버스", "bus", []string{"교통", "topik1"})); text !=
+		"New word successfully added. 버스 -> bus, tagged #교통 #topik1." {
+		t.Fatalf("addWord() with tags = %q", text)
+	}
+
+	want := fmt.Sprintf("Add word failed. %s.", telegram.ErrDuplicateWord)
+	if text := replyText(addWord(repo, testChatID, "학교", "schools", nil)); text != want {
+		t.Fatalf("addWord() of a duplicate = %q, want %q", text, want)
+	}
+
+	// The replies go to the user who sent the command.
+	reply := addWord(repo, testChatID, "사과", "apple", nil).(tgbotapi.MessageConfig)
+	if reply.ChatID != testChatID {
+		t.Fatalf("addWord() replies to %d, want %d", reply.ChatID, testChatID)
+	}
+}
+
+func TestSearchWord(t *testing.T) {
+	dict, err := hanja.NewDictionary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	repo := registeredRepository(t)
+	addWord(repo, testChatID, "학교", "school", nil)
+
+	reply := searchWord(repo, dict, testChatID, "학교").(tgbotapi.MessageConfig)
+	if reply.Text != "학교 -> school.\nAdded by hand." {
+		t.Fatalf("searchWord() = %q", reply.Text)
+	}
+
+	// The Sino-Korean words offer their hanja breakdown.
+	keyboard, ok := reply.ReplyMarkup.(tgbotapi.InlineKeyboardMarkup)
+	if !ok || *keyboard.InlineKeyboard[0][0].CallbackData != hanjaCallbackPrefix+"학교" {
+		t.Fatalf("searchWord() markup = %+v, want the hanja button", reply.ReplyMarkup)
+	}
+
+	want := fmt.Sprintf("Search word failed. %s.", telegram.ErrWordNotFound)
+	if text := replyText(searchWord(repo, dict, testChatID, "버스")); text != want {
+		t.Fatalf("searchWord() of a missing word = %q, want %q", text, want)
+	}
+}
+
+func TestDeleteAndClearWords(t *testing.T) {
+	repo := registeredRepository(t)
+	addWord(repo, testChatID, "학교", "school", nil)
+	addWord(repo, testChatID, "버스", "bus", nil)
+
+	if text := replyText(deleteWord(repo, testChatID, "학교")); text != "학교 deleted." {
+		t.Fatalf("deleteWord() = %q", text)
+	}
+
+	want := fmt.Sprintf("Delete word failed. %s.", telegram.ErrWordNotFound)
+	if text := replyText(deleteWord(repo, testChatID, "학교")); text != want {
+		t.Fatalf("deleteWord() of a missing word = %q, want %q", text, want)
+	}
+
+	if text := replyText(clearWords(repo, testChatID)); text != "Words cleared." {
+		t.Fatalf("clearWords() = %q", text)
+	}
+
+	if repo.IsAdded(testChatID, "버스") {
+		t.Fatal("clearWords() kept 버스")
+	}
+}
+
+func TestListWords(t *testing.T) {
+	repo := registeredRepository(t)
+	addWord(repo, testChatID, "학교", "school", nil)
+	addWord(repo, testChatID, "버스", "bus", []string{"교통"})
+
+	reply := listWords(repo, testChatID, "").(tgbotapi.MessageConfig)
+	if reply.ParseMode != tgbotapi.ModeHTML || !strings.Contains(reply.Text, "학교 -&gt; school") ||
+		!strings.Contains(reply.Text, "#교통") {
+		t.Fatalf("listWords() = %q", reply.Text)
+	}
+
+	if text := replyText(listWords(repo, testChatID, "교통")); strings.Contains(text, "학교") {
+		t.Fatalf("listWords() of #교통 = %q, want 버스 only", text)
+	}
+
+	// A short list is not paged through.
+	if reply.ReplyMarkup != nil {
+		t.Fatalf("listWords() markup = %+v, want none", reply.ReplyMarkup)
+	}
+}
+
+func TestStartMistakes(t *testing.T) {
+	repo := registeredRepository(t)
+	addWord(repo, testChatID, "학교", "school", nil)
+
+	session := &quiz.Session{LastAnswer: time.Now()}
+	reply, ok := startMistakes(repo, testChatID, session)
+	if text := replyText(reply); ok || text != "Your mistake notebook is empty, well done!" {
+		t.Fatalf("startMistakes() = %q, %t, want the empty notebook, false", text, ok)
+	}
+
+	err := repo.Review(testChatID, "학교", func(entry telegram.WordEntry) telegram.WordEntry {
+		return quiz.TrackMistake(entry, false, time.Now())
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reply, ok = startMistakes(repo, testChatID, session)
+	if text := replyText(reply); !ok || text != "Let's go through your 1 mistakes." || len(session.Queue) != 1 {
+		t.Fatalf("startMistakes() = %q, %t, queued %d, want the mistake queued", text, ok, len(session.Queue))
+	}
+}
+
+func TestAnswerQuestion(t *testing.T) {
+	question := quiz.Question{Kind: quiz.KindTranslation, Prompt: "What is 학교?", Answer: "school", Word: "학교"}
+
+	session := &quiz.Session{LastAnswer: time.Now()}
+	reply, pending, revealed := answerQuestion(quiz.NewGrader(1), testChatID, question, "school", session,
+		telegram.Settings{NoCombos: true})
+	if text := replyText(reply); text != "Your answer is correct" || pending != nil || revealed {
+		t.Fatalf("answerQuestion() = %q, %v, %t, want the correct answer", text, pending, revealed)
+	}
+
+	// The missed words are revealed, to be graded by reacting to the reply.
+	reply, pending, revealed = answerQuestion(quiz.NewGrader(1), testChatID, question, "bus", session,
+		telegram.Settings{NoCombos: true})
+	if text := replyText(reply); !strings.HasPrefix(text, "Your answer is incorrect. Correct answer is school.") ||
+		pending != nil || !revealed {
+		t.Fatalf("answerQuestion() = %q, %v, %t, want the answer revealed", text, pending, revealed)
+	}
+}
+
+func TestTakeBackup(t *testing.T) {
+	fake := &fakeSender{}
+
+	want := "Backups are not configured, set the backup directory or endpoint."
+	if text := replyText(takeBackup(nil, fake, testChatID)); text != want {
+		t.Fatalf("takeBackup() = %q, want %q", text, want)
+	}
+}
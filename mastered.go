@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+	"github.com/handracs2007/kquiz/quiz"
+	"github.com/handracs2007/kquiz/scheduler"
+	"github.com/handracs2007/kquiz/storage"
+	"github.com/handracs2007/kquiz/telegram"
+	"log"
+	"strings"
+	"time"
+)
+
+// resurfaceInterval is how often the mastered words are brought back into the reviews.
+const resurfaceInterval = 7 * 24 * time.Hour
+
+// maxResurfacePerWeek caps the number of mastered words brought back every week.
+const maxResurfacePerWeek = 20
+
+// masteredListSize is the number of mastered words listed, the others are only counted.
+const masteredListSize = 50
+
+// showMastered lists the words the user mastered, retired from the quizzes, and how many come back every week.
+func showMastered(lister storage.Lister, manager telegram.SettingsManager, chatID int64) tgbotapi.Chattable {
+	var msg tgbotapi.MessageConfig
+	settings, err := manager.Settings(chatID)
+	if err != nil {
+		log.Printf("Failed to read settings, using the defaults. %s.\n", err)
+	}
+
+	entries, err := userEntries(lister, chatID)
+	mastered := quiz.Mastered(entries)
+	if err != nil {
+		msg = tgbotapi.NewMessage(chatID, fmt.Sprintf("Get mastered words failed. %s.", err))
+	} else if len(mastered) == 0 {
+		msg = tgbotapi.NewMessage(chatID, fmt.Sprintf("You have not mastered any word yet. A word is mastered once "+
+			"answered correctly %d times in a row over ever longer intervals, then it is no longer quizzed.",
+			quiz.MasteryRepetitions))
+	} else {
+		lines := []string{fmt.Sprintf("Mastered words, %d of %d. They are no longer quizzed.", len(mastered),
+			len(entries))}
+		if settings.ResurfacePerWeek > 0 {
+			lines[0] += fmt.Sprintf(" %d come back into your reviews every week, /mastered resurface off to stop.",
+				settings.ResurfacePerWeek)
+		} else {
+			lines[0] += " Bring a few back every week with /mastered resurface <count>."
+		}
+
+		lines = append(lines, "")
+		for i, entry := range mastered {
+			if i == masteredListSize {
+				lines = append(lines, fmt.Sprintf("... and %d more.", len(mastered)-masteredListSize))
+				break
+			}
+
+			lines = append(lines, fmt.Sprintf("%s -> %s", entry.Word, entry.Translation))
+		}
+
+		msg = tgbotapi.NewMessage(chatID, strings.Join(lines, "\n"))
+	}
+
+	return msg
+}
+
+func setResurface(manager telegram.SettingsManager, chatID int64, count int) tgbotapi.Chattable {
+	var msg tgbotapi.MessageConfig
+	settings, err := manager.Settings(chatID)
+	if err == nil {
+		settings.ResurfacePerWeek = count
+		err = manager.SaveSettings(chatID, settings)
+	}
+
+	if err != nil {
+		msg = tgbotapi.NewMessage(chatID, fmt.Sprintf("Save settings failed. %s.", err))
+	} else if count == 0 {
+		msg = tgbotapi.NewMessage(chatID, "Your mastered words stay retired.")
+	} else {
+		msg = tgbotapi.NewMessage(chatID, fmt.Sprintf("%d mastered words will come back into your reviews every "+
+			"week, the ones mastered the longest ago first.", count))
+	}
+
+	return msg
+}
+
+// resurfaceMastered brings a few mastered words of the users who asked for it back into their reviews every week,
+// checking once an hour. Those answered correctly are mastered again, the others are learnt again.
+func resurfaceMastered(audience storage.Audience, lister storage.Lister, reviewer storage.Reviewer,
+	manager telegram.SettingsManager) scheduler.Job {
+	return func(now time.Time) {
+		if now.Minute() != 0 {
+			return
+		}
+
+		users, err := audience.Users()
+		if err != nil {
+			log.Printf("Failed to list users for resurfacing. %s.\n", err)
+			return
+		}
+
+		for _, chatID := range users {
+			settings, err := manager.Settings(chatID)
+			if err != nil || settings.ResurfacePerWeek <= 0 ||
+				settings.ResurfacedAt != nil && now.Sub(*settings.ResurfacedAt) < resurfaceInterval {
+				continue
+			}
+
+			entries, err := userEntries(lister, chatID)
+			if err != nil {
+				log.Printf("Failed to list words for resurfacing. %s.\n", err)
+				continue
+			}
+
+			mastered := quiz.Mastered(entries)
+			if len(mastered) > settings.ResurfacePerWeek {
+				mastered = mastered[:settings.ResurfacePerWeek]
+			}
+
+			for _, entry := range mastered {
+				err = reviewer.Review(chatID, entry.Word, func(entry telegram.WordEntry) telegram.WordEntry {
+					return quiz.Resurface(entry, now)
+				})
+				if err != nil {
+					log.Printf("Failed to resurface %s. %s.\n", entry.Word, err)
+				}
+			}
+
+			settings.ResurfacedAt = &now
+			err = manager.SaveSettings(chatID, settings)
+			if err != nil {
+				log.Printf("Failed to save settings. %s.\n", err)
+			}
+		}
+	}
+}
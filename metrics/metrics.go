@@ -0,0 +1,136 @@
+// Package metrics keeps the live metrics of the bot in memory for the operators: the users active today, the commands
+// used and the calls to Telegram that failed over the last minutes. They start over when the bot restarts.
+package metrics
+
+import (
+	"sync"
+	"time"
+)
+
+// windowMinutes is the number of minutes the rates are kept for.
+const windowMinutes = 60
+
+// rateMinutes is the number of minutes the commands per minute are averaged over.
+const rateMinutes = 5
+
+// dayLayout is the layout of the days the active users are counted on, in UTC.
+const dayLayout = "2006-01-02"
+
+// minute counts what happened within a minute.
+type minute struct {
+	at       int64
+	commands int
+	calls    int
+	failures int
+}
+
+// Metrics counts what the bot does. It is safe for concurrent use.
+type Metrics struct {
+	mutex   sync.Mutex
+	minutes [windowMinutes]minute
+	day     string
+	active  map[int64]bool
+}
+
+// New creates a new instance of Metrics
+func New() *Metrics {
+	return &Metrics{active: make(map[int64]bool)}
+}
+
+// at returns the counts of the minute of the given time, reset when it was last used for an older minute. The counts
+// of a minute already out of the window are thrown away. The mutex must be held.
+func (metrics *Metrics) at(now time.Time) *minute {
+	unix := now.Unix() / 60
+	current := &metrics.minutes[unix%windowMinutes]
+	if current.at > unix {
+		return &minute{}
+	}
+
+	if current.at != unix {
+		*current = minute{at: unix}
+	}
+
+	return current
+}
+
+// RecordUser counts the user as active on the day of the given time.
+func (metrics *Metrics) RecordUser(userID int64, now time.Time) {
+	metrics.mutex.Lock()
+	defer metrics.mutex.Unlock()
+
+	if day := now.UTC().Format(dayLayout); day != metrics.day {
+		metrics.day = day
+		metrics.active = make(map[int64]bool)
+	}
+
+	metrics.active[userID] = true
+}
+
+// RecordCommand counts a command used at the given time.
+func (metrics *Metrics) RecordCommand(now time.Time) {
+	metrics.mutex.Lock()
+	defer metrics.mutex.Unlock()
+
+	metrics.at(now).commands++
+}
+
+// RecordCall counts a call to Telegram made at the given time, and whether it failed.
+func (metrics *Metrics) RecordCall(failed bool, now time.Time) {
+	metrics.mutex.Lock()
+	defer metrics.mutex.Unlock()
+
+	current := metrics.at(now)
+	current.calls++
+	if failed {
+		current.failures++
+	}
+}
+
+// ActiveUsers returns the number of users active on the day of the given time, in UTC.
+func (metrics *Metrics) ActiveUsers(now time.Time) int {
+	metrics.mutex.Lock()
+	defer metrics.mutex.Unlock()
+
+	if now.UTC().Format(dayLayout) != metrics.day {
+		return 0
+	}
+
+	return len(metrics.active)
+}
+
+// sum returns the counts of the given number of minutes up to the one of the given time, included. The mutex must be
+// held.
+func (metrics *Metrics) sum(now time.Time, minutes int) minute {
+	var total minute
+	unix := now.Unix() / 60
+	for _, counts := range metrics.minutes {
+		if counts.at <= unix && counts.at > unix-int64(minutes) {
+			total.commands += counts.commands
+			total.calls += counts.calls
+			total.failures += counts.failures
+		}
+	}
+
+	return total
+}
+
+// CommandsPerMinute returns the average number of commands used per minute over the last minutes.
+func (metrics *Metrics) CommandsPerMinute(now time.Time) float64 {
+	metrics.mutex.Lock()
+	defer metrics.mutex.Unlock()
+
+	return float64(metrics.sum(now, rateMinutes).commands) / rateMinutes
+}
+
+// ErrorRate returns the share of the calls to Telegram that failed over the last hour, from 0 to 1.
+func (metrics *Metrics) ErrorRate(now time.Time) float64 {
+	metrics.mutex.Lock()
+	defer metrics.mutex.Unlock()
+
+	total := metrics.sum(now, windowMinutes)
+	if total.calls == 0 {
+		return 0
+	}
+
+	return float64(total.failures) / float64(total.calls)
+}
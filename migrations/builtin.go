@@ -0,0 +1,63 @@
+package migrations
+
+import (
+	"go.etcd.io/bbolt"
+
+	"github.com/handracs2007/kquiz/config"
+	"github.com/handracs2007/kquiz/storage"
+	"github.com/handracs2007/kquiz/telegram"
+)
+
+// Builtin returns the migrations of the data written by the earlier versions of the bot, with the bucket names
+// configured. The newest version goes last.
+func Builtin(buckets config.Buckets) []Migration {
+	return []Migration{
+		{Version: 1, Name: "nest the words and the decks in the buckets of their users",
+			Migrate: nestUserKeys(buckets.Telegram, buckets.Kquiz, buckets.Deck)},
+		{Version: 2, Name: "encode the legacy words as entries", Migrate: encodeLegacyEntries(buckets.Kquiz)},
+	}
+}
+
+// nestUserKeys moves the words and the decks stored under keys prefixed with the chat ID of their user, before the
+// users got buckets of their own, into the buckets of their users.
+func nestUserKeys(telegramBucket string, bucketNames ...string) func(tx Tx) (int, error) {
+	return func(tx Tx) (int, error) {
+		users := make([]int64, 0)
+		err := tx.Each(func(shardTx *bbolt.Tx) error {
+			shardUsers, err := storage.UsersIn(shardTx, telegramBucket)
+			users = append(users, shardUsers...)
+			return err
+		})
+		if err != nil {
+			return 0, err
+		}
+
+		moved := 0
+		for _, bucketName := range bucketNames {
+			err = tx.Each(func(shardTx *bbolt.Tx) error {
+				count, err := telegram.NestUserKeysIn(shardTx, tx.Shards(), bucketName, users)
+				moved += count
+				return err
+			})
+			if err != nil {
+				return moved, err
+			}
+		}
+
+		return moved, nil
+	}
+}
+
+// encodeLegacyEntries rewrites the words stored as the plain translation, before entries were introduced, as entries.
+func encodeLegacyEntries(kquizBucket string) func(tx Tx) (int, error) {
+	return func(tx Tx) (int, error) {
+		rewritten := 0
+		err := tx.Each(func(shardTx *bbolt.Tx) error {
+			count, err := storage.EncodeLegacyEntriesIn(shardTx, kquizBucket)
+			rewritten += count
+			return err
+		})
+
+		return rewritten, err
+	}
+}
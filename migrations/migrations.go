@@ -0,0 +1,179 @@
+// Package migrations upgrades the data written by the earlier versions of the bot, one schema version at a time. The
+// version reached is recorded in the meta bucket of the first shard, so that each migration runs once.
+package migrations
+
+import (
+	"errors"
+	"go.etcd.io/bbolt"
+	"log"
+	"sort"
+
+	"github.com/handracs2007/kquiz/telegram"
+)
+
+// ErrMigrationFailed indicates that a migration failed, the databases are left as they were before it.
+var ErrMigrationFailed = errors.New("migration failed")
+
+// ErrNewerSchema indicates that the databases were written by a newer version of the bot, which this one cannot run.
+var ErrNewerSchema = errors.New("databases written by a newer version")
+
+// Tx holds the read-write transactions of a migration, one per shard.
+type Tx struct {
+	shards telegram.Shards
+	txs    []*bbolt.Tx
+}
+
+// Main returns the transaction of the main database.
+func (tx Tx) Main() *bbolt.Tx {
+	return tx.txs[0]
+}
+
+// Shards returns the shards migrated, e.g. to find the shard of a user.
+func (tx Tx) Shards() telegram.Shards {
+	return tx.shards
+}
+
+// Each executes the function in the transaction of every shard, stopping at the first error.
+func (tx Tx) Each(fn func(tx *bbolt.Tx) error) error {
+	for _, shardTx := range tx.txs {
+		err := fn(shardTx)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Migration upgrades the data from the previous version to its version and returns how many items it changed. A
+// migration should be harmless to run again, the databases of a shard may have been restored from a backup taken
+// before it.
+type Migration struct {
+	Version int
+	Name    string
+	Migrate func(tx Tx) (int, error)
+}
+
+// Result is the outcome of a migration run.
+type Result struct {
+	Version int
+	Name    string
+	Changed int
+}
+
+// begin starts a read-write transaction on every shard.
+func begin(shards telegram.Shards) (Tx, error) {
+	tx := Tx{shards: shards}
+	for _, db := range shards {
+		shardTx, err := db.Begin(true)
+		if err != nil {
+			tx.rollback()
+			return Tx{}, err
+		}
+
+		tx.txs = append(tx.txs, shardTx)
+	}
+
+	return tx, nil
+}
+
+// rollback discards the changes made in every shard.
+func (tx Tx) rollback() {
+	for _, shardTx := range tx.txs {
+		_ = shardTx.Rollback()
+	}
+}
+
+// commit commits the changes made in every shard. The main database, recording the version, is committed last so
+// that the migration runs again unless all the shards made it.
+func (tx Tx) commit() error {
+	for i := len(tx.txs) - 1; i > 0; i-- {
+		err := tx.txs[i].Commit()
+		if err != nil {
+			for _, shardTx := range tx.txs[:i] {
+				_ = shardTx.Rollback()
+			}
+
+			return err
+		}
+	}
+
+	return tx.txs[0].Commit()
+}
+
+// Run runs the migrations newer than the version of the databases in order, each in its own transactions committed
+// only when it succeeded on every shard, and returns what they changed. A dry run runs them all in the same
+// transactions and rolls them back, to tell what would change.
+// This function returns the following errors:
+//  - ErrNewerSchema
+//  - ErrMigrationFailed
+func Run(shards telegram.Shards, migrations []Migration, dryRun bool) ([]Result, error) {
+	sorted := append([]Migration(nil), migrations...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Version < sorted[j].Version
+	})
+
+	results := make([]Result, 0)
+	tx, err := begin(shards)
+	if err != nil {
+		log.Printf("Failed to begin migration. %s.\n", err)
+		return results, ErrMigrationFailed
+	}
+
+	version, err := telegram.SchemaVersion(tx.Main())
+	if err != nil {
+		log.Printf("Failed to read schema version. %s.\n", err)
+		tx.rollback()
+		return results, ErrMigrationFailed
+	}
+
+	if len(sorted) != 0 && version > sorted[len(sorted)-1].Version {
+		log.Printf("The databases are at version %d but this version of the bot only knows up to version %d.\n",
+			version, sorted[len(sorted)-1].Version)
+		tx.rollback()
+		return results, ErrNewerSchema
+	}
+
+	for _, migration := range sorted {
+		if migration.Version <= version {
+			continue
+		}
+
+		if tx.txs == nil {
+			tx, err = begin(shards)
+			if err != nil {
+				log.Printf("Failed to begin migration. %s.\n", err)
+				return results, ErrMigrationFailed
+			}
+		}
+
+		changed, err := migration.Migrate(tx)
+		if err == nil {
+			err = telegram.SetSchemaVersion(tx.Main(), migration.Version)
+		}
+		if err != nil {
+			log.Printf("Failed to migrate to version %d, %s. %s.\n", migration.Version, migration.Name, err)
+			tx.rollback()
+			return results, ErrMigrationFailed
+		}
+
+		version = migration.Version
+		results = append(results, Result{Version: migration.Version, Name: migration.Name, Changed: changed})
+		if dryRun {
+			continue
+		}
+
+		err = tx.commit()
+		tx = Tx{}
+		if err != nil {
+			log.Printf("Failed to commit migration to version %d, %s. %s.\n", migration.Version, migration.Name, err)
+			return results[:len(results)-1], ErrMigrationFailed
+		}
+	}
+
+	if tx.txs != nil {
+		tx.rollback()
+	}
+
+	return results, nil
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+	"github.com/handracs2007/kquiz/quiz"
+	"github.com/handracs2007/kquiz/storage"
+	"github.com/handracs2007/kquiz/telegram"
+	"strings"
+)
+
+// mistakeNotebook returns the words and the grammar patterns in the mistake notebook of the user, the latest mistakes
+// first.
+func mistakeNotebook(lister storage.Lister, chatID int64) ([]telegram.Entry, error) {
+	entries := make([]telegram.Entry, 0)
+	for _, kind := range []string{telegram.KindVocabulary, telegram.KindGrammar} {
+		kindEntries, err := lister.ListEntries(chatID, kind)
+		if err != nil && err != telegram.ErrWordNotFound {
+			return nil, err
+		}
+
+		entries = append(entries, kindEntries...)
+	}
+
+	return quiz.Mistakes(entries), nil
+}
+
+// showMistakes lists the mistake notebook of the user with the correct answers left to take each word out of it.
+func showMistakes(lister storage.Lister, chatID int64) tgbotapi.Chattable {
+	var msg tgbotapi.MessageConfig
+	mistakes, err := mistakeNotebook(lister, chatID)
+	if err != nil {
+		msg = tgbotapi.NewMessage(chatID, fmt.Sprintf("Get mistakes failed. %s.", err))
+	} else if len(mistakes) == 0 {
+		msg = tgbotapi.NewMessage(chatID, "Your mistake notebook is empty, the words you answer wrong go there.")
+	} else {
+		lines := []string{fmt.Sprintf("Mistake notebook, %d words. Each leaves it once answered correctly %d times, "+
+			"quiz them with /mistakes quiz.", len(mistakes), quiz.MistakeFixes), ""}
+		for _, entry := range mistakes {
+			lines = append(lines, fmt.Sprintf("%s -> %s (%d/%d)", entry.Word, entry.Translation, entry.Fixes,
+				quiz.MistakeFixes))
+		}
+
+		msg = tgbotapi.NewMessage(chatID, strings.Join(lines, "\n"))
+	}
+
+	return msg
+}
+
+// startMistakes queues the words of the mistake notebook into the session, it returns the reply and whether there is
+// any.
+func startMistakes(lister storage.Lister, chatID int64, session *quiz.Session) (tgbotapi.Chattable, bool) {
+	var msg tgbotapi.MessageConfig
+	mistakes, err := mistakeNotebook(lister, chatID)
+	if err != nil {
+		msg = tgbotapi.NewMessage(chatID, fmt.Sprintf("Quiz mistakes failed. %s.", err))
+	} else if len(mistakes) == 0 {
+		msg = tgbotapi.NewMessage(chatID, "Your mistake notebook is empty, well done!")
+	} else {
+		session.Queue = mistakes
+		msg = tgbotapi.NewMessage(chatID, fmt.Sprintf("Let's go through your %d mistakes.", len(mistakes)))
+	}
+
+	return msg, len(session.Queue) != 0
+}
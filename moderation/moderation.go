@@ -0,0 +1,163 @@
+// Package moderation keeps inappropriate language out of the words the users add, for the deployments that require
+// it, e.g. classrooms.
+package moderation
+
+import (
+	_ "embed"
+	"errors"
+	"strings"
+	"unicode"
+
+	"github.com/handracs2007/kquiz/storage"
+	"github.com/handracs2007/kquiz/telegram"
+)
+
+// ErrInappropriate indicates that the text contains inappropriate language.
+var ErrInappropriate = errors.New("the text contains inappropriate language")
+
+//go:embed data/profanity.txt
+var profanity string
+
+// Filter finds the inappropriate words in the texts.
+type Filter struct {
+	// korean matches anywhere in a text since the particles attach to the words, words matches whole words.
+	korean []string
+	words  map[string]bool
+}
+
+// NewFilter creates a new instance of Filter with the embedded list of inappropriate words and the extra ones.
+func NewFilter(extra []string) Filter {
+	filter := Filter{words: map[string]bool{}}
+	for _, word := range append(strings.Split(profanity, "\n"), extra...) {
+		word = strings.ToLower(strings.TrimSpace(word))
+		if len(word) == 0 || strings.HasPrefix(word, "#") {
+			continue
+		}
+
+		if isHangul(word) {
+			filter.korean = append(filter.korean, word)
+		} else {
+			filter.words[word] = true
+		}
+	}
+
+	return filter
+}
+
+func isHangul(word string) bool {
+	for _, r := range word {
+		if unicode.Is(unicode.Hangul, r) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Appropriate reports whether the text contains none of the inappropriate words.
+func (filter Filter) Appropriate(text string) bool {
+	text = strings.ToLower(text)
+
+	// Spaces do not hide a Korean word, e.g. 시 발.
+	compact := strings.Join(strings.Fields(text), "")
+	for _, word := range filter.korean {
+		if strings.Contains(compact, word) {
+			return false
+		}
+	}
+
+	for _, word := range strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if filter.words[word] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Adder adds and updates the words of the users, refusing those containing inappropriate language.
+type Adder struct {
+	adder   storage.Adder
+	updater storage.Updater
+	filter  Filter
+}
+
+// NewAdder creates a new instance of Adder
+func NewAdder(adder storage.Adder, updater storage.Updater, filter Filter) Adder {
+	return Adder{adder: adder, updater: updater, filter: filter}
+}
+
+// Add adds the word and its translation.
+// This function returns the following errors:
+//  - ErrInappropriate
+//  - the errors of the underlying adder
+func (adder Adder) Add(chatID int64, word string, translation string) error {
+	if !adder.filter.Appropriate(word) || !adder.filter.Appropriate(translation) {
+		return ErrInappropriate
+	}
+
+	return adder.adder.Add(chatID, word, translation)
+}
+
+// AddEntry adds the word with its entry.
+// This function returns the following errors:
+//  - ErrInappropriate
+//  - the errors of the underlying adder
+func (adder Adder) AddEntry(chatID int64, word string, entry telegram.WordEntry) error {
+	if !adder.filter.Appropriate(word) || !adder.filter.Appropriate(entry.Translation) ||
+		!adder.filter.Appropriate(entry.Example) || !adder.filter.Appropriate(entry.Deck) {
+		return ErrInappropriate
+	}
+
+	return adder.adder.AddEntry(chatID, word, entry)
+}
+
+// SetExample sets the example sentence of the word.
+// This function returns the following errors:
+//  - ErrInappropriate
+//  - the errors of the underlying updater
+func (adder Adder) SetExample(chatID int64, word string, example string) error {
+	if !adder.filter.Appropriate(example) {
+		return ErrInappropriate
+	}
+
+	return adder.updater.SetExample(chatID, word, example)
+}
+
+// SetNotes sets the notes of the word.
+// This function returns the following errors:
+//  - ErrInappropriate
+//  - the errors of the underlying updater
+func (adder Adder) SetNotes(chatID int64, word string, notes string) error {
+	if !adder.filter.Appropriate(notes) {
+		return ErrInappropriate
+	}
+
+	return adder.updater.SetNotes(chatID, word, notes)
+}
+
+// AddTag tags the word.
+// This function returns the following errors:
+//  - ErrInappropriate
+//  - the errors of the underlying updater
+func (adder Adder) AddTag(chatID int64, word string, tag string) error {
+	if !adder.filter.Appropriate(tag) {
+		return ErrInappropriate
+	}
+
+	return adder.updater.AddTag(chatID, word, tag)
+}
+
+// SetTranslation replaces the translation of the word.
+// This function returns the following errors:
+//  - ErrInappropriate
+//  - the errors of the underlying updater
+func (adder Adder) SetTranslation(chatID int64, word string, translation string) error {
+	if !adder.filter.Appropriate(translation) {
+		return ErrInappropriate
+	}
+
+	return adder.updater.SetTranslation(chatID, word, translation)
+}
@@ -0,0 +1,41 @@
+package nudge
+
+import (
+	"hash/fnv"
+	"strconv"
+	"time"
+)
+
+// EngagementWindow is how soon after a nudge a quiz counts as engagement with it.
+const EngagementWindow = 2 * time.Hour
+
+// KeyPrefix starts the analytics keys of the experiments.
+const KeyPrefix = "nudge/"
+
+// The events counted for each variant of an experiment.
+const (
+	EventSent    = "sent"
+	EventEngaged = "engaged"
+)
+
+// Experiment compares how well the variants of a nudge message get the users to study.
+type Experiment struct {
+	Name      string
+	Templates []string
+}
+
+// Variant returns the label, e.g. A, and the template of the variant the user is assigned to. Users are spread over the
+// variants at random, yet a user always gets the same variant.
+func (experiment Experiment) Variant(chatID int64) (string, string) {
+	hash := fnv.New32a()
+	_, _ = hash.Write([]byte(experiment.Name + "/" + strconv.FormatInt(chatID, 10)))
+
+	index := int(hash.Sum32() % uint32(len(experiment.Templates)))
+	return string(rune('A' + index)), experiment.Templates[index]
+}
+
+// Key returns the analytics key of the variant, e.g. nudge/reviews/A. Its events are counted as the key followed by the
+// event, e.g. nudge/reviews/A/sent.
+func (experiment Experiment) Key(variant string) string {
+	return KeyPrefix + experiment.Name + "/" + variant
+}
@@ -0,0 +1,47 @@
+// Package nudge renders the messages the bot sends on its own, e.g. reminders and broadcasts, for each recipient.
+package nudge
+
+import (
+	"strings"
+)
+
+// The placeholders resolved for each recipient.
+const (
+	Name     = "name"
+	Streak   = "streak"
+	DueCount = "due_count"
+	// LastStreak is the streak of the user when last studied, even if it is broken since.
+	LastStreak = "last_streak"
+	// DaysAway is the number of days since the user last studied.
+	DaysAway = "days_away"
+)
+
+// Render replaces the {placeholders} of the template with the values of the recipient. Unknown placeholders are left
+// as they are, so that a typo shows rather than disappears.
+func Render(template string, values map[string]string) string {
+	var sb strings.Builder
+
+	for {
+		start := strings.Index(template, "{")
+		if start == -1 {
+			break
+		}
+
+		end := strings.Index(template[start:], "}")
+		if end == -1 {
+			break
+		}
+
+		value, ok := values[template[start+1:start+end]]
+		if !ok {
+			value = template[start : start+end+1]
+		}
+
+		sb.WriteString(template[:start])
+		sb.WriteString(value)
+		template = template[start+end+1:]
+	}
+
+	sb.WriteString(template)
+	return sb.String()
+}
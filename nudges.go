@@ -0,0 +1,220 @@
+package main
+
+import (
+	"fmt"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+	"github.com/handracs2007/kquiz/nudge"
+	"github.com/handracs2007/kquiz/scheduler"
+	"github.com/handracs2007/kquiz/storage"
+	"github.com/handracs2007/kquiz/telegram"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// recipientValues resolves the placeholders of the messages sent to the user identified by the chat ID.
+func recipientValues(tracker telegram.ActivityTracker, manager telegram.SettingsManager, lister storage.Lister,
+	chatID int64, now time.Time) map[string]string {
+	settings, err := manager.Settings(chatID)
+	if err != nil {
+		log.Printf("Failed to read settings, using the defaults. %s.\n", err)
+	}
+
+	activity, err := tracker.Activity(chatID)
+	if err != nil {
+		log.Printf("Failed to read activity. %s.\n", err)
+	}
+
+	name := activity.Name
+	if len(name) == 0 {
+		name = "friend"
+	}
+
+	dueCount := 0
+	if batch, err := dueReviews(lister, settings, chatID, now); err == nil {
+		dueCount = len(batch)
+	}
+
+	daysAway := 0
+	if !activity.LastStudied.IsZero() {
+		daysAway = int(now.Sub(activity.LastStudied) / (24 * time.Hour))
+	}
+
+	return map[string]string{
+		nudge.Name:       name,
+		nudge.Streak:     strconv.Itoa(activity.CurrentStreak(now, settings.Location())),
+		nudge.DueCount:   strconv.Itoa(dueCount),
+		nudge.LastStreak: strconv.Itoa(activity.Streak),
+		nudge.DaysAway:   strconv.Itoa(daysAway),
+	}
+}
+
+// broadcast posts the message to every user, resolving its placeholders for each of them. It runs in the background
+// not to hold the other updates up, the messages are then sent by the outbox within the rate limits.
+func broadcast(audience storage.Audience, tracker telegram.ActivityTracker, manager telegram.SettingsManager,
+	lister storage.Lister, botAPI sender, bulk poster, chatID int64, template string) {
+	go func() {
+		var msg tgbotapi.MessageConfig
+		users, err := audience.Users()
+		if err != nil {
+			msg = tgbotapi.NewMessage(chatID, fmt.Sprintf("Broadcast failed. %s.", err))
+		} else {
+			for _, user := range users {
+				text := nudge.Render(template, recipientValues(tracker, manager, lister, user, time.Now()))
+				bulk.Post(tgbotapi.NewMessage(user, text))
+			}
+
+			msg = tgbotapi.NewMessage(chatID, fmt.Sprintf("Broadcast queued for %d users.", len(users)))
+		}
+
+		_, err = botAPI.Send(msg)
+		if err != nil {
+			log.Printf("Failed to respond to broadcast request. %s.\n", err)
+		}
+	}()
+}
+
+// experimentReport shows the engagement with each variant of the nudge experiments.
+func experimentReport(recorder telegram.AnalyticsRecorder, chatID int64) tgbotapi.Chattable {
+	var msg tgbotapi.MessageConfig
+	counts, err := recorder.Counts(nudge.KeyPrefix)
+	if err != nil {
+		msg = tgbotapi.NewMessage(chatID, fmt.Sprintf("Get experiments failed. %s.", err))
+	} else if len(counts) == 0 {
+		msg = tgbotapi.NewMessage(chatID, "No nudges sent yet.")
+	} else {
+		keys := make([]string, 0)
+		for key := range counts {
+			if strings.HasSuffix(key, "/"+nudge.EventSent) {
+				keys = append(keys, strings.TrimSuffix(key, "/"+nudge.EventSent))
+			}
+		}
+		sort.Strings(keys)
+
+		var sb strings.Builder
+		sb.WriteString(fmt.Sprintf("Quizzes within %s of a nudge:\n", nudge.EngagementWindow))
+		for _, key := range keys {
+			sent := counts[key+"/"+nudge.EventSent]
+			engaged := counts[key+"/"+nudge.EventEngaged]
+			sb.WriteString(fmt.Sprintf("\n%s: %d of %d (%.1f%%)", strings.TrimPrefix(key, nudge.KeyPrefix), engaged,
+				sent, 100*float64(engaged)/float64(sent)))
+		}
+
+		msg = tgbotapi.NewMessage(chatID, sb.String())
+	}
+
+	return msg
+}
+
+// winBack returns the job sending the win-back message to the users who have not studied for the given duration, once
+// per lapse and unless they opted out. The users are checked once an hour.
+func winBack(audience storage.Audience, tracker telegram.ActivityTracker, lister storage.Lister,
+	manager telegram.SettingsManager, recorder telegram.AnalyticsRecorder, botAPI sender, inactivity time.Duration,
+	template string) scheduler.Job {
+	experiment := nudge.Experiment{Name: "winback", Templates: []string{template}}
+
+	return func(now time.Time) {
+		if now.Minute() != 0 {
+			return
+		}
+
+		users, err := audience.Users()
+		if err != nil {
+			log.Printf("Failed to list users for win-back. %s.\n", err)
+			return
+		}
+
+		for _, chatID := range users {
+			activity, err := tracker.Activity(chatID)
+			if err != nil || !activity.Lapsed(now, inactivity) {
+				continue
+			}
+
+			settings, err := manager.Settings(chatID)
+			if err != nil || settings.NoWinBack {
+				continue
+			}
+
+			variant, template := experiment.Variant(chatID)
+			msg := tgbotapi.NewMessage(chatID, nudge.Render(template,
+				recipientValues(tracker, manager, lister, chatID, now)))
+			msg.DisableNotification = settings.Silent(now)
+
+			_, err = botAPI.Send(msg)
+			if err != nil {
+				log.Printf("Failed to send win-back message. %s.\n", err)
+				continue
+			}
+
+			err = tracker.RecordWinBack(chatID, experiment.Key(variant), now)
+			if err == nil {
+				err = recorder.Count(experiment.Key(variant) + "/" + nudge.EventSent)
+			}
+			if err != nil {
+				log.Printf("Failed to record win-back message. %s.\n", err)
+			}
+		}
+	}
+}
+
+func setWinBack(manager telegram.SettingsManager, chatID int64, enabled bool) tgbotapi.Chattable {
+	var msg tgbotapi.MessageConfig
+	settings, err := manager.Settings(chatID)
+	if err == nil {
+		settings.NoWinBack = !enabled
+		err = manager.SaveSettings(chatID, settings)
+	}
+
+	if err != nil {
+		msg = tgbotapi.NewMessage(chatID, fmt.Sprintf("Save settings failed. %s.", err))
+	} else if enabled {
+		msg = tgbotapi.NewMessage(chatID, "We will check on you when you have not studied for a while.")
+	} else {
+		msg = tgbotapi.NewMessage(chatID, "We will not check on you anymore.")
+	}
+
+	return msg
+}
+
+func setSilentPushes(manager telegram.SettingsManager, chatID int64, silent bool) tgbotapi.Chattable {
+	var msg tgbotapi.MessageConfig
+	settings, err := manager.Settings(chatID)
+	if err == nil {
+		settings.SilentPushes = silent
+		err = manager.SaveSettings(chatID, settings)
+	}
+
+	if err != nil {
+		msg = tgbotapi.NewMessage(chatID, fmt.Sprintf("Save settings failed. %s.", err))
+	} else if silent {
+		msg = tgbotapi.NewMessage(chatID, "Reminders will arrive without a notification sound.")
+	} else {
+		msg = tgbotapi.NewMessage(chatID, "Reminders will notify you, except around your quiet hours.")
+	}
+
+	return msg
+}
+
+// setQuietHours saves the quiet hours of the user, empty times turn them off.
+func setQuietHours(manager telegram.SettingsManager, chatID int64, start string, end string) tgbotapi.Chattable {
+	var msg tgbotapi.MessageConfig
+	settings, err := manager.Settings(chatID)
+	if err == nil {
+		settings.QuietStart = start
+		settings.QuietEnd = end
+		err = manager.SaveSettings(chatID, settings)
+	}
+
+	if err != nil {
+		msg = tgbotapi.NewMessage(chatID, fmt.Sprintf("Save settings failed. %s.", err))
+	} else if len(start) == 0 {
+		msg = tgbotapi.NewMessage(chatID, "Quiet hours are off.")
+	} else {
+		msg = tgbotapi.NewMessage(chatID, fmt.Sprintf("Reminders from an hour before %s until %s (%s) will arrive "+
+			"without a notification sound.", start, end, settings.Location()))
+	}
+
+	return msg
+}
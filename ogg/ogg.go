@@ -0,0 +1,90 @@
+// Package ogg joins the Ogg encoded audio, e.g. the speech synthesised word by word, into a single file.
+package ogg
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+)
+
+// ErrInvalidStream is returned when the audio is not a sequence of Ogg pages.
+var ErrInvalidStream = errors.New("invalid ogg stream")
+
+// headerSize is the size of the header of an Ogg page, before its segment table.
+const headerSize = 27
+
+// The offsets of the fields of the header of an Ogg page that are rewritten.
+const (
+	serialOffset   = 14
+	checksumOffset = 22
+	segmentsOffset = 26
+)
+
+var capturePattern = []byte("OggS")
+
+// crcTable is the table of the CRC-32 of the Ogg pages, with the polynomial 0x04c11db7 and no reflection.
+var crcTable = func() [256]uint32 {
+	var table [256]uint32
+	for i := range table {
+		crc := uint32(i) << 24
+		for bit := 0; bit < 8; bit++ {
+			if crc&0x80000000 != 0 {
+				crc = crc<<1 ^ 0x04c11db7
+			} else {
+				crc <<= 1
+			}
+		}
+
+		table[i] = crc
+	}
+
+	return table
+}()
+
+// checksum returns the CRC-32 of the page, whose checksum field must be zero.
+func checksum(page []byte) uint32 {
+	var crc uint32
+	for _, b := range page {
+		crc = crc<<8 ^ crcTable[byte(crc>>24)^b]
+	}
+
+	return crc
+}
+
+// Chain joins the streams into a chained Ogg stream, played one after the other. Each stream gets its own serial
+// number, so that the players tell them apart even when the encoder reused one.
+// This function returns the following errors:
+//  - ErrInvalidStream
+func Chain(streams [][]byte) ([]byte, error) {
+	var chained bytes.Buffer
+	for i, stream := range streams {
+		for len(stream) != 0 {
+			if len(stream) < headerSize || !bytes.HasPrefix(stream, capturePattern) {
+				return nil, ErrInvalidStream
+			}
+
+			segments := int(stream[segmentsOffset])
+			if len(stream) < headerSize+segments {
+				return nil, ErrInvalidStream
+			}
+
+			size := headerSize + segments
+			for _, lacing := range stream[headerSize : headerSize+segments] {
+				size += int(lacing)
+			}
+			if len(stream) < size {
+				return nil, ErrInvalidStream
+			}
+
+			page := append([]byte(nil), stream[:size]...)
+			binary.LittleEndian.PutUint32(page[serialOffset:], uint32(i+1))
+			binary.LittleEndian.PutUint32(page[checksumOffset:], 0)
+			binary.LittleEndian.PutUint32(page[checksumOffset:], checksum(page))
+
+			chained.Write(page)
+			stream = stream[size:]
+		}
+	}
+
+	return chained.Bytes(), nil
+}
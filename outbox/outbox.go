@@ -0,0 +1,306 @@
+// Package outbox sends the messages of the bot within the rate limits of Telegram, about 30 messages per second
+// overall, one per second in a chat and 20 per minute in a group, so that the bulk operations do not lose messages.
+// The replies are sent right away, the bulk messages are posted to a queue drained in the background.
+package outbox
+
+import (
+	"context"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+// The rate limits of Telegram, as the interval between two messages and the number of messages sent at once before
+// the interval applies.
+const (
+	globalInterval = time.Second / 30
+	globalBurst    = 30
+	chatInterval   = time.Second
+	groupInterval  = 3 * time.Second
+	chatBurst      = 3
+)
+
+// maxAttempts is the number of times a message is sent before giving up, when Telegram asks to slow down.
+const maxAttempts = 4
+
+// pruneThreshold is the number of chats tracked before the idle ones are forgotten.
+const pruneThreshold = 1024
+
+// Client is the part of the Telegram client sending the messages.
+type Client interface {
+	Send(c tgbotapi.Chattable) (tgbotapi.Message, error)
+}
+
+// limit tracks the rate of the messages sent to a chat, or overall, as a generic cell rate algorithm: a message may
+// be sent once the theoretical arrival time minus the burst tolerance has passed.
+type limit struct {
+	interval time.Duration
+	burst    int
+	arrival  time.Time
+}
+
+// earliest returns when a message may be sent, not before the given time.
+func (l *limit) earliest(at time.Time) time.Time {
+	if allowed := l.arrival.Add(-time.Duration(l.burst-1) * l.interval); allowed.After(at) {
+		return allowed
+	}
+
+	return at
+}
+
+// take records a message sent at the given time.
+func (l *limit) take(at time.Time) {
+	if l.arrival.Before(at) {
+		l.arrival = at
+	}
+
+	l.arrival = l.arrival.Add(l.interval)
+}
+
+// Outbox queues the messages and sends each once the limits of its chat and the overall limit allow it, in the order
+// they were queued. It retries the messages Telegram refused for going too fast after the delay it asks for, and
+// holds all the messages back meanwhile. The messages posted wait in a queue drained by a single worker, one at a
+// time, so that they only take the slots the replies leave free.
+type Outbox struct {
+	client   Client
+	mutex    sync.Mutex
+	global   limit
+	chats    map[int64]*limit
+	pending  int
+	idle     chan struct{}
+	queue    []tgbotapi.Chattable
+	draining bool
+}
+
+// New creates a new instance of Outbox
+func New(client Client) *Outbox {
+	return &Outbox{
+		client: client,
+		global: limit{interval: globalInterval, burst: globalBurst},
+		chats:  make(map[int64]*limit),
+	}
+}
+
+// chatID returns the chat the message is sent to, zero when it is not known, e.g. a channel given by its username.
+func chatID(c tgbotapi.Chattable) int64 {
+	switch config := c.(type) {
+	case tgbotapi.MessageConfig:
+		return config.ChatID
+	case tgbotapi.PhotoConfig:
+		return config.ChatID
+	case tgbotapi.DocumentConfig:
+		return config.ChatID
+	case tgbotapi.VoiceConfig:
+		return config.ChatID
+	case tgbotapi.AudioConfig:
+		return config.ChatID
+	case tgbotapi.EditMessageTextConfig:
+		return config.ChatID
+	case tgbotapi.EditMessageReplyMarkupConfig:
+		return config.ChatID
+	case tgbotapi.EditMessageCaptionConfig:
+		return config.ChatID
+	}
+
+	return 0
+}
+
+// reserve books the next time a message may be sent to the chat, zero for the overall limit only.
+func (outbox *Outbox) reserve(chat int64, now time.Time) time.Time {
+	outbox.mutex.Lock()
+	defer outbox.mutex.Unlock()
+
+	if len(outbox.chats) > pruneThreshold {
+		for id, l := range outbox.chats {
+			if l.arrival.Before(now) {
+				delete(outbox.chats, id)
+			}
+		}
+	}
+
+	var l *limit
+	if chat != 0 {
+		l = outbox.chats[chat]
+		if l == nil {
+			l = &limit{interval: chatInterval, burst: chatBurst}
+			// The groups have negative IDs.
+			if chat < 0 {
+				l.interval = groupInterval
+			}
+
+			outbox.chats[chat] = l
+		}
+
+		now = l.earliest(now)
+	}
+
+	at := outbox.global.earliest(now)
+	outbox.global.take(at)
+	if l != nil {
+		l.take(at)
+	}
+
+	return at
+}
+
+// begin counts a message being sent, for Flush to wait for it.
+func (outbox *Outbox) begin() {
+	outbox.mutex.Lock()
+	defer outbox.mutex.Unlock()
+
+	if outbox.pending == 0 {
+		outbox.idle = make(chan struct{})
+	}
+
+	outbox.pending++
+}
+
+// end counts a message sent, or given up on.
+func (outbox *Outbox) end() {
+	outbox.mutex.Lock()
+	defer outbox.mutex.Unlock()
+
+	outbox.pending--
+	if outbox.pending == 0 {
+		close(outbox.idle)
+	}
+}
+
+// Pending returns the number of messages queued or being sent.
+func (outbox *Outbox) Pending() int {
+	outbox.mutex.Lock()
+	defer outbox.mutex.Unlock()
+
+	return outbox.pending
+}
+
+// holdBack delays all the messages until the given time, when Telegram asks to slow down.
+func (outbox *Outbox) holdBack(until time.Time) {
+	outbox.mutex.Lock()
+	defer outbox.mutex.Unlock()
+
+	// The burst tolerance is spent, the next messages are spaced out again.
+	if until.After(outbox.global.arrival) {
+		outbox.global.arrival = until.Add(time.Duration(outbox.global.burst-1) * outbox.global.interval)
+	}
+}
+
+// retryAfter returns how long Telegram asks to wait before sending again, false when the error is not about the rate.
+func retryAfter(err error, attempt int) (time.Duration, bool) {
+	if err == nil || !strings.Contains(err.Error(), "Too Many Requests") {
+		return 0, false
+	}
+
+	if apiErr, ok := err.(tgbotapi.Error); ok && apiErr.RetryAfter > 0 {
+		return time.Duration(apiErr.RetryAfter) * time.Second, true
+	}
+
+	// The uploads only get the description, e.g. Too Many Requests: retry after 5.
+	if index := strings.LastIndex(err.Error(), "retry after "); index != -1 {
+		seconds, parseErr := strconv.Atoi(strings.TrimSpace(err.Error()[index+len("retry after "):]))
+		if parseErr == nil && seconds > 0 {
+			return time.Duration(seconds) * time.Second, true
+		}
+	}
+
+	// Let's back off exponentially when the delay is missing.
+	return time.Second << uint(attempt), true
+}
+
+// Send queues the message and sends it once the rate limits allow it. It blocks until the message is sent, use Post
+// for the messages whose outcome is not needed right away.
+func (outbox *Outbox) Send(c tgbotapi.Chattable) (tgbotapi.Message, error) {
+	outbox.begin()
+	defer outbox.end()
+
+	return outbox.send(c)
+}
+
+// Post queues the message and returns at once, the worker sends it after the messages posted before once the rate
+// limits allow it, e.g. for a broadcast. The failures are logged.
+func (outbox *Outbox) Post(c tgbotapi.Chattable) {
+	outbox.begin()
+
+	outbox.mutex.Lock()
+	outbox.queue = append(outbox.queue, c)
+	start := !outbox.draining
+	outbox.draining = true
+	outbox.mutex.Unlock()
+
+	if start {
+		go outbox.drain()
+	}
+}
+
+// drain sends the messages posted until the queue is empty.
+func (outbox *Outbox) drain() {
+	for {
+		outbox.mutex.Lock()
+		if len(outbox.queue) == 0 {
+			outbox.draining = false
+			outbox.mutex.Unlock()
+			return
+		}
+
+		c := outbox.queue[0]
+		outbox.queue[0] = nil
+		outbox.queue = outbox.queue[1:]
+		outbox.mutex.Unlock()
+
+		_, err := outbox.send(c)
+		if err != nil {
+			log.Printf("Failed to send posted message to %d. %s.\n", chatID(c), err)
+		}
+
+		outbox.end()
+	}
+}
+
+// send sends the message once the rate limits allow it, retrying when Telegram asks to slow down.
+func (outbox *Outbox) send(c tgbotapi.Chattable) (tgbotapi.Message, error) {
+	chat := chatID(c)
+
+	var message tgbotapi.Message
+	var err error
+	for attempt := 0; attempt < maxAttempts; attempt++ {
+		if wait := time.Until(outbox.reserve(chat, time.Now())); wait > 0 {
+			time.Sleep(wait)
+		}
+
+		message, err = outbox.client.Send(c)
+
+		delay, limited := retryAfter(err, attempt)
+		if !limited {
+			return message, err
+		}
+
+		log.Printf("Telegram asked to slow down, retrying in %s. %s.\n", delay, err)
+		outbox.holdBack(time.Now().Add(delay))
+	}
+
+	return message, err
+}
+
+// Flush waits for the messages queued so far to be sent, e.g. before shutting down. It returns the error of the
+// context when it is done first, the messages still queued are then lost.
+func (outbox *Outbox) Flush(ctx context.Context) error {
+	outbox.mutex.Lock()
+	if outbox.pending == 0 {
+		outbox.mutex.Unlock()
+		return nil
+	}
+
+	idle := outbox.idle
+	outbox.mutex.Unlock()
+
+	select {
+	case <-idle:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"fmt"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+	"github.com/handracs2007/kquiz/ogg"
+	"github.com/handracs2007/kquiz/providers"
+	"github.com/handracs2007/kquiz/quiz"
+	"github.com/handracs2007/kquiz/scheduler"
+	"github.com/handracs2007/kquiz/storage"
+	"github.com/handracs2007/kquiz/telegram"
+	"log"
+	"time"
+)
+
+// podcastSize is the number of due words read aloud in the daily podcast, a few minutes of audio.
+const podcastSize = 15
+
+// cachedSpeech returns the text read aloud in the language. The Korean texts share the cache of the voice messages,
+// the translations are synthesised every time.
+func cachedSpeech(cache telegram.AudioCacheManager, tts providers.TextToSpeech, text string, language string,
+	now time.Time) ([]byte, error) {
+	if language != providers.KoreanLanguageCode {
+		return tts.Synthesize(text, language)
+	}
+
+	cached, err := cache.Audio(text, now)
+	if err != nil {
+		log.Printf("Failed to read cached audio. %s.\n", err)
+	}
+	if cached != nil {
+		return cached.Audio, nil
+	}
+
+	audio, err := tts.Synthesize(text, language)
+	if err != nil {
+		return nil, err
+	}
+
+	err = cache.SaveAudio(text, telegram.CachedAudio{Audio: audio, LastPlayed: now})
+	if err != nil {
+		log.Printf("Failed to cache audio. %s.\n", err)
+	}
+
+	return audio, nil
+}
+
+// podcastEpisode reads aloud the words of the user due for review, each followed by its translation in the language,
+// as a single audio file. It returns the number of words read, zero when none is due.
+func podcastEpisode(lister storage.Lister, cache telegram.AudioCacheManager, tts providers.TextToSpeech,
+	chatID int64, language string, now time.Time) ([]byte, int, error) {
+	entries, err := lister.ListEntries(chatID, telegram.KindVocabulary)
+	if err == telegram.ErrWordNotFound {
+		return nil, 0, nil
+	} else if err != nil {
+		return nil, 0, err
+	}
+
+	due := quiz.DueQueue(entries, now)
+	if len(due) > podcastSize {
+		due = due[:podcastSize]
+	}
+	if len(due) == 0 {
+		return nil, 0, nil
+	}
+
+	streams := make([][]byte, 0, 2*len(due))
+	for _, entry := range due {
+		word, err := cachedSpeech(cache, tts, entry.Word, providers.KoreanLanguageCode, now)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		translation, err := cachedSpeech(cache, tts, entry.Translation, language, now)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		streams = append(streams, word, translation)
+	}
+
+	audio, err := ogg.Chain(streams)
+	if err != nil {
+		log.Printf("Failed to join podcast audio. %s.\n", err)
+		return nil, 0, telegram.ErrDatabaseError
+	}
+
+	return audio, len(due), nil
+}
+
+// podcastMessage returns the message delivering the podcast of the words due for the user, or telling why there is
+// none.
+func podcastMessage(lister storage.Lister, cache telegram.AudioCacheManager, tts providers.TextToSpeech,
+	chatID int64, language string, now time.Time) tgbotapi.Chattable {
+	if !providers.Available(tts) {
+		return tgbotapi.NewMessage(chatID, "The podcast is not available, text-to-speech is not configured.")
+	}
+
+	audio, count, err := podcastEpisode(lister, cache, tts, chatID, language, now)
+	if err != nil {
+		return tgbotapi.NewMessage(chatID, fmt.Sprintf("Get podcast failed. %s.", err))
+	} else if count == 0 {
+		return tgbotapi.NewMessage(chatID, "No word is due for review, enjoy the silence.")
+	}
+
+	voice := tgbotapi.NewVoiceUpload(chatID, tgbotapi.FileBytes{Name: "kquiz-podcast.ogg", Bytes: audio})
+	voice.Caption = fmt.Sprintf("Your review podcast: %d due words, each followed by its translation.", count)
+	return voice
+}
+
+// setPodcast sets the local time of the daily podcast of the user, empty to turn it off.
+func setPodcast(manager telegram.SettingsManager, chatID int64, at string) tgbotapi.Chattable {
+	var msg tgbotapi.MessageConfig
+	settings, err := manager.Settings(chatID)
+	if err == nil {
+		settings.Podcast = at
+		err = manager.SaveSettings(chatID, settings)
+	}
+
+	if err != nil {
+		msg = tgbotapi.NewMessage(chatID, fmt.Sprintf("Save settings failed. %s.", err))
+	} else if len(at) == 0 {
+		msg = tgbotapi.NewMessage(chatID, "Daily podcasts are off.")
+	} else {
+		msg = tgbotapi.NewMessage(chatID, fmt.Sprintf("You will get the podcast of your due words every day at %s "+
+			"(%s), set your time zone with /timezone.", at, settings.Location()))
+	}
+
+	return msg
+}
+
+// dailyPodcasts returns the job sending the users the podcast of their due words at the local time they chose. The
+// days without due words are skipped.
+func dailyPodcasts(audience storage.Audience, lister storage.Lister, manager telegram.SettingsManager,
+	cache telegram.AudioCacheManager, tts providers.TextToSpeech, botAPI sender, language string) scheduler.Job {
+	// The job may run more than once within the minute of a podcast, let's remember the podcasts sent lately.
+	sent := make(map[string]time.Time)
+
+	return func(now time.Time) {
+		for key, at := range sent {
+			if now.Sub(at) > 48*time.Hour {
+				delete(sent, key)
+			}
+		}
+
+		users, err := audience.Users()
+		if err != nil {
+			log.Printf("Failed to list users for daily podcasts. %s.\n", err)
+			return
+		}
+
+		for _, chatID := range users {
+			settings, err := manager.Settings(chatID)
+			if err != nil || len(settings.Podcast) == 0 {
+				continue
+			}
+
+			local := now.In(settings.Location())
+			if local.Format("15:04") != settings.Podcast {
+				continue
+			}
+
+			key := fmt.Sprintf("%d/%s", chatID, local.Format("2006-01-02"))
+			if _, ok := sent[key]; ok {
+				continue
+			}
+			sent[key] = now
+
+			audio, count, err := podcastEpisode(lister, cache, tts, chatID, language, now)
+			if err != nil {
+				log.Printf("Failed to prepare daily podcast. %s.\n", err)
+				continue
+			} else if count == 0 {
+				continue
+			}
+
+			voice := tgbotapi.NewVoiceUpload(chatID, tgbotapi.FileBytes{Name: "kquiz-podcast.ogg", Bytes: audio})
+			voice.Caption = fmt.Sprintf("Your daily podcast: %d due words, each followed by its translation.", count)
+			voice.DisableNotification = settings.Silent(now)
+
+			_, err = botAPI.Send(voice)
+			if err != nil {
+				log.Printf("Failed to send daily podcast. %s.\n", err)
+			}
+		}
+	}
+}
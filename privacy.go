@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+	"github.com/handracs2007/kquiz/telegram"
+	"log"
+	"time"
+)
+
+// privacyExempt are the commands available before the privacy notice is accepted.
+var privacyExempt = map[string]bool{"/help": true, "/privacy": true, "/stop": true, "/unregister": true}
+
+// acceptedPrivacy reports whether the user accepted the given version of the privacy notice.
+func acceptedPrivacy(manager telegram.SettingsManager, chatID int64, version string) bool {
+	settings, err := manager.Settings(chatID)
+	if err != nil {
+		// Let's not lock the user out when the settings cannot be read.
+		log.Printf("Failed to read settings, skipping the privacy notice. %s.\n", err)
+		return true
+	}
+
+	return settings.PrivacyVersion == version
+}
+
+func showPrivacyNotice(chatID int64, notice string, version string) tgbotapi.Chattable {
+	msg := tgbotapi.NewMessage(chatID, fmt.Sprintf("Privacy notice (version %s)\n\n%s", version, notice))
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("Accept", privacyCallbackPrefix+version)))
+
+	return msg
+}
+
+// acceptPrivacy records that the user accepted the version of the privacy notice and turns the notice into its
+// acceptance. It returns the edit of the notice and whether the user accepted a notice for the first time.
+func acceptPrivacy(manager telegram.SettingsManager, chatID int64, messageID int, notice string, version string,
+	now time.Time) (tgbotapi.Chattable, bool) {
+	settings, err := manager.Settings(chatID)
+	first := err == nil && len(settings.PrivacyVersion) == 0
+	if err == nil {
+		settings.PrivacyVersion = version
+		settings.PrivacyAcceptedAt = &now
+		err = manager.SaveSettings(chatID, settings)
+	}
+
+	// The notice keeps its button when the acceptance could not be saved, so that it can be pressed again.
+	var chattable tgbotapi.Chattable
+	if err != nil {
+		chattable = tgbotapi.NewMessage(chatID, fmt.Sprintf("Accept privacy notice failed. %s.", err))
+	} else {
+		chattable = tgbotapi.NewEditMessageText(chatID, messageID, fmt.Sprintf("Privacy notice (version %s)\n\n%s\n\n"+
+			"Accepted on %s.", version, notice, now.UTC().Format("2006-01-02")))
+	}
+
+	return chattable, first
+}
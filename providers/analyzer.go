@@ -0,0 +1,212 @@
+package providers
+
+import (
+	"bufio"
+	"io"
+	"log"
+	"os/exec"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// Token represents a morpheme of Korean text, or a whole word when the analyzer does not tell the morphemes apart. The
+// tag is the part of speech of the Sejong tag set used by mecab-ko-dic, e.g. NNG for a common noun or VV for a verb,
+// empty when not known. The lemma is the dictionary form, e.g. 먹다 for 먹었어요.
+type Token struct {
+	Surface string
+	Lemma   string
+	Tag     string
+}
+
+// Content reports whether the token carries meaning worth learning, e.g. a noun or a verb rather than a particle or
+// an ending. The tokens without tag are assumed to.
+func (token Token) Content() bool {
+	if len(token.Tag) == 0 {
+		return true
+	}
+
+	for _, prefix := range []string{"NNG", "NNP", "VV", "VA", "MAG", "XR"} {
+		if strings.HasPrefix(token.Tag, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Analyzer defines operations to be fulfilled by the implementation that has capability to split Korean text into
+// morphemes.
+type Analyzer interface {
+	// Analyze returns the tokens of the text, in order.
+	Analyze(text string) ([]Token, error)
+}
+
+// naiveParticles are the particles NaiveAnalyzer removes from the end of the words. The particles also ending common
+// nouns, e.g. 이 in 고양이 or 과 in 사과, are left alone.
+var naiveParticles = []string{"에서", "에게", "한테", "으로", "까지", "부터", "처럼", "보다", "은", "는", "을", "를", "에", "의"}
+
+// NaiveAnalyzer splits the text into its Hangul words and removes the common particles, without telling the
+// morphemes apart. It is the fallback when no morphological analyzer is installed.
+type NaiveAnalyzer struct{}
+
+// Analyze returns the Hangul words of the text, without their particles and without tag.
+func (NaiveAnalyzer) Analyze(text string) ([]Token, error) {
+	words := strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.Is(unicode.Hangul, r)
+	})
+
+	tokens := make([]Token, 0, len(words))
+	for _, word := range words {
+		lemma := word
+		for _, particle := range naiveParticles {
+			if strings.HasSuffix(word, particle) && len(word) > len(particle) {
+				lemma = strings.TrimSuffix(word, particle)
+				break
+			}
+		}
+
+		tokens = append(tokens, Token{Surface: word, Lemma: lemma})
+	}
+
+	return tokens, nil
+}
+
+// MecabAnalyzer analyzes the text with mecab and the mecab-ko-dic dictionary. A single mecab process is kept running
+// and fed a line at a time, it is restarted after it failed.
+type MecabAnalyzer struct {
+	mutex  *sync.Mutex
+	path   string
+	args   []string
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout *bufio.Reader
+}
+
+// NewMecabAnalyzer creates a new instance of MecabAnalyzer running the mecab binary at the path with the dictionary
+// directory, the default dictionary of mecab when empty.
+func NewMecabAnalyzer(path string, dictionary string) *MecabAnalyzer {
+	var args []string
+	if len(dictionary) != 0 {
+		args = []string{"-d", dictionary}
+	}
+
+	return &MecabAnalyzer{mutex: &sync.Mutex{}, path: path, args: args}
+}
+
+// start starts the mecab process.
+func (analyzer *MecabAnalyzer) start() error {
+	cmd := exec.Command(analyzer.path, analyzer.args...)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+
+	err = cmd.Start()
+	if err != nil {
+		return err
+	}
+
+	analyzer.cmd, analyzer.stdin, analyzer.stdout = cmd, stdin, bufio.NewReader(stdout)
+	return nil
+}
+
+// stop stops the mecab process, the next analysis starts a new one.
+func (analyzer *MecabAnalyzer) stop() {
+	_ = analyzer.stdin.Close()
+	_ = analyzer.cmd.Process.Kill()
+	_ = analyzer.cmd.Wait()
+	analyzer.cmd = nil
+}
+
+// Analyze returns the morphemes of the text. The verbs and the adjectives are lemmatised, and the nouns followed by
+// the suffix 하, e.g. 공부 and 했어요, are joined into the verb 공부하다.
+func (analyzer *MecabAnalyzer) Analyze(text string) ([]Token, error) {
+	analyzer.mutex.Lock()
+	defer analyzer.mutex.Unlock()
+
+	if analyzer.cmd == nil {
+		err := analyzer.start()
+		if err != nil {
+			log.Printf("Failed to start mecab. %s.\n", err)
+			return nil, ErrProviderFailed
+		}
+	}
+
+	// Mecab analyzes a line at a time and ends each with EOS.
+	_, err := io.WriteString(analyzer.stdin, strings.Join(strings.Fields(text), " ")+"\n")
+	if err != nil {
+		log.Printf("Failed to write to mecab. %s.\n", err)
+		analyzer.stop()
+		return nil, ErrProviderFailed
+	}
+
+	tokens := make([]Token, 0)
+	for {
+		line, err := analyzer.stdout.ReadString('\n')
+		if err != nil {
+			log.Printf("Failed to read from mecab. %s.\n", err)
+			analyzer.stop()
+			return nil, ErrProviderFailed
+		}
+
+		line = strings.TrimRight(line, "\r\n")
+		if line == "EOS" {
+			return tokens, nil
+		}
+
+		token, ok := parseMecab(line)
+		if !ok {
+			continue
+		}
+
+		// The suffixes turning the previous noun into a verb or an adjective are joined with it.
+		if last := len(tokens) - 1; last >= 0 && strings.HasPrefix(tokens[last].Tag, "NN") &&
+			(strings.HasPrefix(token.Tag, "XSV") || strings.HasPrefix(token.Tag, "XSA")) {
+			tokens[last] = Token{Surface: tokens[last].Surface + token.Surface,
+				Lemma: tokens[last].Lemma + token.Lemma, Tag: "VV"}
+			if strings.HasPrefix(token.Tag, "XSA") {
+				tokens[last].Tag = "VA"
+			}
+
+			continue
+		}
+
+		tokens = append(tokens, token)
+	}
+}
+
+// parseMecab parses a line of the output of mecab, the surface followed by a tab and the features separated by commas:
+// the tag, the semantic class, the final consonant, the reading, the type, the first and last tags and the
+// expression, e.g. 먹었 VV+EP,*,T,먹었,Inflect,VV,EP,먹/VV/*+었/EP/*.
+func parseMecab(line string) (Token, bool) {
+	fields := strings.SplitN(line, "\t", 2)
+	if len(fields) != 2 {
+		return Token{}, false
+	}
+
+	features := strings.Split(fields[1], ",")
+	token := Token{Surface: fields[0], Lemma: fields[0], Tag: features[0]}
+
+	// The inflected forms are expressed with their morphemes, the first one is the stem.
+	if len(features) >= 8 && features[4] == "Inflect" {
+		stem := strings.SplitN(strings.SplitN(features[7], "+", 2)[0], "/", 2)[0]
+		if len(stem) != 0 {
+			token.Lemma = stem
+		}
+	}
+
+	for _, tag := range []string{"VV", "VA", "VX", "XSV", "XSA"} {
+		if strings.HasPrefix(token.Tag, tag) {
+			token.Lemma += "다"
+			break
+		}
+	}
+
+	return token, true
+}
@@ -0,0 +1,199 @@
+package providers
+
+import (
+	"errors"
+	"log"
+	"sync"
+	"time"
+)
+
+// ErrBudgetExceeded indicates that the daily budget of the service is spent.
+var ErrBudgetExceeded = errors.New("daily budget exceeded")
+
+// Names of the services whose usage is counted.
+const (
+	TTSService         = "tts"
+	STTService         = "stt"
+	TranslationService = "translation"
+	DictionaryService  = "dictionary"
+)
+
+// Usage defines operations to be fulfilled by the implementation that has capability to count the daily calls to the
+// external services.
+type Usage interface {
+	// AddCall counts a call to the service on the given day, formatted as 2006-01-02, with its estimated cost.
+	AddCall(day string, service string, cost float64) error
+	// Spent returns the number of calls to the service and their estimated cost on the given day.
+	Spent(day string, service string) (int, float64, error)
+}
+
+// Budget limits the estimated daily cost of each service. The services without a limit are not limited but their
+// usage is still counted.
+type Budget struct {
+	usage  Usage
+	costs  map[string]float64
+	limits map[string]float64
+	alert  func(service string, limit float64)
+
+	mutex   sync.Mutex
+	alerted map[string]string
+}
+
+// NewBudget creates a new instance of Budget. The costs are the estimated costs of a call to each service, the limits
+// their daily budget in the same currency. The alert is called the first time a service exceeds its budget each day.
+func NewBudget(usage Usage, costs map[string]float64, limits map[string]float64,
+	alert func(service string, limit float64)) *Budget {
+	return &Budget{usage: usage, costs: costs, limits: limits, alert: alert, alerted: map[string]string{}}
+}
+
+// Today returns the current day, in UTC, as used to count the usage.
+func Today() string {
+	return time.Now().UTC().Format("2006-01-02")
+}
+
+// Limit returns the daily budget of the service, zero when it is not limited.
+func (budget *Budget) Limit(service string) float64 {
+	return budget.limits[service]
+}
+
+// allow fails with ErrBudgetExceeded when the service has spent its budget today. The usage is not enforced when it
+// cannot be read, the bot keeps working and the error is logged.
+func (budget *Budget) allow(service string) error {
+	limit := budget.limits[service]
+	if limit <= 0 {
+		return nil
+	}
+
+	day := Today()
+	_, spent, err := budget.usage.Spent(day, service)
+	if err != nil {
+		log.Printf("Failed to read %s usage. %s.\n", service, err)
+		return nil
+	}
+
+	if spent+budget.costs[service] <= limit {
+		return nil
+	}
+
+	budget.mutex.Lock()
+	alert := budget.alerted[service] != day
+	budget.alerted[service] = day
+	budget.mutex.Unlock()
+
+	if alert {
+		log.Printf("Daily %s budget of %.2f exceeded.\n", service, limit)
+		if budget.alert != nil {
+			budget.alert(service, limit)
+		}
+	}
+
+	return ErrBudgetExceeded
+}
+
+// record counts a successful call to the service.
+func (budget *Budget) record(service string) {
+	err := budget.usage.AddCall(Today(), service, budget.costs[service])
+	if err != nil {
+		log.Printf("Failed to count %s usage. %s.\n", service, err)
+	}
+}
+
+type budgetedTTS struct {
+	tts    TextToSpeech
+	budget *Budget
+}
+
+func (tts budgetedTTS) Synthesize(text string, languageCode string) ([]byte, error) {
+	err := tts.budget.allow(TTSService)
+	if err != nil {
+		return nil, err
+	}
+
+	audio, err := tts.tts.Synthesize(text, languageCode)
+	if err != nil {
+		return nil, err
+	}
+
+	tts.budget.record(TTSService)
+	return audio, nil
+}
+
+type budgetedSTT struct {
+	stt    SpeechToText
+	budget *Budget
+}
+
+func (stt budgetedSTT) Recognize(audio []byte, languageCode string) (*Transcript, error) {
+	err := stt.budget.allow(STTService)
+	if err != nil {
+		return nil, err
+	}
+
+	transcript, err := stt.stt.Recognize(audio, languageCode)
+	if err != nil {
+		return nil, err
+	}
+
+	stt.budget.record(STTService)
+	return transcript, nil
+}
+
+type budgetedTranslator struct {
+	translator Translator
+	budget     *Budget
+}
+
+func (translator budgetedTranslator) Translate(text string, source string, target string) (string, error) {
+	err := translator.budget.allow(TranslationService)
+	if err != nil {
+		return "", err
+	}
+
+	translation, err := translator.translator.Translate(text, source, target)
+	if err != nil {
+		return "", err
+	}
+
+	translator.budget.record(TranslationService)
+	return translation, nil
+}
+
+type budgetedDictionary struct {
+	dictionary Dictionary
+	budget     *Budget
+}
+
+func (dictionary budgetedDictionary) Define(word string) ([]Definition, error) {
+	err := dictionary.budget.allow(DictionaryService)
+	if err != nil {
+		return nil, err
+	}
+
+	definitions, err := dictionary.dictionary.Define(word)
+	if err != nil {
+		return nil, err
+	}
+
+	dictionary.budget.record(DictionaryService)
+	return definitions, nil
+}
+
+// UseBudget makes the available providers count their calls and refuse them with ErrBudgetExceeded once the daily
+// budget of their service is spent. It must be called before UseCache so that the cached responses are free.
+func (registry *Registry) UseBudget(budget *Budget) {
+	if Available(registry.TTS) {
+		registry.TTS = budgetedTTS{tts: registry.TTS, budget: budget}
+	}
+
+	if Available(registry.STT) {
+		registry.STT = budgetedSTT{stt: registry.STT, budget: budget}
+	}
+
+	if Available(registry.Translator) {
+		registry.Translator = budgetedTranslator{translator: registry.Translator, budget: budget}
+	}
+
+	if Available(registry.Dictionary) {
+		registry.Dictionary = budgetedDictionary{dictionary: registry.Dictionary, budget: budget}
+	}
+}
@@ -0,0 +1,109 @@
+package providers
+
+import (
+	"log"
+	"time"
+)
+
+// Namespaces of the cached responses.
+const (
+	TranslationCache = "translation"
+	DictionaryCache  = "dictionary"
+)
+
+// Cache defines operations to be fulfilled by the implementation that has capability to store provider responses for
+// a while.
+type Cache interface {
+	// Get decodes the cached value of the key into value. It reports false when the key is not cached or has expired.
+	Get(namespace string, key string, value interface{}) (bool, error)
+	// Put caches the value of the key until the given duration passes.
+	Put(namespace string, key string, value interface{}, ttl time.Duration) error
+}
+
+// CachedTranslator serves the translations from the cache, asking the translator only the texts it has not translated
+// recently.
+type CachedTranslator struct {
+	translator Translator
+	cache      Cache
+	ttl        time.Duration
+}
+
+// NewCachedTranslator creates a new instance of CachedTranslator
+func NewCachedTranslator(translator Translator, cache Cache, ttl time.Duration) CachedTranslator {
+	return CachedTranslator{translator: translator, cache: cache, ttl: ttl}
+}
+
+// Translate translates the text between the given ISO-639-1 languages, e.g. ko and en. The cache failures are logged
+// and the translator is asked instead.
+func (translator CachedTranslator) Translate(text string, source string, target string) (string, error) {
+	key := source + "\x00" + target + "\x00" + text
+
+	var translation string
+	found, err := translator.cache.Get(TranslationCache, key, &translation)
+	if err != nil {
+		log.Printf("Failed to read cached translation. %s.\n", err)
+	} else if found {
+		return translation, nil
+	}
+
+	translation, err = translator.translator.Translate(text, source, target)
+	if err != nil {
+		return "", err
+	}
+
+	err = translator.cache.Put(TranslationCache, key, translation, translator.ttl)
+	if err != nil {
+		log.Printf("Failed to cache translation. %s.\n", err)
+	}
+
+	return translation, nil
+}
+
+// CachedDictionary serves the definitions from the cache, asking the dictionary only the words it has not looked up
+// recently.
+type CachedDictionary struct {
+	dictionary Dictionary
+	cache      Cache
+	ttl        time.Duration
+}
+
+// NewCachedDictionary creates a new instance of CachedDictionary
+func NewCachedDictionary(dictionary Dictionary, cache Cache, ttl time.Duration) CachedDictionary {
+	return CachedDictionary{dictionary: dictionary, cache: cache, ttl: ttl}
+}
+
+// Define returns the dictionary entries of the Korean word, with the meanings in English. The cache failures are
+// logged and the dictionary is asked instead.
+func (dictionary CachedDictionary) Define(word string) ([]Definition, error) {
+	var definitions []Definition
+	found, err := dictionary.cache.Get(DictionaryCache, word, &definitions)
+	if err != nil {
+		log.Printf("Failed to read cached definitions. %s.\n", err)
+	} else if found {
+		return definitions, nil
+	}
+
+	definitions, err = dictionary.dictionary.Define(word)
+	if err != nil {
+		return nil, err
+	}
+
+	err = dictionary.cache.Put(DictionaryCache, word, definitions, dictionary.ttl)
+	if err != nil {
+		log.Printf("Failed to cache definitions. %s.\n", err)
+	}
+
+	return definitions, nil
+}
+
+// UseCache makes the available translator and dictionary serve their responses from the cache. The stubs are left
+// untouched so that they are still reported unavailable.
+func (registry *Registry) UseCache(cache Cache, translationTTL time.Duration, dictionaryTTL time.Duration) {
+	if Available(registry.Translator) {
+		registry.Translator = NewCachedTranslator(registry.Translator, cache, translationTTL)
+	}
+
+	if Available(registry.Dictionary) {
+		registry.Dictionary = NewCachedDictionary(registry.Dictionary, cache, dictionaryTTL)
+	}
+}
@@ -0,0 +1,133 @@
+package providers
+
+import (
+	"encoding/base64"
+	"log"
+)
+
+const googleTTSURL = "https://texttospeech.googleapis.com/v1/text:synthesize"
+
+// GoogleTTS synthesises speech with the Google Cloud Text-to-Speech API.
+type GoogleTTS struct {
+	apiKey string
+}
+
+// NewGoogleTTS creates a new instance of GoogleTTS
+func NewGoogleTTS(apiKey string) GoogleTTS {
+	return GoogleTTS{apiKey: apiKey}
+}
+
+// Synthesize returns the OGG/Opus encoded audio of the text read in the given language.
+// This function returns the following errors:
+//  - ErrProviderFailed
+func (tts GoogleTTS) Synthesize(text string, languageCode string) ([]byte, error) {
+	request := map[string]interface{}{
+		"input":       map[string]string{"text": text},
+		"voice":       map[string]string{"languageCode": languageCode},
+		"audioConfig": map[string]string{"audioEncoding": "OGG_OPUS"},
+	}
+
+	var response struct {
+		AudioContent string `json:"audioContent"`
+	}
+
+	// The key is sent in a header rather than in the URL, so that it never ends up in the logged errors.
+	err := postJSON(googleTTSURL, map[string]string{"X-Goog-Api-Key": tts.apiKey}, request, &response)
+	if err != nil {
+		log.Printf("Failed to synthesise speech. %s.\n", err)
+		return nil, ErrProviderFailed
+	}
+
+	audio, err := base64.StdEncoding.DecodeString(response.AudioContent)
+	if err != nil {
+		log.Printf("Failed to decode synthesised speech. %s.\n", err)
+		return nil, ErrProviderFailed
+	}
+
+	return audio, nil
+}
+
+const googleSTTURL = "https://speech.googleapis.com/v1/speech:recognize"
+
+// GoogleSTT recognises speech with the Google Cloud Speech-to-Text API.
+type GoogleSTT struct {
+	apiKey string
+}
+
+// NewGoogleSTT creates a new instance of GoogleSTT
+func NewGoogleSTT(apiKey string) GoogleSTT {
+	return GoogleSTT{apiKey: apiKey}
+}
+
+// Recognize returns the most likely transcript of the OGG/Opus encoded audio spoken in the given language. Telegram
+// voice messages are recorded at 48 kHz. An empty transcript is returned when nothing is recognised.
+// This function returns the following errors:
+//  - ErrProviderFailed
+func (stt GoogleSTT) Recognize(audio []byte, languageCode string) (*Transcript, error) {
+	request := map[string]interface{}{
+		"config": map[string]interface{}{
+			"encoding":        "OGG_OPUS",
+			"sampleRateHertz": 48000,
+			"languageCode":    languageCode,
+			"maxAlternatives": 1,
+		},
+		"audio": map[string]string{"content": base64.StdEncoding.EncodeToString(audio)},
+	}
+
+	var response struct {
+		Results []struct {
+			Alternatives []struct {
+				Transcript string  `json:"transcript"`
+				Confidence float64 `json:"confidence"`
+			} `json:"alternatives"`
+		} `json:"results"`
+	}
+
+	err := postJSON(googleSTTURL, map[string]string{"X-Goog-Api-Key": stt.apiKey}, request, &response)
+	if err != nil {
+		log.Printf("Failed to recognise speech. %s.\n", err)
+		return nil, ErrProviderFailed
+	}
+
+	if len(response.Results) == 0 || len(response.Results[0].Alternatives) == 0 {
+		return &Transcript{}, nil
+	}
+
+	alternative := response.Results[0].Alternatives[0]
+	return &Transcript{Text: alternative.Transcript, Confidence: alternative.Confidence}, nil
+}
+
+const googleTranslateURL = "https://translation.googleapis.com/language/translate/v2"
+
+// GoogleTranslator translates text with the Google Cloud Translation API.
+type GoogleTranslator struct {
+	apiKey string
+}
+
+// NewGoogleTranslator creates a new instance of GoogleTranslator
+func NewGoogleTranslator(apiKey string) GoogleTranslator {
+	return GoogleTranslator{apiKey: apiKey}
+}
+
+// Translate translates the text between the given ISO-639-1 languages.
+// This function returns the following errors:
+//  - ErrProviderFailed
+func (translator GoogleTranslator) Translate(text string, source string, target string) (string, error) {
+	request := map[string]string{"q": text, "source": source, "target": target, "format": "text"}
+
+	var response struct {
+		Data struct {
+			Translations []struct {
+				TranslatedText string `json:"translatedText"`
+			} `json:"translations"`
+		} `json:"data"`
+	}
+
+	err := postJSON(googleTranslateURL, map[string]string{"X-Goog-Api-Key": translator.apiKey}, request, &response)
+	if err != nil || len(response.Data.Translations) == 0 {
+		log.Printf("Failed to translate text. %v.\n", err)
+		return "", ErrProviderFailed
+	}
+
+	return response.Data.Translations[0].TranslatedText, nil
+}
@@ -0,0 +1,88 @@
+package providers
+
+import (
+	"encoding/xml"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const krdictURL = "https://krdict.korean.go.kr/api/search"
+
+// KrdictDictionary looks words up in the Basic Korean Dictionary (한국어기초사전) of the National Institute of Korean
+// Language, using its English translations.
+type KrdictDictionary struct {
+	apiKey string
+}
+
+// NewKrdictDictionary creates a new instance of KrdictDictionary
+func NewKrdictDictionary(apiKey string) KrdictDictionary {
+	return KrdictDictionary{apiKey: apiKey}
+}
+
+// krdictChannel mirrors the part of the search response that we need.
+type krdictChannel struct {
+	Items []struct {
+		Word         string `xml:"word"`
+		PartOfSpeech string `xml:"pos"`
+		Senses       []struct {
+			Translations []struct {
+				Word string `xml:"trans_word"`
+			} `xml:"translation"`
+		} `xml:"sense"`
+	} `xml:"item"`
+}
+
+// Define returns the dictionary entries of the Korean word, with the meanings in English.
+// This function returns the following errors:
+//  - ErrProviderFailed
+//  - ErrNoDefinition
+func (dict KrdictDictionary) Define(word string) ([]Definition, error) {
+	query := url.Values{}
+	query.Set("key", dict.apiKey)
+	query.Set("q", word)
+	query.Set("translated", "y")
+	query.Set("trans_lang", "1") // English
+	query.Set("advanced", "y")
+	query.Set("method", "exact")
+
+	resp, err := httpClient.Get(krdictURL + "?" + query.Encode())
+	if err != nil {
+		// The error contains the URL with the key, let's not log it.
+		log.Println("Failed to look word up.")
+		return nil, ErrProviderFailed
+	}
+	defer resp.Body.Close()
+
+	var channel krdictChannel
+	if resp.StatusCode == http.StatusOK {
+		err = xml.NewDecoder(resp.Body).Decode(&channel)
+	}
+	if resp.StatusCode != http.StatusOK || err != nil {
+		log.Printf("Failed to look word up. Status %s, %v.\n", resp.Status, err)
+		return nil, ErrProviderFailed
+	}
+
+	definitions := make([]Definition, 0)
+	for _, item := range channel.Items {
+		definition := Definition{Word: item.Word, PartOfSpeech: item.PartOfSpeech}
+		for _, sense := range item.Senses {
+			for _, translation := range sense.Translations {
+				if meaning := strings.TrimSpace(translation.Word); len(meaning) != 0 {
+					definition.Meanings = append(definition.Meanings, meaning)
+				}
+			}
+		}
+
+		if len(definition.Meanings) > 0 {
+			definitions = append(definitions, definition)
+		}
+	}
+
+	if len(definitions) == 0 {
+		return nil, ErrNoDefinition
+	}
+
+	return definitions, nil
+}
@@ -0,0 +1,52 @@
+package providers
+
+// offline is implemented by the stubs used when no provider is configured for a service.
+type offline interface {
+	offline()
+}
+
+// Available reports whether the provider is able to serve requests, that is, it is not an offline stub.
+func Available(provider interface{}) bool {
+	_, stub := provider.(offline)
+	return provider != nil && !stub
+}
+
+// OfflineTTS is the text-to-speech stub used when no provider is configured.
+type OfflineTTS struct{}
+
+func (OfflineTTS) offline() {}
+
+// Synthesize always fails with ErrProviderUnavailable.
+func (OfflineTTS) Synthesize(string, string) ([]byte, error) {
+	return nil, ErrProviderUnavailable
+}
+
+// OfflineSTT is the speech recognition stub used when no provider is configured.
+type OfflineSTT struct{}
+
+func (OfflineSTT) offline() {}
+
+// Recognize always fails with ErrProviderUnavailable.
+func (OfflineSTT) Recognize([]byte, string) (*Transcript, error) {
+	return nil, ErrProviderUnavailable
+}
+
+// OfflineTranslator is the translation stub used when no provider is configured.
+type OfflineTranslator struct{}
+
+func (OfflineTranslator) offline() {}
+
+// Translate always fails with ErrProviderUnavailable.
+func (OfflineTranslator) Translate(string, string, string) (string, error) {
+	return "", ErrProviderUnavailable
+}
+
+// OfflineDictionary is the dictionary stub used when no provider is configured.
+type OfflineDictionary struct{}
+
+func (OfflineDictionary) offline() {}
+
+// Define always fails with ErrProviderUnavailable.
+func (OfflineDictionary) Define(string) ([]Definition, error) {
+	return nil, ErrProviderUnavailable
+}
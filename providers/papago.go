@@ -0,0 +1,45 @@
+package providers
+
+import (
+	"log"
+)
+
+const papagoURL = "https://naveropenapi.apigw.ntruss.com/nmt/v1/translation"
+
+// PapagoTranslator translates text with the Naver Cloud Papago Translation API.
+type PapagoTranslator struct {
+	clientID     string
+	clientSecret string
+}
+
+// NewPapagoTranslator creates a new instance of PapagoTranslator
+func NewPapagoTranslator(clientID string, clientSecret string) PapagoTranslator {
+	return PapagoTranslator{clientID: clientID, clientSecret: clientSecret}
+}
+
+// Translate translates the text between the given ISO-639-1 languages.
+// This function returns the following errors:
+//  - ErrProviderFailed
+func (translator PapagoTranslator) Translate(text string, source string, target string) (string, error) {
+	request := map[string]string{"source": source, "target": target, "text": text}
+	headers := map[string]string{
+		"X-NCP-APIGW-API-KEY-ID": translator.clientID,
+		"X-NCP-APIGW-API-KEY":    translator.clientSecret,
+	}
+
+	var response struct {
+		Message struct {
+			Result struct {
+				TranslatedText string `json:"translatedText"`
+			} `json:"result"`
+		} `json:"message"`
+	}
+
+	err := postJSON(papagoURL, headers, request, &response)
+	if err != nil || len(response.Message.Result.TranslatedText) == 0 {
+		log.Printf("Failed to translate text. %v.\n", err)
+		return "", ErrProviderFailed
+	}
+
+	return response.Message.Result.TranslatedText, nil
+}
@@ -0,0 +1,92 @@
+package providers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// ErrProviderFailed indicates that the external provider failed to fulfil the request.
+var ErrProviderFailed = errors.New("external service failed")
+
+// ErrProviderUnavailable indicates that no provider is configured for the requested service.
+var ErrProviderUnavailable = errors.New("service is not configured")
+
+// ErrNoDefinition indicates that the dictionary does not know the word.
+var ErrNoDefinition = errors.New("no definition found")
+
+// KoreanLanguageCode is the BCP-47 language code of Korean.
+const KoreanLanguageCode = "ko-KR"
+
+// TextToSpeech defines operations to be fulfilled by the implementation that has capability to synthesise speech.
+type TextToSpeech interface {
+	// Synthesize returns the OGG/Opus encoded audio of the text read in the given language.
+	Synthesize(text string, languageCode string) ([]byte, error)
+}
+
+// Transcript represents the text recognised from speech together with the confidence of the recognition, between 0 and
+// 1.
+type Transcript struct {
+	Text       string
+	Confidence float64
+}
+
+// SpeechToText defines operations to be fulfilled by the implementation that has capability to recognise speech.
+type SpeechToText interface {
+	// Recognize returns the most likely transcript of the OGG/Opus encoded audio spoken in the given language.
+	Recognize(audio []byte, languageCode string) (*Transcript, error)
+}
+
+// Translator defines operations to be fulfilled by the implementation that has capability to translate text.
+type Translator interface {
+	// Translate translates the text between the given ISO-639-1 languages, e.g. ko and en.
+	Translate(text string, source string, target string) (string, error)
+}
+
+// Definition represents a dictionary entry of a word.
+type Definition struct {
+	Word         string
+	PartOfSpeech string
+	Meanings     []string
+}
+
+// Dictionary defines operations to be fulfilled by the implementation that has capability to look words up.
+type Dictionary interface {
+	// Define returns the dictionary entries of the Korean word, with the meanings in English.
+	Define(word string) ([]Definition, error)
+}
+
+var httpClient = &http.Client{Timeout: 30 * time.Second}
+
+// postJSON posts the request as JSON with the given headers and decodes the JSON response.
+func postJSON(endpoint string, headers map[string]string, request interface{}, response interface{}) error {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(response)
+}
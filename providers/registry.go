@@ -0,0 +1,171 @@
+package providers
+
+import (
+	"fmt"
+	"log"
+	"os/exec"
+)
+
+// Names of the supported providers.
+const (
+	Google  = "google"
+	Papago  = "papago"
+	Krdict  = "krdict"
+	Mecab   = "mecab"
+	Naive   = "naive"
+	Offline = "offline"
+)
+
+// Config holds the selected provider of each service and the credentials of the providers. An empty selection picks
+// the first provider having its credentials configured. In offline mode, every service is disabled. The morphological
+// analyzer runs locally, mecab is picked when it is installed and the naive analyzer otherwise.
+type Config struct {
+	Offline            bool   `json:"-"`
+	TTS                string `json:"tts"`
+	STT                string `json:"stt"`
+	Translation        string `json:"translation"`
+	Dictionary         string `json:"dictionary"`
+	GoogleAPIKey       string `json:"google_api_key"`
+	PapagoClientID     string `json:"papago_client_id"`
+	PapagoClientSecret string `json:"papago_client_secret"`
+	KrdictAPIKey       string `json:"krdict_api_key"`
+	Analyzer           string `json:"analyzer"`
+	MecabPath          string `json:"mecab_path"`
+	MecabDictionary    string `json:"mecab_dictionary"`
+}
+
+// Registry holds the provider of each service. Services without a configured provider are served by offline stubs
+// failing with ErrProviderUnavailable, use Available to find out whether a service can be used. The analyzer is always
+// available.
+type Registry struct {
+	TTS        TextToSpeech
+	STT        SpeechToText
+	Translator Translator
+	Dictionary Dictionary
+	Analyzer   Analyzer
+}
+
+// choose returns the selected provider, or the first candidate having credentials when nothing is selected.
+// A selected provider without credentials is degraded to offline.
+func choose(service string, selected string, credentials map[string]bool, candidates ...string) (string, error) {
+	if len(selected) == 0 {
+		for _, candidate := range candidates {
+			if credentials[candidate] {
+				return candidate, nil
+			}
+		}
+
+		return Offline, nil
+	}
+
+	if selected == Offline {
+		return Offline, nil
+	}
+
+	known := false
+	for _, candidate := range candidates {
+		known = known || candidate == selected
+	}
+
+	if !known {
+		return "", fmt.Errorf("unknown %s provider %s", service, selected)
+	}
+
+	if !credentials[selected] {
+		log.Printf("The %s provider %s is not configured, %s is disabled.\n", service, selected, service)
+		return Offline, nil
+	}
+
+	return selected, nil
+}
+
+// NewRegistry creates a new instance of Registry with the providers selected in the configuration. It fails when an
+// unknown provider is selected.
+func NewRegistry(config Config) (*Registry, error) {
+	mecabPath := config.MecabPath
+	if len(mecabPath) == 0 {
+		mecabPath = Mecab
+	}
+
+	_, err := exec.LookPath(mecabPath)
+	credentials := map[string]bool{
+		Google: len(config.GoogleAPIKey) != 0,
+		Papago: len(config.PapagoClientID) != 0 && len(config.PapagoClientSecret) != 0,
+		Krdict: len(config.KrdictAPIKey) != 0,
+		Mecab:  err == nil,
+	}
+
+	registry := &Registry{
+		TTS:        OfflineTTS{},
+		STT:        OfflineSTT{},
+		Translator: OfflineTranslator{},
+		Dictionary: OfflineDictionary{},
+		Analyzer:   NaiveAnalyzer{},
+	}
+
+	// The naive analyzer stands in for a missing mecab, like the offline stubs.
+	selected := config.Analyzer
+	if selected == Naive {
+		selected = Offline
+	}
+
+	analyzer, err := choose("morphological analyzer", selected, credentials, Mecab)
+	if err != nil {
+		return nil, err
+	}
+
+	if analyzer == Mecab {
+		registry.Analyzer = NewMecabAnalyzer(mecabPath, config.MecabDictionary)
+	} else {
+		analyzer = Naive
+	}
+
+	if config.Offline {
+		log.Println("Offline mode, all providers are disabled.")
+		return registry, nil
+	}
+
+	tts, err := choose("text-to-speech", config.TTS, credentials, Google)
+	if err != nil {
+		return nil, err
+	}
+
+	if tts == Google {
+		registry.TTS = NewGoogleTTS(config.GoogleAPIKey)
+	}
+
+	stt, err := choose("speech recognition", config.STT, credentials, Google)
+	if err != nil {
+		return nil, err
+	}
+
+	if stt == Google {
+		registry.STT = NewGoogleSTT(config.GoogleAPIKey)
+	}
+
+	translation, err := choose("translation", config.Translation, credentials, Papago, Google)
+	if err != nil {
+		return nil, err
+	}
+
+	switch translation {
+	case Papago:
+		registry.Translator = NewPapagoTranslator(config.PapagoClientID, config.PapagoClientSecret)
+	case Google:
+		registry.Translator = NewGoogleTranslator(config.GoogleAPIKey)
+	}
+
+	dictionary, err := choose("dictionary", config.Dictionary, credentials, Krdict)
+	if err != nil {
+		return nil, err
+	}
+
+	if dictionary == Krdict {
+		registry.Dictionary = NewKrdictDictionary(config.KrdictAPIKey)
+	}
+
+	log.Printf("Providers: text-to-speech %s, speech recognition %s, translation %s, dictionary %s, "+
+		"morphological analyzer %s.\n", tts, stt, translation, dictionary, analyzer)
+
+	return registry, nil
+}
@@ -0,0 +1,104 @@
+// Package publish composes the word of the day published to the channel and syndicated by the feed.
+package publish
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/handracs2007/kquiz/telegram"
+)
+
+// DayLayout is the layout of the days of the posts, in UTC.
+const DayLayout = "2006-01-02"
+
+// QueueSize is the number of upcoming posts kept in the content queue for the admins to preview.
+const QueueSize = 7
+
+// Next returns the word of the curated deck to publish next, the one never published or else published the longest
+// ago. The words already in the content queue come last. The words are taken in alphabetical order, so that the deck
+// is gone through from start to end.
+func Next(entries []telegram.Entry, posts []telegram.Post, queue []telegram.Draft) (telegram.Entry, bool) {
+	if len(entries) == 0 {
+		return telegram.Entry{}, false
+	}
+
+	published := make(map[string]string)
+	for _, post := range posts {
+		if day, ok := published[post.Word]; !ok || post.Day > day {
+			published[post.Word] = post.Day
+		}
+	}
+
+	// The queued words are published after any day of the past.
+	for _, draft := range queue {
+		published[draft.Word] = "~"
+	}
+
+	sorted := append([]telegram.Entry(nil), entries...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Word < sorted[j].Word
+	})
+
+	next := sorted[0]
+	for _, entry := range sorted {
+		if published[entry.Word] < published[next.Word] {
+			next = entry
+		}
+	}
+
+	return next, true
+}
+
+// Fill tops the content queue up to QueueSize with the next words of the curated deck. It returns the queue unchanged
+// when the deck has fewer words than that.
+func Fill(queue []telegram.Draft, entries []telegram.Entry, posts []telegram.Post) []telegram.Draft {
+	for len(queue) < QueueSize && len(queue) < len(entries) {
+		entry, _ := Next(entries, posts, queue)
+		if queued(queue, entry.Word) {
+			// Every word of the deck is queued already, the rest of the queue was removed from the deck.
+			break
+		}
+
+		queue = append(queue, telegram.Draft{Word: entry.Word, Translation: entry.Translation, Example: entry.Example})
+	}
+
+	return queue
+}
+
+func queued(queue []telegram.Draft, word string) bool {
+	for _, draft := range queue {
+		if draft.Word == word {
+			return true
+		}
+	}
+
+	return false
+}
+
+// NewPost makes the post of the word of the day published at the given time.
+func NewPost(draft telegram.Draft, now time.Time) telegram.Post {
+	return telegram.Post{
+		Day:         now.UTC().Format(DayLayout),
+		Word:        draft.Word,
+		Translation: draft.Translation,
+		Example:     draft.Example,
+		PublishedAt: now,
+	}
+}
+
+// Text returns the text of the post.
+func Text(post telegram.Post) string {
+	text := fmt.Sprintf("Word of the day: %s\n%s", post.Word, post.Translation)
+	if len(post.Example) != 0 {
+		text += fmt.Sprintf("\n\nExample: %s", post.Example)
+	}
+
+	return text
+}
+
+// Speech returns the Korean text read in the audio of the post.
+func Speech(post telegram.Post) string {
+	return strings.TrimSpace(post.Word + ". " + post.Example)
+}
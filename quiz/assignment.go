@@ -0,0 +1,55 @@
+package quiz
+
+import (
+	"errors"
+	"time"
+
+	"github.com/handracs2007/kquiz/telegram"
+)
+
+// ErrDeadlinePassed indicates that the deadline of the class assignment has passed.
+var ErrDeadlinePassed = errors.New("the deadline of the assignment has passed")
+
+// ErrNoAttemptsLeft indicates that the student used all the attempts of the class assignment.
+var ErrNoAttemptsLeft = errors.New("no attempts of the assignment are left")
+
+// StartAssignment checks that the student may attempt the assignment of the template once more, given the attempts
+// already started, and returns the queue of its questions. The entries are the words of the student in the class deck,
+// those deleted since are left out. The queue follows the order of the template, or is shuffled for each attempt.
+// This function returns the following errors:
+//  - ErrDeadlinePassed
+//  - ErrNoAttemptsLeft
+func (engine *Engine) StartAssignment(template telegram.Template, attempts int, entries []telegram.Entry,
+	now time.Time) ([]telegram.Entry, error) {
+	assignment := template.Assignment
+	if assignment.Deadline != nil && now.After(*assignment.Deadline) {
+		return nil, ErrDeadlinePassed
+	}
+
+	if assignment.Attempts > 0 && attempts >= assignment.Attempts {
+		return nil, ErrNoAttemptsLeft
+	}
+
+	words := make(map[string]telegram.Entry, len(entries))
+	for _, entry := range entries {
+		words[entry.Word] = entry
+	}
+
+	queue := make([]telegram.Entry, 0, len(template.Entries))
+	for _, entry := range template.Entries {
+		if entry, ok := words[entry.Word]; ok {
+			queue = append(queue, entry)
+		}
+	}
+
+	if assignment.Order != telegram.OrderShuffle {
+		return queue, nil
+	}
+
+	shuffled := make([]telegram.Entry, len(queue))
+	for i, index := range engine.perm(len(queue)) {
+		shuffled[i] = queue[index]
+	}
+
+	return shuffled, nil
+}
@@ -0,0 +1,50 @@
+package quiz
+
+import (
+	"fmt"
+
+	"github.com/handracs2007/kquiz/telegram"
+)
+
+// KindChoice is the kind of the questions answered by picking one of the options.
+const KindChoice = "choice"
+
+// choiceOptions is the number of options of a multiple choice question, when there are enough words.
+const choiceOptions = 4
+
+// ForMultipleChoice generates a question asking to pick the translation of a random word of the candidates among the
+// translations of other words of the entries, in random order.
+// It returns false when there are not at least 2 different translations to choose from.
+func (engine *Engine) ForMultipleChoice(entries []telegram.Entry, candidates []telegram.Entry) (Question, bool) {
+	entry, ok := engine.Pick(candidates)
+	if !ok {
+		return Question{}, false
+	}
+
+	options := []string{entry.Translation}
+	seen := map[string]bool{entry.Translation: true}
+	for _, idx := range engine.perm(len(entries)) {
+		if len(options) == choiceOptions {
+			break
+		}
+
+		if translation := entries[idx].Translation; !seen[translation] {
+			seen[translation] = true
+			options = append(options, translation)
+		}
+	}
+
+	if len(options) < 2 {
+		return Question{}, false
+	}
+
+	engine.shuffle(options)
+
+	return Question{
+		Kind:    KindChoice,
+		Prompt:  fmt.Sprintf("What is translation for: %s", entry.Word),
+		Answer:  entry.Translation,
+		Word:    entry.Word,
+		Options: options,
+	}, true
+}
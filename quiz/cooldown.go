@@ -0,0 +1,55 @@
+package quiz
+
+import (
+	"time"
+
+	"github.com/handracs2007/kquiz/telegram"
+)
+
+// Cooldown keeps the words just asked out of the random questions for a while: the last Questions words asked, in the
+// session or before it, and the words asked within the Window. The zero Cooldown keeps none out.
+type Cooldown struct {
+	Questions int
+	Window    time.Duration
+}
+
+// cooling returns the recent words still cooling down with the last questions and the window given.
+func cooling(recent []telegram.AskedWord, questions int, window time.Duration, now time.Time) map[string]bool {
+	words := make(map[string]bool)
+	for i, asked := range recent {
+		if i >= len(recent)-questions || now.Sub(asked.At) < window {
+			words[asked.Word] = true
+		}
+	}
+
+	return words
+}
+
+// Filter returns the entries to pick the next random question from, leaving out the recent words cooling down. The
+// cooldown is relaxed when it would leave nothing to ask, e.g. with a few words, first dropping the window and then
+// the oldest of the last questions.
+func (cooldown Cooldown) Filter(entries []telegram.Entry, recent []telegram.AskedWord,
+	now time.Time) []telegram.Entry {
+	window := cooldown.Window
+	for questions := cooldown.Questions; questions > 0 || window > 0; questions-- {
+		words := cooling(recent, questions, window, now)
+		available := make([]telegram.Entry, 0, len(entries))
+		for _, entry := range entries {
+			if !words[entry.Word] {
+				available = append(available, entry)
+			}
+		}
+
+		if len(available) != 0 {
+			return available
+		}
+
+		// The window goes first, the questions are dropped one at a time after it.
+		if window > 0 {
+			window = 0
+			questions++
+		}
+	}
+
+	return entries
+}
@@ -0,0 +1,119 @@
+package quiz
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/handracs2007/kquiz/telegram"
+)
+
+// KindDictation is the kind of the questions answered by transcribing the audio of a sentence.
+const KindDictation = "dictation"
+
+// MaxDictationAttempts is the number of attempts given to transcribe a sentence before the answer is revealed.
+const MaxDictationAttempts = 3
+
+// ForDictation generates a dictation exercise from the example sentence of the entry. The audio of the sentence is
+// expected to be sent along with the prompt.
+// It returns false when the entry has no example.
+func ForDictation(entry telegram.Entry) (Question, bool) {
+	if len(strings.TrimSpace(entry.Example)) == 0 {
+		return Question{}, false
+	}
+
+	return Question{Kind: KindDictation, Prompt: "Listen and type what you hear.", Answer: entry.Example}, true
+}
+
+// Reveal returns the beginning of the answer revealed after the given number of failed attempts, one more word for
+// each attempt. The last word is never revealed.
+func Reveal(answer string, attempts int) string {
+	words := strings.Fields(answer)
+	if attempts > len(words)-1 {
+		attempts = len(words) - 1
+	}
+
+	if attempts <= 0 {
+		return ""
+	}
+
+	return strings.Join(words[:attempts], " ") + " …"
+}
+
+// isSignificant reports whether the character matters when comparing transcriptions. Spacing and punctuation are
+// difficult to hear, hence, they are ignored.
+func isSignificant(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// Diff compares the attempt against the expected sentence character by character. It returns the expected sentence
+// with the characters that were missed or mistyped enclosed in brackets, and the number of extra characters typed.
+func Diff(expected string, attempt string) (string, int) {
+	expectedRunes := []rune(expected)
+
+	// Only the significant characters take part in the alignment, we remember where they are in the sentence.
+	positions := make([]int, 0, len(expectedRunes))
+	for i, r := range expectedRunes {
+		if isSignificant(r) {
+			positions = append(positions, i)
+		}
+	}
+
+	typed := make([]rune, 0)
+	for _, r := range attempt {
+		if isSignificant(r) {
+			typed = append(typed, unicode.ToLower(r))
+		}
+	}
+
+	// Let's find the longest common subsequence, the characters outside of it are the mistakes.
+	n, m := len(positions), len(typed)
+	lcs := make([][]int, n+1)
+	for i := range lcs {
+		lcs[i] = make([]int, m+1)
+	}
+
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if unicode.ToLower(expectedRunes[positions[i]]) == typed[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	matched := make(map[int]bool)
+	for i, j := 0, 0; i < n && j < m; {
+		if unicode.ToLower(expectedRunes[positions[i]]) == typed[j] {
+			matched[positions[i]] = true
+			i++
+			j++
+		} else if lcs[i+1][j] >= lcs[i][j+1] {
+			i++
+		} else {
+			j++
+		}
+	}
+
+	var builder strings.Builder
+	inMistake := false
+	for i, r := range expectedRunes {
+		mistake := isSignificant(r) && !matched[i]
+		if mistake && !inMistake {
+			builder.WriteRune('[')
+		} else if !mistake && inMistake {
+			builder.WriteRune(']')
+		}
+
+		inMistake = mistake
+		builder.WriteRune(r)
+	}
+
+	if inMistake {
+		builder.WriteRune(']')
+	}
+
+	return builder.String(), m - lcs[0][0]
+}
@@ -0,0 +1,50 @@
+package quiz
+
+import (
+	"math/rand"
+	"sync"
+
+	"github.com/handracs2007/kquiz/telegram"
+)
+
+// Engine owns the random number generator picking and shuffling the quiz material. It is seeded once, a fixed seed
+// makes the quizzes reproducible. An Engine is safe for concurrent use.
+type Engine struct {
+	mutex sync.Mutex
+	rng   *rand.Rand
+}
+
+// NewEngine creates a new instance of Engine seeded with the given seed.
+func NewEngine(seed int64) *Engine {
+	return &Engine{rng: rand.New(rand.NewSource(seed))}
+}
+
+// Pick returns a random entry. It returns false when there are no entries.
+func (engine *Engine) Pick(entries []telegram.Entry) (telegram.Entry, bool) {
+	if len(entries) == 0 {
+		return telegram.Entry{}, false
+	}
+
+	engine.mutex.Lock()
+	defer engine.mutex.Unlock()
+
+	return entries[engine.rng.Intn(len(entries))], true
+}
+
+// shuffle shuffles the words in place.
+func (engine *Engine) shuffle(words []string) {
+	engine.mutex.Lock()
+	defer engine.mutex.Unlock()
+
+	engine.rng.Shuffle(len(words), func(i, j int) {
+		words[i], words[j] = words[j], words[i]
+	})
+}
+
+// perm returns a random permutation of [0, n).
+func (engine *Engine) perm(n int) []int {
+	engine.mutex.Lock()
+	defer engine.mutex.Unlock()
+
+	return engine.rng.Perm(n)
+}
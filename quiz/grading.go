@@ -0,0 +1,204 @@
+package quiz
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// lettersPerTypo is the least number of letters per typo tolerated, the short translations are to be spelled right.
+const lettersPerTypo = 4
+
+// articles are the leading words ignored in the translations, "an apple" is "apple".
+var articles = map[string]bool{"a": true, "an": true, "the": true}
+
+// Grade is the outcome of grading an answer. Close tells that the answer was accepted despite typos, Expected is the
+// accepted translation the answer matched, to show its spelling.
+type Grade struct {
+	Correct  bool
+	Close    bool
+	Expected string
+}
+
+// Grader grades the answers typed by the users. The translations accept any of their comma-separated alternatives,
+// ignoring the case, the punctuation, the leading articles and the notes in parentheses, and up to Tolerance typos
+// in the longer translations. The zero Grader tolerates no typo.
+type Grader struct {
+	Tolerance int
+}
+
+// NewGrader creates a new instance of Grader tolerating up to the given number of typos.
+func NewGrader(tolerance int) Grader {
+	return Grader{Tolerance: tolerance}
+}
+
+// Alternatives returns the accepted translations of the answer, the answer itself first, e.g. apple and fruit for
+// "apple, fruit". The notes in parentheses are left out, e.g. bank for "bank (finance)".
+func Alternatives(answer string) []string {
+	alternatives := []string{answer}
+	stripped := strings.TrimSpace(stripNotes(answer))
+	if stripped != answer && len(stripped) != 0 {
+		alternatives = append(alternatives, stripped)
+	}
+
+	for _, alternative := range strings.FieldsFunc(stripped, func(r rune) bool {
+		return r == ',' || r == ';'
+	}) {
+		if alternative = strings.TrimSpace(alternative); len(alternative) != 0 {
+			alternatives = append(alternatives, alternative)
+		}
+	}
+
+	return alternatives
+}
+
+// stripNotes removes the text in parentheses.
+func stripNotes(text string) string {
+	var stripped strings.Builder
+	depth := 0
+	for _, r := range text {
+		switch {
+		case r == '(':
+			depth++
+		case r == ')' && depth > 0:
+			depth--
+		case depth == 0:
+			stripped.WriteRune(r)
+		}
+	}
+
+	return stripped.String()
+}
+
+// normalizeTranslation lowers the case of the translation, separates its words at the punctuation and removes its
+// leading article.
+func normalizeTranslation(translation string) string {
+	words := strings.FieldsFunc(strings.ToLower(translation), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '\''
+	})
+	if len(words) > 1 && articles[words[0]] {
+		words = words[1:]
+	}
+
+	return strings.ReplaceAll(strings.Join(words, " "), "'", "")
+}
+
+// editDistance returns the Levenshtein distance between the strings, the number of letters inserted, deleted or
+// replaced to turn one into the other.
+func editDistance(a string, b string) int {
+	from, to := []rune(a), []rune(b)
+	previous := make([]int, len(to)+1)
+	current := make([]int, len(to)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(from); i++ {
+		current[0] = i
+		for j := 1; j <= len(to); j++ {
+			cost := 1
+			if from[i-1] == to[j-1] {
+				cost = 0
+			}
+
+			current[j] = min3(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+
+		previous, current = current, previous
+	}
+
+	return previous[len(to)]
+}
+
+func min3(a int, b int, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+
+	return a
+}
+
+// Grade grades the answer given to the question. The sentences are compared ignoring the case, the spacing and the
+// punctuation, and so are the Korean answers once their jamo are composed, or their romanization for the users without
+// a Korean keyboard. The translations accept their alternatives and the typos tolerated, the other answers are compared
+// ignoring the case.
+func (grader Grader) Grade(question Question, answer string) Grade {
+	switch question.Kind {
+	case KindSentence:
+		return Grade{Correct: normalizeSentence(answer) == normalizeSentence(question.Answer),
+			Expected: question.Answer}
+	case KindReverse:
+		if isRomanized(answer) {
+			return grader.gradeRomanized(question, answer)
+		}
+
+		return Grade{Correct: normalizeSentence(composeHangul(answer)) ==
+			normalizeSentence(composeHangul(question.Answer)), Expected: question.Answer}
+	case KindTranslation, KindChoice:
+	default:
+		return Grade{Correct: strings.ToLower(strings.TrimSpace(answer)) ==
+			strings.ToLower(strings.TrimSpace(question.Answer)), Expected: question.Answer}
+	}
+
+	given := normalizeTranslation(answer)
+	if len(given) == 0 {
+		return Grade{Expected: question.Answer}
+	}
+
+	closest := Grade{Expected: question.Answer}
+	best := -1
+	for _, alternative := range Alternatives(question.Answer) {
+		expected := normalizeTranslation(alternative)
+		if expected == given {
+			return Grade{Correct: true, Expected: alternative}
+		}
+
+		tolerated := grader.Tolerance
+		if typos := len([]rune(expected)) / lettersPerTypo; typos < tolerated {
+			tolerated = typos
+		}
+
+		if distance := editDistance(expected, given); distance <= tolerated && (best < 0 || distance < best) {
+			best = distance
+			closest = Grade{Correct: true, Close: true, Expected: alternative}
+		}
+	}
+
+	return closest
+}
+
+// gradeRomanized grades the romanized answer to the question asking for a Korean word. Both the Revised Romanization
+// and the syllable by syllable one are accepted, with the typos tolerated. The expected answer shows the Hangul and its
+// romanization, e.g. 사과 (sagwa).
+func (grader Grader) gradeRomanized(question Question, answer string) Grade {
+	romanized := Romanize(question.Answer)
+	expected := fmt.Sprintf("%s (%s)", question.Answer, romanized)
+	given := normalizeRomanization(answer)
+	if len(given) == 0 {
+		return Grade{Expected: expected}
+	}
+
+	closest := Grade{Expected: expected}
+	best := -1
+	for _, spelling := range []string{romanized, romanize(question.Answer, false)} {
+		spelling = normalizeRomanization(spelling)
+		if spelling == given {
+			return Grade{Correct: true, Expected: expected}
+		}
+
+		tolerated := grader.Tolerance
+		if typos := len(spelling) / lettersPerTypo; typos < tolerated {
+			tolerated = typos
+		}
+
+		if distance := editDistance(spelling, given); distance <= tolerated && (best < 0 || distance < best) {
+			best = distance
+			closest = Grade{Correct: true, Close: true, Expected: expected}
+		}
+	}
+
+	return closest
+}
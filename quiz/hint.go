@@ -0,0 +1,66 @@
+package quiz
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// MaxHints is the number of hints of a question: the first letter of the answer, its length, then an example sentence.
+const MaxHints = 3
+
+// hintMask replaces the answer in the example sentence given as a hint.
+const hintMask = "___"
+
+// Hintable reports whether the question can be hinted, the questions answered with a word or a translation. The other
+// exercises reveal the answer their own way.
+func (question Question) Hintable() bool {
+	return question.Kind == KindTranslation || question.Kind == KindReverse
+}
+
+// Hint returns the next hint of the question given the hints already taken and the example sentence of its word, if
+// any. The answer is masked in the example. It returns false when there is no hint left.
+func (question Question) Hint(example string) (string, bool) {
+	answer := strings.TrimSpace(question.Answer)
+	if !question.Hintable() || len(answer) == 0 {
+		return "", false
+	}
+
+	switch question.Hints {
+	case 0:
+		first := []rune(answer)[0]
+		return fmt.Sprintf("It starts with %c.", first), true
+	case 1:
+		letters := 0
+		for _, r := range answer {
+			if unicode.IsLetter(r) {
+				letters++
+			}
+		}
+
+		if words := len(strings.Fields(answer)); words > 1 {
+			return fmt.Sprintf("It has %d letters in %d words.", letters, words), true
+		}
+
+		return fmt.Sprintf("It has %d letters.", letters), true
+	case 2:
+		// The example of a grammar pattern is already in the prompt.
+		example = strings.TrimSpace(example)
+		if len(example) == 0 || strings.Contains(question.Prompt, example) {
+			return "", false
+		}
+
+		return fmt.Sprintf("Example: %s", strings.ReplaceAll(example, answer, hintMask)), true
+	default:
+		return "", false
+	}
+}
+
+// HintedPoints returns the points of a correct answer given after the hints, each hint taking a share of them.
+func HintedPoints(points int, hints int) int {
+	if hints > MaxHints {
+		hints = MaxHints
+	}
+
+	return points * (MaxHints + 1 - hints) / (MaxHints + 1)
+}
@@ -0,0 +1,44 @@
+package quiz
+
+import (
+	"sort"
+	"time"
+
+	"github.com/handracs2007/kquiz/telegram"
+)
+
+// Active returns the entries still quizzed, the ones not mastered.
+func Active(entries []telegram.Entry) []telegram.Entry {
+	active := make([]telegram.Entry, 0, len(entries))
+	for _, entry := range entries {
+		if entry.MasteredAt == nil {
+			active = append(active, entry)
+		}
+	}
+
+	return active
+}
+
+// Mastered returns the mastered entries, the ones mastered the longest ago first.
+func Mastered(entries []telegram.Entry) []telegram.Entry {
+	mastered := make([]telegram.Entry, 0)
+	for _, entry := range entries {
+		if entry.MasteredAt != nil {
+			mastered = append(mastered, entry)
+		}
+	}
+
+	sort.SliceStable(mastered, func(i, j int) bool {
+		return mastered[i].MasteredAt.Before(*mastered[j].MasteredAt)
+	})
+
+	return mastered
+}
+
+// Resurface returns the entry brought back into the reviews at the given time, due right away. Answered correctly, it
+// is mastered again, see Scheduler.
+func Resurface(entry telegram.WordEntry, now time.Time) telegram.WordEntry {
+	entry.MasteredAt = nil
+	entry.Due = &now
+	return entry
+}
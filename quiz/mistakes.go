@@ -0,0 +1,49 @@
+package quiz
+
+import (
+	"sort"
+	"time"
+
+	"github.com/handracs2007/kquiz/telegram"
+)
+
+// MistakeFixes is the number of correct answers taking a word out of the mistake notebook.
+const MistakeFixes = 3
+
+// TrackMistake returns the entry after being answered at the given time. A wrong answer puts the word in the mistake
+// notebook, or starts its fixes over, and it leaves the notebook once answered correctly MistakeFixes times since.
+func TrackMistake(entry telegram.WordEntry, correct bool, now time.Time) telegram.WordEntry {
+	if !correct {
+		entry.MistakenAt = &now
+		entry.Fixes = 0
+		return entry
+	}
+
+	if entry.MistakenAt == nil {
+		return entry
+	}
+
+	entry.Fixes++
+	if entry.Fixes >= MistakeFixes {
+		entry.MistakenAt = nil
+		entry.Fixes = 0
+	}
+
+	return entry
+}
+
+// Mistakes returns the entries in the mistake notebook, the latest mistakes first.
+func Mistakes(entries []telegram.Entry) []telegram.Entry {
+	mistakes := make([]telegram.Entry, 0)
+	for _, entry := range entries {
+		if entry.MistakenAt != nil {
+			mistakes = append(mistakes, entry)
+		}
+	}
+
+	sort.SliceStable(mistakes, func(i, j int) bool {
+		return mistakes[i].MistakenAt.After(*mistakes[j].MistakenAt)
+	})
+
+	return mistakes
+}
@@ -0,0 +1,115 @@
+package quiz
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/handracs2007/kquiz/telegram"
+)
+
+// KindTranslation is the kind of the questions answered with a translation or a meaning.
+const KindTranslation = "translation"
+
+// KindSentence is the kind of the questions answered with a whole sentence.
+const KindSentence = "sentence"
+
+// Question represents a prompt sent to the user together with the answer we expect.
+type Question struct {
+	Kind     string
+	Prompt   string
+	Answer   string
+	Attempts int
+	// Word is the word or grammar pattern the question reviews, empty for the exercises not reviewing one.
+	Word string
+	// Options are the answers to pick from, for the questions answered with buttons.
+	Options []string
+	// Hints is the number of hints taken, see Hint.
+	Hints int
+}
+
+// Check checks whether the answer given by the user is correct, without tolerating typos, see Grader.
+func (question Question) Check(answer string) bool {
+	return Grader{}.Grade(question, answer).Correct
+}
+
+// normalizeSentence removes everything but the letters and the digits of the sentence.
+func normalizeSentence(sentence string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+
+		return -1
+	}, sentence)
+}
+
+// ForVocabulary generates a question asking for the translation of a word.
+func ForVocabulary(entry telegram.Entry) Question {
+	return Question{
+		Kind:   KindTranslation,
+		Prompt: fmt.Sprintf("What is translation for: %s", entry.Word),
+		Answer: entry.Translation,
+		Word:   entry.Word,
+	}
+}
+
+// ForGrammar generates a question asking for the meaning of a grammar pattern, showing its example when available.
+func ForGrammar(entry telegram.Entry) Question {
+	prompt := fmt.Sprintf("What does the grammar pattern %s mean?", entry.Word)
+	if len(entry.Example) != 0 {
+		prompt += fmt.Sprintf("\nExample: %s", entry.Example)
+	}
+
+	return Question{Kind: KindTranslation, Prompt: prompt, Answer: entry.Translation, Word: entry.Word}
+}
+
+// For generates the question matching the kind of the entry.
+func For(entry telegram.Entry) Question {
+	if entry.EntryKind() == telegram.KindGrammar {
+		return ForGrammar(entry)
+	}
+
+	return ForVocabulary(entry)
+}
+
+// ForSentenceBuilding generates an exercise asking to put the shuffled words of the example sentence of the entry back
+// in order. Korean particles are attached to their words, so they move together with them.
+// It returns false when the entry has no example with at least 2 different words.
+func (engine *Engine) ForSentenceBuilding(entry telegram.Entry) (Question, bool) {
+	words := strings.Fields(entry.Example)
+
+	distinct := make(map[string]bool)
+	for _, word := range words {
+		distinct[word] = true
+	}
+
+	if len(distinct) < 2 {
+		return Question{}, false
+	}
+
+	// Let's make sure the user does not get the sentence already in order.
+	shuffled := make([]string, len(words))
+	copy(shuffled, words)
+	for strings.Join(shuffled, " ") == strings.Join(words, " ") {
+		engine.shuffle(shuffled)
+	}
+
+	prompt := fmt.Sprintf("Put the words in order (%s -> %s):\n%s", entry.Word, entry.Translation,
+		strings.Join(shuffled, " / "))
+
+	return Question{Kind: KindSentence, Prompt: prompt, Answer: entry.Example}, true
+}
+
+// RandomFrom generates a question with the generator from a random entry the generator can use.
+// It returns false when none of the entries can be used.
+func (engine *Engine) RandomFrom(entries []telegram.Entry,
+	generator func(telegram.Entry) (Question, bool)) (Question, bool) {
+	for _, idx := range engine.perm(len(entries)) {
+		if question, ok := generator(entries[idx]); ok {
+			return question, true
+		}
+	}
+
+	return Question{}, false
+}
@@ -0,0 +1,69 @@
+package quiz
+
+import (
+	"fmt"
+
+	"github.com/handracs2007/kquiz/telegram"
+)
+
+// KindReverse is the kind of the questions showing the translation and answered with the Korean word.
+const KindReverse = "reverse"
+
+// The conjoining jamo composing the Hangul syllables, see the Unicode standard, section 3.12.
+const (
+	syllableBase = 0xAC00
+	leadBase     = 0x1100
+	vowelBase    = 0x1161
+	tailBase     = 0x11A7
+	leadCount    = 19
+	vowelCount   = 21
+	tailCount    = 28
+	syllables    = leadCount * vowelCount * tailCount
+)
+
+// ForReverse generates a question asking for the Korean word of a translation.
+func ForReverse(entry telegram.Entry) Question {
+	return Question{
+		Kind:   KindReverse,
+		Prompt: fmt.Sprintf("How do you say in Korean: %s", entry.Translation),
+		Answer: entry.Word,
+		Word:   entry.Word,
+	}
+}
+
+// ForUser generates the question matching the kind of the entry in the direction chosen by the user, the vocabulary
+// being asked from the translation in the reverse direction.
+func ForUser(entry telegram.Entry, settings telegram.Settings) Question {
+	if settings.ReverseQuiz && entry.EntryKind() == telegram.KindVocabulary {
+		return ForReverse(entry)
+	}
+
+	return For(entry)
+}
+
+// composeHangul composes the conjoining jamo into syllables. Some keyboards and systems, e.g. macOS file names, send
+// the syllables decomposed, which look the same but do not compare equal.
+func composeHangul(text string) string {
+	composed := make([]rune, 0, len(text))
+	for _, r := range text {
+		if len(composed) == 0 {
+			composed = append(composed, r)
+			continue
+		}
+
+		last := composed[len(composed)-1]
+		switch {
+		case last >= leadBase && last < leadBase+leadCount && r >= vowelBase && r < vowelBase+vowelCount:
+			composed[len(composed)-1] = syllableBase + ((last-leadBase)*vowelCount+(r-vowelBase))*tailCount
+
+		case last >= syllableBase && last < syllableBase+syllables && (last-syllableBase)%tailCount == 0 &&
+			r > tailBase && r < tailBase+tailCount:
+			composed[len(composed)-1] = last + r - tailBase
+
+		default:
+			composed = append(composed, r)
+		}
+	}
+
+	return string(composed)
+}
@@ -0,0 +1,64 @@
+package quiz
+
+import (
+	"sort"
+	"time"
+
+	"github.com/handracs2007/kquiz/telegram"
+)
+
+// ReviewInterval is how long after its last review a word reviewed before the scheduler was introduced is due again.
+const ReviewInterval = 20 * time.Hour
+
+// DefaultMorningReviews is the number of due words reviewed in the morning when the user did not choose one.
+const DefaultMorningReviews = 20
+
+// DueQueue returns the entries due for review, the ones never reviewed first and then the longest overdue. The
+// mastered entries are never due.
+func DueQueue(entries []telegram.Entry, now time.Time) []telegram.Entry {
+	due := make([]telegram.Entry, 0, len(entries))
+	for _, entry := range entries {
+		if entry.MasteredAt != nil {
+			continue
+		}
+
+		if at, ok := DueAt(entry.WordEntry); !ok || !at.After(now) {
+			due = append(due, entry)
+		}
+	}
+
+	sort.SliceStable(due, func(i, j int) bool {
+		left, leftReviewed := DueAt(due[i].WordEntry)
+		right, rightReviewed := DueAt(due[j].WordEntry)
+		if !leftReviewed || !rightReviewed {
+			return !leftReviewed && rightReviewed
+		}
+
+		return left.Before(right)
+	})
+
+	return due
+}
+
+// ReviewBatch returns the part of the due queue to review now. With both pushes configured, the morning batch is
+// limited to the morning share until the evening push time, the evening batch is whatever is still due.
+func ReviewBatch(due []telegram.Entry, settings telegram.Settings, now time.Time) []telegram.Entry {
+	if len(settings.MorningReview) == 0 || len(settings.EveningReview) == 0 {
+		return due
+	}
+
+	if now.In(settings.Location()).Format("15:04") >= settings.EveningReview {
+		return due
+	}
+
+	count := settings.MorningReviews
+	if count <= 0 {
+		count = DefaultMorningReviews
+	}
+
+	if len(due) > count {
+		return due[:count]
+	}
+
+	return due
+}
@@ -0,0 +1,155 @@
+package quiz
+
+import (
+	"strings"
+	"unicode"
+)
+
+// The Revised Romanization of the jamo, in the order of the Unicode standard. The final consonants are romanized by
+// the sound they make at the end of a syllable.
+var (
+	leadRomanizations = []string{"g", "kk", "n", "d", "tt", "r", "m", "b", "pp", "s", "ss", "", "j", "jj", "ch", "k", "t",
+		"p", "h"}
+	vowelRomanizations = []string{"a", "ae", "ya", "yae", "eo", "e", "yeo", "ye", "o", "wa", "wae", "oe", "yo", "u", "wo",
+		"we", "wi", "yu", "eu", "ui", "i"}
+	tailRomanizations = []string{"", "k", "k", "k", "n", "n", "n", "t", "l", "k", "m", "l", "l", "l", "p", "l", "m", "p",
+		"p", "t", "t", "ng", "t", "t", "k", "t", "p", "t"}
+)
+
+// The final consonants carried over to a following syllable starting with the silent ㅇ, split into what stays in the
+// syllable and what starts the next one, e.g. ㄺ in 읽어 is ilg-eo.
+var (
+	linkedTails = []string{"", "", "", "k", "", "n", "n", "", "", "l", "l", "l", "l", "l", "l", "", "", "", "p", "", "",
+		"ng", "", "", "", "", "", ""}
+	linkedLeads = []string{"", "g", "kk", "s", "n", "j", "", "d", "r", "g", "m", "b", "s", "t", "p", "r", "m", "b", "s",
+		"s", "ss", "", "j", "ch", "k", "t", "p", ""}
+)
+
+// The indexes of the jamo the romanization rules depend on.
+const (
+	leadG      = 0
+	leadN      = 2
+	leadD      = 3
+	leadR      = 5
+	leadM      = 6
+	leadSilent = 11
+	leadJ      = 12
+	tailNH     = 6
+	tailLH     = 15
+	tailH      = 27
+)
+
+// aspiratedLeads are the consonants aspirated after ㅎ, e.g. 좋다 is jota.
+var aspiratedLeads = map[int]string{leadG: "k", leadD: "t", leadJ: "ch"}
+
+// decomposeSyllable returns the indexes of the lead consonant, the vowel and the final consonant of the syllable,
+// false when the rune is not a Hangul syllable.
+func decomposeSyllable(r rune) (int, int, int, bool) {
+	if r < syllableBase || r >= syllableBase+syllables {
+		return 0, 0, 0, false
+	}
+
+	index := int(r - syllableBase)
+	return index / (vowelCount * tailCount), index % (vowelCount * tailCount) / tailCount, index % tailCount, true
+}
+
+// nasalize returns the final sound nasalized before ㄴ, ㄹ or ㅁ, e.g. 입니다 is imnida.
+func nasalize(sound string) string {
+	switch sound {
+	case "k":
+		return "ng"
+	case "t":
+		return "n"
+	case "p":
+		return "m"
+	}
+
+	return sound
+}
+
+// joinSyllables returns the romanization of the final consonant of a syllable and of the lead consonant of the next
+// one, applying the sound changes between them.
+func joinSyllables(tail int, lead int) (string, string) {
+	if lead == leadSilent {
+		return linkedTails[tail], linkedLeads[tail]
+	}
+
+	coda, onset := tailRomanizations[tail], leadRomanizations[lead]
+	aspirated, ok := aspiratedLeads[lead]
+	switch {
+	case ok && (tail == tailH || tail == tailNH || tail == tailLH):
+		return strings.TrimSuffix(coda, "t"), aspirated
+	case lead == leadR && (coda == "n" || coda == "l"), lead == leadN && coda == "l":
+		return "l", "l"
+	case lead == leadR:
+		return nasalize(coda), "n"
+	case lead == leadN || lead == leadM:
+		return nasalize(coda), onset
+	}
+
+	return coda, onset
+}
+
+// Romanize returns the Revised Romanization of the Hangul in the text, e.g. hangugeo for 한국어, the other characters
+// are kept. The sound changes between the syllables of a word are applied, e.g. 신라 is silla.
+func Romanize(text string) string {
+	return romanize(text, true)
+}
+
+// romanize romanizes the Hangul in the text, syllable by syllable when the sound changes are not applied, e.g.
+// hangukeo for 한국어 as the learners often spell it.
+func romanize(text string, sounds bool) string {
+	runes := []rune(composeHangul(text))
+	var romanized strings.Builder
+	onset := ""
+	linked := false
+	for i, r := range runes {
+		lead, vowel, tail, ok := decomposeSyllable(r)
+		if !ok {
+			romanized.WriteRune(r)
+			linked = false
+			continue
+		}
+
+		if !linked {
+			onset = leadRomanizations[lead]
+		}
+		romanized.WriteString(onset)
+		romanized.WriteString(vowelRomanizations[vowel])
+
+		coda := tailRomanizations[tail]
+		linked = false
+		if i+1 < len(runes) && sounds {
+			if nextLead, _, _, ok := decomposeSyllable(runes[i+1]); ok {
+				coda, onset = joinSyllables(tail, nextLead)
+				linked = true
+			}
+		}
+		romanized.WriteString(coda)
+	}
+
+	return romanized.String()
+}
+
+// isRomanized reports whether the answer is romanized, with no Hangul in it.
+func isRomanized(answer string) bool {
+	for _, r := range answer {
+		if unicode.Is(unicode.Hangul, r) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// normalizeRomanization lowers the case of the romanized text and removes anything but the letters, e.g. the spaces,
+// the hyphens and the apostrophes separating the syllables.
+func normalizeRomanization(text string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return r
+		}
+
+		return -1
+	}, strings.ToLower(text))
+}
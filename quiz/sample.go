@@ -0,0 +1,50 @@
+package quiz
+
+import (
+	"bufio"
+	"embed"
+	"fmt"
+	"strings"
+
+	"github.com/handracs2007/kquiz/telegram"
+)
+
+//go:embed data/sample.tsv
+var data embed.FS
+
+// SampleDeck returns the built-in words new users are quizzed on before they add their own.
+func SampleDeck() ([]telegram.Entry, error) {
+	file, err := data.Open("data/sample.tsv")
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	entries := make([]telegram.Entry, 0)
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if len(text) == 0 || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		record := strings.Split(text, "\t")
+		if len(record) != 2 {
+			return nil, fmt.Errorf("data/sample.tsv line %d: expected 2 fields, got %d", line, len(record))
+		}
+
+		entries = append(entries, telegram.Entry{Word: record[0], WordEntry: telegram.WordEntry{Translation: record[1]}})
+	}
+
+	return entries, scanner.Err()
+}
+
+// ForSample makes the question trying a word of the sample deck. The word is not owned by the user, hence, it is not
+// reviewed.
+func ForSample(entry telegram.Entry) Question {
+	return Question{
+		Kind:   KindTranslation,
+		Prompt: fmt.Sprintf("Try it: what is %s?", entry.Word),
+		Answer: entry.Translation,
+	}
+}
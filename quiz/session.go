@@ -0,0 +1,288 @@
+package quiz
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/handracs2007/kquiz/telegram"
+)
+
+// SessionTimeout is how long a quiz session lasts without answers. The next answer starts a new session.
+const SessionTimeout = 30 * time.Minute
+
+// pointsPerAnswer is the score of a correct answer before the combo multiplier.
+const pointsPerAnswer = 10
+
+// maxComboMultiplier caps the combo multiplier so that a long streak does not dwarf the rest of the session.
+const maxComboMultiplier = 3
+
+// DefaultPracticeGoal is the number of correct answers ending an adaptive practice session by default.
+const DefaultPracticeGoal = 10
+
+// PracticeMinAccuracy ends an adaptive practice session early when the accuracy drops below it, once at least
+// practiceMinAnswers were given.
+const PracticeMinAccuracy = 0.5
+
+const practiceMinAnswers = 5
+
+// MaxQuizQuestions caps the number of questions of a quiz session.
+const MaxQuizQuestions = 50
+
+// Result is the outcome of a question of a quiz session, the word asked with its answer.
+type Result struct {
+	Word    string
+	Answer  string
+	Correct bool
+	Skipped bool
+	Hints   int
+}
+
+// Session tracks the answers of a user in a row of quizzes. A session with a goal is an adaptive practice session,
+// asking questions until the goal is reached or the accuracy drops too low. A session with a review queue asks the
+// queued words in order until none is left. A session with a number of questions is a quiz session, asking that many
+// questions unless stopped.
+type Session struct {
+	Answered   int
+	Correct    int
+	Combo      int
+	BestCombo  int
+	Score      int
+	Goal       int
+	Queue      []telegram.Entry
+	LastAnswer time.Time
+	// Players are the standings of the members in the quiz of a group, and StatusMessageID the pinned message showing
+	// them, zero when there is none.
+	Players         map[int64]*Player
+	StatusMessageID int
+	// LiveMessageID is the message of the quiz answered with buttons, edited in place from the question to the
+	// feedback and the next question. Zero when there is none.
+	LiveMessageID int
+	// Assignment tells that the review queue is a class assignment, which ends at the Deadline when it is not zero.
+	Assignment bool
+	Deadline   time.Time
+	// Tag limits the questions of the quiz answered with buttons to the words tagged with it, empty for all the words.
+	Tag string
+	// Missed are the words answered wrongly in the session, in the order they were first missed.
+	Missed []string
+	// Questions is the number of questions of a quiz session, zero for the other sessions, and Results the outcome of
+	// the questions answered or skipped so far.
+	Questions int
+	Results   []Result
+	// HintsUsed is the number of hints taken in the session.
+	HintsUsed int
+	// WarmUp are the well-known words asked first, before the hard material, see Engine.WarmUp.
+	WarmUp []telegram.Entry
+}
+
+// NextWarmUp removes the next word from the warm-up and returns it, false once the session is warmed up.
+func (session *Session) NextWarmUp() (telegram.Entry, bool) {
+	if len(session.WarmUp) == 0 {
+		return telegram.Entry{}, false
+	}
+
+	entry := session.WarmUp[0]
+	session.WarmUp = session.WarmUp[1:]
+	return entry, true
+}
+
+// QuizRunning reports whether the session is a quiz session with questions left to ask.
+func (session *Session) QuizRunning() bool {
+	return session.Questions > 0 && len(session.Results) < session.Questions
+}
+
+// AddResult records the outcome of the question of the quiz session. The exercises not reviewing a word, e.g. the
+// sentences, are recorded by their answer.
+func (session *Session) AddResult(question Question, correct bool) {
+	word := question.Word
+	if len(word) == 0 {
+		word = question.Answer
+	}
+
+	session.Results = append(session.Results, Result{Word: word, Answer: question.Answer, Correct: correct,
+		Hints: question.Hints})
+}
+
+// Skip passes the question of the quiz session, it counts as a wrong answer.
+func (session *Session) Skip(question Question, now time.Time) {
+	session.Record(false, false, now)
+	session.AddResult(question, false)
+	session.Results[len(session.Results)-1].Skipped = true
+	if len(question.Word) != 0 {
+		session.Miss(question.Word)
+	}
+}
+
+// QuizResults describes the outcome of each question of the quiz session, one per line, with short plain words
+// rather than emojis when plain.
+func (session *Session) QuizResults(plain bool) []string {
+	lines := make([]string, 0, len(session.Results))
+	for _, result := range session.Results {
+		mark, word := "✅", "correct"
+		if result.Skipped {
+			mark, word = "⏭", "skipped"
+		} else if !result.Correct {
+			mark, word = "❌", "wrong"
+		}
+
+		// The questions answered in Korean have the word as their answer.
+		text := result.Word
+		if result.Answer != result.Word {
+			text += " -> " + result.Answer
+		}
+		if result.Hints == 1 {
+			text += " (1 hint)"
+		} else if result.Hints > 1 {
+			text += fmt.Sprintf(" (%d hints)", result.Hints)
+		}
+
+		if plain {
+			lines = append(lines, fmt.Sprintf("%s, %s.", text, word))
+		} else {
+			lines = append(lines, fmt.Sprintf("%s %s", mark, text))
+		}
+	}
+
+	return lines
+}
+
+// Miss records the word as missed in the session, once.
+func (session *Session) Miss(word string) {
+	for _, missed := range session.Missed {
+		if missed == word {
+			return
+		}
+	}
+
+	session.Missed = append(session.Missed, word)
+}
+
+// Next removes the next word from the review queue and returns it.
+func (session *Session) Next() (telegram.Entry, bool) {
+	if len(session.Queue) == 0 {
+		return telegram.Entry{}, false
+	}
+
+	entry := session.Queue[0]
+	session.Queue = session.Queue[1:]
+	return entry, true
+}
+
+// Overdue reports whether the deadline of the assignment has passed.
+func (session *Session) Overdue(now time.Time) bool {
+	return !session.Deadline.IsZero() && now.After(session.Deadline)
+}
+
+// Accuracy returns the ratio of correct answers, 1 before any answer.
+func (session *Session) Accuracy() float64 {
+	if session.Answered == 0 {
+		return 1
+	}
+
+	return float64(session.Correct) / float64(session.Answered)
+}
+
+// PracticeOver reports whether the adaptive practice session is over, with the reason to tell the user. Good days
+// stretch until the goal, struggling days end gently once the accuracy drops below PracticeMinAccuracy.
+func (session *Session) PracticeOver() (bool, string) {
+	if session.Goal <= 0 {
+		return false, ""
+	}
+
+	if session.Correct >= session.Goal {
+		return true, fmt.Sprintf("Goal reached, %d correct answers!", session.Correct)
+	}
+
+	if session.Answered >= practiceMinAnswers && session.Accuracy() < PracticeMinAccuracy {
+		return true, "Let's call it a day, a short break helps the words sink in."
+	}
+
+	return false, ""
+}
+
+// Summary describes the results of the session, with the hints taken if any.
+func (session *Session) Summary() string {
+	hints := ""
+	if session.HintsUsed == 1 {
+		hints = ", 1 hint"
+	} else if session.HintsUsed > 1 {
+		hints = fmt.Sprintf(", %d hints", session.HintsUsed)
+	}
+
+	return fmt.Sprintf("%d of %d correct (%.0f%%), best combo %d, score %d%s.", session.Correct, session.Answered,
+		100*session.Accuracy(), session.BestCombo, session.Score, hints)
+}
+
+// Expired reports whether the session has been idle for too long to continue.
+func (session *Session) Expired(now time.Time) bool {
+	return now.Sub(session.LastAnswer) > SessionTimeout
+}
+
+// ComboMultiplier returns the multiplier of the points of a correct answer given the number of correct answers in a
+// row, including this one: x1 for the first two, x2 from the third and x3 from the sixth.
+func ComboMultiplier(combo int) int {
+	multiplier := 1 + combo/3
+	if multiplier > maxComboMultiplier {
+		return maxComboMultiplier
+	}
+
+	return multiplier
+}
+
+// Record counts an answer and returns the points it earned. With combos, the points of the correct answers in a row
+// are multiplied.
+func (session *Session) Record(correct bool, combos bool, now time.Time) int {
+	session.Answered++
+	session.LastAnswer = now
+
+	if !correct {
+		session.Combo = 0
+		return 0
+	}
+
+	session.Correct++
+	session.Combo++
+	if session.Combo > session.BestCombo {
+		session.BestCombo = session.Combo
+	}
+
+	points := pointsPerAnswer
+	if combos {
+		points *= ComboMultiplier(session.Combo)
+	}
+
+	session.Score += points
+	return points
+}
+
+// RecordHinted counts an answer given after the hints like Record, the hints lowering the points it earned, see
+// HintedPoints.
+func (session *Session) RecordHinted(correct bool, combos bool, hints int, now time.Time) int {
+	points := session.Record(correct, combos, now)
+	hinted := HintedPoints(points, hints)
+	session.Score -= points - hinted
+	return hinted
+}
+
+// ComboFeedback returns the cheer for the correct answers in a row, empty when the combo is too short to mention.
+func ComboFeedback(combo int) string {
+	switch {
+	case combo >= 10:
+		return fmt.Sprintf("%d in a row! Unstoppable! 🔥🔥🔥", combo)
+	case combo >= 5:
+		return fmt.Sprintf("%d in a row! 🔥🔥", combo)
+	case combo >= 3:
+		return fmt.Sprintf("%d in a row! 🔥", combo)
+	default:
+		return ""
+	}
+}
+
+// PlainComboFeedback returns the correct answers in a row as a plain sentence, empty when the combo is too short to
+// mention.
+func PlainComboFeedback(combo int) string {
+	if combo < 3 {
+		return ""
+	}
+
+	return fmt.Sprintf("%d correct answers in a row.", combo)
+}
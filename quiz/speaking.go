@@ -0,0 +1,73 @@
+package quiz
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/handracs2007/kquiz/telegram"
+)
+
+// KindSpeaking is the kind of the questions answered by saying the word in a voice message.
+const KindSpeaking = "speaking"
+
+// ForSpeaking generates a speaking exercise asking the user to pronounce the word.
+func ForSpeaking(entry telegram.Entry) Question {
+	return Question{
+		Kind:   KindSpeaking,
+		Prompt: fmt.Sprintf("Say %s (%s) in a voice message.", entry.Word, entry.Translation),
+		Answer: entry.Word,
+	}
+}
+
+// distance returns the Levenshtein distance between the 2 strings, counted in characters.
+func distance(a string, b string) int {
+	first, second := []rune(a), []rune(b)
+
+	previous := make([]int, len(second)+1)
+	current := make([]int, len(second)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(first); i++ {
+		current[0] = i
+		for j := 1; j <= len(second); j++ {
+			cost := 1
+			if first[i-1] == second[j-1] {
+				cost = 0
+			}
+
+			current[j] = minInt(minInt(previous[j]+1, current[j-1]+1), previous[j-1]+cost)
+		}
+
+		previous, current = current, previous
+	}
+
+	return previous[len(second)]
+}
+
+func minInt(a int, b int) int {
+	if a < b {
+		return a
+	}
+
+	return b
+}
+
+// PronunciationScore gives a rough pronunciation score between 0 and 100 from what the speech recognition understood.
+// The similarity of the transcript to the target word is weighted by the confidence of the recognition.
+func PronunciationScore(target string, transcript string, confidence float64) int {
+	expected := normalizeSentence(target)
+	heard := normalizeSentence(transcript)
+	if len(expected) == 0 || len(heard) == 0 {
+		return 0
+	}
+
+	longest := len([]rune(expected))
+	if length := len([]rune(heard)); length > longest {
+		longest = length
+	}
+
+	similarity := 1 - float64(distance(expected, heard))/float64(longest)
+	return int(math.Round(100 * similarity * confidence))
+}
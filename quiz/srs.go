@@ -0,0 +1,110 @@
+package quiz
+
+import (
+	"time"
+
+	"github.com/handracs2007/kquiz/telegram"
+)
+
+// The parameters of SM-2. The first two intervals are fixed, the later ones grow by the ease factor of the word.
+const (
+	initialEase    = 2.5
+	minimumEase    = 1.3
+	firstInterval  = 24 * time.Hour
+	secondInterval = 6 * 24 * time.Hour
+)
+
+// The qualities of the answers on the scale of SM-2, from 0 to 5. The quizzes only tell right from wrong, a correct
+// answer is taken as recalled after some hesitation and an incorrect one as recognised once revealed.
+const (
+	qualityCorrect   = 4
+	qualityIncorrect = 1
+)
+
+// A word is mastered once answered correctly MasteryRepetitions times in a row, the last time after an interval of at
+// least MasteryInterval, or of the longest interval allowed when it is shorter.
+const (
+	MasteryRepetitions = 6
+	MasteryInterval    = 90 * 24 * time.Hour
+)
+
+// Scheduler schedules the next review of the words with SM-2, words answered correctly are reviewed less and less
+// often, the others start over. The words still remembered after the longest intervals are mastered, and unmastered
+// as soon as they are forgotten.
+type Scheduler struct {
+	maxInterval time.Duration
+}
+
+// NewScheduler creates a new instance of Scheduler. The intervals are capped at maxInterval, unless it is 0.
+func NewScheduler(maxInterval time.Duration) Scheduler {
+	return Scheduler{maxInterval: maxInterval}
+}
+
+// Review returns the entry rescheduled after being answered at the given time.
+func (scheduler Scheduler) Review(entry telegram.WordEntry, correct bool, now time.Time) telegram.WordEntry {
+	quality := qualityIncorrect
+	if correct {
+		quality = qualityCorrect
+	}
+
+	ease := entry.Ease
+	if ease == 0 {
+		ease = initialEase
+	}
+
+	ease += 0.1 - float64(5-quality)*(0.08+float64(5-quality)*0.02)
+	if ease < minimumEase {
+		ease = minimumEase
+	}
+
+	masteryInterval := MasteryInterval
+	if scheduler.maxInterval > 0 && scheduler.maxInterval < masteryInterval {
+		masteryInterval = scheduler.maxInterval
+	}
+
+	// The interval survived is the one of the previous review.
+	if quality < 3 {
+		entry.MasteredAt = nil
+	} else if entry.MasteredAt == nil && entry.Repetitions+1 >= MasteryRepetitions && entry.Interval >= masteryInterval {
+		entry.MasteredAt = &now
+	}
+
+	if quality < 3 {
+		entry.Repetitions = 0
+		entry.Interval = firstInterval
+	} else {
+		entry.Repetitions++
+		switch entry.Repetitions {
+		case 1:
+			entry.Interval = firstInterval
+		case 2:
+			entry.Interval = secondInterval
+		default:
+			entry.Interval = time.Duration(float64(entry.Interval) * ease)
+		}
+	}
+
+	if scheduler.maxInterval > 0 && entry.Interval > scheduler.maxInterval {
+		entry.Interval = scheduler.maxInterval
+	}
+
+	due := now.Add(entry.Interval)
+	entry.Ease = ease
+	entry.LastReviewed = &now
+	entry.Due = &due
+	return entry
+}
+
+// DueAt returns when the entry is due for review. Entries reviewed before the scheduler was introduced are due a
+// ReviewInterval after their last review, it returns false for the entries never reviewed.
+func DueAt(entry telegram.WordEntry) (time.Time, bool) {
+	if entry.Due != nil {
+		return *entry.Due, true
+	}
+
+	if entry.LastReviewed != nil {
+		return entry.LastReviewed.Add(ReviewInterval), true
+	}
+
+	return time.Time{}, false
+}
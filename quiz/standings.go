@@ -0,0 +1,63 @@
+package quiz
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Player represents a member of a group taking part in the quiz of the group.
+type Player struct {
+	Name    string
+	Correct int
+	Score   int
+}
+
+// RecordPlayer counts the answer of a member of the group in the standings, with the points the answer earned.
+func (session *Session) RecordPlayer(userID int64, name string, correct bool, points int) {
+	if session.Players == nil {
+		session.Players = make(map[int64]*Player)
+	}
+
+	player, ok := session.Players[userID]
+	if !ok {
+		player = &Player{}
+		session.Players[userID] = player
+	}
+
+	player.Name = name
+	if correct {
+		player.Correct++
+	}
+	player.Score += points
+}
+
+// Standings describes the players of the group ranked by their score.
+func (session *Session) Standings() string {
+	players := make([]*Player, 0, len(session.Players))
+	for _, player := range session.Players {
+		players = append(players, player)
+	}
+
+	sort.Slice(players, func(i, j int) bool {
+		if players[i].Score != players[j].Score {
+			return players[i].Score > players[j].Score
+		}
+
+		return players[i].Name < players[j].Name
+	})
+
+	if len(players) == 0 {
+		return "No answers yet."
+	}
+
+	var sb strings.Builder
+	for i, player := range players {
+		if i > 0 {
+			sb.WriteString("\n")
+		}
+		sb.WriteString(fmt.Sprintf("%d. %s - %d points, %d correct", i+1, player.Name, player.Score, player.Correct))
+	}
+
+	return sb.String()
+}
@@ -0,0 +1,49 @@
+package quiz
+
+import (
+	"sort"
+
+	"github.com/handracs2007/kquiz/telegram"
+)
+
+// MaxWarmUp caps the number of well-known words starting a session.
+const MaxWarmUp = 3
+
+// warmUpRepetitions is the number of correct answers in a row making a word well-known enough to warm up with.
+const warmUpRepetitions = 3
+
+// WarmUp returns up to count well-known words to start a session with before the hard material, picked at random
+// among the easiest ones so that the warm-up varies. The mastered words, the words in the mistake notebook and the
+// words already queued in the session are left out.
+func (engine *Engine) WarmUp(entries []telegram.Entry, count int, queued []telegram.Entry) []telegram.Entry {
+	skip := make(map[string]bool, len(queued))
+	for _, entry := range queued {
+		skip[entry.Word] = true
+	}
+
+	known := make([]telegram.Entry, 0)
+	for _, entry := range Active(entries) {
+		if !skip[entry.Word] && entry.MistakenAt == nil && entry.Repetitions >= warmUpRepetitions {
+			known = append(known, entry)
+		}
+	}
+
+	// The candidates are the twice as many easiest words, the longest intervals first.
+	sort.SliceStable(known, func(i, j int) bool {
+		return known[i].Interval > known[j].Interval
+	})
+	if len(known) > 2*count {
+		known = known[:2*count]
+	}
+
+	warmUp := make([]telegram.Entry, 0, count)
+	for _, i := range engine.perm(len(known)) {
+		if len(warmUp) == count {
+			break
+		}
+
+		warmUp = append(warmUp, known[i])
+	}
+
+	return warmUp
+}
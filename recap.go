@@ -0,0 +1,236 @@
+package main
+
+import (
+	"fmt"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+	"github.com/handracs2007/kquiz/quiz"
+	"github.com/handracs2007/kquiz/telegram"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// recapSize is the number of missed words listed in a recap, with their buttons.
+const recapSize = 10
+
+// missedTag is the tag of the missed words tagged from a recap, to quiz them with /quiz #missed.
+const missedTag = "missed"
+
+// The actions of the buttons of a recap, following recapCallbackPrefix. Tag and note are followed by the index of the
+// missed word.
+const (
+	recapTag   = "t:"
+	recapNote  = "n:"
+	recapDrill = "d"
+)
+
+// recap is the message ending a session with the words missed in it. It is edited in place as the user acts on them.
+type recap struct {
+	messageID int
+	heading   string
+	missed    []telegram.Entry
+	tagged    map[string]bool
+	noted     map[string]bool
+	drilled   bool
+}
+
+// notePrompt is the message asking for the notes of a word missed in a recap, the reply to it is the notes.
+type notePrompt struct {
+	messageID int
+	word      string
+}
+
+// text returns the text of the recap, marking the words tagged or noted.
+func (r *recap) text() string {
+	lines := []string{r.heading, "", "Missed words:"}
+	for _, entry := range r.missed {
+		line := fmt.Sprintf("%s -> %s", entry.Word, entry.Translation)
+		if r.tagged[entry.Word] {
+			line += " #" + missedTag
+		}
+		if r.noted[entry.Word] {
+			line += " (noted)"
+		}
+
+		lines = append(lines, line)
+	}
+
+	if r.drilled {
+		lines = append(lines, "", "Drilling them again.")
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// keyboard returns the buttons tagging or noting each missed word, and drilling them all again.
+func (r *recap) keyboard() tgbotapi.InlineKeyboardMarkup {
+	rows := make([][]tgbotapi.InlineKeyboardButton, 0, len(r.missed)+1)
+	for i, entry := range r.missed {
+		index := strconv.Itoa(i)
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("Tag "+entry.Word, recapCallbackPrefix+recapTag+index),
+			tgbotapi.NewInlineKeyboardButtonData("Note "+entry.Word, recapCallbackPrefix+recapNote+index)))
+	}
+
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("Drill them again", recapCallbackPrefix+recapDrill)))
+
+	return tgbotapi.NewInlineKeyboardMarkup(rows...)
+}
+
+// sendRecap ends the session with the heading, e.g. Review done!, and its summary. The words missed in the session
+// follow with their buttons, unless the user turned the recaps off.
+func (d *dispatcher) sendRecap(chatID int64, heading string, session *quiz.Session) {
+	heading = fmt.Sprintf("%s\n%s", heading, session.Summary())
+	settings, err := d.settingsStore.Settings(chatID)
+	if err != nil {
+		log.Printf("Failed to read settings, using the defaults. %s.\n", err)
+	}
+
+	r := &recap{heading: heading, tagged: make(map[string]bool), noted: make(map[string]bool)}
+	if !settings.NoRecap {
+		for _, word := range session.Missed {
+			if len(r.missed) == recapSize {
+				break
+			}
+
+			entry, err := d.words.SearchEntry(chatID, word)
+			if err == nil {
+				r.missed = append(r.missed, telegram.Entry{Word: word, WordEntry: *entry})
+			}
+		}
+	}
+
+	msg := tgbotapi.NewMessage(chatID, heading)
+	if len(r.missed) != 0 {
+		msg.Text = r.text()
+		msg.ReplyMarkup = r.keyboard()
+	}
+
+	message, err := d.bot.Send(msg)
+	if err != nil {
+		log.Printf("Failed to send session recap. %s.\n", err)
+		return
+	}
+
+	if len(r.missed) != 0 {
+		r.messageID = message.MessageID
+		d.recaps[chatID] = r
+	}
+}
+
+// edit returns the edit of the recap in place after the user acted on it.
+func (r *recap) edit(chatID int64) tgbotapi.Chattable {
+	keyboard := r.keyboard()
+	edit := tgbotapi.NewEditMessageText(chatID, r.messageID, r.text())
+	edit.ReplyMarkup = &keyboard
+
+	return edit
+}
+
+// actOnRecap handles the buttons of the recap: tagging a missed word, asking for its notes, or drilling the missed
+// words again. It returns the edit of the recap, or nil when it has nothing more to reply. The buttons of the earlier
+// recaps are stale and ignored.
+func (d *dispatcher) actOnRecap(query *tgbotapi.CallbackQuery) tgbotapi.Chattable {
+	chatID := query.Message.Chat.ID
+	r, ok := d.recaps[chatID]
+	if !ok || r.messageID != query.Message.MessageID {
+		return nil
+	}
+
+	action := strings.TrimPrefix(query.Data, recapCallbackPrefix)
+	if action == recapDrill {
+		r.drilled = true
+		respond(d.bot, "recap", r.edit(chatID))
+
+		// The drill is a review of the missed words, with a recap of its own.
+		session := &quiz.Session{Queue: append([]telegram.Entry(nil), r.missed...), LastAnswer: time.Now()}
+		d.sessions[chatID] = session
+		d.continueReview(d.bot, chatID, session)
+		return nil
+	}
+
+	var index int
+	var err error
+	switch {
+	case strings.HasPrefix(action, recapTag):
+		index, err = strconv.Atoi(strings.TrimPrefix(action, recapTag))
+	case strings.HasPrefix(action, recapNote):
+		index, err = strconv.Atoi(strings.TrimPrefix(action, recapNote))
+	default:
+		return nil
+	}
+	if err != nil || index < 0 || index >= len(r.missed) {
+		return nil
+	}
+
+	word := r.missed[index].Word
+	if strings.HasPrefix(action, recapNote) {
+		msg := tgbotapi.NewMessage(chatID, fmt.Sprintf("Reply with the notes for %s, e.g. a mnemonic.", word))
+		msg.ReplyMarkup = tgbotapi.ForceReply{ForceReply: true, Selective: true}
+
+		// The notes come as the reply to the prompt, so its ID is kept.
+		message, err := d.bot.Send(msg)
+		if err != nil {
+			log.Printf("Failed to ask for notes. %s.\n", err)
+			return nil
+		}
+
+		d.notePrompts[chatID] = notePrompt{messageID: message.MessageID, word: word}
+		return nil
+	}
+
+	err = d.updater.AddTag(chatID, word, missedTag)
+	if err != nil {
+		return tgbotapi.NewMessage(chatID, fmt.Sprintf("Tag word failed. %s.", err))
+	}
+
+	r.tagged[word] = true
+	return r.edit(chatID)
+}
+
+// noteFromRecap saves the notes replied to the prompt of the recap, and returns the edit of the recap marking the
+// word noted, or nil when there is nothing to reply.
+func (d *dispatcher) noteFromRecap(chatID int64, prompt notePrompt, notes string) tgbotapi.Chattable {
+	delete(d.notePrompts, chatID)
+
+	notes = strings.TrimSpace(notes)
+	if len(notes) == 0 {
+		return nil
+	}
+
+	err := d.updater.SetNotes(chatID, prompt.word, notes)
+	if err != nil {
+		return tgbotapi.NewMessage(chatID, fmt.Sprintf("Set notes failed. %s.", err))
+	}
+
+	r, ok := d.recaps[chatID]
+	if !ok {
+		return nil
+	}
+
+	r.noted[prompt.word] = true
+	return r.edit(chatID)
+}
+
+// setRecap turns the recaps of the missed words at the end of the sessions on or off.
+func setRecap(manager telegram.SettingsManager, chatID int64, enabled bool) tgbotapi.Chattable {
+	var msg tgbotapi.MessageConfig
+	settings, err := manager.Settings(chatID)
+	if err == nil {
+		settings.NoRecap = !enabled
+		err = manager.SaveSettings(chatID, settings)
+	}
+
+	if err != nil {
+		msg = tgbotapi.NewMessage(chatID, fmt.Sprintf("Save settings failed. %s.", err))
+	} else if enabled {
+		msg = tgbotapi.NewMessage(chatID, "Session recaps are on. The sessions end with the words you missed, to "+
+			"tag, note or drill them again.")
+	} else {
+		msg = tgbotapi.NewMessage(chatID, "Session recaps are off. The sessions end with their summary only.")
+	}
+
+	return msg
+}
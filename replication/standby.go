@@ -0,0 +1,89 @@
+package replication
+
+import (
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/handracs2007/kquiz/telegram"
+	"github.com/handracs2007/kquiz/web"
+)
+
+// Standby keeps a copy of the databases of the primary instance by downloading its snapshots periodically, ready to
+// take over when the primary dies. It neither opens the databases nor talks to Telegram until it is promoted.
+type Standby struct {
+	primaryURL string
+	token      string
+	dbPath     string
+	interval   time.Duration
+}
+
+// NewStandby creates a new instance of Standby replicating the primary at the URL into the database files laid out
+// from the path.
+func NewStandby(primaryURL string, token string, dbPath string, interval time.Duration) Standby {
+	return Standby{primaryURL: strings.TrimSuffix(primaryURL, "/"), token: token, dbPath: dbPath, interval: interval}
+}
+
+// sync downloads the snapshot of every shard of the primary.
+func (standby Standby) sync() error {
+	count := 1
+	for shard := 0; shard < count; shard++ {
+		var err error
+		count, _, err = web.DownloadBackup(standby.primaryURL, standby.token, shard,
+			telegram.ShardPath(standby.dbPath, shard))
+		if err != nil {
+			return err
+		}
+	}
+
+	log.Printf("Replicated %d shards from the primary.\n", count)
+	return nil
+}
+
+// Run replicates the primary until an admin promotes the standby with a POST to /admin/promote on the given address,
+// then it makes a last attempt to replicate and reports true so that the bot starts. It reports false when the
+// process is asked to stop instead.
+func (standby Standby) Run(httpAddr string) bool {
+	promoted := make(chan struct{})
+
+	server := web.NewServer(httpAddr)
+	server.Handle("/admin/promote", web.NewPromoteHandler(standby.token, func() {
+		close(promoted)
+	}))
+	server.Start()
+	defer server.Shutdown(10 * time.Second)
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(stop)
+
+	ticker := time.NewTicker(standby.interval)
+	defer ticker.Stop()
+
+	log.Printf("Standby replicating %s every %s.\n", standby.primaryURL, standby.interval)
+	for {
+		err := standby.sync()
+		if err != nil {
+			log.Printf("Failed to replicate the primary. %s.\n", err)
+		}
+
+		select {
+		case <-ticker.C:
+		case <-promoted:
+			// The primary is most likely gone, but it may only be unreachable from here. Let's try to get the
+			// latest data anyway, the last successful snapshot is used otherwise.
+			err = standby.sync()
+			if err != nil {
+				log.Printf("Failed to replicate the primary before taking over, using the last snapshot. %s.\n", err)
+			}
+
+			log.Println("Standby promoted to primary.")
+			return true
+		case <-stop:
+			return false
+		}
+	}
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"fmt"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+	"github.com/handracs2007/kquiz/nudge"
+	"github.com/handracs2007/kquiz/quiz"
+	"github.com/handracs2007/kquiz/scheduler"
+	"github.com/handracs2007/kquiz/storage"
+	"github.com/handracs2007/kquiz/telegram"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The review pushes of the day.
+const (
+	morningSlot = "morning"
+	eveningSlot = "evening"
+)
+
+// dueReviews lists the words of the user due for review now, the morning share of them before the evening push.
+func dueReviews(lister storage.Lister, settings telegram.Settings, chatID int64,
+	now time.Time) ([]telegram.Entry, error) {
+	entries := make([]telegram.Entry, 0)
+	for _, kind := range []string{telegram.KindVocabulary, telegram.KindGrammar} {
+		kindEntries, err := lister.ListEntries(chatID, kind)
+		if err != nil {
+			return nil, err
+		}
+
+		entries = append(entries, kindEntries...)
+	}
+
+	return quiz.ReviewBatch(quiz.DueQueue(entries, now), settings, now), nil
+}
+
+// startReview queues the words to review now into the session, it returns the reply and whether there is any.
+func startReview(lister storage.Lister, manager telegram.SettingsManager, chatID int64,
+	session *quiz.Session) (tgbotapi.Chattable, bool) {
+	var msg tgbotapi.MessageConfig
+	settings, err := manager.Settings(chatID)
+	if err != nil {
+		log.Printf("Failed to read settings, using the defaults. %s.\n", err)
+	}
+
+	batch, err := dueReviews(lister, settings, chatID, time.Now())
+	if err != nil {
+		msg = tgbotapi.NewMessage(chatID, fmt.Sprintf("Review failed. %s.", err))
+	} else if len(batch) == 0 {
+		msg = tgbotapi.NewMessage(chatID, "Nothing to review, well done!")
+	} else {
+		session.Queue = batch
+		msg = tgbotapi.NewMessage(chatID, fmt.Sprintf("Let's review %d words.", len(batch)))
+	}
+
+	return msg, len(session.Queue) != 0
+}
+
+// parseReviewTime validates a local time of a review push, e.g. 08:00.
+func parseReviewTime(value string) (string, bool) {
+	parsed, err := time.Parse("15:04", value)
+	if err != nil {
+		return "", false
+	}
+
+	return parsed.Format("15:04"), true
+}
+
+// parseReviews parses the argument of /reviews, the morning and evening times followed by the optional number of words
+// to review in the morning. An empty schedule turns the pushes off.
+func parseReviews(argument string) (telegram.Settings, bool) {
+	var schedule telegram.Settings
+	if argument == "off" {
+		return schedule, true
+	}
+
+	fields := strings.Fields(argument)
+	if len(fields) != 2 && len(fields) != 3 {
+		return schedule, false
+	}
+
+	var morningOK, eveningOK bool
+	schedule.MorningReview, morningOK = parseReviewTime(fields[0])
+	schedule.EveningReview, eveningOK = parseReviewTime(fields[1])
+	if !morningOK || !eveningOK || schedule.MorningReview >= schedule.EveningReview {
+		return schedule, false
+	}
+
+	schedule.MorningReviews = quiz.DefaultMorningReviews
+	if len(fields) == 3 {
+		count, err := strconv.Atoi(fields[2])
+		if err != nil || count < 1 {
+			return schedule, false
+		}
+
+		schedule.MorningReviews = count
+	}
+
+	return schedule, true
+}
+
+func setReviews(manager telegram.SettingsManager, chatID int64, schedule telegram.Settings) tgbotapi.Chattable {
+	var msg tgbotapi.MessageConfig
+	settings, err := manager.Settings(chatID)
+	if err == nil {
+		settings.MorningReview = schedule.MorningReview
+		settings.EveningReview = schedule.EveningReview
+		settings.MorningReviews = schedule.MorningReviews
+		err = manager.SaveSettings(chatID, settings)
+	}
+
+	if err != nil {
+		msg = tgbotapi.NewMessage(chatID, fmt.Sprintf("Save settings failed. %s.", err))
+	} else if len(schedule.MorningReview) == 0 {
+		msg = tgbotapi.NewMessage(chatID, "Review reminders are off.")
+	} else {
+		msg = tgbotapi.NewMessage(chatID, fmt.Sprintf("You will review up to %d words at %s and the rest at %s (%s).",
+			schedule.MorningReviews, schedule.MorningReview, schedule.EveningReview, settings.Location()))
+	}
+
+	return msg
+}
+
+func setTimezone(manager telegram.SettingsManager, chatID int64, timezone string) tgbotapi.Chattable {
+	var msg tgbotapi.MessageConfig
+	_, err := time.LoadLocation(timezone)
+	if err != nil || len(timezone) == 0 {
+		msg = tgbotapi.NewMessage(chatID, "Please provide a time zone such as Asia/Seoul.")
+	} else {
+		var settings telegram.Settings
+		settings, err = manager.Settings(chatID)
+		if err == nil {
+			settings.Timezone = timezone
+			err = manager.SaveSettings(chatID, settings)
+		}
+
+		if err != nil {
+			msg = tgbotapi.NewMessage(chatID, fmt.Sprintf("Save settings failed. %s.", err))
+		} else {
+			msg = tgbotapi.NewMessage(chatID, fmt.Sprintf("Time zone set to %s.", timezone))
+		}
+	}
+
+	return msg
+}
+
+// reviewPushes returns the job reminding the users of their due words at their morning and evening review times. The
+// morning push covers the morning share of the due words, the evening push whatever is still due by then. Besides the
+// placeholders of every recipient, the templates can use {slot}, the morning or the evening. With more than one
+// template, the users are split between them and the quizzes soon after are counted for each.
+func reviewPushes(audience storage.Audience, tracker telegram.ActivityTracker, lister storage.Lister,
+	manager telegram.SettingsManager, recorder telegram.AnalyticsRecorder, botAPI sender,
+	experiment nudge.Experiment) scheduler.Job {
+	// The job may run more than once within the minute of a push, let's remember the pushes sent lately.
+	sent := make(map[string]time.Time)
+
+	return func(now time.Time) {
+		for key, at := range sent {
+			if now.Sub(at) > 48*time.Hour {
+				delete(sent, key)
+			}
+		}
+
+		users, err := audience.Users()
+		if err != nil {
+			log.Printf("Failed to list users for review reminders. %s.\n", err)
+			return
+		}
+
+		for _, chatID := range users {
+			settings, err := manager.Settings(chatID)
+			if err != nil || len(settings.MorningReview) == 0 {
+				continue
+			}
+
+			local := now.In(settings.Location())
+			slot := ""
+			switch local.Format("15:04") {
+			case settings.MorningReview:
+				slot = morningSlot
+			case settings.EveningReview:
+				slot = eveningSlot
+			default:
+				continue
+			}
+
+			key := fmt.Sprintf("%d/%s/%s", chatID, slot, local.Format("2006-01-02"))
+			if _, ok := sent[key]; ok {
+				continue
+			}
+			sent[key] = now
+
+			values := recipientValues(tracker, manager, lister, chatID, now)
+			if values[nudge.DueCount] == "0" {
+				continue
+			}
+
+			values["slot"] = slot
+			variant, template := experiment.Variant(chatID)
+			msg := tgbotapi.NewMessage(chatID, nudge.Render(template, values))
+			msg.DisableNotification = settings.Silent(now)
+
+			_, err = botAPI.Send(msg)
+			if err != nil {
+				log.Printf("Failed to send review reminder. %s.\n", err)
+				continue
+			}
+
+			err = tracker.RecordNudge(chatID, experiment.Key(variant), now)
+			if err == nil {
+				err = recorder.Count(experiment.Key(variant) + "/" + nudge.EventSent)
+			}
+			if err != nil {
+				log.Printf("Failed to record review reminder. %s.\n", err)
+			}
+		}
+	}
+}
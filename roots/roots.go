@@ -0,0 +1,106 @@
+package roots
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/handracs2007/kquiz/hanja"
+	"github.com/handracs2007/kquiz/providers"
+)
+
+const hanjaRoot = "hanja:"
+const stemRoot = "stem:"
+
+// verbSuffixes are endings turning a noun into a verb or adjective, e.g. 공부하다 is built on the noun 공부.
+var verbSuffixes = []string{"하다", "되다", "시키다", "스럽다"}
+
+// Finder finds the roots that relate words to each other: the hanja characters of Sino-Korean words and the stems of
+// verbs and adjectives. The analyzer lemmatises the words first, e.g. 먹었어요 into 먹다.
+type Finder struct {
+	dict     *hanja.Dictionary
+	analyzer providers.Analyzer
+}
+
+// NewFinder creates a new instance of Finder
+func NewFinder(dict *hanja.Dictionary, analyzer providers.Analyzer) Finder {
+	return Finder{dict: dict, analyzer: analyzer}
+}
+
+// lemma returns the dictionary form of the word, the lemma of its first meaningful morpheme. The word is its own lemma
+// when the analysis fails.
+func (finder Finder) lemma(word string) string {
+	tokens, err := finder.analyzer.Analyze(word)
+	if err != nil {
+		return word
+	}
+
+	for _, token := range tokens {
+		if token.Content() {
+			return token.Lemma
+		}
+	}
+
+	return word
+}
+
+// stem returns the stem of a verb or adjective in its dictionary form. Any other word is its own stem.
+func stem(word string) string {
+	for _, suffix := range verbSuffixes {
+		if strings.HasSuffix(word, suffix) && word != suffix {
+			return strings.TrimSuffix(word, suffix)
+		}
+	}
+
+	if strings.HasSuffix(word, "다") && word != "다" {
+		return strings.TrimSuffix(word, "다")
+	}
+
+	return word
+}
+
+// Roots returns the roots of the word. Words sharing at least one root are related.
+func (finder Finder) Roots(word string) []string {
+	word = strings.TrimSpace(word)
+	wordStem := stem(finder.lemma(word))
+	roots := []string{stemRoot + wordStem}
+
+	breakdown, err := finder.dict.Lookup(wordStem)
+	if err != nil {
+		return roots
+	}
+
+	seen := make(map[string]bool)
+	for _, character := range breakdown.Characters {
+		if seen[character.Hanja] {
+			continue
+		}
+
+		seen[character.Hanja] = true
+		roots = append(roots, hanjaRoot+character.Hanja)
+	}
+
+	return roots
+}
+
+// Describe returns a human readable description of the root.
+func (finder Finder) Describe(root string) string {
+	if strings.HasPrefix(root, hanjaRoot) {
+		char := strings.TrimPrefix(root, hanjaRoot)
+		if character, ok := finder.dict.Character(char); ok {
+			return fmt.Sprintf("%s (%s)", char, character.Meaning)
+		}
+
+		return char
+	}
+
+	return fmt.Sprintf("%s-", strings.TrimPrefix(root, stemRoot))
+}
+
+// DictionaryWords returns the words of the reference dictionary sharing the root.
+func (finder Finder) DictionaryWords(root string) []string {
+	if !strings.HasPrefix(root, hanjaRoot) {
+		return nil
+	}
+
+	return finder.dict.WordsWith(strings.TrimPrefix(root, hanjaRoot))
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+)
+
+// parseCommand splits the text of a message into the command and its argument. In groups, commands must either mention
+// the bot, e.g. /add@kquizbot, or start with the prefix chosen by the group, e.g. !add, so that the commands meant for
+// the other bots in the group are left alone. It returns false when the message is not addressed to the bot.
+func parseCommand(text string, botName string, prefix string, group bool) (string, string, bool) {
+	command := text
+	argument := ""
+
+	// Message can contain parameters, hence, let's get the first text before space as the command and store the rest
+	// as arguments.
+	if spaceIndex := strings.Index(text, " "); spaceIndex != -1 {
+		command = text[:spaceIndex]
+		argument = text[spaceIndex+1:]
+	}
+
+	if len(prefix) != 0 && strings.HasPrefix(command, prefix) && len(command) > len(prefix) {
+		return "/" + strings.TrimPrefix(command, prefix), argument, true
+	}
+
+	if !strings.HasPrefix(command, "/") {
+		// Not a command, most likely the answer of a quiz.
+		return command, argument, true
+	}
+
+	atIndex := strings.Index(command, "@")
+	if atIndex == -1 {
+		return command, argument, !group
+	}
+
+	if !strings.EqualFold(command[atIndex+1:], botName) {
+		return "", "", false
+	}
+
+	return command[:atIndex], argument, true
+}
+
+// validPrefix reports whether the prefix can be used for the commands of a group. Prefixes are a few ASCII punctuation
+// characters, e.g. ! or ;;, so that they are not mistaken for an answer.
+func validPrefix(prefix string) bool {
+	if len(prefix) == 0 || len(prefix) > 3 || strings.HasPrefix(prefix, "/") {
+		return false
+	}
+
+	for _, r := range prefix {
+		if r > unicode.MaxASCII || !(unicode.IsPunct(r) || unicode.IsSymbol(r)) {
+			return false
+		}
+	}
+
+	return true
+}
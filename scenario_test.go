@@ -0,0 +1,360 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+	"github.com/handracs2007/kquiz/config"
+	"github.com/handracs2007/kquiz/events"
+	"github.com/handracs2007/kquiz/features"
+	"github.com/handracs2007/kquiz/hanja"
+	"github.com/handracs2007/kquiz/janitor"
+	"github.com/handracs2007/kquiz/metrics"
+	"github.com/handracs2007/kquiz/providers"
+	"github.com/handracs2007/kquiz/quiz"
+	"github.com/handracs2007/kquiz/roots"
+	"github.com/handracs2007/kquiz/storage"
+	"github.com/handracs2007/kquiz/telegram"
+	"github.com/handracs2007/kquiz/updates"
+	"go.etcd.io/bbolt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden transcripts of the scenarios")
+
+// scenarios are the conversations replayed through the dispatcher, one user message or button press per line. Their
+// transcripts are compared against testdata/<name>.golden. In the scripts, {answer} stands for the answer of the
+// question the user is to answer, {wrong} for a wrong one, and press <label> presses the button of the last message
+// offering it.
+var scenarios = map[string][]string{
+	"onboarding": {
+		"/start",
+		"press Accept",
+		"{answer}",
+		"/start",
+	},
+	"words": {
+		"/start",
+		"press Accept",
+		"/add 학교 school",
+		"/add 버스 bus #교통",
+		"/add 학교 schools",
+		"/search 학교",
+		"/list",
+		"/list #교통",
+		"/delete 버스",
+		"/delete 버스",
+		"/list",
+		"/clear",
+		"/list",
+	},
+	"quiz": {
+		"/start",
+		"press Accept",
+		"/add 학교 school",
+		"/add 버스 bus",
+		"/add 사과 apple",
+		"/quiz 3",
+		"{answer}",
+		"{wrong}",
+		"/skip",
+		"/quiz 2",
+		"/stop",
+	},
+	"unregister": {
+		"/start",
+		"press Accept",
+		"/add 학교 school",
+		"/stop",
+		"/list",
+	},
+}
+
+// scenarioChatID is the chat of the user of the scenarios, a private chat.
+const scenarioChatID = int64(424242)
+
+// newScenarioDispatcher returns a dispatcher storing its data in a temporary database, sending through the fake sender
+// and quizzing with a fixed seed, so that the transcripts are reproducible. The external providers are offline.
+func newScenarioDispatcher(t *testing.T, fake *fakeSender) *dispatcher {
+	cfg := config.Default()
+
+	shards, err := telegram.OpenShards(filepath.Join(t.TempDir(), "kquiz.db"), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = shards.Close()
+	})
+
+	db := shards.Main()
+	buckets := cfg.Buckets
+	for _, bucketName := range []string{buckets.Kquiz, buckets.Telegram, buckets.Deck, buckets.Relation,
+		buckets.Pronunciation, buckets.Settings, buckets.Activity, buckets.Pending, buckets.Stats, buckets.Transcript,
+		buckets.Snapshot, buckets.Session, buckets.Recent, buckets.Export, buckets.Cache, buckets.Usage,
+		buckets.Channel, buckets.Content, buckets.Analytics, buckets.Audio, buckets.Template, buckets.Leaderboard,
+		buckets.Access, buckets.Token, buckets.Ban} {
+		err = shards.Update(func(tx *bbolt.Tx) error {
+			_, err := tx.CreateBucketIfNotExists([]byte(bucketName))
+			return err
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	hanjaDict, err := hanja.NewDictionary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg.Providers.Offline = true
+	registry, err := providers.NewRegistry(cfg.Providers)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sampleDeck, err := quiz.SampleDeck()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rootFinder := roots.NewFinder(hanjaDict, registry.Analyzer)
+	repo := storage.NewBoltRepository(shards, buckets.Telegram, buckets.Kquiz, buckets.Relation, rootFinder, nil)
+	statsStore := telegram.NewStatsStore(shards, buckets.Stats, nil)
+	settingsStore := telegram.NewSettingsStore(shards, buckets.Settings, nil)
+	emitter := events.NewEmitter(settingsStore)
+	usageStore := telegram.NewUsageStore(db, buckets.Usage)
+
+	return &dispatcher{
+		bot:                 fake,
+		bulk:                fake,
+		botName:             "kquizbot",
+		botID:               1,
+		users:               repo,
+		words:               repo,
+		adder:               events.NewAdder(storage.NewCountingAdder(repo, statsStore), emitter),
+		updater:             repo,
+		hanjaDict:           hanjaDict,
+		rootFinder:          rootFinder,
+		registry:            registry,
+		featureFlags:        features.New(registry, true, nil),
+		admins:              map[int64]bool{},
+		quizEngine:          quiz.NewEngine(1),
+		reviewScheduler:     quiz.NewScheduler(time.Duration(cfg.MaxReviewInterval)),
+		pending:             telegram.NewPendingStore(shards, buckets.Pending, time.Duration(cfg.PendingQuestionTTL)),
+		sessionStore:        telegram.NewSessionStore(shards, buckets.Session),
+		recent:              telegram.NewRecentStore(shards, buckets.Recent, recentQuestionsSize),
+		cooldown:            quiz.Cooldown{Questions: cfg.CooldownQuestions, Window: time.Duration(cfg.CooldownWindow)},
+		grader:              quiz.NewGrader(cfg.TypoTolerance),
+		sessions:            make(map[int64]*quiz.Session),
+		reveals:             make(map[int64]reveal),
+		recaps:              make(map[int64]*recap),
+		notePrompts:         make(map[int64]notePrompt),
+		cleanups:            make(map[int64]*cleanup),
+		conflicts:           make(map[int64]*conflictReview),
+		lemmaPrompts:        make(map[int64]lemmaPrompt),
+		sampleDeck:          sampleDeck,
+		privacyVersion:      cfg.PrivacyVersion,
+		privacyNotice:       cfg.PrivacyNotice,
+		channelStore:        telegram.NewChannelStore(db, buckets.Channel, buckets.Content),
+		wordOfTheDayTime:    cfg.WordOfTheDayTime,
+		settingsStore:       settingsStore,
+		activityStore:       telegram.NewActivityStore(shards, buckets.Activity, nil),
+		statsStore:          statsStore,
+		leaderboard:         telegram.NewLeaderboardStore(db, buckets.Leaderboard),
+		access:              telegram.NewAccessStore(db, buckets.Access),
+		tokens:              telegram.NewTokenStore(db, buckets.Token),
+		bans:                telegram.NewBanStore(db, buckets.Ban),
+		events:              emitter,
+		analyticsStore:      telegram.NewAnalyticsStore(db, buckets.Analytics, "scenario"),
+		janitor:             janitor.New(fake),
+		pronunciationStore:  telegram.NewPronunciationStore(shards, buckets.Pronunciation, nil),
+		transcriptStore:     telegram.NewTranscriptStore(shards, buckets.Transcript, nil),
+		snapshotStore:       telegram.NewSnapshotStore(shards, buckets.Snapshot, nil),
+		deckStore:           storage.NewDeckCounter(telegram.NewDeckStore(shards, buckets.Deck, nil), repo),
+		exportLinks:         telegram.NewExportLinkStore(db, buckets.Export),
+		templateStore:       telegram.NewTemplateStore(db, buckets.Template),
+		publicURL:           cfg.PublicURL,
+		exportLinkTTL:       time.Duration(cfg.ExportLinkTTL),
+		translationLanguage: cfg.TranslationLanguage,
+		responseCache:       telegram.NewResponseCache(db, buckets.Cache),
+		audioCache:          telegram.NewAudioCache(db, buckets.Audio, cfg.AudioCacheMB<<20),
+		audioCacheSize:      cfg.AudioCacheMB << 20,
+		usageStore:          usageStore,
+		metrics:             metrics.New(),
+		budget:              providers.NewBudget(usageStore, cfg.Costs, cfg.DailyBudgets, func(string, float64) {}),
+	}
+}
+
+// scenarioUser is the user of the scenarios, writing to the bot in private.
+var scenarioUser = &tgbotapi.User{ID: int(scenarioChatID), UserName: "learner", LanguageCode: "en"}
+
+// messageUpdate returns the update of the user sending the text.
+func messageUpdate(text string) updates.Update {
+	var incoming updates.Update
+	incoming.Message = &tgbotapi.Message{
+		From: scenarioUser,
+		Date: int(time.Now().Unix()),
+		Chat: &tgbotapi.Chat{ID: scenarioChatID, Type: "private", UserName: scenarioUser.UserName},
+		Text: text,
+	}
+
+	return incoming
+}
+
+// pressUpdate returns the update of the user pressing the button with the label on the last message sent offering
+// it, false when no message offers it.
+func pressUpdate(fake *fakeSender, label string) (updates.Update, bool) {
+	var incoming updates.Update
+	for i := len(fake.sent) - 1; i >= 0; i-- {
+		for _, button := range replyButtons(fake.sent[i]) {
+			if button.Text != label || button.CallbackData == nil {
+				continue
+			}
+
+			incoming.CallbackQuery = &tgbotapi.CallbackQuery{
+				ID:   fmt.Sprintf("press-%d", i),
+				From: scenarioUser,
+				Message: &tgbotapi.Message{
+					MessageID: i + 1,
+					Chat:      &tgbotapi.Chat{ID: scenarioChatID, Type: "private"},
+				},
+				Data: *button.CallbackData,
+			}
+
+			return incoming, true
+		}
+	}
+
+	return incoming, false
+}
+
+// replyButtons returns the inline buttons of the reply, in order.
+func replyButtons(c tgbotapi.Chattable) []tgbotapi.InlineKeyboardButton {
+	var markup interface{}
+	switch reply := c.(type) {
+	case tgbotapi.MessageConfig:
+		markup = reply.ReplyMarkup
+	case tgbotapi.EditMessageTextConfig:
+		if reply.ReplyMarkup != nil {
+			markup = *reply.ReplyMarkup
+		}
+	}
+
+	keyboard, ok := markup.(tgbotapi.InlineKeyboardMarkup)
+	if !ok {
+		return nil
+	}
+
+	buttons := make([]tgbotapi.InlineKeyboardButton, 0)
+	for _, row := range keyboard.InlineKeyboard {
+		buttons = append(buttons, row...)
+	}
+
+	return buttons
+}
+
+// transcriptOf renders the replies as the user sees them: the edits are marked, followed by the labels of their
+// buttons. The dates of today read YYYY-MM-DD, so that the transcripts do not change from day to day.
+func transcriptOf(replies []tgbotapi.Chattable) string {
+	today := time.Now().UTC().Format("2006-01-02")
+
+	lines := make([]string, 0, len(replies))
+	for _, reply := range replies {
+		prefix := "< "
+		if _, ok := reply.(tgbotapi.EditMessageTextConfig); ok {
+			prefix = "<~ "
+		}
+
+		text := strings.ReplaceAll(replyText(reply), today, "YYYY-MM-DD")
+		for i, line := range strings.Split(text, "\n") {
+			if i != 0 && len(line) != 0 {
+				prefix = "  "
+			} else if i != 0 {
+				prefix = ""
+			}
+
+			lines = append(lines, prefix+line)
+		}
+
+		labels := make([]string, 0)
+		for _, button := range replyButtons(reply) {
+			labels = append(labels, "["+button.Text+"]")
+		}
+		if len(labels) != 0 {
+			lines = append(lines, "  "+strings.Join(labels, " "))
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// playScenario replays the script through a new dispatcher and returns the transcript of the conversation.
+func playScenario(t *testing.T, script []string) string {
+	fake := &fakeSender{}
+	d := newScenarioDispatcher(t, fake)
+
+	lines := make([]string, 0)
+	for _, step := range script {
+		sent := len(fake.sent)
+
+		if strings.HasPrefix(step, "press ") {
+			incoming, ok := pressUpdate(fake, strings.TrimPrefix(step, "press "))
+			if !ok {
+				t.Fatalf("no button %q to press", strings.TrimPrefix(step, "press "))
+			}
+
+			lines = append(lines, "> "+step)
+			d.dispatch(incoming)
+		} else {
+			text := step
+			if question, ok := d.pendingQuestion(scenarioChatID); ok {
+				text = strings.ReplaceAll(text, "{answer}", question.Answer)
+			}
+			text = strings.ReplaceAll(text, "{wrong}", "모르겠어요")
+
+			lines = append(lines, "> "+text)
+			d.dispatch(messageUpdate(text))
+		}
+
+		if replies := transcriptOf(fake.sent[sent:]); len(replies) != 0 {
+			lines = append(lines, replies)
+		}
+	}
+
+	return strings.Join(lines, "\n") + "\n"
+}
+
+func TestScenarios(t *testing.T) {
+	for name, script := range scenarios {
+		script := script
+		t.Run(name, func(t *testing.T) {
+			got := playScenario(t, script)
+
+			golden := filepath.Join("testdata", name+".golden")
+			if *update {
+				err := os.MkdirAll("testdata", 0755)
+				if err == nil {
+					err = os.WriteFile(golden, []byte(got), 0644)
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("%s. Run go test -run TestScenarios -update to create it.", err)
+			}
+
+			if got != string(want) {
+				t.Errorf("the transcript differs from %s, run go test -run TestScenarios -update after checking "+
+					"the change.\ngot:\n%s\nwant:\n%s", golden, got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,126 @@
+package scheduler
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// Job is a task run on every tick of the scheduler. Jobs decide by themselves whether there is anything to do at the
+// given time.
+type Job func(now time.Time)
+
+type namedJob struct {
+	name string
+	run  Job
+}
+
+// JobStatus describes the last run of a job, the zero times when it did not run yet.
+type JobStatus struct {
+	Name     string
+	LastRun  time.Time
+	Duration time.Duration
+}
+
+// Scheduler runs the jobs periodically, one after the other, in the background.
+type Scheduler struct {
+	interval time.Duration
+	jobs     []namedJob
+	stop     chan struct{}
+	done     chan struct{}
+	once     sync.Once
+	mutex    sync.Mutex
+	next     time.Time
+	statuses map[string]JobStatus
+}
+
+// New creates a new instance of Scheduler ticking at the given interval.
+func New(interval time.Duration) *Scheduler {
+	return &Scheduler{interval: interval, stop: make(chan struct{}), done: make(chan struct{}),
+		statuses: make(map[string]JobStatus)}
+}
+
+// Add adds a job. Jobs must be added before the scheduler starts.
+func (scheduler *Scheduler) Add(name string, job Job) {
+	scheduler.jobs = append(scheduler.jobs, namedJob{name: name, run: job})
+}
+
+// Start starts running the jobs in the background, on every tick aligned to the interval, e.g. at the start of every
+// minute. The scheduler stops once the context is done, the job running by then finishes first.
+func (scheduler *Scheduler) Start(ctx context.Context) {
+	go func() {
+		defer close(scheduler.done)
+
+		for {
+			now := time.Now()
+			next := now.Truncate(scheduler.interval).Add(scheduler.interval)
+			scheduler.mutex.Lock()
+			scheduler.next = next
+			scheduler.mutex.Unlock()
+
+			select {
+			case <-scheduler.stop:
+				return
+			case <-ctx.Done():
+				return
+			case now = <-time.After(next.Sub(now)):
+			}
+
+			for _, job := range scheduler.jobs {
+				scheduler.runJob(job, now)
+			}
+		}
+	}()
+}
+
+// runJob runs the job, a panicking job is logged rather than stopping the other jobs.
+func (scheduler *Scheduler) runJob(job namedJob, now time.Time) {
+	started := time.Now()
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Scheduled job %s failed. %v.\n", job.name, r)
+		}
+
+		scheduler.mutex.Lock()
+		scheduler.statuses[job.name] = JobStatus{Name: job.name, LastRun: now, Duration: time.Since(started)}
+		scheduler.mutex.Unlock()
+	}()
+
+	job.run(now)
+}
+
+// Next returns when the jobs run next, the zero time when the scheduler is not started.
+func (scheduler *Scheduler) Next() time.Time {
+	scheduler.mutex.Lock()
+	defer scheduler.mutex.Unlock()
+
+	return scheduler.next
+}
+
+// Statuses returns the status of each job, in the order they were added.
+func (scheduler *Scheduler) Statuses() []JobStatus {
+	scheduler.mutex.Lock()
+	defer scheduler.mutex.Unlock()
+
+	statuses := make([]JobStatus, 0, len(scheduler.jobs))
+	for _, job := range scheduler.jobs {
+		status, ok := scheduler.statuses[job.name]
+		if !ok {
+			status = JobStatus{Name: job.name}
+		}
+
+		statuses = append(statuses, status)
+	}
+
+	return statuses
+}
+
+// Stop stops the scheduler, waiting for the running jobs to finish. It must only be called once the scheduler started.
+func (scheduler *Scheduler) Stop() {
+	scheduler.once.Do(func() {
+		close(scheduler.stop)
+	})
+
+	<-scheduler.done
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"fmt"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+	"github.com/handracs2007/kquiz/quiz"
+	"github.com/handracs2007/kquiz/telegram"
+	"log"
+	"strings"
+	"time"
+)
+
+// restoreSession returns the quiz session of the user saved before a restart, false when there is none.
+func (d *dispatcher) restoreSession(chatID int64) (*quiz.Session, bool) {
+	session := &quiz.Session{}
+	ok, err := d.sessionStore.Session(chatID, session)
+	if err != nil || !ok {
+		return nil, false
+	}
+
+	d.sessions[chatID] = session
+	return session, true
+}
+
+// saveSession saves the quiz session of the user so that it survives a restart.
+func (d *dispatcher) saveSession(chatID int64, session *quiz.Session) {
+	err := d.sessionStore.SaveSession(chatID, session)
+	if err != nil {
+		log.Printf("Failed to save the quiz session. %s.\n", err)
+	}
+}
+
+// startQuiz starts a quiz session of the given number of questions on the words tagged with the tag, if any.
+func (d *dispatcher) startQuiz(botAPI sender, chatID int64, questions int, tag string, group bool, superGroup bool) {
+	// The quiz starts a fresh session, so that its score covers the quiz only.
+	session := &quiz.Session{Questions: questions, Tag: tag, LastAnswer: time.Now()}
+	d.sessions[chatID] = session
+	d.warmUp(chatID, session)
+
+	respond(d.bot, "quiz", tgbotapi.NewMessage(chatID, fmt.Sprintf("Quiz of %d questions. /hint gives a hint, "+
+		"/skip passes a question and /stop ends the quiz.", questions)))
+
+	// In groups, the members compete with live standings in a pinned message.
+	if group {
+		startGroupQuiz(d.bot, chatID, d.botID, superGroup, session)
+	}
+
+	d.continueQuiz(botAPI, chatID, session)
+}
+
+// continueQuiz asks the next question of the quiz session through the given sender, or ends it with its results once
+// all the questions were asked.
+func (d *dispatcher) continueQuiz(botAPI sender, chatID int64, session *quiz.Session) {
+	if !session.QuizRunning() {
+		d.endQuiz(chatID, "Quiz done!", session)
+		return
+	}
+
+	if prompt, ok := d.askWarmUp(chatID, session); ok {
+		respond(botAPI, "warm-up", prompt)
+		d.saveSession(chatID, session)
+		return
+	}
+
+	question := d.askRandom(botAPI, chatID, telegram.KindVocabulary, session.Tag)
+	if question == nil {
+		session.Questions = 0
+		updateGroupQuiz(d.bot, chatID, session, "No words to quiz.")
+		d.deleteSession(chatID)
+
+		return
+	}
+
+	d.setPending(chatID, *question)
+	d.saveSession(chatID, session)
+}
+
+// skipQuestion passes the question of the quiz session the user is to answer, revealing its answer, and asks the next
+// one.
+func (d *dispatcher) skipQuestion(botAPI sender, chatID int64) {
+	session, ok := d.sessions[chatID]
+	question, pending := d.pendingQuestion(chatID)
+	if !ok || !session.QuizRunning() || !pending {
+		respond(d.bot, "skip", tgbotapi.NewMessage(chatID, "There is no question to skip, start a quiz with "+
+			"/quiz <count>."))
+		return
+	}
+
+	d.clearPending(chatID)
+	delete(d.reveals, chatID)
+	session.Skip(question, time.Now())
+
+	respond(botAPI, "skip", tgbotapi.NewMessage(chatID, fmt.Sprintf("Skipped, the answer is %s.", question.Answer)))
+
+	d.continueQuiz(botAPI, chatID, session)
+}
+
+// stopQuiz ends the quiz session of the user early with the results so far, it returns false when the user is not in
+// one.
+func (d *dispatcher) stopQuiz(chatID int64) bool {
+	session, ok := d.sessions[chatID]
+	if !ok || !session.QuizRunning() {
+		return false
+	}
+
+	d.clearPending(chatID)
+	d.endQuiz(chatID, fmt.Sprintf("Quiz stopped after %d of %d questions.", len(session.Results),
+		session.Questions), session)
+
+	return true
+}
+
+// endQuiz ends the quiz session with the heading, e.g. Quiz done!, the result of each question and the recap.
+func (d *dispatcher) endQuiz(chatID int64, heading string, session *quiz.Session) {
+	session.Questions = 0
+	d.deleteSession(chatID)
+
+	settings, err := d.settingsStore.Settings(chatID)
+	if err != nil {
+		log.Printf("Failed to read settings, using the defaults. %s.\n", err)
+	}
+
+	if session.StatusMessageID != 0 {
+		postScores(settings, chatID, session, time.Now())
+	}
+
+	updateGroupQuiz(d.bot, chatID, session, heading)
+	d.emitCompleted(chatID, "quiz", session)
+
+	if len(session.Results) != 0 {
+		heading += "\n\n" + strings.Join(session.QuizResults(settings.Accessible), "\n") + "\n"
+	}
+	d.sendRecap(chatID, heading, session)
+}
+
+// deleteSession deletes the saved quiz session of the user once it is over.
+func (d *dispatcher) deleteSession(chatID int64) {
+	err := d.sessionStore.DeleteSession(chatID)
+	if err != nil {
+		log.Printf("Failed to delete the quiz session. %s.\n", err)
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+	"github.com/handracs2007/kquiz/providers"
+	"log"
+	"strings"
+)
+
+// The actions of the buttons offering the dictionary form of a conjugated word, following lemmaCallbackPrefix.
+const (
+	lemmaDictionary = "d"
+	lemmaAsIs       = "a"
+)
+
+// lemmaPrompt is the message offering to add the dictionary form of the conjugated word the user adds instead. The
+// translation is empty when the word is to be translated.
+type lemmaPrompt struct {
+	messageID   int
+	word        string
+	lemma       string
+	translation string
+	tags        []string
+}
+
+// dictionaryForm returns the dictionary form of the word when it is a conjugated verb or adjective, e.g. 먹다 for
+// 먹어요, false when it is anything else or already in its dictionary form. It takes a morphological analyzer, the
+// naive one cannot tell.
+func dictionaryForm(analyzer providers.Analyzer, word string) (string, bool) {
+	tokens, err := analyzer.Analyze(word)
+	if err != nil {
+		return "", false
+	}
+
+	lemma := ""
+	for _, token := range tokens {
+		if !token.Content() {
+			continue
+		}
+
+		if len(lemma) != 0 || !(strings.HasPrefix(token.Tag, "VV") || strings.HasPrefix(token.Tag, "VA")) {
+			return "", false
+		}
+
+		lemma = token.Lemma
+	}
+
+	return lemma, len(lemma) != 0 && lemma != word
+}
+
+// offerDictionaryForm asks the user whether to add the dictionary form of the conjugated word rather than the word, so
+// that the deck does not fill up with the conjugations of the same verb. When the user already has the dictionary
+// form, it asks whether to add the word anyway.
+func (d *dispatcher) offerDictionaryForm(chatID int64, prompt lemmaPrompt) {
+	var msg tgbotapi.MessageConfig
+	if d.users.IsAdded(chatID, prompt.lemma) {
+		msg = tgbotapi.NewMessage(chatID, fmt.Sprintf("%s is a form of %s, which you already have.", prompt.word,
+			prompt.lemma))
+		msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("Add %s anyway", prompt.word),
+				lemmaCallbackPrefix+lemmaAsIs)))
+	} else {
+		msg = tgbotapi.NewMessage(chatID, fmt.Sprintf("%s is a form of %s. Add the dictionary form to keep the "+
+			"conjugations out of your deck?", prompt.word, prompt.lemma))
+		msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("Add %s", prompt.lemma),
+				lemmaCallbackPrefix+lemmaDictionary),
+			tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("Add %s", prompt.word), lemmaCallbackPrefix+lemmaAsIs)))
+	}
+
+	message, err := d.bot.Send(msg)
+	if err != nil {
+		log.Printf("Failed to respond to add word request. %s.\n", err)
+		return
+	}
+
+	prompt.messageID = message.MessageID
+	d.lemmaPrompts[chatID] = prompt
+}
+
+// chooseForm handles the buttons offering the dictionary form, adding the form chosen. The buttons of the earlier
+// prompts are stale and ignored.
+func (d *dispatcher) chooseForm(query *tgbotapi.CallbackQuery) {
+	chatID := query.Message.Chat.ID
+	prompt, ok := d.lemmaPrompts[chatID]
+	if !ok || prompt.messageID != query.Message.MessageID {
+		return
+	}
+
+	word := prompt.word
+	switch strings.TrimPrefix(query.Data, lemmaCallbackPrefix) {
+	case lemmaDictionary:
+		word = prompt.lemma
+	case lemmaAsIs:
+	default:
+		return
+	}
+
+	delete(d.lemmaPrompts, chatID)
+
+	// The buttons are removed so that the word is added once.
+	respond(d.bot, "dictionary form", tgbotapi.NewEditMessageText(chatID, prompt.messageID, query.Message.Text))
+
+	if len(prompt.translation) == 0 {
+		ack := acknowledge(d.bot, chatID, tgbotapi.ChatTyping, "")
+		respond(ack, "add word", addTranslatedWord(d.adder, d.registry.Translator, chatID, word, d.translationLanguage,
+			prompt.tags))
+		return
+	}
+
+	respond(d.bot, "add word", addWord(d.adder, chatID, word, prompt.translation, prompt.tags))
+}
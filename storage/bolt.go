@@ -0,0 +1,635 @@
+package storage
+
+import (
+	"bytes"
+	"fmt"
+	"go.etcd.io/bbolt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/handracs2007/kquiz/telegram"
+)
+
+// BoltRepository stores the users and their words in bbolt, it implements UserRepository and WordRepository.
+type BoltRepository struct {
+	telegramBucket []byte
+	kquizBucket    []byte
+	relationBucket []byte
+	rootFinder     RootFinder
+	shards         telegram.Shards
+	journal        *telegram.Journal
+}
+
+// NewBoltRepository creates a new instance of BoltRepository
+func NewBoltRepository(shards telegram.Shards, telegramBucket string, kquizBucket string, relationBucket string,
+	rootFinder RootFinder, journal *telegram.Journal) BoltRepository {
+	return BoltRepository{
+		shards:         shards,
+		journal:        journal,
+		telegramBucket: []byte(telegramBucket),
+		kquizBucket:    []byte(kquizBucket),
+		relationBucket: []byte(relationBucket),
+		rootFinder:     rootFinder,
+	}
+}
+
+// Users returns the chat IDs of all registered users.
+// This function returns the following errors:
+//  - ErrDatabaseError
+func (repo BoltRepository) Users() ([]int64, error) {
+	users := make([]int64, 0)
+
+	err := repo.shards.View(func(tx *bbolt.Tx) error {
+		shardUsers, err := UsersIn(tx, string(repo.telegramBucket))
+		users = append(users, shardUsers...)
+		return err
+	})
+	if err != nil {
+		log.Printf("Failed to list users. %s.\n", err)
+		return nil, telegram.ErrDatabaseError
+	}
+
+	return users, nil
+}
+
+// UsersIn returns the chat IDs of the users registered in the shard of the transaction.
+func UsersIn(tx *bbolt.Tx, bucketName string) ([]int64, error) {
+	users := make([]int64, 0)
+
+	bucket := tx.Bucket([]byte(bucketName))
+	if bucket == nil {
+		return users, nil
+	}
+
+	err := bucket.ForEach(func(key, _ []byte) error {
+		chatID, err := strconv.ParseInt(string(key), 10, 64)
+		if err != nil {
+			return err
+		}
+
+		users = append(users, chatID)
+		return nil
+	})
+
+	return users, err
+}
+
+func (repo BoltRepository) IsRegistered(chatID int64) bool {
+	exists := false
+
+	err := repo.shards.For(chatID).View(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket(repo.telegramBucket)
+		data := bucket.Get([]byte(fmt.Sprintf("%d", chatID)))
+		exists = data != nil
+
+		return nil
+	})
+	if err != nil {
+		log.Printf("Failed to read data from telegram bucket. %s.\n", err)
+	}
+
+	return exists
+}
+
+func (repo BoltRepository) IsAdded(chatID int64, word string) bool {
+	exists := false
+
+	err := repo.shards.For(chatID).View(func(tx *bbolt.Tx) error {
+		bucket := telegram.UserBucket(tx.Bucket(repo.kquizBucket), chatID)
+		exists = bucket != nil && bucket.Get([]byte(word)) != nil
+
+		return nil
+	})
+	if err != nil {
+		log.Printf("Failed to read data from telegram bucket. %s.\n", err)
+	}
+
+	return exists
+}
+
+// Register registers a new user. This function can return the following errors:
+//  - ErrAlreadyRegistered
+//  - ErrDatabaseError
+func (repo BoltRepository) Register(chatID int64) error {
+	if repo.IsRegistered(chatID) {
+		return telegram.ErrAlreadyRegistered
+	}
+
+	err := repo.shards.For(chatID).Update(func(tx *bbolt.Tx) error {
+		key := []byte(fmt.Sprintf("%d", chatID))
+		value := key
+
+		bucket := tx.Bucket(repo.telegramBucket)
+		return bucket.Put(key, value)
+	})
+	if err != nil {
+		log.Printf("Failed to update registration data. %s.\n", err)
+		return telegram.ErrDatabaseError
+	}
+
+	key := []byte(fmt.Sprintf("%d", chatID))
+	repo.journal.Append(telegram.PutRecord(chatID, repo.telegramBucket, key, key))
+
+	return nil
+}
+
+// Unregister unregisters an existing user. This function can return the following errors:
+//  - ErrNotRegistered
+//  - ErrDatabaseError
+func (repo BoltRepository) Unregister(chatID int64) error {
+	if !repo.IsRegistered(chatID) {
+		return telegram.ErrNotRegistered
+	}
+
+	err := repo.shards.For(chatID).Update(func(tx *bbolt.Tx) error {
+		key := []byte(fmt.Sprintf("%d", chatID))
+
+		bucket := tx.Bucket(repo.telegramBucket)
+		return bucket.Delete(key)
+	})
+	if err != nil {
+		log.Printf("Failed to update registration data. %s.\n", err)
+		return telegram.ErrDatabaseError
+	}
+
+	repo.journal.Append(telegram.DeleteRecord(chatID, repo.telegramBucket, []byte(fmt.Sprintf("%d", chatID))))
+
+	return nil
+}
+
+// Add adds a word and its translation to the database. This data is unique for each user identified by the chat ID.
+// This function returns the following errors:
+//  - ErrNotRegistered
+//  - ErrDatabaseError
+//  - ErrDuplicateWord
+func (repo BoltRepository) Add(chatID int64, word string, translation string) error {
+	return repo.AddEntry(chatID, word, telegram.WordEntry{Translation: translation})
+}
+
+// AddEntry adds a word together with its metadata to the database. This data is unique for each user identified by the
+// chat ID.
+// This function returns the following errors:
+//  - ErrNotRegistered
+//  - ErrDatabaseError
+//  - ErrDuplicateWord
+func (repo BoltRepository) AddEntry(chatID int64, word string, entry telegram.WordEntry) error {
+	if !repo.IsRegistered(chatID) {
+		return telegram.ErrNotRegistered
+	}
+
+	if repo.IsAdded(chatID, word) {
+		return telegram.ErrDuplicateWord
+	}
+
+	if entry.CreatedAt == nil {
+		now := time.Now()
+		entry.CreatedAt = &now
+	}
+
+	value, err := telegram.EncodeEntry(entry)
+	if err != nil {
+		log.Printf("Failed to encode word. %s.", err)
+		return telegram.ErrDatabaseError
+	}
+
+	err = repo.shards.For(chatID).Update(func(tx *bbolt.Tx) error {
+		bucket, err := telegram.CreateUserBucket(tx.Bucket(repo.kquizBucket), chatID)
+		if err != nil {
+			return err
+		}
+
+		err = bucket.Put([]byte(word), value)
+		if err != nil {
+			return err
+		}
+
+		// Grammar patterns are not built on hanja or stems, there is nothing to relate.
+		if entry.EntryKind() == telegram.KindGrammar {
+			return nil
+		}
+
+		return repo.indexRelations(tx, chatID, word)
+	})
+	if err != nil {
+		log.Printf("Failed to add word. %s.", err)
+		return telegram.ErrDatabaseError
+	}
+
+	repo.journal.Append(telegram.PutUserRecord(chatID, repo.kquizBucket, []byte(word), value))
+
+	return nil
+}
+
+// SetExample sets the example sentence of a word already added to the database.
+// This function returns the following errors:
+//  - ErrNotRegistered
+//  - ErrDatabaseError
+//  - ErrWordNotFound
+func (repo BoltRepository) SetExample(chatID int64, word string, example string) error {
+	return repo.changeEntry(chatID, word, func(entry *telegram.WordEntry) {
+		entry.Example = example
+	})
+}
+
+// SetNotes sets the notes of a word already added to the database, e.g. its usage or a mnemonic.
+// This function returns the following errors:
+//  - ErrNotRegistered
+//  - ErrDatabaseError
+//  - ErrWordNotFound
+func (repo BoltRepository) SetNotes(chatID int64, word string, notes string) error {
+	return repo.changeEntry(chatID, word, func(entry *telegram.WordEntry) {
+		entry.Notes = notes
+	})
+}
+
+// AddTag tags a word already added to the database, keeping its other tags.
+// This function returns the following errors:
+//  - ErrNotRegistered
+//  - ErrDatabaseError
+//  - ErrWordNotFound
+func (repo BoltRepository) AddTag(chatID int64, word string, tag string) error {
+	return repo.changeEntry(chatID, word, func(entry *telegram.WordEntry) {
+		if !entry.HasTag(tag) {
+			entry.Tags = append(entry.Tags, tag)
+		}
+	})
+}
+
+// SetTranslation replaces the translation of a word already added to the database, e.g. with the translation of a
+// shared deck.
+// This function returns the following errors:
+//  - ErrNotRegistered
+//  - ErrDatabaseError
+//  - ErrWordNotFound
+func (repo BoltRepository) SetTranslation(chatID int64, word string, translation string) error {
+	return repo.changeEntry(chatID, word, func(entry *telegram.WordEntry) {
+		entry.Translation = translation
+	})
+}
+
+// changeEntry changes the entry of a word already added to the database in place.
+func (repo BoltRepository) changeEntry(chatID int64, word string, change func(entry *telegram.WordEntry)) error {
+	if !repo.IsRegistered(chatID) {
+		return telegram.ErrNotRegistered
+	}
+
+	var record telegram.JournalRecord
+
+	err := repo.shards.For(chatID).Update(func(tx *bbolt.Tx) error {
+		bucket := telegram.UserBucket(tx.Bucket(repo.kquizBucket), chatID)
+		if bucket == nil || bucket.Get([]byte(word)) == nil {
+			return telegram.ErrWordNotFound
+		}
+
+		value := bucket.Get([]byte(word))
+
+		entry := telegram.DecodeEntry(value)
+		change(&entry)
+
+		value, err := telegram.EncodeEntry(entry)
+		if err != nil {
+			return err
+		}
+
+		record = telegram.PutUserRecord(chatID, repo.kquizBucket, []byte(word), value)
+		return bucket.Put([]byte(word), value)
+	})
+	if err != nil {
+		log.Printf("Failed to change word. %s.", err)
+
+		if err != telegram.ErrWordNotFound {
+			return telegram.ErrDatabaseError
+		} else {
+			return telegram.ErrWordNotFound
+		}
+	}
+
+	repo.journal.Append(record)
+	return nil
+}
+
+// EncodeLegacyEntriesIn rewrites the words of the bucket of the shard of the transaction stored as the plain
+// translation, before entries were introduced, as entries and returns how many were rewritten. They are read either
+// way, the rewrite spares decoding them differently.
+func EncodeLegacyEntriesIn(tx *bbolt.Tx, bucketName string) (int, error) {
+	parent := tx.Bucket([]byte(bucketName))
+	if parent == nil {
+		return 0, nil
+	}
+
+	rewritten := 0
+	err := parent.ForEach(func(name, value []byte) error {
+		bucket := parent.Bucket(name)
+		if value != nil || bucket == nil {
+			return nil
+		}
+
+		// Changing the values while iterating is not allowed.
+		legacy := make(map[string][]byte)
+		err := bucket.ForEach(func(word, value []byte) error {
+			if !bytes.HasPrefix(value, []byte("{")) {
+				legacy[string(word)] = append([]byte(nil), value...)
+			}
+
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		for word, value := range legacy {
+			encoded, err := telegram.EncodeEntry(telegram.DecodeEntry(value))
+			if err != nil {
+				return err
+			}
+
+			err = bucket.Put([]byte(word), encoded)
+			if err != nil {
+				return err
+			}
+
+			rewritten++
+		}
+
+		return nil
+	})
+
+	return rewritten, err
+}
+
+// Review records the review of the word, the review function updates its schedule given the stored entry.
+// This function returns the following errors:
+//  - ErrDatabaseError
+//  - ErrWordNotFound
+func (repo BoltRepository) Review(chatID int64, word string,
+	review func(entry telegram.WordEntry) telegram.WordEntry) error {
+	var record telegram.JournalRecord
+
+	err := repo.shards.For(chatID).Update(func(tx *bbolt.Tx) error {
+		bucket := telegram.UserBucket(tx.Bucket(repo.kquizBucket), chatID)
+		if bucket == nil || bucket.Get([]byte(word)) == nil {
+			return telegram.ErrWordNotFound
+		}
+
+		value := bucket.Get([]byte(word))
+
+		value, err := telegram.EncodeEntry(review(telegram.DecodeEntry(value)))
+		if err != nil {
+			return err
+		}
+
+		record = telegram.PutUserRecord(chatID, repo.kquizBucket, []byte(word), value)
+		return bucket.Put([]byte(word), value)
+	})
+	if err != nil {
+		if err == telegram.ErrWordNotFound {
+			return telegram.ErrWordNotFound
+		}
+
+		log.Printf("Failed to mark word reviewed. %s.", err)
+		return telegram.ErrDatabaseError
+	}
+
+	repo.journal.Append(record)
+	return nil
+}
+
+// Search searches a word from the database.
+// This function returns the following errors:
+//  - ErrNotRegistered
+//  - ErrDatabaseError
+//  - ErrWordNotFound
+func (repo BoltRepository) Search(chatID int64, word string) (*string, error) {
+	entry, err := repo.SearchEntry(chatID, word)
+	if err != nil {
+		return nil, err
+	}
+
+	return &entry.Translation, nil
+}
+
+// SearchEntry searches a word and returns its entry with the metadata, e.g. its tags and source.
+// This function returns the following errors:
+//  - ErrNotRegistered
+//  - ErrDatabaseError
+//  - ErrWordNotFound
+func (repo BoltRepository) SearchEntry(chatID int64, word string) (*telegram.WordEntry, error) {
+	if !repo.IsRegistered(chatID) {
+		return nil, telegram.ErrNotRegistered
+	}
+
+	var entry telegram.WordEntry
+
+	err := repo.shards.For(chatID).View(func(tx *bbolt.Tx) error {
+		bucket := telegram.UserBucket(tx.Bucket(repo.kquizBucket), chatID)
+		if bucket == nil || bucket.Get([]byte(word)) == nil {
+			return telegram.ErrWordNotFound
+		}
+
+		entry = telegram.DecodeEntry(bucket.Get([]byte(word)))
+		return nil
+	})
+	if err != nil {
+		log.Printf("Failed to get word. %s.", err)
+
+		if err != telegram.ErrWordNotFound {
+			return nil, telegram.ErrDatabaseError
+		} else {
+			return nil, telegram.ErrWordNotFound
+		}
+	}
+
+	return &entry, nil
+}
+
+// Delete deletes a word from the database.
+// This function returns the following errors:
+//  - ErrNotRegistered
+//  - ErrDatabaseError
+//  - ErrWordNotFound
+func (repo BoltRepository) Delete(chatID int64, word string) error {
+	if !repo.IsRegistered(chatID) {
+		return telegram.ErrNotRegistered
+	}
+
+	if !repo.IsAdded(chatID, word) {
+		return telegram.ErrWordNotFound
+	}
+
+	err := repo.shards.For(chatID).Update(func(tx *bbolt.Tx) error {
+		bucket := telegram.UserBucket(tx.Bucket(repo.kquizBucket), chatID)
+		if bucket == nil {
+			return telegram.ErrWordNotFound
+		}
+
+		err := bucket.Delete([]byte(word))
+		if err != nil {
+			return err
+		}
+
+		return repo.unindexRelations(tx, chatID, word)
+	})
+	if err != nil {
+		log.Printf("Failed to get word. %s.", err)
+		return telegram.ErrDatabaseError
+	}
+
+	repo.journal.Append(telegram.DeleteUserRecord(chatID, repo.kquizBucket, []byte(word)))
+
+	return nil
+}
+
+// Clear clears all words from the database owned by the user identified with chat ID.
+// This function returns the following errors:
+//  - ErrNotRegistered
+//  - ErrDatabaseError
+func (repo BoltRepository) Clear(chatID int64) error {
+	if !repo.IsRegistered(chatID) {
+		return telegram.ErrNotRegistered
+	}
+
+	var records []telegram.JournalRecord
+
+	err := repo.shards.For(chatID).Update(func(tx *bbolt.Tx) error {
+		bucket := telegram.UserBucket(tx.Bucket(repo.kquizBucket), chatID)
+		if bucket == nil {
+			return nil
+		}
+
+		var words []string
+		err := bucket.ForEach(func(key, _ []byte) error {
+			words = append(words, string(key))
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, word := range words {
+			records = append(records, telegram.DeleteUserRecord(chatID, repo.kquizBucket, []byte(word)))
+		}
+
+		// The words and their relations go with the buckets of the user.
+		err = tx.Bucket(repo.kquizBucket).DeleteBucket(telegram.UserPrefix(chatID))
+		if err != nil {
+			return err
+		}
+
+		return repo.clearRelations(tx, chatID)
+	})
+	if err != nil {
+		log.Printf("Failed to clear words. %s.", err)
+		return telegram.ErrDatabaseError
+	}
+
+	repo.journal.Append(records...)
+
+	return nil
+}
+
+// List lists the vocabulary from the database owned by the user as identified by the chat ID. Each element contains
+// 2 elements; first element is the Korean word and the second element is the translation.
+// This function returns the following errors:
+//  - ErrNotRegistered
+//  - ErrDatabaseError
+//  - ErrWordNotFound
+func (repo BoltRepository) List(chatID int64) ([][]string, error) {
+	entries, err := repo.ListEntries(chatID, telegram.KindVocabulary)
+	if err != nil {
+		return nil, err
+	}
+
+	wordMap := make([][]string, 0, len(entries))
+	for _, entry := range entries {
+		wordMap = append(wordMap, []string{entry.Word, entry.Translation})
+	}
+
+	return wordMap, nil
+}
+
+// ListEntries lists the entries of the given kind from the database owned by the user as identified by the chat ID.
+// This function returns the following errors:
+//  - ErrNotRegistered
+//  - ErrDatabaseError
+//  - ErrWordNotFound
+func (repo BoltRepository) ListEntries(chatID int64, kind string) ([]telegram.Entry, error) {
+	entries := make([]telegram.Entry, 0)
+
+	if !repo.IsRegistered(chatID) {
+		return nil, telegram.ErrNotRegistered
+	}
+
+	err := repo.shards.For(chatID).View(func(tx *bbolt.Tx) error {
+		bucket := telegram.UserBucket(tx.Bucket(repo.kquizBucket), chatID)
+		if bucket == nil {
+			return nil
+		}
+
+		return bucket.ForEach(func(key, value []byte) error {
+			entry := telegram.DecodeEntry(value)
+			if entry.EntryKind() == kind {
+				entries = append(entries, telegram.Entry{Word: string(key), WordEntry: entry})
+			}
+
+			return nil
+		})
+	})
+	if err != nil {
+		log.Printf("Failed to list words. %s.", err)
+		return nil, telegram.ErrDatabaseError
+	}
+
+	if len(entries) == 0 {
+		return nil, telegram.ErrWordNotFound
+	}
+
+	return entries, nil
+}
+
+// ListPage lists at most limit entries of the kind matching the filter, a tag or a source, if any, from the offset, in
+// the order of the words. It also returns how many entries there are in total, to page through them.
+// This function returns the following errors:
+//  - ErrNotRegistered
+//  - ErrDatabaseError
+//  - ErrWordNotFound
+func (repo BoltRepository) ListPage(chatID int64, kind string, filter string, offset int,
+	limit int) ([]telegram.Entry, int, error) {
+	entries := make([]telegram.Entry, 0, limit)
+	total := 0
+
+	if !repo.IsRegistered(chatID) {
+		return nil, 0, telegram.ErrNotRegistered
+	}
+
+	err := repo.shards.For(chatID).View(func(tx *bbolt.Tx) error {
+		bucket := telegram.UserBucket(tx.Bucket(repo.kquizBucket), chatID)
+		if bucket == nil {
+			return nil
+		}
+
+		return bucket.ForEach(func(key, value []byte) error {
+			entry := telegram.DecodeEntry(value)
+			if entry.EntryKind() != kind || !entry.Matches(filter) {
+				return nil
+			}
+
+			if total >= offset && len(entries) < limit {
+				entries = append(entries, telegram.Entry{Word: string(key), WordEntry: entry})
+			}
+			total++
+
+			return nil
+		})
+	})
+	if err != nil {
+		log.Printf("Failed to list words. %s.", err)
+		return nil, 0, telegram.ErrDatabaseError
+	}
+
+	if total == 0 {
+		return nil, 0, telegram.ErrWordNotFound
+	}
+
+	return entries, total, nil
+}
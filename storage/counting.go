@@ -0,0 +1,50 @@
+package storage
+
+import (
+	"log"
+
+	"github.com/handracs2007/kquiz/telegram"
+)
+
+// CountingAdder counts the words added through the underlying adder in the stats of the users.
+type CountingAdder struct {
+	adder Adder
+	stats telegram.StatsTracker
+}
+
+// NewCountingAdder creates a new instance of CountingAdder
+func NewCountingAdder(adder Adder, stats telegram.StatsTracker) CountingAdder {
+	return CountingAdder{adder: adder, stats: stats}
+}
+
+// Add adds the word and its translation and counts it.
+// This function returns the following errors:
+//  - the errors of the underlying adder
+func (adder CountingAdder) Add(chatID int64, word string, translation string) error {
+	err := adder.adder.Add(chatID, word, translation)
+	if err == nil {
+		adder.count(chatID)
+	}
+
+	return err
+}
+
+// AddEntry adds the word with its entry and counts it.
+// This function returns the following errors:
+//  - the errors of the underlying adder
+func (adder CountingAdder) AddEntry(chatID int64, word string, entry telegram.WordEntry) error {
+	err := adder.adder.AddEntry(chatID, word, entry)
+	if err == nil {
+		adder.count(chatID)
+	}
+
+	return err
+}
+
+// count counts the added word, a failure only loses the count.
+func (adder CountingAdder) count(chatID int64) {
+	err := adder.stats.CountAdded(chatID, 1)
+	if err != nil {
+		log.Printf("Failed to count added word. %s.\n", err)
+	}
+}
@@ -0,0 +1,54 @@
+package storage
+
+import (
+	"github.com/handracs2007/kquiz/telegram"
+)
+
+// DeckCounter counts the words in the decks listed by the underlying deck manager through the lister, so that the
+// counts are right whichever backend stores the words.
+type DeckCounter struct {
+	decks  telegram.DeckManager
+	lister Lister
+}
+
+// NewDeckCounter creates a new instance of DeckCounter
+func NewDeckCounter(decks telegram.DeckManager, lister Lister) DeckCounter {
+	return DeckCounter{decks: decks, lister: lister}
+}
+
+// CreateDeck saves a new deck for the user identified by the chat ID through the underlying deck manager.
+// This function returns the following errors:
+//  - the errors of the underlying deck manager
+func (counter DeckCounter) CreateDeck(chatID int64, deck telegram.Deck) (string, error) {
+	return counter.decks.CreateDeck(chatID, deck)
+}
+
+// Decks lists the decks owned by the user identified by the chat ID together with the number of words, and grammar
+// patterns, in each deck.
+// This function returns the following errors:
+//  - the errors of the underlying deck manager
+//  - the errors of the lister
+func (counter DeckCounter) Decks(chatID int64) ([]telegram.Deck, error) {
+	decks, err := counter.decks.Decks(chatID)
+	if err != nil || len(decks) == 0 {
+		return decks, err
+	}
+
+	counts := make(map[string]int)
+	for _, kind := range []string{telegram.KindVocabulary, telegram.KindGrammar} {
+		entries, err := counter.lister.ListEntries(chatID, kind)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, entry := range entries {
+			counts[entry.Deck]++
+		}
+	}
+
+	for i := range decks {
+		decks[i].WordCount = counts[decks[i].Name]
+	}
+
+	return decks, nil
+}
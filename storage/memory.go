@@ -0,0 +1,394 @@
+package storage
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/handracs2007/kquiz/telegram"
+)
+
+// MemoryRepository keeps the users and their words in memory, it implements UserRepository and WordRepository. Nothing
+// survives a restart, it suits the tests and the throwaway bots, e.g. a demo.
+type MemoryRepository struct {
+	mutex      *sync.RWMutex
+	users      map[int64]bool
+	words      map[int64]map[string]telegram.WordEntry
+	rootFinder RootFinder
+}
+
+// NewMemoryRepository creates a new instance of MemoryRepository
+func NewMemoryRepository(rootFinder RootFinder) MemoryRepository {
+	return MemoryRepository{
+		mutex:      &sync.RWMutex{},
+		users:      make(map[int64]bool),
+		words:      make(map[int64]map[string]telegram.WordEntry),
+		rootFinder: rootFinder,
+	}
+}
+
+// copyEntry returns a copy of the entry not sharing its tags, as the entries decoded from the database do not.
+func copyEntry(entry telegram.WordEntry) telegram.WordEntry {
+	if entry.Tags != nil {
+		entry.Tags = append([]string(nil), entry.Tags...)
+	}
+
+	return entry
+}
+
+// Users returns the chat IDs of all registered users.
+func (repo MemoryRepository) Users() ([]int64, error) {
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
+
+	users := make([]int64, 0, len(repo.users))
+	for chatID := range repo.users {
+		users = append(users, chatID)
+	}
+
+	sort.Slice(users, func(i, j int) bool {
+		return users[i] < users[j]
+	})
+
+	return users, nil
+}
+
+// IsRegistered reports whether the user identified by the chat ID is registered.
+func (repo MemoryRepository) IsRegistered(chatID int64) bool {
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
+
+	return repo.users[chatID]
+}
+
+// IsAdded reports whether the user identified by the chat ID added the word.
+func (repo MemoryRepository) IsAdded(chatID int64, word string) bool {
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
+
+	_, ok := repo.words[chatID][word]
+	return ok
+}
+
+// Register registers a new user. This function can return the following errors:
+//  - ErrAlreadyRegistered
+func (repo MemoryRepository) Register(chatID int64) error {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	if repo.users[chatID] {
+		return telegram.ErrAlreadyRegistered
+	}
+
+	repo.users[chatID] = true
+	return nil
+}
+
+// Unregister unregisters an existing user, the words are kept for when the user registers again. This function can
+// return the following errors:
+//  - ErrNotRegistered
+func (repo MemoryRepository) Unregister(chatID int64) error {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	if !repo.users[chatID] {
+		return telegram.ErrNotRegistered
+	}
+
+	delete(repo.users, chatID)
+	return nil
+}
+
+// Add adds a word and its translation.
+// This function returns the following errors:
+//  - ErrNotRegistered
+//  - ErrDuplicateWord
+func (repo MemoryRepository) Add(chatID int64, word string, translation string) error {
+	return repo.AddEntry(chatID, word, telegram.WordEntry{Translation: translation})
+}
+
+// AddEntry adds a word together with its metadata.
+// This function returns the following errors:
+//  - ErrNotRegistered
+//  - ErrDuplicateWord
+func (repo MemoryRepository) AddEntry(chatID int64, word string, entry telegram.WordEntry) error {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	if !repo.users[chatID] {
+		return telegram.ErrNotRegistered
+	}
+
+	words := repo.words[chatID]
+	if words == nil {
+		words = make(map[string]telegram.WordEntry)
+		repo.words[chatID] = words
+	}
+
+	if _, ok := words[word]; ok {
+		return telegram.ErrDuplicateWord
+	}
+
+	if entry.CreatedAt == nil {
+		now := time.Now()
+		entry.CreatedAt = &now
+	}
+
+	words[word] = copyEntry(entry)
+	return nil
+}
+
+// SetExample sets the example sentence of a word already added.
+// This function returns the following errors:
+//  - ErrNotRegistered
+//  - ErrWordNotFound
+func (repo MemoryRepository) SetExample(chatID int64, word string, example string) error {
+	return repo.changeEntry(chatID, word, func(entry *telegram.WordEntry) {
+		entry.Example = example
+	})
+}
+
+// SetNotes sets the notes of a word already added.
+// This function returns the following errors:
+//  - ErrNotRegistered
+//  - ErrWordNotFound
+func (repo MemoryRepository) SetNotes(chatID int64, word string, notes string) error {
+	return repo.changeEntry(chatID, word, func(entry *telegram.WordEntry) {
+		entry.Notes = notes
+	})
+}
+
+// AddTag tags a word already added, keeping its other tags.
+// This function returns the following errors:
+//  - ErrNotRegistered
+//  - ErrWordNotFound
+func (repo MemoryRepository) AddTag(chatID int64, word string, tag string) error {
+	return repo.changeEntry(chatID, word, func(entry *telegram.WordEntry) {
+		if !entry.HasTag(tag) {
+			entry.Tags = append(entry.Tags, tag)
+		}
+	})
+}
+
+// SetTranslation replaces the translation of a word already added.
+// This function returns the following errors:
+//  - ErrNotRegistered
+//  - ErrWordNotFound
+func (repo MemoryRepository) SetTranslation(chatID int64, word string, translation string) error {
+	return repo.changeEntry(chatID, word, func(entry *telegram.WordEntry) {
+		entry.Translation = translation
+	})
+}
+
+// changeEntry changes the entry of a word already added in place.
+func (repo MemoryRepository) changeEntry(chatID int64, word string, change func(entry *telegram.WordEntry)) error {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	if !repo.users[chatID] {
+		return telegram.ErrNotRegistered
+	}
+
+	words := repo.words[chatID]
+
+	entry, ok := words[word]
+	if !ok {
+		return telegram.ErrWordNotFound
+	}
+
+	change(&entry)
+	words[word] = entry
+
+	return nil
+}
+
+// Review updates the entry of a word after being reviewed, the review function returns the updated entry.
+// This function returns the following errors:
+//  - ErrWordNotFound
+func (repo MemoryRepository) Review(chatID int64, word string,
+	review func(entry telegram.WordEntry) telegram.WordEntry) error {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	entry, ok := repo.words[chatID][word]
+	if !ok {
+		return telegram.ErrWordNotFound
+	}
+
+	repo.words[chatID][word] = copyEntry(review(copyEntry(entry)))
+	return nil
+}
+
+// Search searches a word.
+// This function returns the following errors:
+//  - ErrNotRegistered
+//  - ErrWordNotFound
+func (repo MemoryRepository) Search(chatID int64, word string) (*string, error) {
+	entry, err := repo.SearchEntry(chatID, word)
+	if err != nil {
+		return nil, err
+	}
+
+	return &entry.Translation, nil
+}
+
+// SearchEntry searches a word and returns its entry with the metadata.
+// This function returns the following errors:
+//  - ErrNotRegistered
+//  - ErrWordNotFound
+func (repo MemoryRepository) SearchEntry(chatID int64, word string) (*telegram.WordEntry, error) {
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
+
+	if !repo.users[chatID] {
+		return nil, telegram.ErrNotRegistered
+	}
+
+	words := repo.words[chatID]
+
+	entry, ok := words[word]
+	if !ok {
+		return nil, telegram.ErrWordNotFound
+	}
+
+	entry = copyEntry(entry)
+	return &entry, nil
+}
+
+// Delete deletes a word.
+// This function returns the following errors:
+//  - ErrNotRegistered
+//  - ErrWordNotFound
+func (repo MemoryRepository) Delete(chatID int64, word string) error {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	if !repo.users[chatID] {
+		return telegram.ErrNotRegistered
+	}
+
+	words := repo.words[chatID]
+
+	if _, ok := words[word]; !ok {
+		return telegram.ErrWordNotFound
+	}
+
+	delete(words, word)
+	return nil
+}
+
+// Clear clears all words of the user identified with chat ID.
+// This function returns the following errors:
+//  - ErrNotRegistered
+func (repo MemoryRepository) Clear(chatID int64) error {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	if !repo.users[chatID] {
+		return telegram.ErrNotRegistered
+	}
+
+	delete(repo.words, chatID)
+	return nil
+}
+
+// List lists the vocabulary of the user, each element holding the Korean word and its translation.
+// This function returns the following errors:
+//  - ErrNotRegistered
+//  - ErrWordNotFound
+func (repo MemoryRepository) List(chatID int64) ([][]string, error) {
+	entries, err := repo.ListEntries(chatID, telegram.KindVocabulary)
+	if err != nil {
+		return nil, err
+	}
+
+	wordMap := make([][]string, 0, len(entries))
+	for _, entry := range entries {
+		wordMap = append(wordMap, []string{entry.Word, entry.Translation})
+	}
+
+	return wordMap, nil
+}
+
+// ListEntries lists the entries of the given kind of the user, in the order of the words as in bbolt.
+// This function returns the following errors:
+//  - ErrNotRegistered
+//  - ErrWordNotFound
+func (repo MemoryRepository) ListEntries(chatID int64, kind string) ([]telegram.Entry, error) {
+	entries, _, err := repo.ListPage(chatID, kind, "", 0, -1)
+	return entries, err
+}
+
+// ListPage lists at most limit entries of the kind matching the filter, a tag or a source, if any, from the offset, in
+// the order of the words. A negative limit lists them all. It also returns how many entries there are in total.
+// This function returns the following errors:
+//  - ErrNotRegistered
+//  - ErrWordNotFound
+func (repo MemoryRepository) ListPage(chatID int64, kind string, filter string, offset int,
+	limit int) ([]telegram.Entry, int, error) {
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
+
+	if !repo.users[chatID] {
+		return nil, 0, telegram.ErrNotRegistered
+	}
+
+	words := repo.words[chatID]
+
+	keys := make([]string, 0, len(words))
+	for word, entry := range words {
+		if entry.EntryKind() == kind && entry.Matches(filter) {
+			keys = append(keys, word)
+		}
+	}
+
+	if len(keys) == 0 {
+		return nil, 0, telegram.ErrWordNotFound
+	}
+
+	sort.Strings(keys)
+
+	entries := make([]telegram.Entry, 0)
+	for i, word := range keys {
+		if i >= offset && (limit < 0 || len(entries) < limit) {
+			entries = append(entries, telegram.Entry{Word: word, WordEntry: copyEntry(words[word])})
+		}
+	}
+
+	return entries, len(keys), nil
+}
+
+// Related finds the words of the user sharing a root with the given word, which does not need to be added. The result
+// maps each root to the related words, sorted alphabetically. The word itself is never part of the result.
+// This function returns the following errors:
+//  - ErrNotRegistered
+func (repo MemoryRepository) Related(chatID int64, word string) (map[string][]string, error) {
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
+
+	if !repo.users[chatID] {
+		return nil, telegram.ErrNotRegistered
+	}
+
+	words := repo.words[chatID]
+
+	related := make(map[string][]string)
+	for _, root := range repo.rootFinder.Roots(word) {
+		for candidate, entry := range words {
+			if candidate == word || entry.EntryKind() == telegram.KindGrammar {
+				continue
+			}
+
+			for _, candidateRoot := range repo.rootFinder.Roots(candidate) {
+				if candidateRoot == root {
+					related[root] = append(related[root], candidate)
+					break
+				}
+			}
+		}
+
+		sort.Strings(related[root])
+	}
+
+	return related, nil
+}
@@ -0,0 +1,166 @@
+package storage
+
+import (
+	"bytes"
+	"go.etcd.io/bbolt"
+	"log"
+	"sort"
+
+	"github.com/handracs2007/kquiz/telegram"
+)
+
+// RootFinder defines operations to be fulfilled by the implementation that has capability to find the roots of a word,
+// e.g. its hanja characters or its stem. Words sharing a root are related to each other.
+type RootFinder interface {
+	Roots(word string) []string
+}
+
+// Relater defines operations to be fulfilled by the implementation that has capability to find related words.
+type Relater interface {
+	Related(chatID int64, word string) (map[string][]string, error)
+}
+
+// relationKey returns the key of the relation index entry in the bucket of the user. The root and the word are separated
+// by a zero byte so that all words sharing a root can be found with a prefix scan.
+func relationKey(root string, word string) []byte {
+	return []byte(root + "\x00" + word)
+}
+
+func (repo BoltRepository) indexRelations(tx *bbolt.Tx, chatID int64, word string) error {
+	roots := repo.rootFinder.Roots(word)
+	if len(roots) == 0 {
+		return nil
+	}
+
+	bucket, err := telegram.CreateUserBucket(tx.Bucket(repo.relationBucket), chatID)
+	if err != nil {
+		return err
+	}
+
+	for _, root := range roots {
+		err := bucket.Put(relationKey(root, word), []byte{})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (repo BoltRepository) unindexRelations(tx *bbolt.Tx, chatID int64, word string) error {
+	bucket := telegram.UserBucket(tx.Bucket(repo.relationBucket), chatID)
+	if bucket == nil {
+		return nil
+	}
+
+	for _, root := range repo.rootFinder.Roots(word) {
+		err := bucket.Delete(relationKey(root, word))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (repo BoltRepository) clearRelations(tx *bbolt.Tx, chatID int64) error {
+	err := tx.Bucket(repo.relationBucket).DeleteBucket(telegram.UserPrefix(chatID))
+	if err == bbolt.ErrBucketNotFound {
+		return nil
+	}
+
+	return err
+}
+
+// RebuildRelations rebuilds the relation index from the words of all registered users. This is needed for the words
+// added before the index existed or when the roots of a word change, e.g. after the hanja dictionary is updated.
+// This function returns the following errors:
+//  - ErrDatabaseError
+func (repo BoltRepository) RebuildRelations() error {
+	users, err := repo.Users()
+	if err != nil {
+		return err
+	}
+
+	err = repo.shards.Update(func(tx *bbolt.Tx) error {
+		err := tx.DeleteBucket(repo.relationBucket)
+		if err != nil && err != bbolt.ErrBucketNotFound {
+			return err
+		}
+
+		_, err = tx.CreateBucket(repo.relationBucket)
+		return err
+	})
+	if err != nil {
+		log.Printf("Failed to reset relation index. %s.", err)
+		return telegram.ErrDatabaseError
+	}
+
+	for _, chatID := range users {
+		words, err := repo.List(chatID)
+		if err == telegram.ErrWordNotFound {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		err = repo.shards.For(chatID).Update(func(tx *bbolt.Tx) error {
+			for _, pair := range words {
+				err := repo.indexRelations(tx, chatID, pair[0])
+				if err != nil {
+					return err
+				}
+			}
+
+			return nil
+		})
+		if err != nil {
+			log.Printf("Failed to rebuild relation index. %s.", err)
+			return telegram.ErrDatabaseError
+		}
+	}
+
+	return nil
+}
+
+// Related finds the words of the user sharing a root with the given word, which does not need to be added. The result
+// maps each root to the related words, sorted alphabetically. The word itself is never part of the result.
+// This function returns the following errors:
+//  - ErrNotRegistered
+//  - ErrDatabaseError
+func (repo BoltRepository) Related(chatID int64, word string) (map[string][]string, error) {
+	if !repo.IsRegistered(chatID) {
+		return nil, telegram.ErrNotRegistered
+	}
+
+	related := make(map[string][]string)
+
+	err := repo.shards.For(chatID).View(func(tx *bbolt.Tx) error {
+		bucket := telegram.UserBucket(tx.Bucket(repo.relationBucket), chatID)
+		if bucket == nil {
+			return nil
+		}
+
+		cursor := bucket.Cursor()
+		for _, root := range repo.rootFinder.Roots(word) {
+			prefix := relationKey(root, "")
+			for key, _ := cursor.Seek(prefix); key != nil && bytes.HasPrefix(key, prefix); key, _ = cursor.Next() {
+				relatedWord := string(key[len(prefix):])
+				if relatedWord != word {
+					related[root] = append(related[root], relatedWord)
+				}
+			}
+
+			sort.Strings(related[root])
+		}
+
+		return nil
+	})
+	if err != nil {
+		log.Printf("Failed to find related words. %s.", err)
+		return nil, telegram.ErrDatabaseError
+	}
+
+	return related, nil
+}
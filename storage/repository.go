@@ -0,0 +1,89 @@
+// Package storage stores the users and their words behind the UserRepository and WordRepository interfaces, in bbolt
+// with BoltRepository, in memory with MemoryRepository or in SQLite with SQLRepository. The bot only depends on the
+// interfaces, so that the backends can be swapped.
+package storage
+
+import (
+	"github.com/handracs2007/kquiz/telegram"
+)
+
+// Registerer defines operations to be fulfilled by the implementation that has capability to register user.
+type Registerer interface {
+	Register(chatID int64) error
+}
+
+// Checker defines operations to be fulfilled by the implementation that has capability to check if an ID is registered.
+type Checker interface {
+	IsRegistered(chatID int64) bool
+	IsAdded(chatID int64, word string) bool
+}
+
+// Unregisterer defines operations to be fulfilled by the implementation that has capability to unregister user.
+type Unregisterer interface {
+	Unregister(chatID int64) error
+}
+
+// Adder defines operations to be fulfilled by the implementation that has capability to add word.
+type Adder interface {
+	Add(chatID int64, word string, translation string) error
+	AddEntry(chatID int64, word string, entry telegram.WordEntry) error
+}
+
+// Updater defines operations to be fulfilled by the implementation that has capability to update word.
+type Updater interface {
+	SetExample(chatID int64, word string, example string) error
+	SetNotes(chatID int64, word string, notes string) error
+	AddTag(chatID int64, word string, tag string) error
+	SetTranslation(chatID int64, word string, translation string) error
+}
+
+// Reviewer defines operations to be fulfilled by the implementation that has capability to track the reviews of the
+// words.
+type Reviewer interface {
+	Review(chatID int64, word string, review func(entry telegram.WordEntry) telegram.WordEntry) error
+}
+
+// Audience defines operations to be fulfilled by the implementation that has capability to list the users the bot
+// sends messages to on its own.
+type Audience interface {
+	Users() ([]int64, error)
+}
+
+// Deleter defines operations to be fulfilled by the implementation that has capability to delete word.
+type Deleter interface {
+	Delete(chatID int64, word string) error
+	Clear(chatID int64) error
+}
+
+// Searcher defines operations to be fulfilled by the implementation that has capability to search a word.
+type Searcher interface {
+	Search(chatID int64, word string) (*string, error)
+	SearchEntry(chatID int64, word string) (*telegram.WordEntry, error)
+}
+
+// Lister defines operations to be fulfilled by the implementation that has capability to list words.
+type Lister interface {
+	List(chatID int64) ([][]string, error)
+	ListEntries(chatID int64, kind string) ([]telegram.Entry, error)
+	ListPage(chatID int64, kind string, filter string, offset int, limit int) ([]telegram.Entry, int, error)
+}
+
+// UserRepository defines operations to be fulfilled by the storage of the users. BoltRepository stores them in bbolt,
+// other backends only need to implement this interface and WordRepository to be plugged in.
+type UserRepository interface {
+	Registerer
+	Unregisterer
+	Checker
+	Audience
+}
+
+// WordRepository defines operations to be fulfilled by the storage of the words of the users.
+type WordRepository interface {
+	Adder
+	Updater
+	Reviewer
+	Searcher
+	Deleter
+	Lister
+	Relater
+}
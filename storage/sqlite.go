@@ -0,0 +1,603 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	_ "github.com/mattn/go-sqlite3"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/handracs2007/kquiz/telegram"
+)
+
+// sqlMigrations are the statements upgrading the schema of the SQL database, one version after the other. The version
+// of the database is the number of migrations applied, the applied migrations must never change.
+var sqlMigrations = [][]string{
+	{
+		`CREATE TABLE users (
+			chat_id       INTEGER PRIMARY KEY,
+			registered_at TEXT NOT NULL
+		)`,
+		// The entry is stored whole as JSON, its main fields are copied into their own columns to be queried.
+		`CREATE TABLE words (
+			chat_id     INTEGER NOT NULL,
+			word        TEXT NOT NULL,
+			kind        TEXT NOT NULL,
+			translation TEXT NOT NULL,
+			deck        TEXT NOT NULL,
+			origin      TEXT NOT NULL,
+			created_at  TEXT,
+			due         TEXT,
+			entry       TEXT NOT NULL,
+			PRIMARY KEY (chat_id, word)
+		)`,
+		`CREATE INDEX words_kind ON words (chat_id, kind)`,
+	},
+}
+
+// SQLRepository keeps the users and their words in a SQLite database, it implements UserRepository and
+// WordRepository. The database can be queried and backed up with the standard tools, e.g. the sqlite3 shell.
+type SQLRepository struct {
+	db         *sql.DB
+	rootFinder RootFinder
+}
+
+// OpenSQLRepository opens the SQLite database at the path, creating it if needed, and migrates its schema to the
+// latest version.
+func OpenSQLRepository(path string, rootFinder RootFinder) (SQLRepository, error) {
+	db, err := sql.Open("sqlite3", path+"?_busy_timeout=5000&_journal_mode=WAL")
+	if err != nil {
+		return SQLRepository{}, err
+	}
+
+	// SQLite writes one transaction at a time anyway, a single connection spares retrying the busy ones.
+	db.SetMaxOpenConns(1)
+
+	err = migrateSQL(db)
+	if err != nil {
+		_ = db.Close()
+		return SQLRepository{}, err
+	}
+
+	return SQLRepository{db: db, rootFinder: rootFinder}, nil
+}
+
+// migrateSQL applies the migrations the database does not have yet, each in its own transaction.
+func migrateSQL(db *sql.DB) error {
+	_, err := db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    INTEGER PRIMARY KEY,
+		applied_at TEXT NOT NULL
+	)`)
+	if err != nil {
+		return err
+	}
+
+	var version int
+	err = db.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&version)
+	if err != nil {
+		return err
+	}
+
+	if version > len(sqlMigrations) {
+		return fmt.Errorf("the database is at version %d, newer than this bot at version %d", version,
+			len(sqlMigrations))
+	}
+
+	for ; version < len(sqlMigrations); version++ {
+		tx, err := db.Begin()
+		if err != nil {
+			return err
+		}
+
+		for _, statement := range sqlMigrations[version] {
+			_, err = tx.Exec(statement)
+			if err != nil {
+				_ = tx.Rollback()
+				return fmt.Errorf("migration %d failed, %s", version+1, err)
+			}
+		}
+
+		_, err = tx.Exec(`INSERT INTO schema_migrations (version, applied_at) VALUES (?, ?)`, version+1,
+			time.Now().UTC().Format(time.RFC3339))
+		if err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+
+		err = tx.Commit()
+		if err != nil {
+			return err
+		}
+
+		log.Printf("Migrated the SQL database to version %d.\n", version+1)
+	}
+
+	return nil
+}
+
+// Close closes the database.
+func (repo SQLRepository) Close() error {
+	return repo.db.Close()
+}
+
+// Snapshot writes a consistent copy of the database to the file at the path, which must not exist, while the bot
+// keeps serving.
+func (repo SQLRepository) Snapshot(path string) error {
+	_, err := repo.db.Exec("VACUUM INTO ?", path)
+	return err
+}
+
+// sqlTime returns the time as stored in the database, NULL for nil.
+func sqlTime(t *time.Time) interface{} {
+	if t == nil {
+		return nil
+	}
+
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+// putEntry inserts or replaces the entry of the word with its columns.
+func putEntry(tx *sql.Tx, chatID int64, word string, entry telegram.WordEntry, replace bool) (bool, error) {
+	value, err := telegram.EncodeEntry(entry)
+	if err != nil {
+		return false, err
+	}
+
+	statement := `INSERT INTO words (chat_id, word, kind, translation, deck, origin, created_at, due, entry)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?) ON CONFLICT (chat_id, word) DO NOTHING`
+	if replace {
+		statement = `UPDATE words SET kind = ?3, translation = ?4, deck = ?5, origin = ?6, created_at = ?7, due = ?8,
+			entry = ?9 WHERE chat_id = ?1 AND word = ?2`
+	}
+
+	result, err := tx.Exec(statement, chatID, word, entry.EntryKind(), entry.Translation, entry.Deck,
+		entry.EntryOrigin(), sqlTime(entry.CreatedAt), sqlTime(entry.Due), string(value))
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	return affected == 1, err
+}
+
+// Users returns the chat IDs of all registered users.
+// This function returns the following errors:
+//  - ErrDatabaseError
+func (repo SQLRepository) Users() ([]int64, error) {
+	rows, err := repo.db.Query(`SELECT chat_id FROM users ORDER BY chat_id`)
+	if err != nil {
+		log.Printf("Failed to list users. %s.\n", err)
+		return nil, telegram.ErrDatabaseError
+	}
+	defer rows.Close()
+
+	users := make([]int64, 0)
+	for rows.Next() {
+		var chatID int64
+		err = rows.Scan(&chatID)
+		if err != nil {
+			log.Printf("Failed to list users. %s.\n", err)
+			return nil, telegram.ErrDatabaseError
+		}
+
+		users = append(users, chatID)
+	}
+
+	if rows.Err() != nil {
+		log.Printf("Failed to list users. %s.\n", rows.Err())
+		return nil, telegram.ErrDatabaseError
+	}
+
+	return users, nil
+}
+
+// IsRegistered reports whether the user identified by the chat ID is registered.
+func (repo SQLRepository) IsRegistered(chatID int64) bool {
+	var registered bool
+	err := repo.db.QueryRow(`SELECT EXISTS (SELECT 1 FROM users WHERE chat_id = ?)`, chatID).Scan(&registered)
+	if err != nil {
+		log.Printf("Failed to get registration data. %s.\n", err)
+		return false
+	}
+
+	return registered
+}
+
+// IsAdded reports whether the user identified by the chat ID added the word.
+func (repo SQLRepository) IsAdded(chatID int64, word string) bool {
+	var added bool
+	err := repo.db.QueryRow(`SELECT EXISTS (SELECT 1 FROM words WHERE chat_id = ? AND word = ?)`, chatID,
+		word).Scan(&added)
+	if err != nil {
+		log.Printf("Failed to get word. %s.\n", err)
+		return false
+	}
+
+	return added
+}
+
+// Register registers a new user. This function can return the following errors:
+//  - ErrAlreadyRegistered
+//  - ErrDatabaseError
+func (repo SQLRepository) Register(chatID int64) error {
+	result, err := repo.db.Exec(`INSERT INTO users (chat_id, registered_at) VALUES (?, ?)
+		ON CONFLICT (chat_id) DO NOTHING`, chatID, time.Now().UTC().Format(time.RFC3339))
+	if err != nil {
+		log.Printf("Failed to update registration data. %s.\n", err)
+		return telegram.ErrDatabaseError
+	}
+
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return telegram.ErrAlreadyRegistered
+	}
+
+	return nil
+}
+
+// Unregister unregisters an existing user, the words are kept for when the user registers again. This function can
+// return the following errors:
+//  - ErrNotRegistered
+//  - ErrDatabaseError
+func (repo SQLRepository) Unregister(chatID int64) error {
+	result, err := repo.db.Exec(`DELETE FROM users WHERE chat_id = ?`, chatID)
+	if err != nil {
+		log.Printf("Failed to update registration data. %s.\n", err)
+		return telegram.ErrDatabaseError
+	}
+
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return telegram.ErrNotRegistered
+	}
+
+	return nil
+}
+
+// Add adds a word and its translation.
+// This function returns the following errors:
+//  - ErrNotRegistered
+//  - ErrDatabaseError
+//  - ErrDuplicateWord
+func (repo SQLRepository) Add(chatID int64, word string, translation string) error {
+	return repo.AddEntry(chatID, word, telegram.WordEntry{Translation: translation})
+}
+
+// AddEntry adds a word together with its metadata.
+// This function returns the following errors:
+//  - ErrNotRegistered
+//  - ErrDatabaseError
+//  - ErrDuplicateWord
+func (repo SQLRepository) AddEntry(chatID int64, word string, entry telegram.WordEntry) error {
+	if !repo.IsRegistered(chatID) {
+		return telegram.ErrNotRegistered
+	}
+
+	if entry.CreatedAt == nil {
+		now := time.Now()
+		entry.CreatedAt = &now
+	}
+
+	var added bool
+	err := repo.update(func(tx *sql.Tx) error {
+		var err error
+		added, err = putEntry(tx, chatID, word, entry, false)
+		return err
+	})
+	if err != nil {
+		log.Printf("Failed to add word. %s.", err)
+		return telegram.ErrDatabaseError
+	}
+
+	if !added {
+		return telegram.ErrDuplicateWord
+	}
+
+	return nil
+}
+
+// SetExample sets the example sentence of a word already added.
+// This function returns the following errors:
+//  - ErrNotRegistered
+//  - ErrDatabaseError
+//  - ErrWordNotFound
+func (repo SQLRepository) SetExample(chatID int64, word string, example string) error {
+	return repo.changeEntry(chatID, word, func(entry *telegram.WordEntry) {
+		entry.Example = example
+	})
+}
+
+// SetNotes sets the notes of a word already added.
+// This function returns the following errors:
+//  - ErrNotRegistered
+//  - ErrDatabaseError
+//  - ErrWordNotFound
+func (repo SQLRepository) SetNotes(chatID int64, word string, notes string) error {
+	return repo.changeEntry(chatID, word, func(entry *telegram.WordEntry) {
+		entry.Notes = notes
+	})
+}
+
+// AddTag tags a word already added, keeping its other tags.
+// This function returns the following errors:
+//  - ErrNotRegistered
+//  - ErrDatabaseError
+//  - ErrWordNotFound
+func (repo SQLRepository) AddTag(chatID int64, word string, tag string) error {
+	return repo.changeEntry(chatID, word, func(entry *telegram.WordEntry) {
+		if !entry.HasTag(tag) {
+			entry.Tags = append(entry.Tags, tag)
+		}
+	})
+}
+
+// SetTranslation replaces the translation of a word already added.
+// This function returns the following errors:
+//  - ErrNotRegistered
+//  - ErrDatabaseError
+//  - ErrWordNotFound
+func (repo SQLRepository) SetTranslation(chatID int64, word string, translation string) error {
+	return repo.changeEntry(chatID, word, func(entry *telegram.WordEntry) {
+		entry.Translation = translation
+	})
+}
+
+// update runs the function in a transaction, committed when it returns no error and rolled back otherwise.
+func (repo SQLRepository) update(fn func(tx *sql.Tx) error) error {
+	tx, err := repo.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	err = fn(tx)
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// reviseEntry replaces the entry of a word already added with the revised one, in a single transaction.
+func (repo SQLRepository) reviseEntry(chatID int64, word string,
+	revise func(entry telegram.WordEntry) telegram.WordEntry) error {
+	err := repo.update(func(tx *sql.Tx) error {
+		var value []byte
+		err := tx.QueryRow(`SELECT entry FROM words WHERE chat_id = ? AND word = ?`, chatID, word).Scan(&value)
+		if err == sql.ErrNoRows {
+			return telegram.ErrWordNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		_, err = putEntry(tx, chatID, word, revise(telegram.DecodeEntry(value)), true)
+		return err
+	})
+	if err != nil {
+		if err == telegram.ErrWordNotFound {
+			return telegram.ErrWordNotFound
+		}
+
+		log.Printf("Failed to change word. %s.", err)
+		return telegram.ErrDatabaseError
+	}
+
+	return nil
+}
+
+// changeEntry changes the entry of a word already added in place.
+func (repo SQLRepository) changeEntry(chatID int64, word string, change func(entry *telegram.WordEntry)) error {
+	if !repo.IsRegistered(chatID) {
+		return telegram.ErrNotRegistered
+	}
+
+	return repo.reviseEntry(chatID, word, func(entry telegram.WordEntry) telegram.WordEntry {
+		change(&entry)
+		return entry
+	})
+}
+
+// Review updates the entry of a word after being reviewed, the review function returns the updated entry.
+// This function returns the following errors:
+//  - ErrDatabaseError
+//  - ErrWordNotFound
+func (repo SQLRepository) Review(chatID int64, word string,
+	review func(entry telegram.WordEntry) telegram.WordEntry) error {
+	return repo.reviseEntry(chatID, word, review)
+}
+
+// Search searches a word.
+// This function returns the following errors:
+//  - ErrNotRegistered
+//  - ErrDatabaseError
+//  - ErrWordNotFound
+func (repo SQLRepository) Search(chatID int64, word string) (*string, error) {
+	entry, err := repo.SearchEntry(chatID, word)
+	if err != nil {
+		return nil, err
+	}
+
+	return &entry.Translation, nil
+}
+
+// SearchEntry searches a word and returns its entry with the metadata.
+// This function returns the following errors:
+//  - ErrNotRegistered
+//  - ErrDatabaseError
+//  - ErrWordNotFound
+func (repo SQLRepository) SearchEntry(chatID int64, word string) (*telegram.WordEntry, error) {
+	if !repo.IsRegistered(chatID) {
+		return nil, telegram.ErrNotRegistered
+	}
+
+	var value []byte
+	err := repo.db.QueryRow(`SELECT entry FROM words WHERE chat_id = ? AND word = ?`, chatID, word).Scan(&value)
+	if err == sql.ErrNoRows {
+		return nil, telegram.ErrWordNotFound
+	}
+	if err != nil {
+		log.Printf("Failed to get word. %s.", err)
+		return nil, telegram.ErrDatabaseError
+	}
+
+	entry := telegram.DecodeEntry(value)
+	return &entry, nil
+}
+
+// Delete deletes a word.
+// This function returns the following errors:
+//  - ErrNotRegistered
+//  - ErrDatabaseError
+//  - ErrWordNotFound
+func (repo SQLRepository) Delete(chatID int64, word string) error {
+	if !repo.IsRegistered(chatID) {
+		return telegram.ErrNotRegistered
+	}
+
+	result, err := repo.db.Exec(`DELETE FROM words WHERE chat_id = ? AND word = ?`, chatID, word)
+	if err != nil {
+		log.Printf("Failed to delete word. %s.", err)
+		return telegram.ErrDatabaseError
+	}
+
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return telegram.ErrWordNotFound
+	}
+
+	return nil
+}
+
+// Clear clears all words of the user identified with chat ID.
+// This function returns the following errors:
+//  - ErrNotRegistered
+//  - ErrDatabaseError
+func (repo SQLRepository) Clear(chatID int64) error {
+	if !repo.IsRegistered(chatID) {
+		return telegram.ErrNotRegistered
+	}
+
+	_, err := repo.db.Exec(`DELETE FROM words WHERE chat_id = ?`, chatID)
+	if err != nil {
+		log.Printf("Failed to clear words. %s.", err)
+		return telegram.ErrDatabaseError
+	}
+
+	return nil
+}
+
+// List lists the vocabulary of the user, each element holding the Korean word and its translation.
+// This function returns the following errors:
+//  - ErrNotRegistered
+//  - ErrDatabaseError
+//  - ErrWordNotFound
+func (repo SQLRepository) List(chatID int64) ([][]string, error) {
+	entries, err := repo.ListEntries(chatID, telegram.KindVocabulary)
+	if err != nil {
+		return nil, err
+	}
+
+	wordMap := make([][]string, 0, len(entries))
+	for _, entry := range entries {
+		wordMap = append(wordMap, []string{entry.Word, entry.Translation})
+	}
+
+	return wordMap, nil
+}
+
+// ListEntries lists the entries of the given kind of the user, in the order of the words as in bbolt.
+// This function returns the following errors:
+//  - ErrNotRegistered
+//  - ErrDatabaseError
+//  - ErrWordNotFound
+func (repo SQLRepository) ListEntries(chatID int64, kind string) ([]telegram.Entry, error) {
+	entries, _, err := repo.ListPage(chatID, kind, "", 0, -1)
+	return entries, err
+}
+
+// ListPage lists at most limit entries of the kind matching the filter, a tag or a source, if any, from the offset, in
+// the order of the words. A negative limit lists them all. It also returns how many entries there are in total.
+// This function returns the following errors:
+//  - ErrNotRegistered
+//  - ErrDatabaseError
+//  - ErrWordNotFound
+func (repo SQLRepository) ListPage(chatID int64, kind string, filter string, offset int,
+	limit int) ([]telegram.Entry, int, error) {
+	if !repo.IsRegistered(chatID) {
+		return nil, 0, telegram.ErrNotRegistered
+	}
+
+	// The words are compared byte by byte, as the keys of bbolt.
+	rows, err := repo.db.Query(`SELECT word, entry FROM words WHERE chat_id = ? AND kind = ? ORDER BY word`, chatID,
+		kind)
+	if err != nil {
+		log.Printf("Failed to list words. %s.", err)
+		return nil, 0, telegram.ErrDatabaseError
+	}
+	defer rows.Close()
+
+	entries := make([]telegram.Entry, 0)
+	total := 0
+	for rows.Next() {
+		var word string
+		var value []byte
+		err = rows.Scan(&word, &value)
+		if err != nil {
+			log.Printf("Failed to list words. %s.", err)
+			return nil, 0, telegram.ErrDatabaseError
+		}
+
+		// The tags and the sources are matched as in the other storages.
+		entry := telegram.DecodeEntry(value)
+		if !entry.Matches(filter) {
+			continue
+		}
+
+		if total >= offset && (limit < 0 || len(entries) < limit) {
+			entries = append(entries, telegram.Entry{Word: word, WordEntry: entry})
+		}
+		total++
+	}
+
+	if rows.Err() != nil {
+		log.Printf("Failed to list words. %s.", rows.Err())
+		return nil, 0, telegram.ErrDatabaseError
+	}
+
+	if total == 0 {
+		return nil, 0, telegram.ErrWordNotFound
+	}
+
+	return entries, total, nil
+}
+
+// Related finds the words of the user sharing a root with the given word, which does not need to be added. The result
+// maps each root to the related words, sorted alphabetically. The word itself is never part of the result.
+// This function returns the following errors:
+//  - ErrNotRegistered
+//  - ErrDatabaseError
+func (repo SQLRepository) Related(chatID int64, word string) (map[string][]string, error) {
+	entries, err := repo.ListEntries(chatID, telegram.KindVocabulary)
+	if err != nil && err != telegram.ErrWordNotFound {
+		return nil, err
+	}
+
+	related := make(map[string][]string)
+	for _, root := range repo.rootFinder.Roots(word) {
+		for _, entry := range entries {
+			if entry.Word == word {
+				continue
+			}
+
+			for _, candidateRoot := range repo.rootFinder.Roots(entry.Word) {
+				if candidateRoot == root {
+					related[root] = append(related[root], entry.Word)
+					break
+				}
+			}
+		}
+
+		sort.Strings(related[root])
+	}
+
+	return related, nil
+}
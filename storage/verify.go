@@ -0,0 +1,103 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go.etcd.io/bbolt"
+	"log"
+	"reflect"
+	"strconv"
+
+	"github.com/handracs2007/kquiz/telegram"
+)
+
+// Verify checks that every word in the database is nested in the bucket of a registered user of its shard and that its
+// entry survives being encoded again, and returns a description of each problem found. The words left in the earlier
+// layout are reported too, the bot nests them when it starts.
+// This function returns the following errors:
+//  - ErrDatabaseError
+func (repo BoltRepository) Verify() ([]string, error) {
+	problems := make([]string, 0)
+
+	for index, db := range repo.shards {
+		err := db.View(func(tx *bbolt.Tx) error {
+			if tx.Bucket(repo.telegramBucket) == nil || tx.Bucket(repo.kquizBucket) == nil {
+				problems = append(problems, fmt.Sprintf("shard %d: buckets are missing", index))
+				return nil
+			}
+
+			users := make(map[int64]bool)
+			err := tx.Bucket(repo.telegramBucket).ForEach(func(key, _ []byte) error {
+				chatID, err := strconv.ParseInt(string(key), 10, 64)
+				if err != nil {
+					problems = append(problems, fmt.Sprintf("shard %d: invalid registration %q", index, key))
+					return nil
+				}
+
+				users[chatID] = true
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+
+			parent := tx.Bucket(repo.kquizBucket)
+			return parent.ForEach(func(name, value []byte) error {
+				if value != nil {
+					problems = append(problems, fmt.Sprintf("shard %d: word %q is not nested in the bucket of its "+
+						"user", index, name))
+					return nil
+				}
+
+				chatID, err := strconv.ParseInt(string(name), 10, 64)
+				switch {
+				case err != nil:
+					problems = append(problems, fmt.Sprintf("shard %d: bucket %q is not named after a chat", index,
+						name))
+					return nil
+				case !users[chatID]:
+					problems = append(problems, fmt.Sprintf("shard %d: words of chat %d have no registered owner",
+						index, chatID))
+				case repo.shards.For(chatID) != db:
+					problems = append(problems, fmt.Sprintf("shard %d: words of chat %d are in the wrong shard",
+						index, chatID))
+				}
+
+				return parent.Bucket(name).ForEach(func(word, value []byte) error {
+					if problem := verifyEntry(value); len(problem) != 0 {
+						problems = append(problems, fmt.Sprintf("shard %d: word %q of chat %d %s", index, word,
+							chatID, problem))
+					}
+
+					return nil
+				})
+			})
+		})
+		if err != nil {
+			log.Printf("Failed to verify shard %d. %s.\n", index, err)
+			return nil, telegram.ErrDatabaseError
+		}
+	}
+
+	return problems, nil
+}
+
+// verifyEntry describes what is wrong with the stored entry, if anything.
+func verifyEntry(value []byte) string {
+	if bytes.HasPrefix(value, []byte("{")) && !json.Valid(value) {
+		return "has an undecodable entry read as a plain translation"
+	}
+
+	entry := telegram.DecodeEntry(value)
+	encoded, err := telegram.EncodeEntry(entry)
+	if err != nil {
+		return fmt.Sprintf("cannot be encoded again. %s", err)
+	}
+
+	if !reflect.DeepEqual(telegram.DecodeEntry(encoded), entry) {
+		return "changes when encoded again"
+	}
+
+	return ""
+}
@@ -0,0 +1,175 @@
+package telegram
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go.etcd.io/bbolt"
+	"log"
+	"strconv"
+	"strings"
+)
+
+// ErrUnknownUser is returned when no user who talked to the bot has the username.
+var ErrUnknownUser = errors.New("unknown user, they must talk to the bot first")
+
+// Permissions defines operations to be fulfilled by the implementation that has capability to let the users read the
+// data of other users, e.g. a tutor reading the stats and the transcripts of a student.
+type Permissions interface {
+	RememberUser(chatID int64, username string) error
+	Lookup(username string) (int64, error)
+	Grant(owner int64, grantee int64, username string) error
+	Revoke(owner int64, grantee int64) error
+	Grantees(owner int64) ([]string, error)
+	CanRead(viewer int64, owner int64) bool
+}
+
+// AccessStore stores the usernames of the users, so that they can refer to each other, and the read-only access they
+// grant each other. It is shared by all the users.
+type AccessStore struct {
+	bucket []byte
+	db     *bbolt.DB
+}
+
+// NewAccessStore creates a new instance of AccessStore
+func NewAccessStore(db *bbolt.DB, bucket string) AccessStore {
+	return AccessStore{db: db, bucket: []byte(bucket)}
+}
+
+// usernameKey returns the key of the chat ID of the username, usernames are case insensitive.
+func usernameKey(username string) []byte {
+	return []byte("user:" + strings.ToLower(strings.TrimPrefix(username, "@")))
+}
+
+// grantPrefix returns the prefix of the keys of the grants of the owner.
+func grantPrefix(owner int64) []byte {
+	return []byte(fmt.Sprintf("grant:%d:", owner))
+}
+
+// grantKey returns the key of the grant of the owner to the grantee.
+func grantKey(owner int64, grantee int64) []byte {
+	return append(grantPrefix(owner), strconv.FormatInt(grantee, 10)...)
+}
+
+// RememberUser remembers the chat ID of the username, it is written only when it changed.
+// This function returns the following errors:
+//  - ErrDatabaseError
+func (store AccessStore) RememberUser(chatID int64, username string) error {
+	if len(username) == 0 {
+		return nil
+	}
+
+	value := []byte(strconv.FormatInt(chatID, 10))
+	known := false
+	err := store.db.View(func(tx *bbolt.Tx) error {
+		known = bytes.Equal(tx.Bucket(store.bucket).Get(usernameKey(username)), value)
+		return nil
+	})
+	if err == nil && !known {
+		err = store.db.Update(func(tx *bbolt.Tx) error {
+			return tx.Bucket(store.bucket).Put(usernameKey(username), value)
+		})
+	}
+	if err != nil {
+		log.Printf("Failed to remember username. %s.\n", err)
+		return ErrDatabaseError
+	}
+
+	return nil
+}
+
+// Lookup returns the chat ID of the user with the username.
+// This function returns the following errors:
+//  - ErrUnknownUser
+//  - ErrDatabaseError
+func (store AccessStore) Lookup(username string) (int64, error) {
+	var value []byte
+	err := store.db.View(func(tx *bbolt.Tx) error {
+		value = append([]byte(nil), tx.Bucket(store.bucket).Get(usernameKey(username))...)
+		return nil
+	})
+	if err != nil {
+		log.Printf("Failed to look username up. %s.\n", err)
+		return 0, ErrDatabaseError
+	}
+
+	chatID, err := strconv.ParseInt(string(value), 10, 64)
+	if err != nil {
+		return 0, ErrUnknownUser
+	}
+
+	return chatID, nil
+}
+
+// Grant lets the grantee, known by the username, read the stats and the transcripts of the owner.
+// This function returns the following errors:
+//  - ErrDatabaseError
+func (store AccessStore) Grant(owner int64, grantee int64, username string) error {
+	err := store.db.Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket(store.bucket).Put(grantKey(owner, grantee), []byte(strings.TrimPrefix(username, "@")))
+	})
+	if err != nil {
+		log.Printf("Failed to grant access. %s.\n", err)
+		return ErrDatabaseError
+	}
+
+	return nil
+}
+
+// Revoke withdraws the access granted by the owner to the grantee, if any.
+// This function returns the following errors:
+//  - ErrDatabaseError
+func (store AccessStore) Revoke(owner int64, grantee int64) error {
+	err := store.db.Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket(store.bucket).Delete(grantKey(owner, grantee))
+	})
+	if err != nil {
+		log.Printf("Failed to revoke access. %s.\n", err)
+		return ErrDatabaseError
+	}
+
+	return nil
+}
+
+// Grantees lists the usernames of the users the owner granted access to.
+// This function returns the following errors:
+//  - ErrDatabaseError
+func (store AccessStore) Grantees(owner int64) ([]string, error) {
+	grantees := make([]string, 0)
+	prefix := grantPrefix(owner)
+
+	err := store.db.View(func(tx *bbolt.Tx) error {
+		cursor := tx.Bucket(store.bucket).Cursor()
+		for key, value := cursor.Seek(prefix); key != nil && bytes.HasPrefix(key, prefix); key, value = cursor.Next() {
+			grantees = append(grantees, string(value))
+		}
+
+		return nil
+	})
+	if err != nil {
+		log.Printf("Failed to list grants. %s.\n", err)
+		return nil, ErrDatabaseError
+	}
+
+	return grantees, nil
+}
+
+// CanRead reports whether the viewer may read the stats and the transcripts of the owner, that is the viewer is the
+// owner or was granted access. A failure to read the grants denies the access.
+func (store AccessStore) CanRead(viewer int64, owner int64) bool {
+	if viewer == owner {
+		return true
+	}
+
+	granted := false
+	err := store.db.View(func(tx *bbolt.Tx) error {
+		granted = tx.Bucket(store.bucket).Get(grantKey(owner, viewer)) != nil
+		return nil
+	})
+	if err != nil {
+		log.Printf("Failed to read grants. %s.\n", err)
+		return false
+	}
+
+	return granted
+}
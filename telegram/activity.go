@@ -0,0 +1,172 @@
+package telegram
+
+import (
+	"encoding/json"
+	"go.etcd.io/bbolt"
+	"log"
+	"time"
+)
+
+// dayLayout is the layout of the days of the study streaks.
+const dayLayout = "2006-01-02"
+
+// Activity represents the studying of a user, used to personalise the messages sent to the user.
+type Activity struct {
+	// Name is the first name of the user on Telegram.
+	Name string `json:"name,omitempty"`
+	// LastStudied is when the user last answered a quiz.
+	LastStudied time.Time `json:"last_studied"`
+	// LastDay is the day of LastStudied in the time zone of the user, and Streak the number of days in a row the user
+	// studied until then.
+	LastDay string `json:"last_day,omitempty"`
+	Streak  int    `json:"streak,omitempty"`
+	// Nudge is the event prefix of the variant of the last nudge sent to the user, sent at NudgedAt.
+	Nudge    string    `json:"nudge,omitempty"`
+	NudgedAt time.Time `json:"nudged_at,omitempty"`
+	// WinBackAt is when the last win-back message was sent, at most one per lapse.
+	WinBackAt time.Time `json:"win_back_at,omitempty"`
+}
+
+// Lapsed reports whether the user studied before yet not for the given duration, and was not won back since.
+func (activity Activity) Lapsed(now time.Time, inactivity time.Duration) bool {
+	return !activity.LastStudied.IsZero() && now.Sub(activity.LastStudied) >= inactivity &&
+		activity.WinBackAt.Before(activity.LastStudied)
+}
+
+// CurrentStreak returns the streak still alive on the given day, that is when the user studied that day or the day
+// before.
+func (activity Activity) CurrentStreak(now time.Time, location *time.Location) int {
+	today := now.In(location)
+	if activity.LastDay == today.Format(dayLayout) || activity.LastDay == today.AddDate(0, 0, -1).Format(dayLayout) {
+		return activity.Streak
+	}
+
+	return 0
+}
+
+// ActivityTracker defines operations to be fulfilled by the implementation that has capability to track the studying
+// of the users.
+type ActivityTracker interface {
+	RecordStudy(chatID int64, name string, now time.Time, location *time.Location) (Activity, error)
+	RecordNudge(chatID int64, nudge string, now time.Time) error
+	RecordWinBack(chatID int64, nudge string, now time.Time) error
+	Activity(chatID int64) (Activity, error)
+}
+
+// ActivityStore stores the studying of the users.
+type ActivityStore struct {
+	bucket  []byte
+	shards  Shards
+	journal *Journal
+}
+
+// NewActivityStore creates a new instance of ActivityStore
+func NewActivityStore(shards Shards, bucket string, journal *Journal) ActivityStore {
+	return ActivityStore{shards: shards, bucket: []byte(bucket), journal: journal}
+}
+
+// update changes the activity of the user identified by the chat ID in place and returns the changed activity.
+func (store ActivityStore) update(chatID int64, change func(activity *Activity)) (Activity, error) {
+	var activity Activity
+	var record JournalRecord
+
+	err := store.shards.For(chatID).Update(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket(store.bucket)
+		if data := bucket.Get(userKey(chatID, "")); data != nil {
+			err := json.Unmarshal(data, &activity)
+			if err != nil {
+				return err
+			}
+		}
+
+		change(&activity)
+
+		value, err := json.Marshal(activity)
+		if err != nil {
+			return err
+		}
+
+		record = PutRecord(chatID, store.bucket, userKey(chatID, ""), value)
+		return bucket.Put(userKey(chatID, ""), value)
+	})
+	if err != nil {
+		log.Printf("Failed to save activity. %s.\n", err)
+		return Activity{}, ErrDatabaseError
+	}
+
+	store.journal.Append(record)
+	return activity, nil
+}
+
+// RecordStudy records that the user identified by the chat ID answered a quiz, extending the streak on the first answer
+// of the day in the time zone of the user.
+// This function returns the following errors:
+//  - ErrDatabaseError
+func (store ActivityStore) RecordStudy(chatID int64, name string, now time.Time,
+	location *time.Location) (Activity, error) {
+	return store.update(chatID, func(activity *Activity) {
+		today := now.In(location)
+		switch activity.LastDay {
+		case today.Format(dayLayout):
+		case today.AddDate(0, 0, -1).Format(dayLayout):
+			activity.Streak++
+		default:
+			activity.Streak = 1
+		}
+
+		if len(name) != 0 {
+			activity.Name = name
+		}
+		activity.LastStudied = now
+		activity.LastDay = today.Format(dayLayout)
+	})
+}
+
+// RecordNudge records the variant of the nudge sent to the user identified by the chat ID, so that a quiz soon after
+// counts as engagement with it.
+// This function returns the following errors:
+//  - ErrDatabaseError
+func (store ActivityStore) RecordNudge(chatID int64, nudge string, now time.Time) error {
+	_, err := store.update(chatID, func(activity *Activity) {
+		activity.Nudge = nudge
+		activity.NudgedAt = now
+	})
+
+	return err
+}
+
+// RecordWinBack records the win-back message sent to the lapsed user identified by the chat ID, which is a nudge as
+// well.
+// This function returns the following errors:
+//  - ErrDatabaseError
+func (store ActivityStore) RecordWinBack(chatID int64, nudge string, now time.Time) error {
+	_, err := store.update(chatID, func(activity *Activity) {
+		activity.Nudge = nudge
+		activity.NudgedAt = now
+		activity.WinBackAt = now
+	})
+
+	return err
+}
+
+// Activity returns the studying of the user identified by the chat ID, the zero value when the user never studied.
+// This function returns the following errors:
+//  - ErrDatabaseError
+func (store ActivityStore) Activity(chatID int64) (Activity, error) {
+	var activity Activity
+
+	err := store.shards.For(chatID).View(func(tx *bbolt.Tx) error {
+		data := tx.Bucket(store.bucket).Get(userKey(chatID, ""))
+		if data == nil {
+			return nil
+		}
+
+		return json.Unmarshal(data, &activity)
+	})
+	if err != nil {
+		log.Printf("Failed to read activity. %s.\n", err)
+		return Activity{}, ErrDatabaseError
+	}
+
+	return activity, nil
+}
@@ -0,0 +1,208 @@
+package telegram
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"go.etcd.io/bbolt"
+	"log"
+	"time"
+)
+
+// AnalyticsRecorder defines operations to be fulfilled by the implementation that has capability to count the events
+// the operators learn from.
+type AnalyticsRecorder interface {
+	Count(event string) error
+	Counts(prefix string) (map[string]int, error)
+}
+
+// AnalyticsStore counts the events the operators learn from, e.g. the engagement with the nudges. With a salt, the
+// chat IDs are anonymised before they are written.
+type AnalyticsStore struct {
+	bucket []byte
+	db     *bbolt.DB
+	salt   []byte
+}
+
+// NewAnalyticsStore creates a new instance of AnalyticsStore
+func NewAnalyticsStore(db *bbolt.DB, bucket string, salt string) AnalyticsStore {
+	return AnalyticsStore{db: db, bucket: []byte(bucket), salt: []byte(salt)}
+}
+
+// Count counts one more occurrence of the event.
+// This function returns the following errors:
+//  - ErrDatabaseError
+func (store AnalyticsStore) Count(event string) error {
+	err := store.db.Update(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket(store.bucket)
+
+		var count uint64
+		if data := bucket.Get([]byte(event)); len(data) == 8 {
+			count = binary.BigEndian.Uint64(data)
+		}
+
+		value := make([]byte, 8)
+		binary.BigEndian.PutUint64(value, count+1)
+		return bucket.Put([]byte(event), value)
+	})
+	if err != nil {
+		log.Printf("Failed to count event. %s.\n", err)
+		return ErrDatabaseError
+	}
+
+	return nil
+}
+
+// Counts returns the occurrences of the events starting with the prefix.
+// This function returns the following errors:
+//  - ErrDatabaseError
+func (store AnalyticsStore) Counts(prefix string) (map[string]int, error) {
+	counts := make(map[string]int)
+
+	err := store.db.View(func(tx *bbolt.Tx) error {
+		start := []byte(prefix)
+		cursor := tx.Bucket(store.bucket).Cursor()
+		for key, value := cursor.Seek(start); key != nil && bytes.HasPrefix(key, start); key, value = cursor.Next() {
+			if len(value) == 8 {
+				counts[string(key)] = int(binary.BigEndian.Uint64(value))
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		log.Printf("Failed to read events. %s.\n", err)
+		return nil, ErrDatabaseError
+	}
+
+	return counts, nil
+}
+
+// commandLogPrefix prefixes the commands used, keyed by the time they were used so that they sort in order, and
+// dailyPrefix the number of uses of each command per day.
+const commandLogPrefix = "log/"
+const dailyPrefix = "daily/"
+
+// CommandLog represents a command used by a user. The arguments are not kept. When the analytics are anonymised, the
+// chat ID is left out and User holds its keyed hash, which tells the users apart without identifying them.
+type CommandLog struct {
+	ChatID  int64     `json:"chat_id,omitempty"`
+	User    string    `json:"user,omitempty"`
+	Command string    `json:"command"`
+	At      time.Time `json:"at"`
+}
+
+// commandLog returns the log of the command, anonymised when the store has a salt.
+func (store AnalyticsStore) commandLog(chatID int64, command string, now time.Time) CommandLog {
+	if len(store.salt) == 0 {
+		return CommandLog{ChatID: chatID, Command: command, At: now}
+	}
+
+	// A plain hash of a chat ID is easily reversed by hashing every ID, hence, the salt keys the hash.
+	mac := hmac.New(sha256.New, store.salt)
+	_ = binary.Write(mac, binary.BigEndian, chatID)
+	return CommandLog{User: hex.EncodeToString(mac.Sum(nil)), Command: command, At: now}
+}
+
+func commandLogKey(at time.Time) []byte {
+	return []byte(fmt.Sprintf("%s%020d", commandLogPrefix, at.UnixNano()))
+}
+
+// LogCommand logs the command used by the user identified by the chat ID and counts it in the daily aggregates.
+// This function returns the following errors:
+//  - ErrDatabaseError
+func (store AnalyticsStore) LogCommand(chatID int64, command string, now time.Time) error {
+	data, err := json.Marshal(store.commandLog(chatID, command, now))
+	if err != nil {
+		log.Printf("Failed to encode command log. %s.\n", err)
+		return ErrDatabaseError
+	}
+
+	err = store.db.Update(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket(store.bucket)
+
+		// Two commands in the same nanosecond would overwrite each other, let's find the next free key.
+		at := now
+		for bucket.Get(commandLogKey(at)) != nil {
+			at = at.Add(1)
+		}
+
+		err := bucket.Put(commandLogKey(at), data)
+		if err != nil {
+			return err
+		}
+
+		key := []byte(dailyPrefix + now.UTC().Format(dayLayout) + "/" + command)
+		var count uint64
+		if data := bucket.Get(key); len(data) == 8 {
+			count = binary.BigEndian.Uint64(data)
+		}
+
+		value := make([]byte, 8)
+		binary.BigEndian.PutUint64(value, count+1)
+		return bucket.Put(key, value)
+	})
+	if err != nil {
+		log.Printf("Failed to log command. %s.\n", err)
+		return ErrDatabaseError
+	}
+
+	return nil
+}
+
+// pruneBefore removes the keys starting with the prefix and sorting before the prefix followed by the limit, and
+// returns how many were removed.
+func (store AnalyticsStore) pruneBefore(prefix string, limit string) (int, error) {
+	removed := 0
+
+	err := store.db.Update(func(tx *bbolt.Tx) error {
+		start := []byte(prefix)
+		end := []byte(prefix + limit)
+
+		bucket := tx.Bucket(store.bucket)
+
+		var keys [][]byte
+		cursor := bucket.Cursor()
+		for key, _ := cursor.Seek(start); key != nil && bytes.HasPrefix(key, start); key, _ = cursor.Next() {
+			if bytes.Compare(key, end) >= 0 {
+				break
+			}
+
+			keys = append(keys, append([]byte(nil), key...))
+		}
+
+		for _, key := range keys {
+			err := bucket.Delete(key)
+			if err != nil {
+				return err
+			}
+		}
+
+		removed = len(keys)
+		return nil
+	})
+	if err != nil {
+		log.Printf("Failed to prune analytics. %s.\n", err)
+		return 0, ErrDatabaseError
+	}
+
+	return removed, nil
+}
+
+// PruneCommandLog removes the commands used before the given time and returns how many were removed.
+// This function returns the following errors:
+//  - ErrDatabaseError
+func (store AnalyticsStore) PruneCommandLog(before time.Time) (int, error) {
+	return store.pruneBefore(commandLogPrefix, string(commandLogKey(before)[len(commandLogPrefix):]))
+}
+
+// PruneDailyCounts removes the daily aggregates of the days before the given time and returns how many were removed.
+// This function returns the following errors:
+//  - ErrDatabaseError
+func (store AnalyticsStore) PruneDailyCounts(before time.Time) (int, error) {
+	return store.pruneBefore(dailyPrefix, before.UTC().Format(dayLayout))
+}
@@ -0,0 +1,131 @@
+package telegram
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"go.etcd.io/bbolt"
+	"log"
+	"strconv"
+)
+
+// ErrInvalidToken indicates that the API token does not exist or was revoked.
+var ErrInvalidToken = errors.New("invalid token")
+
+// TokenIssuer defines operations to be fulfilled by the implementation that has capability to issue the API tokens
+// letting other services act on behalf of the users, e.g. add words from a browser extension.
+type TokenIssuer interface {
+	IssueToken(chatID int64) (string, error)
+	RevokeToken(chatID int64) error
+	ResolveToken(token string) (int64, error)
+}
+
+// TokenStore stores the API tokens of the users, at most one each. Only the hash of the tokens is kept, so that a copy
+// of the database does not leak them. It is shared by all the users.
+type TokenStore struct {
+	bucket []byte
+	db     *bbolt.DB
+}
+
+// NewTokenStore creates a new instance of TokenStore
+func NewTokenStore(db *bbolt.DB, bucket string) TokenStore {
+	return TokenStore{db: db, bucket: []byte(bucket)}
+}
+
+// tokenKey returns the key of the chat ID owning the token.
+func tokenKey(token string) []byte {
+	hash := sha256.Sum256([]byte(token))
+	return []byte("token:" + hex.EncodeToString(hash[:]))
+}
+
+// tokenOwnerKey returns the key of the token key of the user, to revoke the token of the user.
+func tokenOwnerKey(chatID int64) []byte {
+	return []byte(fmt.Sprintf("owner:%d", chatID))
+}
+
+// IssueToken creates a new random API token for the user identified by the chat ID, revoking the previous one.
+// This function returns the following errors:
+//  - ErrDatabaseError
+func (store TokenStore) IssueToken(chatID int64) (string, error) {
+	random := make([]byte, 24)
+	_, err := rand.Read(random)
+	if err != nil {
+		log.Printf("Failed to generate API token. %s.\n", err)
+		return "", ErrDatabaseError
+	}
+
+	token := hex.EncodeToString(random)
+	err = store.db.Update(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket(store.bucket)
+		if previous := bucket.Get(tokenOwnerKey(chatID)); previous != nil {
+			err := bucket.Delete(previous)
+			if err != nil {
+				return err
+			}
+		}
+
+		err := bucket.Put(tokenKey(token), []byte(strconv.FormatInt(chatID, 10)))
+		if err != nil {
+			return err
+		}
+
+		return bucket.Put(tokenOwnerKey(chatID), tokenKey(token))
+	})
+	if err != nil {
+		log.Printf("Failed to save API token. %s.\n", err)
+		return "", ErrDatabaseError
+	}
+
+	return token, nil
+}
+
+// RevokeToken revokes the API token of the user identified by the chat ID, if any.
+// This function returns the following errors:
+//  - ErrDatabaseError
+func (store TokenStore) RevokeToken(chatID int64) error {
+	err := store.db.Update(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket(store.bucket)
+		previous := bucket.Get(tokenOwnerKey(chatID))
+		if previous == nil {
+			return nil
+		}
+
+		err := bucket.Delete(previous)
+		if err != nil {
+			return err
+		}
+
+		return bucket.Delete(tokenOwnerKey(chatID))
+	})
+	if err != nil {
+		log.Printf("Failed to revoke API token. %s.\n", err)
+		return ErrDatabaseError
+	}
+
+	return nil
+}
+
+// ResolveToken returns the chat ID owning the API token.
+// This function returns the following errors:
+//  - ErrInvalidToken
+//  - ErrDatabaseError
+func (store TokenStore) ResolveToken(token string) (int64, error) {
+	var value []byte
+	err := store.db.View(func(tx *bbolt.Tx) error {
+		value = append([]byte(nil), tx.Bucket(store.bucket).Get(tokenKey(token))...)
+		return nil
+	})
+	if err != nil {
+		log.Printf("Failed to resolve API token. %s.\n", err)
+		return 0, ErrDatabaseError
+	}
+
+	chatID, err := strconv.ParseInt(string(value), 10, 64)
+	if err != nil {
+		return 0, ErrInvalidToken
+	}
+
+	return chatID, nil
+}
@@ -0,0 +1,192 @@
+package telegram
+
+import (
+	"encoding/json"
+	"go.etcd.io/bbolt"
+	"log"
+	"sort"
+	"time"
+)
+
+// CachedAudio represents the speech synthesised for a text. Once uploaded to Telegram, the audio is sent again by its
+// file ID rather than uploaded again.
+type CachedAudio struct {
+	Audio      []byte    `json:"audio"`
+	FileID     string    `json:"file_id,omitempty"`
+	LastPlayed time.Time `json:"last_played"`
+}
+
+// AudioCacheManager defines operations to be fulfilled by the implementation that has capability to store the
+// synthesised speech.
+type AudioCacheManager interface {
+	Audio(text string, now time.Time) (*CachedAudio, error)
+	SaveAudio(text string, audio CachedAudio) error
+	AudioSize() (int, int, error)
+	PurgeAudio() (int, error)
+}
+
+// AudioCache stores the speech synthesised for the texts, evicting the least recently played audio once the cache
+// grows beyond its maximum size.
+type AudioCache struct {
+	bucket  []byte
+	db      *bbolt.DB
+	maxSize int
+}
+
+// NewAudioCache creates a new instance of AudioCache
+func NewAudioCache(db *bbolt.DB, bucket string, maxSize int) AudioCache {
+	return AudioCache{db: db, bucket: []byte(bucket), maxSize: maxSize}
+}
+
+// Audio returns the cached speech of the text and records that it is played again, nil when it is not cached.
+// This function returns the following errors:
+//  - ErrDatabaseError
+func (cache AudioCache) Audio(text string, now time.Time) (*CachedAudio, error) {
+	var audio *CachedAudio
+
+	err := cache.db.Update(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket(cache.bucket)
+		data := bucket.Get([]byte(text))
+		if data == nil {
+			return nil
+		}
+
+		audio = &CachedAudio{}
+		err := json.Unmarshal(data, audio)
+		if err != nil {
+			return err
+		}
+
+		audio.LastPlayed = now
+		data, err = json.Marshal(audio)
+		if err != nil {
+			return err
+		}
+
+		return bucket.Put([]byte(text), data)
+	})
+	if err != nil {
+		log.Printf("Failed to read cached audio. %s.\n", err)
+		return nil, ErrDatabaseError
+	}
+
+	return audio, nil
+}
+
+// SaveAudio caches the speech of the text, then evicts the least recently played audio until the cache fits its
+// maximum size again.
+// This function returns the following errors:
+//  - ErrDatabaseError
+func (cache AudioCache) SaveAudio(text string, audio CachedAudio) error {
+	data, err := json.Marshal(audio)
+	if err != nil {
+		log.Printf("Failed to encode audio. %s.\n", err)
+		return ErrDatabaseError
+	}
+
+	err = cache.db.Update(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket(cache.bucket)
+		err := bucket.Put([]byte(text), data)
+		if err != nil {
+			return err
+		}
+
+		type cached struct {
+			key        []byte
+			size       int
+			lastPlayed time.Time
+		}
+
+		var entries []cached
+		size := 0
+		err = bucket.ForEach(func(k, v []byte) error {
+			var audio CachedAudio
+			err := json.Unmarshal(v, &audio)
+			if err != nil {
+				return err
+			}
+
+			entries = append(entries, cached{key: append([]byte(nil), k...), size: len(audio.Audio),
+				lastPlayed: audio.LastPlayed})
+			size += len(audio.Audio)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		sort.Slice(entries, func(i, j int) bool {
+			return entries[i].lastPlayed.Before(entries[j].lastPlayed)
+		})
+
+		for i := 0; size > cache.maxSize && i < len(entries); i++ {
+			err = bucket.Delete(entries[i].key)
+			if err != nil {
+				return err
+			}
+
+			size -= entries[i].size
+		}
+
+		return nil
+	})
+	if err != nil {
+		log.Printf("Failed to save audio. %s.\n", err)
+		return ErrDatabaseError
+	}
+
+	return nil
+}
+
+// AudioSize returns the number of cached texts and the size in bytes of their audio.
+// This function returns the following errors:
+//  - ErrDatabaseError
+func (cache AudioCache) AudioSize() (int, int, error) {
+	count := 0
+	size := 0
+
+	err := cache.db.View(func(tx *bbolt.Tx) error {
+		return tx.Bucket(cache.bucket).ForEach(func(_, v []byte) error {
+			var audio CachedAudio
+			err := json.Unmarshal(v, &audio)
+			if err != nil {
+				return err
+			}
+
+			count++
+			size += len(audio.Audio)
+			return nil
+		})
+	})
+	if err != nil {
+		log.Printf("Failed to read audio cache size. %s.\n", err)
+		return 0, 0, ErrDatabaseError
+	}
+
+	return count, size, nil
+}
+
+// PurgeAudio removes all the cached audio and returns how many texts were removed.
+// This function returns the following errors:
+//  - ErrDatabaseError
+func (cache AudioCache) PurgeAudio() (int, error) {
+	removed := 0
+
+	err := cache.db.Update(func(tx *bbolt.Tx) error {
+		removed = tx.Bucket(cache.bucket).Stats().KeyN
+
+		err := tx.DeleteBucket(cache.bucket)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.CreateBucket(cache.bucket)
+		return err
+	})
+	if err != nil {
+		log.Printf("Failed to purge audio cache. %s.\n", err)
+		return 0, ErrDatabaseError
+	}
+
+	return removed, nil
+}
@@ -0,0 +1,95 @@
+package telegram
+
+import (
+	"go.etcd.io/bbolt"
+	"log"
+	"strconv"
+	"time"
+)
+
+// Banlist defines operations to be fulfilled by the implementation that has capability to block the abusive chats
+// from using the bot.
+type Banlist interface {
+	Ban(chatID int64) error
+	Unban(chatID int64) error
+	IsBanned(chatID int64) bool
+	Banned() (int, error)
+}
+
+// BanStore stores the banned chat IDs with the time they were banned. It is shared by all the users.
+type BanStore struct {
+	bucket []byte
+	db     *bbolt.DB
+}
+
+// NewBanStore creates a new instance of BanStore
+func NewBanStore(db *bbolt.DB, bucket string) BanStore {
+	return BanStore{db: db, bucket: []byte(bucket)}
+}
+
+// Ban blocks the chat identified by the chat ID, banning it again keeps the time of the first ban.
+// This function returns the following errors:
+//  - ErrDatabaseError
+func (store BanStore) Ban(chatID int64) error {
+	key := []byte(strconv.FormatInt(chatID, 10))
+	err := store.db.Update(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket(store.bucket)
+		if bucket.Get(key) != nil {
+			return nil
+		}
+
+		return bucket.Put(key, []byte(time.Now().UTC().Format(time.RFC3339)))
+	})
+	if err != nil {
+		log.Printf("Failed to ban chat. %s.\n", err)
+		return ErrDatabaseError
+	}
+
+	return nil
+}
+
+// Unban lets the chat identified by the chat ID use the bot again.
+// This function returns the following errors:
+//  - ErrDatabaseError
+func (store BanStore) Unban(chatID int64) error {
+	err := store.db.Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket(store.bucket).Delete([]byte(strconv.FormatInt(chatID, 10)))
+	})
+	if err != nil {
+		log.Printf("Failed to unban chat. %s.\n", err)
+		return ErrDatabaseError
+	}
+
+	return nil
+}
+
+// IsBanned reports whether the chat identified by the chat ID is banned. A chat is not banned when the database fails.
+func (store BanStore) IsBanned(chatID int64) bool {
+	banned := false
+	err := store.db.View(func(tx *bbolt.Tx) error {
+		banned = tx.Bucket(store.bucket).Get([]byte(strconv.FormatInt(chatID, 10))) != nil
+		return nil
+	})
+	if err != nil {
+		log.Printf("Failed to read ban. %s.\n", err)
+	}
+
+	return banned
+}
+
+// Banned returns the number of banned chats.
+// This function returns the following errors:
+//  - ErrDatabaseError
+func (store BanStore) Banned() (int, error) {
+	count := 0
+	err := store.db.View(func(tx *bbolt.Tx) error {
+		count = tx.Bucket(store.bucket).Stats().KeyN
+		return nil
+	})
+	if err != nil {
+		log.Printf("Failed to count bans. %s.\n", err)
+		return 0, ErrDatabaseError
+	}
+
+	return count, nil
+}
@@ -0,0 +1,240 @@
+package telegram
+
+import (
+	"bytes"
+	"encoding/json"
+	"go.etcd.io/bbolt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+// CacheManager defines operations to be fulfilled by the implementation that has capability to inspect and invalidate
+// the cached responses.
+type CacheManager interface {
+	Stats() ([]CacheStats, error)
+	Invalidate(namespace string) (int, error)
+}
+
+type cachedResponse struct {
+	Value     json.RawMessage `json:"value"`
+	ExpiresAt time.Time       `json:"expires_at"`
+}
+
+// CacheStats represents the usage of a cache namespace. The hits and misses are counted since the bot started.
+type CacheStats struct {
+	Namespace string
+	Entries   int
+	Expired   int
+	Hits      int
+	Misses    int
+}
+
+// ResponseCache stores the responses of the external providers so that the same requests are not paid for twice.
+type ResponseCache struct {
+	bucket []byte
+	db     *bbolt.DB
+
+	mutex  sync.Mutex
+	hits   map[string]int
+	misses map[string]int
+}
+
+// NewResponseCache creates a new instance of ResponseCache
+func NewResponseCache(db *bbolt.DB, bucket string) *ResponseCache {
+	return &ResponseCache{db: db, bucket: []byte(bucket), hits: map[string]int{}, misses: map[string]int{}}
+}
+
+func cacheKey(namespace string, key string) []byte {
+	return []byte(namespace + "\x00" + key)
+}
+
+func (cache *ResponseCache) count(namespace string, hit bool) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	if hit {
+		cache.hits[namespace]++
+	} else {
+		cache.misses[namespace]++
+	}
+}
+
+// Get decodes the cached value of the key into value. It reports false when the key is not cached or has expired.
+// Expired values are removed from the database.
+// This function returns the following errors:
+//  - ErrDatabaseError
+func (cache *ResponseCache) Get(namespace string, key string, value interface{}) (bool, error) {
+	var response cachedResponse
+	found := false
+
+	err := cache.db.Update(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket(cache.bucket)
+		data := bucket.Get(cacheKey(namespace, key))
+		if data == nil {
+			return nil
+		}
+
+		err := json.Unmarshal(data, &response)
+		if err != nil {
+			return err
+		}
+
+		if time.Now().After(response.ExpiresAt) {
+			// This response is stale, let's clean it up.
+			return bucket.Delete(cacheKey(namespace, key))
+		}
+
+		found = true
+		return nil
+	})
+	if err != nil {
+		log.Printf("Failed to read cached response. %s.\n", err)
+		return false, ErrDatabaseError
+	}
+
+	if found {
+		err = json.Unmarshal(response.Value, value)
+		if err != nil {
+			log.Printf("Failed to decode cached response. %s.\n", err)
+			return false, ErrDatabaseError
+		}
+	}
+
+	cache.count(namespace, found)
+	return found, nil
+}
+
+// Put caches the value of the key until the given duration passes.
+// This function returns the following errors:
+//  - ErrDatabaseError
+func (cache *ResponseCache) Put(namespace string, key string, value interface{}, ttl time.Duration) error {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		log.Printf("Failed to encode response. %s.\n", err)
+		return ErrDatabaseError
+	}
+
+	data, err := json.Marshal(cachedResponse{Value: encoded, ExpiresAt: time.Now().Add(ttl)})
+	if err != nil {
+		log.Printf("Failed to encode cached response. %s.\n", err)
+		return ErrDatabaseError
+	}
+
+	err = cache.db.Update(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket(cache.bucket)
+		return bucket.Put(cacheKey(namespace, key), data)
+	})
+	if err != nil {
+		log.Printf("Failed to save cached response. %s.\n", err)
+		return ErrDatabaseError
+	}
+
+	return nil
+}
+
+// Stats returns the usage of each namespace having cached values or requests, sorted by namespace.
+// This function returns the following errors:
+//  - ErrDatabaseError
+func (cache *ResponseCache) Stats() ([]CacheStats, error) {
+	stats := map[string]*CacheStats{}
+	get := func(namespace string) *CacheStats {
+		if _, ok := stats[namespace]; !ok {
+			stats[namespace] = &CacheStats{Namespace: namespace}
+		}
+
+		return stats[namespace]
+	}
+
+	now := time.Now()
+	err := cache.db.View(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket(cache.bucket)
+		return bucket.ForEach(func(k, v []byte) error {
+			separator := bytes.IndexByte(k, 0)
+			if separator == -1 {
+				return nil
+			}
+
+			var response cachedResponse
+			err := json.Unmarshal(v, &response)
+			if err != nil {
+				return err
+			}
+
+			namespaceStats := get(string(k[:separator]))
+			namespaceStats.Entries++
+			if now.After(response.ExpiresAt) {
+				namespaceStats.Expired++
+			}
+
+			return nil
+		})
+	})
+	if err != nil {
+		log.Printf("Failed to read cache stats. %s.\n", err)
+		return nil, ErrDatabaseError
+	}
+
+	cache.mutex.Lock()
+	for namespace, hits := range cache.hits {
+		get(namespace).Hits = hits
+	}
+
+	for namespace, misses := range cache.misses {
+		get(namespace).Misses = misses
+	}
+	cache.mutex.Unlock()
+
+	result := make([]CacheStats, 0, len(stats))
+	for _, namespaceStats := range stats {
+		result = append(result, *namespaceStats)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Namespace < result[j].Namespace
+	})
+
+	return result, nil
+}
+
+// Invalidate removes the cached values of the namespace, or of every namespace when it is empty, and returns how many
+// values were removed.
+// This function returns the following errors:
+//  - ErrDatabaseError
+func (cache *ResponseCache) Invalidate(namespace string) (int, error) {
+	removed := 0
+	prefix := []byte(namespace + "\x00")
+
+	err := cache.db.Update(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket(cache.bucket)
+
+		var keys [][]byte
+		err := bucket.ForEach(func(k, _ []byte) error {
+			if len(namespace) == 0 || bytes.HasPrefix(k, prefix) {
+				keys = append(keys, append([]byte(nil), k...))
+			}
+
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, key := range keys {
+			err = bucket.Delete(key)
+			if err != nil {
+				return err
+			}
+		}
+
+		removed = len(keys)
+		return nil
+	})
+	if err != nil {
+		log.Printf("Failed to invalidate cache. %s.\n", err)
+		return 0, ErrDatabaseError
+	}
+
+	return removed, nil
+}
@@ -0,0 +1,220 @@
+package telegram
+
+import (
+	"encoding/json"
+	"go.etcd.io/bbolt"
+	"log"
+	"time"
+)
+
+// Channel represents the Telegram channel the word of the day is published to, together with the curated deck the
+// words come from.
+type Channel struct {
+	// Chat is the @username or the numeric ID of the channel.
+	Chat string `json:"chat"`
+	// OwnerID is the chat ID of the admin owning the deck.
+	OwnerID int64 `json:"owner_id"`
+	// Deck is the name of the deck of the owner, empty for all the words of the owner.
+	Deck string `json:"deck,omitempty"`
+	// Approval holds back the words of the day until an admin approves them.
+	Approval bool `json:"approval,omitempty"`
+}
+
+// Post represents a word of the day published to the channel.
+type Post struct {
+	Day         string    `json:"day"`
+	Word        string    `json:"word"`
+	Translation string    `json:"translation"`
+	Example     string    `json:"example,omitempty"`
+	PublishedAt time.Time `json:"published_at"`
+}
+
+// Draft represents an upcoming word of the day waiting in the content queue, which the admins can edit or approve
+// before it is published.
+type Draft struct {
+	Word        string `json:"word"`
+	Translation string `json:"translation"`
+	Example     string `json:"example,omitempty"`
+	Approved    bool   `json:"approved,omitempty"`
+}
+
+// ChannelManager defines operations to be fulfilled by the implementation that has capability to manage the channel
+// publishing the word of the day.
+type ChannelManager interface {
+	Channel() (*Channel, error)
+	SaveChannel(channel *Channel) error
+	AddPost(post Post) error
+	Posts(limit int) ([]Post, error)
+	Queue() ([]Draft, error)
+	SaveQueue(queue []Draft) error
+}
+
+// channelKey is the key of the channel configuration, the posts are keyed by postPrefix and their day.
+const channelKey = "channel"
+const postPrefix = "post/"
+
+// queueKey is the key of the content queue in the content bucket.
+const queueKey = "queue"
+
+// ChannelStore stores the channel publishing the word of the day and its posts, and the upcoming posts in the content
+// queue.
+type ChannelStore struct {
+	bucket        []byte
+	contentBucket []byte
+	db            *bbolt.DB
+}
+
+// NewChannelStore creates a new instance of ChannelStore
+func NewChannelStore(db *bbolt.DB, bucket string, contentBucket string) ChannelStore {
+	return ChannelStore{db: db, bucket: []byte(bucket), contentBucket: []byte(contentBucket)}
+}
+
+// Channel returns the channel publishing the word of the day, nil when none is configured.
+// This function returns the following errors:
+//  - ErrDatabaseError
+func (store ChannelStore) Channel() (*Channel, error) {
+	var channel *Channel
+
+	err := store.db.View(func(tx *bbolt.Tx) error {
+		data := tx.Bucket(store.bucket).Get([]byte(channelKey))
+		if data == nil {
+			return nil
+		}
+
+		channel = &Channel{}
+		return json.Unmarshal(data, channel)
+	})
+	if err != nil {
+		log.Printf("Failed to read channel. %s.\n", err)
+		return nil, ErrDatabaseError
+	}
+
+	return channel, nil
+}
+
+// SaveChannel configures the channel publishing the word of the day, nil stops the publishing.
+// This function returns the following errors:
+//  - ErrDatabaseError
+func (store ChannelStore) SaveChannel(channel *Channel) error {
+	err := store.db.Update(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket(store.bucket)
+		if channel == nil {
+			return bucket.Delete([]byte(channelKey))
+		}
+
+		data, err := json.Marshal(channel)
+		if err != nil {
+			return err
+		}
+
+		return bucket.Put([]byte(channelKey), data)
+	})
+	if err != nil {
+		log.Printf("Failed to save channel. %s.\n", err)
+		return ErrDatabaseError
+	}
+
+	return nil
+}
+
+// AddPost records the word of the day published to the channel, replacing the post of the same day.
+// This function returns the following errors:
+//  - ErrDatabaseError
+func (store ChannelStore) AddPost(post Post) error {
+	err := store.db.Update(func(tx *bbolt.Tx) error {
+		data, err := json.Marshal(post)
+		if err != nil {
+			return err
+		}
+
+		return tx.Bucket(store.bucket).Put([]byte(postPrefix+post.Day), data)
+	})
+	if err != nil {
+		log.Printf("Failed to save post. %s.\n", err)
+		return ErrDatabaseError
+	}
+
+	return nil
+}
+
+// Posts returns the latest posts published to the channel, newest first. A limit of zero returns all of them.
+// This function returns the following errors:
+//  - ErrDatabaseError
+func (store ChannelStore) Posts(limit int) ([]Post, error) {
+	posts := make([]Post, 0)
+
+	err := store.db.View(func(tx *bbolt.Tx) error {
+		// The days sort in order, hence, let's walk the posts backwards from the last one.
+		cursor := tx.Bucket(store.bucket).Cursor()
+		key, value := cursor.Seek([]byte(postPrefix + "\xff"))
+		if key == nil {
+			key, value = cursor.Last()
+		} else {
+			key, value = cursor.Prev()
+		}
+
+		for ; key != nil && (limit == 0 || len(posts) < limit); key, value = cursor.Prev() {
+			if len(key) < len(postPrefix) || string(key[:len(postPrefix)]) != postPrefix {
+				break
+			}
+
+			var post Post
+			err := json.Unmarshal(value, &post)
+			if err != nil {
+				return err
+			}
+
+			posts = append(posts, post)
+		}
+
+		return nil
+	})
+	if err != nil {
+		log.Printf("Failed to list posts. %s.\n", err)
+		return nil, ErrDatabaseError
+	}
+
+	return posts, nil
+}
+
+// Queue returns the upcoming posts in the order they are published.
+// This function returns the following errors:
+//  - ErrDatabaseError
+func (store ChannelStore) Queue() ([]Draft, error) {
+	queue := make([]Draft, 0)
+
+	err := store.db.View(func(tx *bbolt.Tx) error {
+		data := tx.Bucket(store.contentBucket).Get([]byte(queueKey))
+		if data == nil {
+			return nil
+		}
+
+		return json.Unmarshal(data, &queue)
+	})
+	if err != nil {
+		log.Printf("Failed to read content queue. %s.\n", err)
+		return nil, ErrDatabaseError
+	}
+
+	return queue, nil
+}
+
+// SaveQueue replaces the upcoming posts.
+// This function returns the following errors:
+//  - ErrDatabaseError
+func (store ChannelStore) SaveQueue(queue []Draft) error {
+	err := store.db.Update(func(tx *bbolt.Tx) error {
+		data, err := json.Marshal(queue)
+		if err != nil {
+			return err
+		}
+
+		return tx.Bucket(store.contentBucket).Put([]byte(queueKey), data)
+	})
+	if err != nil {
+		log.Printf("Failed to save content queue. %s.\n", err)
+		return ErrDatabaseError
+	}
+
+	return nil
+}
@@ -0,0 +1,117 @@
+package telegram
+
+import (
+	"encoding/json"
+	"fmt"
+	"go.etcd.io/bbolt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Deck represents a named group of words owned by a user together with the attribution of its origin.
+type Deck struct {
+	Name       string    `json:"name"`
+	Title      string    `json:"title,omitempty"`
+	Author     string    `json:"author,omitempty"`
+	Provider   string    `json:"provider,omitempty"`
+	SourceURL  string    `json:"source_url,omitempty"`
+	ImportedAt time.Time `json:"imported_at"`
+	// WordCount is the number of words in the deck, counted by storage.DeckCounter, which knows where they are stored.
+	WordCount int `json:"-"`
+}
+
+// DeckManager defines operations to be fulfilled by the implementation that has capability to manage decks.
+type DeckManager interface {
+	CreateDeck(chatID int64, deck Deck) (string, error)
+	Decks(chatID int64) ([]Deck, error)
+}
+
+// DeckStore stores the decks of the users. It does not count their words, see storage.DeckCounter.
+type DeckStore struct {
+	deckBucket []byte
+	shards     Shards
+	journal    *Journal
+}
+
+// NewDeckStore creates a new instance of DeckStore
+func NewDeckStore(shards Shards, deckBucket string, journal *Journal) DeckStore {
+	return DeckStore{shards: shards, journal: journal, deckBucket: []byte(deckBucket)}
+}
+
+// CreateDeck saves a new deck for the user identified by the chat ID. When a deck with the same name already exists,
+// a number is appended to the name to keep it unique. The name eventually used is returned.
+// This function returns the following errors:
+//  - ErrDatabaseError
+func (store DeckStore) CreateDeck(chatID int64, deck Deck) (string, error) {
+	baseName := strings.TrimSpace(deck.Name)
+	if len(baseName) == 0 {
+		baseName = "Imported"
+	}
+
+	var record JournalRecord
+
+	err := store.shards.For(chatID).Update(func(tx *bbolt.Tx) error {
+		bucket, err := CreateUserBucket(tx.Bucket(store.deckBucket), chatID)
+		if err != nil {
+			return err
+		}
+
+		deck.Name = baseName
+		for i := 2; bucket.Get([]byte(deck.Name)) != nil; i++ {
+			deck.Name = fmt.Sprintf("%s (%d)", baseName, i)
+		}
+
+		value, err := json.Marshal(deck)
+		if err != nil {
+			return err
+		}
+
+		record = PutUserRecord(chatID, store.deckBucket, []byte(deck.Name), value)
+		return bucket.Put([]byte(deck.Name), value)
+	})
+	if err != nil {
+		log.Printf("Failed to create deck. %s.", err)
+		return "", ErrDatabaseError
+	}
+
+	store.journal.Append(record)
+
+	return deck.Name, nil
+}
+
+// Decks lists the decks owned by the user identified by the chat ID, sorted by name.
+// This function returns the following errors:
+//  - ErrDatabaseError
+func (store DeckStore) Decks(chatID int64) ([]Deck, error) {
+	decks := make([]Deck, 0)
+
+	err := store.shards.For(chatID).View(func(tx *bbolt.Tx) error {
+		bucket := UserBucket(tx.Bucket(store.deckBucket), chatID)
+		if bucket == nil {
+			return nil
+		}
+
+		return bucket.ForEach(func(_, value []byte) error {
+			var deck Deck
+			err := json.Unmarshal(value, &deck)
+			if err != nil {
+				return err
+			}
+
+			decks = append(decks, deck)
+			return nil
+		})
+	})
+	if err != nil {
+		log.Printf("Failed to list decks. %s.", err)
+		return nil, ErrDatabaseError
+	}
+
+	sort.Slice(decks, func(i, j int) bool {
+		return decks[i].Name < decks[j].Name
+	})
+
+	return decks, nil
+}
@@ -0,0 +1,112 @@
+package telegram
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"go.etcd.io/bbolt"
+	"log"
+	"time"
+)
+
+// ErrLinkNotFound indicates that the export link does not exist or has expired.
+var ErrLinkNotFound = errors.New("link not found or expired")
+
+// ExportLinker defines operations to be fulfilled by the implementation that has capability to publish temporary
+// export links.
+type ExportLinker interface {
+	CreateLink(chatID int64, ttl time.Duration) (string, error)
+	ResolveLink(token string) (int64, error)
+}
+
+type exportLink struct {
+	ChatID    int64     `json:"chat_id"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// ExportLinkStore stores the tokens of the temporary export links.
+type ExportLinkStore struct {
+	bucket []byte
+	db     *bbolt.DB
+}
+
+// NewExportLinkStore creates a new instance of ExportLinkStore
+func NewExportLinkStore(db *bbolt.DB, bucket string) ExportLinkStore {
+	return ExportLinkStore{db: db, bucket: []byte(bucket)}
+}
+
+// CreateLink creates a new random token that gives access to the words of the user identified by the chat ID until
+// the given duration passes.
+// This function returns the following errors:
+//  - ErrDatabaseError
+func (store ExportLinkStore) CreateLink(chatID int64, ttl time.Duration) (string, error) {
+	random := make([]byte, 16)
+	_, err := rand.Read(random)
+	if err != nil {
+		log.Printf("Failed to generate export token. %s.\n", err)
+		return "", ErrDatabaseError
+	}
+
+	token := hex.EncodeToString(random)
+	value, err := json.Marshal(exportLink{ChatID: chatID, ExpiresAt: time.Now().Add(ttl)})
+	if err != nil {
+		log.Printf("Failed to encode export link. %s.\n", err)
+		return "", ErrDatabaseError
+	}
+
+	err = store.db.Update(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket(store.bucket)
+		return bucket.Put([]byte(token), value)
+	})
+	if err != nil {
+		log.Printf("Failed to save export link. %s.\n", err)
+		return "", ErrDatabaseError
+	}
+
+	return token, nil
+}
+
+// ResolveLink returns the chat ID owning the given token. Expired tokens are removed from the database.
+// This function returns the following errors:
+//  - ErrLinkNotFound
+//  - ErrDatabaseError
+func (store ExportLinkStore) ResolveLink(token string) (int64, error) {
+	var link exportLink
+	expired := false
+
+	err := store.db.Update(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket(store.bucket)
+		data := bucket.Get([]byte(token))
+		if data == nil {
+			return ErrLinkNotFound
+		}
+
+		err := json.Unmarshal(data, &link)
+		if err != nil {
+			return err
+		}
+
+		if time.Now().After(link.ExpiresAt) {
+			// This link is no longer valid, let's clean it up. The transaction is committed for the deletion to stick.
+			expired = true
+			return bucket.Delete([]byte(token))
+		}
+
+		return nil
+	})
+	if err != nil {
+		if err == ErrLinkNotFound {
+			return 0, ErrLinkNotFound
+		}
+
+		log.Printf("Failed to resolve export link. %s.\n", err)
+		return 0, ErrDatabaseError
+	}
+
+	if expired {
+		return 0, ErrLinkNotFound
+	}
+
+	return link.ChatID, nil
+}
@@ -0,0 +1,60 @@
+package telegram
+
+import (
+	"go.etcd.io/bbolt"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestResolveExpiredLink(t *testing.T) {
+	db, err := bbolt.Open(filepath.Join(t.TempDir(), "kquiz.db"), 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	const bucketName = "export"
+	err = db.Update(func(tx *bbolt.Tx) error {
+		_, err := tx.CreateBucket([]byte(bucketName))
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store := NewExportLinkStore(db, bucketName)
+	live, err := store.CreateLink(12345, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if chatID, err := store.ResolveLink(live); err != nil || chatID != 12345 {
+		t.Fatalf("ResolveLink(live) = %d, %v, want 12345, nil", chatID, err)
+	}
+
+	expired, err := store.CreateLink(12345, -time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if chatID, err := store.ResolveLink(expired); err != ErrLinkNotFound {
+		t.Fatalf("ResolveLink(expired) = %d, %v, want ErrLinkNotFound", chatID, err)
+	}
+
+	// The expired link is gone from the bucket, the live one stays.
+	err = db.View(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket([]byte(bucketName))
+		if bucket.Get([]byte(expired)) != nil {
+			t.Fatalf("the expired link %s is still stored", expired)
+		}
+		if bucket.Get([]byte(live)) == nil {
+			t.Fatalf("the live link %s was removed", live)
+		}
+
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,173 @@
+package telegram
+
+import (
+	"bufio"
+	"encoding/json"
+	"go.etcd.io/bbolt"
+	"io"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// Operations of the journal records.
+const (
+	OpPut    = "put"
+	OpDelete = "delete"
+)
+
+// JournalRecord represents a change to a key owned by the user identified by the chat ID. Nested records change a key
+// of the bucket of the user nested in the bucket.
+type JournalRecord struct {
+	At     time.Time `json:"at"`
+	ChatID int64     `json:"chat_id"`
+	Op     string    `json:"op"`
+	Bucket string    `json:"bucket"`
+	Nested bool      `json:"nested,omitempty"`
+	Key    string    `json:"key"`
+	Value  string    `json:"value,omitempty"`
+}
+
+// Journal appends the changes to the data of the users to a JSON lines file, so that they can be replayed onto a
+// restored backup. The relation index is derived from the words and is not journaled, it is rebuilt after a replay.
+// A nil journal records nothing.
+type Journal struct {
+	mutex sync.Mutex
+	file  *os.File
+}
+
+// OpenJournal opens the journal file for appending, creating it if needed.
+// This function returns the following errors:
+//  - ErrDatabaseError
+func OpenJournal(path string) (*Journal, error) {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		log.Printf("Failed to open journal. %s.\n", err)
+		return nil, ErrDatabaseError
+	}
+
+	return &Journal{file: file}, nil
+}
+
+// PutRecord returns the record of a key set to the value.
+func PutRecord(chatID int64, bucket []byte, key []byte, value []byte) JournalRecord {
+	return JournalRecord{ChatID: chatID, Op: OpPut, Bucket: string(bucket), Key: string(key), Value: string(value)}
+}
+
+// DeleteRecord returns the record of a deleted key.
+func DeleteRecord(chatID int64, bucket []byte, key []byte) JournalRecord {
+	return JournalRecord{ChatID: chatID, Op: OpDelete, Bucket: string(bucket), Key: string(key)}
+}
+
+// PutUserRecord returns the record of a key of the nested bucket of the user set to the value.
+func PutUserRecord(chatID int64, bucket []byte, key []byte, value []byte) JournalRecord {
+	record := PutRecord(chatID, bucket, key, value)
+	record.Nested = true
+	return record
+}
+
+// DeleteUserRecord returns the record of a deleted key of the nested bucket of the user.
+func DeleteUserRecord(chatID int64, bucket []byte, key []byte) JournalRecord {
+	record := DeleteRecord(chatID, bucket, key)
+	record.Nested = true
+	return record
+}
+
+// Append writes the records of a committed transaction. The records are written after the commit so that the journal
+// never holds changes that were rolled back, a failure is logged rather than failing the already committed change.
+func (journal *Journal) Append(records ...JournalRecord) {
+	if journal == nil || len(records) == 0 {
+		return
+	}
+
+	journal.mutex.Lock()
+	defer journal.mutex.Unlock()
+
+	now := time.Now()
+	for _, record := range records {
+		record.At = now
+
+		data, err := json.Marshal(record)
+		if err != nil {
+			log.Printf("Failed to encode journal record. %s.\n", err)
+			continue
+		}
+
+		_, err = journal.file.Write(append(data, '\n'))
+		if err != nil {
+			log.Printf("Failed to write journal record. %s.\n", err)
+			return
+		}
+	}
+
+	err := journal.file.Sync()
+	if err != nil {
+		log.Printf("Failed to sync journal. %s.\n", err)
+	}
+}
+
+// Close closes the journal file.
+func (journal *Journal) Close() error {
+	if journal == nil {
+		return nil
+	}
+
+	return journal.file.Close()
+}
+
+// Replay applies the records read from the journal made at or after the given time onto the shards, in order, and
+// returns how many were applied. Replaying a record already contained in the backup is harmless.
+// This function returns the following errors:
+//  - ErrDatabaseError
+func Replay(shards Shards, r io.Reader, since time.Time) (int, error) {
+	applied := 0
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var record JournalRecord
+		err := json.Unmarshal(scanner.Bytes(), &record)
+		if err != nil {
+			log.Printf("Failed to decode journal record %d. %s.\n", applied+1, err)
+			return applied, ErrDatabaseError
+		}
+
+		if record.At.Before(since) {
+			continue
+		}
+
+		err = shards.For(record.ChatID).Update(func(tx *bbolt.Tx) error {
+			bucket, err := tx.CreateBucketIfNotExists([]byte(record.Bucket))
+			if err != nil {
+				return err
+			}
+
+			if record.Nested {
+				bucket, err = CreateUserBucket(bucket, record.ChatID)
+				if err != nil {
+					return err
+				}
+			}
+
+			if record.Op == OpDelete {
+				return bucket.Delete([]byte(record.Key))
+			}
+
+			return bucket.Put([]byte(record.Key), []byte(record.Value))
+		})
+		if err != nil {
+			log.Printf("Failed to replay journal record. %s.\n", err)
+			return applied, ErrDatabaseError
+		}
+
+		applied++
+	}
+
+	if err := scanner.Err(); err != nil {
+		log.Printf("Failed to read journal. %s.\n", err)
+		return applied, ErrDatabaseError
+	}
+
+	return applied, nil
+}
@@ -0,0 +1,158 @@
+package telegram
+
+import (
+	"bytes"
+	"go.etcd.io/bbolt"
+	"log"
+	"strconv"
+)
+
+// The words, the decks and the relation index of a user are stored in a bucket nested in their bucket, named after the
+// decimal chat ID of the user, e.g. the word 학교 of the user 12345 is the key 학교 of the bucket 12345 of the kquiz
+// bucket. Listing or clearing the items of a user only visits their own bucket.
+//
+// The other items owned by a user, e.g. the settings, are stored under the decimal chat ID followed by the name of the
+// item. There is no separator, so the prefix of a user is also the prefix of the users whose chat ID starts with the
+// same digits. The readers must make sure the key is the one of the user they expect. The collections were stored the
+// same way before, NestUserKeys moves them into the nested buckets.
+
+// UserPrefix returns the prefix of the database keys of the items owned by the user identified by the chat ID, which
+// is also the name of their nested bucket.
+func UserPrefix(chatID int64) []byte {
+	return []byte(strconv.FormatInt(chatID, 10))
+}
+
+// userKey returns the database key of the item owned by the user identified by the chat ID.
+func userKey(chatID int64, name string) []byte {
+	return append(UserPrefix(chatID), name...)
+}
+
+// userKeyName returns the name of the item from its database key. It returns false when the key does not start with
+// the prefix of the user. Only the prefix is removed, the digits of the chat ID may appear in the name too.
+func userKeyName(chatID int64, key []byte) (string, bool) {
+	prefix := UserPrefix(chatID)
+	if !bytes.HasPrefix(key, prefix) {
+		return "", false
+	}
+
+	return string(key[len(prefix):]), true
+}
+
+// UserBucket returns the bucket of the user nested in the parent, nil when the user has no item in it yet.
+func UserBucket(parent *bbolt.Bucket, chatID int64) *bbolt.Bucket {
+	return parent.Bucket(UserPrefix(chatID))
+}
+
+// CreateUserBucket returns the bucket of the user nested in the parent, creating it if needed.
+func CreateUserBucket(parent *bbolt.Bucket, chatID int64) (*bbolt.Bucket, error) {
+	return parent.CreateBucketIfNotExists(UserPrefix(chatID))
+}
+
+// keyOwner returns the chat ID of the user owning the key of the earlier layout. Among the registered users, the one
+// with the longest matching chat ID owns it. The keys of the users no longer registered are owned by the digits they
+// start with, a name starting with digits is then split at the wrong place.
+func keyOwner(key []byte, users []int64) (int64, bool) {
+	owner := int64(0)
+	found := false
+	for _, chatID := range users {
+		if _, ok := userKeyName(chatID, key); ok && (!found || len(UserPrefix(chatID)) > len(UserPrefix(owner))) {
+			owner = chatID
+			found = true
+		}
+	}
+
+	if found {
+		return owner, true
+	}
+
+	end := 0
+	if len(key) > 0 && key[0] == '-' {
+		end = 1
+	}
+	for end < len(key) && key[end] >= '0' && key[end] <= '9' {
+		end++
+	}
+
+	chatID, err := strconv.ParseInt(string(key[:end]), 10, 64)
+	return chatID, err == nil && end < len(key)
+}
+
+// NestUserKeys moves the items of the bucket stored under the chat ID prefixed keys of the earlier layout into the
+// nested bucket of their user, and returns how many were moved. The users are the registered users, see keyOwner. The
+// items already nested are left alone, so it is harmless to run it on every start, or after replaying a journal
+// written before the nested buckets.
+// This function returns the following errors:
+//  - ErrDatabaseError
+func NestUserKeys(shards Shards, bucketName string, users []int64) (int, error) {
+	moved := 0
+
+	err := shards.Update(func(tx *bbolt.Tx) error {
+		var err error
+		moved, err = NestUserKeysIn(tx, shards, bucketName, users)
+		return err
+	})
+	if err != nil {
+		log.Printf("Failed to nest the keys of bucket %s. %s.\n", bucketName, err)
+		return moved, ErrDatabaseError
+	}
+
+	return moved, nil
+}
+
+// NestUserKeysIn moves the items of the bucket of the shard of the transaction like NestUserKeys does, within the
+// transaction, and returns how many were moved.
+func NestUserKeysIn(tx *bbolt.Tx, shards Shards, bucketName string, users []int64) (int, error) {
+	parent := tx.Bucket([]byte(bucketName))
+	if parent == nil {
+		return 0, nil
+	}
+
+	local := make([]int64, 0, len(users))
+	for _, chatID := range users {
+		if shards.For(chatID) == tx.DB() {
+			local = append(local, chatID)
+		}
+	}
+
+	// Nested buckets have no value, and adding keys while iterating is not allowed.
+	var keys [][]byte
+	err := parent.ForEach(func(key, value []byte) error {
+		if value != nil {
+			keys = append(keys, append([]byte(nil), key...))
+		}
+
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	moved := 0
+	for _, key := range keys {
+		chatID, ok := keyOwner(key, local)
+		name, _ := userKeyName(chatID, key)
+		if !ok || len(name) == 0 {
+			log.Printf("Leaving key %q of bucket %s in place, its owner is unknown.\n", key, bucketName)
+			continue
+		}
+
+		bucket, err := CreateUserBucket(parent, chatID)
+		if err != nil {
+			return moved, err
+		}
+
+		err = bucket.Put([]byte(name), append([]byte(nil), parent.Get(key)...))
+		if err != nil {
+			return moved, err
+		}
+
+		err = parent.Delete(key)
+		if err != nil {
+			return moved, err
+		}
+
+		moved++
+	}
+
+	return moved, nil
+}
@@ -0,0 +1,145 @@
+package telegram
+
+import (
+	"bytes"
+	"go.etcd.io/bbolt"
+	"path/filepath"
+	"testing"
+	"unicode"
+	"unicode/utf8"
+)
+
+// keySeeds are the chat IDs and the names the key tests start from: Unicode words, digits, spaces, and names starting
+// with the digits of a chat ID.
+var keySeeds = []struct {
+	chatID int64
+	name   string
+}{
+	{12345, "학교"},
+	{12345, "2호선"},
+	{12345, "7시 30분"},
+	{12345, "12345"},
+	{12345, "45 사과"},
+	{1, "23"},
+	{-100123, "단어"},
+	{-100123, "-100123 그룹"},
+	{0, ""},
+	{9223372036854775807, "오늘 날씨"},
+	{-9223372036854775808, "🙂 기분"},
+}
+
+func FuzzUserKey(f *testing.F) {
+	for _, seed := range keySeeds {
+		f.Add(seed.chatID, seed.name)
+	}
+
+	f.Fuzz(func(t *testing.T, chatID int64, name string) {
+		key := userKey(chatID, name)
+		if !bytes.HasPrefix(key, UserPrefix(chatID)) {
+			t.Fatalf("userKey(%d, %q) = %q, want the prefix of the user", chatID, name, key)
+		}
+
+		got, ok := userKeyName(chatID, key)
+		if !ok || got != name {
+			t.Fatalf("userKeyName(%d, %q) = %q, %t, want %q, true", chatID, key, got, ok, name)
+		}
+
+		// The users whose prefix the key does not start with never read it.
+		other := chatID + 1
+		if _, ok := userKeyName(other, key); ok && !bytes.HasPrefix(key, UserPrefix(other)) {
+			t.Fatalf("userKeyName(%d, %q) accepted the key of %d", other, key, chatID)
+		}
+	})
+}
+
+func FuzzKeyOwner(f *testing.F) {
+	for _, seed := range keySeeds {
+		f.Add(seed.chatID, seed.name)
+	}
+
+	f.Fuzz(func(t *testing.T, chatID int64, name string) {
+		if len(name) == 0 {
+			return
+		}
+
+		// The registered user owns the key, even when the name starts with digits.
+		key := userKey(chatID, name)
+		owner, ok := keyOwner(key, []int64{chatID})
+		if !ok || owner != chatID {
+			t.Fatalf("keyOwner(%q, [%d]) = %d, %t, want %d, true", key, chatID, owner, ok, chatID)
+		}
+
+		// A user whose chat ID extends the one of the owner only takes the names starting with the same digit.
+		first, _ := utf8.DecodeRuneInString(name)
+		if chatID <= 0 || chatID > 1e17 || unicode.IsDigit(first) {
+			return
+		}
+
+		longer := chatID*10 + 1
+		owner, ok = keyOwner(key, []int64{longer, chatID})
+		if !ok || owner != chatID {
+			t.Fatalf("keyOwner(%q, [%d %d]) = %d, %t, want %d, true", key, longer, chatID, owner, ok, chatID)
+		}
+	})
+}
+
+func FuzzNestUserKeys(f *testing.F) {
+	for _, seed := range keySeeds {
+		f.Add(seed.chatID, seed.name, []byte("translation"))
+	}
+
+	shards, err := OpenShards(filepath.Join(f.TempDir(), "kquiz.db"), 1)
+	if err != nil {
+		f.Fatal(err)
+	}
+	defer shards.Close()
+
+	const bucketName = "kquiz"
+	f.Fuzz(func(t *testing.T, chatID int64, name string, value []byte) {
+		if len(name) == 0 || len(userKey(chatID, name)) > bbolt.MaxKeySize || len(value) == 0 {
+			return
+		}
+
+		err := shards.Update(func(tx *bbolt.Tx) error {
+			_ = tx.DeleteBucket([]byte(bucketName))
+			parent, err := tx.CreateBucket([]byte(bucketName))
+			if err != nil {
+				return err
+			}
+
+			return parent.Put(userKey(chatID, name), value)
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// The earlier layout moves into the nested bucket of the user, once.
+		for _, want := range []int{1, 0} {
+			moved, err := NestUserKeys(shards, bucketName, []int64{chatID})
+			if err != nil || moved != want {
+				t.Fatalf("NestUserKeys() = %d, %v, want %d, nil", moved, err, want)
+			}
+		}
+
+		err = shards.View(func(tx *bbolt.Tx) error {
+			parent := tx.Bucket([]byte(bucketName))
+			if got := parent.Get(userKey(chatID, name)); got != nil {
+				t.Fatalf("the key %q of the earlier layout is still there", userKey(chatID, name))
+			}
+
+			bucket := UserBucket(parent, chatID)
+			if bucket == nil {
+				t.Fatalf("no nested bucket for %d", chatID)
+			}
+
+			if got := bucket.Get([]byte(name)); !bytes.Equal(got, value) {
+				t.Fatalf("nested %q = %q, want %q", name, got, value)
+			}
+
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	})
+}
@@ -0,0 +1,227 @@
+package telegram
+
+import (
+	"encoding/json"
+	"fmt"
+	"go.etcd.io/bbolt"
+	"log"
+	"sort"
+	"time"
+)
+
+// Rankings of the leaderboard.
+const (
+	RankAccuracy = "accuracy"
+	RankStreak   = "streak"
+)
+
+// MinRankedAnswers is the number of answers a player needs to be ranked by accuracy, so that a lucky first answer does
+// not top the leaderboard.
+const MinRankedAnswers = 20
+
+// Player represents a user who opted in to the leaderboard, with the figures it is ranked by.
+type Player struct {
+	ChatID   int64  `json:"chat_id"`
+	Name     string `json:"name"`
+	Correct  int    `json:"correct"`
+	Answered int    `json:"answered"`
+	// Streak is the number of days in a row the player studied until LastDay, in the time zone Timezone.
+	Streak   int    `json:"streak"`
+	LastDay  string `json:"last_day"`
+	Timezone string `json:"timezone,omitempty"`
+}
+
+// Accuracy returns the percentage of the answers of the player that are correct, zero when there is none.
+func (player Player) Accuracy() int {
+	return Stats{Correct: player.Correct, Incorrect: player.Answered - player.Correct}.Accuracy()
+}
+
+// CurrentStreak returns the streak of the player still alive on the given day.
+func (player Player) CurrentStreak(now time.Time) int {
+	location, err := time.LoadLocation(player.Timezone)
+	if err != nil {
+		location = time.UTC
+	}
+
+	return Activity{LastDay: player.LastDay, Streak: player.Streak}.CurrentStreak(now, location)
+}
+
+// Leaderboard defines operations to be fulfilled by the implementation that has capability to rank the users who opted
+// in.
+type Leaderboard interface {
+	Join(chatID int64, name string) error
+	Leave(chatID int64) error
+	Joined(chatID int64) (bool, error)
+	UpdatePlayer(chatID int64, stats Stats, activity Activity, timezone string) error
+	Ranking(rank string, now time.Time, limit int) ([]Player, error)
+}
+
+// LeaderboardStore stores the players of the leaderboard, shared by all the users. Only the users who opted in are
+// stored, leaving removes them.
+type LeaderboardStore struct {
+	bucket []byte
+	db     *bbolt.DB
+}
+
+// NewLeaderboardStore creates a new instance of LeaderboardStore
+func NewLeaderboardStore(db *bbolt.DB, bucket string) LeaderboardStore {
+	return LeaderboardStore{db: db, bucket: []byte(bucket)}
+}
+
+// playerKey returns the key of the player identified by the chat ID.
+func playerKey(chatID int64) []byte {
+	return []byte(fmt.Sprintf("%d", chatID))
+}
+
+// Join opts the user identified by the chat ID in to the leaderboard under the name.
+// This function returns the following errors:
+//  - ErrDatabaseError
+func (store LeaderboardStore) Join(chatID int64, name string) error {
+	err := store.db.Update(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket(store.bucket)
+
+		player := Player{ChatID: chatID}
+		if data := bucket.Get(playerKey(chatID)); data != nil {
+			err := json.Unmarshal(data, &player)
+			if err != nil {
+				return err
+			}
+		}
+		player.Name = name
+
+		value, err := json.Marshal(player)
+		if err != nil {
+			return err
+		}
+
+		return bucket.Put(playerKey(chatID), value)
+	})
+	if err != nil {
+		log.Printf("Failed to join leaderboard. %s.\n", err)
+		return ErrDatabaseError
+	}
+
+	return nil
+}
+
+// Leave removes the user identified by the chat ID from the leaderboard.
+// This function returns the following errors:
+//  - ErrDatabaseError
+func (store LeaderboardStore) Leave(chatID int64) error {
+	err := store.db.Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket(store.bucket).Delete(playerKey(chatID))
+	})
+	if err != nil {
+		log.Printf("Failed to leave leaderboard. %s.\n", err)
+		return ErrDatabaseError
+	}
+
+	return nil
+}
+
+// Joined reports whether the user identified by the chat ID opted in to the leaderboard.
+// This function returns the following errors:
+//  - ErrDatabaseError
+func (store LeaderboardStore) Joined(chatID int64) (bool, error) {
+	joined := false
+
+	err := store.db.View(func(tx *bbolt.Tx) error {
+		joined = tx.Bucket(store.bucket).Get(playerKey(chatID)) != nil
+		return nil
+	})
+	if err != nil {
+		log.Printf("Failed to read leaderboard. %s.\n", err)
+		return false, ErrDatabaseError
+	}
+
+	return joined, nil
+}
+
+// UpdatePlayer updates the figures of the player identified by the chat ID, nothing is stored for the users who did not
+// opt in.
+// This function returns the following errors:
+//  - ErrDatabaseError
+func (store LeaderboardStore) UpdatePlayer(chatID int64, stats Stats, activity Activity, timezone string) error {
+	err := store.db.Update(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket(store.bucket)
+		data := bucket.Get(playerKey(chatID))
+		if data == nil {
+			return nil
+		}
+
+		var player Player
+		err := json.Unmarshal(data, &player)
+		if err != nil {
+			return err
+		}
+
+		player.Correct = stats.Correct
+		player.Answered = stats.Answered()
+		player.Streak = activity.Streak
+		player.LastDay = activity.LastDay
+		player.Timezone = timezone
+
+		value, err := json.Marshal(player)
+		if err != nil {
+			return err
+		}
+
+		return bucket.Put(playerKey(chatID), value)
+	})
+	if err != nil {
+		log.Printf("Failed to update leaderboard. %s.\n", err)
+		return ErrDatabaseError
+	}
+
+	return nil
+}
+
+// Ranking returns the best players by accuracy or by current streak, at most limit of them. The ranking by accuracy
+// leaves out the players with fewer than MinRankedAnswers answers, the ranking by streak the ones without a streak.
+// This function returns the following errors:
+//  - ErrDatabaseError
+func (store LeaderboardStore) Ranking(rank string, now time.Time, limit int) ([]Player, error) {
+	players := make([]Player, 0)
+
+	err := store.db.View(func(tx *bbolt.Tx) error {
+		return tx.Bucket(store.bucket).ForEach(func(_, value []byte) error {
+			var player Player
+			err := json.Unmarshal(value, &player)
+			if err != nil {
+				return err
+			}
+
+			if (rank == RankStreak && player.CurrentStreak(now) > 0) ||
+				(rank != RankStreak && player.Answered >= MinRankedAnswers) {
+				players = append(players, player)
+			}
+
+			return nil
+		})
+	})
+	if err != nil {
+		log.Printf("Failed to read leaderboard. %s.\n", err)
+		return nil, ErrDatabaseError
+	}
+
+	score := func(player Player) int {
+		if rank == RankStreak {
+			return player.CurrentStreak(now)
+		}
+
+		return player.Accuracy()
+	}
+	sort.SliceStable(players, func(i, j int) bool {
+		if score(players[i]) != score(players[j]) {
+			return score(players[i]) > score(players[j])
+		}
+
+		return players[i].Answered > players[j].Answered
+	})
+
+	if len(players) > limit {
+		players = players[:limit]
+	}
+
+	return players, nil
+}
@@ -0,0 +1,74 @@
+package telegram
+
+import (
+	"path"
+	"strings"
+)
+
+// The kinds of the origins of the words. The origins of the imports and the shared decks are followed by their name
+// after a colon, see NewOrigin.
+const (
+	// OriginManual is the origin of the words typed in the chat.
+	OriginManual = "manual"
+	// OriginImport is the origin of the words imported from a file, a sheet or a public set.
+	OriginImport = "import"
+	// OriginDeck is the origin of the words of a deck shared by a teacher, named by its token.
+	OriginDeck = "deck"
+	// OriginExtension is the origin of the words captured by the browser extension, their Source is the page.
+	OriginExtension = "extension"
+	// OriginAPI is the origin of the words posted by the other services, e.g. Zapier.
+	OriginAPI = "api"
+)
+
+// SourceFilter prefixes the source in the filters of ListPage, e.g. source:topik1.
+const SourceFilter = "source:"
+
+// NewOrigin returns the origin of the kind and the name, e.g. import:topik1.csv.
+func NewOrigin(kind string, name string) string {
+	return kind + ":" + name
+}
+
+// SplitOrigin returns the kind and the name of the origin, the name is empty for manual, extension and api.
+func SplitOrigin(origin string) (string, string) {
+	if idx := strings.Index(origin, ":"); idx != -1 {
+		return origin[:idx], origin[idx+1:]
+	}
+
+	return origin, ""
+}
+
+// EntryOrigin returns the origin of the entry. The words captured before the origins were recorded still have their
+// source, the others have none.
+func (entry WordEntry) EntryOrigin() string {
+	if len(entry.Origin) == 0 && len(entry.Source) != 0 {
+		return OriginExtension
+	}
+
+	return entry.Origin
+}
+
+// FromSource reports whether the entry came from the source, which is the kind of its origin or its name, with or
+// without its extension, e.g. import, topik1.csv or topik1. The case and the spaces do not matter.
+func (entry WordEntry) FromSource(source string) bool {
+	simplify := func(text string) string {
+		return strings.ToLower(strings.Join(strings.Fields(text), ""))
+	}
+
+	source = simplify(source)
+	if len(source) == 0 {
+		return false
+	}
+
+	kind, name := SplitOrigin(entry.EntryOrigin())
+	return source == simplify(kind) || source == simplify(name) ||
+		source == simplify(strings.TrimSuffix(name, path.Ext(name)))
+}
+
+// Matches reports whether the entry matches the filter of ListPage, a source following SourceFilter or else a tag.
+func (entry WordEntry) Matches(filter string) bool {
+	if strings.HasPrefix(filter, SourceFilter) {
+		return entry.FromSource(strings.TrimPrefix(filter, SourceFilter))
+	}
+
+	return entry.HasTag(filter)
+}
@@ -0,0 +1,147 @@
+package telegram
+
+import (
+	"encoding/json"
+	"go.etcd.io/bbolt"
+	"log"
+	"time"
+)
+
+// pendingRecord is the stored pending question, encoded by its owner.
+type pendingRecord struct {
+	SavedAt  time.Time       `json:"saved_at"`
+	Question json.RawMessage `json:"question"`
+}
+
+// PendingStore stores the question each user is yet to answer, so that the quizzes in flight survive a restart. The
+// questions are stored encoded as JSON, the store does not look into them. They expire after the TTL. The pending
+// questions are transient and are not journaled.
+type PendingStore struct {
+	bucket []byte
+	shards Shards
+	ttl    time.Duration
+}
+
+// NewPendingStore creates a new instance of PendingStore
+func NewPendingStore(shards Shards, bucket string, ttl time.Duration) PendingStore {
+	return PendingStore{shards: shards, bucket: []byte(bucket), ttl: ttl}
+}
+
+// SavePending saves the question the user identified by the chat ID is to answer, replacing the previous one.
+// This function returns the following errors:
+//  - ErrDatabaseError
+func (store PendingStore) SavePending(chatID int64, question interface{}) error {
+	encoded, err := json.Marshal(question)
+	if err != nil {
+		log.Printf("Failed to encode pending question. %s.\n", err)
+		return ErrDatabaseError
+	}
+
+	value, err := json.Marshal(pendingRecord{SavedAt: time.Now(), Question: encoded})
+	if err != nil {
+		log.Printf("Failed to encode pending question. %s.\n", err)
+		return ErrDatabaseError
+	}
+
+	err = store.shards.For(chatID).Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket(store.bucket).Put(userKey(chatID, ""), value)
+	})
+	if err != nil {
+		log.Printf("Failed to save pending question. %s.\n", err)
+		return ErrDatabaseError
+	}
+
+	return nil
+}
+
+// Pending decodes the question the user identified by the chat ID is to answer into the given value. It returns false
+// when there is none, or it expired.
+// This function returns the following errors:
+//  - ErrDatabaseError
+func (store PendingStore) Pending(chatID int64, question interface{}) (bool, error) {
+	var record pendingRecord
+	found := false
+
+	err := store.shards.For(chatID).View(func(tx *bbolt.Tx) error {
+		data := tx.Bucket(store.bucket).Get(userKey(chatID, ""))
+		if data == nil {
+			return nil
+		}
+
+		found = true
+		return json.Unmarshal(data, &record)
+	})
+	if err != nil {
+		log.Printf("Failed to read pending question. %s.\n", err)
+		return false, ErrDatabaseError
+	}
+
+	if !found || (store.ttl > 0 && time.Since(record.SavedAt) > store.ttl) {
+		return false, nil
+	}
+
+	err = json.Unmarshal(record.Question, question)
+	if err != nil {
+		log.Printf("Failed to decode pending question. %s.\n", err)
+		return false, ErrDatabaseError
+	}
+
+	return true, nil
+}
+
+// DeletePending deletes the question the user identified by the chat ID is to answer, if any.
+// This function returns the following errors:
+//  - ErrDatabaseError
+func (store PendingStore) DeletePending(chatID int64) error {
+	err := store.shards.For(chatID).Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket(store.bucket).Delete(userKey(chatID, ""))
+	})
+	if err != nil {
+		log.Printf("Failed to delete pending question. %s.\n", err)
+		return ErrDatabaseError
+	}
+
+	return nil
+}
+
+// PrunePending removes the questions saved before the given time, answered or not, and returns how many were removed.
+// This function returns the following errors:
+//  - ErrDatabaseError
+func (store PendingStore) PrunePending(before time.Time) (int, error) {
+	removed := 0
+
+	err := store.shards.Update(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket(store.bucket)
+
+		// Deleting keys while iterating is not allowed.
+		var expired [][]byte
+		err := bucket.ForEach(func(key, value []byte) error {
+			var record pendingRecord
+			if json.Unmarshal(value, &record) != nil || record.SavedAt.Before(before) {
+				expired = append(expired, append([]byte(nil), key...))
+			}
+
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, key := range expired {
+			err = bucket.Delete(key)
+			if err != nil {
+				return err
+			}
+
+			removed++
+		}
+
+		return nil
+	})
+	if err != nil {
+		log.Printf("Failed to prune pending questions. %s.\n", err)
+		return removed, ErrDatabaseError
+	}
+
+	return removed, nil
+}
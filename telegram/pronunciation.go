@@ -0,0 +1,99 @@
+package telegram
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go.etcd.io/bbolt"
+	"log"
+	"time"
+)
+
+// PronunciationAttempt represents a scored attempt at pronouncing a word.
+type PronunciationAttempt struct {
+	Word       string    `json:"word"`
+	Transcript string    `json:"transcript"`
+	Confidence float64   `json:"confidence"`
+	Score      int       `json:"score"`
+	At         time.Time `json:"at"`
+}
+
+// PronunciationTracker defines operations to be fulfilled by the implementation that has capability to track the
+// pronunciation attempts of the users.
+type PronunciationTracker interface {
+	RecordAttempt(chatID int64, attempt PronunciationAttempt) error
+	Attempts(chatID int64, since time.Time) ([]PronunciationAttempt, error)
+}
+
+// PronunciationStore stores the pronunciation attempts of the users.
+type PronunciationStore struct {
+	bucket  []byte
+	shards  Shards
+	journal *Journal
+}
+
+// NewPronunciationStore creates a new instance of PronunciationStore
+func NewPronunciationStore(shards Shards, bucket string, journal *Journal) PronunciationStore {
+	return PronunciationStore{shards: shards, journal: journal, bucket: []byte(bucket)}
+}
+
+// attemptKey returns the key of an attempt. The time is zero padded so that the attempts are sorted chronologically
+// and the attempts since a given time can be found with a seek.
+func attemptKey(chatID int64, at time.Time) []byte {
+	return userKey(chatID, fmt.Sprintf(":%020d", at.UnixNano()))
+}
+
+// RecordAttempt saves the pronunciation attempt of the user identified by the chat ID.
+// This function returns the following errors:
+//  - ErrDatabaseError
+func (store PronunciationStore) RecordAttempt(chatID int64, attempt PronunciationAttempt) error {
+	value, err := json.Marshal(attempt)
+	if err != nil {
+		log.Printf("Failed to encode pronunciation attempt. %s.", err)
+		return ErrDatabaseError
+	}
+
+	err = store.shards.For(chatID).Update(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket(store.bucket)
+		return bucket.Put(attemptKey(chatID, attempt.At), value)
+	})
+	if err != nil {
+		log.Printf("Failed to record pronunciation attempt. %s.", err)
+		return ErrDatabaseError
+	}
+
+	store.journal.Append(PutRecord(chatID, store.bucket, attemptKey(chatID, attempt.At), value))
+
+	return nil
+}
+
+// Attempts lists the pronunciation attempts of the user identified by the chat ID since the given time, oldest first.
+// This function returns the following errors:
+//  - ErrDatabaseError
+func (store PronunciationStore) Attempts(chatID int64, since time.Time) ([]PronunciationAttempt, error) {
+	attempts := make([]PronunciationAttempt, 0)
+	prefix := userKey(chatID, ":")
+
+	err := store.shards.For(chatID).View(func(tx *bbolt.Tx) error {
+		cursor := tx.Bucket(store.bucket).Cursor()
+
+		key, value := cursor.Seek(attemptKey(chatID, since))
+		for ; key != nil && bytes.HasPrefix(key, prefix); key, value = cursor.Next() {
+			var attempt PronunciationAttempt
+			err := json.Unmarshal(value, &attempt)
+			if err != nil {
+				return err
+			}
+
+			attempts = append(attempts, attempt)
+		}
+
+		return nil
+	})
+	if err != nil {
+		log.Printf("Failed to list pronunciation attempts. %s.", err)
+		return nil, ErrDatabaseError
+	}
+
+	return attempts, nil
+}
@@ -0,0 +1,90 @@
+package telegram
+
+import (
+	"encoding/json"
+	"go.etcd.io/bbolt"
+	"log"
+	"time"
+)
+
+// AskedWord is a word asked in a random question, with when it was asked.
+type AskedWord struct {
+	Word string    `json:"word"`
+	At   time.Time `json:"at"`
+}
+
+// RecentTracker defines operations to be fulfilled by the implementation that has capability to remember the words
+// recently asked to the users.
+type RecentTracker interface {
+	Recent(chatID int64) ([]AskedWord, error)
+	RecordAsked(chatID int64, word string, at time.Time) error
+}
+
+// RecentStore stores the words recently asked to each user in a ring buffer of the given size, the oldest words make
+// room for the new ones. The recent words are transient and are not journaled.
+type RecentStore struct {
+	bucket []byte
+	shards Shards
+	size   int
+}
+
+// NewRecentStore creates a new instance of RecentStore
+func NewRecentStore(shards Shards, bucket string, size int) RecentStore {
+	return RecentStore{shards: shards, bucket: []byte(bucket), size: size}
+}
+
+// Recent returns the words recently asked to the user identified by the chat ID, the oldest first.
+// This function returns the following errors:
+//  - ErrDatabaseError
+func (store RecentStore) Recent(chatID int64) ([]AskedWord, error) {
+	recent := make([]AskedWord, 0)
+	err := store.shards.For(chatID).View(func(tx *bbolt.Tx) error {
+		data := tx.Bucket(store.bucket).Get(userKey(chatID, ""))
+		if data == nil {
+			return nil
+		}
+
+		return json.Unmarshal(data, &recent)
+	})
+	if err != nil {
+		log.Printf("Failed to read recent words. %s.\n", err)
+		return nil, ErrDatabaseError
+	}
+
+	return recent, nil
+}
+
+// RecordAsked records the word asked to the user identified by the chat ID at the given time, dropping the oldest word
+// when the ring buffer is full.
+// This function returns the following errors:
+//  - ErrDatabaseError
+func (store RecentStore) RecordAsked(chatID int64, word string, at time.Time) error {
+	err := store.shards.For(chatID).Update(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket(store.bucket)
+		recent := make([]AskedWord, 0)
+		if data := bucket.Get(userKey(chatID, "")); data != nil {
+			err := json.Unmarshal(data, &recent)
+			if err != nil {
+				return err
+			}
+		}
+
+		recent = append(recent, AskedWord{Word: word, At: at})
+		if len(recent) > store.size {
+			recent = recent[len(recent)-store.size:]
+		}
+
+		value, err := json.Marshal(recent)
+		if err != nil {
+			return err
+		}
+
+		return bucket.Put(userKey(chatID, ""), value)
+	})
+	if err != nil {
+		log.Printf("Failed to record recent word. %s.\n", err)
+		return ErrDatabaseError
+	}
+
+	return nil
+}
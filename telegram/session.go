@@ -0,0 +1,145 @@
+package telegram
+
+import (
+	"encoding/json"
+	"go.etcd.io/bbolt"
+	"log"
+	"time"
+)
+
+// sessionRecord is the stored quiz session, encoded by its owner.
+type sessionRecord struct {
+	SavedAt time.Time       `json:"saved_at"`
+	Session json.RawMessage `json:"session"`
+}
+
+// SessionStore stores the quiz session each user is in, so that the sessions in flight survive a restart. The sessions
+// are stored encoded as JSON, the store does not look into them. The sessions are transient and are not journaled.
+type SessionStore struct {
+	bucket []byte
+	shards Shards
+}
+
+// NewSessionStore creates a new instance of SessionStore
+func NewSessionStore(shards Shards, bucket string) SessionStore {
+	return SessionStore{shards: shards, bucket: []byte(bucket)}
+}
+
+// SaveSession saves the quiz session of the user identified by the chat ID, replacing the previous one.
+// This function returns the following errors:
+//  - ErrDatabaseError
+func (store SessionStore) SaveSession(chatID int64, session interface{}) error {
+	encoded, err := json.Marshal(session)
+	if err != nil {
+		log.Printf("Failed to encode quiz session. %s.\n", err)
+		return ErrDatabaseError
+	}
+
+	value, err := json.Marshal(sessionRecord{SavedAt: time.Now(), Session: encoded})
+	if err != nil {
+		log.Printf("Failed to encode quiz session. %s.\n", err)
+		return ErrDatabaseError
+	}
+
+	err = store.shards.For(chatID).Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket(store.bucket).Put(userKey(chatID, ""), value)
+	})
+	if err != nil {
+		log.Printf("Failed to save quiz session. %s.\n", err)
+		return ErrDatabaseError
+	}
+
+	return nil
+}
+
+// Session decodes the quiz session of the user identified by the chat ID into the given value. It returns false when
+// there is none.
+// This function returns the following errors:
+//  - ErrDatabaseError
+func (store SessionStore) Session(chatID int64, session interface{}) (bool, error) {
+	var record sessionRecord
+	found := false
+
+	err := store.shards.For(chatID).View(func(tx *bbolt.Tx) error {
+		data := tx.Bucket(store.bucket).Get(userKey(chatID, ""))
+		if data == nil {
+			return nil
+		}
+
+		found = true
+		return json.Unmarshal(data, &record)
+	})
+	if err != nil {
+		log.Printf("Failed to read quiz session. %s.\n", err)
+		return false, ErrDatabaseError
+	}
+
+	if !found {
+		return false, nil
+	}
+
+	err = json.Unmarshal(record.Session, session)
+	if err != nil {
+		log.Printf("Failed to decode quiz session. %s.\n", err)
+		return false, ErrDatabaseError
+	}
+
+	return true, nil
+}
+
+// DeleteSession deletes the quiz session of the user identified by the chat ID, if any.
+// This function returns the following errors:
+//  - ErrDatabaseError
+func (store SessionStore) DeleteSession(chatID int64) error {
+	err := store.shards.For(chatID).Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket(store.bucket).Delete(userKey(chatID, ""))
+	})
+	if err != nil {
+		log.Printf("Failed to delete quiz session. %s.\n", err)
+		return ErrDatabaseError
+	}
+
+	return nil
+}
+
+// PruneSessions removes the sessions saved before the given time, over or abandoned, and returns how many were removed.
+// This function returns the following errors:
+//  - ErrDatabaseError
+func (store SessionStore) PruneSessions(before time.Time) (int, error) {
+	removed := 0
+
+	err := store.shards.Update(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket(store.bucket)
+
+		// Deleting keys while iterating is not allowed.
+		var expired [][]byte
+		err := bucket.ForEach(func(key, value []byte) error {
+			var record sessionRecord
+			if json.Unmarshal(value, &record) != nil || record.SavedAt.Before(before) {
+				expired = append(expired, append([]byte(nil), key...))
+			}
+
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, key := range expired {
+			err = bucket.Delete(key)
+			if err != nil {
+				return err
+			}
+
+			removed++
+		}
+
+		return nil
+	})
+	if err != nil {
+		log.Printf("Failed to prune quiz sessions. %s.\n", err)
+		return removed, ErrDatabaseError
+	}
+
+	return removed, nil
+}
@@ -0,0 +1,187 @@
+package telegram
+
+import (
+	"encoding/json"
+	"go.etcd.io/bbolt"
+	"log"
+	"time"
+)
+
+// Settings represents the preferences of a user. The zero value holds the defaults.
+type Settings struct {
+	// NoCombos turns off the combo multipliers and the cheering of the correct answers in a row.
+	NoCombos bool `json:"no_combos,omitempty"`
+	// Accessible uses short plain sentences without emojis in the feedback, which read better with a screen reader.
+	Accessible bool `json:"accessible,omitempty"`
+	// LargePrint sends the quiz prompts as images in large type as well, for the users who struggle to read the Hangul
+	// at the font size of their phone.
+	LargePrint bool `json:"large_print,omitempty"`
+	// ReverseQuiz asks the Korean word of the translation in the vocabulary quizzes, instead of the translation.
+	ReverseQuiz bool `json:"reverse_quiz,omitempty"`
+	// Timezone is the IANA time zone of the user, e.g. Asia/Seoul. Empty means UTC.
+	Timezone string `json:"timezone,omitempty"`
+	// MorningReview and EveningReview are the local times, formatted as 15:04, of the review pushes. Empty turns the
+	// push off.
+	MorningReview string `json:"morning_review,omitempty"`
+	EveningReview string `json:"evening_review,omitempty"`
+	// MorningReviews is the number of due words reviewed in the morning, the evening gets the remainder.
+	MorningReviews int `json:"morning_reviews,omitempty"`
+	// DailyQuiz is the local time, formatted as 15:04, of the daily quiz question. Empty turns it off.
+	DailyQuiz string `json:"daily_quiz,omitempty"`
+	// Podcast is the local time, formatted as 15:04, of the daily podcast reading the due words aloud. Empty turns it
+	// off.
+	Podcast string `json:"podcast,omitempty"`
+	// SilentPushes delivers the messages the bot sends on its own without a notification sound.
+	SilentPushes bool `json:"silent_pushes,omitempty"`
+	// QuietStart and QuietEnd are the local times, formatted as 15:04, of the quiet hours. The messages the bot sends
+	// on its own during or shortly before the quiet hours are delivered silently.
+	QuietStart string `json:"quiet_start,omitempty"`
+	QuietEnd   string `json:"quiet_end,omitempty"`
+	// CleanupMinutes deletes the question prompts and the feedback of the bot in a group after that many minutes, zero
+	// keeps them.
+	CleanupMinutes int `json:"cleanup_minutes,omitempty"`
+	// NoWinBack opts out of the messages sent after a while without studying.
+	NoWinBack bool `json:"no_win_back,omitempty"`
+	// ResurfacePerWeek is the number of mastered words brought back into the reviews every week, the last time at
+	// ResurfacedAt. Zero leaves them retired.
+	ResurfacePerWeek int        `json:"resurface_per_week,omitempty"`
+	ResurfacedAt     *time.Time `json:"resurfaced_at,omitempty"`
+	// WarmUp is the number of well-known words starting the sessions before the hard material, zero for none.
+	WarmUp int `json:"warm_up,omitempty"`
+	// NoRecap ends the sessions with their summary only, rather than a recap of the missed words with the buttons to
+	// tag, note or drill them again.
+	NoRecap bool `json:"no_recap,omitempty"`
+	// GradebookURL is the LMS endpoint receiving the scores of the quizzes of a group for GradebookAssignment, signed
+	// with GradebookSecret.
+	GradebookURL        string `json:"gradebook_url,omitempty"`
+	GradebookAssignment string `json:"gradebook_assignment,omitempty"`
+	GradebookSecret     string `json:"gradebook_secret,omitempty"`
+	// WebhookURL receives the events of the user, e.g. the words added, signed with WebhookSecret. Empty turns it off.
+	WebhookURL    string `json:"webhook_url,omitempty"`
+	WebhookSecret string `json:"webhook_secret,omitempty"`
+	// Prefix replaces the slash and the mention of the bot for the commands in a group, e.g. !add.
+	Prefix string `json:"prefix,omitempty"`
+	// PrivacyVersion is the version of the privacy notice the user accepted, at PrivacyAcceptedAt.
+	PrivacyVersion    string     `json:"privacy_version,omitempty"`
+	PrivacyAcceptedAt *time.Time `json:"privacy_accepted_at,omitempty"`
+	// PendingTemplate is the token of the class template to apply once the privacy notice is accepted.
+	PendingTemplate string `json:"pending_template,omitempty"`
+	// ClassTemplate is the token of the class template applied last and ClassDeck the deck holding its words, the
+	// assignment being completed at ClassCompletedAt once every word of the deck is reviewed.
+	ClassTemplate    string     `json:"class_template,omitempty"`
+	ClassDeck        string     `json:"class_deck,omitempty"`
+	ClassCompletedAt *time.Time `json:"class_completed_at,omitempty"`
+	// ClassAttempts is the number of attempts of the assignment of ClassTemplate started.
+	ClassAttempts int `json:"class_attempts,omitempty"`
+}
+
+// Location returns the time zone of the user, UTC when it is not set or unknown.
+func (settings Settings) Location() *time.Location {
+	location, err := time.LoadLocation(settings.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+
+	return location
+}
+
+// quietLead is how long before the quiet hours the messages are already delivered silently.
+const quietLead = time.Hour
+
+// minuteOfDay returns the minutes since midnight of the 15:04 formatted time.
+func minuteOfDay(value string) (int, bool) {
+	parsed, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, false
+	}
+
+	return parsed.Hour()*60 + parsed.Minute(), true
+}
+
+// Silent reports whether a message the bot sends on its own at the given time should not buzz the phone of the user.
+func (settings Settings) Silent(now time.Time) bool {
+	if settings.SilentPushes {
+		return true
+	}
+
+	start, startOK := minuteOfDay(settings.QuietStart)
+	end, endOK := minuteOfDay(settings.QuietEnd)
+	if !startOK || !endOK {
+		return false
+	}
+
+	local := now.In(settings.Location())
+	minute := local.Hour()*60 + local.Minute()
+	from := (start - int(quietLead/time.Minute) + 24*60) % (24 * 60)
+
+	// The quiet hours may span midnight, e.g. 22:00 to 07:00.
+	if from <= end {
+		return from <= minute && minute < end
+	}
+
+	return minute >= from || minute < end
+}
+
+// SettingsManager defines operations to be fulfilled by the implementation that has capability to store the
+// preferences of the users.
+type SettingsManager interface {
+	Settings(chatID int64) (Settings, error)
+	SaveSettings(chatID int64, settings Settings) error
+}
+
+// SettingsStore stores the preferences of the users.
+type SettingsStore struct {
+	bucket  []byte
+	shards  Shards
+	journal *Journal
+}
+
+// NewSettingsStore creates a new instance of SettingsStore
+func NewSettingsStore(shards Shards, bucket string, journal *Journal) SettingsStore {
+	return SettingsStore{shards: shards, bucket: []byte(bucket), journal: journal}
+}
+
+// Settings returns the preferences of the user identified by the chat ID, the defaults when the user has not changed
+// anything.
+// This function returns the following errors:
+//  - ErrDatabaseError
+func (store SettingsStore) Settings(chatID int64) (Settings, error) {
+	var settings Settings
+
+	err := store.shards.For(chatID).View(func(tx *bbolt.Tx) error {
+		data := tx.Bucket(store.bucket).Get(userKey(chatID, ""))
+		if data == nil {
+			return nil
+		}
+
+		return json.Unmarshal(data, &settings)
+	})
+	if err != nil {
+		log.Printf("Failed to read settings. %s.\n", err)
+		return Settings{}, ErrDatabaseError
+	}
+
+	return settings, nil
+}
+
+// SaveSettings saves the preferences of the user identified by the chat ID.
+// This function returns the following errors:
+//  - ErrDatabaseError
+func (store SettingsStore) SaveSettings(chatID int64, settings Settings) error {
+	value, err := json.Marshal(settings)
+	if err != nil {
+		log.Printf("Failed to encode settings. %s.\n", err)
+		return ErrDatabaseError
+	}
+
+	err = store.shards.For(chatID).Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket(store.bucket).Put(userKey(chatID, ""), value)
+	})
+	if err != nil {
+		log.Printf("Failed to save settings. %s.\n", err)
+		return ErrDatabaseError
+	}
+
+	store.journal.Append(PutRecord(chatID, store.bucket, userKey(chatID, ""), value))
+	return nil
+}
@@ -0,0 +1,189 @@
+package telegram
+
+import (
+	"errors"
+	"fmt"
+	"go.etcd.io/bbolt"
+	"hash/fnv"
+	"log"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ErrShardMismatch indicates that the databases were created with another number of shards. The users are assigned
+// to a shard by the hash of their chat ID, so changing the number of shards would lose track of their data.
+var ErrShardMismatch = errors.New("number of shards does not match the databases")
+
+// metaBucket stores the layout of the databases in the first shard.
+var metaBucket = []byte("meta")
+
+var shardsKey = []byte("shards")
+
+var schemaVersionKey = []byte("schema_version")
+
+// Shards holds the databases the data of the users is spread across. The first shard is also the main database
+// storing the data not owned by a single user.
+type Shards []*bbolt.DB
+
+// ShardPath returns the path of the database file of the shard. The first shard uses the path as is so that a single
+// shard deployment keeps using the same file, the other shards append their index to the file name, e.g. kquiz-1.db.
+func ShardPath(path string, shard int) string {
+	if shard == 0 {
+		return path
+	}
+
+	ext := filepath.Ext(path)
+	return fmt.Sprintf("%s-%d%s", strings.TrimSuffix(path, ext), shard, ext)
+}
+
+// OpenShards opens the given number of databases laid out from the path of the first shard. The number of shards is
+// recorded in the first shard and cannot change afterwards.
+// This function returns the following errors:
+//  - ErrShardMismatch
+//  - ErrDatabaseError
+func OpenShards(path string, count int) (Shards, error) {
+	if count < 1 {
+		count = 1
+	}
+
+	shards := make(Shards, 0, count)
+	for i := 0; i < count; i++ {
+		db, err := bbolt.Open(ShardPath(path, i), 0666, nil)
+		if err != nil {
+			log.Printf("Failed to open database %s. %s.\n", ShardPath(path, i), err)
+			shards.Close()
+			return nil, ErrDatabaseError
+		}
+
+		shards = append(shards, db)
+	}
+
+	err := shards.checkLayout()
+	if err != nil {
+		shards.Close()
+		return nil, err
+	}
+
+	return shards, nil
+}
+
+// checkLayout records the number of shards in a new database and makes sure it did not change in an existing one. A
+// database existing before the shards did is a single shard.
+func (shards Shards) checkLayout() error {
+	recorded := 0
+
+	err := shards.Main().Update(func(tx *bbolt.Tx) error {
+		existing := false
+		err := tx.ForEach(func([]byte, *bbolt.Bucket) error {
+			existing = true
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		bucket := tx.Bucket(metaBucket)
+		if bucket != nil {
+			recorded, err = strconv.Atoi(string(bucket.Get(shardsKey)))
+			return err
+		}
+
+		recorded = len(shards)
+		if existing {
+			recorded = 1
+		}
+
+		bucket, err = tx.CreateBucket(metaBucket)
+		if err != nil {
+			return err
+		}
+
+		return bucket.Put(shardsKey, []byte(strconv.Itoa(recorded)))
+	})
+	if err != nil {
+		log.Printf("Failed to read database layout. %s.\n", err)
+		return ErrDatabaseError
+	}
+
+	if recorded != len(shards) {
+		log.Printf("The databases have %d shards but %d are configured.\n", recorded, len(shards))
+		return ErrShardMismatch
+	}
+
+	return nil
+}
+
+// SchemaVersion returns the version of the layout of the data recorded in the first shard by the migrations, zero when
+// none ran yet. The transaction must be one of the first shard.
+func SchemaVersion(tx *bbolt.Tx) (int, error) {
+	bucket := tx.Bucket(metaBucket)
+	if bucket == nil || bucket.Get(schemaVersionKey) == nil {
+		return 0, nil
+	}
+
+	return strconv.Atoi(string(bucket.Get(schemaVersionKey)))
+}
+
+// SetSchemaVersion records the version of the layout of the data in the first shard, within the transaction.
+func SetSchemaVersion(tx *bbolt.Tx, version int) error {
+	bucket, err := tx.CreateBucketIfNotExists(metaBucket)
+	if err != nil {
+		return err
+	}
+
+	return bucket.Put(schemaVersionKey, []byte(strconv.Itoa(version)))
+}
+
+// Main returns the main database.
+func (shards Shards) Main() *bbolt.DB {
+	return shards[0]
+}
+
+// For returns the database storing the data of the user identified by the chat ID.
+func (shards Shards) For(chatID int64) *bbolt.DB {
+	if len(shards) == 1 {
+		return shards[0]
+	}
+
+	hash := fnv.New32a()
+	_, _ = hash.Write([]byte(strconv.FormatInt(chatID, 10)))
+	return shards[hash.Sum32()%uint32(len(shards))]
+}
+
+// Update executes the function in a read-write transaction of every shard, stopping at the first error.
+func (shards Shards) Update(fn func(tx *bbolt.Tx) error) error {
+	for _, db := range shards {
+		err := db.Update(fn)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// View executes the function in a read-only transaction of every shard, stopping at the first error.
+func (shards Shards) View(fn func(tx *bbolt.Tx) error) error {
+	for _, db := range shards {
+		err := db.View(fn)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Close closes every shard and returns the first error.
+func (shards Shards) Close() error {
+	var firstErr error
+	for _, db := range shards {
+		err := db.Close()
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	return firstErr
+}
@@ -0,0 +1,195 @@
+package telegram
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go.etcd.io/bbolt"
+	"log"
+	"time"
+)
+
+// ErrSnapshotNotFound indicates that no snapshot of the words of the user was taken yet.
+var ErrSnapshotNotFound = errors.New("snapshot not found")
+
+// SnapshotWord is a word as kept in a snapshot, what the user edits of it without its review schedule.
+type SnapshotWord struct {
+	Kind        string   `json:"kind,omitempty"`
+	Translation string   `json:"translation"`
+	Example     string   `json:"example,omitempty"`
+	Notes       string   `json:"notes,omitempty"`
+	Tags        []string `json:"tags,omitempty"`
+}
+
+// Snapshot represents the words and the grammar patterns of a user at a point in time.
+type Snapshot struct {
+	At    time.Time               `json:"at"`
+	Words map[string]SnapshotWord `json:"words"`
+}
+
+// NewSnapshot returns the snapshot of the entries at the given time.
+func NewSnapshot(entries []Entry, at time.Time) Snapshot {
+	snapshot := Snapshot{At: at, Words: make(map[string]SnapshotWord, len(entries))}
+	for _, entry := range entries {
+		snapshot.Words[entry.Word] = SnapshotWord{Kind: entry.Kind, Translation: entry.Translation,
+			Example: entry.Example, Notes: entry.Notes, Tags: entry.Tags}
+	}
+
+	return snapshot
+}
+
+// Snapshotter defines operations to be fulfilled by the implementation that has capability to keep the snapshots of
+// the words of the users.
+type Snapshotter interface {
+	SaveSnapshot(chatID int64, snapshot Snapshot) error
+	LatestSnapshot(chatID int64) (*Snapshot, error)
+	SnapshotAt(chatID int64, at time.Time) (*Snapshot, error)
+}
+
+// SnapshotStore stores the weekly snapshots of the words of the users, to show them how their words changed since.
+type SnapshotStore struct {
+	bucket  []byte
+	shards  Shards
+	journal *Journal
+}
+
+// NewSnapshotStore creates a new instance of SnapshotStore
+func NewSnapshotStore(shards Shards, bucket string, journal *Journal) SnapshotStore {
+	return SnapshotStore{shards: shards, journal: journal, bucket: []byte(bucket)}
+}
+
+// snapshotKey returns the key of a snapshot, sorted chronologically like the lines of the transcripts.
+func snapshotKey(chatID int64, at time.Time) []byte {
+	return userKey(chatID, fmt.Sprintf(":%020d", at.UnixNano()))
+}
+
+// snapshotOwner returns the prefix of the user owning the snapshot key, the chat ID followed by the colon.
+func snapshotOwner(key []byte) []byte {
+	return key[:bytes.LastIndexByte(key, ':')+1]
+}
+
+// SaveSnapshot saves the snapshot of the words of the user identified by the chat ID.
+// This function returns the following errors:
+//  - ErrDatabaseError
+func (store SnapshotStore) SaveSnapshot(chatID int64, snapshot Snapshot) error {
+	value, err := json.Marshal(snapshot)
+	if err != nil {
+		log.Printf("Failed to encode snapshot. %s.\n", err)
+		return ErrDatabaseError
+	}
+
+	err = store.shards.For(chatID).Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket(store.bucket).Put(snapshotKey(chatID, snapshot.At), value)
+	})
+	if err != nil {
+		log.Printf("Failed to save snapshot. %s.\n", err)
+		return ErrDatabaseError
+	}
+
+	store.journal.Append(PutRecord(chatID, store.bucket, snapshotKey(chatID, snapshot.At), value))
+
+	return nil
+}
+
+// LatestSnapshot returns the latest snapshot of the words of the user identified by the chat ID.
+// This function returns the following errors:
+//  - ErrDatabaseError
+//  - ErrSnapshotNotFound
+func (store SnapshotStore) LatestSnapshot(chatID int64) (*Snapshot, error) {
+	return store.SnapshotAt(chatID, time.Now())
+}
+
+// SnapshotAt returns the snapshot of the words of the user identified by the chat ID as they were at the given time,
+// the latest taken by then. When there is none, it is the oldest snapshot, taken after.
+// This function returns the following errors:
+//  - ErrDatabaseError
+//  - ErrSnapshotNotFound
+func (store SnapshotStore) SnapshotAt(chatID int64, at time.Time) (*Snapshot, error) {
+	var snapshot *Snapshot
+	prefix := userKey(chatID, ":")
+
+	err := store.shards.For(chatID).View(func(tx *bbolt.Tx) error {
+		cursor := tx.Bucket(store.bucket).Cursor()
+
+		// The snapshot at the time is the one before the first snapshot taken after it, if it is the user's.
+		after := snapshotKey(chatID, at.Add(time.Nanosecond))
+		key, value := cursor.Seek(after)
+		if key == nil {
+			key, value = cursor.Last()
+		} else {
+			key, value = cursor.Prev()
+		}
+
+		if key == nil || !bytes.HasPrefix(key, prefix) {
+			key, value = cursor.Seek(after)
+		}
+
+		if key == nil || !bytes.HasPrefix(key, prefix) {
+			return ErrSnapshotNotFound
+		}
+
+		snapshot = &Snapshot{}
+		return json.Unmarshal(value, snapshot)
+	})
+	if err != nil {
+		if err == ErrSnapshotNotFound {
+			return nil, ErrSnapshotNotFound
+		}
+
+		log.Printf("Failed to get snapshot. %s.\n", err)
+		return nil, ErrDatabaseError
+	}
+
+	return snapshot, nil
+}
+
+// PruneSnapshots removes the snapshots of all the users taken before the given time and returns how many were
+// removed. The latest snapshot of each user is kept, to compare the words with.
+// This function returns the following errors:
+//  - ErrDatabaseError
+func (store SnapshotStore) PruneSnapshots(before time.Time) (int, error) {
+	removed := 0
+
+	err := store.shards.Update(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket(store.bucket)
+
+		// Deleting keys while iterating is not allowed. The keys of a user follow each other, oldest first.
+		var expired [][]byte
+		var previous []byte
+		err := bucket.ForEach(func(key, value []byte) error {
+			if previous != nil && bytes.Equal(snapshotOwner(previous), snapshotOwner(key)) {
+				expired = append(expired, previous)
+			}
+
+			previous = nil
+
+			var snapshot Snapshot
+			if json.Unmarshal(value, &snapshot) != nil || snapshot.At.Before(before) {
+				previous = append([]byte(nil), key...)
+			}
+
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, key := range expired {
+			err = bucket.Delete(key)
+			if err != nil {
+				return err
+			}
+
+			removed++
+		}
+
+		return nil
+	})
+	if err != nil {
+		log.Printf("Failed to prune snapshots. %s.\n", err)
+		return removed, ErrDatabaseError
+	}
+
+	return removed, nil
+}
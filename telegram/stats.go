@@ -0,0 +1,151 @@
+package telegram
+
+import (
+	"encoding/json"
+	"go.etcd.io/bbolt"
+	"log"
+)
+
+// Stats represents the counters of the studying of a user since the counting started.
+type Stats struct {
+	WordsAdded   int `json:"words_added,omitempty"`
+	QuizzesAsked int `json:"quizzes_asked,omitempty"`
+	Correct      int `json:"correct,omitempty"`
+	Incorrect    int `json:"incorrect,omitempty"`
+	// BestStreak is the longest number of days in a row the user studied.
+	BestStreak int `json:"best_streak,omitempty"`
+}
+
+// Answered returns the number of quizzes answered.
+func (stats Stats) Answered() int {
+	return stats.Correct + stats.Incorrect
+}
+
+// Accuracy returns the percentage of the answers that are correct, zero when there is none.
+func (stats Stats) Accuracy() int {
+	if stats.Answered() == 0 {
+		return 0
+	}
+
+	return stats.Correct * 100 / stats.Answered()
+}
+
+// StatsTracker defines operations to be fulfilled by the implementation that has capability to count the studying of
+// the users.
+type StatsTracker interface {
+	CountAdded(chatID int64, words int) error
+	CountAsked(chatID int64) error
+	CountAnswer(chatID int64, correct bool) error
+	RecordStreak(chatID int64, streak int) error
+	Stats(chatID int64) (Stats, error)
+}
+
+// StatsStore stores the counters of the studying of the users.
+type StatsStore struct {
+	bucket  []byte
+	shards  Shards
+	journal *Journal
+}
+
+// NewStatsStore creates a new instance of StatsStore
+func NewStatsStore(shards Shards, bucket string, journal *Journal) StatsStore {
+	return StatsStore{shards: shards, bucket: []byte(bucket), journal: journal}
+}
+
+// update changes the counters of the user identified by the chat ID in place.
+func (store StatsStore) update(chatID int64, change func(stats *Stats)) error {
+	var record JournalRecord
+
+	err := store.shards.For(chatID).Update(func(tx *bbolt.Tx) error {
+		var stats Stats
+
+		bucket := tx.Bucket(store.bucket)
+		if data := bucket.Get(userKey(chatID, "")); data != nil {
+			err := json.Unmarshal(data, &stats)
+			if err != nil {
+				return err
+			}
+		}
+
+		change(&stats)
+
+		value, err := json.Marshal(stats)
+		if err != nil {
+			return err
+		}
+
+		record = PutRecord(chatID, store.bucket, userKey(chatID, ""), value)
+		return bucket.Put(userKey(chatID, ""), value)
+	})
+	if err != nil {
+		log.Printf("Failed to save stats. %s.\n", err)
+		return ErrDatabaseError
+	}
+
+	store.journal.Append(record)
+	return nil
+}
+
+// CountAdded counts the words added by the user identified by the chat ID.
+// This function returns the following errors:
+//  - ErrDatabaseError
+func (store StatsStore) CountAdded(chatID int64, words int) error {
+	return store.update(chatID, func(stats *Stats) {
+		stats.WordsAdded += words
+	})
+}
+
+// CountAsked counts a quiz asked to the user identified by the chat ID.
+// This function returns the following errors:
+//  - ErrDatabaseError
+func (store StatsStore) CountAsked(chatID int64) error {
+	return store.update(chatID, func(stats *Stats) {
+		stats.QuizzesAsked++
+	})
+}
+
+// CountAnswer counts a quiz answered by the user identified by the chat ID given whether it was answered correctly.
+// This function returns the following errors:
+//  - ErrDatabaseError
+func (store StatsStore) CountAnswer(chatID int64, correct bool) error {
+	return store.update(chatID, func(stats *Stats) {
+		if correct {
+			stats.Correct++
+		} else {
+			stats.Incorrect++
+		}
+	})
+}
+
+// RecordStreak keeps the streak of the user identified by the chat ID when it is the longest so far.
+// This function returns the following errors:
+//  - ErrDatabaseError
+func (store StatsStore) RecordStreak(chatID int64, streak int) error {
+	return store.update(chatID, func(stats *Stats) {
+		if streak > stats.BestStreak {
+			stats.BestStreak = streak
+		}
+	})
+}
+
+// Stats returns the counters of the user identified by the chat ID, the zero value when nothing was counted yet.
+// This function returns the following errors:
+//  - ErrDatabaseError
+func (store StatsStore) Stats(chatID int64) (Stats, error) {
+	var stats Stats
+
+	err := store.shards.For(chatID).View(func(tx *bbolt.Tx) error {
+		data := tx.Bucket(store.bucket).Get(userKey(chatID, ""))
+		if data == nil {
+			return nil
+		}
+
+		return json.Unmarshal(data, &stats)
+	})
+	if err != nil {
+		log.Printf("Failed to read stats. %s.\n", err)
+		return Stats{}, ErrDatabaseError
+	}
+
+	return stats, nil
+}
@@ -1,12 +1,8 @@
 package telegram
 
 import (
+	"encoding/json"
 	"errors"
-	"fmt"
-	"go.etcd.io/bbolt"
-	"log"
-	"math/rand"
-	"strings"
 	"time"
 )
 
@@ -25,324 +21,102 @@ var ErrDuplicateWord = errors.New("duplicate word")
 // ErrWordNotFound indicates that the word not found.
 var ErrWordNotFound = errors.New("word not found")
 
-// Registerer defines operations to be fulfilled by the implementation that has capability to register user.
-type Registerer interface {
-	Register(chatID int64) error
-}
-
-// Checker defines operations to be fulfilled by the implementation that has capability to check if an ID is registered.
-type Checker interface {
-	IsRegistered(chatID int64) bool
-	IsAdded(chatID int64, word string) bool
-}
-
-// Unregisterer defines operations to be fulfilled by the implementation that has capability to unregister user.
-type Unregisterer interface {
-	Unregister(chatID int64) error
-}
-
-// Adder defines operations to be fulfilled by the implementation that has capability to add word.
-type Adder interface {
-	Add(chatID int64, word string, translation string) error
-}
-
-// Deleter defines operations to be fulfilled by the implementation that has capability to delete word.
-type Deleter interface {
-	Delete(chatID int64, word string) error
-	Clear(chatID int64) error
-}
+// KindVocabulary is the kind of the entries holding a word and its translation.
+const KindVocabulary = "vocabulary"
 
-// Searcher defines operations to be fulfilled by the implementation that has capability to search a word.
-type Searcher interface {
-	Search(chatID int64, word string) (*string, error)
-	Random(chatID int64) ([]string, error)
-}
+// KindGrammar is the kind of the entries holding a grammar pattern and its meaning.
+const KindGrammar = "grammar"
 
-// Lister defines operations to be fulfilled by the implementation that has capability to list words.
-type Lister interface {
-	List(chatID int64) ([][]string, error)
-}
+// WordEntry represents the value stored for a word in the database.
+type WordEntry struct {
+	Kind         string     `json:"kind,omitempty"`
+	Translation  string     `json:"translation"`
+	Example      string     `json:"example,omitempty"`
+	Notes        string     `json:"notes,omitempty"`
+	Deck         string     `json:"deck,omitempty"`
+	Tags         []string   `json:"tags,omitempty"`
+	CreatedAt    *time.Time `json:"created_at,omitempty"`
+	LastReviewed *time.Time `json:"last_reviewed,omitempty"`
 
-// BotHandler handles Telegram bot operations.
-type BotHandler struct {
-	telegramBucket []byte
-	kquizBucket    []byte
-	db             *bbolt.DB
-}
-
-// NewBotHandler creates a new instance of BotHandler
-func NewBotHandler(db *bbolt.DB, telegramBucket string, kquizBucket string) BotHandler {
-	return BotHandler{db: db, telegramBucket: []byte(telegramBucket), kquizBucket: []byte(kquizBucket)}
-}
-
-func (bot BotHandler) IsRegistered(chatID int64) bool {
-	exists := false
-
-	err := bot.db.View(func(tx *bbolt.Tx) error {
-		bucket := tx.Bucket(bot.telegramBucket)
-		data := bucket.Get([]byte(fmt.Sprintf("%d", chatID)))
-		exists = data != nil
-
-		return nil
-	})
-	if err != nil {
-		log.Printf("Failed to read data from telegram bucket. %s.\n", err)
-	}
-
-	return exists
-}
-
-func (bot BotHandler) IsAdded(chatID int64, word string) bool {
-	exists := false
-
-	err := bot.db.View(func(tx *bbolt.Tx) error {
-		key := []byte(fmt.Sprintf("%d%s", chatID, word))
-		bucket := tx.Bucket(bot.kquizBucket)
-		data := bucket.Get(key)
-		exists = data != nil
-
-		return nil
-	})
-	if err != nil {
-		log.Printf("Failed to read data from telegram bucket. %s.\n", err)
-	}
-
-	return exists
-}
-
-// Register registers a new user. This function can return the following errors:
-//  - ErrAlreadyRegistered
-//  - ErrDatabaseError
-func (bot BotHandler) Register(chatID int64) error {
-	if bot.IsRegistered(chatID) {
-		return ErrAlreadyRegistered
-	}
-
-	err := bot.db.Update(func(tx *bbolt.Tx) error {
-		key := []byte(fmt.Sprintf("%d", chatID))
-		value := key
-
-		bucket := tx.Bucket(bot.telegramBucket)
-		return bucket.Put(key, value)
-	})
-	if err != nil {
-		log.Printf("Failed to update registration data. %s.\n", err)
-		return ErrDatabaseError
-	}
-
-	return nil
-}
-
-// Unregister unregisters an existing user. This function can return the following errors:
-//  - ErrNotRegistered
-//  - ErrDatabaseError
-func (bot BotHandler) Unregister(chatID int64) error {
-	if !bot.IsRegistered(chatID) {
-		return ErrNotRegistered
-	}
+	// Source is the URL of the page the word was captured from, e.g. by the browser extension, empty when it was typed.
+	// Origin tells how the word was added, see EntryOrigin.
+	Source string `json:"source,omitempty"`
+	Origin string `json:"origin,omitempty"`
 
-	err := bot.db.Update(func(tx *bbolt.Tx) error {
-		key := []byte(fmt.Sprintf("%d", chatID))
+	// The mistake notebook holds the word from its last wrong answer until it is answered correctly a few times, see
+	// quiz.TrackMistake.
+	MistakenAt *time.Time `json:"mistaken_at,omitempty"`
+	Fixes      int        `json:"fixes,omitempty"`
 
-		bucket := tx.Bucket(bot.telegramBucket)
-		return bucket.Delete(key)
-	})
-	if err != nil {
-		log.Printf("Failed to update registration data. %s.\n", err)
-		return ErrDatabaseError
-	}
+	// The review schedule of the word, see quiz.Scheduler.
+	Due         *time.Time    `json:"due,omitempty"`
+	Interval    time.Duration `json:"interval,omitempty"`
+	Ease        float64       `json:"ease,omitempty"`
+	Repetitions int           `json:"repetitions,omitempty"`
 
-	return nil
+	// MasteredAt is when the word was mastered, see quiz.Scheduler. The mastered words are retired from the quizzes
+	// but still listed and searched.
+	MasteredAt *time.Time `json:"mastered_at,omitempty"`
 }
 
-// Add adds a word and its translation to the database. This data is unique for each user identified by the chat ID.
-// This function returns the following errors:
-//  - ErrNotRegistered
-//  - ErrDatabaseError
-//  - ErrDuplicateWord
-func (bot BotHandler) Add(chatID int64, word string, translation string) error {
-	if !bot.IsRegistered(chatID) {
-		return ErrNotRegistered
-	}
-
-	if bot.IsAdded(chatID, word) {
-		return ErrDuplicateWord
-	}
-
-	err := bot.db.Update(func(tx *bbolt.Tx) error {
-		key := []byte(fmt.Sprintf("%d%s", chatID, word))
-		bucket := tx.Bucket(bot.kquizBucket)
-		return bucket.Put(key, []byte(translation))
-	})
-	if err != nil {
-		log.Printf("Failed to add word. %s.", err)
-		return ErrDatabaseError
+// EntryKind returns the kind of the entry. Entries stored without kind are vocabulary.
+func (entry WordEntry) EntryKind() string {
+	if len(entry.Kind) == 0 {
+		return KindVocabulary
 	}
 
-	return nil
+	return entry.Kind
 }
 
-// Search searches a word from the database.
-// This function returns the following errors:
-//  - ErrNotRegistered
-//  - ErrDatabaseError
-//  - ErrWordNotFound
-func (bot BotHandler) Search(chatID int64, word string) (*string, error) {
-	if !bot.IsRegistered(chatID) {
-		return nil, ErrNotRegistered
+// HasTag reports whether the entry is tagged with the tag, every entry has the empty tag.
+func (entry WordEntry) HasTag(tag string) bool {
+	if len(tag) == 0 {
+		return true
 	}
 
-	var translation []byte
-
-	err := bot.db.View(func(tx *bbolt.Tx) error {
-		key := []byte(fmt.Sprintf("%d%s", chatID, word))
-		bucket := tx.Bucket(bot.kquizBucket)
-		translation = bucket.Get(key)
-
-		if translation == nil {
-			return ErrWordNotFound
-		}
-
-		return nil
-	})
-	if err != nil {
-		log.Printf("Failed to get word. %s.", err)
-
-		if err != ErrWordNotFound {
-			return nil, ErrDatabaseError
-		} else {
-			return nil, ErrWordNotFound
+	for _, candidate := range entry.Tags {
+		if candidate == tag {
+			return true
 		}
 	}
 
-	translationStr := string(translation)
-	return &translationStr, nil
+	return false
 }
 
-// Random gets random item from the database. When successful, this returned slice will contain
-// 2 elements; first element is the Korean word and the second element is the translation.
-// This function returns the following errors:
-//  - ErrNotRegistered
-//  - ErrDatabaseError
-//  - ErrWordNotFound
-func (bot BotHandler) Random(chatID int64) ([]string, error) {
-	if !bot.IsRegistered(chatID) {
-		return nil, ErrNotRegistered
+// Tagged returns the entries tagged with the tag, all of them for the empty tag.
+func Tagged(entries []Entry, tag string) []Entry {
+	if len(tag) == 0 {
+		return entries
 	}
 
-	items, err := bot.List(chatID)
-	if err != nil {
-		log.Printf("Failed to get random word. %s.", err)
-		return nil, err
+	tagged := make([]Entry, 0)
+	for _, entry := range entries {
+		if entry.HasTag(tag) {
+			tagged = append(tagged, entry)
+		}
 	}
 
-	rand.Seed(time.Now().UnixNano())
-	idx := rand.Intn(len(items))
-
-	return items[idx], nil
+	return tagged
 }
 
-// Delete deletes a word from the database.
-// This function returns the following errors:
-//  - ErrNotRegistered
-//  - ErrDatabaseError
-//  - ErrWordNotFound
-func (bot BotHandler) Delete(chatID int64, word string) error {
-	if !bot.IsRegistered(chatID) {
-		return ErrNotRegistered
-	}
-
-	if !bot.IsAdded(chatID, word) {
-		return ErrWordNotFound
-	}
-
-	err := bot.db.Update(func(tx *bbolt.Tx) error {
-		key := []byte(fmt.Sprintf("%d%s", chatID, word))
-		bucket := tx.Bucket(bot.kquizBucket)
-		return bucket.Delete(key)
-	})
-	if err != nil {
-		log.Printf("Failed to get word. %s.", err)
-		return ErrDatabaseError
-	}
-
-	return nil
+// Entry represents a word, or a grammar pattern, together with its stored value.
+type Entry struct {
+	Word string
+	WordEntry
 }
 
-// Clear clears all words from the database owned by the user identified with chat ID.
-// This function returns the following errors:
-//  - ErrNotRegistered
-//  - ErrDatabaseError
-func (bot BotHandler) Clear(chatID int64) error {
-	if !bot.IsRegistered(chatID) {
-		return ErrNotRegistered
-	}
-
-	err := bot.db.Update(func(tx *bbolt.Tx) error {
-		chatIDStr := fmt.Sprintf("%d", chatID)
-		bucket := tx.Bucket(bot.kquizBucket)
-		cursor := bucket.Cursor()
-
-		for key, _ := cursor.First(); key != nil; key, _ = cursor.Next() {
-			keyStr := string(key)
-			if !strings.HasPrefix(keyStr, chatIDStr) {
-				// This word is not owned by the user. Skip.
-				continue
-			}
-
-			err := cursor.Delete()
-			if err != nil {
-				return err
-			}
-		}
-
-		return nil
-	})
-	if err != nil {
-		log.Printf("Failed to clear words. %s.", err)
-		return ErrDatabaseError
-	}
-
-	return nil
+// EncodeEntry serialises the entry to be stored in the database.
+func EncodeEntry(entry WordEntry) ([]byte, error) {
+	return json.Marshal(entry)
 }
 
-// List lists words from the database owned by the user as identified by the chat ID.
-// This function returns the following errors:
-//  - ErrNotRegistered
-//  - ErrDatabaseError
-//  - ErrWordNotFound
-func (bot BotHandler) List(chatID int64) ([][]string, error) {
-	wordMap := make([][]string, 0)
-
-	if !bot.IsRegistered(chatID) {
-		return nil, ErrNotRegistered
-	}
-
-	err := bot.db.View(func(tx *bbolt.Tx) error {
-		bucket := tx.Bucket(bot.kquizBucket)
-		cursor := bucket.Cursor()
-
-		for key, value := cursor.First(); key != nil; key, value = cursor.Next() {
-			if !strings.HasPrefix(string(key), fmt.Sprintf("%d", chatID)) {
-				continue
-			}
-
-			// Remove the chatID from the koreanWord
-			koreanWord := strings.ReplaceAll(string(key), fmt.Sprintf("%d", chatID), "")
-			translation := string(value)
-			wordMap = append(wordMap, []string{koreanWord, translation})
-		}
-
-		return nil
-	})
-	if err != nil {
-		log.Printf("Failed to list words. %s.", err)
-		return nil, ErrDatabaseError
-	}
-
-	if len(wordMap) == 0 {
-		return nil, ErrWordNotFound
+// DecodeEntry deserialises the entry stored in the database. Words added before entries were introduced are stored as
+// the plain translation, hence, anything that is not a JSON object is treated as such.
+func DecodeEntry(value []byte) WordEntry {
+	var entry WordEntry
+	if len(value) > 0 && value[0] == '{' && json.Unmarshal(value, &entry) == nil {
+		return entry
 	}
 
-	return wordMap, nil
+	return WordEntry{Translation: string(value)}
 }
@@ -0,0 +1,89 @@
+package telegram
+
+import (
+	"bytes"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+func FuzzEncodeEntry(f *testing.F) {
+	f.Add("학교", "school", "", "", "", int64(0))
+	f.Add("2호선", "line 2", "2호선을 타요.", "Seoul subway", "교통", int64(1700000000))
+	f.Add("-(으)면", "if", "비가 오면 집에 있어요.", "", "", int64(-1))
+	f.Add("7시 30분", "7:30", "", "12345", "시간 2", int64(253402300799))
+
+	f.Fuzz(func(t *testing.T, word string, translation string, example string, notes string, tag string,
+		unix int64) {
+		// JSON replaces the invalid UTF-8 with U+FFFD, the words typed in Telegram are always valid.
+		for _, text := range []string{word, translation, example, notes, tag} {
+			if !utf8.ValidString(text) {
+				return
+			}
+		}
+
+		entry := WordEntry{Translation: translation, Example: example, Notes: notes}
+		if len(tag) != 0 {
+			entry.Tags = []string{tag}
+		}
+		if len(word) != 0 && word[0] == '-' {
+			entry.Kind = KindGrammar
+		}
+		if unix >= 0 && unix < 253402300800 {
+			at := time.Unix(unix, 0).UTC()
+			entry.CreatedAt = &at
+			entry.Due = &at
+		}
+
+		value, err := EncodeEntry(entry)
+		if err != nil {
+			t.Fatalf("EncodeEntry(%+v) failed. %s.", entry, err)
+		}
+
+		decoded := DecodeEntry(value)
+		if decoded.Translation != entry.Translation || decoded.Example != entry.Example ||
+			decoded.Notes != entry.Notes || decoded.Kind != entry.Kind || !decoded.HasTag(tag) {
+			t.Fatalf("DecodeEntry(%s) = %+v, want %+v", value, decoded, entry)
+		}
+
+		again, err := EncodeEntry(decoded)
+		if err != nil || !bytes.Equal(again, value) {
+			t.Fatalf("EncodeEntry(DecodeEntry(%s)) = %s, %v", value, again, err)
+		}
+	})
+}
+
+func FuzzDecodeEntry(f *testing.F) {
+	f.Add([]byte(`{"translation":"school","tags":["교통"]}`))
+	f.Add([]byte("school"))
+	f.Add([]byte("{not json"))
+	f.Add([]byte("12345 사과"))
+	f.Add([]byte(""))
+
+	f.Fuzz(func(t *testing.T, value []byte) {
+		entry := DecodeEntry(value)
+
+		// The translations stored before the entries are plain text, anything but a JSON object is one.
+		if len(value) == 0 || value[0] != '{' {
+			if entry.Translation != string(value) {
+				t.Fatalf("DecodeEntry(%q).Translation = %q, want the value", value, entry.Translation)
+			}
+
+			return
+		}
+
+		// Whatever was decoded is stored again as an entry, read back the same. JSON replaces the invalid UTF-8.
+		if !utf8.Valid(value) {
+			return
+		}
+
+		encoded, err := EncodeEntry(entry)
+		if err != nil {
+			t.Fatalf("EncodeEntry(%+v) failed. %s.", entry, err)
+		}
+
+		if again := DecodeEntry(encoded); again.Translation != entry.Translation || again.Kind != entry.Kind {
+			t.Fatalf("DecodeEntry(%s) = %+v, want %+v", encoded, again, entry)
+		}
+	})
+}
@@ -0,0 +1,113 @@
+package telegram
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"go.etcd.io/bbolt"
+	"log"
+	"time"
+)
+
+// ErrTemplateNotFound indicates that the class template does not exist.
+var ErrTemplateNotFound = errors.New("template not found")
+
+// The orders of the questions of an assignment.
+const (
+	OrderFixed   = "fixed"
+	OrderShuffle = "shuffle"
+)
+
+// Assignment represents the rules of the class assignment set by the teacher. The questions are asked in the order of
+// the template or shuffled for each student, the number of attempts is unlimited when zero and there is no deadline
+// when nil.
+type Assignment struct {
+	Order    string     `json:"order,omitempty"`
+	Attempts int        `json:"attempts,omitempty"`
+	Deadline *time.Time `json:"deadline,omitempty"`
+}
+
+// Template represents the settings and the words a teacher shares with a class, so that every student starts alike.
+type Template struct {
+	Owner      int64      `json:"owner"`
+	Deck       string     `json:"deck"`
+	Settings   Settings   `json:"settings"`
+	Entries    []Entry    `json:"entries"`
+	Assignment Assignment `json:"assignment"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// TemplateManager defines operations to be fulfilled by the implementation that has capability to share the class
+// templates.
+type TemplateManager interface {
+	SaveTemplate(template Template) (string, error)
+	Template(token string) (Template, error)
+}
+
+// TemplateStore stores the class templates, keyed by random tokens to be passed in the links given to the students.
+type TemplateStore struct {
+	bucket []byte
+	db     *bbolt.DB
+}
+
+// NewTemplateStore creates a new instance of TemplateStore
+func NewTemplateStore(db *bbolt.DB, bucket string) TemplateStore {
+	return TemplateStore{db: db, bucket: []byte(bucket)}
+}
+
+// SaveTemplate saves the template and returns the new random token giving access to it.
+// This function returns the following errors:
+//  - ErrDatabaseError
+func (store TemplateStore) SaveTemplate(template Template) (string, error) {
+	random := make([]byte, 16)
+	_, err := rand.Read(random)
+	if err != nil {
+		log.Printf("Failed to generate template token. %s.\n", err)
+		return "", ErrDatabaseError
+	}
+
+	token := hex.EncodeToString(random)
+	value, err := json.Marshal(template)
+	if err != nil {
+		log.Printf("Failed to encode template. %s.\n", err)
+		return "", ErrDatabaseError
+	}
+
+	err = store.db.Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket(store.bucket).Put([]byte(token), value)
+	})
+	if err != nil {
+		log.Printf("Failed to save template. %s.\n", err)
+		return "", ErrDatabaseError
+	}
+
+	return token, nil
+}
+
+// Template returns the template of the given token.
+// This function returns the following errors:
+//  - ErrTemplateNotFound
+//  - ErrDatabaseError
+func (store TemplateStore) Template(token string) (Template, error) {
+	var template Template
+
+	err := store.db.View(func(tx *bbolt.Tx) error {
+		data := tx.Bucket(store.bucket).Get([]byte(token))
+		if data == nil {
+			return ErrTemplateNotFound
+		}
+
+		return json.Unmarshal(data, &template)
+	})
+	if err != nil {
+		if err == ErrTemplateNotFound {
+			return Template{}, ErrTemplateNotFound
+		}
+
+		log.Printf("Failed to read template. %s.\n", err)
+		return Template{}, ErrDatabaseError
+	}
+
+	return template, nil
+}
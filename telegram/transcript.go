@@ -0,0 +1,141 @@
+package telegram
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go.etcd.io/bbolt"
+	"log"
+	"time"
+)
+
+// TranscriptLine represents a quiz question answered by a user, with the answer given.
+type TranscriptLine struct {
+	Prompt   string    `json:"prompt"`
+	Expected string    `json:"expected"`
+	Answer   string    `json:"answer"`
+	Correct  bool      `json:"correct"`
+	At       time.Time `json:"at"`
+}
+
+// Transcriber defines operations to be fulfilled by the implementation that has capability to keep the transcripts of
+// the quizzes of the users.
+type Transcriber interface {
+	RecordLine(chatID int64, line TranscriptLine) error
+	Transcript(chatID int64, since time.Time) ([]TranscriptLine, error)
+}
+
+// TranscriptStore stores the transcripts of the quizzes of the users, e.g. for their tutors to review the mistakes.
+type TranscriptStore struct {
+	bucket  []byte
+	shards  Shards
+	journal *Journal
+}
+
+// NewTranscriptStore creates a new instance of TranscriptStore
+func NewTranscriptStore(shards Shards, bucket string, journal *Journal) TranscriptStore {
+	return TranscriptStore{shards: shards, journal: journal, bucket: []byte(bucket)}
+}
+
+// lineKey returns the key of a line of the transcript, sorted chronologically like the pronunciation attempts.
+func lineKey(chatID int64, at time.Time) []byte {
+	return userKey(chatID, fmt.Sprintf(":%020d", at.UnixNano()))
+}
+
+// RecordLine appends the line to the transcript of the user identified by the chat ID.
+// This function returns the following errors:
+//  - ErrDatabaseError
+func (store TranscriptStore) RecordLine(chatID int64, line TranscriptLine) error {
+	value, err := json.Marshal(line)
+	if err != nil {
+		log.Printf("Failed to encode transcript line. %s.\n", err)
+		return ErrDatabaseError
+	}
+
+	err = store.shards.For(chatID).Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket(store.bucket).Put(lineKey(chatID, line.At), value)
+	})
+	if err != nil {
+		log.Printf("Failed to record transcript line. %s.\n", err)
+		return ErrDatabaseError
+	}
+
+	store.journal.Append(PutRecord(chatID, store.bucket, lineKey(chatID, line.At), value))
+
+	return nil
+}
+
+// Transcript lists the lines of the transcript of the user identified by the chat ID since the given time, oldest
+// first.
+// This function returns the following errors:
+//  - ErrDatabaseError
+func (store TranscriptStore) Transcript(chatID int64, since time.Time) ([]TranscriptLine, error) {
+	lines := make([]TranscriptLine, 0)
+	prefix := userKey(chatID, ":")
+
+	err := store.shards.For(chatID).View(func(tx *bbolt.Tx) error {
+		cursor := tx.Bucket(store.bucket).Cursor()
+
+		key, value := cursor.Seek(lineKey(chatID, since))
+		for ; key != nil && bytes.HasPrefix(key, prefix); key, value = cursor.Next() {
+			var line TranscriptLine
+			err := json.Unmarshal(value, &line)
+			if err != nil {
+				return err
+			}
+
+			lines = append(lines, line)
+		}
+
+		return nil
+	})
+	if err != nil {
+		log.Printf("Failed to list transcript. %s.\n", err)
+		return nil, ErrDatabaseError
+	}
+
+	return lines, nil
+}
+
+// PruneTranscripts removes the lines of the transcripts of all the users recorded before the given time and returns
+// how many were removed.
+// This function returns the following errors:
+//  - ErrDatabaseError
+func (store TranscriptStore) PruneTranscripts(before time.Time) (int, error) {
+	removed := 0
+
+	err := store.shards.Update(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket(store.bucket)
+
+		// Deleting keys while iterating is not allowed.
+		var expired [][]byte
+		err := bucket.ForEach(func(key, value []byte) error {
+			var line TranscriptLine
+			if json.Unmarshal(value, &line) != nil || line.At.Before(before) {
+				expired = append(expired, append([]byte(nil), key...))
+			}
+
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, key := range expired {
+			err = bucket.Delete(key)
+			if err != nil {
+				return err
+			}
+
+			removed++
+		}
+
+		return nil
+	})
+	if err != nil {
+		log.Printf("Failed to prune transcripts. %s.\n", err)
+		return removed, ErrDatabaseError
+	}
+
+	return removed, nil
+}
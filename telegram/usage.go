@@ -0,0 +1,121 @@
+package telegram
+
+import (
+	"bytes"
+	"encoding/json"
+	"go.etcd.io/bbolt"
+	"log"
+	"time"
+)
+
+// DailyUsage represents the calls to an external service on a day and their estimated cost.
+type DailyUsage struct {
+	Calls int     `json:"calls"`
+	Cost  float64 `json:"cost"`
+}
+
+// UsageStore counts the daily calls to the external services.
+type UsageStore struct {
+	bucket []byte
+	db     *bbolt.DB
+}
+
+// NewUsageStore creates a new instance of UsageStore
+func NewUsageStore(db *bbolt.DB, bucket string) UsageStore {
+	return UsageStore{db: db, bucket: []byte(bucket)}
+}
+
+func usageKey(day string, service string) []byte {
+	return []byte(day + "\x00" + service)
+}
+
+// AddCall counts a call to the service on the given day with its estimated cost.
+// This function returns the following errors:
+//  - ErrDatabaseError
+func (store UsageStore) AddCall(day string, service string, cost float64) error {
+	err := store.db.Update(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket(store.bucket)
+
+		var usage DailyUsage
+		if data := bucket.Get(usageKey(day, service)); data != nil {
+			err := json.Unmarshal(data, &usage)
+			if err != nil {
+				return err
+			}
+		}
+
+		usage.Calls++
+		usage.Cost += cost
+
+		data, err := json.Marshal(usage)
+		if err != nil {
+			return err
+		}
+
+		return bucket.Put(usageKey(day, service), data)
+	})
+	if err != nil {
+		log.Printf("Failed to save usage. %s.\n", err)
+		return ErrDatabaseError
+	}
+
+	return nil
+}
+
+// Spent returns the number of calls to the service and their estimated cost on the given day.
+// This function returns the following errors:
+//  - ErrDatabaseError
+func (store UsageStore) Spent(day string, service string) (int, float64, error) {
+	var usage DailyUsage
+
+	err := store.db.View(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket(store.bucket)
+		data := bucket.Get(usageKey(day, service))
+		if data == nil {
+			return nil
+		}
+
+		return json.Unmarshal(data, &usage)
+	})
+	if err != nil {
+		log.Printf("Failed to read usage. %s.\n", err)
+		return 0, 0, ErrDatabaseError
+	}
+
+	return usage.Calls, usage.Cost, nil
+}
+
+// PruneUsage removes the usage of the days before the given time and returns how many records were removed.
+// This function returns the following errors:
+//  - ErrDatabaseError
+func (store UsageStore) PruneUsage(before time.Time) (int, error) {
+	removed := 0
+	limit := []byte(before.UTC().Format(dayLayout))
+
+	err := store.db.Update(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket(store.bucket)
+
+		// The records are keyed by their day first, hence, the old ones come first.
+		var keys [][]byte
+		cursor := bucket.Cursor()
+		for key, _ := cursor.First(); key != nil && bytes.Compare(key, limit) < 0; key, _ = cursor.Next() {
+			keys = append(keys, append([]byte(nil), key...))
+		}
+
+		for _, key := range keys {
+			err := bucket.Delete(key)
+			if err != nil {
+				return err
+			}
+		}
+
+		removed = len(keys)
+		return nil
+	})
+	if err != nil {
+		log.Printf("Failed to prune usage. %s.\n", err)
+		return 0, ErrDatabaseError
+	}
+
+	return removed, nil
+}
@@ -0,0 +1,443 @@
+package main
+
+import (
+	"fmt"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+	"github.com/handracs2007/kquiz/card"
+	"github.com/handracs2007/kquiz/gradebook"
+	"github.com/handracs2007/kquiz/quiz"
+	"github.com/handracs2007/kquiz/storage"
+	"github.com/handracs2007/kquiz/telegram"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// templateStartPrefix prefixes the start parameter of the links applying a class template, the token follows.
+const templateStartPrefix = "tpl_"
+
+// classSettings returns the settings a teacher shares with the class, the review pushes, the quiet hours and the quiz
+// modes. The personal ones, e.g. the accepted privacy notice, are left out.
+func classSettings(settings telegram.Settings) telegram.Settings {
+	return telegram.Settings{
+		NoCombos:       settings.NoCombos,
+		Timezone:       settings.Timezone,
+		MorningReview:  settings.MorningReview,
+		EveningReview:  settings.EveningReview,
+		MorningReviews: settings.MorningReviews,
+		SilentPushes:   settings.SilentPushes,
+		QuietStart:     settings.QuietStart,
+		QuietEnd:       settings.QuietEnd,
+	}
+}
+
+// classEntries lists the vocabulary and the grammar patterns of the user in the deck, or all of them without a deck.
+func classEntries(lister storage.Lister, chatID int64, deck string) ([]telegram.Entry, error) {
+	entries, err := allEntries(lister, chatID)
+	if err == telegram.ErrWordNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	inDeck := make([]telegram.Entry, 0, len(entries))
+	for _, entry := range entries {
+		if len(deck) == 0 || entry.Deck == deck {
+			inDeck = append(inDeck, entry)
+		}
+	}
+
+	return inDeck, nil
+}
+
+// newTemplate shares the class settings of the user and the words of the deck, or all the words without a deck. The
+// students start their own reviews, only the words themselves are shared.
+func newTemplate(lister storage.Lister, settings telegram.Settings, chatID int64,
+	deck string) (telegram.Template, error) {
+	entries, err := classEntries(lister, chatID, deck)
+	if err != nil {
+		return telegram.Template{}, err
+	}
+
+	template := telegram.Template{Owner: chatID, Deck: deck, Settings: classSettings(settings), CreatedAt: time.Now()}
+	for _, entry := range entries {
+		template.Entries = append(template.Entries, telegram.Entry{Word: entry.Word, WordEntry: telegram.WordEntry{
+			Kind:        entry.Kind,
+			Translation: entry.Translation,
+			Example:     entry.Example,
+		}})
+	}
+
+	return template, nil
+}
+
+// parseAssignment parses the argument of /template, the deck followed by the rules of the assignment: fixed or
+// shuffle, attempts=<n> and due=<yyyy-mm-dd>. The deadline is the end of the day in the time zone of the teacher.
+func parseAssignment(argument string, location *time.Location) (string, telegram.Assignment, bool) {
+	var assignment telegram.Assignment
+	deck := make([]string, 0)
+	for _, field := range strings.Fields(argument) {
+		switch {
+		case field == telegram.OrderFixed || field == telegram.OrderShuffle:
+			assignment.Order = field
+
+		case strings.HasPrefix(field, "attempts="):
+			attempts, err := strconv.Atoi(strings.TrimPrefix(field, "attempts="))
+			if err != nil || attempts < 1 {
+				return "", assignment, false
+			}
+
+			assignment.Attempts = attempts
+
+		case strings.HasPrefix(field, "due="):
+			day, err := time.ParseInLocation("2006-01-02", strings.TrimPrefix(field, "due="), location)
+			if err != nil {
+				return "", assignment, false
+			}
+
+			deadline := day.AddDate(0, 0, 1).Add(-time.Second)
+			assignment.Deadline = &deadline
+
+		default:
+			deck = append(deck, field)
+		}
+	}
+
+	return strings.Join(deck, " "), assignment, true
+}
+
+// describeAssignment describes the rules of the assignment to the teacher and the students.
+func describeAssignment(assignment telegram.Assignment) string {
+	lines := make([]string, 0)
+	if assignment.Order == telegram.OrderShuffle {
+		lines = append(lines, "The questions are shuffled for each attempt.")
+	}
+	if assignment.Attempts > 0 {
+		lines = append(lines, fmt.Sprintf("%d attempts are allowed.", assignment.Attempts))
+	}
+	if assignment.Deadline != nil {
+		lines = append(lines, fmt.Sprintf("The deadline is %s.", assignment.Deadline.Format("2006-01-02 15:04 MST")))
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// createTemplate shares the class settings of the teacher and the words of the deck, or all the words without a deck,
+// as a link the students open to start with the same setup. The rules of the assignment follow the deck.
+func createTemplate(lister storage.Lister, settingsManager telegram.SettingsManager,
+	templateManager telegram.TemplateManager, chatID int64, botName string, argument string) tgbotapi.Chattable {
+	var msg tgbotapi.MessageConfig
+	settings, err := settingsManager.Settings(chatID)
+	if err != nil {
+		log.Printf("Failed to read settings, using the defaults. %s.\n", err)
+	}
+
+	deck, assignment, ok := parseAssignment(argument, settings.Location())
+	if !ok {
+		return tgbotapi.NewMessage(chatID, "Please provide the deck and the rules of the assignment, e.g. "+
+			"/template Week 3 shuffle attempts=2 due=2026-11-01.")
+	}
+
+	template, err := newTemplate(lister, settings, chatID, deck)
+	template.Assignment = assignment
+
+	if err != nil {
+		msg = tgbotapi.NewMessage(chatID, fmt.Sprintf("Create template failed. %s.", err))
+	} else if len(template.Entries) == 0 {
+		msg = tgbotapi.NewMessage(chatID, "There are no words to share, please add some words first.")
+	} else if token, err := templateManager.SaveTemplate(template); err != nil {
+		msg = tgbotapi.NewMessage(chatID, fmt.Sprintf("Create template failed. %s.", err))
+	} else {
+		msg = tgbotapi.NewMessage(chatID, strings.TrimSpace(fmt.Sprintf("Share this link with your class, the "+
+			"students opening it get your %d words and your review times:\nhttps://t.me/%s?start=%s%s\n%s",
+			len(template.Entries), botName, templateStartPrefix, token, describeAssignment(assignment))))
+	}
+
+	return msg
+}
+
+// shareCard shares the words of the deck, or all the words without a deck, as an image with the QR code of the link
+// of their template, to be shown on posters and slides. The text is rendered with the font given, ASCII only when nil.
+func shareCard(lister storage.Lister, settingsManager telegram.SettingsManager,
+	templateManager telegram.TemplateManager, chatID int64, botName string, deck string,
+	font []byte) tgbotapi.Chattable {
+	settings, err := settingsManager.Settings(chatID)
+	if err != nil {
+		log.Printf("Failed to read settings, using the defaults. %s.\n", err)
+	}
+
+	template, err := newTemplate(lister, settings, chatID, deck)
+	if err != nil {
+		return tgbotapi.NewMessage(chatID, fmt.Sprintf("Create card failed. %s.", err))
+	}
+
+	if len(template.Entries) == 0 {
+		return tgbotapi.NewMessage(chatID, "There are no words to share, please add some words first.")
+	}
+
+	token, err := templateManager.SaveTemplate(template)
+	if err != nil {
+		return tgbotapi.NewMessage(chatID, fmt.Sprintf("Create card failed. %s.", err))
+	}
+
+	title := deck
+	if len(title) == 0 {
+		title = "Korean vocabulary"
+	}
+
+	link := fmt.Sprintf("https://t.me/%s?start=%s%s", botName, templateStartPrefix, token)
+	image, err := card.Render(card.Card{
+		Title: title,
+		Lines: []string{fmt.Sprintf("%d words", len(template.Entries)), fmt.Sprintf("Scan to study with @%s", botName)},
+		Link:  link,
+	}, font)
+	if err != nil {
+		log.Printf("Failed to render card. %s.\n", err)
+		return tgbotapi.NewMessage(chatID, fmt.Sprintf("Create card failed. %s.", telegram.ErrDatabaseError))
+	}
+
+	photo := tgbotapi.NewPhotoUpload(chatID, tgbotapi.FileBytes{Name: "kquiz-card.png", Bytes: image})
+	photo.Caption = link
+
+	return photo
+}
+
+// scanCard reads the QR code of a share card in the photo and offers to import the words it shares, with a button
+// opening the link of their template. The largest size of the photo is scanned.
+func scanCard(templateManager telegram.TemplateManager, files fileLocator, chatID int64, botName string,
+	photos []tgbotapi.PhotoSize) tgbotapi.Chattable {
+	photo := photos[len(photos)-1]
+	if photo.FileSize > maxDownloadSize {
+		return tgbotapi.NewMessage(chatID, "The photo is too large to scan.")
+	}
+
+	data, err := downloadFile(files, photo.FileID)
+	if err != nil {
+		return tgbotapi.NewMessage(chatID, fmt.Sprintf("Scan photo failed. %s.", err))
+	}
+
+	text, err := card.Scan(data)
+	prefix := fmt.Sprintf("https://t.me/%s?start=%s", botName, templateStartPrefix)
+	if err != nil || len(text) <= len(prefix) || !strings.EqualFold(text[:len(prefix)], prefix) {
+		return tgbotapi.NewMessage(chatID, "No card of this bot was found in the photo, please send a sharp photo of "+
+			"its QR code.")
+	}
+
+	template, err := templateManager.Template(text[len(prefix):])
+	if err != nil {
+		return tgbotapi.NewMessage(chatID, fmt.Sprintf("Scan photo failed. %s.", err))
+	}
+
+	name := template.Deck
+	if len(name) == 0 {
+		name = "Class"
+	}
+
+	msg := tgbotapi.NewMessage(chatID, fmt.Sprintf("This card shares %d words, they will be added to the deck %s "+
+		"together with the review times of the class.", len(template.Entries), name))
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonURL("Import", text)))
+	return msg
+}
+
+// applyTemplate applies the class template of the token to the settings of the student and adds its words to a new
+// deck, it returns the reply and the words conflicting with those of the student. The personal settings of the
+// student are kept.
+func applyTemplate(templateManager telegram.TemplateManager, adder storage.Adder, searcher storage.Searcher,
+	deckManager telegram.DeckManager, settingsManager telegram.SettingsManager, chatID int64,
+	token string) (tgbotapi.Chattable, []translationConflict) {
+	failed := func(err error) tgbotapi.Chattable {
+		return tgbotapi.NewMessage(chatID, fmt.Sprintf("Apply template failed. %s.", err))
+	}
+
+	template, err := templateManager.Template(token)
+	if err != nil {
+		return failed(err), nil
+	}
+
+	settings, err := settingsManager.Settings(chatID)
+	if err != nil {
+		return failed(err), nil
+	}
+
+	class := template.Settings
+	settings.NoCombos = class.NoCombos
+	settings.Timezone = class.Timezone
+	settings.MorningReview = class.MorningReview
+	settings.EveningReview = class.EveningReview
+	settings.MorningReviews = class.MorningReviews
+	settings.SilentPushes = class.SilentPushes
+	settings.QuietStart = class.QuietStart
+	settings.QuietEnd = class.QuietEnd
+
+	name := template.Deck
+	if len(name) == 0 {
+		name = "Class"
+	}
+
+	deck, err := deckManager.CreateDeck(chatID, telegram.Deck{Name: name, Title: name, ImportedAt: time.Now()})
+	if err != nil {
+		return failed(err), nil
+	}
+
+	// The words of the deck make the assignment of the class.
+	settings.ClassTemplate = token
+	settings.ClassDeck = deck
+	settings.ClassCompletedAt = nil
+	settings.ClassAttempts = 0
+
+	err = settingsManager.SaveSettings(chatID, settings)
+	if err != nil {
+		return failed(err), nil
+	}
+
+	added := 0
+	conflicts := make([]translationConflict, 0)
+	for _, entry := range template.Entries {
+		entry.Deck = deck
+		entry.Origin = telegram.NewOrigin(telegram.OriginDeck, token)
+		err = adder.AddEntry(chatID, entry.Word, entry.WordEntry)
+		if err == nil {
+			added++
+		} else if err == telegram.ErrDuplicateWord {
+			if conflict, ok := findConflict(searcher, chatID, entry.Word, entry.Translation); ok {
+				conflicts = append(conflicts, conflict)
+			}
+		}
+	}
+
+	lines := []string{fmt.Sprintf("Your class setup is ready, %d words are in the deck %s.", added, deck)}
+	if len(settings.MorningReview) != 0 && len(settings.EveningReview) != 0 {
+		lines = append(lines, fmt.Sprintf("Your reviews are at %s and %s.", settings.MorningReview,
+			settings.EveningReview))
+	}
+	if skipped := len(template.Entries) - added - len(conflicts); skipped > 0 {
+		lines = append(lines, fmt.Sprintf("%d words were skipped, you may have them already.", skipped))
+	}
+	if len(conflicts) != 0 {
+		lines = append(lines, fmt.Sprintf("%d words you already have with another translation, please review them.",
+			len(conflicts)))
+	}
+	lines = append(lines, "Take the assignment with /assignment.")
+	if rules := describeAssignment(template.Assignment); len(rules) != 0 {
+		lines = append(lines, rules)
+	}
+
+	return tgbotapi.NewMessage(chatID, strings.Join(lines, "\n")), conflicts
+}
+
+// startAssignment queues the questions of the class assignment into the session, counting the attempt, it returns
+// the reply and whether there is any.
+func startAssignment(lister storage.Lister, settingsManager telegram.SettingsManager,
+	templateManager telegram.TemplateManager, engine *quiz.Engine, chatID int64,
+	session *quiz.Session) (tgbotapi.Chattable, bool) {
+	settings, err := settingsManager.Settings(chatID)
+	if err != nil {
+		return tgbotapi.NewMessage(chatID, fmt.Sprintf("Start assignment failed. %s.", err)), false
+	}
+
+	if len(settings.ClassTemplate) == 0 {
+		return tgbotapi.NewMessage(chatID, "You have no class assignment, please open the link shared by your "+
+			"teacher first."), false
+	}
+
+	template, err := templateManager.Template(settings.ClassTemplate)
+	if err != nil {
+		return tgbotapi.NewMessage(chatID, fmt.Sprintf("Start assignment failed. %s.", err)), false
+	}
+
+	entries, err := classEntries(lister, chatID, settings.ClassDeck)
+	if err != nil {
+		return tgbotapi.NewMessage(chatID, fmt.Sprintf("Start assignment failed. %s.", err)), false
+	}
+
+	queue, err := engine.StartAssignment(template, settings.ClassAttempts, entries, time.Now())
+	if err != nil {
+		return tgbotapi.NewMessage(chatID, fmt.Sprintf("Start assignment failed, %s.", err)), false
+	}
+
+	if len(queue) == 0 {
+		return tgbotapi.NewMessage(chatID, "There are no words left in the deck of your assignment."), false
+	}
+
+	settings.ClassAttempts++
+	err = settingsManager.SaveSettings(chatID, settings)
+	if err != nil {
+		return tgbotapi.NewMessage(chatID, fmt.Sprintf("Start assignment failed. %s.", err)), false
+	}
+
+	session.Queue = queue
+	session.Assignment = true
+	if template.Assignment.Deadline != nil {
+		session.Deadline = *template.Assignment.Deadline
+	}
+
+	text := fmt.Sprintf("Let's start the assignment, %d questions.", len(queue))
+	if template.Assignment.Attempts > 0 {
+		text = fmt.Sprintf("Let's start attempt %d of %d of the assignment, %d questions.", settings.ClassAttempts,
+			template.Assignment.Attempts, len(queue))
+	}
+
+	return tgbotapi.NewMessage(chatID, text), true
+}
+
+// completeAssignment reports the class assignment of the student once every word of the class deck is reviewed. Each
+// template applied is reported once, in the background so that a slow gradebook does not hold the quiz up. It returns
+// the congratulations to the student once the assignment is complete, otherwise nil.
+func completeAssignment(lister storage.Lister, settingsManager telegram.SettingsManager,
+	templateManager telegram.TemplateManager, reporter gradebook.Reporter, chatID int64, name string,
+	now time.Time) tgbotapi.Chattable {
+	settings, err := settingsManager.Settings(chatID)
+	if err != nil || len(settings.ClassDeck) == 0 || settings.ClassCompletedAt != nil {
+		return nil
+	}
+
+	entries, err := classEntries(lister, chatID, settings.ClassDeck)
+	if err != nil {
+		log.Printf("Failed to list the words of the assignment. %s.\n", err)
+		return nil
+	}
+
+	for _, entry := range entries {
+		if entry.LastReviewed == nil {
+			return nil
+		}
+	}
+
+	// The student may have deleted the words of the class.
+	words := len(entries)
+	if words == 0 {
+		return nil
+	}
+
+	template, err := templateManager.Template(settings.ClassTemplate)
+	if err != nil {
+		log.Printf("Failed to read the template of the assignment. %s.\n", err)
+		return nil
+	}
+
+	settings.ClassCompletedAt = &now
+	err = settingsManager.SaveSettings(chatID, settings)
+	if err != nil {
+		log.Printf("Failed to save the completion of the assignment. %s.\n", err)
+		return nil
+	}
+
+	completion := gradebook.Completion{
+		Template:    settings.ClassTemplate,
+		Teacher:     template.Owner,
+		Student:     chatID,
+		Name:        name,
+		Deck:        settings.ClassDeck,
+		Words:       words,
+		CompletedAt: now,
+	}
+	go func() {
+		_ = reporter.Report(completion)
+	}()
+
+	return tgbotapi.NewMessage(chatID, fmt.Sprintf("Assignment complete, you reviewed all %d words of the deck %s!",
+		words, settings.ClassDeck))
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+	"github.com/handracs2007/kquiz/telegram"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultTranscriptPeriod is the period of the transcript when none is given.
+const defaultTranscriptPeriod = 7 * 24 * time.Hour
+
+// parsePeriod parses the period of a transcript, a number of days such as 7d or a duration such as 12h.
+func parsePeriod(argument string) (time.Duration, bool) {
+	if len(argument) == 0 {
+		return defaultTranscriptPeriod, true
+	}
+
+	if strings.HasSuffix(argument, "d") {
+		days, err := strconv.Atoi(strings.TrimSuffix(argument, "d"))
+		if err != nil || days < 1 {
+			return 0, false
+		}
+
+		return time.Duration(days) * 24 * time.Hour, true
+	}
+
+	period, err := time.ParseDuration(argument)
+	if err != nil || period <= 0 {
+		return 0, false
+	}
+
+	return period, true
+}
+
+// encodeTranscript writes the lines of the transcript as CSV with a header, the times in the time zone given.
+func encodeTranscript(lines []telegram.TranscriptLine, location *time.Location) ([]byte, error) {
+	var buffer bytes.Buffer
+	writer := csv.NewWriter(&buffer)
+
+	err := writer.Write([]string{"time", "question", "expected", "answer", "correct"})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, line := range lines {
+		err = writer.Write([]string{line.At.In(location).Format("2006-01-02 15:04"), line.Prompt, line.Expected,
+			line.Answer, strconv.FormatBool(line.Correct)})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return nil, err
+	}
+
+	return buffer.Bytes(), nil
+}
+
+// sendTranscript sends the questions the owner answered over the period, with the answers given, as a CSV file. The
+// owner is the user or a student who granted the user access.
+func sendTranscript(transcriber telegram.Transcriber, manager telegram.SettingsManager, chatID int64,
+	owner int64, period time.Duration) tgbotapi.Chattable {
+	var msg tgbotapi.Chattable
+	settings, err := manager.Settings(owner)
+	if err != nil {
+		log.Printf("Failed to read settings, using the defaults. %s.\n", err)
+	}
+
+	lines, err := transcriber.Transcript(owner, time.Now().Add(-period))
+	if err != nil {
+		msg = tgbotapi.NewMessage(chatID, fmt.Sprintf("Get transcript failed. %s.", err))
+	} else if len(lines) == 0 {
+		msg = tgbotapi.NewMessage(chatID, "No quiz was answered over the period.")
+	} else {
+		data, err := encodeTranscript(lines, settings.Location())
+		if err != nil {
+			log.Printf("Failed to encode transcript. %s.\n", err)
+			msg = tgbotapi.NewMessage(chatID, fmt.Sprintf("Get transcript failed. %s.", telegram.ErrDatabaseError))
+		} else {
+			mistakes := 0
+			for _, line := range lines {
+				if !line.Correct {
+					mistakes++
+				}
+			}
+
+			document := tgbotapi.NewDocumentUpload(chatID, tgbotapi.FileBytes{Name: "kquiz-transcript.csv",
+				Bytes: data})
+			document.Caption = fmt.Sprintf("%d answers, %d mistakes.", len(lines), mistakes)
+			msg = document
+		}
+	}
+
+	return msg
+}
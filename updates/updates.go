@@ -0,0 +1,39 @@
+// Package updates holds the updates the bot receives from Telegram, extending the ones of the Telegram client with
+// the kinds it predates, e.g. the reactions. The poller and the webhook receive them, the dispatcher handles them.
+package updates
+
+import (
+	"encoding/json"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+// AllowedUpdates are the kinds of updates the bot asks Telegram for. The reactions are only delivered when asked for.
+var AllowedUpdates = []string{"message", "callback_query", "message_reaction"}
+
+// AllowedUpdatesParam returns AllowedUpdates encoded as the allowed_updates parameter of the requests.
+func AllowedUpdatesParam() string {
+	data, _ := json.Marshal(AllowedUpdates)
+	return string(data)
+}
+
+// Update represents an incoming update, extending the update of the Telegram client with the kinds it predates.
+type Update struct {
+	tgbotapi.Update
+	MessageReaction *MessageReaction `json:"message_reaction"`
+}
+
+// MessageReaction represents a change of the reactions of a user to a message.
+type MessageReaction struct {
+	Chat        tgbotapi.Chat  `json:"chat"`
+	MessageID   int            `json:"message_id"`
+	User        *tgbotapi.User `json:"user"`
+	Date        int            `json:"date"`
+	OldReaction []ReactionType `json:"old_reaction"`
+	NewReaction []ReactionType `json:"new_reaction"`
+}
+
+// ReactionType represents a reaction, only the emoji reactions have an emoji.
+type ReactionType struct {
+	Type  string `json:"type"`
+	Emoji string `json:"emoji,omitempty"`
+}
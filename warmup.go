@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+	"github.com/handracs2007/kquiz/quiz"
+	"github.com/handracs2007/kquiz/telegram"
+	"log"
+)
+
+// warmUp queues the well-known words the session of the user starts with, as many as the user asked for. The session
+// of a tag warms up with the words of the tag.
+func (d *dispatcher) warmUp(chatID int64, session *quiz.Session) {
+	settings, err := d.settingsStore.Settings(chatID)
+	if err != nil || settings.WarmUp <= 0 {
+		return
+	}
+
+	entries, err := userEntries(d.words, chatID)
+	if err != nil {
+		log.Printf("Failed to list words for warm-up. %s.\n", err)
+		return
+	}
+
+	// The warm-up of a quiz session counts towards its questions, some are left for the hard material.
+	count := settings.WarmUp
+	if session.Questions > 0 && count >= session.Questions {
+		count = session.Questions - 1
+	}
+
+	session.WarmUp = d.quizEngine.WarmUp(telegram.Tagged(entries, session.Tag), count, session.Queue)
+}
+
+// askWarmUp asks the next warm-up word of the session, it returns the question message and false once the session is
+// warmed up.
+func (d *dispatcher) askWarmUp(chatID int64, session *quiz.Session) (tgbotapi.Chattable, bool) {
+	entry, ok := session.NextWarmUp()
+	if !ok {
+		return nil, false
+	}
+
+	settings, err := d.settingsStore.Settings(chatID)
+	if err != nil {
+		log.Printf("Failed to read settings, using the defaults. %s.\n", err)
+	}
+
+	question := quiz.ForUser(entry, settings)
+	d.setPending(chatID, question)
+
+	return promptMessage(chatID, question.Prompt, settings, d.font, false), true
+}
+
+// setWarmUp sets the number of well-known words starting the sessions, zero for none.
+func setWarmUp(manager telegram.SettingsManager, chatID int64, count int) tgbotapi.Chattable {
+	var msg tgbotapi.MessageConfig
+	settings, err := manager.Settings(chatID)
+	if err == nil {
+		settings.WarmUp = count
+		err = manager.SaveSettings(chatID, settings)
+	}
+
+	if err != nil {
+		msg = tgbotapi.NewMessage(chatID, fmt.Sprintf("Save settings failed. %s.", err))
+	} else if count == 0 {
+		msg = tgbotapi.NewMessage(chatID, "The sessions start straight with the hard material.")
+	} else {
+		msg = tgbotapi.NewMessage(chatID, fmt.Sprintf("The sessions start with %d well-known words to warm up.",
+			count))
+	}
+
+	return msg
+}
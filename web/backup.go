@@ -0,0 +1,81 @@
+package web
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"go.etcd.io/bbolt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/handracs2007/kquiz/telegram"
+)
+
+// ShardCountHeader is the response header telling the backup clients how many shards there are to download.
+const ShardCountHeader = "X-Kquiz-Shards"
+
+// BackupHandler streams a consistent snapshot of a database shard while the bot keeps serving. The requests must
+// present the admin token as a bearer token. The snapshots are refused when the words are kept in SQLite, they would be
+// missing from them, the backups to a target include the SQLite database.
+type BackupHandler struct {
+	shards telegram.Shards
+	token  string
+	sqlite bool
+}
+
+// NewBackupHandler creates a new instance of BackupHandler
+func NewBackupHandler(shards telegram.Shards, token string, sqlite bool) BackupHandler {
+	return BackupHandler{shards: shards, token: token, sqlite: sqlite}
+}
+
+// authorized reports whether the request presents the admin token as a bearer token. Nothing is authorized without an
+// admin token.
+func authorized(r *http.Request, adminToken string) bool {
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return len(adminToken) != 0 && subtle.ConstantTimeCompare([]byte(token), []byte(adminToken)) == 1
+}
+
+func (h BackupHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !authorized(r, h.token) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if h.sqlite {
+		http.Error(w, "the words are kept in SQLite, use the backups to a directory or an endpoint instead",
+			http.StatusConflict)
+		return
+	}
+
+	shard := 0
+	if value := r.URL.Query().Get("shard"); len(value) != 0 {
+		var err error
+		shard, err = strconv.Atoi(value)
+		if err != nil || shard < 0 || shard >= len(h.shards) {
+			http.Error(w, "invalid shard", http.StatusBadRequest)
+			return
+		}
+	}
+
+	// A read transaction sees a consistent snapshot and does not block the writers.
+	err := h.shards[shard].View(func(tx *bbolt.Tx) error {
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`,
+			telegram.ShardPath("kquiz.db", shard)))
+		w.Header().Set("Content-Length", strconv.FormatInt(tx.Size(), 10))
+		w.Header().Set(ShardCountHeader, strconv.Itoa(len(h.shards)))
+
+		_, err := tx.WriteTo(w)
+		return err
+	})
+	if err != nil {
+		// The headers are already sent, the client notices the truncated body from the content length.
+		log.Printf("Failed to stream backup of shard %d. %s.\n", shard, err)
+	}
+}
@@ -0,0 +1,79 @@
+package web
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"strings"
+)
+
+var adminClient = &http.Client{Timeout: 30 * time.Minute}
+
+// adminRequest sends a request to an admin endpoint of the kquiz HTTP server at the base URL.
+func adminRequest(method string, baseURL string, path string, token string) (*http.Response, error) {
+	req, err := http.NewRequest(method, baseURL+path, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := adminClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		// The error pages are short, the reason is on their first line.
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		resp.Body.Close()
+		return nil, fmt.Errorf("server responded %s. %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	return resp, nil
+}
+
+// DownloadBackup saves the snapshot of the shard served by the kquiz HTTP server at the base URL into the file and
+// returns the number of shards and the size of the snapshot. The file is only replaced once the snapshot is complete.
+func DownloadBackup(baseURL string, token string, shard int, path string) (int, int64, error) {
+	resp, err := adminRequest(http.MethodGet, baseURL, fmt.Sprintf("/admin/backup?shard=%d", shard), token)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer resp.Body.Close()
+
+	count, err := strconv.Atoi(resp.Header.Get(ShardCountHeader))
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid shard count. %s", err)
+	}
+
+	// Let's write to a temporary file first so that a broken download never replaces a good backup.
+	file, err := os.Create(path + ".part")
+	if err != nil {
+		return 0, 0, err
+	}
+
+	written, err := io.Copy(file, resp.Body)
+	closeErr := file.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err == nil && resp.ContentLength >= 0 && written != resp.ContentLength {
+		err = fmt.Errorf("truncated snapshot, %d of %d bytes", written, resp.ContentLength)
+	}
+	if err != nil {
+		_ = os.Remove(path + ".part")
+		return 0, 0, err
+	}
+
+	err = os.Rename(path+".part", path)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return count, written, nil
+}
@@ -0,0 +1,132 @@
+package web
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/handracs2007/kquiz/scheduler"
+)
+
+// dashboardRefresh is how often the dashboard page reloads itself, in seconds.
+const dashboardRefresh = 15
+
+// Queue is a queue of work the bot has yet to do, e.g. the messages waiting for the rate limits.
+type Queue struct {
+	Name  string
+	Depth int
+}
+
+// Dashboard holds the live metrics shown to the operators.
+type Dashboard struct {
+	At                time.Time
+	ActiveUsers       int
+	CommandsPerMinute float64
+	ErrorRate         float64
+	Queues            []Queue
+	NextRun           time.Time
+	Jobs              []scheduler.JobStatus
+	// Backups tells whether the backups are configured, LastBackup is the zero time until one is taken.
+	Backups    bool
+	LastBackup time.Time
+}
+
+var dashboardTemplate = template.Must(template.New("dashboard").Funcs(template.FuncMap{
+	"time": func(at time.Time) string {
+		if at.IsZero() {
+			return "never"
+		}
+
+		return at.UTC().Format("2006-01-02 15:04:05 UTC")
+	},
+	"percent": func(rate float64) string {
+		return fmt.Sprintf("%.1f%%", rate*100)
+	},
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta http-equiv="refresh" content="{{.Refresh}}">
+<title>kquiz dashboard</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; margin-bottom: 2em; }
+th, td { border: 1px solid #ccc; padding: 0.3em 0.8em; text-align: left; }
+</style>
+</head>
+<body>
+<h1>kquiz</h1>
+<p>As of {{time .At}}, refreshed every {{.Refresh}} seconds.</p>
+<table>
+<tr><th>Active users today</th><td>{{.ActiveUsers}}</td></tr>
+<tr><th>Commands per minute</th><td>{{printf "%.1f" .CommandsPerMinute}}</td></tr>
+<tr><th>Telegram error rate, last hour</th><td>{{percent .ErrorRate}}</td></tr>
+<tr><th>Last backup</th><td>{{if .Backups}}{{time .LastBackup}}{{else}}not configured{{end}}</td></tr>
+</table>
+<h2>Queues</h2>
+<table>
+<tr><th>Queue</th><th>Depth</th></tr>
+{{range .Queues}}<tr><td>{{.Name}}</td><td>{{.Depth}}</td></tr>
+{{end}}</table>
+<h2>Scheduler</h2>
+<p>The jobs run next at {{time .NextRun}}.</p>
+<table>
+<tr><th>Job</th><th>Last run</th><th>Duration</th></tr>
+{{range .Jobs}}<tr><td>{{.Name}}</td><td>{{time .LastRun}}</td><td>{{.Duration}}</td></tr>
+{{end}}</table>
+</body>
+</html>
+`))
+
+// DashboardHandler shows the live metrics to the operators on a web page. The requests must present the admin token,
+// as a bearer token or as the password of the basic authentication for the browsers.
+type DashboardHandler struct {
+	token    string
+	snapshot func(now time.Time) Dashboard
+}
+
+// NewDashboardHandler creates a new instance of DashboardHandler. The snapshot function returns the metrics at the
+// given time.
+func NewDashboardHandler(token string, snapshot func(now time.Time) Dashboard) DashboardHandler {
+	return DashboardHandler{token: token, snapshot: snapshot}
+}
+
+// dashboardAuthorized reports whether the request presents the admin token, as a bearer token or as the password of
+// the basic authentication.
+func dashboardAuthorized(r *http.Request, adminToken string) bool {
+	if authorized(r, adminToken) {
+		return true
+	}
+
+	_, password, ok := r.BasicAuth()
+	return ok && len(adminToken) != 0 && subtle.ConstantTimeCompare([]byte(password), []byte(adminToken)) == 1
+}
+
+func (h DashboardHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !dashboardAuthorized(r, h.token) {
+		// The browsers ask for the token as a password.
+		w.Header().Set("WWW-Authenticate", `Basic realm="kquiz admin"`)
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	page := struct {
+		Dashboard
+		Refresh int
+	}{Dashboard: h.snapshot(time.Now()), Refresh: dashboardRefresh}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	err := dashboardTemplate.Execute(w, page)
+	if err != nil {
+		log.Printf("Failed to write dashboard. %s.\n", err)
+	}
+}
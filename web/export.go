@@ -0,0 +1,86 @@
+package web
+
+import (
+	"html/template"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/handracs2007/kquiz/storage"
+	"github.com/handracs2007/kquiz/telegram"
+)
+
+var exportTemplate = template.Must(template.New("export").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="robots" content="noindex">
+<title>kquiz words</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #ccc; padding: 4px 12px; text-align: left; }
+</style>
+</head>
+<body>
+<p><button onclick="copyTable()">Copy table</button> Paste it into Google Sheets or any spreadsheet.</p>
+<table id="words">
+<tr><th>Word</th><th>Translation</th></tr>
+{{range .}}<tr><td>{{index . 0}}</td><td>{{index . 1}}</td></tr>
+{{end}}</table>
+<script>
+function copyTable() {
+  var rows = Array.prototype.map.call(document.querySelectorAll("#words tr"), function (row) {
+    return Array.prototype.map.call(row.cells, function (cell) { return cell.innerText; }).join("\t");
+  });
+  navigator.clipboard.writeText(rows.join("\n"));
+}
+</script>
+</body>
+</html>
+`))
+
+// ExportHandler renders the words of the user owning the export link as a copyable table.
+type ExportHandler struct {
+	linker telegram.ExportLinker
+	lister storage.Lister
+}
+
+// NewExportHandler creates a new instance of ExportHandler
+func NewExportHandler(linker telegram.ExportLinker, lister storage.Lister) ExportHandler {
+	return ExportHandler{linker: linker, lister: lister}
+}
+
+func (h ExportHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	token := strings.TrimPrefix(r.URL.Path, "/export/")
+
+	chatID, err := h.linker.ResolveLink(token)
+	if err != nil {
+		if err == telegram.ErrLinkNotFound {
+			http.NotFound(w, r)
+		} else {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+
+		return
+	}
+
+	words, err := h.lister.List(chatID)
+	if err != nil && err != telegram.ErrWordNotFound {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+
+	err = exportTemplate.Execute(w, words)
+	if err != nil {
+		log.Printf("Failed to render export page. %s.\n", err)
+	}
+}
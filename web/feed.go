@@ -0,0 +1,106 @@
+package web
+
+import (
+	"encoding/xml"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/handracs2007/kquiz/publish"
+	"github.com/handracs2007/kquiz/telegram"
+)
+
+// feedItems is the number of the latest posts in the feed.
+const feedItems = 30
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+type rssItem struct {
+	Title       string  `xml:"title"`
+	Description string  `xml:"description"`
+	PubDate     string  `xml:"pubDate"`
+	GUID        rssGUID `xml:"guid"`
+}
+
+type rssChannel struct {
+	Title       string    `xml:"title"`
+	Link        string    `xml:"link"`
+	Description string    `xml:"description"`
+	Language    string    `xml:"language"`
+	Items       []rssItem `xml:"item"`
+}
+
+type rss struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+// FeedHandler syndicates the words of the day published to the channel as an RSS feed.
+type FeedHandler struct {
+	manager   telegram.ChannelManager
+	publicURL string
+}
+
+// NewFeedHandler creates a new instance of FeedHandler
+func NewFeedHandler(manager telegram.ChannelManager, publicURL string) FeedHandler {
+	return FeedHandler{manager: manager, publicURL: strings.TrimSuffix(publicURL, "/")}
+}
+
+func (h FeedHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	channel, err := h.manager.Channel()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	posts, err := h.manager.Posts(feedItems)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Readers link to the channel itself when it is a public one.
+	link := h.publicURL + r.URL.Path
+	if channel != nil && strings.HasPrefix(channel.Chat, "@") {
+		link = "https://t.me/" + strings.TrimPrefix(channel.Chat, "@")
+	}
+
+	feed := rss{Version: "2.0", Channel: rssChannel{
+		Title:       "kquiz word of the day",
+		Link:        link,
+		Description: "A Korean word a day, with its translation and an example.",
+		Language:    "ko",
+		Items:       make([]rssItem, 0, len(posts)),
+	}}
+
+	for _, post := range posts {
+		feed.Channel.Items = append(feed.Channel.Items, rssItem{
+			Title:       post.Word,
+			Description: publish.Text(post),
+			PubDate:     post.PublishedAt.UTC().Format(time.RFC1123Z),
+			GUID:        rssGUID{Value: h.publicURL + r.URL.Path + "#" + post.Day},
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/rss+xml; charset=utf-8")
+
+	_, err = w.Write([]byte(xml.Header))
+	if err == nil {
+		encoder := xml.NewEncoder(w)
+		encoder.Indent("", "  ")
+		err = encoder.Encode(feed)
+	}
+	if err != nil {
+		log.Printf("Failed to render feed. %s.\n", err)
+	}
+}
@@ -0,0 +1,67 @@
+package web
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"sync"
+)
+
+type handoffResponse struct {
+	Offset int `json:"offset"`
+}
+
+// HandoffHandler drains the bot when a new instance takes over and hands it the offset of the next Telegram update.
+type HandoffHandler struct {
+	token  string
+	drain  func() int
+	once   *sync.Once
+	offset *int
+}
+
+// NewHandoffHandler creates a new instance of HandoffHandler. The drain function must stop receiving updates, wait
+// for those being processed and return the offset of the next update. It is called once, the later requests get the
+// same offset.
+func NewHandoffHandler(token string, drain func() int) HandoffHandler {
+	return HandoffHandler{token: token, drain: drain, once: &sync.Once{}, offset: new(int)}
+}
+
+func (h HandoffHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !authorized(r, h.token) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	h.once.Do(func() {
+		*h.offset = h.drain()
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	err := json.NewEncoder(w).Encode(handoffResponse{Offset: *h.offset})
+	if err != nil {
+		log.Printf("Failed to respond to handoff request. %s.\n", err)
+	}
+}
+
+// RequestHandoff asks the instance at the base URL to stop processing updates and returns the offset of the next
+// update it has not processed.
+func RequestHandoff(baseURL string, token string) (int, error) {
+	resp, err := adminRequest(http.MethodPost, baseURL, "/admin/handoff", token)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	var response handoffResponse
+	err = json.NewDecoder(resp.Body).Decode(&response)
+	if err != nil {
+		return 0, err
+	}
+
+	return response.Offset, nil
+}
@@ -0,0 +1,44 @@
+package web
+
+import (
+	"net/http"
+	"sync"
+)
+
+// PromoteHandler turns a standby instance into the primary when an admin asks for it.
+type PromoteHandler struct {
+	token   string
+	promote func()
+	once    *sync.Once
+}
+
+// NewPromoteHandler creates a new instance of PromoteHandler. The promote function is called once, whatever the number
+// of requests.
+func NewPromoteHandler(token string, promote func()) PromoteHandler {
+	return PromoteHandler{token: token, promote: promote, once: &sync.Once{}}
+}
+
+func (h PromoteHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !authorized(r, h.token) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	h.once.Do(h.promote)
+	w.WriteHeader(http.StatusOK)
+}
+
+// Promote asks the standby instance at the base URL to take over as the primary.
+func Promote(baseURL string, token string) error {
+	resp, err := adminRequest(http.MethodPost, baseURL, "/admin/promote", token)
+	if err != nil {
+		return err
+	}
+
+	return resp.Body.Close()
+}
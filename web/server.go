@@ -0,0 +1,68 @@
+package web
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+)
+
+// Server serves the HTTP endpoints of kquiz.
+type Server struct {
+	server *http.Server
+	mux    *http.ServeMux
+}
+
+// NewServer creates a new instance of Server listening on the given address.
+func NewServer(addr string) *Server {
+	mux := http.NewServeMux()
+
+	return &Server{
+		mux: mux,
+		server: &http.Server{
+			Addr:              addr,
+			Handler:           mux,
+			ReadHeaderTimeout: 10 * time.Second,
+		},
+	}
+}
+
+// Handle registers the handler for the given pattern.
+func (s *Server) Handle(pattern string, handler http.Handler) {
+	s.mux.Handle(pattern, handler)
+}
+
+// Start starts listening in the background.
+func (s *Server) Start() {
+	go func() {
+		log.Printf("HTTP server listening on %s.\n", s.server.Addr)
+
+		err := s.server.ListenAndServe()
+		if err != nil && err != http.ErrServerClosed {
+			log.Printf("HTTP server stopped. %s.\n", err)
+		}
+	}()
+}
+
+// StartTLS starts listening for HTTPS in the background, with the given certificate and private key files.
+func (s *Server) StartTLS(certFile string, keyFile string) {
+	go func() {
+		log.Printf("HTTPS server listening on %s.\n", s.server.Addr)
+
+		err := s.server.ListenAndServeTLS(certFile, keyFile)
+		if err != nil && err != http.ErrServerClosed {
+			log.Printf("HTTPS server stopped. %s.\n", err)
+		}
+	}()
+}
+
+// Shutdown stops the server, waiting for the active requests to finish up to the given timeout.
+func (s *Server) Shutdown(timeout time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	err := s.server.Shutdown(ctx)
+	if err != nil {
+		log.Printf("Failed to shut down HTTP server. %s.\n", err)
+	}
+}
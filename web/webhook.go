@@ -0,0 +1,89 @@
+package web
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+
+	"github.com/handracs2007/kquiz/updates"
+)
+
+// maxUpdateSize limits the size of the updates posted to the webhook.
+const maxUpdateSize = 1 << 20
+
+// WebhookHandler receives the Telegram updates posted to the webhook. An update is acknowledged once it is received
+// from the updates channel, Telegram delivers it again otherwise.
+type WebhookHandler struct {
+	updates chan updates.Update
+	stop    chan struct{}
+	mutex   *sync.Mutex
+	closed  *bool
+	pending *sync.WaitGroup
+}
+
+// NewWebhookHandler creates a new instance of WebhookHandler
+func NewWebhookHandler() WebhookHandler {
+	return WebhookHandler{
+		updates: make(chan updates.Update),
+		stop:    make(chan struct{}),
+		mutex:   &sync.Mutex{},
+		closed:  new(bool),
+		pending: &sync.WaitGroup{},
+	}
+}
+
+// Updates returns the channel of the received updates, closed once the handler is closed.
+func (h WebhookHandler) Updates() <-chan updates.Update {
+	return h.updates
+}
+
+// Close stops receiving updates, the updates not received yet are refused so that Telegram delivers them again later.
+func (h WebhookHandler) Close() {
+	h.mutex.Lock()
+	if *h.closed {
+		h.mutex.Unlock()
+		return
+	}
+
+	*h.closed = true
+	close(h.stop)
+	h.mutex.Unlock()
+
+	h.pending.Wait()
+	close(h.updates)
+}
+
+func (h WebhookHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var update updates.Update
+	err := json.NewDecoder(io.LimitReader(r.Body, maxUpdateSize)).Decode(&update)
+	if err != nil {
+		log.Printf("Failed to decode webhook update. %s.\n", err)
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+
+	h.mutex.Lock()
+	if *h.closed {
+		h.mutex.Unlock()
+		http.Error(w, "shutting down", http.StatusServiceUnavailable)
+		return
+	}
+	h.pending.Add(1)
+	h.mutex.Unlock()
+	defer h.pending.Done()
+
+	select {
+	case h.updates <- update:
+		w.WriteHeader(http.StatusOK)
+	case <-h.stop:
+		http.Error(w, "shutting down", http.StatusServiceUnavailable)
+	case <-r.Context().Done():
+	}
+}
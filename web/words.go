@@ -0,0 +1,254 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/handracs2007/kquiz/storage"
+	"github.com/handracs2007/kquiz/telegram"
+	"golang.org/x/text/unicode/norm"
+)
+
+// maxWordsBody is the largest body accepted by WordsHandler, a word is a few hundred bytes at most.
+const maxWordsBody = 64 << 10
+
+// maxSourceLength is the longest source URL kept with a word.
+const maxSourceLength = 2048
+
+// The number of words listed by WordsHandler when no limit is given, and the most it lists at once.
+const (
+	defaultListLimit = 20
+	maxListLimit     = 100
+)
+
+// listedWord is a word listed by WordsHandler.
+type listedWord struct {
+	Word        string   `json:"word"`
+	Translation string   `json:"translation"`
+	Tags        []string `json:"tags,omitempty"`
+	Source      string   `json:"source,omitempty"`
+	Origin      string   `json:"origin,omitempty"`
+}
+
+// incomingWord is the word posted to WordsHandler. The tags are separated by commas or spaces, the # is optional. The
+// source is the URL of the page the word was captured from, if any.
+type incomingWord struct {
+	Word        string `json:"word"`
+	Translation string `json:"translation"`
+	Tags        string `json:"tags"`
+	Source      string `json:"source"`
+}
+
+// WordsHandler adds the words posted by other services, e.g. Zapier, IFTTT or the browser extension, to the words of
+// the user owning the API token, and lists them a page at a time with the offset and limit query parameters. The
+// requests must present the token as a bearer token, and post the word as JSON or as a form.
+//
+// The words already added are not added again, the response tells they are duplicates with the entry kept, so that
+// capturing the same word twice is harmless.
+type WordsHandler struct {
+	tokens   telegram.TokenIssuer
+	adder    storage.Adder
+	searcher storage.Searcher
+	lister   storage.Lister
+}
+
+// NewWordsHandler creates a new instance of WordsHandler
+func NewWordsHandler(tokens telegram.TokenIssuer, adder storage.Adder, searcher storage.Searcher,
+	lister storage.Lister) WordsHandler {
+	return WordsHandler{tokens: tokens, adder: adder, searcher: searcher, lister: lister}
+}
+
+// parseWord reads the word of the request, posted as JSON or as a form.
+func parseWord(r *http.Request) (incomingWord, error) {
+	var word incomingWord
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType == "application/json" {
+		err := json.NewDecoder(r.Body).Decode(&word)
+		return word, err
+	}
+
+	err := r.ParseForm()
+	if err != nil {
+		return word, err
+	}
+
+	word.Word = r.PostForm.Get("word")
+	word.Translation = r.PostForm.Get("translation")
+	word.Tags = r.PostForm.Get("tags")
+	word.Source = r.PostForm.Get("source")
+	return word, nil
+}
+
+// validSource reports whether the source is empty or the URL of a web page.
+func validSource(source string) bool {
+	if len(source) == 0 {
+		return true
+	}
+
+	parsed, err := url.Parse(source)
+	return err == nil && len(source) <= maxSourceLength && (parsed.Scheme == "https" || parsed.Scheme == "http") &&
+		len(parsed.Host) != 0
+}
+
+// respondWord responds the word as JSON with the status, the entry kept for the duplicates.
+func respondWord(w http.ResponseWriter, status int, word string, entry telegram.WordEntry, duplicate bool) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	err := json.NewEncoder(w).Encode(map[string]interface{}{"word": word, "translation": entry.Translation,
+		"tags": entry.Tags, "source": entry.Source, "duplicate": duplicate})
+	if err != nil {
+		log.Printf("Failed to respond to words request. %s.\n", err)
+	}
+}
+
+// normalizeTags splits the tags and lowercases them without #, like the tags typed in the chat.
+func normalizeTags(tags string) []string {
+	var normalized []string
+	for _, field := range strings.FieldsFunc(tags, func(r rune) bool { return r == ',' || r == ' ' }) {
+		tag := strings.ToLower(strings.TrimPrefix(field, "#"))
+		if len(tag) != 0 && !(telegram.WordEntry{Tags: normalized}).HasTag(tag) {
+			normalized = append(normalized, tag)
+		}
+	}
+
+	return normalized
+}
+
+// queryInt returns the integer query parameter, the default when it is missing, false when it is not a positive or
+// zero integer.
+func queryInt(r *http.Request, name string, defaultValue int) (int, bool) {
+	value := r.URL.Query().Get(name)
+	if len(value) == 0 {
+		return defaultValue, true
+	}
+
+	number, err := strconv.Atoi(value)
+	return number, err == nil && number >= 0
+}
+
+// listWords responds a page of the words of the user, with the total number of words.
+func (h WordsHandler) listWords(w http.ResponseWriter, r *http.Request, chatID int64) {
+	offset, validOffset := queryInt(r, "offset", 0)
+	limit, validLimit := queryInt(r, "limit", defaultListLimit)
+	if !validOffset || !validLimit || limit == 0 || limit > maxListLimit {
+		http.Error(w, fmt.Sprintf("offset must not be negative and limit between 1 and %d", maxListLimit),
+			http.StatusBadRequest)
+		return
+	}
+
+	// The words are filtered by tag, or else by source, e.g. topik1 for the words imported from topik1.csv.
+	filter := strings.ToLower(strings.TrimPrefix(r.URL.Query().Get("tag"), "#"))
+	if source := r.URL.Query().Get("source"); len(filter) == 0 && len(source) != 0 {
+		filter = telegram.SourceFilter + source
+	}
+
+	entries, total, err := h.lister.ListPage(chatID, telegram.KindVocabulary, filter, offset, limit)
+	if err != nil && err != telegram.ErrWordNotFound {
+		if err == telegram.ErrNotRegistered {
+			http.Error(w, err.Error(), http.StatusForbidden)
+		} else {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+
+		return
+	}
+
+	words := make([]listedWord, 0, len(entries))
+	for _, entry := range entries {
+		words = append(words, listedWord{Word: entry.Word, Translation: entry.Translation, Tags: entry.Tags,
+			Source: entry.Source, Origin: entry.EntryOrigin()})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+
+	err = json.NewEncoder(w).Encode(map[string]interface{}{"total": total, "offset": offset, "words": words})
+	if err != nil {
+		log.Printf("Failed to respond to words request. %s.\n", err)
+	}
+}
+
+func (h WordsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost && r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	chatID, err := h.tokens.ResolveToken(token)
+	if err != nil {
+		if err == telegram.ErrInvalidToken {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+		} else {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+
+		return
+	}
+
+	if r.Method == http.MethodGet {
+		h.listWords(w, r, chatID)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxWordsBody)
+	word, err := parseWord(r)
+	if err != nil {
+		http.Error(w, "invalid body", http.StatusBadRequest)
+		return
+	}
+
+	// The text selected in the browser may be decomposed, e.g. on macOS, while the words typed in the chat are not.
+	word.Word = norm.NFC.String(strings.TrimSpace(word.Word))
+	word.Translation = strings.TrimSpace(word.Translation)
+	word.Source = strings.TrimSpace(word.Source)
+	if len(word.Word) == 0 || len(word.Translation) == 0 {
+		http.Error(w, "word and translation are required", http.StatusBadRequest)
+		return
+	}
+
+	if !validSource(word.Source) {
+		http.Error(w, "source must be an http or https URL", http.StatusBadRequest)
+		return
+	}
+
+	if existing, err := h.searcher.SearchEntry(chatID, word.Word); err == nil {
+		respondWord(w, http.StatusOK, word.Word, *existing, true)
+		return
+	}
+
+	// The extension tells the page the word was captured from, the other services do not.
+	origin := telegram.OriginAPI
+	if len(word.Source) != 0 {
+		origin = telegram.OriginExtension
+	}
+
+	entry := telegram.WordEntry{Translation: word.Translation, Tags: normalizeTags(word.Tags), Source: word.Source,
+		Origin: origin}
+	err = h.adder.AddEntry(chatID, word.Word, entry)
+	if err != nil {
+		switch err {
+		case telegram.ErrDuplicateWord:
+			// Another request added it in the meantime.
+			http.Error(w, err.Error(), http.StatusConflict)
+		case telegram.ErrNotRegistered:
+			http.Error(w, err.Error(), http.StatusForbidden)
+		case telegram.ErrDatabaseError:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		default:
+			// The moderation refuses the words containing inappropriate language.
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		}
+
+		return
+	}
+
+	respondWord(w, http.StatusCreated, word.Word, entry, false)
+}